@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"ypost/internal/batch"
+	"ypost/internal/logger"
+	"ypost/pkg/models"
+)
+
+var (
+	fromList     string
+	batchJobs    int
+	batchSummary string
+)
+
+func init() {
+	postCmd.Flags().StringVar(&fromList, "from-list", "", "post every path listed in a file, one per line (- reads stdin)")
+	postCmd.Flags().IntVar(&batchJobs, "jobs", 1, "number of list entries posted in parallel")
+	postCmd.Flags().StringVar(&batchSummary, "summary", "", "write the batch summary to this file")
+}
+
+// postArgs accepts one path, or none with --from-list
+func postArgs(cmd *cobra.Command, args []string) error {
+	if fromList != "" {
+		return cobra.NoArgs(cmd, args)
+	}
+	return cobra.ExactArgs(1)(cmd, args)
+}
+
+// runBatch posts every entry of a list, continuing past failed entries, and
+// reports the results. It exits with status 1 when any entry failed.
+func runBatch(ctx context.Context, cfg *models.Config, listPath string, log *logger.Logger) {
+	var list io.Reader = os.Stdin
+	if listPath != "-" {
+		file, err := os.Open(listPath)
+		if err != nil {
+			log.Fatal("Failed to open list: %v", err)
+		}
+		defer file.Close()
+		list = file
+	}
+
+	paths, err := batch.ReadList(list)
+	if err != nil {
+		log.Fatal("Failed to read list: %v", err)
+	}
+	if len(paths) == 0 {
+		log.Fatal("No paths in list: %s", listPath)
+	}
+
+	jobs := batchJobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	log.Info("Posting %d list entries, %d at a time", len(paths), jobs)
+
+	results := batch.Run(ctx, paths, jobs, func(path string) (string, error) {
+		// Each job gets a shallow copy of the settings: the servers, group
+		// presets and other lists and maps in it are shared, and only read
+		jobCfg := *cfg
+		nzbPath, err := postPath(ctx, &jobCfg, path, log, nil, nil)
+		if err != nil {
+			log.Error("Failed to post %s: %v", path, err)
+		}
+		return nzbPath, err
+	})
+
+	summary, failed := batch.Summary(results)
+	for _, line := range strings.Split(strings.TrimRight(summary, "\n"), "\n") {
+		log.Info("%s", line)
+	}
+	if jsonOutput {
+		printJSON(newBatchOutcome(results))
+	}
+	if batchSummary != "" {
+		if err := os.WriteFile(batchSummary, []byte(summary), 0644); err != nil {
+			log.Error("Failed to write summary: %v", err)
+		}
+	}
+	if failed > 0 {
+		log.Fatal("%d of %d list entries failed", failed, len(results))
+	}
+}
+
+// batchOutcome is the JSON result of a batch
+type batchOutcome struct {
+	Entries []postSummary `json:"entries"`
+	Posted  int           `json:"posted"`
+	Failed  int           `json:"failed"`
+}
+
+// newBatchOutcome returns the outcome of the entries of a batch
+func newBatchOutcome(results []batch.Result) batchOutcome {
+	outcome := batchOutcome{Entries: []postSummary{}}
+	for _, result := range results {
+		outcome.Entries = append(outcome.Entries, newPostSummary(result.Path, result.NZBPath, 0, result.Duration, result.Err))
+		if result.Err != nil {
+			outcome.Failed++
+		} else {
+			outcome.Posted++
+		}
+	}
+	return outcome
+}
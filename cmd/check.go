@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"ypost/internal/check"
+	"ypost/internal/config"
+	"ypost/internal/nzb"
+	"ypost/pkg/models"
+)
+
+var (
+	checkServers     []string
+	checkThreshold   float64
+	checkConnections int
+	checkAllServers  bool
+	checkShowMissing bool
+)
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check <nzb>",
+	Short: "Check that every article of an NZB is on the servers",
+	Long: `Look every segment of an NZB up on one or more servers (STAT) and report
+the completion of each file. A segment counts as present when a checked server
+has it, or when every checked server has it with --all-servers. The command
+exits with status 1 when the overall completion is below --threshold percent,
+so scripts can wait for an upload to propagate.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runCheck,
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+
+	checkCmd.Flags().StringSliceVar(&checkServers, "server", nil, "host of a configured server to check with (repeatable, default all)")
+	checkCmd.Flags().Float64Var(&checkThreshold, "threshold", 100, "minimum completion in percent for a zero exit status")
+	checkCmd.Flags().IntVar(&checkConnections, "connections", 0, "connections per server (default: the server's max_connections)")
+	checkCmd.Flags().BoolVar(&checkAllServers, "all-servers", false, "count a segment as present only when every server has it")
+	checkCmd.Flags().BoolVar(&checkShowMissing, "show-missing", false, "list the numbers of the missing segments")
+	config.AddFlags(checkCmd.Flags())
+}
+
+// checkOutcome is the JSON result of a check
+type checkOutcome struct {
+	*check.Report
+	Percent   float64 `json:"percent"`
+	Threshold float64 `json:"threshold"`
+	Passed    bool    `json:"passed"`
+}
+
+func runCheck(cmd *cobra.Command, args []string) {
+	if checkThreshold < 0 || checkThreshold > 100 {
+		fmt.Printf("Error: threshold must be between 0 and 100\n")
+		os.Exit(1)
+	}
+
+	doc, err := nzb.ParseFile(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg, _, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	servers, err := checkTargets(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	names := make([]string, len(servers))
+	for i, server := range servers {
+		names[i] = server.Name
+	}
+	fmt.Printf("Checking %d segments of %d files on %s\n", doc.SegmentCount(), len(doc.Files), strings.Join(names, ", "))
+
+	report, err := check.Check(cmd.Context(), doc, servers, checkAllServers)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, file := range report.Files {
+		fmt.Printf("  %6.2f%%  %d/%d  %s\n", file.Percent(), file.Present, file.Segments, file.Name)
+		if len(names) > 1 {
+			for _, name := range names {
+				fmt.Printf("           %s: %d/%d\n", name, file.PerServer[name], file.Segments)
+			}
+		}
+		if checkShowMissing && len(file.Missing) > 0 {
+			fmt.Printf("           missing: %s\n", formatNumbers(file.Missing))
+		}
+	}
+	fmt.Printf("Completion: %.2f%% (%d/%d segments)\n", report.Percent(), report.Present, report.Segments)
+	if jsonOutput {
+		printJSON(checkOutcome{Report: report, Percent: report.Percent(), Threshold: checkThreshold, Passed: report.Percent() >= checkThreshold})
+	}
+
+	if report.Percent() < checkThreshold {
+		fmt.Printf("Below the %.2f%% threshold\n", checkThreshold)
+		os.Exit(1)
+	}
+}
+
+// checkTargets returns the configured servers selected with --server
+func checkTargets(cfg *models.Config) ([]check.Server, error) {
+	configured, err := selectServers(cfg, checkServers, models.RoleRead)
+	if err != nil {
+		return nil, err
+	}
+
+	var servers []check.Server
+	for _, server := range configured {
+		connections := checkConnections
+		if connections <= 0 {
+			connections = server.MaxConns
+		}
+		servers = append(servers, check.Server{
+			Name:        server.Host,
+			Connections: connections,
+			Dial: func() (check.Conn, error) {
+				return dialReader(server)
+			},
+		})
+	}
+	return servers, nil
+}
+
+// formatNumbers lists segment numbers, collapsing runs into ranges
+func formatNumbers(numbers []int) string {
+	var parts []string
+	for i := 0; i < len(numbers); {
+		j := i
+		for j+1 < len(numbers) && numbers[j+1] == numbers[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", numbers[i], numbers[j]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d", numbers[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
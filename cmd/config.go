@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"ypost/internal/config"
+)
+
+// configCmd groups the configuration management commands
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the configuration file",
+}
+
+// configValidateCmd represents the config validate command
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration for errors",
+	Long: `Load the configuration and run extended checks: unknown keys, placeholder
+values left from the sample configuration, conflicting sizes and missing
+directories. Findings are reported with their line in the configuration file.
+The command exits with status 1 when any error is found.`,
+	Args: cobra.NoArgs,
+	Run:  runConfigValidate,
+}
+
+var encryptDecrypt bool
+
+// configEncryptCmd represents the config encrypt command
+var configEncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt the server passwords in the configuration file",
+	Long: `Rewrite the configuration file with security.encrypt_passwords enabled, so
+plain server passwords are stored encrypted with the key from security.key_cmd
+or security.key_file (created if missing, default ~/.ypost/secret.key).
+${VAR} references and password_cmd servers are kept as they are. With
+--decrypt, the passwords are written back in plain text instead. Comments in
+the file are not preserved.`,
+	Args: cobra.NoArgs,
+	Run:  runConfigEncrypt,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configEncryptCmd)
+
+	configEncryptCmd.Flags().BoolVar(&encryptDecrypt, "decrypt", false, "store the passwords in plain text again")
+	// No settings flags: they would be saved to the file with the passwords
+}
+
+// validateOutcome is the JSON result of config validate
+type validateOutcome struct {
+	File        string              `json:"file"`
+	Valid       bool                `json:"valid"`
+	Diagnostics []config.Diagnostic `json:"diagnostics"`
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) {
+	configFile, diagnostics, err := config.Validate(cfgFile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	errors := 0
+	for _, diagnostic := range diagnostics {
+		fmt.Println(diagnostic.Format(configFile))
+		if diagnostic.Severity == config.SeverityError {
+			errors++
+		}
+	}
+	if jsonOutput {
+		if diagnostics == nil {
+			diagnostics = []config.Diagnostic{}
+		}
+		printJSON(validateOutcome{File: configFile, Valid: errors == 0, Diagnostics: diagnostics})
+	}
+
+	if errors > 0 {
+		fmt.Printf("%d error(s), %d warning(s)\n", errors, len(diagnostics)-errors)
+		os.Exit(1)
+	}
+	if len(diagnostics) > 0 {
+		fmt.Printf("Configuration is valid with %d warning(s)\n", len(diagnostics))
+		return
+	}
+	fmt.Println("Configuration is valid")
+}
+
+func runConfigEncrypt(cmd *cobra.Command, args []string) {
+	cfg, configFile, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	if configFile == "" {
+		fmt.Println("Error: no configuration file found")
+		os.Exit(1)
+	}
+
+	cfg.Security.EncryptPasswords = !encryptDecrypt
+	if err := config.SaveConfig(cfg, configFile); err != nil {
+		fmt.Printf("Error saving config: %v\n", err)
+		os.Exit(1)
+	}
+	if encryptDecrypt {
+		fmt.Printf("Passwords in %s are stored in plain text\n", configFile)
+		return
+	}
+	fmt.Printf("Passwords in %s are encrypted\n", configFile)
+}
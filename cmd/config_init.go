@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"ypost/internal/config"
+	"ypost/internal/nntp"
+	"ypost/pkg/models"
+)
+
+var initForce bool
+
+// configInitCmd represents the config init command
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create a configuration file interactively",
+	Long: `Prompt for the NNTP server, credentials, default newsgroup and output
+directories, test the connection to the server and write the configuration file
+(--config, or config.yaml in the current directory). Passwords may be given as
+${VAR} references, which are stored unresolved.`,
+	Args: cobra.NoArgs,
+	Run:  runConfigInit,
+}
+
+func init() {
+	configCmd.AddCommand(configInitCmd)
+
+	configInitCmd.Flags().BoolVarP(&initForce, "force", "f", false, "overwrite an existing configuration file")
+}
+
+func runConfigInit(cmd *cobra.Command, args []string) {
+	configPath := cfgFile
+	if configPath == "" {
+		configPath = "config.yaml"
+	}
+	if _, err := os.Stat(configPath); err == nil && !initForce {
+		fmt.Printf("Error: %s already exists (use --force to overwrite)\n", configPath)
+		os.Exit(1)
+	}
+
+	cfg, err := config.DefaultConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	wizard := &configWizard{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+	if err := wizard.run(cfg); err != nil {
+		fmt.Printf("\nError: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := config.SaveConfig(cfg, configPath); err != nil {
+		fmt.Printf("Error saving config: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Configuration written to %s\n", configPath)
+}
+
+// configWizard prompts for configuration values
+type configWizard struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// run fills cfg from the answers, testing the server connection before
+// accepting it
+func (w *configWizard) run(cfg *models.Config) error {
+	fmt.Fprintln(w.out, "NNTP server")
+	server := models.ServerConfig{SSL: true, Port: 563, MaxConns: 8}
+	for {
+		var err error
+		if server.Host, err = w.ask("  Host", server.Host, true); err != nil {
+			return err
+		}
+		if server.SSL, err = w.askBool("  Use SSL/TLS", server.SSL); err != nil {
+			return err
+		}
+		defaultPort := server.Port
+		if !server.SSL && defaultPort == 563 {
+			defaultPort = 119
+		}
+		if server.Port, err = w.askInt("  Port", defaultPort); err != nil {
+			return err
+		}
+		if server.Username, err = w.ask("  Username", server.Username, false); err != nil {
+			return err
+		}
+		if server.Password, err = w.askSecret("  Password (or ${VAR})"); err != nil {
+			return err
+		}
+		if server.MaxConns, err = w.askInt("  Connections", server.MaxConns); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w.out, "Testing connection to %s:%d... ", server.Host, server.Port)
+		if err := testServer(server); err != nil {
+			fmt.Fprintf(w.out, "failed: %v\n", err)
+			retry, askErr := w.askBool("Edit the server settings", true)
+			if askErr != nil {
+				return askErr
+			}
+			if retry {
+				continue
+			}
+		} else {
+			fmt.Fprintln(w.out, "ok")
+		}
+		break
+	}
+
+	// The legacy single-server keys would otherwise be saved as placeholders
+	cfg.NNTP.Servers = []models.ServerConfig{server}
+	cfg.NNTP.Server, cfg.NNTP.Username, cfg.NNTP.Password = "", "", ""
+
+	var err error
+	if server.Password != "" && !strings.Contains(server.Password, "${") {
+		if cfg.Security.EncryptPasswords, err = w.askBool("Encrypt the password in the configuration file", false); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(w.out, "Posting")
+	if cfg.Posting.Group, err = w.ask("  Default newsgroup", cfg.Posting.Group, true); err != nil {
+		return err
+	}
+	if cfg.Posting.PosterEmail, err = w.ask("  Poster address", "", true); err != nil {
+		return err
+	}
+	cfg.Posting.From = cfg.Posting.PosterEmail
+
+	fmt.Fprintln(w.out, "Output")
+	if cfg.Output.OutputDir, err = w.ask("  Output directory", cfg.Output.OutputDir, true); err != nil {
+		return err
+	}
+	if cfg.Output.NZBDir, err = w.ask("  NZB directory", cfg.Output.NZBDir, true); err != nil {
+		return err
+	}
+	if cfg.Output.LogDir, err = w.ask("  Log directory", cfg.Output.LogDir, true); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ask prompts for a line of text, returning def on an empty answer
+func (w *configWizard) ask(label string, def string, required bool) (string, error) {
+	for {
+		if def != "" {
+			fmt.Fprintf(w.out, "%s [%s]: ", label, def)
+		} else {
+			fmt.Fprintf(w.out, "%s: ", label)
+		}
+
+		line, err := w.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", fmt.Errorf("no answer for %s", strings.TrimSpace(label))
+		}
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = def
+		}
+		if answer != "" || !required {
+			return answer, nil
+		}
+	}
+}
+
+// askBool prompts for a yes/no answer
+func (w *configWizard) askBool(label string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		answer, err := w.ask(fmt.Sprintf("%s (%s)", label, hint), "", false)
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+	}
+}
+
+// askInt prompts for a positive number
+func (w *configWizard) askInt(label string, def int) (int, error) {
+	for {
+		answer, err := w.ask(label, strconv.Itoa(def), true)
+		if err != nil {
+			return 0, err
+		}
+		value, err := strconv.Atoi(answer)
+		if err == nil && value > 0 {
+			return value, nil
+		}
+		fmt.Fprintf(w.out, "  %q is not a positive number\n", answer)
+	}
+}
+
+// askSecret prompts for a password without echoing it on a terminal
+func (w *configWizard) askSecret(label string) (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return w.ask(label, "", false)
+	}
+
+	fmt.Fprintf(w.out, "%s: ", label)
+	secret, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(w.out)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return strings.TrimSpace(string(secret)), nil
+}
+
+// testServer connects and authenticates to a server, resolving ${VAR}
+// password references the way the configuration loader does
+func testServer(server models.ServerConfig) error {
+	password, err := config.ResolveSecret(server.Password, "")
+	if err != nil {
+		return err
+	}
+	server.Password = password
+
+	client := nntp.NewClient(&server)
+	if err := client.Connect(); err != nil {
+		return err
+	}
+	defer client.Quit()
+	return client.Authenticate()
+}
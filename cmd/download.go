@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"ypost/internal/config"
+	"ypost/internal/download"
+	"ypost/internal/nzb"
+	"ypost/pkg/models"
+)
+
+var (
+	downloadOutput      string
+	downloadServers     []string
+	downloadConnections int
+)
+
+// downloadCmd represents the download command
+var downloadCmd = &cobra.Command{
+	Use:   "download <nzb>",
+	Short: "Download and reassemble the files of an NZB",
+	Long: `Fetch every article of an NZB, decode it and reassemble the files, checking
+the yEnc checksums and, when the NZB lists an SFV file, the checksums of the
+files it covers. Each article is tried on the configured servers in turn.
+Padded files are cut to their real length and obfuscated names restored when
+the NZB (or its mapping sidecar) records them. The command exits with status 1
+when a file is incomplete or does not match its checksum, so own uploads can be
+round-trip tested.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runDownload,
+}
+
+func init() {
+	rootCmd.AddCommand(downloadCmd)
+
+	downloadCmd.Flags().StringVarP(&downloadOutput, "output", "o", ".", "directory to write the files to")
+	downloadCmd.Flags().StringSliceVar(&downloadServers, "server", nil, "host of a configured server to download from (repeatable, default all)")
+	downloadCmd.Flags().IntVar(&downloadConnections, "connections", 0, "articles fetched at once (default: the first server's max_connections)")
+	config.AddFlags(downloadCmd.Flags())
+}
+
+// downloadOutcome is the JSON result of a download
+type downloadOutcome struct {
+	*download.Result
+	OK bool `json:"ok"`
+}
+
+func runDownload(cmd *cobra.Command, args []string) {
+	nzbPath := args[0]
+	doc, err := nzb.ParseFile(nzbPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg, _, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	configured, err := selectServers(cfg, downloadServers, models.RoleRead)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	var servers []download.Server
+	for _, server := range configured {
+		servers = append(servers, download.Server{
+			Name: server.Host,
+			Dial: func() (download.Conn, error) {
+				return dialReader(server)
+			},
+		})
+	}
+
+	opts := download.Options{OutputDir: downloadOutput, Connections: downloadConnections}
+	if opts.Connections <= 0 {
+		opts.Connections = configured[0].MaxConns
+	}
+	if opts.Lengths, err = doc.Lengths(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.Names, err = doc.Mapping(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	sidecar, err := nzb.ReadMapping(nzbPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	for posted, name := range sidecar {
+		opts.Names[posted] = name
+	}
+
+	fmt.Printf("Downloading %d segments of %d files to %s\n", doc.SegmentCount(), len(doc.Files), downloadOutput)
+	result, err := download.Download(cmd.Context(), doc, servers, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, file := range result.Files {
+		status := "OK"
+		var problems []string
+		if file.Missing > 0 {
+			problems = append(problems, fmt.Sprintf("%d/%d segments missing", file.Missing, file.Segments))
+		}
+		if file.Damaged > 0 {
+			problems = append(problems, fmt.Sprintf("%d segments damaged", file.Damaged))
+		}
+		if file.FileCRCMismatch {
+			problems = append(problems, "CRC32 mismatch")
+		}
+		if len(problems) > 0 {
+			status = strings.Join(problems, ", ")
+		}
+		fmt.Printf("  %s (%d bytes, CRC32 %08X): %s\n", file.Path, file.Bytes, file.CRC32, status)
+	}
+	if len(result.SFVChecked) > 0 {
+		fmt.Printf("SFV: %d files checked, %d mismatched\n", len(result.SFVChecked), len(result.SFVMismatched))
+		for _, name := range result.SFVMismatched {
+			fmt.Printf("  checksum mismatch: %s\n", name)
+		}
+	}
+
+	if jsonOutput {
+		printJSON(downloadOutcome{Result: result, OK: result.OK()})
+	}
+
+	if !result.OK() {
+		fmt.Println("Download incomplete or damaged")
+		os.Exit(1)
+	}
+	fmt.Println("Download complete")
+}
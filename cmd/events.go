@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"context"
+
+	"ypost/internal/events"
+	"ypost/internal/logger"
+	"ypost/pkg/models"
+)
+
+// subscribePost subscribes the outputs of the post of filePath to its
+// events: the log, the tally of its articles, the history, the indexer, the
+// hooks and the notifications. It returns
+// the function ending the subscriptions.
+func subscribePost(ctx context.Context, cfg *models.Config, filePath string, postID string, log *logger.Logger, tally *postTally) func() {
+	var unsubscribe []func()
+	subscribe := func(handler events.Handler, kinds ...events.Kind) {
+		unsubscribe = append(unsubscribe, events.Subscribe(events.ForPost(postID, handler), kinds...))
+	}
+
+	subscribe(func(event events.Event) {
+		segment := event.Segment
+		log.LogUploadProgress(segment.FileName, segment.PartNumber, segment.TotalParts, segment.BytesPosted)
+		tally.add(segment)
+	}, events.SegmentPosted)
+	subscribe(func(event events.Event) {
+		tally.failed++
+	}, events.SegmentFailed)
+	subscribe(func(event events.Event) {
+		recordHistory(cfg, event.Record, log)
+	}, events.PostCompleted, events.Error)
+	subscribe(func(event events.Event) {
+		pushNZB(ctx, cfg, event.Record, log)
+	}, events.PostCompleted)
+	subscribe(func(event events.Event) {
+		runPostHook(ctx, cfg, filePath, event.Record, log)
+	}, events.PostCompleted, events.Error)
+	subscribe(func(event events.Event) {
+		if event.Kind == events.PostStarted {
+			notifyStart(ctx, cfg, event.File, event.Size, log)
+			return
+		}
+		notifyPost(ctx, cfg, event.Record, log)
+	}, events.PostStarted, events.PostCompleted, events.Error)
+
+	return func() {
+		for _, end := range unsubscribe {
+			end()
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ypost/internal/events"
+	"ypost/internal/logger"
+	"ypost/pkg/models"
+)
+
+// failureReportFile is the name of the failure summary in the output
+// directory of a post
+const failureReportFile = "ypost-failures.txt"
+
+// failureReport collects the articles of a post that needed retries or that
+// no server accepted. Events come from the collecting goroutine only.
+type failureReport struct {
+	posted  int
+	retried []*models.PostSegment
+	failed  []*models.PostSegment
+	// nzbWritten tells whether the post got as far as its NZB
+	nzbWritten bool
+}
+
+// add records a posted or refused article
+func (r *failureReport) add(event events.Event) {
+	if event.Segment == nil {
+		return
+	}
+	switch event.Kind {
+	case events.SegmentPosted:
+		r.posted++
+		if len(event.Segment.Failures) > 0 {
+			r.retried = append(r.retried, event.Segment)
+		}
+	case events.SegmentFailed:
+		r.failed = append(r.failed, event.Segment)
+	}
+}
+
+// finish logs the summary and writes it to the output directory when any
+// article needed a retry or failed
+func (r *failureReport) finish(outputDir string, log *logger.Logger) {
+	if len(r.retried) == 0 && len(r.failed) == 0 {
+		if r.posted > 0 {
+			log.Info("All %d articles were posted without retries", r.posted)
+		}
+		return
+	}
+
+	summary := formatFailureSummary(r.posted, r.retried, r.failed, r.nzbWritten)
+	for _, line := range strings.Split(strings.TrimRight(summary, "\n"), "\n") {
+		if len(r.failed) > 0 {
+			log.Warn("%s", line)
+		} else {
+			log.Info("%s", line)
+		}
+	}
+	path := filepath.Join(outputDir, failureReportFile)
+	if err := os.WriteFile(path, []byte(summary), 0644); err != nil {
+		log.Warn("Failed to write the failure summary: %v", err)
+		return
+	}
+	log.Info("Failure summary written to %s", path)
+}
+
+// formatFailureSummary renders the totals, then every retried and failed
+// article with the response of each server that refused it, then whether the
+// NZB, when one was written, can be trusted
+func formatFailureSummary(posted int, retried, failed []*models.PostSegment, nzbWritten bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d articles posted, %d after retries, %d failed\n", posted, len(retried), len(failed))
+	for _, segment := range retried {
+		fmt.Fprintf(&b, "RETRIED  %s (%d/%d) posted to %s\n", segment.FileName, segment.PartNumber, segment.TotalParts, segment.Server)
+		writeFailures(&b, segment.Failures)
+	}
+	for _, segment := range failed {
+		fmt.Fprintf(&b, "FAILED   %s (%d/%d)\n", segment.FileName, segment.PartNumber, segment.TotalParts)
+		writeFailures(&b, segment.Failures)
+	}
+	switch {
+	case len(failed) > 0 && nzbWritten:
+		fmt.Fprintf(&b, "The post is incomplete: %d articles were not posted and are left out of the NZB\n", len(failed))
+	case len(failed) > 0:
+		fmt.Fprintf(&b, "The post is incomplete: %d articles were not posted and no NZB was written\n", len(failed))
+	case nzbWritten:
+		fmt.Fprintf(&b, "Every article was posted in the end: the NZB is complete\n")
+	default:
+		fmt.Fprintf(&b, "The post stopped before its NZB was written\n")
+	}
+	return b.String()
+}
+
+// writeFailures renders the server responses of an article
+func writeFailures(b *strings.Builder, failures []models.ServerFailure) {
+	for _, failure := range failures {
+		fmt.Fprintf(b, "         %s: %s\n", failure.Server, failure.Response)
+	}
+}
+
+// uploadStopped is the error of an upload that ended before any file was
+// posted whole, telling apart one that posted nothing from one interrupted
+// after some articles, which the journal keeps for ypost resume
+func uploadStopped(posted int, err error) error {
+	if posted == 0 {
+		return fmt.Errorf("failed to upload any parts: %w", err)
+	}
+	return fmt.Errorf("upload stopped after %d articles were posted: %w", posted, err)
+}
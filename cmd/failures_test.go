@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"ypost/internal/events"
+	"ypost/pkg/models"
+)
+
+func TestFailureReportAdd(t *testing.T) {
+	var r failureReport
+	retried := &models.PostSegment{FileName: "a.bin", Failures: []models.ServerFailure{{Server: "news.example.com", Response: "441 refused"}}}
+	failed := &models.PostSegment{FileName: "a.bin"}
+	r.add(events.Event{Kind: events.SegmentPosted, Segment: &models.PostSegment{FileName: "a.bin"}})
+	r.add(events.Event{Kind: events.SegmentPosted, Segment: retried})
+	r.add(events.Event{Kind: events.SegmentFailed, Segment: failed})
+	r.add(events.Event{Kind: events.SegmentPosted})
+
+	if r.posted != 2 {
+		t.Errorf("expected 2 articles posted, got %d", r.posted)
+	}
+	if len(r.retried) != 1 || r.retried[0] != retried {
+		t.Errorf("expected the retried article, got %v", r.retried)
+	}
+	if len(r.failed) != 1 || r.failed[0] != failed {
+		t.Errorf("expected the failed article, got %v", r.failed)
+	}
+}
+
+func TestFormatFailureSummary(t *testing.T) {
+	retried := []*models.PostSegment{{
+		FileName: "a.bin", PartNumber: 2, TotalParts: 3, Server: "backup.example.com",
+		Failures: []models.ServerFailure{{Server: "news.example.com", Response: "441 posting failed"}},
+	}}
+	failed := []*models.PostSegment{{
+		FileName: "a.bin", PartNumber: 3, TotalParts: 3,
+		Failures: []models.ServerFailure{{Server: "news.example.com", Response: "441 posting failed"}},
+	}}
+
+	tests := []struct {
+		name       string
+		failed     []*models.PostSegment
+		nzbWritten bool
+		want       string
+	}{
+		{"failed with NZB", failed, true, "The post is incomplete: 1 articles were not posted and are left out of the NZB\n"},
+		{"failed without NZB", failed, false, "The post is incomplete: 1 articles were not posted and no NZB was written\n"},
+		{"retried with NZB", nil, true, "Every article was posted in the end: the NZB is complete\n"},
+		{"retried without NZB", nil, false, "The post stopped before its NZB was written\n"},
+	}
+	for _, test := range tests {
+		summary := formatFailureSummary(2, retried, test.failed, test.nzbWritten)
+		if !strings.HasSuffix(summary, test.want) {
+			t.Errorf("%s: expected the summary to end with %q, got:\n%s", test.name, test.want, summary)
+		}
+		if !test.nzbWritten && (strings.Contains(summary, "NZB is complete") || strings.Contains(summary, "left out of the NZB")) {
+			t.Errorf("%s: the summary speaks of an NZB never written:\n%s", test.name, summary)
+		}
+		if !strings.Contains(summary, "RETRIED  a.bin (2/3) posted to backup.example.com\n         news.example.com: 441 posting failed\n") {
+			t.Errorf("%s: expected the retried article with its server responses, got:\n%s", test.name, summary)
+		}
+	}
+
+	summary := formatFailureSummary(2, retried, failed, true)
+	if !strings.HasPrefix(summary, "2 articles posted, 1 after retries, 1 failed\n") {
+		t.Errorf("expected the totals first, got:\n%s", summary)
+	}
+	if !strings.Contains(summary, "FAILED   a.bin (3/3)\n") {
+		t.Errorf("expected the failed article, got:\n%s", summary)
+	}
+}
+
+func TestUploadStopped(t *testing.T) {
+	err := uploadStopped(0, context.Canceled)
+	if !errors.Is(err, context.Canceled) || !strings.HasPrefix(err.Error(), "failed to upload any parts") {
+		t.Errorf("expected an upload that posted nothing, got %v", err)
+	}
+
+	// An upload interrupted after some articles does not claim none was posted
+	err = uploadStopped(12, context.Canceled)
+	if !errors.Is(err, context.Canceled) || strings.Contains(err.Error(), "failed to upload any parts") || !strings.Contains(err.Error(), "12 articles") {
+		t.Errorf("expected an upload stopped after 12 articles, got %v", err)
+	}
+}
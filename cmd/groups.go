@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"ypost/internal/logger"
+	"ypost/internal/nntp"
+	"ypost/pkg/models"
+)
+
+// maxAlternatives bounds the configured groups looked up and suggested
+// instead of a group the servers do not take posts to
+const maxAlternatives = 5
+
+// checkPostingGroups looks the posting groups up on the posting servers
+// before anything is done, in failover order until one takes posts to all of
+// them, so a group missing or closed to posting fails the post at once
+// rather than each of its articles. A server that cannot be reached is left
+// to the upload, which retries and fails over.
+func checkPostingGroups(cfg *models.Config, log *logger.Logger) error {
+	groups := postingGroups(cfg)
+	if len(groups) == 0 {
+		return nil
+	}
+	servers := nntp.NewServerGroup(cfg.NNTP.Servers)
+	defer servers.CloseAll()
+
+	var refusing string
+	var problems []*nntp.GroupError
+	var alternatives []string
+	for _, pool := range servers.Pools() {
+		host := pool.Server().Host
+		client, err := pool.GetClient()
+		if err != nil {
+			log.Debug("Cannot check the groups on %s: %v", host, err)
+			continue
+		}
+		found, err := client.CheckGroups(groups)
+		if err == nil && len(found) > 0 && problems == nil {
+			alternatives = groupAlternatives(cfg, client, found[0].Group)
+		}
+		pool.Release(client)
+		if err != nil {
+			log.Debug("Cannot check the groups on %s: %v", host, err)
+			continue
+		}
+
+		if len(found) == 0 {
+			if problems != nil {
+				log.Warn("%s: %v; the articles go to %s", refusing, problems[0], host)
+			}
+			return nil
+		}
+		if problems == nil {
+			refusing, problems = host, found
+		}
+	}
+	if problems == nil {
+		return nil
+	}
+
+	var reasons []string
+	for _, problem := range problems {
+		reasons = append(reasons, problem.Error())
+	}
+	message := fmt.Sprintf("%s: %s", refusing, strings.Join(reasons, "; "))
+	if len(alternatives) > 0 {
+		message += fmt.Sprintf(" (configured groups the server takes posts to: %s)", strings.Join(alternatives, ", "))
+	}
+	return errors.New(message)
+}
+
+// groupAlternatives returns the configured groups, by group preset or
+// posting.newsgroup, the server takes posts to instead of a failing group,
+// those sharing most of its hierarchy first
+func groupAlternatives(cfg *models.Config, client *nntp.Client, group string) []string {
+	candidates := configuredGroups(cfg, group)
+	if len(candidates) > maxAlternatives {
+		candidates = candidates[:maxAlternatives]
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	problems, err := client.CheckGroups(candidates)
+	if err != nil {
+		return nil
+	}
+	refused := make(map[string]bool, len(problems))
+	for _, problem := range problems {
+		refused[problem.Group] = true
+	}
+	var alternatives []string
+	for _, group := range candidates {
+		if !refused[group] {
+			alternatives = append(alternatives, group)
+		}
+	}
+	return alternatives
+}
+
+// configuredGroups returns the groups of the configuration other than the
+// posting groups, those closest to group first
+func configuredGroups(cfg *models.Config, group string) []string {
+	posting := make(map[string]bool)
+	for _, name := range postingGroups(cfg) {
+		posting[name] = true
+	}
+	seen := make(map[string]bool)
+	var groups []string
+	add := func(name string) {
+		if name = strings.TrimSpace(name); name != "" && !posting[name] && !seen[name] {
+			seen[name] = true
+			groups = append(groups, name)
+		}
+	}
+	for name := range cfg.Groups {
+		add(name)
+	}
+	for _, name := range strings.Split(cfg.Posting.Newsgroup, ",") {
+		add(name)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := sharedHierarchy(group, groups[i]), sharedHierarchy(group, groups[j])
+		if a != b {
+			return a > b
+		}
+		return groups[i] < groups[j]
+	})
+	return groups
+}
+
+// sharedHierarchy returns the number of leading components two group names
+// share: 2 for alt.binaries.a and alt.binaries.b
+func sharedHierarchy(a, b string) int {
+	first, second := strings.Split(a, "."), strings.Split(b, ".")
+	n := 0
+	for n < len(first) && n < len(second) && first[n] == second[n] {
+		n++
+	}
+	return n
+}
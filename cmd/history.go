@@ -0,0 +1,410 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"ypost/internal/config"
+	"ypost/internal/history"
+	"ypost/internal/logger"
+	"ypost/internal/prune"
+	"ypost/internal/remote"
+	"ypost/internal/utils"
+	"ypost/pkg/models"
+)
+
+var (
+	historyLimit  int
+	historySince  string
+	historyFailed bool
+)
+
+// historyCmd groups the posting history commands
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the posting history",
+	Long: `Every post is recorded with its size, groups, NZB, number of articles,
+duration and outcome in a database (history.path, default ~/.ypost/history.db),
+unless history.enabled is false. A history.path ending in .jsonl keeps the
+history as JSON lines instead.`,
+}
+
+// historyListCmd represents the history list command
+var historyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the latest posts",
+	Args:  cobra.NoArgs,
+	Run:   runHistoryList,
+}
+
+// historyShowCmd represents the history show command
+var historyShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show the details of a post",
+	Long: `Show the details of a post, given its history ID or the post ID its log
+lines are prefixed with.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runHistoryShow,
+}
+
+// historySearchCmd represents the history search command
+var historySearchCmd = &cobra.Command{
+	Use:   "search <term>",
+	Short: "Find posts by file name, NZB path or group",
+	Args:  cobra.ExactArgs(1),
+	Run:   runHistorySearch,
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyListCmd, historyShowCmd, historySearchCmd)
+
+	historyListCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "number of posts to list (0 for all)")
+	historySearchCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "number of posts to list (0 for all)")
+	for _, command := range []*cobra.Command{historyListCmd, historySearchCmd} {
+		command.Flags().StringVar(&historySince, "since", "", "only list posts of this age or younger (e.g. 12h, 7d, 2w)")
+		command.Flags().BoolVar(&historyFailed, "failed", false, "only list failed posts")
+	}
+	config.AddFlags(historyCmd.PersistentFlags())
+}
+
+func runHistoryList(cmd *cobra.Command, args []string) {
+	store := openHistory()
+	defer store.Close()
+
+	records, err := store.Find(historyQuery(""))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	printHistory(records)
+}
+
+func runHistoryShow(cmd *cobra.Command, args []string) {
+	store := openHistory()
+	defer store.Close()
+
+	// A number is a history ID, anything else a post ID; a post ID of digits
+	// only is looked up when no history ID matches
+	var record *models.PostingHistory
+	var err error
+	if id, parseErr := strconv.ParseInt(args[0], 10, 64); parseErr == nil {
+		record, err = store.Get(id)
+	}
+	if record == nil {
+		if byPost, postErr := store.GetPost(args[0]); postErr == nil {
+			record, err = byPost, nil
+		} else if err == nil {
+			err = postErr
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if jsonOutput {
+		printJSON(record)
+		return
+	}
+
+	fmt.Printf("ID:          %d\n", record.ID)
+	if record.PostID != "" {
+		fmt.Printf("Post ID:     %s\n", record.PostID)
+	}
+	fmt.Printf("File:        %s\n", record.FileName)
+	fmt.Printf("Size:        %s (%d bytes)\n", utils.FormatFileSize(record.FileSize), record.FileSize)
+	if record.ContentHash != "" {
+		fmt.Printf("Content:     %s\n", record.ContentHash)
+	}
+	fmt.Printf("Groups:      %s\n", strings.Join(record.Groups, ", "))
+	fmt.Printf("Posted:      %s\n", record.PostedAt.Format(time.RFC3339))
+	fmt.Printf("Duration:    %s\n", record.Duration.Round(time.Second))
+	fmt.Printf("Message-IDs: %d\n", record.MessageIDs)
+	if record.Server != "" {
+		fmt.Printf("Server:      %s (%d retries)\n", record.Server, record.Retries)
+	}
+	if record.NZBPath != "" {
+		fmt.Printf("NZB:         %s\n", record.NZBPath)
+	}
+	if record.RoundTrip != "" {
+		fmt.Printf("Round trip:  %s\n", record.RoundTrip)
+	}
+	switch {
+	case record.Success:
+		fmt.Printf("Status:      success\n")
+	case record.Failed > 0:
+		fmt.Printf("Status:      incomplete, %d articles not posted: %s\n", record.Failed, record.Error)
+	default:
+		fmt.Printf("Status:      failed: %s\n", record.Error)
+	}
+}
+
+func runHistorySearch(cmd *cobra.Command, args []string) {
+	store := openHistory()
+	defer store.Close()
+
+	records, err := store.Find(historyQuery(args[0]))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	printHistory(records)
+}
+
+// historyQuery returns the query of the history flags, exiting when they are
+// invalid
+func historyQuery(term string) history.Query {
+	query := history.Query{Term: term, Limit: historyLimit}
+	if historySince != "" {
+		age, err := prune.ParseAge(historySince)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		query.Since = time.Now().Add(-age)
+	}
+	if historyFailed {
+		success := false
+		query.Success = &success
+	}
+	return query
+}
+
+// printHistory prints posts as a table or JSON
+func printHistory(records []*models.PostingHistory) {
+	if jsonOutput {
+		if records == nil {
+			records = []*models.PostingHistory{}
+		}
+		printJSON(records)
+		return
+	}
+	if len(records) == 0 {
+		fmt.Println("No posts found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPOSTED\tSTATUS\tSIZE\tARTICLES\tDURATION\tFILE")
+	for _, record := range records {
+		status := "ok"
+		if record.Failed > 0 {
+			status = "incomplete"
+		} else if !record.Success {
+			status = "failed"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%s\t%s\n", record.ID, record.PostedAt.Format("2006-01-02 15:04"), status,
+			utils.FormatFileSize(record.FileSize), record.MessageIDs, record.Duration.Round(time.Second), record.FileName)
+	}
+	w.Flush()
+}
+
+// historyPath returns the history database of the configuration
+func historyPath(cfg *models.Config) (string, error) {
+	if cfg.History.Path != "" {
+		return cfg.History.Path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".ypost", "history.db"), nil
+}
+
+// openHistory loads the configuration and opens its history, exiting on error
+func openHistory() *history.Store {
+	cfg, _, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	path, err := historyPath(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	store, err := history.Open(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return store
+}
+
+// postTally counts the articles of a post and the servers that accepted them
+type postTally struct {
+	articles int
+	retries  int
+	// failed counts the articles no server accepted
+	failed  int
+	servers map[string]int
+	// roundTrip is the outcome of the download of the post, once done
+	roundTrip string
+}
+
+// roundTripped records the outcome of the download of the post
+func (t *postTally) roundTripped(passed bool) {
+	t.roundTrip = models.RoundTripFailed
+	if passed {
+		t.roundTrip = models.RoundTripPassed
+	}
+}
+
+// add counts a posted article
+func (t *postTally) add(segment *models.PostSegment) {
+	t.articles++
+	t.retries += segment.Retries
+	if segment.Server == "" {
+		return
+	}
+	if t.servers == nil {
+		t.servers = make(map[string]int)
+	}
+	t.servers[segment.Server]++
+}
+
+// server returns the host that accepted the most articles
+func (t *postTally) server() string {
+	best := ""
+	for host, count := range t.servers {
+		if count > t.servers[best] || (count == t.servers[best] && host < best) {
+			best = host
+		}
+	}
+	return best
+}
+
+// postRecord returns the outcome of a post, as recorded in the history and
+// notified
+func postRecord(cfg *models.Config, name string, size int64, nzbPath string, tally *postTally, duration time.Duration, postErr error) *models.PostingHistory {
+	record := &models.PostingHistory{
+		FileName:   name,
+		FileSize:   size,
+		NZBPath:    nzbPath,
+		MessageIDs: tally.articles,
+		PostedAt:   time.Now().Add(-duration),
+		Duration:   duration,
+		Success:    postErr == nil,
+		Server:     tally.server(),
+		Retries:    tally.retries,
+		Failed:     tally.failed,
+		RoundTrip:  tally.roundTrip,
+	}
+	if postErr != nil {
+		record.Error = postErr.Error()
+	}
+	record.Groups = postingGroups(cfg)
+	return record
+}
+
+// postedFile returns the name of a posted file, directory or URL and the
+// size of the files posted
+func postedFile(filePath string) (string, int64) {
+	name, size := filepath.Base(filePath), int64(0)
+	if remote.IsURL(filePath) {
+		if obj, err := remote.Open(context.Background(), filePath); err == nil {
+			name, size = obj.Name(), obj.Size()
+			obj.Close()
+		}
+	} else if files, err := utils.CollectFiles(filePath); err == nil {
+		for _, file := range files {
+			if info, err := os.Stat(file); err == nil {
+				size += info.Size()
+			}
+		}
+	}
+	return name, size
+}
+
+// checkPostedBefore looks the content of a file or directory up in the
+// history and returns its hash. Content posted successfully within
+// history.duplicate_window fails the post under history.duplicates abort,
+// and only warns under warn; a post of the same name and size, as recorded
+// before content hashes, warns. URLs are not hashed.
+func checkPostedBefore(cfg *models.Config, filePath string, name string, size int64, log *logger.Logger) (string, error) {
+	if !cfg.History.Enabled || cfg.History.Duplicates == models.DuplicatesOff {
+		return "", nil
+	}
+	path, err := historyPath(cfg)
+	if err != nil {
+		return "", nil
+	}
+
+	var hash string
+	if !remote.IsURL(filePath) {
+		files, err := utils.CollectFiles(filePath)
+		if err == nil {
+			hash, err = history.ContentHash(files)
+		}
+		if err != nil {
+			log.Warn("Cannot look for earlier posts of %s: %v", name, err)
+			return "", nil
+		}
+	}
+
+	store, err := history.Open(path)
+	if err != nil {
+		log.Warn("Failed to read the history: %v", err)
+		return hash, nil
+	}
+	defer store.Close()
+
+	// The window was checked with the configuration
+	window, _ := prune.ParseAge(cfg.History.DuplicateWindow)
+	var since time.Time
+	if window > 0 {
+		since = time.Now().Add(-window)
+	}
+	if hash != "" {
+		posted, err := store.PostedContent(hash, since)
+		if err != nil {
+			log.Warn("Failed to read the history: %v", err)
+			return hash, nil
+		}
+		if posted != nil {
+			duplicate := fmt.Sprintf("the content of %s was already posted on %s as %s (history post %d)",
+				name, posted.PostedAt.Format("2006-01-02 15:04"), posted.FileName, posted.ID)
+			if cfg.History.Duplicates != models.DuplicatesWarn {
+				return hash, fmt.Errorf("%s; use --force to post it again", duplicate)
+			}
+			log.Warn("Posting again: %s", duplicate)
+			return hash, nil
+		}
+	}
+	if posted, err := store.Posted(name, size); err != nil {
+		log.Warn("Failed to read the history: %v", err)
+	} else if posted != nil && posted.ContentHash == "" {
+		log.Warn("%s was already posted on %s (history post %d)", name, posted.PostedAt.Format("2006-01-02 15:04"), posted.ID)
+	}
+	return hash, nil
+}
+
+// recordHistory records the outcome of a post when the history is enabled; a
+// history that cannot be written only logs a warning
+func recordHistory(cfg *models.Config, record *models.PostingHistory, log *logger.Logger) {
+	if !cfg.History.Enabled {
+		return
+	}
+
+	path, err := historyPath(cfg)
+	if err != nil {
+		log.Warn("Failed to record post in history: %v", err)
+		return
+	}
+	store, err := history.Open(path)
+	if err != nil {
+		log.Warn("Failed to record post in history: %v", err)
+		return
+	}
+	defer store.Close()
+	if err := store.Add(record); err != nil {
+		log.Warn("Failed to record post in history: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"ypost/internal/hook"
+	"ypost/internal/logger"
+	"ypost/internal/remote"
+	"ypost/pkg/models"
+)
+
+// runPreHook runs hooks.pre_post before the post of filePath starts; a
+// failing hook refuses the post
+func runPreHook(ctx context.Context, cfg *models.Config, filePath string, name string, size int64, log *logger.Logger) error {
+	if cfg.Hooks.PrePost == "" {
+		return nil
+	}
+	runner, err := hook.New(cfg.Hooks)
+	if err != nil {
+		return err
+	}
+	job := hook.Job{
+		PostID:   log.PostID(),
+		Source:   hookSource(filePath),
+		Name:     name,
+		Size:     size,
+		Groups:   postingGroups(cfg),
+		Category: cfg.NZB.Category,
+	}
+	log.Debug("Running the %s hook", hook.PrePost)
+	output, err := runner.Run(ctx, hook.PrePost, job)
+	logHookOutput(hook.PrePost, output, log)
+	return err
+}
+
+// runPostHook runs hooks.post_success or hooks.post_failure with the record
+// of the finished post of filePath. A failing hook only logs a warning.
+func runPostHook(ctx context.Context, cfg *models.Config, filePath string, record *models.PostingHistory, log *logger.Logger) {
+	name := hook.PostSuccess
+	if !record.Success {
+		name = hook.PostFailure
+	}
+	runner, err := hook.New(cfg.Hooks)
+	if err != nil {
+		log.Warn("%v", err)
+		return
+	}
+	if !runner.Has(name) {
+		return
+	}
+	job := hook.Job{
+		PostID:   record.PostID,
+		Source:   hookSource(filePath),
+		Name:     record.FileName,
+		Size:     record.FileSize,
+		Groups:   record.Groups,
+		Category: cfg.NZB.Category,
+		Result:   record,
+	}
+	log.Debug("Running the %s hook", name)
+	// An interrupted post still runs its hook
+	output, err := runner.Run(context.WithoutCancel(ctx), name, job)
+	logHookOutput(name, output, log)
+	if err != nil {
+		log.Warn("%v", err)
+	}
+}
+
+// hookSource returns the source of a job: its URL, or its absolute path, as
+// hooks may not run where the post was started
+func hookSource(filePath string) string {
+	if remote.IsURL(filePath) {
+		return filePath
+	}
+	if abs, err := filepath.Abs(filePath); err == nil {
+		return abs
+	}
+	return filePath
+}
+
+// logHookOutput logs the lines a hook wrote, in verbose mode
+func logHookOutput(name string, output []byte, log *logger.Logger) {
+	for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		if line != "" {
+			log.Debug("%s: %s", name, line)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"ypost/internal/config"
+	"ypost/internal/indexer"
+	"ypost/internal/logger"
+	"ypost/internal/nzb"
+	"ypost/pkg/models"
+)
+
+// nzbPushCategory is the --category of nzb push
+var nzbPushCategory string
+
+// nzbPushCmd represents the nzb push command
+var nzbPushCmd = &cobra.Command{
+	Use:   "push <file.nzb>...",
+	Short: "Push NZBs to the configured newznab indexer",
+	Long: `Submit NZBs to the indexer of the indexer settings, as is done after every
+successful post. The newznab category is that of the first indexer.categories
+mapping matching the category in the head of the NZB or one of its groups,
+then indexer.default_category, unless --category gives it.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runNZBPush,
+}
+
+func init() {
+	nzbCmd.AddCommand(nzbPushCmd)
+
+	nzbPushCmd.Flags().StringVar(&nzbPushCategory, "category", "", "newznab category of the NZBs, e.g. 2040")
+	config.AddFlags(nzbPushCmd.Flags())
+}
+
+func runNZBPush(cmd *cobra.Command, args []string) {
+	cfg, _, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	if cfg.Indexer.URL == "" {
+		fmt.Println("Error: no indexer configured (indexer.url)")
+		os.Exit(1)
+	}
+
+	log := newLogger(cfg)
+	defer log.Close()
+
+	idx, err := newIndexer(cfg, log)
+	if err != nil {
+		log.Fatal("Failed to push: %v", err)
+	}
+	failed := 0
+	for _, nzbPath := range args {
+		category := nzbPushCategory
+		if category == "" {
+			doc, err := nzb.ParseFile(nzbPath)
+			if err != nil {
+				log.Error("Failed to read %s: %v", nzbPath, err)
+				failed++
+				continue
+			}
+			category = idx.Category(doc.MetaValue("category"), doc.Groups())
+		}
+		if err := idx.Push(cmd.Context(), nzbPath, category); err != nil {
+			log.Error("Failed to push %s: %v", nzbPath, err)
+			failed++
+			continue
+		}
+		log.Info("Pushed %s to %s in category %s", nzbPath, idx, categoryName(category))
+	}
+	if failed > 0 {
+		log.Close()
+		os.Exit(1)
+	}
+}
+
+// pushNZB submits the NZB of a successful post to the configured indexer. A
+// push that fails only logs a warning; the NZB can be pushed again with nzb
+// push.
+func pushNZB(ctx context.Context, cfg *models.Config, record *models.PostingHistory, log *logger.Logger) {
+	if cfg.Indexer.URL == "" || !record.Success || record.NZBPath == "" {
+		return
+	}
+	if record.Failed > 0 {
+		log.Warn("Not pushing %s to the indexer: %d article(s) failed", record.NZBPath, record.Failed)
+		return
+	}
+
+	idx, err := newIndexer(cfg, log)
+	if err != nil {
+		log.Warn("Failed to push the NZB: %v", err)
+		return
+	}
+	category := idx.Category(cfg.NZB.Category, record.Groups)
+	// An interrupted run still pushes the NZB of a post it finished
+	if err := idx.Push(context.WithoutCancel(ctx), record.NZBPath, category); err != nil {
+		log.Warn("Failed to push the NZB: %v", err)
+		return
+	}
+	log.Info("Pushed %s to %s in category %s", record.NZBPath, idx, categoryName(category))
+}
+
+// newIndexer returns the configured indexer, its API key resolved
+func newIndexer(cfg *models.Config, log *logger.Logger) (*indexer.Indexer, error) {
+	indexerConfig := cfg.Indexer
+	// The key may reference environment variables, as passwords do
+	apiKey, err := config.ResolveSecret(indexerConfig.APIKey, "")
+	if err != nil {
+		return nil, err
+	}
+	log.Redact(apiKey)
+	indexerConfig.APIKey = apiKey
+	return indexer.New(indexerConfig)
+}
+
+// categoryName names a newznab category in log lines
+func categoryName(category string) string {
+	if category == "" {
+		return "(none)"
+	}
+	return category
+}
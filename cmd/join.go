@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"ypost/internal/sfv"
+	"ypost/internal/splitter"
+)
+
+var joinOutput string
+
+// joinCmd represents the join command
+var joinCmd = &cobra.Command{
+	Use:   "join [dir-or-glob]",
+	Short: "Reassemble split parts into the original file",
+	Long: `Reassemble split part files into the original file. Parts are ordered by
+volume number (name.partNN.ext, name.partNN.rar or name.rar/name.rNN) and, when
+an SFV file is found next to them, verified before joining.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runJoin,
+}
+
+func init() {
+	rootCmd.AddCommand(joinCmd)
+
+	joinCmd.Flags().StringVarP(&joinOutput, "output", "o", "", "output file path")
+	joinCmd.MarkFlagRequired("output")
+}
+
+func runJoin(cmd *cobra.Command, args []string) {
+	partPaths, sfvPaths, err := collectJoinInputs(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(partPaths) == 0 {
+		fmt.Printf("Error: no part files found in %s\n", args[0])
+		os.Exit(1)
+	}
+
+	parts, err := splitter.DiscoverParts(partPaths)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Verify parts against any SFV found alongside them
+	for _, sfvPath := range sfvPaths {
+		mismatched, err := sfv.NewGenerator(filepath.Dir(sfvPath)).VerifyFiles(sfvPath, partPaths)
+		if err != nil {
+			fmt.Printf("Error verifying %s: %v\n", sfvPath, err)
+			os.Exit(1)
+		}
+		if len(mismatched) > 0 {
+			fmt.Printf("Error: checksum mismatch for %s\n", strings.Join(mismatched, ", "))
+			os.Exit(1)
+		}
+		fmt.Printf("Verified parts against %s\n", filepath.Base(sfvPath))
+	}
+
+	for _, part := range parts {
+		fmt.Printf("  %02d %s (%d bytes)\n", part.PartNumber, part.FileName, part.Size)
+	}
+
+	split := splitter.NewSplitter(1)
+	if err := split.JoinParts(parts, joinOutput); err != nil {
+		fmt.Printf("Error joining parts: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Joined %d parts into %s\n", len(parts), joinOutput)
+}
+
+// collectJoinInputs expands a directory or glob into part files and SFV files.
+// Recovery and index files produced alongside the parts are skipped.
+func collectJoinInputs(pattern string) ([]string, []string, error) {
+	var candidates []string
+
+	info, err := os.Stat(pattern)
+	if err == nil && info.IsDir() {
+		entries, err := os.ReadDir(pattern)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read directory: %w", err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				candidates = append(candidates, filepath.Join(pattern, entry.Name()))
+			}
+		}
+	} else {
+		candidates, err = filepath.Glob(pattern)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+	}
+
+	var partPaths, sfvPaths []string
+	for _, candidate := range candidates {
+		switch strings.ToLower(filepath.Ext(candidate)) {
+		case ".sfv":
+			sfvPaths = append(sfvPaths, candidate)
+		case ".par2", ".nzb", ".json", ".log":
+			continue
+		default:
+			if candidate == joinOutput {
+				continue
+			}
+			partPaths = append(partPaths, candidate)
+		}
+	}
+
+	return partPaths, sfvPaths, nil
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ypost/internal/nfo"
+	"ypost/pkg/models"
+)
+
+// nfoRelease returns what the NFO template of a post receives: the input
+// files under the names they are posted as, with the CRC32 computed while
+// planning their parts
+func nfoRelease(cfg *models.Config, name string, postID string, poster string, inputFiles []string, postedNames []string, inputParts [][]*models.FilePart, crcs map[string]uint32) nfo.Release {
+	release := nfo.Release{
+		Name:   name,
+		Group:  cfg.Posting.Group,
+		Poster: poster,
+		PostID: postID,
+		Date:   time.Now(),
+	}
+	for i, inputFile := range inputFiles {
+		file := nfo.File{Name: postedNames[i], Size: sumPartSizes(inputParts[i])}
+		if crc, ok := crcs[inputFile]; ok {
+			file.CRC32 = fmt.Sprintf("%08X", crc)
+		}
+		release.Files = append(release.Files, file)
+		release.Size += file.Size
+	}
+	return release
+}
+
+// nfoInput returns the first input file that is an NFO, which is posted with
+// the others instead of another one
+func nfoInput(inputFiles []string) string {
+	for _, inputFile := range inputFiles {
+		if strings.EqualFold(filepath.Ext(inputFile), ".nfo") {
+			return inputFile
+		}
+	}
+	return ""
+}
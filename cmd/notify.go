@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"ypost/internal/logger"
+	"ypost/internal/notify"
+	"ypost/pkg/models"
+)
+
+// notifyStart tells the configured chats a post starts; only chats are
+// told about starts
+func notifyStart(ctx context.Context, cfg *models.Config, name string, size int64, log *logger.Logger) {
+	if len(cfg.Notifications.Chats) == 0 {
+		return
+	}
+	notifyEvent(ctx, cfg, notify.Event{
+		Kind:   notify.EventStart,
+		PostID: log.PostID(),
+		File:   name,
+		Size:   size,
+		Groups: postingGroups(cfg),
+		Time:   time.Now(),
+	}, log)
+}
+
+// notifyPost sends the outcome of a post to the configured webhooks, mail
+// recipients, chats and apps
+func notifyPost(ctx context.Context, cfg *models.Config, record *models.PostingHistory, log *logger.Logger) {
+	kind := notify.EventComplete
+	if !record.Success {
+		kind = notify.EventFailure
+	}
+	password := ""
+	if cfg.Archive.Format != "" {
+		password = cfg.Archive.Password
+	}
+	notifyEvent(ctx, cfg, notify.Event{
+		Kind:     kind,
+		PostID:   record.PostID,
+		File:     record.FileName,
+		Size:     record.FileSize,
+		Duration: record.Duration.Seconds(),
+		NZB:      record.NZBPath,
+		Groups:   record.Groups,
+		Articles: record.MessageIDs,
+		Success:  record.Success,
+		Error:    record.Error,
+		Category: cfg.NZB.Category,
+		Password: password,
+		Time:     time.Now(),
+	}, log)
+}
+
+// notifyEvent sends an event to the configured notifiers. A notification
+// that cannot be delivered only logs a warning.
+func notifyEvent(ctx context.Context, cfg *models.Config, event notify.Event, log *logger.Logger) {
+	notifiers, err := notify.New(cfg)
+	if err != nil {
+		log.Warn("Failed to notify: %v", err)
+		return
+	}
+	// An interrupted post is still reported
+	ctx = context.WithoutCancel(ctx)
+	for _, notifier := range notifiers {
+		if err := notifier.Notify(ctx, event); err != nil {
+			log.Warn("Failed to notify: %v", err)
+		} else {
+			log.Debug("Notified %s of the %s of %s", notifier, event.Kind, event.File)
+		}
+	}
+}
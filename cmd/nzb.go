@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"ypost/internal/nzb"
+	"ypost/internal/utils"
+)
+
+var nzbInfoGaps bool
+
+// nzbCmd groups the NZB commands
+var nzbCmd = &cobra.Command{
+	Use:   "nzb",
+	Short: "Inspect NZB files and push them to an indexer",
+}
+
+// nzbInfoCmd represents the nzb info command
+var nzbInfoCmd = &cobra.Command{
+	Use:   "info <file.nzb>",
+	Short: "Show the files, size, groups and meta of an NZB",
+	Long: `Print the meta entries, posters, groups and files of an NZB with their
+segment counts and sizes. With --gaps, the segments missing from each file
+(numbers absent from 1 to the count its subject announces) are listed and the
+command exits with status 1 when there are any.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runNZBInfo,
+}
+
+func init() {
+	rootCmd.AddCommand(nzbCmd)
+	nzbCmd.AddCommand(nzbInfoCmd)
+
+	nzbInfoCmd.Flags().BoolVar(&nzbInfoGaps, "gaps", false, "list the segments missing from each file")
+}
+
+// nzbFileInfo is a file of the JSON result of nzb info
+type nzbFileInfo struct {
+	Name     string    `json:"name"`
+	Subject  string    `json:"subject"`
+	Poster   string    `json:"poster"`
+	Date     time.Time `json:"date"`
+	Groups   []string  `json:"groups"`
+	Segments int       `json:"segments"`
+	Expected int       `json:"expected_segments"`
+	Bytes    int64     `json:"bytes"`
+	Gaps     []int     `json:"gaps,omitempty"`
+}
+
+// nzbInfo is the JSON result of nzb info
+type nzbInfo struct {
+	Meta     map[string]string `json:"meta"`
+	Posters  []string          `json:"posters"`
+	Groups   []string          `json:"groups"`
+	Files    []nzbFileInfo     `json:"files"`
+	Segments int               `json:"segments"`
+	Bytes    int64             `json:"bytes"`
+}
+
+func runNZBInfo(cmd *cobra.Command, args []string) {
+	doc, err := nzb.ParseFile(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	info := nzbInfo{Meta: make(map[string]string), Posters: doc.Posters(), Groups: doc.Groups(), Segments: doc.SegmentCount()}
+	gaps := 0
+	for _, meta := range doc.Meta {
+		info.Meta[meta.Type] = meta.Value
+	}
+	for i := range doc.Files {
+		file := &doc.Files[i]
+		fileInfo := nzbFileInfo{
+			Name:     file.Name(),
+			Subject:  file.Subject,
+			Poster:   file.Poster,
+			Date:     time.Unix(file.Date, 0).UTC(),
+			Groups:   file.Groups,
+			Segments: len(file.Segments),
+			Expected: file.ExpectedSegments(),
+			Bytes:    file.Bytes(),
+		}
+		if nzbInfoGaps {
+			fileInfo.Gaps = file.Gaps()
+			gaps += len(fileInfo.Gaps)
+		}
+		info.Files = append(info.Files, fileInfo)
+		info.Bytes += fileInfo.Bytes
+	}
+
+	for _, meta := range doc.Meta {
+		fmt.Printf("%-10s %s\n", meta.Type+":", meta.Value)
+	}
+	fmt.Printf("%-10s %s\n", "Posters:", strings.Join(info.Posters, ", "))
+	fmt.Printf("%-10s %s\n", "Groups:", strings.Join(info.Groups, ", "))
+	fmt.Printf("%-10s %d files, %d segments, %s (%d bytes)\n\n", "Total:", len(info.Files), info.Segments, utils.FormatFileSize(info.Bytes), info.Bytes)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SEGMENTS\tSIZE\tFILE")
+	for _, file := range info.Files {
+		fmt.Fprintf(w, "%d/%d\t%s\t%s\n", file.Segments, file.Expected, utils.FormatFileSize(file.Bytes), file.Name)
+	}
+	w.Flush()
+
+	if nzbInfoGaps {
+		for _, file := range info.Files {
+			if len(file.Gaps) > 0 {
+				fmt.Printf("%s: missing segments %s\n", file.Name, formatNumbers(file.Gaps))
+			}
+		}
+		if gaps == 0 {
+			fmt.Println("No missing segments")
+		}
+	}
+	if jsonOutput {
+		printJSON(info)
+	}
+
+	if gaps > 0 {
+		os.Exit(1)
+	}
+}
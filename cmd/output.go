@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+	"ypost/internal/progress"
+	"ypost/internal/upload"
+)
+
+var (
+	// jsonOutput emits the results of commands as JSON on stdout
+	jsonOutput bool
+	// resultOut receives the results of commands. In JSON mode it is the
+	// process's stdout while os.Stdout is pointed at stderr, so the messages,
+	// logs and progress of every command go to stderr and stdout holds only
+	// JSON.
+	resultOut io.Writer = os.Stdout
+	// progressJSON is where progress events are written as JSON lines
+	progressJSON string
+)
+
+// initOutput sets up the output of the --json and --quiet modes
+func initOutput() {
+	if jsonOutput {
+		resultOut = os.Stdout
+		os.Stdout = os.Stderr
+	}
+
+	// Progress bars would garble the output captured under cron or systemd,
+	// so they are only drawn on a terminal
+	progress.SetVisible(!quiet && term.IsTerminal(int(os.Stdout.Fd())))
+	progress.SetSinkFactory(newBarSink)
+
+	switch progressJSON {
+	case "":
+	case "-":
+		// Next to the results in JSON mode, the messages being on stderr
+		progress.SetEventOutput(resultOut)
+	default:
+		out, err := progress.OpenEventOutput(progressJSON)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		progress.SetEventOutput(out)
+	}
+}
+
+// printJSON prints a value as indented JSON to the result output
+func printJSON(value interface{}) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintln(resultOut, string(data))
+}
+
+// postSummary is the JSON result of a post
+type postSummary struct {
+	Path     string        `json:"path"`
+	NZBPath  string        `json:"nzb_path,omitempty"`
+	Articles int           `json:"articles,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Success  bool          `json:"success"`
+	// Failed is the number of articles left out of the NZB of an
+	// incomplete post
+	Failed int    `json:"failed,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// newPostSummary returns the summary of a post's outcome
+func newPostSummary(path string, nzbPath string, articles int, duration time.Duration, err error) postSummary {
+	summary := postSummary{Path: path, NZBPath: nzbPath, Articles: articles, Duration: duration, Success: err == nil, Failed: upload.IncompleteArticles(err)}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	return summary
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"ypost/internal/par2"
+	"ypost/internal/utils"
+)
+
+var (
+	par2Redundancy int
+	par2Output     string
+	par2Name       string
+	par2BlockSize  int
+)
+
+// par2Cmd groups the PAR2 commands
+var par2Cmd = &cobra.Command{
+	Use:   "par2",
+	Short: "Create PAR2 recovery files",
+}
+
+// par2CreateCmd represents the par2 create command
+var par2CreateCmd = &cobra.Command{
+	Use:   "create <files...>",
+	Short: "Create a PAR2 recovery set for files",
+	Long: `Create a PAR2 recovery set (an index file and recovery volumes) for files,
+or the files below directories, with the same generator used when posting. No
+configuration or server is needed.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runPar2Create,
+}
+
+func init() {
+	rootCmd.AddCommand(par2Cmd)
+	par2Cmd.AddCommand(par2CreateCmd)
+
+	par2CreateCmd.Flags().IntVarP(&par2Redundancy, "redundancy", "r", 10, "recovery data in percent of the data size")
+	par2CreateCmd.Flags().StringVarP(&par2Output, "output", "o", "", "directory of the recovery set (default: the directory of the first file)")
+	par2CreateCmd.Flags().StringVarP(&par2Name, "name", "n", "", "base name of the recovery set (default: the first file's name)")
+	par2CreateCmd.Flags().IntVarP(&par2BlockSize, "block-size", "b", 0, "recovery block size in bytes, a multiple of 4 (0 picks it from the data size)")
+}
+
+func runPar2Create(cmd *cobra.Command, args []string) {
+	if par2Redundancy < 1 || par2Redundancy > 100 {
+		fmt.Printf("Error: redundancy must be between 1 and 100\n")
+		os.Exit(1)
+	}
+	if par2BlockSize < 0 || par2BlockSize%4 != 0 {
+		fmt.Printf("Error: block size must be a multiple of 4, got %d\n", par2BlockSize)
+		os.Exit(1)
+	}
+
+	// The recovery set names files by base name, which must be unique
+	var files []string
+	names := make(map[string]string)
+	for _, arg := range args {
+		collected, err := utils.CollectFiles(arg)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, file := range collected {
+			name := filepath.Base(file)
+			if other, ok := names[name]; ok {
+				fmt.Printf("Error: %s and %s have the same name\n", other, file)
+				os.Exit(1)
+			}
+			names[name] = file
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		fmt.Printf("Error: no files found\n")
+		os.Exit(1)
+	}
+
+	outputDir := par2Output
+	if outputDir == "" {
+		outputDir = filepath.Dir(files[0])
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	baseName := par2Name
+	if baseName == "" {
+		baseName = filepath.Base(files[0])
+	}
+
+	generator := par2.NewGenerator(outputDir)
+	generator.SetSliceSize(par2BlockSize)
+	par2Files, err := generator.CreatePAR2ForParts(files, baseName, par2Redundancy)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Created %d PAR2 recovery files for %d inputs:\n", len(par2Files), len(files))
+	for _, par2File := range par2Files {
+		fmt.Printf("  %s\n", par2File)
+	}
+	if jsonOutput {
+		printJSON(par2Files)
+	}
+}
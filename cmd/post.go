@@ -1,117 +1,142 @@
 package cmd
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"sync"
-	"text/template"
 	"time"
 
 	"github.com/spf13/cobra"
+	"ypost/internal/archive"
 	"ypost/internal/config"
+	"ypost/internal/events"
+	"ypost/internal/journal"
 	"ypost/internal/logger"
+	"ypost/internal/memory"
+	"ypost/internal/nfo"
 	"ypost/internal/nntp"
 	"ypost/internal/nzb"
+	"ypost/internal/obfuscate"
 	"ypost/internal/par2"
 	"ypost/internal/progress"
+	"ypost/internal/remote"
+	"ypost/internal/schedule"
 	"ypost/internal/sfv"
 	"ypost/internal/splitter"
+	"ypost/internal/upload"
 	"ypost/internal/utils"
+	"ypost/internal/verify"
 	"ypost/internal/yenc"
 	"ypost/pkg/models"
 )
 
 var (
-	group          string
-	posterName     string
-	posterEmail    string
-	subject        string
-	maxPartSize    int64
-	maxArticleSize int64
-	maxLineLen     int
-	createPAR2     bool
-	createSFV      bool
-	redundancy     int
-	outputDir      string
-	nzbDir         string
+	obfuscatePost bool
+	postAt        string
+	forcePost     bool
 )
 
 // postCmd represents the post command
 var postCmd = &cobra.Command{
-	Use:   "post [file]",
-	Short: "Post a file to Usenet",
+	Use:   "post [file-or-dir]",
+	Short: "Post a file or directory to Usenet",
 	Long: `Post a file to Usenet with automatic yEnc encoding, file splitting,
-NZB generation, and optional PAR2/SFV creation.`,
-	Args: cobra.ExactArgs(1),
+NZB generation, and optional PAR2/SFV creation. A directory is walked
+recursively and its files are posted with a shared NZB and recovery set.
+With --from-list, every path of a list file (or stdin with -) is posted as a
+job of its own.`,
+	Args: postArgs,
 	Run:  runPost,
 }
 
 func init() {
 	rootCmd.AddCommand(postCmd)
 
-	postCmd.Flags().StringVarP(&group, "group", "g", "", "newsgroup to post to")
-	postCmd.Flags().StringVar(&posterName, "poster-name", "", "name of the poster")
-	postCmd.Flags().StringVar(&posterEmail, "poster-email", "", "email address of the poster")
-	postCmd.Flags().StringVarP(&subject, "subject", "s", "", "subject template")
-	postCmd.Flags().Int64Var(&maxPartSize, "max-part-size", 0, "maximum size per part in bytes")
-	postCmd.Flags().Int64Var(&maxArticleSize, "max-article-size", 0, "maximum size per NNTP article in bytes")
-	postCmd.Flags().IntVar(&maxLineLen, "max-line-length", 128, "maximum line length")
-	postCmd.Flags().BoolVar(&createPAR2, "par2", true, "create PAR2 recovery files")
-	postCmd.Flags().BoolVar(&createSFV, "sfv", true, "create SFV checksum file")
-	postCmd.Flags().IntVar(&redundancy, "redundancy", 10, "PAR2 redundancy percentage")
-	postCmd.Flags().StringVarP(&outputDir, "output", "o", "", "output directory")
-	postCmd.Flags().StringVar(&nzbDir, "nzb-dir", "", "NZB output directory")
+	// The usual settings of a post have short flags of their own
+	flags := postCmd.Flags()
+	config.AddFlag(flags, "posting.group", "group", "g", "newsgroup to post to")
+	config.AddFlag(flags, "posting.poster_name", "poster-name", "", "name of the poster")
+	config.AddFlag(flags, "posting.poster_email", "poster-email", "", "email address of the poster")
+	config.AddFlag(flags, "posting.subject_template", "subject", "s", "subject template")
+	config.AddFlag(flags, "posting.max_part_size", "max-part-size", "", "maximum size per part in bytes")
+	config.AddFlag(flags, "posting.max_article_size", "max-article-size", "", "maximum size per NNTP article in bytes")
+	config.AddFlag(flags, "posting.max_line_length", "max-line-length", "", "maximum line length")
+	config.AddFlag(flags, "par2.enabled", "par2", "", "create PAR2 recovery files")
+	config.AddFlag(flags, "sfv.enabled", "sfv", "", "create SFV checksum file")
+	config.AddFlag(flags, "par2.redundancy", "redundancy", "", "PAR2 redundancy percentage")
+	config.AddFlag(flags, "output.output_dir", "output", "o", "output directory")
+	config.AddFlag(flags, "output.nzb_dir", "nzb-dir", "", "NZB output directory")
+	config.AddFlag(flags, "nzb.title_template", "nzb-title", "", "NZB title template")
+	config.AddFlag(flags, "nzb.category", "nzb-category", "", "NZB category")
+	config.AddFlag(flags, "nzb.tags", "nzb-tags", "", "NZB tags (comma-separated)")
+	config.AddFlag(flags, "splitting.naming", "part-naming", "", "post parts as separate files named by scheme: default, part or rnn")
+	config.AddFlag(flags, "splitting.name_width", "part-name-width", "", "zero-padded width of part numbers (0 = automatic)")
+	config.AddFlag(flags, "posting.preserve_paths", "preserve-paths", "", "keep relative paths of directory inputs in subjects and the NZB")
+	config.AddFlag(flags, "splitting.align_parts", "align-parts", "", "round part sizes to a whole number of articles")
+	config.AddFlag(flags, "splitting.pad_parts", "pad-parts", "", "zero-pad the final part of each file to the full part size")
+	config.AddFlag(flags, "splitting.checksum", "checksum", "", "part checksum algorithm: crc32, xxhash, blake3 or sha256")
+	config.AddFlag(flags, "archive.format", "archive", "", "wrap the input in a store-mode archive first: zip, 7z or rar")
+	config.AddFlag(flags, "archive.volume_size", "archive-volume-size", "", "split the archive into volumes of this size (e.g. 50MB)")
+	config.AddFlag(flags, "nzb.par2", "nzb-par2", "", "PAR2 files listed in the NZB: all, index or none")
+	config.AddFlag(flags, "nzb.include_sfv", "nzb-sfv", "", "list the SFV file in the NZB")
+	config.AddFlag(flags, "posting.verify", "verify", "", "fetch posted articles back to compare them with the source: all, sample:N% or sample:N")
+	config.AddFlag(flags, "posting.on_error", "on-error", "", "when an article fails: skip it, abort the upload, or retry it after the others")
+	flags.BoolVar(&obfuscatePost, "obfuscate", false, "post under random subjects, file names and poster, mapping the names next to the NZB")
+	flags.StringVar(&postAt, "post-at", "", "wait until this time before posting: HH:MM, \"2006-01-02 15:04\" or RFC 3339")
+	flags.BoolVar(&forcePost, "force", false, "post content the history holds a recent post of, only warning")
+
+	// Every other setting gets a flag named after its key
+	config.AddFlags(postCmd.Flags())
 }
 
 func runPost(cmd *cobra.Command, args []string) {
-	filePath := args[0]
-
-	// Load configuration
+	// Load configuration: flags over environment over file over defaults
 	cfg, configFileUsed, err := config.LoadConfig(cfgFile)
 	if err != nil {
 		fmt.Printf("Error loading config: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Override config with command line flags
-	if group != "" {
-		cfg.Posting.Group = group
-	}
-	if posterName != "" {
-		cfg.Posting.PosterName = posterName
-	}
-	if posterEmail != "" {
-		cfg.Posting.PosterEmail = posterEmail
-	}
-	if subject != "" {
-		cfg.Posting.SubjectTemplate = subject
+	// The group's preset sits between the general settings and the flags;
+	// the presets are checked with the configuration
+	groupPreset := config.ApplyGroupPreset(cfg)
+	if forcePost && cfg.History.Duplicates == models.DuplicatesAbort {
+		cfg.History.Duplicates = models.DuplicatesWarn
 	}
-	if maxPartSize > 0 {
-		cfg.Posting.MaxPartSize = maxPartSize
-	}
-	if maxArticleSize > 0 {
-		cfg.Posting.MaxArticleSize = maxArticleSize
-	}
-	if maxLineLen > 0 {
-		cfg.Posting.MaxLineLength = maxLineLen
-	}
-	if outputDir != "" {
-		cfg.Output.OutputDir = outputDir
+	if obfuscatePost {
+		cfg.Obfuscation.Enabled = true
+		cfg.Obfuscation.RandomSubjects = true
+		cfg.Obfuscation.RandomPoster = true
+		cfg.Obfuscation.ScrambleFilenames = true
+		// The real names must be kept somewhere to restore them
+		if cfg.NZB.MappingMode == nzb.MappingNone {
+			cfg.NZB.MappingMode = nzb.MappingSidecar
+		}
 	}
-	if nzbDir != "" {
-		cfg.Output.NZBDir = nzbDir
+
+	var startAt time.Time
+	if postAt != "" {
+		if startAt, err = schedule.At(postAt, time.Now()); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
 	// Initialize logger
-	log, err := logger.New(cfg.Output.LogDir)
-	if err != nil {
-		fmt.Printf("Error initializing logger: %v\n", err)
-		os.Exit(1)
-	}
+	log := newLogger(cfg)
 	defer log.Close()
 
+	ctx, stop := stopContext(cmd.Context(), log)
+	defer stop()
+
+	if groupPreset {
+		log.Info("Applied posting preset for group: %s", cfg.Posting.Group)
+	}
+
 	// Log configuration file path and contents
 	if configFileUsed != "" {
 		// Get absolute path
@@ -120,11 +145,11 @@ func runPost(cmd *cobra.Command, args []string) {
 			absPath = configFileUsed
 		}
 		log.Info("Configuration file loaded: %s", absPath)
-		
+
 		// Read and log config file contents
 		content, err := os.ReadFile(configFileUsed)
 		if err == nil {
-			log.Debug("Config contents: %s", string(content))
+			log.Debug("Config contents: %s", config.RedactText(string(content)))
 		} else {
 			log.Warn("Could not read config file contents: %v", err)
 		}
@@ -132,89 +157,560 @@ func runPost(cmd *cobra.Command, args []string) {
 		log.Info("Using default configuration (no config file found)")
 	}
 
-// Check if file exists
-if _, err := os.Stat(filePath); os.IsNotExist(err) {
-	log.Fatal("File does not exist: %s", filePath)
+	if time.Until(startAt) > 0 {
+		log.Info("Waiting until %s to start posting", startAt.Format("2006-01-02 15:04"))
+		if err := schedule.Sleep(ctx, startAt); err != nil {
+			log.Fatal("Posting cancelled: %v", err)
+		}
+	}
+
+	if fromList != "" {
+		runBatch(ctx, cfg, fromList, log)
+		return
+	}
+
+	start := time.Now()
+	articles := 0
+	counted := &postHooks{segmentPosted: func(*models.PostSegment) { articles++ }}
+	nzbPath, err := postPath(ctx, cfg, args[0], log, counted, nil)
+	if jsonOutput {
+		printJSON(newPostSummary(args[0], nzbPath, articles, time.Since(start), err))
+	}
+	if err != nil {
+		if upload.IncompleteArticles(err) > 0 {
+			log.Warn("Incomplete NZB file: %s", nzbPath)
+		} else if nzbPath != "" {
+			log.Warn("NZB file: %s", nzbPath)
+		}
+		log.Fatal("Posting failed: %v", err)
+	}
+	log.Info("Posting completed successfully!")
+	log.Info("NZB file: %s", nzbPath)
+}
+
+// newLogger creates the logger configured by the logging settings, exiting
+// when the log file cannot be opened
+func newLogger(cfg *models.Config) *logger.Logger {
+	logLevel, _ := logger.ParseLevel(cfg.Logging.Level)
+	logLevels, _ := logger.ParseLevels(cfg.Logging.Levels)
+	if verbose {
+		logLevel = logger.DEBUG
+	}
+	log, err := logger.NewWithOptions(logger.Options{
+		Dir:     cfg.Output.LogDir,
+		File:    cfg.Logging.File,
+		Backend: cfg.Logging.Backend,
+		Level:   logLevel,
+		Levels:  logLevels,
+		Console: cfg.Logging.Console,
+		Quiet:   quiet,
+	})
+	if err != nil {
+		fmt.Printf("Error initializing logger: %v\n", err)
+		os.Exit(1)
+	}
+	log.Redact(config.Secrets(cfg)...)
+	return log
+}
+
+// postingGroups returns the newsgroups of the comma-separated posting group
+func postingGroups(cfg *models.Config) []string {
+	var groups []string
+	for _, group := range strings.Split(cfg.Posting.Group, ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups
 }
 
-// Create unified output directory with timestamp
-baseName := filepath.Base(filePath)
-unifiedOutputDir := utils.GetUnifiedOutputPath(cfg.Output.OutputDir, baseName)
+// posterFrom returns the From header of the articles: the poster name and
+// address, normalized, the address falling back to "from" when unset
+func posterFrom(cfg *models.Config) (string, error) {
+	address := cfg.Posting.PosterEmail
+	if address == "" {
+		address = cfg.Posting.From
+	}
+	return nntp.FormatFrom(cfg.Posting.PosterName, address)
+}
 
-// Ensure the unified directory exists (even if some file types are disabled)
-if err := os.MkdirAll(unifiedOutputDir, 0755); err != nil {
-	log.Fatal("Failed to create unified output directory: %v", err)
+// postHooks are called as a posting makes progress; a nil *postHooks or
+// hook is skipped
+type postHooks struct {
+	// postStarted is called with the ID of the post once it starts
+	postStarted func(postID string)
+	// segmentPosted is called for every article posted
+	segmentPosted func(*models.PostSegment)
+	// chunksPlanned is called before the articles of a file are posted,
+	// numbered from first
+	chunksPlanned func(fileName string, first int, chunks int)
+	// postedBefore returns the article of a chunk posted by an interrupted
+	// run, which is then reused instead of posted again
+	postedBefore func(fileName string, number int) (*models.PostSegment, bool)
+	// segmentFailed is called for every article no server accepted
+	segmentFailed func(segment *models.PostSegment, err error)
+	// roundTripped is called with the outcome of the download of the post
+	// by the round trip downloader
+	roundTripped func(passed bool)
 }
 
-// Initialize components
-fmt.Printf("DEBUG: Initializing splitter with MaxPartSize: %d bytes\n", cfg.Posting.MaxPartSize)
-split := splitter.NewSplitter(cfg.Posting.MaxPartSize)
-yencEnc := yenc.Encoder{}
+// started calls postStarted
+func (h *postHooks) started(postID string) {
+	if h != nil && h.postStarted != nil {
+		h.postStarted(postID)
+	}
+}
 
-// Use the "from" value from config for NZB poster
-poster := cfg.Posting.From
-if poster == "" {
-	// Fallback to poster_email if "from" is not specified
-	poster = cfg.Posting.PosterEmail
+// posted calls segmentPosted
+func (h *postHooks) posted(segment *models.PostSegment) {
+	if h != nil && h.segmentPosted != nil {
+		h.segmentPosted(segment)
+	}
 }
-nzbGen := nzb.NewGenerator(unifiedOutputDir, poster)
 
-var par2Gen *par2.Generator
-var sfvGen *sfv.Generator
+// planned calls chunksPlanned
+func (h *postHooks) planned(fileName string, first int, chunks int) {
+	if h != nil && h.chunksPlanned != nil {
+		h.chunksPlanned(fileName, first, chunks)
+	}
+}
 
-if createPAR2 || cfg.Features.CreatePAR2 {
-	par2Gen = par2.NewGenerator(unifiedOutputDir)
+// failed calls segmentFailed
+func (h *postHooks) failed(segment *models.PostSegment, err error) {
+	if h != nil && h.segmentFailed != nil {
+		h.segmentFailed(segment, err)
+	}
 }
-if createSFV || cfg.Features.CreateSFV {
-	sfvGen = sfv.NewGenerator(unifiedOutputDir)
+
+// checked calls roundTripped
+func (h *postHooks) checked(passed bool) {
+	if h != nil && h.roundTripped != nil {
+		h.roundTripped(passed)
+	}
 }
 
-	// Split file into parts and save them to the output directory
-	log.Info("Splitting file: %s", filePath)
-	parts, err := split.SplitFile(filePath, unifiedOutputDir)
+// reused calls postedBefore
+func (h *postHooks) reused(fileName string, number int) (*models.PostSegment, bool) {
+	if h != nil && h.postedBefore != nil {
+		return h.postedBefore(fileName, number)
+	}
+	return nil, false
+}
+
+// postPath posts a file, or a directory as one job, and returns the path of
+// its NZB. The post is published as events, which its log, history and
+// notifications subscribe to. With resume, the interrupted post of that
+// journal is finished instead.
+func postPath(ctx context.Context, cfg *models.Config, filePath string, log *logger.Logger, hooks *postHooks, resume *journal.State) (string, error) {
+	start := time.Now()
+
+	// Every log line, the state file, the history record and the
+	// notifications of the post carry its ID; a resumed post keeps its own
+	var postID string
+	if resume != nil {
+		postID = resume.ID
+	} else {
+		var err error
+		if postID, err = journal.NewID(); err != nil {
+			return "", err
+		}
+	}
+	log = log.WithPost(postID)
+
+	// Hooks are called from the collecting goroutine only
+	tally := &postTally{}
+	defer subscribePost(ctx, cfg, filePath, postID, log, tally)()
+	published := &postHooks{
+		segmentPosted: func(segment *models.PostSegment) {
+			events.Publish(events.Event{Kind: events.SegmentPosted, PostID: postID, Segment: segment})
+			hooks.posted(segment)
+		},
+		segmentFailed: func(segment *models.PostSegment, err error) {
+			events.Publish(events.Event{Kind: events.SegmentFailed, PostID: postID, Segment: segment, Err: err})
+			hooks.failed(segment, err)
+		},
+		roundTripped: func(passed bool) {
+			tally.roundTripped(passed)
+			hooks.checked(passed)
+		},
+	}
+
+	// A resumed post was looked up in the history when it started
+	name, size := postedFile(filePath)
+	var hash string
+	if resume == nil {
+		var err error
+		if hash, err = checkPostedBefore(cfg, filePath, name, size, log); err != nil {
+			return "", err
+		}
+		// A resumed post already passed its hook
+		if err := runPreHook(ctx, cfg, filePath, name, size, log); err != nil {
+			return "", err
+		}
+	}
+	hooks.started(postID)
+	events.Publish(events.Event{Kind: events.PostStarted, PostID: postID, File: name, Size: size})
+	nzbPath, err := postJob(ctx, cfg, filePath, postID, log, published, resume)
+	record := postRecord(cfg, name, size, nzbPath, tally, time.Since(start), err)
+	record.PostID = postID
+	record.ContentHash = hash
+	outcome := events.Event{Kind: events.PostCompleted, PostID: postID, File: name, Size: size, Record: record}
 	if err != nil {
-		log.Fatal("Failed to split file: %v", err)
+		outcome.Kind, outcome.Err = events.Error, err
 	}
+	events.Publish(outcome)
+	return nzbPath, err
+}
 
-	log.LogFileSplit(filePath, len(parts), sumPartSizes(parts))
+// postJob does the posting of postPath
+func postJob(ctx context.Context, cfg *models.Config, filePath string, postID string, log *logger.Logger, hooks *postHooks, resume *journal.State) (string, error) {
+	// Check if file exists; a URL is read in place from its server
+	remoteSource := remote.IsURL(filePath)
+	if _, err := os.Stat(filePath); os.IsNotExist(err) && !remoteSource {
+		return "", fmt.Errorf("file does not exist: %s", filePath)
+	}
+	// A poster the flags made invalid fails the post before anything is done,
+	// as does a group the servers do not take posts to or a missing NFO
+	if _, err := posterFrom(cfg); err != nil {
+		return "", err
+	}
+	if err := checkPostingGroups(cfg, log); err != nil {
+		return "", err
+	}
+	nfoGen, err := nfo.New(cfg.NFO)
+	if err != nil {
+		return "", err
+	}
+	if err := nfoGen.Check(); err != nil {
+		return "", err
+	}
 
-	// Create PAR2 files if enabled - use split parts for standard practice
-	var par2Files []string
-	if par2Gen != nil {
-		log.Info("Creating PAR2 recovery files...")
-		
-		// Collect part file paths for PAR2 generation
-		var partPaths []string
-		for _, part := range parts {
-			partPaths = append(partPaths, part.FilePath)
+	// Create unified output directory with timestamp
+	baseName := filepath.Base(filePath)
+	if remoteSource {
+		obj, err := remote.Open(ctx, filePath)
+		if err != nil {
+			return "", err
 		}
-		
-		// Create PAR2 files for the split parts (standard practice)
-		par2Files, err = par2Gen.CreatePAR2ForParts(partPaths, filepath.Base(filePath), redundancy)
+		obj.Close()
+		baseName = obj.Name()
+	}
+	unifiedOutputDir := utils.GetUnifiedOutputPath(cfg.Output.OutputDir, baseName)
+	if resume != nil {
+		unifiedOutputDir = resume.OutputDir
+	}
+
+	// Ensure the unified directory exists (even if some file types are disabled)
+	if err := os.MkdirAll(unifiedOutputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create unified output directory: %w", err)
+	}
+
+	// The journal records every posted article, so an interrupted post can
+	// be finished with ypost resume
+	var postJournal *journal.Journal
+	if resume != nil {
+		postJournal, err = journal.Reopen(journal.Path(unifiedOutputDir))
+	} else {
+		postJournal, err = journal.Create(unifiedOutputDir, postID, filePath, cfg)
+	}
+	if err != nil {
+		return "", err
+	}
+	completed := false
+	defer func() {
+		if completed {
+			if err := postJournal.Remove(); err != nil {
+				log.Warn("%v", err)
+			}
+			return
+		}
+		postJournal.Close()
+		log.Info("Resume the post with: ypost resume %s", unifiedOutputDir)
+	}()
+	hooks = journalHooks(postJournal, resume, hooks, log)
+
+	// The articles retried or refused are reported once the post ends
+	failures := &failureReport{}
+	defer events.Subscribe(events.ForPost(postID, failures.add), events.SegmentPosted, events.SegmentFailed)()
+	defer failures.finish(unifiedOutputDir, log)
+
+	// Random names derive from the post ID, so a resumed post keeps them
+	var obfuscator *obfuscate.Obfuscator
+	nameMapping := make(map[string]string)
+	if cfg.Obfuscation.Enabled {
+		obfuscator = obfuscate.New(postID, cfg.Obfuscation.NameLength)
+		if cfg.Obfuscation.RandomPoster {
+			obfuscated := *cfg
+			obfuscated.Posting.PosterName, obfuscated.Posting.PosterEmail = obfuscator.Poster()
+			obfuscated.Posting.From = obfuscated.Posting.PosterEmail
+			cfg = &obfuscated
+		}
+	}
+
+	// Initialize components
+	log.Debug("Initializing splitter with MaxPartSize: %d bytes", cfg.Posting.MaxPartSize)
+	split, err := splitter.NewFromConfig(cfg)
+	if err != nil {
+		return "", fmt.Errorf("invalid splitting configuration: %w", err)
+	}
+	if cfg.Splitting.AlignParts {
+		log.Info("Part size aligned to %d bytes (%d-byte articles)", split.PartSize(), cfg.Posting.MaxArticleSize)
+	}
+	// PAR2 and SFV files keep their own names whatever the part naming scheme
+	generatedSplit := splitter.NewSplitter(cfg.Posting.MaxPartSize)
+	yencEnc := yenc.Encoder{LineLength: cfg.Posting.MaxLineLength}
+
+	// Use the "from" value from config for NZB poster
+	poster := cfg.Posting.From
+	if poster == "" {
+		// Fallback to poster_email if "from" is not specified
+		poster = cfg.Posting.PosterEmail
+	}
+	nzbGen := nzb.NewGenerator(unifiedOutputDir, poster)
+	nzbGen.SetMeta(cfg.NZB.TitleTemplate, cfg.NZB.Category, cfg.NZB.Tags)
+	nzbGen.SetInclusion(cfg.NZB.PAR2, cfg.NZB.IncludeSFV, cfg.NZB.IncludeNFO)
+
+	var par2Gen *par2.Generator
+	var sfvGen *sfv.Generator
+
+	if cfg.Par2.Enabled {
+		par2Gen = par2.NewGenerator(unifiedOutputDir)
+		par2Gen.SetSliceSize(cfg.Par2.BlockSize)
+		par2Gen.SetLogger(log.Module("par2"))
+		par2Gen.SetMemoryBudget(memoryBudget(cfg))
+	}
+	if cfg.SFV.Enabled {
+		sfvGen = sfv.NewGenerator(unifiedOutputDir)
+	}
+
+	// A directory is posted as one job: every file below it shares the NZB
+	// and the recovery set. With an archive format, the files are wrapped in
+	// store-mode archive volumes first and the volumes posted instead.
+	// A remote source is a single object
+	inputFiles := []string{filePath}
+	if !remoteSource {
+		inputFiles, err = utils.CollectFiles(filePath)
 		if err != nil {
-			log.Error("Failed to create PAR2 files: %v", err)
+			return "", fmt.Errorf("failed to collect input files: %w", err)
+		}
+	}
+	if len(inputFiles) == 0 {
+		return "", fmt.Errorf("no files found in: %s", filePath)
+	}
+	if len(inputFiles) > 1 {
+		var jobSize int64
+		for _, inputFile := range inputFiles {
+			if info, err := os.Stat(inputFile); err == nil {
+				jobSize += info.Size()
+			}
+		}
+		log.Info("Posting %d files (%d bytes) as one job: %s", len(inputFiles), jobSize, baseName)
+	}
+	inputRoot := filePath
+	var archiveFiles []string
+	if resume != nil && len(resume.Volumes) > 0 && filesExist(resume.Volumes) {
+		// Archives are not reproducible; post the volumes of the interrupted run
+		log.Info("Reusing %d archive volume(s) of the interrupted post", len(resume.Volumes))
+		archiveFiles = resume.Volumes
+		inputFiles = archiveFiles
+		inputRoot = unifiedOutputDir
+	} else if cfg.Archive.Format != "" {
+		if remoteSource {
+			return "", fmt.Errorf("archiving needs a local input, not %s", filePath)
+		}
+		volumeSize, err := parseArchiveVolumeSize(cfg.Archive.VolumeSize)
+		if err != nil {
+			return "", fmt.Errorf("invalid archive volume size: %w", err)
+		}
+		archiver, err := archive.NewArchiver(cfg.Archive.Format, cfg.Archive.Password, volumeSize, unifiedOutputDir)
+		if err != nil {
+			return "", fmt.Errorf("failed to initialize archiver: %w", err)
+		}
+
+		log.Info("Creating %s archive of: %s", cfg.Archive.Format, filePath)
+		archiveFiles, err = archiver.Create(filePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to create archive: %w", err)
+		}
+		log.Info("Created %d archive volume(s)", len(archiveFiles))
+		if err := postJournal.Archive(archiveFiles); err != nil {
+			return "", err
+		}
+		inputFiles = archiveFiles
+		inputRoot = unifiedOutputDir
+	}
+
+	// Files posted under the same name would clash in the NZB, PAR2 and SFV
+	postedNames, err := utils.PostedNames(inputRoot, inputFiles, cfg.Posting.PreservePaths)
+	if err != nil {
+		return "", fmt.Errorf("cannot post as one job: %w (use --preserve-paths or --archive)", err)
+	}
+	if remoteSource {
+		postedNames = []string{baseName}
+	}
+
+	// The overall progress of the post spans the phases it runs; the PAR2
+	// and SFV files are created during the upload, which grows with them
+	planned := []progress.Phase{progress.PhaseSplit, progress.PhaseUpload}
+	if sample, _ := verify.ParseSample(cfg.Posting.Verify); sample.Enabled() {
+		planned = append(planned, progress.PhaseVerify)
+	}
+	if cfg.RoundTrip.Client != "" {
+		planned = append(planned, progress.PhaseRoundTrip)
+	}
+	phases := progress.NewPhases(planned...)
+	phases.SetLogger(log.Module("progress"))
+	statusMonitor.Track(postID, phases.Progress)
+
+	// Describe the file parts as views over the sources; nothing is copied to disk
+	phases.Start(progress.PhaseSplit)
+	var inputParts [][]*models.FilePart
+	for i, inputFile := range inputFiles {
+		parts, err := split.Split(ctx, inputFile, splitter.Options{Storage: splitter.StorageView})
+		if err != nil {
+			return "", fmt.Errorf("failed to split file: %w", err)
+		}
+		if len(parts) == 1 {
+			log.Info("Posting file directly (no split needed): %s", inputFile)
 		} else {
-			log.LogPAR2Creation(filePath, par2Files)
+			log.Info("Splitting file: %s", inputFile)
 		}
+
+		// Keep the directory structure of the input in subjects and the NZB
+		if cfg.Posting.PreservePaths && !remoteSource {
+			if relDir := path.Dir(utils.RelativeName(inputRoot, inputFile)); relDir != "." {
+				for _, part := range parts {
+					part.FileName = path.Join(relDir, part.FileName)
+				}
+			}
+		}
+
+		obfuscateParts(obfuscator, cfg, parts, cfg.Obfuscation.ScrambleFilenames, nameMapping)
+		log.LogFileSplit(inputFile, len(parts), sumPartSizes(parts))
+		inputParts = append(inputParts, parts)
+		phases.Update(progress.PhaseSplit, float64(i+1)/float64(len(inputFiles)))
+	}
+
+	// Recovery and checksum files are named after the job, or a random name
+	// keeping their extensions when file names are scrambled
+	generatedName := baseName
+	if obfuscator != nil && cfg.Obfuscation.ScrambleFilenames {
+		generatedName = obfuscator.Name(baseName)
+	}
+	// The NFO, PAR2 and SFV list the files under the names they are posted
+	// as, never their real names when scrambled nor their local paths
+	entryNames := postedNames
+	if obfuscator != nil && cfg.Obfuscation.ScrambleFilenames {
+		entryNames = make([]string, len(postedNames))
+		for i, name := range postedNames {
+			entryNames[i] = obfuscator.Name(name)
+		}
+	}
+
+	// The NFO is written before the upload, from the checksums of the
+	// planning, and posted after the other files under the name of the job
+	var nfoPath string
+	nfoName := generatedName + ".nfo"
+	if nfoGen.Enabled() {
+		if input := nfoInput(inputFiles); input != "" {
+			log.Info("Posting the NFO of the input: %s", input)
+		} else {
+			release := nfoRelease(cfg, generatedName, postID, poster, inputFiles, entryNames, inputParts, split.FileCRCs())
+			nfoPath, err = nfoGen.Create(unifiedOutputDir, nfoName, release)
+			if err != nil {
+				return "", err
+			}
+			log.Info("NFO file ready: %s", nfoPath)
+		}
+	}
+
+	// PAR2 files are computed over the input files the parts view while
+	// those are posted, then posted after them
+	par2Ready := make(chan []string, 1)
+	if par2Gen != nil && remoteSource {
+		log.Warn("PAR2 recovery files need a local input, none are created for %s", filePath)
+		par2Ready <- nil
+	} else if par2Gen != nil {
+		log.Info("Creating PAR2 recovery files while posting...")
+		// Only the bar of the upload is drawn meanwhile
+		par2Gen.SetSinkFactory(progress.Discard)
+		for i, inputFile := range inputFiles {
+			par2Gen.SetEntryName(inputFile, entryNames[i])
+		}
+		go func() {
+			par2Files, err := par2Gen.CreatePAR2ForParts(inputFiles, generatedName, cfg.Par2.Redundancy)
+			if err != nil {
+				log.Error("Failed to create PAR2 files: %v", err)
+			} else {
+				log.LogPAR2Creation(filePath, par2Files)
+			}
+			par2Ready <- par2Files
+		}()
+	} else {
+		par2Ready <- nil
+	}
+
+	// One tracker follows the whole job, fed by the articles posted; the
+	// recovery and checksum files are added once created
+	tracker := jobTracker(baseName, int(cfg.Posting.MaxArticleSize), inputParts)
+	tracker.SetPostID(postID)
+	tracker.SetPhases(phases)
+	tracker.SetLogger(log.Module("progress"))
+	if articles, bytes := seedTracker(tracker, int(cfg.Posting.MaxArticleSize), inputParts, hooks); articles > 0 {
+		log.Info("Resuming with %d articles (%s) posted before", articles, utils.FormatFileSize(bytes))
+	}
+	defer events.Subscribe(events.ForPost(postID, func(event events.Event) {
+		tracker.EmitFileProgress(event.Segment.FileName, event.Segment.BytesPosted)
+	}), events.SegmentPosted)()
+
+	// Primary servers take normal traffic; a segment only moves down to
+	// lower-priority and backup servers when it fails on the ones above
+	servers := nntp.NewServerGroup(cfg.NNTP.Servers)
+	if cfg.Obfuscation.Enabled {
+		servers.SetMessageIDDomain(cfg.Obfuscation.MessageIDDomain)
+	}
+	log.Info("Connecting to server: %s", servers.Primary().Server().Host)
+
+	// Upload every input file
+	// Articles no server accepted are left out of the NZB, the post going on
+	// without them and ending incomplete
+	postedFiles, err := uploadFiles(ctx, servers, inputParts, split.FileCRCs(), *cfg, &yencEnc, log, hooks, tracker)
+	incomplete := &upload.IncompleteError{}
+	if !incomplete.Add(err) || len(postedFiles) == 0 {
+		servers.CloseAll()
+		// The PAR2 files are not left half written
+		<-par2Ready
+		return "", uploadStopped(failures.posted, err)
 	}
+	par2Files := <-par2Ready
 
-	// Create SFV file for split parts if enabled
+	// Create SFV file if enabled
 	var sfvPath string
 	if sfvGen != nil {
 		log.Info("Creating SFV checksum file...")
-		
+
 		// Collect paths of all files to include in SFV
 		var allFilePaths []string
-		
-		// Add the split part files (standard practice)
-		for _, part := range parts {
-			allFilePaths = append(allFilePaths, part.FilePath)
-		}
-		
+		allFilePaths = append(allFilePaths, inputFiles...)
+
 		// Add PAR2 files
 		allFilePaths = append(allFilePaths, par2Files...)
-		
-		sfvPath, err = sfvGen.CreateSFV(allFilePaths, fmt.Sprintf("%s.sfv", filepath.Base(filePath)))
+
+		// Reuse the CRC32 computed while planning the parts
+		for path, crc := range split.FileCRCs() {
+			sfvGen.AddChecksum(path, crc)
+		}
+		if par2Gen != nil {
+			// and those of the PAR2 files, computed as they were written
+			for path, crc := range par2Gen.FileCRCs() {
+				sfvGen.AddChecksum(path, crc)
+			}
+		}
+		for i, inputFile := range inputFiles {
+			sfvGen.SetEntryName(inputFile, entryNames[i])
+		}
+
+		sfvPath, err = sfvGen.CreateSFV(allFilePaths, fmt.Sprintf("%s.sfv", generatedName))
 		if err != nil {
 			log.Error("Failed to create SFV file: %v", err)
 		} else {
@@ -222,47 +718,54 @@ if createSFV || cfg.Features.CreateSFV {
 		}
 	}
 
-	// Initialize NNTP connection pool
-	var allSegments []*models.PostSegment
-	var pool *nntp.ConnectionPool
-	
-	for _, server := range cfg.NNTP.Servers {
-		log.Info("Connecting to server: %s", server.Host)
-		pool = nntp.NewConnectionPool(&server, server.MaxConns)
-		
-		// Upload parts
-		segments, err := uploadParts(pool, parts, *cfg, &yencEnc, log)
+	// Generated files are posted in place, never copied into parts
+	var par2PartLists [][]*models.FilePart
+	for _, par2File := range par2Files {
+		par2Parts, err := generatedSplit.Split(ctx, par2File, splitter.Options{Storage: splitter.StorageView})
 		if err != nil {
-			log.Error("Failed to upload parts: %v", err)
-			pool.CloseAll()
+			log.Error("Failed to split PAR2 file: %v", err)
 			continue
 		}
-		
-		allSegments = append(allSegments, segments...)
-		break // Use first successful server
+		obfuscateParts(obfuscator, cfg, par2Parts, false, nameMapping)
+		par2PartLists = append(par2PartLists, par2Parts)
 	}
-
-	if len(allSegments) == 0 {
-		if pool != nil {
-			pool.CloseAll()
+	var sfvParts []*models.FilePart
+	if sfvPath != "" {
+		sfvParts, err = generatedSplit.Split(ctx, sfvPath, splitter.Options{Storage: splitter.StorageView})
+		if err != nil {
+			log.Error("Failed to split SFV file: %v", err)
+			sfvParts = nil
+		} else {
+			obfuscateParts(obfuscator, cfg, sfvParts, false, nameMapping)
 		}
-		log.Fatal("Failed to upload any parts")
 	}
+	var nfoParts []*models.FilePart
+	if nfoPath != "" {
+		nfoParts, err = generatedSplit.Split(ctx, nfoPath, splitter.Options{Storage: splitter.StorageView})
+		if err != nil {
+			log.Error("Failed to split NFO file: %v", err)
+			nfoParts = nil
+		} else {
+			for _, part := range nfoParts {
+				part.FileName = nfoName
+			}
+			obfuscateParts(obfuscator, cfg, nfoParts, false, nameMapping)
+		}
+	}
+	generatedLists := append(par2PartLists, sfvParts, nfoParts)
+	tracker.AddFiles(jobFiles(int(cfg.Posting.MaxArticleSize), generatedLists))
+	seedTracker(tracker, int(cfg.Posting.MaxArticleSize), generatedLists, hooks)
 
 	// Post PAR2 files if created
 	var par2Segments []*models.PostSegment
-	if len(par2Files) > 0 {
+	if len(par2PartLists) > 0 {
 		log.Info("Posting PAR2 recovery files...")
-		for _, par2File := range par2Files {
-			par2Parts, err := split.SplitFile(par2File, unifiedOutputDir)
-			if err != nil {
-				log.Error("Failed to split PAR2 file: %v", err)
-				continue
-			}
-
-			par2FileSegments, err := uploadParts(pool, par2Parts, *cfg, &yencEnc, log)
+		for _, par2Parts := range par2PartLists {
+			par2FileSegments, err := uploadParts(ctx, servers, par2Parts, generatedSplit.FileCRCs(), *cfg, &yencEnc, log, hooks, tracker)
 			if err != nil {
 				log.Error("Failed to upload PAR2 parts: %v", err)
+			}
+			if !incomplete.Add(err) {
 				continue
 			}
 
@@ -272,24 +775,34 @@ if createSFV || cfg.Features.CreateSFV {
 
 	// Post SFV file if created
 	var sfvSegments []*models.PostSegment
-	if sfvPath != "" {
+	if len(sfvParts) > 0 {
 		log.Info("Posting SFV checksum file...")
-		sfvParts, err := split.SplitFile(sfvPath, unifiedOutputDir)
+		sfvFileSegments, err := uploadParts(ctx, servers, sfvParts, generatedSplit.FileCRCs(), *cfg, &yencEnc, log, hooks, tracker)
 		if err != nil {
-			log.Error("Failed to split SFV file: %v", err)
-		} else {
-			sfvFileSegments, err := uploadParts(pool, sfvParts, *cfg, &yencEnc, log)
-			if err != nil {
-				log.Error("Failed to upload SFV parts: %v", err)
-			} else {
-				sfvSegments = sfvFileSegments
-			}
+			log.Error("Failed to upload SFV parts: %v", err)
+		}
+		if incomplete.Add(err) {
+			sfvSegments = sfvFileSegments
+		}
+	}
+
+	// Post the NFO file if any
+	var nfoSegments []*models.PostSegment
+	if len(nfoParts) > 0 {
+		log.Info("Posting NFO file...")
+		nfoFileSegments, err := uploadParts(ctx, servers, nfoParts, generatedSplit.FileCRCs(), *cfg, &yencEnc, log, hooks, tracker)
+		if err != nil {
+			log.Error("Failed to upload NFO parts: %v", err)
+		}
+		if incomplete.Add(err) {
+			nfoSegments = nfoFileSegments
 		}
 	}
+	tracker.EmitComplete()
 
-	// Close the connection pool when done
-	if pool != nil {
-		pool.CloseAll()
+	// Close the server connections when done
+	if servers != nil {
+		servers.CloseAll()
 	}
 
 	// Collect all additional files for NZB
@@ -300,17 +813,31 @@ if createSFV || cfg.Features.CreateSFV {
 	if len(sfvSegments) > 0 {
 		additionalFiles["SFV"] = sfvSegments
 	}
+	if len(nfoSegments) > 0 {
+		additionalFiles["NFO"] = nfoSegments
+	}
 
 	// Generate NZB file with all segments including PAR2 and SFV
 	log.Info("Generating NZB file...")
-	nzbPath, err := nzbGen.Generate(filepath.Base(filePath), allSegments, cfg.Posting.Group, additionalFiles)
+	if len(nameMapping) > 0 {
+		nzbGen.SetNameMapping(nameMapping, cfg.NZB.MappingMode)
+	}
+	if generatedName != baseName {
+		nzbGen.SetTitleName(generatedName)
+	}
+	nzbPath, err := nzbGen.GenerateMulti(baseName, postedFiles, cfg.Posting.Group, additionalFiles)
 	if err != nil {
-		log.Fatal("Failed to generate NZB file: %v", err)
+		return "", fmt.Errorf("failed to generate NZB file: %w", err)
 	}
+	failures.nzbWritten = true
 	log.LogNZBCreation(filePath, nzbPath)
 
-	// Move PAR2 and SFV files to the same directory as NZB
-	if err := moveGeneratedFiles(par2Files, sfvPath, filepath.Dir(nzbPath)); err != nil {
+	// Move PAR2, SFV and generated NFO files to the same directory as NZB
+	generatedNFO := ""
+	if !nfoGen.Provided() {
+		generatedNFO = nfoPath
+	}
+	if err := moveGeneratedFiles(par2Files, sfvPath, generatedNFO, filepath.Dir(nzbPath)); err != nil {
 		log.Error("Failed to move generated files: %v", err)
 	} else {
 		log.Info("Successfully moved PAR2 and SFV files to NZB directory")
@@ -318,12 +845,70 @@ if createSFV || cfg.Features.CreateSFV {
 
 	// Clean up temporary part files
 	log.Info("Cleaning up temporary files...")
-	if err := cleanupAllPartFiles(split, parts, par2Segments, sfvSegments); err != nil {
-		log.Error("Failed to clean up some temporary files: %v", err)
+	for _, parts := range inputParts {
+		if err := cleanupAllPartFiles(split, parts, par2Segments, sfvSegments); err != nil {
+			log.Error("Failed to clean up some temporary files: %v", err)
+		}
+	}
+	for _, archiveFile := range archiveFiles {
+		if err := os.Remove(archiveFile); err != nil {
+			log.Error("Failed to remove archive volume %s: %v", archiveFile, err)
+		}
 	}
 
-	log.Info("Posting completed successfully!")
-	log.Info("NZB file: %s", nzbPath)
+	// Articles fetched back and compared with the source catch servers
+	// that accept articles and damage them
+	verifyErr := verifyPost(ctx, cfg, postedFiles, [][]*models.PostSegment{par2Segments, sfvSegments, nfoSegments}, phases, log)
+
+	// An incomplete post keeps its journal, for ypost resume to post the
+	// articles missing
+	if err := incomplete.Result(); err != nil {
+		return nzbPath, fmt.Errorf("the post is incomplete: %w", err)
+	}
+	completed = true
+	if verifyErr != nil {
+		return nzbPath, verifyErr
+	}
+
+	// Downloading the post back with a downloader shows it can be
+	// downloaded and repaired from what the servers kept
+	return nzbPath, roundTripPost(ctx, cfg, nzbPath, phases, hooks, log)
+}
+
+// journalHooks wraps hooks to record the posted articles in the journal and
+// reuse those recorded by the interrupted run of resume
+func journalHooks(postJournal *journal.Journal, resume *journal.State, hooks *postHooks, log *logger.Logger) *postHooks {
+	wrapped := &postHooks{
+		segmentPosted: func(segment *models.PostSegment) {
+			if err := postJournal.Record(segment); err != nil {
+				log.Warn("%v", err)
+			}
+			hooks.posted(segment)
+		},
+		chunksPlanned: func(fileName string, first int, chunks int) {
+			if err := postJournal.Plan(fileName, first, chunks); err != nil {
+				log.Warn("%v", err)
+			}
+			hooks.planned(fileName, first, chunks)
+		},
+		postedBefore:  hooks.reused,
+		segmentFailed: hooks.failed,
+		roundTripped:  hooks.checked,
+	}
+	if resume != nil {
+		wrapped.postedBefore = resume.Posted
+	}
+	return wrapped
+}
+
+// filesExist reports whether every path exists
+func filesExist(paths []string) bool {
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return false
+		}
+	}
+	return true
 }
 
 // cleanupAllPartFiles removes all temporary part files
@@ -344,247 +929,236 @@ func cleanupAllPartFiles(split *splitter.Splitter, mainParts []*models.FilePart,
 	return nil
 }
 
-// uploadJob represents a single chunk upload task
-type uploadJob struct {
-	chunkData   []byte
-	part        *models.FilePart
-	chunkIndex  int
-	chunkNumber int
-	totalParts  int
-	totalChunks int
-	totalBytes  int64
+// jobTracker returns the progress tracker of a job posting the parts of
+// every file list, each part a file of the job
+func jobTracker(name string, maxArticleSize int, partLists [][]*models.FilePart) *progress.Tracker {
+	return progress.NewJobTracker(name, jobFiles(maxArticleSize, partLists))
 }
 
-func uploadParts(pool *nntp.ConnectionPool, parts []*models.FilePart, postingConfig models.Config, yencEnc *yenc.Encoder, log *logger.Logger) ([]*models.PostSegment, error) {
-	// Calculate total bytes for progress tracking
-	var totalBytes int64
-	for _, part := range parts {
-		totalBytes += part.Size
-	}
-	
-	// NNTP article size limit from configuration
-	maxArticleSize := int(postingConfig.Posting.MaxArticleSize)
-	
-	// Calculate total chunks across all parts for proper numbering
-	var totalChunks int
-	var allJobs []uploadJob
-	
-	chunkNumber := 1
-	
-	// Prepare all upload jobs
-	for _, part := range parts {
-		data, err := os.ReadFile(part.FilePath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read part file %s: %w", part.FilePath, err)
-		}
-		
-		chunks := splitDataIntoChunks(data, maxArticleSize)
-		totalChunks += len(chunks)
-		
-		for chunkIndex, chunkData := range chunks {
-			job := uploadJob{
-				chunkData:   chunkData,
-				part:        part,
-				chunkIndex:  chunkIndex,
-				chunkNumber: chunkNumber,
-				totalParts:  len(parts),
-				totalChunks: totalChunks, // Will be updated after we know the final count
-				totalBytes:  totalBytes,
-			}
-			allJobs = append(allJobs, job)
-			chunkNumber++
-		}
-	}
-	
-	// Update totalChunks in all jobs now that we know the final count
-	for i := range allJobs {
-		allJobs[i].totalChunks = totalChunks
-	}
-	
-	// Create progress tracker
-	tracker := progress.NewTracker(parts[0].FileName, totalChunks, totalBytes)
-	
-	// Create channels for work distribution and result collection
-	jobs := make(chan uploadJob, len(allJobs))
-	results := make(chan *models.PostSegment, len(allJobs))
-	errors := make(chan error, len(allJobs))
-	
-	// Determine number of workers (use connection count from config)
-	numWorkers := 4 // Default to 4 connections
-	if len(postingConfig.NNTP.Servers) > 0 {
-		numWorkers = postingConfig.NNTP.Servers[0].MaxConns
-	}
-	
-	log.Info("Starting parallel upload with %d workers for %d chunks", numWorkers, totalChunks)
-	
-	// Start worker goroutines
-	var wg sync.WaitGroup
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func(workerID int) {
-			defer wg.Done()
-			
-			for job := range jobs {
-				segment, err := uploadChunk(pool, job, postingConfig, yencEnc, log, tracker)
-				if err != nil {
-					log.Error("Worker %d failed to upload chunk %d: %v", workerID, job.chunkNumber, err)
-					errors <- fmt.Errorf("worker %d: %w", workerID, err)
-					return
+// jobFiles returns the files of a job posting the parts of every file list
+func jobFiles(maxArticleSize int, partLists [][]*models.FilePart) []progress.FileProgress {
+	var files []progress.FileProgress
+	for _, parts := range partLists {
+		for _, part := range parts {
+			files = append(files, progress.FileProgress{
+				Name:   part.FileName,
+				Chunks: upload.Articles(part, maxArticleSize),
+				Bytes:  part.Size + part.Padding,
+			})
+		}
+	}
+	return files
+}
+
+// seedTracker counts in the tracker the articles of the parts posted before,
+// numbered as uploadParts does, and returns their number and bytes
+func seedTracker(tracker *progress.Tracker, maxArticleSize int, partLists [][]*models.FilePart, hooks *postHooks) (int, int64) {
+	articles, bytes := 0, int64(0)
+	for _, parts := range partLists {
+		numbered := make(map[string]int)
+		for _, part := range parts {
+			number := numbered[part.FileName] + 1
+			numbered[part.FileName] += upload.Articles(part, maxArticleSize)
+			for last := number + upload.Articles(part, maxArticleSize); number < last; number++ {
+				if segment, ok := hooks.reused(part.FileName, number); ok {
+					tracker.Seed(segment.FileName, segment.BytesPosted)
+					articles++
+					bytes += segment.BytesPosted
 				}
-				results <- segment
 			}
-		}(i)
+		}
 	}
-	
-	// Send all jobs to workers
-	go func() {
-		defer close(jobs)
-		for _, job := range allJobs {
-			jobs <- job
+	return articles, bytes
+}
+
+// uploadFiles uploads the parts of each input file and returns one NZB entry per file
+func uploadFiles(ctx context.Context, servers *nntp.ServerGroup, inputParts [][]*models.FilePart, sourceCRCs map[string]uint32, postingConfig models.Config, yencEnc *yenc.Encoder, log *logger.Logger, hooks *postHooks, tracker *progress.Tracker) ([]nzb.FileEntry, error) {
+	var files []nzb.FileEntry
+	incomplete := &upload.IncompleteError{}
+	for _, parts := range inputParts {
+		if len(parts) == 0 {
+			continue
 		}
-	}()
-	
-	// Collect results
-	var segments []*models.PostSegment
-	var uploadErrors []error
-	
-	for i := 0; i < len(allJobs); i++ {
-		select {
-		case segment := <-results:
-			segments = append(segments, segment)
-		case err := <-errors:
-			uploadErrors = append(uploadErrors, err)
-		}
-	}
-	
-	// Wait for all workers to complete
-	wg.Wait()
-	
-	// Check for errors
-	if len(uploadErrors) > 0 {
-		return nil, fmt.Errorf("upload failed with %d errors: %v", len(uploadErrors), uploadErrors[0])
-	}
-	
-	// Emit completion message
-	tracker.EmitComplete()
-	
-	log.Info("Successfully uploaded %d chunks using %d parallel connections", len(segments), numWorkers)
-	
-	return segments, nil
+
+		// The files after one whose articles are not all posted still are
+		segments, err := uploadParts(ctx, servers, parts, sourceCRCs, postingConfig, yencEnc, log, hooks, tracker)
+		if !incomplete.Add(err) {
+			return nil, err
+		}
+		if len(segments) == 0 {
+			continue
+		}
+
+		files = append(files, nzb.FileEntry{Name: parts[0].FileName, Segments: segments, Lengths: paddedLengths(parts)})
+	}
+
+	if len(files) == 0 && incomplete.Failed > 0 {
+		return nil, incomplete
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no data to upload")
+	}
+	return files, incomplete.Result()
 }
 
-// uploadChunk handles uploading a single chunk
-func uploadChunk(pool *nntp.ConnectionPool, job uploadJob, postingConfig models.Config, yencEnc *yenc.Encoder, log *logger.Logger, tracker *progress.Tracker) (*models.PostSegment, error) {
-	client, err := pool.GetClient()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get client: %w", err)
-	}
-
-	// Join group
-	if err := client.JoinGroup(postingConfig.Posting.Group); err != nil {
-		return nil, fmt.Errorf("failed to join group: %w", err)
-	}
-
-	// Encode chunk with proper part information
-	encoded := yencEnc.Encode(job.chunkData, job.part.FileName, job.part.PartNumber, job.totalParts)
-	
-	// Create subject using proper Go template processing
-	subject := postingConfig.Posting.SubjectTemplate
-	if subject == "" {
-		subject = "[{{.Index}}/{{.Total}}] - {{.Filename}} - ({{.Size}}) yEnc ({{.ChunkIndex}}/{{.TotalChunks}})"
-	}
-	
-	// Calculate file size in human-readable format
-	fileSize := float64(job.totalBytes)
-	sizeStr := ""
-	if fileSize >= 1024*1024*1024 {
-		sizeStr = fmt.Sprintf("%.1fGB", fileSize/(1024*1024*1024))
-	} else if fileSize >= 1024*1024 {
-		sizeStr = fmt.Sprintf("%.1fMB", fileSize/(1024*1024))
-	} else if fileSize >= 1024 {
-		sizeStr = fmt.Sprintf("%.1fKB", fileSize/1024)
-	} else {
-		sizeStr = fmt.Sprintf("%dB", int(fileSize))
-	}
-	
-	// Create template data with both part and chunk information
-	templateData := struct {
-		Index       int    // Part number (for file parts like RAR)
-		Total       int    // Total parts
-		Filename    string
-		Size        string
-		ChunkIndex  int    // Chunk number (for NNTP articles)
-		TotalChunks int    // Total chunks
-	}{
-		Index:       job.part.PartNumber,
-		Total:       job.totalParts,
-		Filename:    job.part.FileName,
-		Size:        sizeStr,
-		ChunkIndex:  job.chunkNumber,
-		TotalChunks: job.totalChunks,
-	}
-	
-	// Process template
-	tmpl, err := template.New("subject").Parse(subject)
-	if err != nil {
-		// Fallback to format showing both part and chunk info
-		subject = fmt.Sprintf("(%02d/%02d) - %s - (%s) yEnc (%04d/%04d)",
-			job.part.PartNumber, job.totalParts, job.part.FileName, sizeStr, job.chunkNumber, job.totalChunks)
-	} else {
-		var buf bytes.Buffer
-		if err := tmpl.Execute(&buf, templateData); err != nil {
-			// Fallback to format showing both part and chunk info
-			subject = fmt.Sprintf("(%02d/%02d) - %s - (%s) yEnc (%04d/%04d)",
-				job.part.PartNumber, job.totalParts, job.part.FileName, sizeStr, job.chunkNumber, job.totalChunks)
-		} else {
-			subject = buf.String()
+// obfuscateParts posts parts under random subjects, and random names with
+// scramble, as configured. The real names are recorded in mapping under the
+// random subjects and under the random names of the yEnc headers.
+func obfuscateParts(obfuscator *obfuscate.Obfuscator, cfg *models.Config, parts []*models.FilePart, scramble bool, mapping map[string]string) {
+	if obfuscator == nil {
+		return
+	}
+	for _, part := range parts {
+		realName := part.FileName
+		if scramble {
+			part.FileName = obfuscator.Name(realName)
+		}
+		if cfg.Obfuscation.RandomSubjects {
+			part.Subject = obfuscator.Subject(realName)
+		}
+		// Downloaders name a file after its subject or its yEnc header, so
+		// both lead back to the real name
+		for _, shown := range []string{part.FileName, part.Subject} {
+			if shown != "" && shown != realName {
+				mapping[shown] = realName
+			}
 		}
 	}
+}
 
-	// Upload chunk
-	messageID, err := client.PostArticle(
-		postingConfig.Posting.Group,
-		subject,
-		fmt.Sprintf("%s <%s>", postingConfig.Posting.PosterName, postingConfig.Posting.PosterEmail),
-		encoded,
-		postingConfig.Posting.CustomHeaders,
-	)
-	
+// paddedLengths returns the real length of every posted file that was padded,
+// keyed by the name the NZB shows for it
+func paddedLengths(parts []*models.FilePart) map[string]int64 {
+	lengths := make(map[string]int64)
+	padded := make(map[string]bool)
+	for _, part := range parts {
+		name := part.FileName
+		if part.Subject != "" {
+			name = part.Subject
+		}
+		lengths[name] += part.Size
+		if part.Padding > 0 {
+			padded[name] = true
+		}
+	}
+	for name := range lengths {
+		if !padded[name] {
+			delete(lengths, name)
+		}
+	}
+	if len(lengths) == 0 {
+		return nil
+	}
+	return lengths
+}
+
+// parseArchiveVolumeSize parses the archive volume size, empty meaning unsplit
+func parseArchiveVolumeSize(size string) (int64, error) {
+	if size == "" || size == "0" {
+		return 0, nil
+	}
+	return utils.ParseFileSize(size)
+}
+
+var (
+	budgetOnce sync.Once
+	budget     *memory.Budget
+)
+
+// memoryBudget returns the memory budget the posts of the process share,
+// from the configuration of the first
+func memoryBudget(cfg *models.Config) *memory.Budget {
+	budgetOnce.Do(func() {
+		if cfg.Performance.MaxMemory == "" {
+			return
+		}
+		// The size was checked with the configuration
+		limit, _ := utils.ParseFileSize(cfg.Performance.MaxMemory)
+		budget = memory.NewBudget(limit)
+	})
+	return budget
+}
+
+// poolServer posts the articles of an upload through the connections of a
+// server
+type poolServer struct {
+	pool *nntp.ConnectionPool
+	cfg  *models.Config
+}
+
+func (s poolServer) Host() string {
+	return s.pool.Server().Host
+}
+
+func (s poolServer) Post(subject string, from string, body string, messageID string) error {
+	_, err := postChunk(s.pool, *s.cfg, subject, from, body, messageID)
+	return err
+}
+
+// uploadParts posts the articles of parts through the servers, the
+// checksums of their sources by path written in the yEnc trailers
+func uploadParts(ctx context.Context, servers *nntp.ServerGroup, parts []*models.FilePart, sourceCRCs map[string]uint32, postingConfig models.Config, yencEnc *yenc.Encoder, log *logger.Logger, hooks *postHooks, tracker *progress.Tracker) ([]*models.PostSegment, error) {
+	from, err := posterFrom(&postingConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to post chunk %d of part %d: %w", job.chunkIndex+1, job.part.PartNumber, err)
+		return nil, err
 	}
 
-	segment := &models.PostSegment{
-		MessageID:   messageID,
-		PartNumber:  job.chunkNumber, // Use chunk number for NZB
-		TotalParts:  job.totalChunks, // Total chunks for NZB
-		FileName:    job.part.FileName,
-		Subject:     subject,
-		PostedAt:    time.Now(),
-		BytesPosted: int64(len(job.chunkData)),
+	// Determine number of workers (use connection count from config)
+	numWorkers := 4 // Default to 4 connections
+	if primary := servers.Primary(); primary != nil && primary.Server().MaxConns > 0 {
+		numWorkers = primary.Server().MaxConns
+	}
+
+	var tiers []upload.Server
+	for _, pool := range servers.Pools() {
+		tiers = append(tiers, poolServer{pool: pool, cfg: &postingConfig})
 	}
-	
-	// Emit real-time progress (thread-safe)
-	tracker.EmitProgress(job.chunkNumber, int64(len(job.chunkData)))
-	
-	log.LogUploadProgress(job.part.FileName, job.chunkNumber, job.totalChunks, int64(len(job.chunkData)))
-	
-	return segment, nil
+	return upload.Parts(ctx, parts, upload.Options{
+		Config:      postingConfig,
+		Servers:     tiers,
+		MessageID:   servers.NextMessageID,
+		From:        from,
+		Connections: numWorkers,
+		Encoder:     *yencEnc,
+		SourceCRCs:  sourceCRCs,
+		Budget:      memoryBudget(&postingConfig),
+		Log:         log,
+		Tracker:     tracker,
+		Hooks: upload.Hooks{
+			Planned: hooks.planned,
+			Reused:  hooks.reused,
+			Posted:  hooks.posted,
+			Failed:  hooks.failed,
+		},
+	})
 }
 
-// splitDataIntoChunks splits data into chunks of specified maximum size
-func splitDataIntoChunks(data []byte, maxChunkSize int) [][]byte {
-	var chunks [][]byte
-	
-	for i := 0; i < len(data); i += maxChunkSize {
-		end := i + maxChunkSize
-		if end > len(data) {
-			end = len(data)
+// postChunk posts an encoded chunk through one server's connection pool
+// under messageID
+func postChunk(pool *nntp.ConnectionPool, postingConfig models.Config, subject string, from string, encoded string, messageID string) (string, error) {
+	client, err := pool.GetClient()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client: %w", err)
+	}
+	defer pool.Release(client)
+
+	// Join the first group, once per connection
+	if groups := postingGroups(&postingConfig); postingConfig.Posting.JoinGroup && len(groups) > 0 {
+		if err := client.JoinGroup(groups[0]); err != nil {
+			return "", fmt.Errorf("failed to join group: %w", err)
 		}
-		chunks = append(chunks, data[i:end])
 	}
-	
-	return chunks
+
+	headers := config.ArticleHeaders(&postingConfig)
+	headers["Message-ID"] = messageID
+
+	return client.PostArticle(
+		postingConfig.Posting.Group,
+		subject,
+		from,
+		encoded,
+		headers,
+	)
 }
 
 func sumPartSizes(parts []*models.FilePart) int64 {
@@ -595,8 +1169,8 @@ func sumPartSizes(parts []*models.FilePart) int64 {
 	return total
 }
 
-// moveGeneratedFiles moves PAR2 and SFV files to the NZB directory
-func moveGeneratedFiles(par2Files []string, sfvPath string, nzbDir string) error {
+// moveGeneratedFiles moves PAR2, SFV and NFO files to the NZB directory
+func moveGeneratedFiles(par2Files []string, sfvPath string, nfoPath string, nzbDir string) error {
 	// Move PAR2 files
 	for _, par2File := range par2Files {
 		if _, err := os.Stat(par2File); err == nil {
@@ -617,5 +1191,15 @@ func moveGeneratedFiles(par2Files []string, sfvPath string, nzbDir string) error
 		}
 	}
 
+	// Move NFO file
+	if nfoPath != "" {
+		if _, err := os.Stat(nfoPath); err == nil {
+			destPath := filepath.Join(nzbDir, filepath.Base(nfoPath))
+			if err := os.Rename(nfoPath, destPath); err != nil {
+				return fmt.Errorf("failed to move NFO file %s: %w", nfoPath, err)
+			}
+		}
+	}
+
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+	"ypost/internal/progress"
+)
+
+// barSink draws a task as a progress bar on the console. Once an upload
+// reports its connections each gets a line below the bar, the block redrawn
+// in place with ANSI cursor moves.
+type barSink struct {
+	bar *progressbar.ProgressBar
+
+	// mu guards the drawing, which the bar's renders also go through
+	mu  sync.Mutex
+	out io.Writer
+	// line is the last render of the bar
+	line  string
+	conns []progress.ConnectionProgress
+	// lines is the number of lines drawn below the bar the last time
+	lines int
+	done  bool
+}
+
+// newBarSink creates the progress bar of a task on the standard output
+func newBarSink(task progress.Task) progress.ProgressSink {
+	s := &barSink{out: os.Stdout}
+	options := []progressbar.Option{
+		progressbar.OptionSetWriter(s),
+		progressbar.OptionSetDescription(task.Description),
+		progressbar.OptionShowCount(),
+		// The ETA of uploads is the tracker's, in the description
+		progressbar.OptionSetPredictTime(false),
+		progressbar.OptionSetRenderBlankState(true),
+	}
+	if task.Transient {
+		options = append(options,
+			progressbar.OptionSetWidth(15),
+			progressbar.OptionClearOnFinish(),
+			progressbar.OptionThrottle(200*time.Millisecond),
+		)
+	} else {
+		options = append(options,
+			progressbar.OptionSetWidth(50),
+			progressbar.OptionFullWidth(),
+			progressbar.OptionThrottle(65*time.Millisecond),
+			progressbar.OptionOnCompletion(s.finish),
+		)
+	}
+	if task.Bytes {
+		options = append(options, progressbar.OptionShowBytes(true))
+	}
+	s.bar = progressbar.NewOptions64(task.Total, options...)
+	return s
+}
+
+// Describe sets the description of the bar
+func (s *barSink) Describe(description string) {
+	s.bar.Describe(description)
+}
+
+// Add moves the bar
+func (s *barSink) Add(n int64) {
+	s.bar.Add64(n)
+}
+
+// Grow raises the total of the bar
+func (s *barSink) Grow(n int64) {
+	s.bar.ChangeMax64(s.bar.GetMax64() + n)
+}
+
+// Finish completes the bar
+func (s *barSink) Finish() {
+	s.bar.Finish()
+}
+
+// Connections redraws the connection lines
+func (s *barSink) Connections(conns []progress.ConnectionProgress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return
+	}
+	s.conns = conns
+	s.draw()
+}
+
+// Write receives the renders of the bar, passed through until there are
+// connection lines
+func (s *barSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Renders clear the line then draw it, both starting with \r
+	line := strings.TrimRight(strings.Trim(string(p), "\r"), " ")
+	if line != "" {
+		s.line = line
+	}
+	if len(s.conns) == 0 {
+		return s.out.Write(p)
+	}
+	if line != "" {
+		s.draw()
+	}
+	return len(p), nil
+}
+
+// finish moves below the bar and its lines once it is complete
+func (s *barSink) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(s.out, "\n")
+	s.done = true
+}
+
+// draw redraws the bar and the connection lines over the previous ones
+func (s *barSink) draw() {
+	var b strings.Builder
+	b.WriteString("\r")
+	if s.lines > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", s.lines)
+	}
+	b.WriteString("\x1b[2K")
+	b.WriteString(s.line)
+	for _, conn := range s.conns {
+		b.WriteString("\n\x1b[2K")
+		b.WriteString(progress.FormatConnection(conn))
+	}
+	s.lines = len(s.conns)
+	io.WriteString(s.out, b.String())
+}
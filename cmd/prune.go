@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"ypost/internal/config"
+	"ypost/internal/prune"
+	"ypost/internal/utils"
+)
+
+var (
+	pruneOlderThan   string
+	pruneDryRun      bool
+	pruneNZBs        bool
+	pruneInterrupted bool
+)
+
+// pruneCmd represents the prune command
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove old output folders, logs and temporary files",
+	Long: `Apply the retention policy to the output directories: the timestamped
+output folders of posts older than retention.outputs lose their PAR2, SFV and
+archive files, their NZBs and name mappings being kept until retention.nzbs;
+date-named logs older than retention.logs and temporary files older than
+retention.temp are removed. Folders of interrupted posts, which ypost resume
+can still finish, are kept unless --interrupted is given. --older-than
+overrides every age but the NZBs'.`,
+	Args: cobra.NoArgs,
+	Run:  runPrune,
+}
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+
+	pruneCmd.Flags().StringVar(&pruneOlderThan, "older-than", "", "remove what is older than this age (e.g. 30d, 2w, 12h)")
+	pruneCmd.Flags().BoolVarP(&pruneDryRun, "dry-run", "n", false, "list what would be removed without removing it")
+	pruneCmd.Flags().BoolVar(&pruneNZBs, "nzbs", false, "also remove the NZBs of pruned output folders")
+	pruneCmd.Flags().BoolVar(&pruneInterrupted, "interrupted", false, "also remove the output folders of interrupted posts")
+	config.AddFlags(pruneCmd.Flags())
+}
+
+func runPrune(cmd *cobra.Command, args []string) {
+	cfg, _, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The ages were validated with the configuration
+	var policy prune.Policy
+	policy.Outputs, _ = prune.ParseAge(cfg.Retention.Outputs)
+	policy.NZBs, _ = prune.ParseAge(cfg.Retention.NZBs)
+	policy.Logs, _ = prune.ParseAge(cfg.Retention.Logs)
+	policy.Temp, _ = prune.ParseAge(cfg.Retention.Temp)
+	if pruneOlderThan != "" {
+		age, err := prune.ParseAge(pruneOlderThan)
+		if err != nil || age <= 0 {
+			fmt.Printf("Error: invalid age %q\n", pruneOlderThan)
+			os.Exit(1)
+		}
+		policy.Outputs, policy.Logs, policy.Temp = age, age, age
+	}
+	if pruneNZBs {
+		policy.NZBs = policy.Outputs
+	}
+	policy.Interrupted = pruneInterrupted
+
+	dirs := prune.Dirs{Output: cfg.Output.OutputDir, NZB: cfg.Output.NZBDir, Log: cfg.Output.LogDir}
+	entries, err := prune.Plan(dirs, policy, time.Now())
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if !pruneDryRun {
+		if err := prune.Remove(entries); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if jsonOutput {
+		if entries == nil {
+			entries = []prune.Entry{}
+		}
+		printJSON(entries)
+		return
+	}
+	if len(entries) == 0 {
+		fmt.Println("Nothing to prune")
+		return
+	}
+
+	var freed int64
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tDATE\tSIZE\tPATH")
+	for _, entry := range entries {
+		path := entry.Path
+		if len(entry.Kept) > 0 {
+			path += " (kept: " + strings.Join(entry.Kept, ", ") + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", entry.Kind, entry.Time.Format("2006-01-02 15:04"), utils.FormatFileSize(entry.Size), path)
+		freed += entry.Size
+	}
+	w.Flush()
+
+	if pruneDryRun {
+		fmt.Printf("\n%d entries would be pruned, freeing %s\n", len(entries), utils.FormatFileSize(freed))
+	} else {
+		fmt.Printf("\nPruned %d entries, freeing %s\n", len(entries), utils.FormatFileSize(freed))
+	}
+}
@@ -0,0 +1,379 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"ypost/internal/config"
+	"ypost/internal/logger"
+	"ypost/internal/queue"
+	"ypost/internal/remote"
+	"ypost/pkg/models"
+)
+
+var queueGroup string
+
+// queueCmd groups the job queue commands
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Manage the persistent job queue",
+	Long: `Queue files and directories for posting and run them later. Jobs and the
+articles posted for them are kept in a database (queue.path, default
+~/.ypost/queue.db) and survive restarts.`,
+}
+
+// queueAddCmd represents the queue add command
+var queueAddCmd = &cobra.Command{
+	Use:   "add <file-or-dir>...",
+	Short: "Add files or directories to the queue",
+	Args:  cobra.MinimumNArgs(1),
+	Run:   runQueueAdd,
+}
+
+// queueListCmd represents the queue list command
+var queueListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the queued jobs",
+	Args:  cobra.NoArgs,
+	Run:   runQueueList,
+}
+
+// queueRunCmd represents the queue run command
+var queueRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Post the pending jobs",
+	Long: `Post the pending jobs one after the other until none is left. Jobs left
+running by an interrupted run are picked up again first. Only one queue run
+may use a queue at a time; jobs can be added while it runs.`,
+	Args: cobra.NoArgs,
+	Run:  runQueueRun,
+}
+
+// queueRetryCmd represents the queue retry command
+var queueRetryCmd = &cobra.Command{
+	Use:   "retry [id]...",
+	Short: "Make failed jobs pending again (all failed jobs without IDs)",
+	Run:   runQueueRetry,
+}
+
+func init() {
+	rootCmd.AddCommand(queueCmd)
+	queueCmd.AddCommand(queueAddCmd, queueListCmd, queueRunCmd, queueRetryCmd)
+
+	queueAddCmd.Flags().StringVarP(&queueGroup, "group", "g", "", "newsgroup to post to (default: posting.group)")
+	for _, command := range []*cobra.Command{queueAddCmd, queueListCmd, queueRetryCmd} {
+		addQueueFlag(command)
+	}
+	addRunFlags(queueRunCmd)
+}
+
+// addQueueFlag registers --queue, the flag of queue.path, on command
+func addQueueFlag(command *cobra.Command) {
+	config.AddFlag(command.Flags(), "queue.path", "queue", "", "queue database (default: ~/.ypost/queue.db)")
+}
+
+// addRunFlags registers the flags of queue run and serve: the queue, the
+// status endpoint, the gRPC API and every other setting
+func addRunFlags(command *cobra.Command) {
+	addQueueFlag(command)
+	config.AddFlag(command.Flags(), "status.address", "status-address", "", "serve the status as JSON on this host:port")
+	config.AddFlag(command.Flags(), "grpc.address", "grpc-address", "", "serve the gRPC API on this host:port")
+	config.AddFlags(command.Flags())
+}
+
+func runQueueAdd(cmd *cobra.Command, args []string) {
+	_, store := openQueue()
+
+	queued := []*queue.Job{}
+	for _, arg := range args {
+		path, err := queuedLocation(arg)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		job, err := store.Add(path, queueGroup)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Queued job %d: %s\n", job.ID, path)
+		queued = append(queued, job)
+	}
+	if jsonOutput {
+		printJSON(queued)
+	}
+}
+
+// queuedLocation returns the absolute path of an input to queue, which must
+// exist; URLs are queued as given
+func queuedLocation(arg string) (string, error) {
+	if remote.IsURL(arg) {
+		return arg, nil
+	}
+	path, err := filepath.Abs(arg)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func runQueueList(cmd *cobra.Command, args []string) {
+	_, store := openQueue()
+
+	jobs, err := store.List()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if jsonOutput {
+		if jobs == nil {
+			jobs = []*queue.Job{}
+		}
+		printJSON(jobs)
+		return
+	}
+	if len(jobs) == 0 {
+		fmt.Println("The queue is empty")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATE\tATTEMPTS\tPATH\tRESULT")
+	for _, job := range jobs {
+		result := job.NZBPath
+		if job.State == queue.StateFailed {
+			result = job.Error
+		}
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\n", job.ID, job.State, job.Attempts, job.Path, result)
+	}
+	w.Flush()
+}
+
+func runQueueRun(cmd *cobra.Command, args []string) {
+	cfg, store := openQueue()
+
+	log := newLogger(cfg)
+	defer log.Close()
+
+	ctx, stop := stopContext(cmd.Context(), log)
+	defer stop()
+	defer startStatus(ctx, cfg, log)()
+	runner := newJobRunner(store)
+	defer startGRPC(cfg, store, runner, log)()
+
+	done, failed := runner.run(ctx, cfg, log, false)
+	log.Info("Queue run finished: %d done, %d failed", done, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// followInterval is how often a runner waiting for jobs looks for jobs queued
+// by other processes
+const followInterval = 5 * time.Second
+
+// jobRunner runs the jobs of a queue one after the other, for queue run and
+// serve, and stops the running one when it is cancelled
+type jobRunner struct {
+	store *queue.Store
+	wake  chan struct{}
+
+	mu      sync.Mutex
+	running uint64
+	cancel  context.CancelFunc
+}
+
+// newJobRunner creates the runner of a queue
+func newJobRunner(store *queue.Store) *jobRunner {
+	return &jobRunner{store: store, wake: make(chan struct{}, 1)}
+}
+
+// Wake makes a runner waiting for jobs look for them at once
+func (r *jobRunner) Wake() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Cancel stops the job if it is the one running
+func (r *jobRunner) Cancel(id uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil && r.running == id {
+		r.cancel()
+	}
+}
+
+// run posts the pending jobs until none is left, then with follow waits for
+// more until ctx is done. It returns the number of jobs done and failed.
+func (r *jobRunner) run(ctx context.Context, cfg *models.Config, log *logger.Logger, follow bool) (int, int) {
+	// Jobs left running are only interrupted ones under the run lock
+	lock, err := r.store.Lock()
+	if err != nil {
+		log.Fatal("%v", err)
+	}
+	defer lock.Unlock()
+
+	if recovered, err := r.store.Recover(); err != nil {
+		log.Fatal("Failed to recover interrupted jobs: %v", err)
+	} else if recovered > 0 {
+		log.Info("Requeued %d job(s) left running by an interrupted run", recovered)
+	}
+
+	done, failed := 0, 0
+	for ctx.Err() == nil {
+		job, err := r.store.Claim()
+		if err != nil {
+			log.Fatal("%v", err)
+		}
+		if job == nil {
+			if !follow {
+				break
+			}
+			select {
+			case <-ctx.Done():
+			case <-r.wake:
+			case <-time.After(followInterval):
+			}
+			continue
+		}
+
+		nzbPath, err := r.post(ctx, cfg, job, log)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			// Left running, the job is picked up again on the next start
+			log.Warn("Job %d interrupted", job.ID)
+		case err != nil && r.cancelled(job.ID):
+			log.Info("Job %d cancelled", job.ID)
+		case err != nil:
+			failed++
+			log.Error("Job %d failed: %v", job.ID, err)
+			if err := r.store.Fail(job.ID, err); err != nil {
+				log.Error("%v", err)
+			}
+		default:
+			done++
+			log.Info("Job %d done: %s", job.ID, nzbPath)
+			if err := r.store.Complete(job.ID, nzbPath); err != nil {
+				log.Error("%v", err)
+			}
+		}
+	}
+	// Write the articles of an interrupted job, which no transaction follows
+	if err := r.store.Flush(); err != nil {
+		log.Error("%v", err)
+	}
+	return done, failed
+}
+
+// post runs a claimed job, recording its post ID and posted articles
+func (r *jobRunner) post(ctx context.Context, cfg *models.Config, job *queue.Job, log *logger.Logger) (string, error) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	r.mu.Lock()
+	r.running, r.cancel = job.ID, cancel
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.running, r.cancel = 0, nil
+		r.mu.Unlock()
+	}()
+	// A job cancelled while it was claimed is stopped before it starts
+	if r.cancelled(job.ID) {
+		cancel()
+	}
+
+	jobCfg := *cfg
+	if job.Group != "" {
+		jobCfg.Posting.Group = job.Group
+	}
+	config.ApplyGroupPreset(&jobCfg)
+
+	log.Info("Running job %d (attempt %d): %s", job.ID, job.Attempts, job.Path)
+	hooks := &postHooks{
+		postStarted: func(postID string) {
+			if err := r.store.Started(job.ID, postID); err != nil {
+				log.Warn("Job %d: %v", job.ID, err)
+			}
+		},
+		segmentPosted: func(segment *models.PostSegment) {
+			err := r.store.RecordSegment(job.ID, queue.Segment{
+				FileName:  segment.FileName,
+				Number:    segment.PartNumber,
+				MessageID: segment.MessageID,
+				Bytes:     segment.BytesPosted,
+				PostedAt:  segment.PostedAt,
+			})
+			if err != nil {
+				log.Warn("Job %d: %v", job.ID, err)
+			}
+		},
+	}
+	return postPath(jobCtx, &jobCfg, job.Path, log, hooks, nil)
+}
+
+// cancelled reports whether a job was cancelled in the queue
+func (r *jobRunner) cancelled(id uint64) bool {
+	job, err := r.store.Get(id)
+	return err == nil && job.State == queue.StateCancelled
+}
+
+func runQueueRetry(cmd *cobra.Command, args []string) {
+	_, store := openQueue()
+
+	var ids []uint64
+	for _, arg := range args {
+		id, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			fmt.Printf("Error: invalid job ID %q\n", arg)
+			os.Exit(1)
+		}
+		ids = append(ids, id)
+	}
+
+	count, err := store.Retry(ids...)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Requeued %d job(s)\n", count)
+}
+
+// openQueue loads the configuration and opens the queue database of
+// queue.path, exiting on error
+func openQueue() (*models.Config, *queue.Store) {
+	cfg, _, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	path := cfg.Queue.Path
+	if path == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		path = filepath.Join(homeDir, ".ypost", "queue.db")
+	}
+
+	store, err := queue.Open(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg, store
+}
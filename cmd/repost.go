@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"ypost/internal/check"
+	"ypost/internal/config"
+	"ypost/internal/nzb"
+	"ypost/internal/repost"
+	"ypost/internal/splitter"
+	"ypost/internal/utils"
+	"ypost/pkg/models"
+)
+
+var (
+	repostServer      string
+	repostConnections int
+	repostArticleSize int64
+	repostPartSize    int64
+	repostNewIDs      bool
+	repostOutput      string
+)
+
+// repostCmd represents the repost-missing command
+var repostCmd = &cobra.Command{
+	Use:   "repost-missing <file.nzb> <sources...>",
+	Short: "Repost the segments of an NZB missing from the servers",
+	Long: `Check every segment of an NZB on the configured servers, repost the
+missing ones and the ones the NZB has no entry for from the source files, and
+write a patched NZB. Sources are files, or directories searched recursively,
+matched to the NZB files by name; each must be the file as it was posted.
+
+Segments are reposted under the message IDs the NZB records unless --new-ids
+is given, for servers refusing an ID they have seen. Segments are read from
+the parts the file was posted in, laid out by the splitting settings of the
+configuration unless --part-size is given, in articles of the size the NZB
+records for the first segment unless --article-size is given. The command
+exits with status 1 when a segment could not be reposted.`,
+	Args: cobra.MinimumNArgs(2),
+	Run:  runRepost,
+}
+
+func init() {
+	rootCmd.AddCommand(repostCmd)
+
+	repostCmd.Flags().StringVar(&repostServer, "server", "", "host of the configured server to post to (default: the first one)")
+	repostCmd.Flags().IntVar(&repostConnections, "connections", 0, "connections per server (default: the server's max_connections)")
+	repostCmd.Flags().Int64Var(&repostArticleSize, "article-size", 0, "bytes of data per segment as originally posted (default: from the NZB)")
+	repostCmd.Flags().Int64Var(&repostPartSize, "part-size", 0, "bytes of data per part as originally posted (default: from the configuration)")
+	repostCmd.Flags().BoolVar(&repostNewIDs, "new-ids", false, "post the segments under new message IDs")
+	repostCmd.Flags().StringVarP(&repostOutput, "output", "o", "", "path of the patched NZB (default: <name>.repaired.nzb next to the NZB)")
+	config.AddFlags(repostCmd.Flags())
+}
+
+// repostOutcome is the JSON result of repost-missing
+type repostOutcome struct {
+	*repost.Result
+	Missing   int      `json:"missing"`
+	NoSource  []string `json:"no_source,omitempty"`
+	PatchedTo string   `json:"nzb_path,omitempty"`
+}
+
+func runRepost(cmd *cobra.Command, args []string) {
+	doc, err := nzb.ParseFile(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg, _, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	sources, err := repostSources(args[1:])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var hosts []string
+	if repostServer != "" {
+		hosts = []string{repostServer}
+	}
+	posting, err := selectServers(cfg, hosts, models.RolePost)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	server := posting[0]
+	configured, err := selectServers(cfg, nil, models.RoleRead)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	var servers []check.Server
+	for _, configuredServer := range configured {
+		servers = append(servers, check.Server{
+			Name:        configuredServer.Host,
+			Connections: repostConnectionsFor(configuredServer.MaxConns),
+			Dial: func() (check.Conn, error) {
+				return dialReader(configuredServer)
+			},
+		})
+	}
+
+	fmt.Printf("Checking %d segments of %d files\n", doc.SegmentCount(), len(doc.Files))
+	report, err := check.Check(cmd.Context(), doc, servers, false)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Segments absent from the NZB are reposted along with the missing ones
+	mapping, _ := doc.Mapping()
+	outcome := repostOutcome{Result: &repost.Result{}}
+	var jobs []repost.Job
+	for i := range doc.Files {
+		file := &doc.Files[i]
+		missing := append(append([]int(nil), report.Files[i].Missing...), file.Gaps()...)
+		if len(missing) == 0 {
+			continue
+		}
+		sort.Ints(missing)
+		outcome.Missing += len(missing)
+
+		name := file.Name()
+		source, ok := sources[name]
+		if !ok {
+			source, ok = sources[mapping[name]]
+		}
+		if !ok {
+			fmt.Printf("  %s: no source for missing segments %s\n", name, formatNumbers(missing))
+			outcome.NoSource = append(outcome.NoSource, name)
+			continue
+		}
+		fmt.Printf("  %s: reposting segments %s\n", name, formatNumbers(missing))
+		jobs = append(jobs, repost.Job{File: i, Source: source, Segments: missing})
+	}
+
+	if outcome.Missing == 0 {
+		fmt.Printf("All %d segments are present\n", report.Segments)
+	}
+	if len(jobs) > 0 {
+		dial := func() (repost.Conn, error) {
+			return dialServer(server)
+		}
+		opts := repost.Options{
+			ArticleSize: repostArticleSize,
+			PartSize:    repostPartSizeFor(cfg),
+			PadParts:    cfg.Splitting.PadParts,
+			NewIDs:      repostNewIDs,
+			Connections: repostConnectionsFor(server.MaxConns),
+			Headers:     config.ArticleHeaders(cfg),
+			LineLength:  cfg.Posting.MaxLineLength,
+		}
+		outcome.Result, err = repost.Repost(cmd.Context(), doc, jobs, dial, opts)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, segment := range outcome.Segments {
+			if segment.Error != "" {
+				fmt.Printf("  %s: segment %d failed: %s\n", segment.File, segment.Number, segment.Error)
+			}
+		}
+		fmt.Printf("Reposted %d segments to %s, %d failed\n", outcome.Reposted, server.Host, outcome.Failed)
+	}
+	if outcome.Reposted > 0 {
+		outcome.PatchedTo = repostOutput
+		if outcome.PatchedTo == "" {
+			outcome.PatchedTo = strings.TrimSuffix(args[0], filepath.Ext(args[0])) + ".repaired.nzb"
+		}
+		if err := doc.WriteFile(outcome.PatchedTo); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Patched NZB: %s\n", outcome.PatchedTo)
+	}
+	if jsonOutput {
+		printJSON(outcome)
+	}
+
+	if outcome.Failed > 0 || len(outcome.NoSource) > 0 {
+		os.Exit(1)
+	}
+}
+
+// repostSources indexes the source files by name
+func repostSources(args []string) (map[string]string, error) {
+	sources := make(map[string]string)
+	for _, arg := range args {
+		files, err := utils.CollectFiles(arg)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			name := filepath.Base(file)
+			if other, ok := sources[name]; ok && other != file {
+				return nil, fmt.Errorf("%s and %s have the same name", other, file)
+			}
+			sources[name] = file
+		}
+	}
+	return sources, nil
+}
+
+// repostConnectionsFor returns --connections, or the server's max_connections
+func repostConnectionsFor(maxConns int) int {
+	if repostConnections > 0 {
+		return repostConnections
+	}
+	return maxConns
+}
+
+// repostPartSizeFor returns the size of the parts files were posted in: the one
+// given, or the one the splitter takes from the configuration
+func repostPartSizeFor(cfg *models.Config) int64 {
+	if repostPartSize > 0 {
+		return repostPartSize
+	}
+	partSize := cfg.Posting.MaxPartSize
+	if cfg.Splitting.AlignParts {
+		articleSize := cfg.Posting.MaxArticleSize
+		if repostArticleSize > 0 {
+			articleSize = repostArticleSize
+		}
+		partSize = splitter.AlignedPartSize(partSize, articleSize)
+	}
+	return partSize
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"ypost/internal/config"
+	"ypost/internal/journal"
+	"ypost/internal/upload"
+	"ypost/pkg/models"
+)
+
+// resumeCmd represents the resume command
+var resumeCmd = &cobra.Command{
+	Use:   "resume <state-file-or-dir>",
+	Short: "Finish an interrupted post",
+	Long: `Finish a post that was interrupted, from the journal it left in its output
+directory (` + journal.FileName + `). Articles already posted are reused rather
+than posted again and the NZB is written with all of them. The post's own
+settings are taken from the journal; the servers and security settings come
+from the current configuration.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runResume,
+}
+
+func init() {
+	rootCmd.AddCommand(resumeCmd)
+	// No settings flags: the settings of the post are those of its journal
+}
+
+func runResume(cmd *cobra.Command, args []string) {
+	statePath := args[0]
+	if info, err := os.Stat(statePath); err == nil && info.IsDir() {
+		statePath = journal.Path(statePath)
+	}
+	state, err := journal.Read(statePath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg, _, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	postCfg := state.PostConfig(cfg)
+
+	log := newLogger(&postCfg)
+	defer log.Close()
+
+	ctx, stop := stopContext(cmd.Context(), log)
+	defer stop()
+
+	log.Info("Resuming post %s of %s started %s: %d articles posted, %d known pending",
+		state.ID, state.Source, state.StartedAt.Format("2006-01-02 15:04:05"), state.PostedCount(), state.PendingCount())
+	start := time.Now()
+	articles := state.PostedCount()
+	counted := &postHooks{segmentPosted: func(*models.PostSegment) { articles++ }}
+	nzbPath, err := postPath(ctx, &postCfg, state.Source, log, counted, state)
+	if jsonOutput {
+		printJSON(newPostSummary(state.Source, nzbPath, articles, time.Since(start), err))
+	}
+	if err != nil {
+		if upload.IncompleteArticles(err) > 0 {
+			log.Warn("Incomplete NZB file: %s", nzbPath)
+		} else if nzbPath != "" {
+			log.Warn("NZB file: %s", nzbPath)
+		}
+		log.Fatal("Posting failed: %v", err)
+	}
+	log.Info("Posting completed successfully!")
+	log.Info("NZB file: %s", nzbPath)
+}
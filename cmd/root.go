@@ -5,11 +5,14 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+	"ypost/internal/config"
 )
 
 var (
-	cfgFile string
-	verbose bool
+	cfgFile   string
+	cfgFormat string
+	verbose   bool
+	quiet     bool
 )
 
 // rootCmd represents the base command
@@ -20,6 +23,11 @@ var rootCmd = &cobra.Command{
 It supports yEnc encoding, file splitting, NZB generation, PAR2 recovery files,
 and SFV checksums.`,
 	Version: "1.0.0",
+	// The settings flags of the command run apply over the environment
+	// and the file; no subcommand has a PersistentPreRun of its own
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		config.SetFlags(cmd.Flags())
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -34,10 +42,21 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.ypost/config.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFormat, "config-format", "", "config file format: yaml, toml or json (default: from the file extension)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "no progress bars, and only warnings and errors on the console")
+	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "print results as JSON on stdout and messages on stderr")
+	rootCmd.PersistentFlags().StringVar(&progressJSON, "progress-json", "", "write progress events as JSON lines to a file, fd:N or - for stdout")
 }
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
-	// Config initialization is handled in subcommands
+	initOutput()
+
+	// Config initialization is handled in subcommands; only the format
+	// override applies to all of them
+	if err := config.SetFormat(cfgFormat); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
 }
\ No newline at end of file
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"ypost/internal/config"
+)
+
+// visitCommands calls fn with every command below and including command
+func visitCommands(command *cobra.Command, fn func(*cobra.Command)) {
+	fn(command)
+	for _, child := range command.Commands() {
+		visitCommands(child, fn)
+	}
+}
+
+func TestNoFlagsForSecrets(t *testing.T) {
+	visitCommands(rootCmd, func(command *cobra.Command) {
+		check := func(flag *pflag.Flag) {
+			// Flags are named after their keys, - for . and _
+			if config.IsSecretKey(strings.ReplaceAll(flag.Name, "-", "_")) {
+				t.Errorf("%s: unexpected flag --%s for a secret", command.CommandPath(), flag.Name)
+			}
+		}
+		command.Flags().VisitAll(check)
+		command.PersistentFlags().VisitAll(check)
+	})
+}
+
+// settingFlags returns the flags of command, inherited ones included, by the
+// configuration key they set, with an error for every key set by two flags
+func settingFlags(command *cobra.Command) (map[string]string, []string) {
+	flagged := make(map[string]string)
+	var errors []string
+	check := func(flag *pflag.Flag) {
+		key := flag.Annotations["config_key"]
+		if len(key) != 1 {
+			return
+		}
+		if name, ok := flagged[key[0]]; ok && name != flag.Name {
+			errors = append(errors, fmt.Sprintf("both --%s and --%s set %s", name, flag.Name, key[0]))
+		}
+		flagged[key[0]] = flag.Name
+	}
+	command.Flags().VisitAll(check)
+	command.InheritedFlags().VisitAll(check)
+	return flagged, errors
+}
+
+func TestOneFlagPerSetting(t *testing.T) {
+	visitCommands(rootCmd, func(command *cobra.Command) {
+		_, errors := settingFlags(command)
+		for _, err := range errors {
+			t.Errorf("%s: %s", command.CommandPath(), err)
+		}
+	})
+
+	// Every command loading the configuration takes the flags of the
+	// settings, under the names of their aliases
+	tests := []struct {
+		command *cobra.Command
+		key     string
+		flag    string
+	}{
+		{postCmd, "posting.group", "group"},
+		{postCmd, "par2.enabled", "par2"},
+		{watchCmd, "par2.enabled", "par2-enabled"},
+		{watchCmd, "status.address", "status-address"},
+		{queueRunCmd, "grpc.address", "grpc-address"},
+		{queueAddCmd, "queue.path", "queue"},
+		{serveCmd, "queue.path", "queue"},
+		{speedtestCmd, "posting.max_article_size", "article-size"},
+		{historyListCmd, "history.path", "history-path"},
+		{checkCmd, "nntp.servers.host", "nntp-servers-host"},
+	}
+	for _, test := range tests {
+		flagged, _ := settingFlags(test.command)
+		if flagged[test.key] != test.flag {
+			t.Errorf("%s: expected --%s for %s, got %q", test.command.CommandPath(), test.flag, test.key, flagged[test.key])
+		}
+	}
+}
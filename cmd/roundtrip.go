@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ypost/internal/config"
+	"ypost/internal/logger"
+	"ypost/internal/progress"
+	"ypost/internal/roundtrip"
+	"ypost/pkg/models"
+)
+
+// roundTripPost has the configured SABnzbd or NZBGet download the post of
+// nzbPath, repairing and unpacking it, and reports the outcome to hooks. A
+// download that fails fails the post; a round trip that cannot be carried
+// out only logs a warning.
+func roundTripPost(ctx context.Context, cfg *models.Config, nzbPath string, phases *progress.Phases, hooks *postHooks, log *logger.Logger) error {
+	if cfg.RoundTrip.Client == "" || nzbPath == "" {
+		return nil
+	}
+	checker, err := newRoundTrip(cfg, log)
+	if err != nil {
+		log.Warn("Cannot download the post back: %v", err)
+		return nil
+	}
+	data, err := os.ReadFile(nzbPath)
+	if err != nil {
+		log.Warn("Cannot download the post back: %v", err)
+		return nil
+	}
+	// The archive password lets the downloader unpack it
+	password := ""
+	if cfg.Archive.Format != "" {
+		password = cfg.Archive.Password
+	}
+
+	phases.Start(progress.PhaseRoundTrip)
+	defer phases.Finish(progress.PhaseRoundTrip)
+	log.Info("Downloading the post back with %s...", checker)
+	name := strings.TrimSuffix(filepath.Base(nzbPath), ".nzb")
+	status, err := checker.Check(ctx, name, password, data, func(fraction float64) {
+		phases.Update(progress.PhaseRoundTrip, fraction)
+	})
+	if err != nil {
+		log.Warn("Cannot download the post back: %v", err)
+		return nil
+	}
+
+	hooks.checked(status.Passed)
+	if !status.Passed {
+		return fmt.Errorf("round trip failed: %s could not download the post: %s", checker, status.Message)
+	}
+	log.Info("%s downloaded and repaired the post", checker)
+	return nil
+}
+
+// newRoundTrip returns the checker of the round trip settings, their
+// secrets resolved
+func newRoundTrip(cfg *models.Config, log *logger.Logger) (*roundtrip.Checker, error) {
+	roundTripConfig := cfg.RoundTrip
+	// The key and password may reference environment variables
+	for _, secret := range []*string{&roundTripConfig.APIKey, &roundTripConfig.Password} {
+		resolved, err := config.ResolveSecret(*secret, "")
+		if err != nil {
+			return nil, err
+		}
+		log.Redact(resolved)
+		*secret = resolved
+	}
+	return roundtrip.New(roundTripConfig)
+}
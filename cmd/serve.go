@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"ypost/internal/config"
+	"ypost/internal/logger"
+	"ypost/internal/queue"
+	"ypost/internal/rpc"
+	"ypost/pkg/models"
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run queued jobs as they are added, serving the status and the gRPC API",
+	Long: `Run the jobs of the queue like queue run, then keep waiting for more until
+interrupted. Jobs are added with queue add, or submitted through the gRPC API
+(grpc.address), which also streams their progress, returns their NZB and
+cancels them; see pkg/api/ypost.proto.`,
+	Args: cobra.NoArgs,
+	Run:  runServe,
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	addRunFlags(serveCmd)
+}
+
+func runServe(cmd *cobra.Command, args []string) {
+	cfg, store := openQueue()
+
+	log := newLogger(cfg)
+	defer log.Close()
+
+	ctx, stop := stopContext(cmd.Context(), log)
+	defer stop()
+	defer startStatus(ctx, cfg, log)()
+	runner := newJobRunner(store)
+	defer startGRPC(cfg, store, runner, log)()
+
+	log.Info("Serving the queue %s", store.Path())
+	done, failed := runner.run(ctx, cfg, log, true)
+	log.Info("Stopped serving: %d done, %d failed", done, failed)
+}
+
+// startGRPC serves the gRPC API of grpc.address over the queue run by
+// runner, when set. It returns the function stopping it.
+func startGRPC(cfg *models.Config, store *queue.Store, runner *jobRunner, log *logger.Logger) func() {
+	address := cfg.GRPC.Address
+	if address == "" {
+		return func() {}
+	}
+
+	// The token may reference environment variables, as passwords do
+	token, err := config.ResolveSecret(cfg.GRPC.Token, "")
+	if err != nil {
+		log.Fatal("Failed to resolve grpc.token: %v", err)
+	}
+	log.Redact(token)
+
+	monitor, release := useMonitor()
+	server, err := rpc.Listen(address, rpc.NewService(store, runner, monitor), token)
+	if err != nil {
+		release()
+		log.Fatal("Failed to start the gRPC API: %v", err)
+	}
+	if token == "" {
+		log.Warn("The gRPC API on %s accepts any client: set grpc.token to require one", server.Addr())
+	}
+	log.Info("Serving the gRPC API on %s", server.Addr())
+
+	return func() {
+		if err := server.Close(); err != nil {
+			log.Warn("Failed to stop the gRPC API: %v", err)
+		}
+		release()
+	}
+}
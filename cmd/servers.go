@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+
+	"ypost/internal/nntp"
+	"ypost/pkg/models"
+)
+
+// selectServers returns the configured servers whose host is in hosts, or all
+// of them when hosts is empty, keeping those with role: models.RolePost or
+// models.RoleRead, any role when empty
+func selectServers(cfg *models.Config, hosts []string, role string) ([]models.ServerConfig, error) {
+	selected := make(map[string]bool)
+	for _, host := range hosts {
+		selected[host] = true
+	}
+
+	var servers []models.ServerConfig
+	for _, server := range cfg.NNTP.Servers {
+		if len(selected) > 0 && !selected[server.Host] {
+			continue
+		}
+		delete(selected, server.Host)
+		if !hasRole(server, role) {
+			if len(hosts) > 0 {
+				return nil, fmt.Errorf("server %s does not %s articles", server.Host, role)
+			}
+			continue
+		}
+		servers = append(servers, server)
+	}
+
+	for host := range selected {
+		return nil, fmt.Errorf("no configured server %s", host)
+	}
+	if len(servers) == 0 && role != "" {
+		return nil, fmt.Errorf("no servers configured to %s articles", role)
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no servers configured")
+	}
+	return servers, nil
+}
+
+// hasRole reports whether a server is used for role, any when empty
+func hasRole(server models.ServerConfig, role string) bool {
+	switch role {
+	case models.RolePost:
+		return server.Posts()
+	case models.RoleRead:
+		return server.Reads()
+	}
+	return true
+}
+
+// dialServer opens an authenticated connection of its own to a server
+func dialServer(server models.ServerConfig) (*nntp.Client, error) {
+	client := nntp.NewClient(&server)
+	if err := client.Connect(); err != nil {
+		return nil, err
+	}
+	if err := client.Authenticate(); err != nil {
+		client.Quit()
+		return nil, err
+	}
+	return client, nil
+}
+
+// dialReader opens an authenticated connection of its own to a server to
+// read articles from, in reader mode. MODE READER goes before the
+// credentials, as RFC 4643 has it, unless the server asks for them first.
+func dialReader(server models.ServerConfig) (*nntp.Client, error) {
+	client := nntp.NewClient(&server)
+	if err := client.Connect(); err != nil {
+		return nil, err
+	}
+	err := client.ModeReader()
+	authenticated := false
+	if nntp.AuthRequired(err) {
+		if err = client.Authenticate(); err == nil {
+			authenticated = true
+			err = client.ModeReader()
+		}
+	}
+	if err == nil && !authenticated {
+		err = client.Authenticate()
+	}
+	if err != nil {
+		client.Quit()
+		return nil, err
+	}
+	return client, nil
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"ypost/internal/sfv"
+	"ypost/internal/utils"
+)
+
+var (
+	sfvOutput string
+	sfvJobs   int
+)
+
+// sfvCmd groups the SFV commands
+var sfvCmd = &cobra.Command{
+	Use:   "sfv",
+	Short: "Create and verify SFV checksum files",
+}
+
+// sfvCreateCmd represents the sfv create command
+var sfvCreateCmd = &cobra.Command{
+	Use:   "create <files...>",
+	Short: "Create an SFV file for files",
+	Long: `Create an SFV file listing the CRC32 of files, or of the files below
+directories. Files are listed relative to the SFV file's directory, or by name
+when outside of it.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runSFVCreate,
+}
+
+// sfvVerifyCmd represents the sfv verify command
+var sfvVerifyCmd = &cobra.Command{
+	Use:   "verify <file.sfv>",
+	Short: "Verify files against an SFV file",
+	Long: `Check every file listed in an SFV file, relative to its directory, and
+summarize the mismatched and missing files. The command exits with status 1
+when a file does not match.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runSFVVerify,
+}
+
+func init() {
+	rootCmd.AddCommand(sfvCmd)
+	sfvCmd.AddCommand(sfvCreateCmd, sfvVerifyCmd)
+
+	sfvCmd.PersistentFlags().IntVarP(&sfvJobs, "jobs", "j", runtime.NumCPU(), "files checksummed at once")
+	sfvCreateCmd.Flags().StringVarP(&sfvOutput, "output", "o", "", "path of the SFV file (default: named after the first input, next to it)")
+}
+
+func runSFVCreate(cmd *cobra.Command, args []string) {
+	var files []string
+	for _, arg := range args {
+		collected, err := utils.CollectFiles(arg)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, file := range collected {
+			if !strings.EqualFold(filepath.Ext(file), ".sfv") {
+				files = append(files, file)
+			}
+		}
+	}
+	if len(files) == 0 {
+		fmt.Printf("Error: no files found\n")
+		os.Exit(1)
+	}
+
+	output := sfvOutput
+	if output == "" {
+		if info, err := os.Stat(args[0]); err == nil && info.IsDir() {
+			output = filepath.Join(args[0], filepath.Base(filepath.Clean(args[0]))+".sfv")
+		} else {
+			name := filepath.Base(args[0])
+			output = filepath.Join(filepath.Dir(args[0]), strings.TrimSuffix(name, filepath.Ext(name))+".sfv")
+		}
+	}
+
+	generator := sfv.NewGenerator(filepath.Dir(output))
+	generator.SetWorkers(sfvJobs)
+	sfvPath, err := generator.CreateSFV(files, filepath.Base(output))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Created %s for %d files\n", sfvPath, len(files))
+	if jsonOutput {
+		printJSON(struct {
+			SFVPath string `json:"sfv_path"`
+			Files   int    `json:"files"`
+		}{sfvPath, len(files)})
+	}
+}
+
+// sfvOutcome is the JSON result of sfv verify
+type sfvOutcome struct {
+	Entries    []sfv.Entry `json:"entries"`
+	OK         int         `json:"ok"`
+	Mismatched int         `json:"mismatched"`
+	Missing    int         `json:"missing"`
+}
+
+func runSFVVerify(cmd *cobra.Command, args []string) {
+	generator := sfv.NewGenerator(filepath.Dir(args[0]))
+	generator.SetWorkers(sfvJobs)
+	entries, err := generator.Verify(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(entries) == 0 {
+		fmt.Printf("Error: no entries in %s\n", args[0])
+		os.Exit(1)
+	}
+
+	outcome := sfvOutcome{Entries: entries}
+	for _, entry := range entries {
+		switch {
+		case entry.OK():
+			outcome.OK++
+			fmt.Printf("  OK        %s\n", entry.Name)
+		case entry.Missing():
+			outcome.Missing++
+			fmt.Printf("  MISSING   %s: %s\n", entry.Name, entry.Error)
+		default:
+			outcome.Mismatched++
+			fmt.Printf("  MISMATCH  %s: expected %s, got %s\n", entry.Name, entry.Expected, entry.Actual)
+		}
+	}
+	fmt.Printf("%d files: %d OK, %d mismatched, %d missing\n", len(entries), outcome.OK, outcome.Mismatched, outcome.Missing)
+	if jsonOutput {
+		printJSON(outcome)
+	}
+
+	if outcome.Mismatched > 0 || outcome.Missing > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"ypost/internal/logger"
+)
+
+// stopContext returns a context done on the first SIGINT or SIGTERM, as a
+// container is stopped with. The running post then takes no more articles
+// but finishes, and records in its journal and the queue, the ones being
+// posted, so it resumes where it stopped; a second signal exits at once.
+// The returned function stops listening for the signals.
+func stopContext(parent context.Context, log *logger.Logger) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case sig := <-signals:
+			log.Warn("Stopping (%s): finishing the articles being posted, signal again to exit at once", sig)
+			cancel()
+		case <-done:
+			return
+		}
+		select {
+		case sig := <-signals:
+			log.Fatal("Stopping at once (%s): the articles being posted are not finished", sig)
+		case <-done:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		close(done)
+		cancel()
+	}
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"ypost/internal/config"
+	"ypost/internal/speedtest"
+	"ypost/internal/utils"
+	"ypost/pkg/models"
+)
+
+var (
+	speedtestServer      string
+	speedtestConnections []int
+	speedtestDuration    time.Duration
+	speedtestGroup       string
+)
+
+// speedtestCmd represents the speedtest command
+var speedtestCmd = &cobra.Command{
+	Use:   "speedtest",
+	Short: "Measure the posting throughput of a server",
+	Long: `Post throwaway articles of random data to a test group and report the
+throughput reached with each number of connections, to help tune
+max_connections. By default the connection count doubles from 1 up to the
+server's max_connections. The articles are real posts: use a test group.`,
+	Args: cobra.NoArgs,
+	Run:  runSpeedtest,
+}
+
+func init() {
+	rootCmd.AddCommand(speedtestCmd)
+
+	speedtestCmd.Flags().StringVar(&speedtestServer, "server", "", "host of the configured server to test (default: the first one)")
+	speedtestCmd.Flags().IntSliceVar(&speedtestConnections, "connections", nil, "connection counts to measure (default: 1, 2, 4, ... up to max_connections)")
+	speedtestCmd.Flags().DurationVar(&speedtestDuration, "duration", 30*time.Second, "how long each connection count posts")
+	speedtestCmd.Flags().StringVar(&speedtestGroup, "group", "alt.binaries.test", "group the test articles are posted to")
+	config.AddFlag(speedtestCmd.Flags(), "posting.max_article_size", "article-size", "", "bytes of data per article")
+	config.AddFlags(speedtestCmd.Flags())
+}
+
+// speedtestOutcome is the JSON result of a speedtest
+type speedtestOutcome struct {
+	Server      string             `json:"server"`
+	Results     []speedtest.Result `json:"results"`
+	Recommended int                `json:"recommended_connections"`
+}
+
+func runSpeedtest(cmd *cobra.Command, args []string) {
+	if speedtestDuration <= 0 {
+		fmt.Printf("Error: duration must be positive\n")
+		os.Exit(1)
+	}
+
+	cfg, _, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	var hosts []string
+	if speedtestServer != "" {
+		hosts = []string{speedtestServer}
+	}
+	servers, err := selectServers(cfg, hosts, models.RolePost)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	server := servers[0]
+	from, err := posterFrom(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	counts := speedtestConnections
+	if len(counts) == 0 {
+		for count := 1; count < server.MaxConns; count *= 2 {
+			counts = append(counts, count)
+		}
+		counts = append(counts, server.MaxConns)
+	}
+
+	opts := speedtest.Options{
+		Group:       speedtestGroup,
+		From:        from,
+		ArticleSize: int(cfg.Posting.MaxArticleSize),
+		Duration:    speedtestDuration,
+	}
+	dial := func() (speedtest.Conn, error) {
+		return dialServer(server)
+	}
+
+	fmt.Printf("Posting %s articles to %s on %s for %s per connection count\n",
+		utils.FormatFileSize(int64(opts.ArticleSize)), opts.Group, server.Host, opts.Duration)
+	var results []speedtest.Result
+	for _, count := range counts {
+		fmt.Printf("Measuring with %d connections...\n", count)
+		result, err := speedtest.Run(cmd.Context(), dial, count, opts)
+		if err != nil {
+			fmt.Printf("Error with %d connections: %v\n", count, err)
+			os.Exit(1)
+		}
+		results = append(results, result)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CONNECTIONS\tARTICLES\tTHROUGHPUT\tPER CONNECTION\tERRORS")
+	for _, result := range results {
+		fmt.Fprintf(w, "%d\t%d\t%s/s\t%s/s\t%d\n", result.Connections, result.Articles,
+			utils.FormatFileSize(int64(result.BytesPerSecond())), utils.FormatFileSize(int64(result.PerConnection())), result.Errors)
+	}
+	w.Flush()
+
+	recommended, ok := speedtest.Recommend(results)
+	if ok {
+		fmt.Printf("Recommended max_connections: %d (%s/s)\n", recommended.Connections, utils.FormatFileSize(int64(recommended.BytesPerSecond())))
+	}
+	if jsonOutput {
+		printJSON(speedtestOutcome{Server: server.Host, Results: results, Recommended: recommended.Connections})
+	}
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"ypost/internal/history"
+	"ypost/internal/utils"
+)
+
+var statsDays int
+
+// statsCmd represents the stats command
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show posting statistics per day and per server",
+	Long: `Aggregate the posting history per day and per server: the number of posts,
+the failure rate, the data posted, the average speed of the successful posts
+and the number of articles retried on another server. Posts recorded before
+the history kept servers are listed under "-".`,
+	Args: cobra.NoArgs,
+	Run:  runStats,
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	statsCmd.Flags().IntVar(&statsDays, "days", 30, "number of days to aggregate, today included (0 for all)")
+}
+
+func runStats(cmd *cobra.Command, args []string) {
+	if statsDays < 0 {
+		fmt.Printf("Error: days must not be negative\n")
+		os.Exit(1)
+	}
+
+	store := openHistory()
+	defer store.Close()
+
+	var since time.Time
+	if statsDays > 0 {
+		now := time.Now()
+		since = time.Date(now.Year(), now.Month(), now.Day()-statsDays+1, 0, 0, 0, 0, now.Location())
+	}
+	stats, err := store.Stats(since)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if jsonOutput {
+		printJSON(stats)
+		return
+	}
+	if stats.Total.Posts == 0 {
+		fmt.Println("No posts found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DAY\tPOSTS\tFAILED\tPOSTED\tSPEED\tARTICLES\tRETRIES")
+	for _, day := range stats.Days {
+		printTotals(w, day.Day, day.Totals)
+	}
+	printTotals(w, "total", stats.Total)
+	w.Flush()
+	fmt.Println()
+
+	w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVER\tPOSTS\tFAILED\tPOSTED\tSPEED\tARTICLES\tRETRIES")
+	for _, server := range stats.Servers {
+		name := server.Server
+		if name == "" {
+			name = "-"
+		}
+		printTotals(w, name, server.Totals)
+	}
+	w.Flush()
+}
+
+// printTotals prints a row of a stats table
+func printTotals(w io.Writer, label string, totals history.Totals) {
+	fmt.Fprintf(w, "%s\t%d\t%d (%.1f%%)\t%s\t%s/s\t%d\t%d\n", label, totals.Posts, totals.Failed, totals.FailureRate,
+		utils.FormatFileSize(totals.Bytes), utils.FormatFileSize(int64(totals.BytesPerSecond)), totals.Articles, totals.Retries)
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+
+	"ypost/internal/logger"
+	"ypost/internal/status"
+	"ypost/pkg/models"
+)
+
+// statusMonitor follows the posts of the queue run, serve and watch modes for
+// the status endpoint and the gRPC API, nil when both are disabled
+var statusMonitor *status.Monitor
+
+// startStatus serves the status endpoint of status.address, when set; once
+// ctx is done its /healthz reports the process draining. It returns the
+// function stopping it.
+func startStatus(ctx context.Context, cfg *models.Config, log *logger.Logger) func() {
+	address := cfg.Status.Address
+	if address == "" {
+		return func() {}
+	}
+
+	monitor, release := useMonitor()
+	server, err := status.Listen(address, monitor)
+	if err != nil {
+		release()
+		log.Fatal("Failed to start the status endpoint: %v", err)
+	}
+	log.Info("Serving the status on http://%s/status", server.Addr())
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			monitor.Drain()
+		case <-stopped:
+		}
+	}()
+
+	return func() {
+		close(stopped)
+		if err := server.Close(); err != nil {
+			log.Warn("Failed to stop the status endpoint: %v", err)
+		}
+		release()
+	}
+}
+
+// useMonitor returns statusMonitor, creating it to follow the posts of the
+// process when it is not yet, for the status endpoint and the gRPC API. The
+// returned function stops the monitor it created.
+func useMonitor() (*status.Monitor, func()) {
+	if statusMonitor != nil {
+		return statusMonitor, func() {}
+	}
+	monitor := status.NewMonitor()
+	unsubscribe := monitor.Subscribe()
+	statusMonitor = monitor
+	return monitor, func() {
+		unsubscribe()
+		statusMonitor = nil
+	}
+}
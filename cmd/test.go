@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"ypost/internal/config"
+	"ypost/internal/nntp"
+	"ypost/pkg/models"
+)
+
+// Step outcomes of a server test
+const (
+	testOK      = "ok"
+	testFailed  = "FAIL"
+	testSkipped = "-"
+)
+
+// testCmd represents the test command
+var testCmd = &cobra.Command{
+	Use:     "test",
+	Aliases: []string{"test-connection"},
+	Short:   "Test the connection to every configured server",
+	Long: `Connect to every configured server, authenticate, check that posting is
+allowed, or that servers with the read role switch to reader mode, and that
+the posting groups can be selected, and print a pass/fail table. The command
+exits with status 1 when a server fails, so credentials can be checked before
+an upload.`,
+	Args: cobra.NoArgs,
+	Run:  runTest,
+}
+
+func init() {
+	rootCmd.AddCommand(testCmd)
+	config.AddFlags(testCmd.Flags())
+}
+
+// serverTest is the outcome of testing a server; each step is ok, FAIL or
+// skipped after an earlier failure
+type serverTest struct {
+	Server  string `json:"server"`
+	Connect string `json:"connect"`
+	Auth    string `json:"auth"`
+	Posting string `json:"posting"`
+	Groups  string `json:"groups"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Passed reports whether no step failed
+func (t serverTest) Passed() bool {
+	return t.Error == ""
+}
+
+func runTest(cmd *cobra.Command, args []string) {
+	cfg, _, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	servers, err := selectServers(cfg, nil, "")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	groups := postingGroups(cfg)
+
+	var results []serverTest
+	failed := 0
+	for _, server := range servers {
+		result := runServerTest(server, groups)
+		if !result.Passed() {
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVER\tCONNECT\tAUTH\tPOSTING\tGROUPS")
+	for _, result := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", result.Server, result.Connect, result.Auth, result.Posting, result.Groups)
+	}
+	w.Flush()
+	for _, result := range results {
+		if !result.Passed() {
+			fmt.Printf("%s: %s\n", result.Server, result.Error)
+		}
+	}
+	if jsonOutput {
+		printJSON(results)
+	}
+
+	if failed > 0 {
+		fmt.Printf("%d of %d servers failed\n", failed, len(results))
+		os.Exit(1)
+	}
+	fmt.Printf("All %d servers passed\n", len(results))
+}
+
+// runServerTest runs the steps of a server test, stopping at the first failure
+func runServerTest(server models.ServerConfig, groups []string) serverTest {
+	result := serverTest{
+		Server:  fmt.Sprintf("%s:%d", server.Host, server.Port),
+		Connect: testSkipped,
+		Auth:    testSkipped,
+		Posting: testSkipped,
+		Groups:  testSkipped,
+	}
+	fail := func(step *string, err error) serverTest {
+		*step = testFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	client := nntp.NewClient(&server)
+	if err := client.Connect(); err != nil {
+		return fail(&result.Connect, err)
+	}
+	defer client.Quit()
+	result.Connect = testOK
+
+	if err := client.Authenticate(); err != nil {
+		return fail(&result.Auth, err)
+	}
+	result.Auth = testOK
+	if server.Username == "" || server.Password == "" {
+		result.Auth = "none"
+	}
+
+	// Servers only read from switch to reader mode instead
+	if server.Posts() {
+		if !client.CanPost() {
+			return fail(&result.Posting, fmt.Errorf("the server does not allow posting"))
+		}
+		result.Posting = testOK
+	} else {
+		if err := client.ModeReader(); err != nil {
+			return fail(&result.Posting, err)
+		}
+		result.Posting = models.RoleRead
+	}
+
+	if len(groups) == 0 {
+		return fail(&result.Groups, fmt.Errorf("no posting group configured"))
+	}
+	var missing []string
+	for _, group := range groups {
+		if err := client.JoinGroup(group); err != nil {
+			missing = append(missing, group)
+		}
+	}
+	if len(missing) > 0 {
+		return fail(&result.Groups, fmt.Errorf("cannot select group %s", strings.Join(missing, ", ")))
+	}
+	result.Groups = testOK
+	return result
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"ypost/internal/logger"
+	"ypost/internal/nzb"
+	"ypost/internal/progress"
+	"ypost/internal/verify"
+	"ypost/pkg/models"
+)
+
+// verifyPost fetches the sample of the articles of a post that
+// posting.verify sets back from the reading servers, and compares them with
+// the source. Articles that come back damaged, or not at all, fail the post.
+func verifyPost(ctx context.Context, cfg *models.Config, files []nzb.FileEntry, generated [][]*models.PostSegment, phases *progress.Phases, log *logger.Logger) error {
+	// The mode was checked with the configuration and the flag
+	sample, _ := verify.ParseSample(cfg.Posting.Verify)
+	if !sample.Enabled() {
+		return nil
+	}
+
+	// Articles recorded by a run without their checksum are left out
+	var articles []verify.Article
+	add := func(segments []*models.PostSegment) {
+		for _, segment := range segments {
+			if segment.CRC32 == 0 {
+				continue
+			}
+			articles = append(articles, verify.Article{
+				MessageID: segment.MessageID,
+				FileName:  segment.FileName,
+				Number:    segment.PartNumber,
+				Size:      segment.BytesPosted,
+				CRC32:     segment.CRC32,
+			})
+		}
+	}
+	for _, file := range files {
+		add(file.Segments)
+	}
+	for _, segments := range generated {
+		add(segments)
+	}
+	if len(articles) == 0 {
+		return nil
+	}
+
+	servers, err := verifyTargets(cfg)
+	if err != nil {
+		log.Warn("Cannot verify the post: %v", err)
+		return nil
+	}
+
+	phases.Start(progress.PhaseVerify)
+	defer phases.Finish(progress.PhaseVerify)
+	log.Info("Verifying %d of %d articles posted...", sample.Size(len(articles)), len(articles))
+	report, err := verify.Verify(ctx, articles, sample, servers)
+	if err != nil {
+		return fmt.Errorf("failed to verify the post: %w", err)
+	}
+
+	for _, problem := range report.Problems {
+		log.Error("Article %s of %s (%d): %s", problem.MessageID, problem.FileName, problem.Number, problem.Reason)
+	}
+	if report.OK() {
+		log.Info("Verified %d articles: all match the source", report.Sampled)
+		return nil
+	}
+	corrupt, missing := report.Counts()
+	return fmt.Errorf("post verification failed: %d corrupt and %d missing of %d articles verified", corrupt, missing, report.Sampled)
+}
+
+// verifyTargets returns the servers the articles are read back from
+func verifyTargets(cfg *models.Config) ([]verify.Server, error) {
+	configured, err := selectServers(cfg, nil, models.RoleRead)
+	if err != nil {
+		return nil, err
+	}
+
+	var servers []verify.Server
+	for _, server := range configured {
+		servers = append(servers, verify.Server{
+			Name:        server.Host,
+			Connections: server.MaxConns,
+			Dial: func() (verify.Conn, error) {
+				return dialReader(server)
+			},
+		})
+	}
+	return servers, nil
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"ypost/internal/config"
+	"ypost/internal/watchdir"
+	"ypost/pkg/models"
+)
+
+var (
+	watchInterval time.Duration
+	watchSettle   time.Duration
+	watchDoneDir  string
+	watchFailDir  string
+)
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch <dir>",
+	Short: "Post files as they appear in a folder",
+	Long: `Watch a folder and post every file or directory dropped into it once it has
+stopped growing, each as a job of its own. Posted entries are moved to done/
+and failed ones to failed/ (inside the watched folder unless --done and
+--failed are given). Hidden entries are ignored, so tools can write to
+.name and rename when finished. The command keeps running until interrupted;
+changes to the configuration file's subject template, redundancy, NZB and
+group preset settings apply to the next job.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runWatch,
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", watchdir.DefaultInterval, "time between scans of the folder")
+	watchCmd.Flags().DurationVar(&watchSettle, "settle", watchdir.DefaultSettle, "time an entry must stay unchanged before it is posted")
+	watchCmd.Flags().StringVar(&watchDoneDir, "done", "", "folder posted entries are moved to (default <dir>/done)")
+	watchCmd.Flags().StringVar(&watchFailDir, "failed", "", "folder failed entries are moved to (default <dir>/failed)")
+	config.AddFlag(watchCmd.Flags(), "status.address", "status-address", "", "serve the status as JSON on this host:port")
+	config.AddFlags(watchCmd.Flags())
+}
+
+func runWatch(cmd *cobra.Command, args []string) {
+	dir := args[0]
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		fmt.Printf("Error: %s is not a directory\n", dir)
+		os.Exit(1)
+	}
+	doneDir, failDir := watchDoneDir, watchFailDir
+	if doneDir == "" {
+		doneDir = filepath.Join(dir, "done")
+	}
+	if failDir == "" {
+		failDir = filepath.Join(dir, "failed")
+	}
+
+	cfg, configFileUsed, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	log := newLogger(cfg)
+	defer log.Close()
+
+	// Reload the hot-reloadable settings when the configuration file changes
+	current := func() *models.Config { return cfg }
+	if configFileUsed != "" {
+		watcher, err := config.Watch(configFileUsed, log, nil)
+		if err != nil {
+			log.Fatal("Failed to watch configuration file: %v", err)
+		}
+		current = watcher.Config
+	}
+
+	ctx, stop := stopContext(cmd.Context(), log)
+	defer stop()
+	defer startStatus(ctx, cfg, log)()
+
+	folder := watchdir.New(dir, watchSettle, doneDir, failDir)
+	log.Info("Watching %s (scan every %s, posting after %s unchanged)", dir, watchInterval, watchSettle)
+
+	for {
+		ready, err := folder.Scan(time.Now())
+		if err != nil {
+			log.Error("%v", err)
+		}
+
+		for _, path := range ready {
+			if ctx.Err() != nil {
+				break
+			}
+
+			jobCfg := *current()
+			config.ApplyGroupPreset(&jobCfg)
+
+			log.Info("Posting %s", path)
+			target := doneDir
+			nzbPath, err := postPath(ctx, &jobCfg, path, log, nil, nil)
+			if err != nil && ctx.Err() != nil {
+				// Interrupted; the entry is posted again on the next start
+				log.Warn("Posting of %s interrupted", path)
+				break
+			}
+			if err != nil {
+				log.Error("Failed to post %s: %v", path, err)
+				target = failDir
+			} else {
+				log.Info("Posted %s: %s", path, nzbPath)
+			}
+
+			moved, err := watchdir.Move(path, target)
+			if err != nil {
+				log.Error("%v", err)
+				continue
+			}
+			log.Info("Moved %s to %s", path, moved)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info("Stopped watching %s", dir)
+			return
+		case <-time.After(watchInterval):
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package archive
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Supported archive formats
+const (
+	FormatZip = "zip"
+	Format7z  = "7z"
+	FormatRar = "rar"
+)
+
+// Archiver wraps input files into store-mode (uncompressed) archives before posting
+type Archiver struct {
+	format     string
+	password   string
+	volumeSize int64
+	outputDir  string
+}
+
+// NewArchiver creates a new archiver. volumeSize splits the archive into volumes
+// of at most that many bytes (0 disables splitting). zip is created natively;
+// 7z and rar require the 7z and rar binaries in PATH.
+func NewArchiver(format string, password string, volumeSize int64, outputDir string) (*Archiver, error) {
+	format = strings.ToLower(format)
+	switch format {
+	case FormatZip:
+		if password != "" {
+			return nil, fmt.Errorf("password-protected zip archives are not supported, use 7z or rar")
+		}
+		if volumeSize > 0 {
+			return nil, fmt.Errorf("split zip archives are not supported, use 7z or rar")
+		}
+	case Format7z, FormatRar:
+	default:
+		return nil, fmt.Errorf("unsupported archive format %q (expected zip, 7z or rar)", format)
+	}
+
+	return &Archiver{
+		format:     format,
+		password:   password,
+		volumeSize: volumeSize,
+		outputDir:  outputDir,
+	}, nil
+}
+
+// Create archives inputPath (a file or directory) and returns the archive
+// volume paths in order
+func (a *Archiver) Create(inputPath string) ([]string, error) {
+	if err := os.MkdirAll(a.outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	baseName := filepath.Base(inputPath)
+	if info, err := os.Stat(inputPath); err != nil {
+		return nil, fmt.Errorf("failed to stat input: %w", err)
+	} else if !info.IsDir() {
+		baseName = strings.TrimSuffix(baseName, filepath.Ext(baseName))
+	}
+	archivePath := filepath.Join(a.outputDir, fmt.Sprintf("%s.%s", baseName, a.format))
+
+	switch a.format {
+	case FormatZip:
+		if err := a.createZip(inputPath, archivePath); err != nil {
+			return nil, err
+		}
+		return []string{archivePath}, nil
+	case Format7z:
+		if err := a.run("7z", a.sevenZipArgs(inputPath, archivePath)); err != nil {
+			return nil, err
+		}
+	case FormatRar:
+		if err := a.run("rar", a.rarArgs(inputPath, archivePath)); err != nil {
+			return nil, err
+		}
+	}
+
+	return a.collectVolumes(baseName)
+}
+
+// createZip writes a store-only zip of inputPath
+func (a *Archiver) createZip(inputPath string, archivePath string) error {
+	out, err := os.Create(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer out.Close()
+
+	writer := zip.NewWriter(out)
+	root := filepath.Dir(inputPath)
+
+	err = filepath.Walk(inputPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		header.Method = zip.Store
+
+		entry, err := writer.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(entry, file)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to finalize archive: %w", err)
+	}
+	return nil
+}
+
+// sevenZipArgs builds the 7z command line for a store-only archive
+func (a *Archiver) sevenZipArgs(inputPath string, archivePath string) []string {
+	args := []string{"a", "-mx=0", "-y"}
+	if a.volumeSize > 0 {
+		args = append(args, fmt.Sprintf("-v%db", a.volumeSize))
+	}
+	if a.password != "" {
+		args = append(args, "-p"+a.password, "-mhe=on")
+	}
+	return append(args, archivePath, inputPath)
+}
+
+// rarArgs builds the rar command line for a store-only archive
+func (a *Archiver) rarArgs(inputPath string, archivePath string) []string {
+	args := []string{"a", "-m0", "-ep1", "-y", "-idq"}
+	if a.volumeSize > 0 {
+		args = append(args, fmt.Sprintf("-v%db", a.volumeSize))
+	}
+	if a.password != "" {
+		args = append(args, "-hp"+a.password)
+	}
+	return append(args, archivePath, inputPath)
+}
+
+// run invokes an external archiver binary
+func (a *Archiver) run(binary string, args []string) error {
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return fmt.Errorf("%s archives require the %s binary in PATH: %w", a.format, binary, err)
+	}
+
+	output, err := exec.Command(path, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %w: %s", binary, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// collectVolumes finds the volumes written for baseName, in volume order
+func (a *Archiver) collectVolumes(baseName string) ([]string, error) {
+	// rar: name.rar or name.partNN.rar, 7z: name.7z or name.7z.NNN
+	patterns := []string{
+		filepath.Join(a.outputDir, baseName+"."+a.format),
+		filepath.Join(a.outputDir, baseName+".part*."+a.format),
+		filepath.Join(a.outputDir, baseName+"."+a.format+".[0-9]*"),
+	}
+
+	seen := make(map[string]bool)
+	var volumes []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list archive volumes: %w", err)
+		}
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				volumes = append(volumes, match)
+			}
+		}
+	}
+
+	if len(volumes) == 0 {
+		return nil, fmt.Errorf("archiver produced no volumes for %s", baseName)
+	}
+
+	// Zero-padded volume numbers sort lexically
+	sort.Strings(volumes)
+	return volumes, nil
+}
@@ -0,0 +1,95 @@
+package archive
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateZipStoreMode(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Create a small directory tree to archive
+	inputDir := filepath.Join(tempDir, "release")
+	if err := os.MkdirAll(filepath.Join(inputDir, "extras"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"movie.mkv":         "movie data",
+		"extras/sample.mkv": "sample data",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	archiver, err := NewArchiver(FormatZip, "", 0, filepath.Join(tempDir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	volumes, err := archiver.Create(inputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(volumes) != 1 || filepath.Base(volumes[0]) != "release.zip" {
+		t.Fatalf("unexpected volumes: %v", volumes)
+	}
+
+	reader, err := zip.OpenReader(volumes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	if len(reader.File) != len(files) {
+		t.Fatalf("expected %d entries, got %d", len(files), len(reader.File))
+	}
+	for _, entry := range reader.File {
+		if entry.Method != zip.Store {
+			t.Errorf("entry %s is compressed", entry.Name)
+		}
+
+		rc, err := entry.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+
+		rel, _ := filepath.Rel("release", filepath.FromSlash(entry.Name))
+		if string(data) != files[filepath.ToSlash(rel)] {
+			t.Errorf("entry %s has unexpected content %q", entry.Name, data)
+		}
+	}
+}
+
+func TestNewArchiverValidation(t *testing.T) {
+	tests := []struct {
+		format     string
+		password   string
+		volumeSize int64
+		hasError   bool
+	}{
+		{"zip", "", 0, false},
+		{"ZIP", "", 0, false},
+		{"zip", "secret", 0, true},
+		{"zip", "", 1024, true},
+		{"7z", "secret", 1024, false},
+		{"rar", "secret", 1024, false},
+		{"tar", "", 0, true},
+	}
+
+	for _, test := range tests {
+		_, err := NewArchiver(test.format, test.password, test.volumeSize, t.TempDir())
+		if test.hasError && err == nil {
+			t.Errorf("Expected error for %s (password %q, volumes %d)", test.format, test.password, test.volumeSize)
+		}
+		if !test.hasError && err != nil {
+			t.Errorf("Unexpected error for %s: %v", test.format, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Package batch posts the entries of a list of paths, several at a time,
+// and summarizes how each went
+package batch
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Result is the outcome of one list entry
+type Result struct {
+	Path     string
+	NZBPath  string
+	Err      error
+	Duration time.Duration
+}
+
+// ReadList returns the paths of a list, one per line. Blank lines and lines
+// starting with # are skipped.
+func ReadList(r io.Reader) ([]string, error) {
+	var paths []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	return paths, scanner.Err()
+}
+
+// Run runs post for every path with at most jobs running at once and
+// returns the results in list order. Entries not started when ctx is
+// cancelled are reported as failed.
+func Run(ctx context.Context, paths []string, jobs int, post func(string) (string, error)) []Result {
+	if jobs < 1 {
+		jobs = 1
+	}
+	results := make([]Result, len(paths))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range queue {
+				start := time.Now()
+				nzbPath, err := post(paths[index])
+				results[index] = Result{Path: paths[index], NZBPath: nzbPath, Err: err, Duration: time.Since(start)}
+			}
+		}()
+	}
+
+	for i := range paths {
+		if ctx != nil && ctx.Err() != nil {
+			results[i] = Result{Path: paths[i], Err: ctx.Err()}
+			continue
+		}
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	return results
+}
+
+// Summary renders one line per entry followed by the totals, and returns
+// the number of failed entries
+func Summary(results []Result) (string, int) {
+	var b strings.Builder
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			fmt.Fprintf(&b, "FAILED  %s: %v\n", result.Path, result.Err)
+			continue
+		}
+		fmt.Fprintf(&b, "OK      %s -> %s (%s)\n", result.Path, result.NZBPath, result.Duration.Round(time.Second))
+	}
+	fmt.Fprintf(&b, "%d posted, %d failed\n", len(results)-failed, failed)
+	return b.String(), failed
+}
@@ -0,0 +1,84 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadList(t *testing.T) {
+	list := "# releases\n/data/one.mkv\n\n  /data/two dir  \r\n#/data/skipped\n"
+	paths, err := ReadList(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(paths, "|") != "/data/one.mkv|/data/two dir" {
+		t.Errorf("unexpected paths %q", paths)
+	}
+}
+
+func TestRun(t *testing.T) {
+	paths := []string{"a", "b", "c", "d", "e"}
+	var running, most int32
+	results := Run(context.Background(), paths, 2, func(path string) (string, error) {
+		now := atomic.AddInt32(&running, 1)
+		for {
+			seen := atomic.LoadInt32(&most)
+			if now <= seen || atomic.CompareAndSwapInt32(&most, seen, now) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		if path == "c" {
+			return "", errors.New("refused")
+		}
+		return path + ".nzb", nil
+	})
+
+	if most > 2 {
+		t.Errorf("%d entries posted at once, expected at most 2", most)
+	}
+	for i, result := range results {
+		if result.Path != paths[i] {
+			t.Errorf("result %d is %s, expected list order", i, result.Path)
+		}
+	}
+	if results[2].Err == nil || results[0].NZBPath != "a.nzb" {
+		t.Errorf("unexpected results %+v", results)
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	posted := 0
+	results := Run(ctx, []string{"a", "b"}, 1, func(string) (string, error) {
+		posted++
+		return "", nil
+	})
+	if posted != 0 {
+		t.Errorf("%d entries posted after the cancel", posted)
+	}
+	for _, result := range results {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("expected %s to be cancelled, got %v", result.Path, result.Err)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	summary, failed := Summary([]Result{
+		{Path: "/data/one.mkv", NZBPath: "out/one.mkv.nzb", Duration: 61400 * time.Millisecond},
+		{Path: "/data/two.mkv", Err: errors.New("no server accepted the articles")},
+	})
+	want := "OK      /data/one.mkv -> out/one.mkv.nzb (1m1s)\n" +
+		"FAILED  /data/two.mkv: no server accepted the articles\n" +
+		"1 posted, 1 failed\n"
+	if summary != want || failed != 1 {
+		t.Errorf("unexpected summary (%d failed):\n%s", failed, summary)
+	}
+}
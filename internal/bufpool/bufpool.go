@@ -0,0 +1,43 @@
+package bufpool
+
+import "sync"
+
+// oversize is how many times its size a buffer may have grown to and still
+// go back to the pool; larger ones are left to the garbage collector
+const oversize = 4
+
+// Pool recycles byte buffers of about the same size, such as the articles of
+// a post, so posting does not allocate a buffer per article
+type Pool struct {
+	size int
+	pool sync.Pool
+}
+
+// New creates a pool of buffers with a capacity of size
+func New(size int) *Pool {
+	p := &Pool{size: size}
+	p.pool.New = func() any {
+		buf := make([]byte, 0, size)
+		return &buf
+	}
+	return p
+}
+
+// Size returns the capacity of the buffers the pool creates
+func (p *Pool) Size() int {
+	return p.size
+}
+
+// Get returns an empty buffer with a capacity of at least the pool's size
+func (p *Pool) Get() []byte {
+	return (*p.pool.Get().(*[]byte))[:0]
+}
+
+// Put gives back a buffer no longer used, including one grown by appends
+func (p *Pool) Put(buf []byte) {
+	if cap(buf) < p.size || cap(buf) > oversize*p.size {
+		return
+	}
+	buf = buf[:0]
+	p.pool.Put(&buf)
+}
@@ -0,0 +1,27 @@
+package bufpool
+
+import "testing"
+
+func TestPool(t *testing.T) {
+	pool := New(64)
+	buf := pool.Get()
+	if len(buf) != 0 || cap(buf) < 64 {
+		t.Fatalf("expected an empty buffer of 64 bytes, got %d/%d", len(buf), cap(buf))
+	}
+	buf = append(buf, "article"...)
+	pool.Put(buf)
+
+	// A recycled buffer comes back empty
+	if buf := pool.Get(); len(buf) != 0 || cap(buf) < 64 {
+		t.Errorf("expected an empty buffer of 64 bytes, got %d/%d", len(buf), cap(buf))
+	}
+
+	// Buffers too small or grown too large are not kept, so never returned
+	pool.Put(make([]byte, 0, 10))
+	pool.Put(make([]byte, 0, 1000))
+	for i := 0; i < 10; i++ {
+		if buf := pool.Get(); cap(buf) < 64 || cap(buf) > 4*64 {
+			t.Fatalf("unexpected buffer of %d bytes", cap(buf))
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"ypost/internal/nzb"
+)
+
+// Conn is a reader connection able to look articles up
+type Conn interface {
+	Stat(messageID string) (bool, error)
+	Quit() error
+}
+
+// Server is a reader server to check with
+type Server struct {
+	Name        string
+	Connections int
+	Dial        func() (Conn, error)
+}
+
+// FileReport is the completion of a file of the NZB
+type FileReport struct {
+	Name     string `json:"name"`
+	Segments int    `json:"segments"`
+	Present  int    `json:"present"`
+	// Missing holds the numbers of the segments found on no server (or not
+	// on every server when every server is required)
+	Missing []int `json:"missing,omitempty"`
+	// PerServer holds the number of segments found on each server, by name
+	PerServer map[string]int `json:"per_server"`
+}
+
+// Report is the completion of an NZB
+type Report struct {
+	Files    []FileReport `json:"files"`
+	Segments int          `json:"segments"`
+	Present  int          `json:"present"`
+}
+
+// Percent returns the share of segments present, from 0 to 100
+func (r *Report) Percent() float64 {
+	return percent(r.Present, r.Segments)
+}
+
+// Percent returns the share of the file's segments present, from 0 to 100
+func (f *FileReport) Percent() float64 {
+	return percent(f.Present, f.Segments)
+}
+
+func percent(present, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(present) * 100 / float64(total)
+}
+
+// segmentRef identifies a segment of the NZB
+type segmentRef struct {
+	file    int
+	segment int
+}
+
+// Check looks every segment of doc up on each server. A segment counts as
+// present when a server has it, or when every server has it if requireAll
+// is set. A connection failing mid-check is reopened once per segment
+// before the check gives up.
+func Check(ctx context.Context, doc *nzb.NZB, servers []Server, requireAll bool) (*Report, error) {
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no servers to check with")
+	}
+
+	// found[server][file][segment]
+	found := make([][][]bool, len(servers))
+	for i := range servers {
+		found[i] = make([][]bool, len(doc.Files))
+		for j, file := range doc.Files {
+			found[i][j] = make([]bool, len(file.Segments))
+		}
+	}
+
+	for i, server := range servers {
+		if err := checkServer(ctx, doc, server, found[i]); err != nil {
+			return nil, fmt.Errorf("failed to check with %s: %w", server.Name, err)
+		}
+	}
+
+	report := &Report{}
+	for j, file := range doc.Files {
+		fileReport := FileReport{Name: file.Name(), Segments: len(file.Segments), PerServer: make(map[string]int)}
+		for k, segment := range file.Segments {
+			hits := 0
+			for i, server := range servers {
+				if found[i][j][k] {
+					hits++
+					fileReport.PerServer[server.Name]++
+				}
+			}
+			if hits == len(servers) || (hits > 0 && !requireAll) {
+				fileReport.Present++
+			} else {
+				fileReport.Missing = append(fileReport.Missing, segment.Number)
+			}
+		}
+		report.Files = append(report.Files, fileReport)
+		report.Segments += fileReport.Segments
+		report.Present += fileReport.Present
+	}
+	return report, nil
+}
+
+// checkServer looks every segment up on one server, recording hits in found
+func checkServer(ctx context.Context, doc *nzb.NZB, server Server, found [][]bool) error {
+	connections := server.Connections
+	if connections < 1 {
+		connections = 1
+	}
+
+	refs := make(chan segmentRef)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for w := 0; w < connections; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := statWorker(server, doc, refs, found); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}()
+	}
+
+	go func() {
+		defer close(refs)
+		for j, file := range doc.Files {
+			for k := range file.Segments {
+				select {
+				case refs <- segmentRef{file: j, segment: k}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	// Interrupted by the caller
+	return ctx.Err()
+}
+
+// statWorker looks segments up over a connection of its own until refs is
+// closed; each index of found is written by one worker only
+func statWorker(server Server, doc *nzb.NZB, refs <-chan segmentRef, found [][]bool) error {
+	var conn Conn
+	defer func() {
+		if conn != nil {
+			conn.Quit()
+		}
+	}()
+
+	for ref := range refs {
+		messageID := doc.Files[ref.file].Segments[ref.segment].MessageID
+		var err error
+		for attempt := 0; attempt < 2; attempt++ {
+			if conn == nil {
+				if conn, err = server.Dial(); err != nil {
+					conn = nil
+					continue
+				}
+			}
+			var present bool
+			if present, err = conn.Stat(messageID); err == nil {
+				found[ref.file][ref.segment] = present
+				break
+			}
+			conn.Quit()
+			conn = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ypost/internal/nzb"
+)
+
+// fakeConn answers STAT from a set of message IDs
+type fakeConn struct {
+	articles map[string]bool
+	fail     *int
+}
+
+func (c *fakeConn) Stat(messageID string) (bool, error) {
+	if c.fail != nil && *c.fail > 0 {
+		*c.fail--
+		return false, errors.New("connection reset")
+	}
+	return c.articles[messageID], nil
+}
+
+func (c *fakeConn) Quit() error { return nil }
+
+func fakeServer(name string, fail *int, ids ...string) Server {
+	articles := make(map[string]bool)
+	for _, id := range ids {
+		articles[id] = true
+	}
+	return Server{Name: name, Connections: 1, Dial: func() (Conn, error) {
+		return &fakeConn{articles: articles, fail: fail}, nil
+	}}
+}
+
+func testNZB() *nzb.NZB {
+	return &nzb.NZB{Files: []nzb.File{
+		{Subject: `"a.bin" yEnc (1/2)`, Segments: []nzb.Segment{{Number: 1, MessageID: "a1"}, {Number: 2, MessageID: "a2"}}},
+		{Subject: `"b.bin" yEnc (1/2)`, Segments: []nzb.Segment{{Number: 1, MessageID: "b1"}, {Number: 2, MessageID: "b2"}}},
+	}}
+}
+
+func TestCheckAnyServer(t *testing.T) {
+	servers := []Server{
+		fakeServer("one", nil, "a1", "b1"),
+		fakeServer("two", nil, "a2"),
+	}
+
+	report, err := Check(context.Background(), testNZB(), servers, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Present != 3 || report.Segments != 4 || report.Percent() != 75 {
+		t.Errorf("unexpected totals %+v", report)
+	}
+	a, b := report.Files[0], report.Files[1]
+	if a.Name != "a.bin" || a.Present != 2 || a.PerServer["one"] != 1 || a.PerServer["two"] != 1 {
+		t.Errorf("unexpected report for a.bin: %+v", a)
+	}
+	if b.Present != 1 || len(b.Missing) != 1 || b.Missing[0] != 2 {
+		t.Errorf("unexpected report for b.bin: %+v", b)
+	}
+}
+
+func TestCheckAllServers(t *testing.T) {
+	servers := []Server{
+		fakeServer("one", nil, "a1", "a2", "b1", "b2"),
+		fakeServer("two", nil, "a1", "a2"),
+	}
+
+	report, err := Check(context.Background(), testNZB(), servers, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Files[0].Percent() != 100 || report.Files[1].Percent() != 0 {
+		t.Errorf("unexpected completion %v / %v", report.Files[0].Percent(), report.Files[1].Percent())
+	}
+}
+
+func TestCheckRetriesFailedConnection(t *testing.T) {
+	// A single failure is retried over a new connection
+	failures := 1
+	report, err := Check(context.Background(), testNZB(), []Server{fakeServer("one", &failures, "a1", "a2", "b1", "b2")}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Percent() != 100 {
+		t.Errorf("expected full completion, got %v", report.Percent())
+	}
+
+	// A segment failing twice stops the check
+	failures = 2
+	if _, err := Check(context.Background(), testNZB(), []Server{fakeServer("one", &failures)}, false); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -2,48 +2,59 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/mail"
+	"net/textproto"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
 
 	"github.com/spf13/viper"
+	"ypost/internal/hook"
+	"ypost/internal/indexer"
+	"ypost/internal/logger"
+	"ypost/internal/nfo"
+	"ypost/internal/nntp"
+	"ypost/internal/notify"
+	"ypost/internal/prune"
+	"ypost/internal/roundtrip"
+	"ypost/internal/schedule"
 	"ypost/internal/utils"
+	"ypost/internal/verify"
 	"ypost/pkg/models"
 )
 
+// messageIDDomain matches a host name usable as the right-hand side of a
+// Message-ID
+var messageIDDomain = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`)
+
 // LoadConfig loads configuration from file and environment
 func LoadConfig(configPath string) (*models.Config, string, error) {
-	v := viper.New()
-
-	// Set default values
-	setDefaults(v)
-
-	// Set config file
-	if configPath != "" {
-		v.SetConfigFile(configPath)
-	} else {
-		v.SetConfigName("config")
-		v.SetConfigType("yaml")
-		// Search paths in order:
-		v.AddConfigPath(".")                // 1. Current directory
-		v.AddConfigPath("$HOME/.ypost")     // 2. User's home directory
-		v.AddConfigPath("/etc/ypost")       // 3. System-wide configuration
+	v, err := readConfig(configPath)
+	if err != nil {
+		return nil, "", err
 	}
 
-	// Read environment variables
-	v.SetEnvPrefix("USENET")
-	v.AutomaticEnv()
-
-	// Read config file
-	if err := v.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return nil, "", fmt.Errorf("failed to read config file: %w", err)
+	// logging.levels is also accepted as "par2=debug,progress=warn", the
+	// only form an environment variable can take
+	if value, ok := v.Get("logging.levels").(string); ok {
+		levels, err := logger.SplitLevels(value)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid logging.levels: %w", err)
 		}
+		v.Set("logging.levels", levels)
 	}
 
 	var config models.Config
 	if err := v.Unmarshal(&config); err != nil {
 		return nil, "", fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	if err := decodeGroups(v, &config, nil); err != nil {
+		return nil, "", err
+	}
 
 	// Handle legacy configuration format (backward compatibility)
 	if len(config.NNTP.Servers) == 0 {
@@ -57,6 +68,8 @@ func LoadConfig(configPath string) (*models.Config, string, error) {
 				Password: config.NNTP.Password,
 				SSL:      config.NNTP.SSL,
 				MaxConns: config.NNTP.Connections,
+
+				PasswordCmd: config.NNTP.PasswordCmd,
 			}
 			if server.Port == 0 {
 				server.Port = 563 // Default NNTP SSL port
@@ -68,6 +81,20 @@ func LoadConfig(configPath string) (*models.Config, string, error) {
 		}
 	}
 
+	// Settings for every server override the file's servers list
+	if err := applyServerOverrides(config.NNTP.Servers); err != nil {
+		return nil, "", err
+	}
+
+	// Handle features.create_par2/create_sfv, which only ever turned them
+	// on, unless the flags turn them off
+	if config.Features.CreatePAR2 && !FlagChanged("par2.enabled") {
+		config.Par2.Enabled = true
+	}
+	if config.Features.CreateSFV && !FlagChanged("sfv.enabled") {
+		config.SFV.Enabled = true
+	}
+
 	// Handle newsgroup/group field mapping
 	if config.Posting.Group == "" && config.Posting.Newsgroup != "" {
 		config.Posting.Group = config.Posting.Newsgroup
@@ -75,17 +102,19 @@ func LoadConfig(configPath string) (*models.Config, string, error) {
 
 	// Parse max_file_size and set it to posting.max_part_size
 	// Prioritize splitting.max_file_size over posting.max_part_size
-	if config.Splitting.MaxFileSize != "" {
-		fmt.Printf("DEBUG: Found splitting.max_file_size: %s\n", config.Splitting.MaxFileSize)
+	// unless max_part_size is given at a higher precedence layer
+	if config.Splitting.MaxFileSize != "" &&
+		settingSource(v.InConfig, "splitting.max_file_size") >= settingSource(v.InConfig, "posting.max_part_size") {
 		maxPartSize, err := utils.ParseFileSize(config.Splitting.MaxFileSize)
 		if err != nil {
 			return nil, "", fmt.Errorf("invalid max_file_size: %w", err)
 		}
-		fmt.Printf("DEBUG: Parsed max_file_size to %d bytes\n", maxPartSize)
 		config.Posting.MaxPartSize = maxPartSize
-		fmt.Printf("DEBUG: Set posting.max_part_size to %d bytes\n", config.Posting.MaxPartSize)
-	} else {
-		fmt.Printf("DEBUG: No splitting.max_file_size found, using posting.max_part_size: %d\n", config.Posting.MaxPartSize)
+	}
+
+	// Resolve ${VAR} and password_cmd secrets
+	if err := resolveSecrets(&config); err != nil {
+		return nil, "", fmt.Errorf("failed to resolve secrets: %w", err)
 	}
 
 	// Validate configuration
@@ -97,6 +126,75 @@ func LoadConfig(configPath string) (*models.Config, string, error) {
 	return &config, configFileUsed, nil
 }
 
+// DefaultConfig returns the configuration made of the default values only
+func DefaultConfig() (*models.Config, error) {
+	v := viper.New()
+	setDefaults(v)
+
+	var config models.Config
+	if err := v.Unmarshal(&config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal defaults: %w", err)
+	}
+
+	// Keep the part size consistent with splitting.max_file_size, which
+	// LoadConfig gives precedence
+	maxPartSize, err := utils.ParseFileSize(config.Splitting.MaxFileSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid default max_file_size: %w", err)
+	}
+	config.Posting.MaxPartSize = maxPartSize
+	return &config, nil
+}
+
+// readConfig creates a viper instance with defaults, environment overrides and
+// the configuration file, searched for in the usual locations when configPath
+// is empty
+func readConfig(configPath string) (*viper.Viper, error) {
+	v := viper.New()
+
+	// Set default values
+	setDefaults(v)
+
+	// Set config file. Without a format override, the format follows the
+	// extension and the search accepts config.yaml, config.toml or config.json.
+	if configFormat != "" {
+		v.SetConfigType(configFormat)
+	}
+	if configPath != "" {
+		v.SetConfigFile(configPath)
+	} else {
+		v.SetConfigName("config")
+		// Search paths in order:
+		v.AddConfigPath(".")                // 1. Current directory
+		v.AddConfigPath("$HOME/.ypost")     // 2. User's home directory
+		v.AddConfigPath("/etc/ypost")       // 3. System-wide configuration
+	}
+
+	// Read environment variables (posting.group is USENET_POSTING_GROUP) and
+	// the flags set with SetFlags, which take precedence over the file
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	if err := bindFlags(v); err != nil {
+		return nil, err
+	}
+
+	// Read config file
+	if err := v.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+
+	// Lists of settings, such as the servers, are read from the environment
+	// over the file
+	if err := applyListEnv(v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
 // setDefaults sets default configuration values
 func setDefaults(v *viper.Viper) {
 	// NNTP defaults - don't set servers default to allow legacy format
@@ -106,33 +204,162 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("nntp.password", "your-password")
 	v.SetDefault("nntp.ssl", true)
 	v.SetDefault("nntp.connections", 4)
+	v.SetDefault("nntp.password_cmd", "")
 
 	// Posting defaults
 	v.SetDefault("posting.group", "alt.binaries.test")
+	v.SetDefault("posting.newsgroup", "")
+	v.SetDefault("posting.from", "")
+	v.SetDefault("posting.poster_name", "")
 	v.SetDefault("posting.poster_email", "poster@example.com")
-	v.SetDefault("posting.subject_template", "[{{.Index}}/{{.Total}}] - {{.Filename}} - ({{.Size}})")
+	v.SetDefault("posting.subject_template", "")
+	v.SetDefault("posting.subject_comment", "")
+	v.SetDefault("posting.subject_quote", models.SubjectQuoteDouble)
+	v.SetDefault("posting.subject_brackets", models.SubjectBracketsSquare)
 	v.SetDefault("posting.max_line_length", 128)
 	v.SetDefault("posting.max_part_size", 750000)
-	v.SetDefault("posting.max_article_size", 500000) // 500KB for NNTP article chunks
+	v.SetDefault("posting.max_article_size", DefaultArticleSize)
+	v.SetDefault("posting.preserve_paths", false)
+	v.SetDefault("posting.join_group", true)
+	v.SetDefault("posting.retries", 1)
+	v.SetDefault("posting.on_error", models.OnErrorSkip)
+	v.SetDefault("posting.verify", "none")
+	v.SetDefault("posting.x_no_archive", false)
+	v.SetDefault("posting.organization", "")
+	v.SetDefault("posting.x_newsposter", "")
 
 	// Output defaults
 	v.SetDefault("output.output_dir", "output")
 	v.SetDefault("output.nzb_dir", "output/nzb")
 	v.SetDefault("output.log_dir", "output/logs")
 
+	// NZB defaults
+	v.SetDefault("nzb.title_template", "{{.Filename}}")
+	v.SetDefault("nzb.category", "misc")
+	v.SetDefault("nzb.tags", []string{})
+	v.SetDefault("nzb.mapping_mode", "sidecar")
+	v.SetDefault("nzb.par2", "all")
+	v.SetDefault("nzb.include_sfv", true)
+	v.SetDefault("nzb.include_nfo", true)
+
 	// Splitting defaults
 	v.SetDefault("splitting.max_file_size", "50MB")
+	v.SetDefault("splitting.naming", "")
+	v.SetDefault("splitting.name_width", 0)
+	v.SetDefault("splitting.align_parts", false)
+	v.SetDefault("splitting.pad_parts", false)
+	v.SetDefault("splitting.checksum", "crc32")
+
+	// Archive defaults - no archive unless a format is set
+	v.SetDefault("archive.format", "")
+	v.SetDefault("archive.password", "")
+	v.SetDefault("archive.volume_size", "")
+
+	// Feature defaults - older spelling of par2.enabled and sfv.enabled
+	v.SetDefault("features.create_par2", false)
+	v.SetDefault("features.create_sfv", false)
 
 	// Par2 defaults
 	v.SetDefault("par2.redundancy", 10)
+	v.SetDefault("par2.block_size", 0) // 0 picks the block size from the data size
 	v.SetDefault("par2.enabled", true)
 
 	// SFV defaults
 	v.SetDefault("sfv.enabled", true)
 
+	// Obfuscation defaults - the individual options apply once enabled
+	v.SetDefault("obfuscation.enabled", false)
+	v.SetDefault("obfuscation.random_subjects", true)
+	v.SetDefault("obfuscation.random_poster", true)
+	v.SetDefault("obfuscation.scramble_filenames", true)
+	v.SetDefault("obfuscation.name_length", 16)
+	v.SetDefault("obfuscation.message_id_domain", "")
+
+	// Retention defaults - NZBs outlive the rest of their output folders
+	v.SetDefault("retention.outputs", "30d")
+	v.SetDefault("retention.nzbs", "")
+	v.SetDefault("retention.logs", "30d")
+	v.SetDefault("retention.temp", "1d")
+
+	// Schedule defaults - no window, uploads run at any time
+	v.SetDefault("schedule.window_start", "")
+	v.SetDefault("schedule.window_end", "")
+
+	// Security defaults - passwords are stored as written unless encryption
+	// is enabled; the key file defaults to ~/.ypost/secret.key
+	v.SetDefault("security.encrypt_passwords", false)
+	v.SetDefault("security.key_file", "")
+	v.SetDefault("security.key_cmd", "")
+
+	// Queue defaults - the job database defaults to ~/.ypost/queue.db
+	v.SetDefault("queue.path", "")
+
+	// Status defaults - no HTTP status endpoint
+	v.SetDefault("status.address", "")
+
+	// gRPC defaults - no API
+	v.SetDefault("grpc.address", "")
+	v.SetDefault("grpc.token", "")
+
+	// Indexer defaults - no NZB is pushed until indexer.url is set
+	v.SetDefault("indexer.url", "")
+	v.SetDefault("indexer.api_key", "")
+	v.SetDefault("indexer.mode", models.IndexerModeAPI)
+	v.SetDefault("indexer.default_category", "")
+	v.SetDefault("indexer.attempts", 3)
+
+	// Round trip defaults - posts are not downloaded back
+	v.SetDefault("roundtrip.client", "")
+	v.SetDefault("roundtrip.url", "")
+	v.SetDefault("roundtrip.api_key", "")
+	v.SetDefault("roundtrip.username", "")
+	v.SetDefault("roundtrip.password", "")
+	v.SetDefault("roundtrip.category", "")
+	v.SetDefault("roundtrip.delay", "")
+	v.SetDefault("roundtrip.timeout", "2h")
+	v.SetDefault("roundtrip.keep", false)
+
+	// Hook defaults - no command is run around posts
+	v.SetDefault("hooks.pre_post", "")
+	v.SetDefault("hooks.post_success", "")
+	v.SetDefault("hooks.post_failure", "")
+	v.SetDefault("hooks.timeout", "10m")
+
+	// NFO defaults - none posted unless asked for
+	v.SetDefault("nfo.generate", false)
+	v.SetDefault("nfo.template", "")
+	v.SetDefault("nfo.file", "")
+
+	// Performance defaults - connections added while they help
+	v.SetDefault("performance.adaptive_connections", true)
+	v.SetDefault("performance.max_memory", "")
+	v.SetDefault("performance.readahead", 0)
+
+	// History defaults - every post is recorded in ~/.ypost/history.db
+	v.SetDefault("history.enabled", true)
+	v.SetDefault("history.path", "")
+	v.SetDefault("history.duplicates", models.DuplicatesAbort)
+	v.SetDefault("history.duplicate_window", "30d")
+
 	// Logging defaults
 	v.SetDefault("logging.level", "info")
-	v.SetDefault("logging.file", "ypost.log")
+	v.SetDefault("logging.file", "")
+	v.SetDefault("logging.console", true)
+	v.SetDefault("logging.backend", "file")
+
+	// Notification defaults
+	v.SetDefault("notifications.email.enabled", false)
+	v.SetDefault("notifications.email.host", "")
+	v.SetDefault("notifications.email.port", 587)
+	v.SetDefault("notifications.email.security", "starttls")
+	v.SetDefault("notifications.email.username", "")
+	v.SetDefault("notifications.email.password", "")
+	v.SetDefault("notifications.email.password_cmd", "")
+	v.SetDefault("notifications.email.from", "")
+	v.SetDefault("notifications.email.to", []string{})
+	v.SetDefault("notifications.email.on", "all")
+	v.SetDefault("notifications.email.subject", notify.DefaultSubject)
+	v.SetDefault("notifications.email.attach_nzb", true)
 }
 
 // validateConfig validates the configuration
@@ -151,6 +378,24 @@ func validateConfig(config *models.Config) error {
 		if server.MaxConns <= 0 || server.MaxConns > 50 {
 			server.MaxConns = 4 // Default
 		}
+		if server.Priority < 0 {
+			return fmt.Errorf("server %d: invalid priority %d", i+1, server.Priority)
+		}
+		switch server.Role {
+		case "", models.RolePost, models.RoleRead, models.RoleBoth:
+		default:
+			return fmt.Errorf("server %d: invalid role %q, expected post, read or both", i+1, server.Role)
+		}
+	}
+
+	hasPrimary := false
+	for _, server := range config.NNTP.Servers {
+		if !server.Backup && server.Posts() {
+			hasPrimary = true
+		}
+	}
+	if !hasPrimary {
+		return fmt.Errorf("at least one NNTP server must post and not be a backup server")
 	}
 
 	if config.Posting.Group == "" {
@@ -161,17 +406,231 @@ func validateConfig(config *models.Config) error {
 		return fmt.Errorf("max part size must be positive")
 	}
 
-	if config.Posting.MaxArticleSize <= 0 {
-		return fmt.Errorf("max article size must be positive")
+	if err := ValidateArticleSize(config.Posting.MaxArticleSize); err != nil {
+		return err
+	}
+
+	if err := ValidateLineLength(config.Posting.MaxLineLength); err != nil {
+		return err
+	}
+
+	if text := config.Posting.SubjectTemplate; text != "" {
+		if _, err := template.New("subject").Parse(text); err != nil {
+			return fmt.Errorf("invalid subject template: %w", err)
+		}
+	}
+
+	switch config.Posting.SubjectQuote {
+	case "", models.SubjectQuoteDouble, models.SubjectQuoteSingle, models.SubjectQuoteNone:
+	default:
+		return fmt.Errorf("invalid subject quote %q (expected double, single or none)", config.Posting.SubjectQuote)
+	}
+	switch config.Posting.SubjectBrackets {
+	case "", models.SubjectBracketsSquare, models.SubjectBracketsRound:
+	default:
+		return fmt.Errorf("invalid subject brackets %q (expected square or round)", config.Posting.SubjectBrackets)
+	}
+	if err := nntp.ValidateHeader("Subject", config.Posting.SubjectComment); err != nil {
+		return fmt.Errorf("posting subject comment: %w", err)
+	}
+
+	// The poster addresses go into the From header, RFC 5322 addresses
+	for _, address := range []struct {
+		key   string
+		value string
+	}{
+		{"posting.poster_email", config.Posting.PosterEmail},
+		{"posting.from", config.Posting.From},
+	} {
+		if address.value == "" {
+			continue
+		}
+		if _, err := mail.ParseAddress(address.value); err != nil {
+			return fmt.Errorf("%s: invalid address %q: %w", address.key, address.value, err)
+		}
+	}
+
+	// Headers go into every article as they are, one line each; each
+	// article has its own Message-ID
+	for name, value := range ArticleHeaders(config) {
+		if err := nntp.ValidateHeader(name, value); err != nil {
+			return fmt.Errorf("posting headers: %w", err)
+		}
+		if strings.EqualFold(name, "Message-ID") {
+			return fmt.Errorf("posting headers: Message-ID is set for each article and cannot be a custom header")
+		}
+	}
+
+	if size := config.Par2.BlockSize; size < 0 || size%4 != 0 {
+		return fmt.Errorf("par2 block size must be a multiple of 4, got %d", size)
 	}
 
-	if config.Posting.MaxLineLength <= 0 {
-		return fmt.Errorf("max line length must be positive")
+	if length := config.Obfuscation.NameLength; length != 0 && (length < 8 || length > 64) {
+		return fmt.Errorf("obfuscation name length must be between 8 and 64, got %d", length)
+	}
+	if domain := config.Obfuscation.MessageIDDomain; domain != "" && !messageIDDomain.MatchString(domain) {
+		return fmt.Errorf("invalid obfuscation message-id domain %q", domain)
 	}
 
+	for _, age := range []struct {
+		key   string
+		value string
+	}{
+		{"retention.outputs", config.Retention.Outputs},
+		{"retention.nzbs", config.Retention.NZBs},
+		{"retention.logs", config.Retention.Logs},
+		{"retention.temp", config.Retention.Temp},
+		{"history.duplicate_window", config.History.DuplicateWindow},
+	} {
+		if _, err := prune.ParseAge(age.value); err != nil {
+			return fmt.Errorf("%s: %w", age.key, err)
+		}
+	}
+
+	switch config.History.Duplicates {
+	case "", models.DuplicatesAbort, models.DuplicatesWarn, models.DuplicatesOff:
+	default:
+		return fmt.Errorf("invalid history duplicates policy %q (expected abort, warn or off)", config.History.Duplicates)
+	}
+
+	if _, err := schedule.ParseWindow(config.Schedule.WindowStart, config.Schedule.WindowEnd); err != nil {
+		return err
+	}
+
+	if err := validateGroups(config); err != nil {
+		return err
+	}
+
+	if size := config.Performance.MaxMemory; size != "" {
+		if _, err := utils.ParseFileSize(size); err != nil {
+			return fmt.Errorf("invalid max_memory %q: %w", size, err)
+		}
+	}
+	if config.Performance.Readahead < 0 {
+		return fmt.Errorf("readahead must not be negative, got %d", config.Performance.Readahead)
+	}
+
+	if address := config.Status.Address; address != "" {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return fmt.Errorf("invalid status address %q: %w", address, err)
+		}
+	}
+	if address := config.GRPC.Address; address != "" {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return fmt.Errorf("invalid grpc address %q: %w", address, err)
+		}
+	}
+
+	if _, err := logger.ParseLevel(config.Logging.Level); err != nil {
+		return err
+	}
+	if _, err := logger.ParseLevels(config.Logging.Levels); err != nil {
+		return err
+	}
+	if _, err := logger.ParseBackend(config.Logging.Backend); err != nil {
+		return err
+	}
+
+	if _, err := notify.New(config); err != nil {
+		return err
+	}
+	if config.Indexer.URL != "" {
+		if _, err := indexer.New(config.Indexer); err != nil {
+			return err
+		}
+	}
+	if config.RoundTrip.Client != "" {
+		if _, err := roundtrip.New(config.RoundTrip); err != nil {
+			return err
+		}
+	}
+	if _, err := hook.New(config.Hooks); err != nil {
+		return err
+	}
+	if _, err := nfo.New(config.NFO); err != nil {
+		return err
+	}
+
+	if config.Posting.Retries < 0 {
+		return fmt.Errorf("posting retries must not be negative, got %d", config.Posting.Retries)
+	}
+	switch config.Posting.OnError {
+	case "", models.OnErrorSkip, models.OnErrorAbort, models.OnErrorRetry:
+	default:
+		return fmt.Errorf("invalid posting on_error policy %q (expected abort, skip or retry)", config.Posting.OnError)
+	}
+
+	if _, err := verify.ParseSample(config.Posting.Verify); err != nil {
+		return fmt.Errorf("posting verify: %w", err)
+	}
+
+	switch config.NZB.MappingMode {
+	case "", "none", "meta", "sidecar":
+	default:
+		return fmt.Errorf("invalid nzb mapping mode %q (expected none, meta or sidecar)", config.NZB.MappingMode)
+	}
+
+	switch config.NZB.PAR2 {
+	case "", "all", "index", "none":
+	default:
+		return fmt.Errorf("invalid nzb par2 mode %q (expected all, index or none)", config.NZB.PAR2)
+	}
+
+	return nil
+}
+
+// Article sizes are the bytes of data of an article before yEnc encoding.
+// Servers commonly refuse articles much above a megabyte, and small articles
+// only add overhead.
+const (
+	DefaultArticleSize = 716800 // 700 KiB
+	MinArticleSize     = 4096
+	MaxArticleSize     = 4 << 20
+)
+
+// Line lengths are the encoded bytes of a yEnc line, which must stay within
+// the 998 characters of an RFC 5322 line
+const (
+	MinLineLength = 32
+	MaxLineLength = 997
+)
+
+// ValidateArticleSize checks the size of the data of an article
+func ValidateArticleSize(size int64) error {
+	if size < MinArticleSize || size > MaxArticleSize {
+		return fmt.Errorf("max article size must be between %d and %d bytes, got %d", MinArticleSize, MaxArticleSize, size)
+	}
+	return nil
+}
+
+// ValidateLineLength checks the length of the yEnc lines of an article
+func ValidateLineLength(length int) error {
+	if length < MinLineLength || length > MaxLineLength {
+		return fmt.Errorf("max line length must be between %d and %d, got %d", MinLineLength, MaxLineLength, length)
+	}
 	return nil
 }
 
+// ArticleHeaders returns the headers added to every article: the standard
+// optional headers set, then the custom headers, which take precedence. The
+// configuration file keeps no case in names, they are given the usual one.
+func ArticleHeaders(config *models.Config) map[string]string {
+	headers := make(map[string]string, len(config.Posting.CustomHeaders)+3)
+	if config.Posting.XNoArchive {
+		headers["X-No-Archive"] = "yes"
+	}
+	if config.Posting.Organization != "" {
+		headers["Organization"] = config.Posting.Organization
+	}
+	if config.Posting.XNewsposter != "" {
+		headers["X-Newsposter"] = config.Posting.XNewsposter
+	}
+	for name, value := range config.Posting.CustomHeaders {
+		headers[textproto.CanonicalMIMEHeaderKey(name)] = value
+	}
+	return headers
+}
+
 // SaveConfig saves configuration to file
 func SaveConfig(config *models.Config, configPath string) error {
 	if configPath == "" {
@@ -184,23 +643,29 @@ func SaveConfig(config *models.Config, configPath string) error {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
+	// Marshal in the format of the file. Server passwords are written as
+	// they appeared in the configuration, never resolved, and encrypted
+	// when security.encrypt_passwords is set.
+	saved := *config
+	saved.NNTP.Servers = unresolvedServers(config.NNTP.Servers)
+	if err := protectPasswords(&saved, config); err != nil {
+		return err
+	}
+
 	file, err := os.Create(configPath)
 	if err != nil {
 		return fmt.Errorf("failed to create config file: %w", err)
 	}
 	defer file.Close()
 
-	// Use viper to marshal and save
-	v := viper.New()
-	v.Set("nntp", config.NNTP)
-	v.Set("posting", config.Posting)
-	v.Set("output", config.Output)
-	v.Set("splitting", config.Splitting)
-	v.Set("par2", config.Par2)
-	v.Set("sfv", config.SFV)
-	v.Set("logging", config.Logging)
-
-	return v.WriteConfigAs(configPath)
+	data, err := encodeSettings(settingsMap(reflect.ValueOf(saved)), formatFor(configPath))
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
 }
 
 // CreateSampleConfig creates a sample configuration file
@@ -221,16 +686,25 @@ func CreateSampleConfig(configPath string) error {
 	// Posting configuration
 	sampleConfig.Posting.Group = "alt.binaries.test"
 	sampleConfig.Posting.PosterEmail = "poster@example.com"
-	sampleConfig.Posting.SubjectTemplate = "[{{.Index}}/{{.Total}}] - {{.Filename}} - ({{.Size}})"
+	sampleConfig.Posting.SubjectQuote = models.SubjectQuoteDouble
+	sampleConfig.Posting.SubjectBrackets = models.SubjectBracketsSquare
 	sampleConfig.Posting.MaxLineLength = 128
 	sampleConfig.Posting.MaxPartSize = 750000
-	sampleConfig.Posting.MaxArticleSize = 500000
+	sampleConfig.Posting.MaxArticleSize = DefaultArticleSize
 
 	// Output configuration
 	sampleConfig.Output.OutputDir = "output"
 	sampleConfig.Output.NZBDir = "output/nzb"
 	sampleConfig.Output.LogDir = "output/logs"
 
+	// NZB configuration
+	sampleConfig.NZB.TitleTemplate = "{{.Filename}}"
+	sampleConfig.NZB.Category = "misc"
+	sampleConfig.NZB.MappingMode = "sidecar"
+	sampleConfig.NZB.PAR2 = "all"
+	sampleConfig.NZB.IncludeSFV = true
+	sampleConfig.NZB.IncludeNFO = true
+
 	// Splitting configuration
 	sampleConfig.Splitting.MaxFileSize = "50MB"
 
@@ -241,26 +715,39 @@ func CreateSampleConfig(configPath string) error {
 	// SFV configuration
 	sampleConfig.SFV.Enabled = true
 
+	// Obfuscation configuration
+	sampleConfig.Obfuscation.RandomSubjects = true
+	sampleConfig.Obfuscation.RandomPoster = true
+	sampleConfig.Obfuscation.ScrambleFilenames = true
+	sampleConfig.Obfuscation.NameLength = 16
+
 	// Logging configuration
 	sampleConfig.Logging.Level = "info"
 	sampleConfig.Logging.File = "ypost.log"
+	sampleConfig.Logging.Console = true
 
 	return SaveConfig(sampleConfig, configPath)
 }
 
 // GetConfigPath returns the default config path
 func GetConfigPath() string {
+	names := []string{"config.yaml", "config.toml", "config.json"}
+
 	// Check for config in current directory
-	if _, err := os.Stat("config.yaml"); err == nil {
-		return "config.yaml"
+	for _, name := range names {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
 	}
 
 	// Check for config in home directory
 	homeDir, err := os.UserHomeDir()
 	if err == nil {
-		homeConfig := filepath.Join(homeDir, ".ypost", "config.yaml")
-		if _, err := os.Stat(homeConfig); err == nil {
-			return homeConfig
+		for _, name := range names {
+			homeConfig := filepath.Join(homeDir, ".ypost", name)
+			if _, err := os.Stat(homeConfig); err == nil {
+				return homeConfig
+			}
 		}
 	}
 
@@ -0,0 +1,552 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+	"ypost/pkg/models"
+)
+
+// validTestConfig returns the defaults with a usable server
+func validTestConfig(t *testing.T) *models.Config {
+	t.Helper()
+	config, err := DefaultConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.NNTP.Servers = []models.ServerConfig{{Host: "news.example.com", Port: 563, MaxConns: 4}}
+	return config
+}
+
+func TestValidateObfuscation(t *testing.T) {
+	tests := []struct {
+		nameLength int
+		domain     string
+		wantErr    bool
+	}{
+		{16, "", false},
+		{0, "", false},
+		{16, "example.com", false},
+		{16, "news-1.example.org", false},
+		{4, "", true},
+		{65, "", true},
+		{16, "user@example.com", true},
+		{16, "exa mple.com", true},
+		{16, "-example.com", true},
+	}
+
+	for _, test := range tests {
+		config := validTestConfig(t)
+		config.Obfuscation.Enabled = true
+		config.Obfuscation.NameLength = test.nameLength
+		config.Obfuscation.MessageIDDomain = test.domain
+
+		err := validateConfig(config)
+		if (err != nil) != test.wantErr {
+			t.Errorf("name length %d, domain %q: unexpected error %v", test.nameLength, test.domain, err)
+		}
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	tests := []struct {
+		start, end string
+		wantErr    bool
+	}{
+		{"", "", false},
+		{"01:00", "07:00", false},
+		{"22:00", "06:00", false},
+		{"01:00", "", true},
+		{"", "07:00", true},
+		{"1am", "07:00", true},
+		{"07:00", "07:00", true},
+	}
+
+	for _, test := range tests {
+		config := validTestConfig(t)
+		config.Schedule.WindowStart = test.start
+		config.Schedule.WindowEnd = test.end
+
+		err := validateConfig(config)
+		if (err != nil) != test.wantErr {
+			t.Errorf("window %q-%q: unexpected error %v", test.start, test.end, err)
+		}
+	}
+}
+
+func TestValidateSubjectTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		wantErr  bool
+	}{
+		{"", false},
+		{"{{.Filename}} yEnc ({{.ChunkIndex}}/{{.TotalChunks}})", false},
+		{"{{.Filename", true},
+		{"{{if .Index}}{{.Filename}}", true},
+	}
+
+	for _, test := range tests {
+		config := validTestConfig(t)
+		config.Posting.SubjectTemplate = test.template
+
+		err := validateConfig(config)
+		if (err != nil) != test.wantErr {
+			t.Errorf("template %q: unexpected error %v", test.template, err)
+		}
+	}
+}
+
+func TestValidateWebhooks(t *testing.T) {
+	tests := []struct {
+		hook    models.WebhookConfig
+		wantErr bool
+	}{
+		{models.WebhookConfig{URL: "https://hooks.example.com/ypost"}, false},
+		{models.WebhookConfig{URL: "http://localhost:8123/api/webhook/x", On: "failure", Template: `{"file": {{json .File}}}`}, false},
+		{models.WebhookConfig{URL: "hooks.example.com"}, true},
+		{models.WebhookConfig{URL: "https://hooks.example.com", On: "sometimes"}, true},
+		{models.WebhookConfig{URL: "https://hooks.example.com", Template: "{{.File"}, true},
+		{models.WebhookConfig{URL: "https://hooks.example.com", Attempts: -1}, true},
+	}
+
+	for _, test := range tests {
+		config := validTestConfig(t)
+		config.Notifications.Webhooks = []models.WebhookConfig{test.hook}
+
+		err := validateConfig(config)
+		if (err != nil) != test.wantErr {
+			t.Errorf("webhook %+v: unexpected error %v", test.hook, err)
+		}
+	}
+}
+
+// TestDefaultsMatchConfig catches drift between setDefaults and models.Config:
+// a default without a field is dropped on unmarshal, and a field without a
+// default cannot be overridden from the environment
+func TestDefaultsMatchConfig(t *testing.T) {
+	v := viper.New()
+	setDefaults(v)
+
+	var config models.Config
+	err := v.Unmarshal(&config, func(dc *mapstructure.DecoderConfig) {
+		dc.ErrorUnused = true
+	})
+	if err != nil {
+		t.Fatalf("defaults do not decode strictly: %v", err)
+	}
+
+	for key := range flattenSettings(&models.Config{}) {
+		if !v.IsSet(key) {
+			t.Errorf("no default for %s", key)
+		}
+	}
+}
+
+func TestSampleConfigDecodesStrictly(t *testing.T) {
+	for _, name := range []string{"config.yaml", "config.toml", "config.json"} {
+		configPath := filepath.Join(t.TempDir(), name)
+		if err := CreateSampleConfig(configPath); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		v, err := readConfig(configPath)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if unknown := unknownKeys(v); len(unknown) > 0 {
+			t.Errorf("%s: unknown keys %v", name, unknown)
+		}
+
+		var config models.Config
+		err = v.Unmarshal(&config, func(dc *mapstructure.DecoderConfig) {
+			dc.ErrorUnused = true
+		})
+		if err != nil {
+			t.Fatalf("%s: sample does not decode strictly: %v", name, err)
+		}
+		if config.Splitting.MaxFileSize != "50MB" || !config.Par2.Enabled || config.Par2.Redundancy != 10 ||
+			!config.SFV.Enabled || config.Logging.File != "ypost.log" {
+			t.Errorf("%s: sections not read back: %+v %+v %+v %+v", name, config.Splitting, config.Par2, config.SFV, config.Logging)
+		}
+	}
+}
+
+func TestLegacyFeatureKeys(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	data := `nntp:
+  server: news.example.com
+par2:
+  enabled: false
+sfv:
+  enabled: false
+features:
+  create_par2: true
+`
+	if err := os.WriteFile(configPath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, _, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.Par2.Enabled {
+		t.Error("features.create_par2 did not enable PAR2")
+	}
+	if config.SFV.Enabled {
+		t.Error("sfv.enabled false was overridden")
+	}
+	if len(config.NNTP.Servers) != 1 || config.NNTP.Servers[0].Host != "news.example.com" {
+		t.Errorf("legacy server not converted: %+v", config.NNTP.Servers)
+	}
+}
+
+func TestLoggingLevels(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	data := `nntp:
+  server: news.example.com
+logging:
+  levels:
+    par2: debug
+`
+	if err := os.WriteFile(configPath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, _, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Logging.Levels) != 1 || config.Logging.Levels["par2"] != "debug" {
+		t.Errorf("module levels not read: %v", config.Logging.Levels)
+	}
+
+	t.Setenv("USENET_LOGGING_LEVELS", "par2=warn,progress=error")
+	config, _, err = LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Logging.Levels["par2"] != "warn" || config.Logging.Levels["progress"] != "error" {
+		t.Errorf("module levels not read from the environment: %v", config.Logging.Levels)
+	}
+
+	t.Setenv("USENET_LOGGING_LEVELS", "nntp=debug")
+	if _, _, err := LoadConfig(configPath); err == nil {
+		t.Error("expected an unknown log module to fail")
+	}
+}
+
+func TestValidatePosterAddress(t *testing.T) {
+	tests := []struct {
+		email   string
+		from    string
+		wantErr bool
+	}{
+		{"poster@example.com", "", false},
+		{"", "Poster <poster@example.com>", false},
+		{"poster", "", true},
+		{"poster@example.com", "poster at example.com", true},
+		{"<poster@example.com", "", true},
+	}
+
+	for _, test := range tests {
+		config := validTestConfig(t)
+		config.Posting.PosterEmail = test.email
+		config.Posting.From = test.from
+
+		err := validateConfig(config)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q, %q: unexpected error %v", test.email, test.from, err)
+		}
+	}
+}
+
+func TestValidateServerRoles(t *testing.T) {
+	tests := []struct {
+		roles   []string
+		wantErr bool
+	}{
+		{[]string{""}, false},
+		{[]string{"both"}, false},
+		{[]string{"post", "read"}, false},
+		{[]string{"read"}, true},
+		{[]string{"write"}, true},
+	}
+
+	for _, test := range tests {
+		config := validTestConfig(t)
+		server := config.NNTP.Servers[0]
+		config.NNTP.Servers = nil
+		for _, role := range test.roles {
+			server.Role = role
+			config.NNTP.Servers = append(config.NNTP.Servers, server)
+		}
+
+		err := validateConfig(config)
+		if (err != nil) != test.wantErr {
+			t.Errorf("roles %v: unexpected error %v", test.roles, err)
+		}
+	}
+}
+
+func TestValidateErrorPolicy(t *testing.T) {
+	tests := []struct {
+		onError string
+		retries int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"skip", 1, false},
+		{"abort", 0, false},
+		{"retry", 3, false},
+		{"ignore", 1, true},
+		{"skip", -1, true},
+	}
+
+	for _, test := range tests {
+		config := validTestConfig(t)
+		config.Posting.OnError = test.onError
+		config.Posting.Retries = test.retries
+
+		err := validateConfig(config)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q, %d: unexpected error %v", test.onError, test.retries, err)
+		}
+	}
+}
+
+func TestValidateVerify(t *testing.T) {
+	for _, mode := range []string{"", "none", "all", "sample:5%", "sample:20"} {
+		config := validTestConfig(t)
+		config.Posting.Verify = mode
+		if err := validateConfig(config); err != nil {
+			t.Errorf("%q: unexpected error %v", mode, err)
+		}
+	}
+	for _, mode := range []string{"some", "sample:0%", "sample:-3"} {
+		config := validTestConfig(t)
+		config.Posting.Verify = mode
+		if err := validateConfig(config); err == nil {
+			t.Errorf("%q: expected an error", mode)
+		}
+	}
+}
+
+func TestValidateArticleSize(t *testing.T) {
+	if config := validTestConfig(t); config.Posting.MaxArticleSize != DefaultArticleSize {
+		t.Errorf("expected the default article size, got %d", config.Posting.MaxArticleSize)
+	}
+
+	tests := []struct {
+		articleSize int64
+		lineLength  int
+		wantErr     bool
+	}{
+		{DefaultArticleSize, 128, false},
+		{MinArticleSize, MinLineLength, false},
+		{MaxArticleSize, MaxLineLength, false},
+		{0, 128, true},
+		{100, 128, true},
+		{MaxArticleSize + 1, 128, true},
+		{DefaultArticleSize, 0, true},
+		{DefaultArticleSize, 1000, true},
+	}
+
+	for _, test := range tests {
+		config := validTestConfig(t)
+		config.Posting.MaxArticleSize = test.articleSize
+		config.Posting.MaxLineLength = test.lineLength
+
+		err := validateConfig(config)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%d, %d: unexpected error %v", test.articleSize, test.lineLength, err)
+		}
+	}
+
+	// Group presets are held to the same sizes
+	config := validTestConfig(t)
+	config.Groups = map[string]models.GroupPreset{"alt.binaries.test": {ArticleSize: 100}}
+	if err := validateConfig(config); err == nil {
+		t.Error("expected an error for a preset article size of 100 bytes")
+	}
+}
+
+func TestArticleHeaders(t *testing.T) {
+	config := validTestConfig(t)
+	if headers := ArticleHeaders(config); len(headers) != 0 {
+		t.Errorf("expected no headers by default, got %v", headers)
+	}
+
+	config.Posting.XNoArchive = true
+	config.Posting.Organization = "Example"
+	config.Posting.XNewsposter = "ypost"
+	config.Posting.CustomHeaders = map[string]string{"organization": "Custom", "x-test": "yes"}
+	headers := ArticleHeaders(config)
+	want := map[string]string{"X-No-Archive": "yes", "Organization": "Custom", "X-Newsposter": "ypost", "X-Test": "yes"}
+	if len(headers) != len(want) {
+		t.Fatalf("got headers %v, want %v", headers, want)
+	}
+	for name, value := range want {
+		if headers[name] != value {
+			t.Errorf("%s: got %q, want %q", name, headers[name], value)
+		}
+	}
+}
+
+func TestValidateHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*models.Config)
+		wantErr bool
+	}{
+		{"custom header", func(c *models.Config) { c.Posting.CustomHeaders = map[string]string{"X-Test": "yes"} }, false},
+		{"organization", func(c *models.Config) { c.Posting.Organization = "Example" }, false},
+		{"injected custom header", func(c *models.Config) { c.Posting.CustomHeaders = map[string]string{"X-Test": "yes\r\nBcc: someone"} }, true},
+		{"invalid header name", func(c *models.Config) { c.Posting.CustomHeaders = map[string]string{"X Test": "yes"} }, true},
+		{"injected organization", func(c *models.Config) { c.Posting.Organization = "Example\nX-Other: injected" }, true},
+		{"injected newsposter", func(c *models.Config) { c.Posting.XNewsposter = "ypost\r" }, true},
+		{"custom message id", func(c *models.Config) { c.Posting.CustomHeaders = map[string]string{"message-id": "<id@test>"} }, true},
+	}
+
+	for _, test := range tests {
+		config := validTestConfig(t)
+		test.modify(config)
+		if err := validateConfig(config); (err != nil) != test.wantErr {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+}
+
+func TestValidateSubjectStyle(t *testing.T) {
+	config := validTestConfig(t)
+	if config.Posting.SubjectTemplate != "" || config.Posting.SubjectQuote != models.SubjectQuoteDouble || config.Posting.SubjectBrackets != models.SubjectBracketsSquare {
+		t.Errorf("unexpected default subject style %q, %q, %q", config.Posting.SubjectTemplate, config.Posting.SubjectQuote, config.Posting.SubjectBrackets)
+	}
+
+	tests := []struct {
+		quote    string
+		brackets string
+		comment  string
+		wantErr  bool
+	}{
+		{models.SubjectQuoteDouble, models.SubjectBracketsSquare, "", false},
+		{models.SubjectQuoteSingle, models.SubjectBracketsRound, "My upload", false},
+		{models.SubjectQuoteNone, "", "", false},
+		{"backtick", models.SubjectBracketsSquare, "", true},
+		{models.SubjectQuoteDouble, "curly", "", true},
+		{models.SubjectQuoteDouble, models.SubjectBracketsSquare, "comment\r\nX-Injected: yes", true},
+	}
+
+	for _, test := range tests {
+		config := validTestConfig(t)
+		config.Posting.SubjectQuote = test.quote
+		config.Posting.SubjectBrackets = test.brackets
+		config.Posting.SubjectComment = test.comment
+		if err := validateConfig(config); (err != nil) != test.wantErr {
+			t.Errorf("%q, %q, %q: unexpected error %v", test.quote, test.brackets, test.comment, err)
+		}
+	}
+}
+
+func TestValidateDuplicates(t *testing.T) {
+	config := validTestConfig(t)
+	if config.History.Duplicates != models.DuplicatesAbort || config.History.DuplicateWindow != "30d" {
+		t.Errorf("unexpected default duplicates policy %q within %q", config.History.Duplicates, config.History.DuplicateWindow)
+	}
+
+	tests := []struct {
+		policy  string
+		window  string
+		wantErr bool
+	}{
+		{models.DuplicatesAbort, "30d", false},
+		{models.DuplicatesWarn, "12h", false},
+		{models.DuplicatesOff, "", false},
+		{"ask", "30d", true},
+		{models.DuplicatesAbort, "a month", true},
+	}
+
+	for _, test := range tests {
+		config := validTestConfig(t)
+		config.History.Duplicates = test.policy
+		config.History.DuplicateWindow = test.window
+		if err := validateConfig(config); (err != nil) != test.wantErr {
+			t.Errorf("%q within %q: unexpected error %v", test.policy, test.window, err)
+		}
+	}
+}
+
+func TestValidateIndexer(t *testing.T) {
+	tests := []struct {
+		indexer models.IndexerConfig
+		wantErr bool
+	}{
+		{models.IndexerConfig{}, false},
+		{models.IndexerConfig{URL: "https://indexer.example.com", APIKey: "${INDEXER_KEY}"}, false},
+		{models.IndexerConfig{
+			URL:        "https://indexer.example.com/upload",
+			Mode:       models.IndexerModeUpload,
+			Categories: []models.IndexerCategory{{Match: "alt.binaries.movies*", Category: "2000"}},
+		}, false},
+		{models.IndexerConfig{URL: "indexer.example.com"}, true},
+		{models.IndexerConfig{URL: "https://indexer.example.com", Mode: "ftp"}, true},
+		{models.IndexerConfig{URL: "https://indexer.example.com", Categories: []models.IndexerCategory{{Match: "tv"}}}, true},
+	}
+
+	for _, test := range tests {
+		config := validTestConfig(t)
+		config.Indexer = test.indexer
+		if err := validateConfig(config); (err != nil) != test.wantErr {
+			t.Errorf("indexer %+v: unexpected error %v", test.indexer, err)
+		}
+	}
+}
+
+func TestValidateRoundTrip(t *testing.T) {
+	tests := []struct {
+		roundTrip models.RoundTripConfig
+		wantErr   bool
+	}{
+		{models.RoundTripConfig{}, false},
+		{models.RoundTripConfig{Client: models.ClientSABnzbd, URL: "http://localhost:8080", APIKey: "${SABNZBD_KEY}"}, false},
+		{models.RoundTripConfig{Client: models.ClientNZBGet, URL: "http://localhost:6789", Delay: "5m", Timeout: "2h"}, false},
+		{models.RoundTripConfig{Client: models.ClientSABnzbd, URL: "http://localhost:8080"}, true},
+		{models.RoundTripConfig{Client: "deluge", URL: "http://localhost:8112"}, true},
+		{models.RoundTripConfig{Client: models.ClientNZBGet, URL: "http://localhost:6789", Timeout: "later"}, true},
+	}
+
+	for _, test := range tests {
+		config := validTestConfig(t)
+		config.RoundTrip = test.roundTrip
+		if err := validateConfig(config); (err != nil) != test.wantErr {
+			t.Errorf("roundtrip %+v: unexpected error %v", test.roundTrip, err)
+		}
+	}
+}
+
+func TestValidateHooks(t *testing.T) {
+	config := validTestConfig(t)
+	config.Hooks = models.HooksConfig{PostSuccess: "./archive.sh", Timeout: "30s"}
+	if err := validateConfig(config); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	config.Hooks.Timeout = "whenever"
+	if err := validateConfig(config); err == nil {
+		t.Error("expected an invalid timeout to be refused")
+	}
+}
+
+func TestValidateNFO(t *testing.T) {
+	config := validTestConfig(t)
+	config.NFO = models.NFOConfig{Generate: true}
+	if err := validateConfig(config); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	config.NFO.Template = filepath.Join(t.TempDir(), "missing.tmpl")
+	if err := validateConfig(config); err == nil {
+		t.Error("expected a missing template to be refused")
+	}
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ypost/pkg/models"
+)
+
+// encryptedPrefix marks a password encrypted with the configuration key
+const encryptedPrefix = "enc:"
+
+// isEncrypted reports whether a password value is encrypted
+func isEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// protectPasswords prepares the passwords of a configuration about to be
+// saved: with security.encrypt_passwords set, plain passwords are encrypted;
+// without it, encrypted passwords are written back in plain text. ${VAR}
+// references and password_cmd servers are left alone. resolved is the loaded
+// configuration saved was copied from.
+func protectPasswords(saved *models.Config, resolved *models.Config) error {
+	var key []byte
+	protect := func(password *string, plain string) error {
+		switch {
+		case *password == "":
+		case !resolved.Security.EncryptPasswords && isEncrypted(*password):
+			*password = plain
+		case resolved.Security.EncryptPasswords && !isEncrypted(*password) && !envReference.MatchString(*password):
+			if key == nil {
+				var err error
+				if key, err = encryptionKey(resolved, true); err != nil {
+					return err
+				}
+			}
+			encrypted, err := encryptSecret(*password, key)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt password: %w", err)
+			}
+			*password = encrypted
+		}
+		return nil
+	}
+
+	for i := range saved.NNTP.Servers {
+		server := &saved.NNTP.Servers[i]
+		if server.PasswordCmd != "" {
+			continue
+		}
+		if err := protect(&server.Password, resolved.NNTP.Servers[i].Password); err != nil {
+			return fmt.Errorf("server %d: %w", i+1, err)
+		}
+	}
+
+	// The legacy single-server password is only decrypted through the server
+	// converted from it
+	if saved.NNTP.PasswordCmd == "" {
+		plain := saved.NNTP.Password
+		if isEncrypted(plain) && len(resolved.NNTP.Servers) > 0 && resolved.NNTP.Servers[0].PasswordRef == plain {
+			plain = resolved.NNTP.Servers[0].Password
+		}
+		if err := protect(&saved.NNTP.Password, plain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encryptionKey returns the AES-256 key derived from the output of
+// security.key_cmd (e.g. an OS keychain lookup) or the contents of the key
+// file. With create set, a missing key file is generated.
+func encryptionKey(config *models.Config, create bool) ([]byte, error) {
+	var material string
+	if config.Security.KeyCmd != "" {
+		secret, err := runSecretCommand("key_cmd", config.Security.KeyCmd)
+		if err != nil {
+			return nil, err
+		}
+		material = secret
+	} else {
+		keyFile, err := keyFilePath(config)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(keyFile)
+		if os.IsNotExist(err) && create {
+			data, err = createKeyFile(keyFile)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		material = strings.TrimSpace(string(data))
+		if material == "" {
+			return nil, fmt.Errorf("key file %s is empty", keyFile)
+		}
+	}
+
+	key := sha256.Sum256([]byte(material))
+	return key[:], nil
+}
+
+// keyFilePath returns security.key_file, or ~/.ypost/secret.key by default
+func keyFilePath(config *models.Config) (string, error) {
+	if config.Security.KeyFile != "" {
+		return config.Security.KeyFile, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the default key file: %w", err)
+	}
+	return filepath.Join(homeDir, ".ypost", "secret.key"), nil
+}
+
+// createKeyFile writes a new random key readable by the owner only
+func createKeyFile(keyFile string) ([]byte, error) {
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+		return nil, err
+	}
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	data := []byte(hex.EncodeToString(random) + "\n")
+	if err := os.WriteFile(keyFile, data, 0600); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// encryptSecret encrypts a value with AES-256-GCM, returning enc: followed by
+// the base64 of the nonce and ciphertext
+func encryptSecret(plain string, key []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptSecret reverses encryptSecret
+func decryptSecret(value string, key []byte) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted password: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted password")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt password: wrong key or corrupted value")
+	}
+	return string(plain), nil
+}
+
+// newGCM creates the AES-GCM cipher for a key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
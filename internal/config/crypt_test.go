@@ -0,0 +1,106 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ypost/pkg/models"
+)
+
+func TestEncryptedPasswords(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "keys", "secret.key")
+	configPath := filepath.Join(dir, "config.yaml")
+	t.Setenv("YPOST_TEST_PASS", "from-env")
+
+	config := validTestConfig(t)
+	config.Security.EncryptPasswords = true
+	config.Security.KeyFile = keyFile
+	config.NNTP.Servers = []models.ServerConfig{
+		{Host: "news.example.com", Port: 563, MaxConns: 4, Password: "s3cret"},
+		{Host: "backup.example.com", Port: 563, MaxConns: 4, Password: "${YPOST_TEST_PASS}", Backup: true},
+	}
+	if err := SaveConfig(config, configPath); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(keyFile)
+	if err != nil {
+		t.Fatalf("key file not created: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("key file mode %v, expected 0600", info.Mode().Perm())
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	if strings.Contains(content, "s3cret") || !strings.Contains(content, encryptedPrefix) {
+		t.Errorf("password not encrypted:\n%s", content)
+	}
+	if !strings.Contains(content, "${YPOST_TEST_PASS}") {
+		t.Errorf("secret reference not preserved:\n%s", content)
+	}
+
+	loaded, _, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.NNTP.Servers[0].Password != "s3cret" || loaded.NNTP.Servers[1].Password != "from-env" {
+		t.Fatalf("passwords not resolved: %+v", loaded.NNTP.Servers)
+	}
+
+	// Saving again keeps the stored ciphertext; disabling writes plain text
+	encrypted := loaded.NNTP.Servers[0].PasswordRef
+	if err := SaveConfig(loaded, configPath); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(configPath); !strings.Contains(string(data), encrypted) {
+		t.Error("encrypted password changed on save")
+	}
+	loaded.Security.EncryptPasswords = false
+	if err := SaveConfig(loaded, configPath); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(configPath); !strings.Contains(string(data), "s3cret") {
+		t.Errorf("password not decrypted:\n%s", data)
+	}
+}
+
+func TestDecryptWithWrongKey(t *testing.T) {
+	dir := t.TempDir()
+	config := validTestConfig(t)
+	config.Security.KeyFile = filepath.Join(dir, "secret.key")
+
+	key, err := encryptionKey(config, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := encryptSecret("s3cret", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.NNTP.Servers[0].Password = encrypted
+	if err := resolveSecrets(config); err != nil || config.NNTP.Servers[0].Password != "s3cret" {
+		t.Fatalf("decrypt failed: %v", err)
+	}
+
+	config.NNTP.Servers[0].Password = encrypted
+	config.NNTP.Servers[0].PasswordRef = ""
+	config.Security.KeyFile = ""
+	config.Security.KeyCmd = "echo another-key"
+	if err := resolveSecrets(config); err == nil {
+		t.Error("expected an error decrypting with the wrong key")
+	}
+
+	config.Security.KeyCmd = ""
+	config.Security.KeyFile = filepath.Join(dir, "missing.key")
+	if _, err := encryptionKey(config, false); err == nil {
+		t.Error("expected an error for a missing key file")
+	}
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+	"ypost/pkg/models"
+)
+
+// walkLists calls fn with the dotted key and item type of every list of
+// settings, such as nntp.servers, which walkKeys skips
+func walkLists(t reflect.Type, prefix string, fn func(string, reflect.Type)) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if key == "-" || !field.IsExported() {
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			walkLists(field.Type, key, fn)
+		case reflect.Slice:
+			if field.Type.Elem().Kind() == reflect.Struct {
+				fn(key, field.Type.Elem())
+			}
+		}
+	}
+}
+
+// applyListEnv sets the lists of settings from the environment, for
+// deployments configured without a file. A list is given whole as YAML or
+// JSON in the variable of its key (USENET_NNTP_SERVERS='[{"host": ...}]'),
+// and an item's setting in the variable of the key, the index of the item
+// and the setting (USENET_NNTP_SERVERS_0_HOST), over the file's or the
+// whole list's item, which it adds when missing.
+func applyListEnv(v *viper.Viper) error {
+	var err error
+	walkLists(reflect.TypeOf(models.Config{}), "", func(key string, item reflect.Type) {
+		if err == nil {
+			err = applyList(v, key, item)
+		}
+	})
+	return err
+}
+
+// applyList sets the list of key, with items of type item, from the
+// environment
+func applyList(v *viper.Viper, key string, item reflect.Type) error {
+	name := EnvName(key)
+	var items []map[string]interface{}
+	changed := false
+	if raw, ok := os.LookupEnv(name); ok {
+		if err := yaml.Unmarshal([]byte(raw), &items); err != nil {
+			return fmt.Errorf("invalid %s: expected a YAML or JSON list: %w", name, err)
+		}
+		changed = true
+	} else if file, ok := v.Get(key).([]interface{}); ok {
+		for _, entry := range file {
+			fields, _ := entry.(map[string]interface{})
+			copied := make(map[string]interface{}, len(fields))
+			for field, value := range fields {
+				copied[field] = value
+			}
+			items = append(items, copied)
+		}
+	}
+
+	// Items in order, so the errors of a list are always the same
+	var names []string
+	for _, variable := range os.Environ() {
+		variable, _, _ = strings.Cut(variable, "=")
+		if rest, ok := strings.CutPrefix(variable, name+"_"); ok && rest != "" && rest[0] >= '0' && rest[0] <= '9' {
+			names = append(names, variable)
+		}
+	}
+	sort.Strings(names)
+	for _, variable := range names {
+		index, field, ok := strings.Cut(strings.TrimPrefix(variable, name+"_"), "_")
+		position, err := strconv.Atoi(index)
+		if !ok || err != nil || field == "" {
+			return fmt.Errorf("invalid variable %s (expected %s_<index>_<setting>)", variable, name)
+		}
+		value, err := listValue(item, strings.ToLower(field), os.Getenv(variable))
+		if err != nil {
+			return fmt.Errorf("invalid variable %s: %w", variable, err)
+		}
+		for len(items) <= position {
+			items = append(items, map[string]interface{}{})
+		}
+		items[position][strings.ToLower(field)] = value
+		changed = true
+	}
+
+	if changed {
+		v.Set(key, items)
+	}
+	return nil
+}
+
+// listValue returns the value of the setting field of a list item given as
+// raw: lists of strings are comma-separated, and the others decoded with
+// the item
+func listValue(item reflect.Type, field string, raw string) (interface{}, error) {
+	for i := 0; i < item.NumField(); i++ {
+		tag, _, _ := strings.Cut(item.Field(i).Tag.Get("mapstructure"), ",")
+		if tag != field || tag == "-" {
+			continue
+		}
+		switch kind := item.Field(i).Type; {
+		case kind.Kind() == reflect.Slice && kind.Elem().Kind() == reflect.String:
+			var values []string
+			for _, value := range strings.Split(raw, ",") {
+				if value = strings.TrimSpace(value); value != "" {
+					values = append(values, value)
+				}
+			}
+			return values, nil
+		case kind.Kind() == reflect.Map || kind.Kind() == reflect.Slice || kind.Kind() == reflect.Struct:
+			return nil, fmt.Errorf("%s is only set in the file or the whole list", field)
+		}
+		return raw, nil
+	}
+	return nil, fmt.Errorf("unknown setting %s", field)
+}
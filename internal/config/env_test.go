@@ -0,0 +1,107 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestConfig writes a configuration file and returns its path
+func writeTestConfig(t *testing.T, data string) string {
+	t.Helper()
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return configPath
+}
+
+func TestListsFromEnvironment(t *testing.T) {
+	// Not even a server in the file
+	configPath := writeTestConfig(t, "posting:\n  group: alt.binaries.test\n")
+	for name, value := range map[string]string{
+		"USENET_NNTP_SERVERS_0_HOST":            "news.example.com",
+		"USENET_NNTP_SERVERS_0_PORT":            "443",
+		"USENET_NNTP_SERVERS_0_SSL":             "true",
+		"USENET_NNTP_SERVERS_0_MAX_CONNECTIONS": "20",
+		"USENET_NNTP_SERVERS_1_HOST":            "backup.example.com",
+		"USENET_NNTP_SERVERS_1_PORT":            "563",
+		"USENET_NNTP_SERVERS_1_BACKUP":          "true",
+		"USENET_NNTP_SERVERS_USERNAME":          "poster",
+		"USENET_NOTIFICATIONS_WEBHOOKS":         `[{"url": "https://hooks.example.com/ypost", "on": "failure"}]`,
+		"USENET_NOTIFICATIONS_CHATS_0_PLATFORM": "discord",
+		"USENET_NOTIFICATIONS_CHATS_0_URL":      "https://discord.com/api/webhooks/1/x",
+		"USENET_NOTIFICATIONS_CHATS_0_EVENTS":   "start, failure",
+	} {
+		t.Setenv(name, value)
+	}
+
+	config, _, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers := config.NNTP.Servers
+	if len(servers) != 2 {
+		t.Fatalf("expected 2 servers, got %+v", servers)
+	}
+	if servers[0].Host != "news.example.com" || servers[0].Port != 443 || !servers[0].SSL || servers[0].MaxConns != 20 {
+		t.Errorf("unexpected first server %+v", servers[0])
+	}
+	if servers[1].Host != "backup.example.com" || !servers[1].Backup {
+		t.Errorf("unexpected second server %+v", servers[1])
+	}
+	// The settings for every server still apply
+	if servers[0].Username != "poster" || servers[1].Username != "poster" {
+		t.Errorf("expected every server to log in as poster, got %q and %q", servers[0].Username, servers[1].Username)
+	}
+
+	webhooks := config.Notifications.Webhooks
+	if len(webhooks) != 1 || webhooks[0].URL != "https://hooks.example.com/ypost" || webhooks[0].On != "failure" {
+		t.Errorf("unexpected webhooks %+v", webhooks)
+	}
+	chats := config.Notifications.Chats
+	if len(chats) != 1 || chats[0].Platform != "discord" || strings.Join(chats[0].Events, ",") != "start,failure" {
+		t.Errorf("unexpected chats %+v", chats)
+	}
+}
+
+func TestListItemFromEnvironmentOverFile(t *testing.T) {
+	configPath := writeTestConfig(t, `nntp:
+  servers:
+    - host: news.example.com
+      port: 563
+      username: poster
+      max_connections: 6
+posting:
+  group: alt.binaries.test
+`)
+	t.Setenv("USENET_NNTP_SERVERS_0_PASSWORD", "from env")
+
+	config, _, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := config.NNTP.Servers[0]
+	if server.Host != "news.example.com" || server.MaxConns != 6 || server.Password != "from env" {
+		t.Errorf("expected the password over the file's server, got %+v", server)
+	}
+}
+
+func TestInvalidListEnvironment(t *testing.T) {
+	configPath := writeTestConfig(t, "posting:\n  group: alt.binaries.test\n")
+	for name, value := range map[string]string{
+		"USENET_NNTP_SERVERS_0_COLOR":            "blue",
+		"USENET_NNTP_SERVERS":                    "news.example.com",
+		"USENET_NNTP_SERVERS_0":                  "news.example.com",
+		"USENET_NOTIFICATIONS_CHATS_0_TEMPLATES": "start=hi",
+		"USENET_NOTIFICATIONS_WEBHOOKS_1X_URL":   "https://hooks.example.com",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, _, err := LoadConfig(configPath); err == nil {
+				t.Errorf("expected %s=%s to be refused", name, value)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"ypost/pkg/models"
+)
+
+// envPrefix is the prefix of the environment variables overriding settings
+const envPrefix = "USENET"
+
+// serversKey is the prefix of the settings applied to every configured server
+const serversKey = "nntp.servers"
+
+// Precedence layers of a setting, lowest first
+const (
+	sourceDefault = iota
+	sourceFile
+	sourceEnv
+	sourceFlag
+)
+
+// keyAnnotation is the annotation of a flag naming the configuration key
+// it sets
+const keyAnnotation = "config_key"
+
+// configFlags holds the flags LoadConfig applies by key, set by SetFlags
+var configFlags map[string]*pflag.Flag
+
+// FlagName returns the flag of a configuration key:
+// posting.subject_template is --posting-subject-template
+func FlagName(key string) string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(key)
+}
+
+// EnvName returns the environment variable of a configuration key:
+// posting.subject_template is USENET_POSTING_SUBJECT_TEMPLATE
+func EnvName(key string) string {
+	return envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// secretKey matches the keys of passwords, tokens and API keys. They get no
+// flag: a command line is shown by ps to every user of the host and kept in
+// the shell history, so they are set in the environment, the file or with a
+// ${VAR} reference or password_cmd.
+var secretKey = regexp.MustCompile(`(^|[._])(password|passwd|token|secret|api_?key)$`)
+
+// IsSecretKey reports whether a configuration key holds a secret
+func IsSecretKey(key string) bool {
+	return secretKey.MatchString(key)
+}
+
+// AddFlags registers a flag for every configuration key that has none on
+// flags yet, named after the key with the default value as its default. The
+// nntp.servers.* flags apply to the configured server, when there is only
+// one. Map settings (custom headers, group presets) are only read from the
+// file, and secrets from the environment or the file.
+func AddFlags(flags *pflag.FlagSet) {
+	defaults := flagDefaults()
+	flagged := make(map[string]bool)
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if key := flag.Annotations[keyAnnotation]; len(key) == 1 {
+			flagged[key[0]] = true
+		}
+	})
+
+	add := func(key string, value reflect.Value) {
+		name := FlagName(key)
+		if flagged[key] || flags.Lookup(name) != nil || IsSecretKey(key) {
+			return
+		}
+		addFlag(flags, key, name, "", fmt.Sprintf("set %s (env %s)", key, EnvName(key)), value)
+	}
+
+	walkKeys(reflect.ValueOf(defaults).Elem(), "", add)
+	walkKeys(reflect.ValueOf(models.ServerConfig{}), serversKey, add)
+}
+
+// AddFlag registers name, with its shorthand and usage, as the flag of a
+// configuration key, which AddFlags then gives no flag of its own
+func AddFlag(flags *pflag.FlagSet, key, name, shorthand, usage string) {
+	var value reflect.Value
+	walkKeys(reflect.ValueOf(flagDefaults()).Elem(), "", func(setting string, v reflect.Value) {
+		if setting == key {
+			value = v
+		}
+	})
+	if !value.IsValid() || IsSecretKey(key) {
+		panic(fmt.Sprintf("config: no flag for the setting %s", key))
+	}
+	addFlag(flags, key, name, shorthand, usage, value)
+}
+
+// flagDefaults returns the defaults the flags show
+func flagDefaults() *models.Config {
+	defaults, err := DefaultConfig()
+	if err != nil {
+		return &models.Config{}
+	}
+	return defaults
+}
+
+// addFlag registers the flag of a key, of the type of its value
+func addFlag(flags *pflag.FlagSet, key, name, shorthand, usage string, value reflect.Value) {
+	// Placeholder passwords make no sense as a shown default
+	if strings.Contains(key, "password") && value.Kind() == reflect.String {
+		value = reflect.ValueOf("")
+	}
+	switch value.Kind() {
+	case reflect.String:
+		flags.StringP(name, shorthand, value.String(), usage)
+	case reflect.Bool:
+		flags.BoolP(name, shorthand, value.Bool(), usage)
+	case reflect.Int:
+		flags.IntP(name, shorthand, int(value.Int()), usage)
+	case reflect.Int64:
+		flags.Int64P(name, shorthand, value.Int(), usage)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.String {
+			return
+		}
+		flags.StringSliceP(name, shorthand, value.Interface().([]string), usage)
+	default:
+		return
+	}
+	flags.SetAnnotation(name, keyAnnotation, []string{key})
+}
+
+// SetFlags sets the flags LoadConfig applies over the environment, the file
+// and the defaults: those AddFlags and AddFlag registered on flags. nil
+// disables them.
+func SetFlags(flags *pflag.FlagSet) {
+	configFlags = nil
+	if flags == nil {
+		return
+	}
+	configFlags = make(map[string]*pflag.Flag)
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if key := flag.Annotations[keyAnnotation]; len(key) == 1 {
+			configFlags[key[0]] = flag
+		}
+	})
+}
+
+// FlagChanged reports whether the flag of a configuration key was given
+func FlagChanged(key string) bool {
+	flag := configFlags[key]
+	return flag != nil && flag.Changed
+}
+
+// walkKeys calls fn with the dotted key and value of every setting of a
+// configuration struct. The servers list and map settings are skipped.
+func walkKeys(value reflect.Value, prefix string, fn func(string, reflect.Value)) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if key == "-" || !field.IsExported() {
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		switch item := value.Field(i); item.Kind() {
+		case reflect.Struct:
+			walkKeys(item, key, fn)
+		case reflect.Map:
+		case reflect.Slice:
+			if item.Type().Elem().Kind() != reflect.Struct {
+				fn(key, item)
+			}
+		default:
+			fn(key, item)
+		}
+	}
+}
+
+// bindFlags binds the flags set with SetFlags to their configuration keys
+func bindFlags(v *viper.Viper) error {
+	if configFlags == nil {
+		return nil
+	}
+
+	var err error
+	walkKeys(reflect.ValueOf(models.Config{}), "", func(key string, value reflect.Value) {
+		if flag := configFlags[key]; flag != nil && err == nil {
+			err = v.BindPFlag(key, flag)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to bind flags: %w", err)
+	}
+	return nil
+}
+
+// settingSource returns the highest layer a setting is given in
+func settingSource(inConfig func(string) bool, key string) int {
+	if FlagChanged(key) {
+		return sourceFlag
+	}
+	if _, ok := os.LookupEnv(EnvName(key)); ok {
+		return sourceEnv
+	}
+	if inConfig(key) {
+		return sourceFile
+	}
+	return sourceDefault
+}
+
+// applyServerOverrides applies the nntp.servers.* flags and environment
+// variables to every configured server, flags first. A flag is meant for the
+// server of a one-server configuration: with several it is refused, the
+// indexed variables setting one server.
+func applyServerOverrides(servers []models.ServerConfig) error {
+	var err error
+	walkKeys(reflect.ValueOf(models.ServerConfig{}), serversKey, func(key string, _ reflect.Value) {
+		if err != nil {
+			return
+		}
+
+		field := strings.TrimPrefix(key, serversKey+".")
+		var raw string
+		var ok bool
+		if FlagChanged(key) {
+			if len(servers) > 1 {
+				err = fmt.Errorf("--%s would apply to all %d servers: set %s for one of them instead", configFlags[key].Name, len(servers), EnvName(serversKey+".<N>."+field))
+				return
+			}
+			raw, ok = configFlags[key].Value.String(), true
+		}
+		if !ok {
+			raw, ok = os.LookupEnv(EnvName(key))
+		}
+		if !ok {
+			return
+		}
+
+		for i := range servers {
+			if err = setServerField(&servers[i], field, raw); err != nil {
+				return
+			}
+		}
+	})
+	return err
+}
+
+// setServerField parses raw into the server setting with the given key
+func setServerField(server *models.ServerConfig, key string, raw string) error {
+	value := reflect.ValueOf(server).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		tag, _, _ := strings.Cut(value.Type().Field(i).Tag.Get("mapstructure"), ",")
+		if tag != key {
+			continue
+		}
+
+		field := value.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(raw)
+		case reflect.Bool:
+			parsed, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("invalid %s.%s %q: %w", serversKey, key, raw, err)
+			}
+			field.SetBool(parsed)
+		case reflect.Int:
+			parsed, err := strconv.Atoi(raw)
+			if err != nil {
+				return fmt.Errorf("invalid %s.%s %q: %w", serversKey, key, raw, err)
+			}
+			field.SetInt(int64(parsed))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown server setting %s", key)
+}
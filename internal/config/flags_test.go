@@ -0,0 +1,231 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"ypost/pkg/models"
+)
+
+func TestSettingPrecedence(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	data := `nntp:
+  servers:
+    - host: news.example.com
+      port: 563
+      max_connections: 6
+    - host: backup.example.com
+      port: 563
+      max_connections: 6
+      backup: true
+posting:
+  subject_template: "from file"
+par2:
+  redundancy: 15
+`
+	if err := os.WriteFile(configPath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		env         map[string]string
+		flags       []string
+		subject     string
+		redundancy  int
+		alignParts  bool
+		connections int
+	}{
+		{
+			name:        "file over defaults",
+			subject:     "from file",
+			redundancy:  15,
+			connections: 6,
+		},
+		{
+			name: "environment over file",
+			env: map[string]string{
+				"USENET_POSTING_SUBJECT_TEMPLATE":     "from env",
+				"USENET_PAR2_REDUNDANCY":              "20",
+				"USENET_SPLITTING_ALIGN_PARTS":        "true",
+				"USENET_NNTP_SERVERS_MAX_CONNECTIONS": "12",
+			},
+			subject:     "from env",
+			redundancy:  20,
+			alignParts:  true,
+			connections: 12,
+		},
+		{
+			name: "flags over environment",
+			env: map[string]string{
+				"USENET_POSTING_SUBJECT_TEMPLATE":     "from env",
+				"USENET_PAR2_REDUNDANCY":              "20",
+				"USENET_NNTP_SERVERS_MAX_CONNECTIONS": "12",
+			},
+			flags: []string{
+				"--posting-subject-template=from flag",
+				"--par2-redundancy=25",
+				"--splitting-align-parts",
+			},
+			subject:     "from flag",
+			redundancy:  25,
+			alignParts:  true,
+			connections: 12,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+			flags := pflag.NewFlagSet("post", pflag.ContinueOnError)
+			AddFlags(flags)
+			if err := flags.Parse(test.flags); err != nil {
+				t.Fatal(err)
+			}
+			SetFlags(flags)
+			defer SetFlags(nil)
+
+			config, _, err := LoadConfig(configPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Posting.SubjectTemplate != test.subject {
+				t.Errorf("subject template %q, expected %q", config.Posting.SubjectTemplate, test.subject)
+			}
+			if config.Par2.Redundancy != test.redundancy {
+				t.Errorf("redundancy %d, expected %d", config.Par2.Redundancy, test.redundancy)
+			}
+			if config.Splitting.AlignParts != test.alignParts {
+				t.Errorf("align parts %v, expected %v", config.Splitting.AlignParts, test.alignParts)
+			}
+			for _, server := range config.NNTP.Servers {
+				if server.MaxConns != test.connections {
+					t.Errorf("%s: %d connections, expected %d", server.Host, server.MaxConns, test.connections)
+				}
+			}
+			if !config.NNTP.Servers[1].Backup {
+				t.Error("server settings not given as flags were changed")
+			}
+		})
+	}
+}
+
+func TestServerFlags(t *testing.T) {
+	one := writeTestConfig(t, "nntp:\n  servers:\n    - host: news.example.com\n      port: 563\n      max_connections: 6\n")
+	two := writeTestConfig(t, "nntp:\n  servers:\n    - host: news.example.com\n      port: 563\n    - host: backup.example.com\n      port: 563\n      backup: true\n")
+
+	flags := pflag.NewFlagSet("post", pflag.ContinueOnError)
+	AddFlags(flags)
+	if err := flags.Parse([]string{"--nntp-servers-max-connections=16"}); err != nil {
+		t.Fatal(err)
+	}
+	SetFlags(flags)
+	defer SetFlags(nil)
+
+	config, _, err := LoadConfig(one)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.NNTP.Servers[0].MaxConns != 16 {
+		t.Errorf("expected 16 connections, got %d", config.NNTP.Servers[0].MaxConns)
+	}
+
+	// A flag cannot tell which of several servers it is for
+	if _, _, err := LoadConfig(two); err == nil || !strings.Contains(err.Error(), "USENET_NNTP_SERVERS_<N>_MAX_CONNECTIONS") {
+		t.Errorf("expected the server flag to be refused with two servers, got %v", err)
+	}
+}
+
+func TestFlagAlias(t *testing.T) {
+	configPath := writeTestConfig(t, `nntp:
+  server: news.example.com
+posting:
+  group: alt.binaries.test
+groups:
+  alt.binaries.test:
+    subject_template: "from preset"
+    par2_redundancy: 30
+`)
+	flags := pflag.NewFlagSet("post", pflag.ContinueOnError)
+	AddFlag(flags, "posting.subject_template", "subject", "s", "subject template")
+	AddFlags(flags)
+	if flags.Lookup("posting-subject-template") != nil {
+		t.Error("the setting has two flags")
+	}
+	if err := flags.Parse([]string{"-s", "from flag"}); err != nil {
+		t.Fatal(err)
+	}
+	SetFlags(flags)
+	defer SetFlags(nil)
+
+	config, _, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ApplyGroupPreset(config)
+	if config.Posting.SubjectTemplate != "from flag" {
+		t.Errorf("expected the subject of the flag over the preset, got %q", config.Posting.SubjectTemplate)
+	}
+	if config.Par2.Redundancy != 30 {
+		t.Errorf("expected the redundancy of the preset, got %d", config.Par2.Redundancy)
+	}
+}
+
+func TestMaxPartSizeFlag(t *testing.T) {
+	flags := pflag.NewFlagSet("post", pflag.ContinueOnError)
+	AddFlags(flags)
+	if err := flags.Parse([]string{"--posting-max-part-size=1000000"}); err != nil {
+		t.Fatal(err)
+	}
+	SetFlags(flags)
+	defer SetFlags(nil)
+
+	// The default splitting.max_file_size would otherwise win
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("nntp:\n  server: news.example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, _, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Posting.MaxPartSize != 1000000 {
+		t.Errorf("max part size %d, expected 1000000", config.Posting.MaxPartSize)
+	}
+}
+
+func TestFlagsCoverConfig(t *testing.T) {
+	flags := pflag.NewFlagSet("post", pflag.ContinueOnError)
+	AddFlags(flags)
+
+	for key := range flattenSettings(&models.Config{}) {
+		if flags.Lookup(FlagName(key)) == nil && !IsSecretKey(key) {
+			t.Errorf("no flag for %s", key)
+		}
+	}
+	if flags.Lookup("nntp-servers-ssl") == nil {
+		t.Error("no flag for the server settings")
+	}
+}
+
+func TestNoFlagsForSecrets(t *testing.T) {
+	flags := pflag.NewFlagSet("post", pflag.ContinueOnError)
+	AddFlags(flags)
+
+	for _, name := range []string{"nntp-servers-password", "nntp-password", "archive-password", "notifications-email-password", "grpc-token", "indexer-api-key", "roundtrip-api-key", "roundtrip-password"} {
+		if flags.Lookup(name) != nil {
+			t.Errorf("unexpected flag --%s for a secret", name)
+		}
+	}
+	// Commands obtaining a secret are no secret themselves
+	for _, name := range []string{"nntp-servers-password-cmd", "security-key-cmd", "nntp-servers-username"} {
+		if flags.Lookup(name) == nil {
+			t.Errorf("no flag --%s", name)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported configuration file formats
+const (
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+	FormatJSON = "json"
+)
+
+// configFormat overrides format detection from the file extension
+var configFormat string
+
+// SetFormat forces the format used to read and write configuration files,
+// regardless of their extension. An empty format restores detection.
+func SetFormat(format string) error {
+	format = strings.ToLower(format)
+	switch format {
+	case "", FormatYAML, FormatTOML, FormatJSON:
+	case "yml":
+		format = FormatYAML
+	default:
+		return fmt.Errorf("unsupported config format %q (expected yaml, toml or json)", format)
+	}
+	configFormat = format
+	return nil
+}
+
+// formatFor returns the format of a configuration file: the override if set,
+// otherwise the one matching its extension, YAML by default
+func formatFor(configPath string) string {
+	if configFormat != "" {
+		return configFormat
+	}
+	switch strings.ToLower(filepath.Ext(configPath)) {
+	case ".toml":
+		return FormatTOML
+	case ".json":
+		return FormatJSON
+	default:
+		return FormatYAML
+	}
+}
+
+// encodeSettings marshals settings in the given format
+func encodeSettings(settings interface{}, format string) ([]byte, error) {
+	switch format {
+	case FormatTOML:
+		return toml.Marshal(settings)
+	case FormatJSON:
+		data, err := json.MarshalIndent(settings, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	default:
+		return yaml.Marshal(settings)
+	}
+}
+
+// settingsMap converts a configuration value into nested maps keyed by the
+// mapstructure tags, so every format is written with the keys LoadConfig reads
+func settingsMap(value reflect.Value) interface{} {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return settingsMap(value.Elem())
+	case reflect.Struct:
+		settings := make(map[string]interface{})
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			key, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+			if key == "-" || !field.IsExported() {
+				continue
+			}
+			if key == "" {
+				key = strings.ToLower(field.Name)
+			}
+			settings[key] = settingsMap(value.Field(i))
+		}
+		return settings
+	case reflect.Slice, reflect.Array:
+		items := make([]interface{}, value.Len())
+		for i := range items {
+			items[i] = settingsMap(value.Index(i))
+		}
+		return items
+	case reflect.Map:
+		settings := make(map[string]interface{})
+		iter := value.MapRange()
+		for iter.Next() {
+			settings[fmt.Sprint(iter.Key().Interface())] = settingsMap(iter.Value())
+		}
+		return settings
+	default:
+		return value.Interface()
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigFormats(t *testing.T) {
+	for _, name := range []string{"config.yaml", "config.toml", "config.json"} {
+		t.Run(name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), name)
+			if err := CreateSampleConfig(configPath); err != nil {
+				t.Fatal(err)
+			}
+
+			// Keys are written as LoadConfig reads them, whatever the format
+			data, err := os.ReadFile(configPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(data), "max_connections") {
+				t.Errorf("%s written without mapstructure keys:\n%s", name, data)
+			}
+
+			config, configFile, err := LoadConfig(configPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if configFile != configPath {
+				t.Errorf("expected config file %s, got %s", configPath, configFile)
+			}
+			if len(config.NNTP.Servers) != 1 || config.NNTP.Servers[0].MaxConns != 8 {
+				t.Errorf("servers not read back: %+v", config.NNTP.Servers)
+			}
+			if config.Posting.Group != "alt.binaries.test" || config.Par2.Redundancy != 10 {
+				t.Errorf("settings not read back: %+v", config.Posting)
+			}
+		})
+	}
+}
+
+func TestFormatOverride(t *testing.T) {
+	if err := SetFormat("toml"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetFormat("")
+
+	// The override wins over the extension on write and read
+	configPath := filepath.Join(t.TempDir(), "ypost.conf")
+	if err := CreateSampleConfig(configPath); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "[nntp]") {
+		t.Errorf("expected TOML output:\n%s", data)
+	}
+	if _, _, err := LoadConfig(configPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetFormat("ini"); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
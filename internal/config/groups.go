@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+	"ypost/pkg/models"
+)
+
+// decodeGroups decodes the group presets. Group names contain dots, which
+// viper treats as key separators when unmarshaling, so the presets are read
+// from the raw groups block instead. Keys that match no preset field are
+// recorded in metadata when it is set.
+func decodeGroups(v *viper.Viper, config *models.Config, metadata *mapstructure.Metadata) error {
+	config.Groups = nil
+	raw := v.Get("groups")
+	if raw == nil {
+		return nil
+	}
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Metadata:         metadata,
+		Result:           &config.Groups,
+		WeaklyTypedInput: true,
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(raw); err != nil {
+		return fmt.Errorf("failed to decode group presets: %w", err)
+	}
+	return nil
+}
+
+// GroupPreset returns the preset for a group, or for the first group of a
+// comma-separated list. Group names are matched case-insensitively.
+func GroupPreset(config *models.Config, group string) (models.GroupPreset, bool) {
+	for _, name := range []string{group, strings.Split(group, ",")[0]} {
+		name = strings.ToLower(strings.TrimSpace(name))
+		for presetGroup, preset := range config.Groups {
+			if strings.ToLower(presetGroup) == name {
+				return preset, true
+			}
+		}
+	}
+	return models.GroupPreset{}, false
+}
+
+// ApplyGroupPreset applies the preset of the posting group over the general
+// settings, not over those given as flags, and reports whether one was found
+func ApplyGroupPreset(config *models.Config) bool {
+	preset, ok := GroupPreset(config, config.Posting.Group)
+	if !ok {
+		return false
+	}
+
+	if preset.SubjectTemplate != "" && !FlagChanged("posting.subject_template") {
+		config.Posting.SubjectTemplate = preset.SubjectTemplate
+	}
+	if preset.ArticleSize > 0 && !FlagChanged("posting.max_article_size") {
+		config.Posting.MaxArticleSize = preset.ArticleSize
+	}
+	if preset.Par2Redundancy > 0 && !FlagChanged("par2.redundancy") {
+		config.Par2.Redundancy = preset.Par2Redundancy
+	}
+	return true
+}
+
+// validateGroups checks the values of every group preset
+func validateGroups(config *models.Config) error {
+	for name, preset := range config.Groups {
+		if preset.ArticleSize != 0 {
+			if err := ValidateArticleSize(preset.ArticleSize); err != nil {
+				return fmt.Errorf("group %s: %w", name, err)
+			}
+		}
+		if preset.Par2Redundancy < 0 || preset.Par2Redundancy > 100 {
+			return fmt.Errorf("group %s: invalid par2 redundancy %d", name, preset.Par2Redundancy)
+		}
+	}
+	return nil
+}
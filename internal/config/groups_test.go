@@ -0,0 +1,79 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const groupsConfig = `nntp:
+  servers:
+    - host: "news.example.com"
+      port: 563
+posting:
+  group: "alt.binaries.test"
+  poster_email: "me@example.org"
+  subject_template: "{{.Filename}}"
+  max_article_size: 500000
+groups:
+  alt.binaries.multimedia:
+    subject_template: "[{{.Index}}/{{.Total}}] {{.Filename}}"
+    article_size: 700000
+    par2_redundancy: 15
+  alt.binaries.test:
+    article_size: 250000
+    sizze: 1
+`
+
+func TestGroupPresets(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte(groupsConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, _, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Groups) != 2 {
+		t.Fatalf("expected 2 group presets, got %+v", config.Groups)
+	}
+
+	config.Posting.Group = "alt.binaries.multimedia,alt.binaries.test"
+	if !ApplyGroupPreset(config) {
+		t.Fatal("preset of the first group not applied")
+	}
+	if config.Posting.SubjectTemplate != "[{{.Index}}/{{.Total}}] {{.Filename}}" ||
+		config.Posting.MaxArticleSize != 700000 || config.Par2.Redundancy != 15 {
+		t.Errorf("preset not applied: %+v %+v", config.Posting, config.Par2)
+	}
+
+	// Unset preset fields keep the general settings
+	config, _, _ = LoadConfig(configPath)
+	ApplyGroupPreset(config)
+	if config.Posting.SubjectTemplate != "{{.Filename}}" || config.Posting.MaxArticleSize != 250000 {
+		t.Errorf("unexpected settings: %+v", config.Posting)
+	}
+
+	config.Posting.Group = "alt.binaries.other"
+	if ApplyGroupPreset(config) {
+		t.Error("preset applied to a group without one")
+	}
+
+	// Unknown preset keys are reported with their line
+	_, diagnostics, err := Validate(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Key == "groups.alt.binaries.test.sizze" && diagnostic.Line == 17 {
+			found = true
+		} else if diagnostic.Severity == SeverityError {
+			t.Errorf("unexpected diagnostic: %+v", diagnostic)
+		}
+	}
+	if !found {
+		t.Errorf("unknown preset key not reported: %+v", diagnostics)
+	}
+}
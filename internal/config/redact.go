@@ -0,0 +1,49 @@
+package config
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"ypost/pkg/models"
+)
+
+// secretLine matches a setting whose value is a secret in a YAML, TOML or
+// JSON configuration file: the key, its separator and the value
+var secretLine = regexp.MustCompile(`(?i)((?:^|[\s{,])"?[a-z0-9_]*(?:password|passwd|secret|token|key_cmd|api_?key|authorization)[a-z0-9_]*"?\s*[:=]\s*)("[^"]*"|'[^']*'|[^\s,#}]+)`)
+
+// RedactText masks the secret values of a configuration file, for logging
+// its contents
+func RedactText(content string) string {
+	return secretLine.ReplaceAllString(content, `${1}"***"`)
+}
+
+// Secrets returns the resolved secrets of a configuration, for the logger
+// to mask wherever they appear
+func Secrets(config *models.Config) []string {
+	var secrets []string
+	for _, server := range config.NNTP.Servers {
+		secrets = append(secrets, server.Password, server.PasswordRef)
+	}
+	secrets = append(secrets, config.NNTP.Password, config.Archive.Password, config.Notifications.Email.Password, config.GRPC.Token, config.Indexer.APIKey)
+	secrets = append(secrets, config.RoundTrip.APIKey, config.RoundTrip.Password)
+	for _, hook := range config.Notifications.Webhooks {
+		for name, value := range hook.Headers {
+			if strings.EqualFold(name, "Authorization") || strings.Contains(strings.ToLower(name), "token") {
+				secrets = append(secrets, value)
+			}
+		}
+		if u, err := url.Parse(hook.URL); err == nil && u.User != nil {
+			password, _ := u.User.Password()
+			secrets = append(secrets, password)
+		}
+	}
+	for _, chat := range config.Notifications.Chats {
+		// The webhook URL of a Discord or Slack channel is its credential
+		secrets = append(secrets, chat.Token, chat.URL)
+	}
+	for _, arr := range config.Notifications.Arrs {
+		secrets = append(secrets, arr.APIKey)
+	}
+	return secrets
+}
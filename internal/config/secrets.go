@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"ypost/pkg/models"
+)
+
+// envReference matches ${VAR} references in secret values
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// resolveSecrets resolves server passwords in place, decrypting enc: values
+// with the configured key. The value as written in the configuration is kept
+// in PasswordRef so it can be saved back unresolved.
+func resolveSecrets(config *models.Config) error {
+	var key []byte
+	for i := range config.NNTP.Servers {
+		server := &config.NNTP.Servers[i]
+		if server.Password == "" && server.PasswordCmd == "" {
+			continue
+		}
+
+		if server.PasswordCmd == "" && isEncrypted(server.Password) {
+			if key == nil {
+				var err error
+				if key, err = encryptionKey(config, false); err != nil {
+					return fmt.Errorf("server %d: %w", i+1, err)
+				}
+			}
+			password, err := decryptSecret(server.Password, key)
+			if err != nil {
+				return fmt.Errorf("server %d: %w", i+1, err)
+			}
+			server.PasswordRef = server.Password
+			server.Password = password
+			continue
+		}
+
+		password, err := ResolveSecret(server.Password, server.PasswordCmd)
+		if err != nil {
+			return fmt.Errorf("server %d: %w", i+1, err)
+		}
+		if password != server.Password {
+			server.PasswordRef = server.Password
+			server.Password = password
+		}
+	}
+
+	email := &config.Notifications.Email
+	if email.Enabled {
+		password, err := ResolveSecret(email.Password, email.PasswordCmd)
+		if err != nil {
+			return fmt.Errorf("notifications.email: %w", err)
+		}
+		email.Password = password
+	}
+	return nil
+}
+
+// ResolveSecret runs command when set and returns its output, otherwise
+// expands ${VAR} references in value. A reference to an unset variable is an
+// error rather than an empty password.
+func ResolveSecret(value string, command string) (string, error) {
+	if command != "" {
+		return runSecretCommand("password_cmd", command)
+	}
+
+	var missing []string
+	resolved := envReference.ReplaceAllStringFunc(value, func(ref string) string {
+		name := envReference.FindStringSubmatch(ref)[1]
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return secret
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return resolved, nil
+}
+
+// runSecretCommand runs a secret command (e.g. a keyring lookup) through the
+// shell and returns its first line of output. name is the configuration key
+// of the command, for error messages.
+func runSecretCommand(name string, command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s: %w", name, err)
+	}
+
+	secret, _, _ := strings.Cut(string(output), "\n")
+	secret = strings.TrimRight(secret, "\r")
+	if secret == "" {
+		return "", fmt.Errorf("%s returned an empty value", name)
+	}
+	return secret, nil
+}
+
+// unresolvedServers returns a copy of the servers with passwords restored to
+// their form in the configuration, so resolved secrets are never written out
+func unresolvedServers(servers []models.ServerConfig) []models.ServerConfig {
+	unresolved := make([]models.ServerConfig, len(servers))
+	copy(unresolved, servers)
+	for i := range unresolved {
+		server := &unresolved[i]
+		switch {
+		case server.PasswordCmd != "":
+			server.Password = ""
+		case server.PasswordRef != "":
+			server.Password = server.PasswordRef
+		}
+		server.PasswordRef = ""
+	}
+	return unresolved
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ypost/pkg/models"
+)
+
+func TestResolveSecret(t *testing.T) {
+	t.Setenv("YPOST_TEST_PASS", "s3cret")
+
+	tests := []struct {
+		value    string
+		expected string
+		wantErr  bool
+	}{
+		{"${YPOST_TEST_PASS}", "s3cret", false},
+		{"prefix-${YPOST_TEST_PASS}", "prefix-s3cret", false},
+		{"plain$password", "plain$password", false},
+		{"${YPOST_TEST_UNSET}", "", true},
+	}
+
+	for _, test := range tests {
+		result, err := ResolveSecret(test.value, "")
+		if (err != nil) != test.wantErr {
+			t.Errorf("ResolveSecret(%q): unexpected error %v", test.value, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("ResolveSecret(%q): expected %q, got %q", test.value, test.expected, result)
+		}
+	}
+}
+
+func TestResolveSecretCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+
+	result, err := ResolveSecret("ignored", "printf 'from-cmd\\nextra'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "from-cmd" {
+		t.Errorf("expected first line of output, got %q", result)
+	}
+
+	if _, err := ResolveSecret("", "exit 1"); err == nil {
+		t.Error("expected an error for a failing password_cmd")
+	}
+}
+
+func TestSaveConfigKeepsSecretReferences(t *testing.T) {
+	t.Setenv("YPOST_TEST_PASS", "s3cret")
+
+	config := &models.Config{}
+	config.NNTP.Servers = []models.ServerConfig{
+		{Host: "news.example.com", Port: 563, Password: "${YPOST_TEST_PASS}"},
+		{Host: "backup.example.com", Port: 563, PasswordCmd: "echo hidden", Password: "hidden"},
+	}
+	if err := resolveSecrets(config); err != nil {
+		t.Fatal(err)
+	}
+	if config.NNTP.Servers[0].Password != "s3cret" {
+		t.Fatalf("password not resolved: %q", config.NNTP.Servers[0].Password)
+	}
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := SaveConfig(config, configPath); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	if strings.Contains(content, "s3cret") || strings.Contains(content, "password: hidden") {
+		t.Errorf("resolved secret written to config:\n%s", content)
+	}
+	if !strings.Contains(content, "${YPOST_TEST_PASS}") {
+		t.Errorf("secret reference not preserved:\n%s", content)
+	}
+}
@@ -0,0 +1,240 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+	"ypost/pkg/models"
+)
+
+// Severity levels of a Diagnostic
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// placeholderValues are the sample and default values that cannot work as-is
+var placeholderValues = map[string]string{
+	"your-newsserver.com": "placeholder server host",
+	"your-username":       "placeholder username",
+	"your-password":       "placeholder password",
+	"poster@example.com":  "placeholder poster address",
+}
+
+// Diagnostic is a single finding of Validate. Line is the line of Key in the
+// configuration file, or 0 when it is not known.
+type Diagnostic struct {
+	Severity string `json:"severity"`
+	Key      string `json:"key"`
+	Line     int    `json:"line,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Format renders the diagnostic as file:line: severity: key: message
+func (d Diagnostic) Format(file string) string {
+	location := file
+	if location == "" {
+		location = "(defaults)"
+	}
+	if d.Line > 0 {
+		location = fmt.Sprintf("%s:%d", location, d.Line)
+	}
+	if d.Key == "" {
+		return fmt.Sprintf("%s: %s: %s", location, d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s: %s", location, d.Severity, d.Key, d.Message)
+}
+
+// Validate loads the configuration like LoadConfig and runs extended checks:
+// unknown keys, placeholder values, conflicting sizes and missing directories.
+// It returns the configuration file used and the diagnostics, in file order.
+// The error is only set when the file cannot be read at all.
+func Validate(configPath string) (string, []Diagnostic, error) {
+	v, err := readConfig(configPath)
+	if err != nil {
+		return "", nil, err
+	}
+	configFile := v.ConfigFileUsed()
+	lines := keyLines(configFile)
+
+	var diagnostics []Diagnostic
+	report := func(severity string, key string, format string, args ...interface{}) {
+		diagnostics = append(diagnostics, Diagnostic{
+			Severity: severity,
+			Key:      key,
+			Line:     lines[key],
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	for _, key := range unknownKeys(v) {
+		report(SeverityError, key, "unknown key")
+	}
+
+	config, _, err := LoadConfig(configFile)
+	if err != nil {
+		report(SeverityError, "", "%v", err)
+		return configFile, sortDiagnostics(diagnostics), nil
+	}
+
+	// Without a servers list the server was converted from the legacy keys
+	legacy := !v.IsSet("nntp.servers")
+	for i, server := range config.NNTP.Servers {
+		prefix, hostKey := fmt.Sprintf("nntp.servers[%d]", i), fmt.Sprintf("nntp.servers[%d].host", i)
+		if legacy {
+			prefix, hostKey = "nntp", "nntp.server"
+		}
+		checkPlaceholder(report, hostKey, server.Host)
+		checkPlaceholder(report, prefix+".username", server.Username)
+		if server.PasswordRef == "" && server.PasswordCmd == "" {
+			checkPlaceholder(report, prefix+".password", server.Password)
+		}
+	}
+	checkPlaceholder(report, "posting.poster_email", config.Posting.PosterEmail)
+
+	if config.Posting.MaxArticleSize > config.Posting.MaxPartSize {
+		report(SeverityWarning, "posting.max_article_size",
+			"article size %d is larger than the part size %d, every part fits in a single article",
+			config.Posting.MaxArticleSize, config.Posting.MaxPartSize)
+	}
+	if config.Splitting.MaxFileSize != "" && v.InConfig("posting.max_part_size") &&
+		v.GetInt64("posting.max_part_size") != config.Posting.MaxPartSize {
+		report(SeverityWarning, "posting.max_part_size",
+			"ignored because splitting.max_file_size (%s) is set", config.Splitting.MaxFileSize)
+	}
+
+	if config.Obfuscation.Enabled && config.Obfuscation.ScrambleFilenames && config.NZB.MappingMode == "none" {
+		report(SeverityWarning, "nzb.mapping_mode",
+			"scrambled file names are not recorded anywhere, the real names cannot be recovered")
+	}
+
+	for _, dir := range []struct {
+		key  string
+		path string
+	}{
+		{"output.output_dir", config.Output.OutputDir},
+		{"output.nzb_dir", config.Output.NZBDir},
+		{"output.log_dir", config.Output.LogDir},
+	} {
+		if dir.path == "" {
+			continue
+		}
+		info, err := os.Stat(dir.path)
+		switch {
+		case os.IsNotExist(err):
+			report(SeverityWarning, dir.key, "directory %s does not exist and will be created", dir.path)
+		case err != nil:
+			report(SeverityError, dir.key, "cannot access %s: %v", dir.path, err)
+		case !info.IsDir():
+			report(SeverityError, dir.key, "%s is not a directory", dir.path)
+		}
+	}
+
+	return configFile, sortDiagnostics(diagnostics), nil
+}
+
+// checkPlaceholder reports values left at their sample placeholder
+func checkPlaceholder(report func(string, string, string, ...interface{}), key string, value string) {
+	if description, ok := placeholderValues[value]; ok {
+		report(SeverityError, key, "%s %q must be replaced", description, value)
+	}
+}
+
+// unknownKeys strictly decodes the configuration and returns the keys that do
+// not map to any configuration field
+func unknownKeys(v *viper.Viper) []string {
+	var config models.Config
+	var metadata mapstructure.Metadata
+	err := v.Unmarshal(&config, func(dc *mapstructure.DecoderConfig) {
+		dc.Metadata = &metadata
+	})
+	if err != nil {
+		return nil
+	}
+
+	// Dotted group names come out of the main decode split into pieces
+	var unknown []string
+	for _, key := range metadata.Unused {
+		if !strings.HasPrefix(key, "groups[") {
+			unknown = append(unknown, key)
+		}
+	}
+
+	var groupMetadata mapstructure.Metadata
+	if err := decodeGroups(v, &config, &groupMetadata); err == nil {
+		for _, key := range groupMetadata.Unused {
+			unknown = append(unknown, "groups."+groupKey(key))
+		}
+	}
+
+	sort.Strings(unknown)
+	return unknown
+}
+
+// groupKey turns a preset decode path (alt.binaries.x.foo, decoded from a map
+// as [alt.binaries.x].foo) into the dotted key used for line lookups
+func groupKey(key string) string {
+	key = strings.TrimPrefix(key, "[")
+	return strings.Replace(key, "].", ".", 1)
+}
+
+// keyLines maps the dotted keys of a YAML file (nntp.servers[0].host) to their
+// line numbers. Other formats have no line information.
+func keyLines(configFile string) map[string]int {
+	lines := make(map[string]int)
+	switch strings.ToLower(filepath.Ext(configFile)) {
+	case ".yaml", ".yml":
+	default:
+		return lines
+	}
+
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return lines
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil || len(root.Content) == 0 {
+		return lines
+	}
+
+	var walk func(node *yaml.Node, prefix string)
+	walk = func(node *yaml.Node, prefix string) {
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := strings.ToLower(node.Content[i].Value)
+				if prefix != "" {
+					key = prefix + "." + key
+				}
+				lines[key] = node.Content[i].Line
+				walk(node.Content[i+1], key)
+			}
+		case yaml.SequenceNode:
+			for i, item := range node.Content {
+				key := fmt.Sprintf("%s[%d]", prefix, i)
+				lines[key] = item.Line
+				walk(item, key)
+			}
+		}
+	}
+	walk(root.Content[0], "")
+	return lines
+}
+
+// sortDiagnostics orders diagnostics by line, keeping those without a line last
+func sortDiagnostics(diagnostics []Diagnostic) []Diagnostic {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		li, lj := diagnostics[i].Line, diagnostics[j].Line
+		if li == 0 || lj == 0 {
+			return lj == 0 && li != 0
+		}
+		return li < lj
+	})
+	return diagnostics
+}
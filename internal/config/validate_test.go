@@ -0,0 +1,60 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+	content := `nntp:
+  servers:
+    - host: "your-newsserver.com"
+      port: 563
+      hots: "typo"
+posting:
+  group: "alt.binaries.test"
+  poster_email: "me@example.org"
+  max_part_size: 100000
+  max_article_size: 500000
+output:
+  output_dir: "` + filepath.Join(tempDir, "missing") + `"
+  nzb_dir: "` + tempDir + `"
+  log_dir: "` + tempDir + `"
+splitting:
+  max_file_size: ""
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	configFile, diagnostics, err := Validate(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configFile != configPath {
+		t.Errorf("expected config file %s, got %s", configPath, configFile)
+	}
+
+	expected := []struct {
+		severity string
+		key      string
+		line     int
+	}{
+		{SeverityError, "nntp.servers[0].host", 3},
+		{SeverityError, "nntp.servers[0].hots", 5},
+		{SeverityWarning, "posting.max_article_size", 10},
+		{SeverityWarning, "output.output_dir", 12},
+	}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %d: %+v", len(expected), len(diagnostics), diagnostics)
+	}
+	for i, want := range expected {
+		got := diagnostics[i]
+		if got.Severity != want.severity || got.Key != want.key || got.Line != want.line {
+			t.Errorf("diagnostic %d: expected %s %s at line %d, got %+v", i, want.severity, want.key, want.line, got)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"ypost/internal/logger"
+	"ypost/pkg/models"
+)
+
+// hotReloadable lists the settings applied on reload without restarting, by
+// key or key prefix. Everything else (servers, output directories, splitting)
+// is connection- or layout-critical and only takes effect after a restart.
+var hotReloadable = []string{
+	"posting.subject_template",
+	"posting.subject_comment",
+	"posting.subject_quote",
+	"posting.subject_brackets",
+	"posting.custom_headers",
+	"posting.x_no_archive",
+	"posting.organization",
+	"posting.x_newsposter",
+	"par2.redundancy",
+	"nzb",
+	"obfuscation",
+	"schedule",
+	"groups",
+	"logging.level",
+	"logging.levels",
+}
+
+// Change describes one setting that differs after a reload
+type Change struct {
+	Key     string
+	Old     string
+	New     string
+	Applied bool
+}
+
+// Watcher reloads the configuration file when it changes and applies the
+// hot-reloadable settings to the current configuration
+type Watcher struct {
+	mu       sync.RWMutex
+	current  *models.Config
+	path     string
+	log      *logger.Logger
+	onChange func(*models.Config, []Change)
+}
+
+// Watch loads the configuration and starts watching its file. onChange, if
+// set, is called with the updated configuration after every reload that
+// applied at least one change.
+func Watch(configPath string, log *logger.Logger, onChange func(*models.Config, []Change)) (*Watcher, error) {
+	current, configFile, err := LoadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if configFile == "" {
+		return nil, fmt.Errorf("no configuration file to watch")
+	}
+
+	v, err := readConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{current: current, path: configFile, log: log, onChange: onChange}
+	v.OnConfigChange(func(event fsnotify.Event) {
+		w.reload()
+	})
+	v.WatchConfig()
+	return w, nil
+}
+
+// Config returns the current configuration. Callers must treat it as read-only;
+// a reload replaces it rather than modifying it.
+func (w *Watcher) Config() *models.Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.current
+}
+
+// reload reads the file again and applies its hot-reloadable changes. An
+// invalid file is reported and the current configuration kept.
+func (w *Watcher) reload() {
+	loaded, _, err := LoadConfig(w.path)
+	if err != nil {
+		w.log.Error("Configuration reload failed, keeping the current settings: %v", err)
+		return
+	}
+
+	w.mu.Lock()
+	updated, changes := mergeReloadable(w.current, loaded)
+	w.current = updated
+	w.mu.Unlock()
+
+	if level, err := logger.ParseLevel(updated.Logging.Level); err == nil {
+		w.log.SetLevel(level)
+	}
+	if levels, err := logger.ParseLevels(updated.Logging.Levels); err == nil {
+		w.log.SetModuleLevels(levels)
+	}
+
+	applied := 0
+	for _, change := range changes {
+		if change.Applied {
+			applied++
+			w.log.Info("Configuration reloaded: %s changed from %s to %s", change.Key, change.Old, change.New)
+		} else {
+			w.log.Warn("Configuration change to %s requires a restart", change.Key)
+		}
+	}
+
+	if applied > 0 && w.onChange != nil {
+		w.onChange(updated, changes)
+	}
+}
+
+// mergeReloadable returns a copy of current with the hot-reloadable settings
+// of loaded, and every setting that differs between the two
+func mergeReloadable(current *models.Config, loaded *models.Config) (*models.Config, []Change) {
+	before := flattenSettings(current)
+	after := flattenSettings(loaded)
+
+	keys := make(map[string]bool)
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+
+	var changes []Change
+	for key := range keys {
+		if before[key] == after[key] {
+			continue
+		}
+		change := Change{Key: key, Old: before[key], New: after[key], Applied: isHotReloadable(key)}
+		if strings.Contains(key, "password") {
+			change.Old, change.New = "(hidden)", "(hidden)"
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+
+	updated := *current
+	updated.Posting.SubjectTemplate = loaded.Posting.SubjectTemplate
+	updated.Posting.SubjectComment = loaded.Posting.SubjectComment
+	updated.Posting.SubjectQuote = loaded.Posting.SubjectQuote
+	updated.Posting.SubjectBrackets = loaded.Posting.SubjectBrackets
+	updated.Posting.CustomHeaders = loaded.Posting.CustomHeaders
+	updated.Posting.XNoArchive = loaded.Posting.XNoArchive
+	updated.Posting.Organization = loaded.Posting.Organization
+	updated.Posting.XNewsposter = loaded.Posting.XNewsposter
+	updated.Par2.Redundancy = loaded.Par2.Redundancy
+	updated.NZB = loaded.NZB
+	updated.Obfuscation = loaded.Obfuscation
+	updated.Schedule = loaded.Schedule
+	updated.Groups = loaded.Groups
+	updated.Logging.Level = loaded.Logging.Level
+	updated.Logging.Levels = loaded.Logging.Levels
+	return &updated, changes
+}
+
+// isHotReloadable reports whether a flattened key is applied on reload
+func isHotReloadable(key string) bool {
+	for _, setting := range hotReloadable {
+		if key == setting || strings.HasPrefix(key, setting+".") || strings.HasPrefix(key, setting+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// flattenSettings renders a configuration as dotted keys and printable values
+func flattenSettings(config *models.Config) map[string]string {
+	flat := make(map[string]string)
+	var walk func(prefix string, value interface{})
+	walk = func(prefix string, value interface{}) {
+		switch typed := value.(type) {
+		case map[string]interface{}:
+			for key, item := range typed {
+				if prefix != "" {
+					key = prefix + "." + key
+				}
+				walk(key, item)
+			}
+		case []interface{}:
+			for i, item := range typed {
+				walk(fmt.Sprintf("%s[%d]", prefix, i), item)
+			}
+		default:
+			flat[prefix] = fmt.Sprintf("%v", typed)
+		}
+	}
+	walk("", settingsMap(reflect.ValueOf(config)))
+	return flat
+}
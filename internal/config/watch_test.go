@@ -0,0 +1,47 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestMergeReloadable(t *testing.T) {
+	current := validTestConfig(t)
+	current.NNTP.Servers[0].Password = "old-secret"
+
+	loaded := validTestConfig(t)
+	loaded.Posting.SubjectTemplate = "{{.Filename}} yEnc"
+	loaded.Par2.Redundancy = 20
+	loaded.Output.OutputDir = "/srv/ypost"
+	loaded.NNTP.Servers[0].Password = "new-secret"
+
+	updated, changes := mergeReloadable(current, loaded)
+
+	if updated.Posting.SubjectTemplate != "{{.Filename}} yEnc" || updated.Par2.Redundancy != 20 {
+		t.Errorf("hot-reloadable settings not applied: %+v", updated.Posting)
+	}
+	if updated.Output.OutputDir != current.Output.OutputDir || updated.NNTP.Servers[0].Password != "old-secret" {
+		t.Error("restart-only settings were applied")
+	}
+	if current.Par2.Redundancy == 20 {
+		t.Error("current configuration was modified in place")
+	}
+
+	expected := map[string]bool{
+		"nntp.servers[0].password": false,
+		"output.output_dir":        false,
+		"par2.redundancy":          true,
+		"posting.subject_template": true,
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %+v", len(expected), changes)
+	}
+	for _, change := range changes {
+		applied, ok := expected[change.Key]
+		if !ok || applied != change.Applied {
+			t.Errorf("unexpected change %+v", change)
+		}
+		if change.Key == "nntp.servers[0].password" && change.New != "(hidden)" {
+			t.Errorf("password change not hidden: %+v", change)
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"ypost/internal/nntp"
+	"ypost/internal/nzb"
+	"ypost/internal/sfv"
+	"ypost/internal/yenc"
+)
+
+// Conn is a reader connection able to fetch article bodies
+type Conn interface {
+	Body(messageID string) ([]byte, error)
+	Quit() error
+}
+
+// Server is a reader server to download from
+type Server struct {
+	Name string
+	Dial func() (Conn, error)
+}
+
+// Options tune a download
+type Options struct {
+	// OutputDir receives the downloaded files
+	OutputDir string
+	// Connections is the number of articles fetched at once
+	Connections int
+	// Names maps posted (obfuscated) file names to the names to write
+	Names map[string]string
+	// Lengths holds the real length of padded files, by posted name
+	Lengths map[string]int64
+}
+
+// FileResult is the outcome of a downloaded file
+type FileResult struct {
+	Name string `json:"name"`
+	// PostedName is the name the file was posted under when it is written
+	// under another, e.g. the random name of an obfuscated post
+	PostedName string `json:"posted_name,omitempty"`
+	Path       string `json:"path"`
+	Bytes      int64  `json:"bytes"`
+	CRC32      uint32 `json:"crc32"`
+	Segments   int    `json:"segments"`
+	// Missing counts the segments no server had; they are left as zeros
+	// between the =ypart offsets of the others, so PAR2 can repair them
+	Missing int `json:"missing"`
+	// Damaged counts the segments whose yEnc checksum did not match, and the
+	// articles of a file of several that are not placed by a =ypart line
+	Damaged int `json:"damaged"`
+	// FileCRCMismatch is set when the checksum of a multi-part post's trailer
+	// does not match the assembled file
+	FileCRCMismatch bool `json:"file_crc_mismatch,omitempty"`
+}
+
+// Complete reports whether every segment of the file was downloaded intact
+func (f *FileResult) Complete() bool {
+	return f.Missing == 0 && f.Damaged == 0 && !f.FileCRCMismatch
+}
+
+// Result is the outcome of a download
+type Result struct {
+	Files []FileResult `json:"files"`
+	// SFVChecked and SFVMismatched are the files checked against a downloaded
+	// SFV file and those with a different checksum, by name
+	SFVChecked    []string `json:"sfv_checked,omitempty"`
+	SFVMismatched []string `json:"sfv_mismatched,omitempty"`
+}
+
+// OK reports whether every file is complete and matches the SFV
+func (r *Result) OK() bool {
+	for _, file := range r.Files {
+		if !file.Complete() {
+			return false
+		}
+	}
+	return len(r.SFVMismatched) == 0
+}
+
+// fetched is a downloaded segment of the file being assembled
+type fetched struct {
+	index   int
+	part    *yenc.Part
+	damaged bool
+}
+
+// Download fetches, decodes and reassembles every file of doc into the output
+// directory. Each segment is tried on the servers in order. Files are written
+// under a temporary name and renamed once complete; existing files of the
+// same name are replaced. Downloaded SFV files are used to verify the others.
+func Download(ctx context.Context, doc *nzb.NZB, servers []Server, opts Options) (*Result, error) {
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no servers to download from")
+	}
+	if opts.Connections < 1 {
+		opts.Connections = 1
+	}
+	if err := os.MkdirAll(opts.OutputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	pool := newPool(servers)
+	defer pool.closeAll()
+
+	result := &Result{}
+	for i := range doc.Files {
+		file, err := downloadFile(ctx, pool, &doc.Files[i], i, opts)
+		if err != nil {
+			return nil, err
+		}
+		result.Files = append(result.Files, *file)
+	}
+
+	if err := verifySFV(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// downloadFile fetches the segments of one file in parallel and writes them
+// in order, each part of a multi-part post at its =ypart offset
+func downloadFile(ctx context.Context, pool *pool, file *nzb.File, index int, opts Options) (*FileResult, error) {
+	result := &FileResult{Name: file.Name(), Segments: len(file.Segments)}
+	tempPath := filepath.Join(opts.OutputDir, fmt.Sprintf(".ypost-download-%d", index))
+	out, err := os.Create(tempPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", tempPath, err)
+	}
+	defer func() {
+		out.Close()
+		os.Remove(tempPath)
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The window bounds the segments held in memory awaiting their turn
+	window := make(chan struct{}, 2*opts.Connections)
+	indexes := make(chan int)
+	results := make(chan fetched)
+	go func() {
+		defer close(indexes)
+		for i := range file.Segments {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Connections; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				part, damaged := pool.fetch(file.Segments[i].MessageID)
+				select {
+				case results <- fetched{index: i, part: part, damaged: damaged}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var fileCRC *uint32
+	named := false
+	// size is the file size the yEnc headers give
+	var size int64
+	pending := make(map[int]fetched)
+	next := 0
+	for next < len(file.Segments) {
+		var segment fetched
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case item, ok := <-results:
+			if !ok {
+				return nil, ctx.Err()
+			}
+			segment = item
+		}
+		pending[segment.index] = segment
+
+		for {
+			segment, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			<-window
+
+			switch {
+			case segment.part == nil:
+				// The parts around it, written at their offsets, leave the gap
+				result.Missing++
+			case segment.part.Begin == 0 && len(file.Segments) > 1:
+				// An article of its own has nowhere to go among several
+				result.Damaged++
+			default:
+				if segment.damaged {
+					result.Damaged++
+				}
+				// The yEnc header carries the posted name, the subject may not
+				if !named && segment.part.Name != "" {
+					result.Name, named = segment.part.Name, true
+				}
+				if segment.part.HasFileCRC {
+					crc := segment.part.FileCRC32
+					fileCRC = &crc
+				}
+				var at int64
+				if segment.part.Begin > 0 {
+					at = segment.part.Begin - 1
+				}
+				size = max(segment.part.Size, at+int64(len(segment.part.Data)))
+				if _, err := out.WriteAt(segment.part.Data, at); err != nil {
+					return nil, fmt.Errorf("failed to write %s: %w", tempPath, err)
+				}
+			}
+			next++
+		}
+	}
+
+	// Missing parts at the end are zeros up to the size of the file
+	if err := out.Truncate(size); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", tempPath, err)
+	}
+
+	if fileCRC != nil && result.Missing == 0 {
+		crc, err := fileChecksum(out)
+		if err != nil {
+			return nil, err
+		}
+		result.FileCRCMismatch = crc != *fileCRC
+	}
+
+	// Padded files are cut back to their real length
+	if length, ok := opts.Lengths[result.Name]; ok {
+		if err := out.Truncate(length); err != nil {
+			return nil, fmt.Errorf("failed to truncate %s: %w", result.Name, err)
+		}
+	}
+	if name, ok := opts.Names[result.Name]; ok {
+		result.PostedName, result.Name = result.Name, name
+	}
+
+	result.Path = filepath.Join(opts.OutputDir, safeName(result.Name))
+	if err := os.MkdirAll(filepath.Dir(result.Path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", result.Name, err)
+	}
+	if result.CRC32, err = fileChecksum(out); err != nil {
+		return nil, err
+	}
+	info, err := out.Stat()
+	if err != nil {
+		return nil, err
+	}
+	result.Bytes = info.Size()
+	if err := out.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", result.Name, err)
+	}
+	if err := os.Rename(tempPath, result.Path); err != nil {
+		return nil, fmt.Errorf("failed to move %s into place: %w", result.Name, err)
+	}
+	return result, nil
+}
+
+// fileChecksum returns the CRC32 of an open file
+func fileChecksum(file *os.File) (uint32, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	hash := crc32.NewIEEE()
+	if _, err := io.Copy(hash, file); err != nil {
+		return 0, fmt.Errorf("failed to checksum %s: %w", file.Name(), err)
+	}
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		return 0, err
+	}
+	return hash.Sum32(), nil
+}
+
+// safeName turns a posted name into a relative path that stays inside the
+// output directory
+func safeName(name string) string {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return filepath.Base(clean)
+	}
+	return clean
+}
+
+// verifySFV checks the downloaded files against the downloaded SFV files
+func verifySFV(result *Result) error {
+	checksums := make(map[string]uint32)
+	for _, file := range result.Files {
+		checksums[filepath.ToSlash(file.Name)] = file.CRC32
+		checksums[filepath.Base(file.Name)] = file.CRC32
+		// An obfuscated post lists its files under the names they were
+		// posted as
+		if file.PostedName != "" {
+			checksums[filepath.ToSlash(file.PostedName)] = file.CRC32
+		}
+	}
+
+	for _, file := range result.Files {
+		if !strings.EqualFold(filepath.Ext(file.Name), ".sfv") || !file.Complete() {
+			continue
+		}
+		entries, err := sfv.NewGenerator(filepath.Dir(file.Path)).ReadSFV(file.Path)
+		if err != nil {
+			return err
+		}
+		for name, expected := range entries {
+			actual, ok := checksums[filepath.ToSlash(name)]
+			if !ok {
+				continue
+			}
+			result.SFVChecked = append(result.SFVChecked, name)
+			if !strings.EqualFold(fmt.Sprintf("%08X", actual), expected) {
+				result.SFVMismatched = append(result.SFVMismatched, name)
+			}
+		}
+	}
+	return nil
+}
+
+// pool keeps idle connections to each server
+type pool struct {
+	servers []Server
+	mu      sync.Mutex
+	idle    [][]Conn
+}
+
+func newPool(servers []Server) *pool {
+	return &pool{servers: servers, idle: make([][]Conn, len(servers))}
+}
+
+// fetch downloads and decodes a segment, trying each server in order. A
+// damaged copy is kept only when no server has an intact one; nil means no
+// server had the article.
+func (p *pool) fetch(messageID string) (*yenc.Part, bool) {
+	var damaged *yenc.Part
+	for i := range p.servers {
+		body, err := p.body(i, messageID)
+		if err != nil {
+			continue
+		}
+		part, err := yenc.DecodePart(body)
+		if err == nil {
+			return part, false
+		}
+		if errors.Is(err, yenc.ErrCRCMismatch) && damaged == nil {
+			damaged = part
+		}
+	}
+	return damaged, damaged != nil
+}
+
+// body fetches an article from one server, reconnecting once when the
+// connection fails
+func (p *pool) body(server int, messageID string) ([]byte, error) {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		var conn Conn
+		if conn, err = p.get(server); err != nil {
+			continue
+		}
+		var body []byte
+		body, err = conn.Body(messageID)
+		if err == nil || errors.Is(err, nntp.ErrNoArticle) {
+			p.put(server, conn)
+			return body, err
+		}
+		conn.Quit()
+	}
+	return nil, err
+}
+
+func (p *pool) get(server int) (Conn, error) {
+	p.mu.Lock()
+	if idle := p.idle[server]; len(idle) > 0 {
+		conn := idle[len(idle)-1]
+		p.idle[server] = idle[:len(idle)-1]
+		p.mu.Unlock()
+		return conn, nil
+	}
+	p.mu.Unlock()
+	return p.servers[server].Dial()
+}
+
+func (p *pool) put(server int, conn Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idle[server] = append(p.idle[server], conn)
+}
+
+func (p *pool) closeAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, idle := range p.idle {
+		for _, conn := range idle {
+			conn.Quit()
+		}
+		p.idle[i] = nil
+	}
+}
@@ -0,0 +1,194 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ypost/internal/nntp"
+	"ypost/internal/nzb"
+	"ypost/internal/yenc"
+)
+
+// fakeConn serves article bodies from a map
+type fakeConn struct {
+	articles map[string][]byte
+}
+
+func (c *fakeConn) Body(messageID string) ([]byte, error) {
+	body, ok := c.articles[messageID]
+	if !ok {
+		return nil, nntp.ErrNoArticle
+	}
+	return body, nil
+}
+
+func (c *fakeConn) Quit() error { return nil }
+
+// post posts data in chunks as a multi-part yEnc post, or an article of its
+// own when it fits one, and returns the NZB file entry, which gives the size
+// of the encoded articles
+func post(articles map[string][]byte, name string, data []byte, chunkSize int) nzb.File {
+	file := nzb.File{Subject: fmt.Sprintf(`"%s" yEnc`, name)}
+	total := (len(data) + chunkSize - 1) / chunkSize
+	for i := 0; i < total; i++ {
+		begin, end := i*chunkSize, min((i+1)*chunkSize, len(data))
+		encoder := &yenc.Encoder{}
+		article := encoder.Encode(data[begin:end], yenc.Article{
+			Name: name, Number: i + 1, Total: total, FileSize: int64(len(data)), Offset: int64(begin),
+			FileCRC32: crc32.ChecksumIEEE(data), HasFileCRC: true,
+		})
+		id := fmt.Sprintf("%s-%d@test", name, i+1)
+		articles[id] = []byte(strings.ReplaceAll(article, "\r\n", "\n"))
+		file.Segments = append(file.Segments, nzb.Segment{Number: i + 1, MessageID: id, Bytes: int64(len(article))})
+	}
+	return file
+}
+
+func servers(articles ...map[string][]byte) []Server {
+	var list []Server
+	for i, set := range articles {
+		set := set
+		list = append(list, Server{Name: fmt.Sprintf("server%d", i), Dial: func() (Conn, error) {
+			return &fakeConn{articles: set}, nil
+		}})
+	}
+	return list
+}
+
+func TestDownloadRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef="), 100)
+	padded := append([]byte("short file"), make([]byte, 22)...)
+
+	articles := make(map[string][]byte)
+	doc := &nzb.NZB{Files: []nzb.File{
+		post(articles, "data.bin", data, 128),
+		post(articles, "ABC123", padded, 16),
+	}}
+	// A file renamed after download is checked under its real and its posted name
+	shortCRC := crc32.ChecksumIEEE([]byte("short file"))
+	sfvContent := fmt.Sprintf("data.bin %08X\nreal.txt %08X\nABC123 %08X\n", crc32.ChecksumIEEE(data), shortCRC, shortCRC)
+	doc.Files = append(doc.Files, post(articles, "data.sfv", []byte(sfvContent), 1024))
+
+	outputDir := t.TempDir()
+	result, err := Download(context.Background(), doc, servers(articles), Options{
+		OutputDir:   outputDir,
+		Connections: 4,
+		Names:       map[string]string{"ABC123": "real.txt"},
+		Lengths:     map[string]int64{"ABC123": 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.OK() {
+		t.Fatalf("expected a complete download, got %+v", result)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "data.bin"))
+	if err != nil || !bytes.Equal(content, data) {
+		t.Errorf("data.bin differs from the posted data (%v)", err)
+	}
+	content, err = os.ReadFile(filepath.Join(outputDir, "real.txt"))
+	if err != nil || string(content) != "short file" {
+		t.Errorf("unexpected real.txt content %q (%v)", content, err)
+	}
+	if len(result.SFVChecked) != 3 {
+		t.Errorf("expected 3 files checked against the SFV, got %v", result.SFVChecked)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(outputDir, ".ypost-download-*")); len(leftovers) > 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestDownloadFailsOverAndReportsMissing(t *testing.T) {
+	data := bytes.Repeat([]byte{0, 1, 2, 3}, 64)
+	articles := make(map[string][]byte)
+	doc := &nzb.NZB{Files: []nzb.File{post(articles, "data.bin", data, 64)}}
+
+	// The first server lacks segment 2, no server has segment 4
+	first := make(map[string][]byte)
+	second := make(map[string][]byte)
+	for id, body := range articles {
+		if id != "data.bin-2@test" {
+			first[id] = body
+		}
+		if id != "data.bin-4@test" {
+			second[id] = body
+		}
+	}
+	delete(first, "data.bin-4@test")
+
+	outputDir := t.TempDir()
+	result, err := Download(context.Background(), doc, servers(first, second), Options{OutputDir: outputDir, Connections: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := result.Files[0]
+	if file.Missing != 1 || file.Damaged != 0 || result.OK() {
+		t.Fatalf("expected one missing segment, got %+v", file)
+	}
+
+	// The missing segment is written as zeros so the size is kept
+	content, err := os.ReadFile(file.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := append(append([]byte{}, data[:192]...), make([]byte, 64)...)
+	if !bytes.Equal(content, expected) {
+		t.Error("unexpected content for a download with a missing segment")
+	}
+}
+
+func TestDownloadPlacesPartsAtTheirOffsets(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	articles := make(map[string][]byte)
+	doc := &nzb.NZB{Files: []nzb.File{post(articles, "data.bin", data, 300)}}
+	// A part in the middle and the last one are missing
+	delete(articles, "data.bin-2@test")
+	delete(articles, "data.bin-4@test")
+	// An article of its own in place of the first part has no offset
+	articles["data.bin-1@test"] = []byte((&yenc.Encoder{}).Encode(data[:300], yenc.Article{Name: "data.bin"}))
+
+	result, err := Download(context.Background(), doc, servers(articles), Options{OutputDir: t.TempDir(), Connections: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := result.Files[0]
+	if file.Missing != 2 || file.Damaged != 1 {
+		t.Fatalf("expected two missing segments and a damaged one, got %+v", file)
+	}
+
+	// The encoded sizes of the NZB do not shift the parts or the length
+	content, err := os.ReadFile(file.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := append([]byte{}, data...)
+	copy(expected[:600], make([]byte, 600))
+	copy(expected[900:], make([]byte, 100))
+	if len(content) != len(data) || !bytes.Equal(content, expected) {
+		t.Errorf("expected the parts at their offsets in %d bytes, got %d bytes", len(data), len(content))
+	}
+}
+
+func TestSafeName(t *testing.T) {
+	tests := map[string]string{
+		"file.bin":         "file.bin",
+		"dir/file.bin":     filepath.Join("dir", "file.bin"),
+		"../../etc/passwd": "passwd",
+		"/abs/file.bin":    "file.bin",
+	}
+	for name, expected := range tests {
+		if actual := safeName(name); actual != expected {
+			t.Errorf("%q: expected %q, got %q", name, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"ypost/pkg/models"
+)
+
+// Kind is the kind of an event
+type Kind string
+
+// Kinds of events: a post starts, posts its articles, then completes or fails
+const (
+	PostStarted   Kind = "post_started"
+	SegmentPosted Kind = "segment_posted"
+	// SegmentFailed is an article every server refused
+	SegmentFailed Kind = "segment_failed"
+	PostCompleted Kind = "post_completed"
+	// Error is the end of a failed post
+	Error Kind = "error"
+)
+
+// Event is something that happened to a post
+type Event struct {
+	Kind   Kind
+	PostID string
+	// File and Size are the name and size of the posted file or directory
+	File string
+	Size int64
+	// Segment is the article posted, for SegmentPosted, or refused, for
+	// SegmentFailed
+	Segment *models.PostSegment
+	// Record is the outcome of the post, for PostCompleted and Error
+	Record *models.PostingHistory
+	// Err is why the article or the post failed, for SegmentFailed and
+	// Error
+	Err  error
+	Time time.Time
+}
+
+// Handler receives the events it subscribed to
+type Handler func(Event)
+
+// subscription is a handler and the kinds it receives, all when empty
+type subscription struct {
+	handler Handler
+	kinds   []Kind
+}
+
+// wants reports whether the subscription receives events of kind
+func (s *subscription) wants(kind Kind) bool {
+	if len(s.kinds) == 0 {
+		return true
+	}
+	for _, candidate := range s.kinds {
+		if candidate == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Bus delivers the events published to its subscribers
+type Bus struct {
+	mu            sync.RWMutex
+	subscriptions []*subscription
+}
+
+// NewBus creates a bus without subscribers
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe calls handler for every event of the given kinds, or of every
+// kind when none is given, until the returned function is called
+func (b *Bus) Subscribe(handler Handler, kinds ...Kind) func() {
+	sub := &subscription{handler: handler, kinds: kinds}
+	b.mu.Lock()
+	b.subscriptions = append(b.subscriptions, sub)
+	b.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			for i, candidate := range b.subscriptions {
+				if candidate == sub {
+					b.subscriptions = append(b.subscriptions[:i:i], b.subscriptions[i+1:]...)
+					break
+				}
+			}
+		})
+	}
+}
+
+// Publish calls the handlers subscribed to the kind of event, in the order
+// they subscribed, before returning. Handlers may subscribe and unsubscribe;
+// the change applies from the next event.
+func (b *Bus) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	b.mu.RLock()
+	subscriptions := b.subscriptions
+	b.mu.RUnlock()
+	for _, sub := range subscriptions {
+		if sub.wants(event.Kind) {
+			sub.handler(event)
+		}
+	}
+}
+
+// defaultBus is the bus of the process
+var defaultBus = NewBus()
+
+// Subscribe subscribes handler to the bus of the process
+func Subscribe(handler Handler, kinds ...Kind) func() {
+	return defaultBus.Subscribe(handler, kinds...)
+}
+
+// Publish publishes an event on the bus of the process
+func Publish(event Event) {
+	defaultBus.Publish(event)
+}
+
+// ForPost returns a handler passing the events of post id to handler, for
+// subscribers of one post among concurrent ones
+func ForPost(id string, handler Handler) Handler {
+	return func(event Event) {
+		if event.PostID == id {
+			handler(event)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package events
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"ypost/pkg/models"
+)
+
+func TestBusDelivery(t *testing.T) {
+	bus := NewBus()
+	var all, ends []Kind
+	unsubscribeAll := bus.Subscribe(func(event Event) {
+		all = append(all, event.Kind)
+	})
+	bus.Subscribe(func(event Event) {
+		if event.Time.IsZero() {
+			t.Error("expected the publishing time")
+		}
+		ends = append(ends, event.Kind)
+	}, PostCompleted, Error)
+
+	bus.Publish(Event{Kind: PostStarted})
+	bus.Publish(Event{Kind: SegmentPosted, Segment: &models.PostSegment{PartNumber: 1}})
+	bus.Publish(Event{Kind: Error, Err: errors.New("refused")})
+	unsubscribeAll()
+	unsubscribeAll()
+	bus.Publish(Event{Kind: PostCompleted})
+
+	if expected := []Kind{PostStarted, SegmentPosted, Error}; !reflect.DeepEqual(all, expected) {
+		t.Errorf("expected %v, got %v", expected, all)
+	}
+	if expected := []Kind{Error, PostCompleted}; !reflect.DeepEqual(ends, expected) {
+		t.Errorf("expected %v, got %v", expected, ends)
+	}
+}
+
+func TestForPost(t *testing.T) {
+	received := 0
+	unsubscribe := Subscribe(ForPost("a", func(event Event) {
+		if event.PostID != "a" {
+			t.Errorf("received an event of post %q", event.PostID)
+		}
+		received++
+	}), SegmentPosted)
+	defer unsubscribe()
+
+	Publish(Event{Kind: SegmentPosted, PostID: "a"})
+	Publish(Event{Kind: SegmentPosted, PostID: "b"})
+	Publish(Event{Kind: PostStarted, PostID: "a"})
+	if received != 1 {
+		t.Errorf("expected 1 event, got %d", received)
+	}
+}
+
+func TestSubscribeFromHandler(t *testing.T) {
+	bus := NewBus()
+	late := 0
+	bus.Subscribe(func(event Event) {
+		if event.Kind == PostStarted {
+			bus.Subscribe(func(Event) { late++ })
+		}
+	})
+	bus.Publish(Event{Kind: PostStarted})
+	if late != 0 {
+		t.Error("a subscription made while publishing received that event")
+	}
+	bus.Publish(Event{Kind: PostCompleted})
+	if late != 1 {
+		t.Errorf("expected the later event, got %d", late)
+	}
+}
@@ -0,0 +1,54 @@
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// hashSample is the size of each of the samples of a file read by
+// ContentHash: its start, middle and end
+const hashSample = 1 << 20
+
+// ContentHash returns a fast hash of the content of files, in that order:
+// their sizes and samples of their start, middle and end, files of up to
+// three samples being hashed whole. It tells apart posts whatever the names
+// of their files, reading a few megabytes of each.
+func ContentHash(paths []string) (string, error) {
+	hash := sha256.New()
+	for _, path := range paths {
+		if err := hashFile(hash, path); err != nil {
+			return "", fmt.Errorf("failed to hash %s: %w", path, err)
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashFile adds the size and samples of a file to hash
+func hashFile(hash io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	binary.Write(hash, binary.LittleEndian, size)
+
+	if size <= 3*hashSample {
+		_, err := io.Copy(hash, file)
+		return err
+	}
+	for _, offset := range []int64{0, size/2 - hashSample/2, size - hashSample} {
+		if _, err := io.Copy(hash, io.NewSectionReader(file, offset, hashSample)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ypost/pkg/models"
+)
+
+// Store is the posting history, kept in an SQLite database or in a file of
+// JSON lines. Posts are only ever appended.
+type Store struct {
+	backend backend
+}
+
+// backend keeps the posts of a Store
+type backend interface {
+	add(record *models.PostingHistory) error
+	// find returns the posts matching q, newest first
+	find(q Query) ([]*models.PostingHistory, error)
+	close() error
+}
+
+// Query selects posts; zero fields match every post
+type Query struct {
+	ID int64
+	// PostID matches the post of a posting run, as named in its log lines
+	PostID string
+	// Term matches the posts whose file name, NZB path or groups contain it,
+	// case-insensitively
+	Term string
+	// File and Size match the posts of a file exactly
+	File string
+	Size int64
+	// ContentHash matches the posts of the same content, as ContentHash
+	// returns it
+	ContentHash string
+	// Since and Until bound the posting time, Until excluded
+	Since time.Time
+	Until time.Time
+	// Success keeps the successful posts when true, the failed ones when false
+	Success *bool
+	// Limit bounds the posts returned, the newest ones; 0 returns all of them
+	Limit int
+}
+
+// Open opens the history at path, creating it if needed: a path ending in
+// .jsonl is a file of JSON lines, anything else an SQLite database
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	var b backend
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".jsonl") {
+		b, err = openJSONL(path)
+	} else {
+		b, err = openSQLite(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Store{backend: b}, nil
+}
+
+// Close closes the history
+func (s *Store) Close() error {
+	return s.backend.close()
+}
+
+// Add records a post and sets its ID
+func (s *Store) Add(record *models.PostingHistory) error {
+	if err := s.backend.add(record); err != nil {
+		return fmt.Errorf("failed to record post: %w", err)
+	}
+	return nil
+}
+
+// Find returns the posts matching q, newest first
+func (s *Store) Find(q Query) ([]*models.PostingHistory, error) {
+	records, err := s.backend.find(q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return records, nil
+}
+
+// Get returns a post by ID
+func (s *Store) Get(id int64) (*models.PostingHistory, error) {
+	records, err := s.Find(Query{ID: id, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("post %d not found", id)
+	}
+	return records[0], nil
+}
+
+// GetPost returns the post of a posting run by its post ID
+func (s *Store) GetPost(postID string) (*models.PostingHistory, error) {
+	records, err := s.Find(Query{PostID: postID, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("post %s not found", postID)
+	}
+	return records[0], nil
+}
+
+// List returns the latest posts, newest first; limit 0 returns all of them
+func (s *Store) List(limit int) ([]*models.PostingHistory, error) {
+	return s.Find(Query{Limit: limit})
+}
+
+// Search returns the posts whose file name, NZB path or groups contain term
+// (case-insensitive), newest first; limit 0 returns all of them
+func (s *Store) Search(term string, limit int) ([]*models.PostingHistory, error) {
+	return s.Find(Query{Term: term, Limit: limit})
+}
+
+// Posted returns the latest successful post of a file of that name and
+// size, or nil when it was never posted
+func (s *Store) Posted(name string, size int64) (*models.PostingHistory, error) {
+	success := true
+	records, err := s.Find(Query{File: name, Size: size, Success: &success, Limit: 1})
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return records[0], nil
+}
+
+// PostedContent returns the latest successful post of the content of hash
+// since a time, any time when zero, or nil when there is none
+func (s *Store) PostedContent(hash string, since time.Time) (*models.PostingHistory, error) {
+	success := true
+	records, err := s.Find(Query{ContentHash: hash, Since: since, Success: &success, Limit: 1})
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return records[0], nil
+}
+
+// matches reports whether a post is selected by q, the filter of backends
+// without queries of their own
+func (q Query) matches(record *models.PostingHistory) bool {
+	if q.ID != 0 && record.ID != q.ID {
+		return false
+	}
+	if q.PostID != "" && record.PostID != q.PostID {
+		return false
+	}
+	if q.Term != "" {
+		term := strings.ToLower(q.Term)
+		if !strings.Contains(strings.ToLower(record.FileName), term) &&
+			!strings.Contains(strings.ToLower(record.NZBPath), term) &&
+			!strings.Contains(strings.ToLower(strings.Join(record.Groups, ",")), term) {
+			return false
+		}
+	}
+	if q.File != "" && record.FileName != q.File {
+		return false
+	}
+	if q.Size != 0 && record.FileSize != q.Size {
+		return false
+	}
+	if q.ContentHash != "" && record.ContentHash != q.ContentHash {
+		return false
+	}
+	if !q.Since.IsZero() && record.PostedAt.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !record.PostedAt.Before(q.Until) {
+		return false
+	}
+	if q.Success != nil && record.Success != *q.Success {
+		return false
+	}
+	return true
+}
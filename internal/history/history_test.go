@@ -0,0 +1,321 @@
+package history
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ypost/pkg/models"
+)
+
+func TestStoreAddGetList(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	postedAt := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	first := &models.PostingHistory{
+		FileName: "first.iso", FileSize: 1000, Groups: []string{"alt.binaries.test", "alt.binaries.misc"},
+		NZBPath: "/out/first.iso.nzb", MessageIDs: 12, PostedAt: postedAt, Duration: 1500 * time.Millisecond, Success: true,
+	}
+	second := &models.PostingHistory{
+		FileName: "second_100%.bin", FileSize: 5, Groups: []string{"alt.binaries.test"},
+		PostedAt: postedAt.Add(time.Minute), Error: "connection refused",
+	}
+	for _, record := range []*models.PostingHistory{first, second} {
+		if err := store.Add(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if first.ID == 0 || second.ID == first.ID {
+		t.Fatalf("unexpected IDs %d, %d", first.ID, second.ID)
+	}
+
+	got, err := store.Get(first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.FileName != first.FileName || len(got.Groups) != 2 || got.MessageIDs != 12 ||
+		!got.PostedAt.Equal(postedAt) || got.Duration != first.Duration || !got.Success {
+		t.Errorf("unexpected record %+v", got)
+	}
+	if _, err := store.Get(999); err == nil {
+		t.Error("expected an error for an unknown ID")
+	}
+
+	records, err := store.List(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].ID != second.ID || records[1].Error != "" {
+		t.Errorf("expected newest first, got %+v", records)
+	}
+	if records, _ := store.List(1); len(records) != 1 {
+		t.Errorf("expected 1 record with a limit, got %d", len(records))
+	}
+}
+
+func TestStoreSearch(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	for _, name := range []string{"Movie.2024.mkv", "movie_extras.zip", "music.flac"} {
+		if err := store.Add(&models.PostingHistory{FileName: name, Groups: []string{"alt.binaries.test"}, PostedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string]int{"movie": 2, "MUSIC": 1, "_extras": 1, "%": 0, "alt.binaries": 3}
+	for term, expected := range tests {
+		records, err := store.Search(term, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != expected {
+			t.Errorf("%q: expected %d matches, got %d", term, expected, len(records))
+		}
+	}
+}
+
+func TestOpenMigratesOldHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE posts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, file_name TEXT NOT NULL, file_size INTEGER NOT NULL,
+		groups TEXT NOT NULL, nzb_path TEXT NOT NULL, message_ids INTEGER NOT NULL, posted_at INTEGER NOT NULL,
+		duration_ms INTEGER NOT NULL, success INTEGER NOT NULL, error TEXT NOT NULL);
+		INSERT INTO posts (file_name, file_size, groups, nzb_path, message_ids, posted_at, duration_ms, success, error)
+		VALUES ('old.bin', 1, 'alt.binaries.test', '', 1, 0, 0, 1, '')`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.Add(&models.PostingHistory{FileName: "new.bin", PostedAt: time.Now(), Server: "news.example.com", Retries: 2, Failed: 3}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := store.List(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Server != "news.example.com" || records[0].Retries != 2 || records[0].Failed != 3 || records[1].Server != "" {
+		t.Errorf("unexpected records after migration %+v, %+v", records[0], records[1])
+	}
+}
+
+func TestStats(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	today := time.Now().Truncate(time.Millisecond)
+	yesterday := today.AddDate(0, 0, -1)
+	records := []*models.PostingHistory{
+		{FileName: "a", FileSize: 3000, MessageIDs: 3, PostedAt: today, Duration: time.Second, Success: true, Server: "a.example.com", Retries: 1},
+		{FileName: "b", FileSize: 1000, MessageIDs: 1, PostedAt: today, Duration: time.Second, Success: true, Server: "b.example.com"},
+		{FileName: "c", FileSize: 500, PostedAt: yesterday, Duration: time.Second, Server: "a.example.com", Retries: 4, Error: "refused"},
+		{FileName: "d", FileSize: 500, PostedAt: today.AddDate(0, 0, -10), Success: true},
+	}
+	for _, record := range records {
+		if err := store.Add(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := store.Stats(yesterday.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := stats.Total
+	if total.Posts != 3 || total.Failed != 1 || total.Bytes != 4000 || total.Articles != 4 || total.Retries != 5 {
+		t.Errorf("unexpected totals %+v", total)
+	}
+	if total.BytesPerSecond != 2000 || total.FailureRate < 33.3 || total.FailureRate > 33.4 {
+		t.Errorf("unexpected rates %+v", total)
+	}
+	if len(stats.Days) != 2 || stats.Days[0].Day != today.Format("2006-01-02") || stats.Days[0].Posts != 2 {
+		t.Errorf("expected 2 days, newest first, got %+v", stats.Days)
+	}
+	if len(stats.Servers) != 2 || stats.Servers[0].Server != "a.example.com" || stats.Servers[0].Failed != 1 || stats.Servers[0].Retries != 5 {
+		t.Errorf("unexpected servers %+v", stats.Servers)
+	}
+
+	if all, err := store.Stats(time.Time{}); err != nil || all.Total.Posts != 4 || all.Servers[0].Server != "" {
+		t.Errorf("expected every post with a zero since, got %+v, %v", all, err)
+	}
+}
+
+func TestFindOnEveryBackend(t *testing.T) {
+	for _, name := range []string{"history.db", "history.jsonl"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			store, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+
+			day := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+			for i, record := range []*models.PostingHistory{
+				{FileName: "a.iso", FileSize: 100, Groups: []string{"alt.binaries.test"}, PostedAt: day, Success: true, ContentHash: "cafe"},
+				{FileName: "a.iso", FileSize: 100, Groups: []string{"alt.binaries.test"}, PostedAt: day.Add(time.Hour), Error: "refused", PostID: "0123456789abcdef", ContentHash: "cafe"},
+				{FileName: "b.MKV", FileSize: 200, Groups: []string{"alt.binaries.hdtv"}, PostedAt: day.Add(24 * time.Hour), Success: true, ContentHash: "beef", RoundTrip: models.RoundTripPassed},
+			} {
+				if err := store.Add(record); err != nil {
+					t.Fatal(err)
+				}
+				if record.ID != int64(i+1) {
+					t.Fatalf("expected ID %d, got %d", i+1, record.ID)
+				}
+			}
+
+			failed := false
+			tests := []struct {
+				query    Query
+				expected []int64
+			}{
+				{Query{}, []int64{3, 2, 1}},
+				{Query{ID: 2}, []int64{2}},
+				{Query{PostID: "0123456789abcdef"}, []int64{2}},
+				{Query{Term: "mkv"}, []int64{3}},
+				{Query{Term: "hdtv"}, []int64{3}},
+				{Query{File: "a.iso", Size: 100}, []int64{2, 1}},
+				{Query{File: "a.is"}, nil},
+				{Query{ContentHash: "cafe"}, []int64{2, 1}},
+				{Query{Since: day.Add(time.Hour), Until: day.Add(24 * time.Hour)}, []int64{2}},
+				{Query{Success: &failed}, []int64{2}},
+				{Query{Limit: 2}, []int64{3, 2}},
+			}
+			for _, test := range tests {
+				records, err := store.Find(test.query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var ids []int64
+				for _, record := range records {
+					ids = append(ids, record.ID)
+				}
+				if fmt.Sprint(ids) != fmt.Sprint(test.expected) {
+					t.Errorf("%+v: expected posts %v, got %v", test.query, test.expected, ids)
+				}
+			}
+
+			posted, err := store.Posted("a.iso", 100)
+			if err != nil || posted == nil || posted.ID != 1 {
+				t.Errorf("expected the successful post of a.iso, got %+v (%v)", posted, err)
+			}
+			if record, err := store.GetPost("0123456789abcdef"); err != nil || record.ID != 2 || record.PostID != "0123456789abcdef" {
+				t.Errorf("expected post 2 by its post ID, got %+v (%v)", record, err)
+			}
+			if record, err := store.Get(3); err != nil || record.RoundTrip != models.RoundTripPassed {
+				t.Errorf("expected the round trip of post 3, got %+v (%v)", record, err)
+			}
+			if posted, err := store.Posted("a.iso", 101); err != nil || posted != nil {
+				t.Errorf("expected no post of another size, got %+v (%v)", posted, err)
+			}
+
+			// Only successful posts of the content count, within the window
+			if posted, err := store.PostedContent("cafe", time.Time{}); err != nil || posted == nil || posted.ID != 1 || posted.ContentHash != "cafe" {
+				t.Errorf("expected the successful post of the content, got %+v (%v)", posted, err)
+			}
+			if posted, err := store.PostedContent("cafe", day.Add(time.Minute)); err != nil || posted != nil {
+				t.Errorf("expected no post of the content since, got %+v (%v)", posted, err)
+			}
+		})
+	}
+}
+
+func TestJSONLSkipsTornLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	data := `{"file_name":"a.iso","posted_at":"2024-05-01T12:00:00Z","success":true}` + "\n\n" + `{"file_name":"b.i`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	records, err := store.List(0)
+	if err != nil || len(records) != 1 || records[0].FileName != "a.iso" {
+		t.Fatalf("expected the complete line only, got %+v (%v)", records, err)
+	}
+
+	// The torn line keeps its ID and does not swallow the next post
+	record := &models.PostingHistory{FileName: "c.iso", PostedAt: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)}
+	if err := store.Add(record); err != nil {
+		t.Fatal(err)
+	}
+	records, err = store.List(0)
+	if err != nil || len(records) != 2 || records[0].FileName != "c.iso" || records[0].ID != 3 || record.ID != 3 {
+		t.Fatalf("expected c.iso as post 3, got %+v (%v)", records, err)
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	large := bytes.Repeat([]byte("0123456789abcdef"), 4*hashSample/16)
+	small := write("small.bin", []byte("small file"))
+	original := write("large.bin", large)
+	renamed := write("renamed.bin", large)
+
+	// The middle of a large file is sampled, a byte outside the samples and
+	// the size unchanged is not seen
+	changed := append([]byte(nil), large...)
+	changed[len(changed)/2] ^= 0xff
+	middle := write("middle.bin", changed)
+	changed = append([]byte(nil), large...)
+	changed[len(changed)/4] ^= 0xff
+	unsampled := write("unsampled.bin", changed)
+
+	hash := func(paths ...string) string {
+		t.Helper()
+		sum, err := ContentHash(paths)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sum
+	}
+	if hash(original) != hash(renamed) {
+		t.Error("expected the same hash under another name")
+	}
+	if hash(original) == hash(middle) {
+		t.Error("expected another hash for a change in the middle")
+	}
+	if hash(original) != hash(unsampled) {
+		t.Error("expected a change outside the samples to keep the hash")
+	}
+	if hash(small, original) == hash(original, small) || hash(small) == hash(original) {
+		t.Error("expected the hash to depend on the files and their order")
+	}
+	if _, err := ContentHash([]string{filepath.Join(dir, "missing.bin")}); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
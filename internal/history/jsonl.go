@@ -0,0 +1,146 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"ypost/pkg/models"
+)
+
+// jsonlBackend keeps the posts in a file of JSON lines, one post per line.
+// The ID of a post is its line number, blank lines aside, as lines are only
+// appended.
+type jsonlBackend struct {
+	mu   sync.Mutex
+	path string
+}
+
+// openJSONL opens the file at path, creating it if needed
+func openJSONL(path string) (*jsonlBackend, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history %s: %w", path, err)
+	}
+	file.Close()
+	return &jsonlBackend{path: path}, nil
+}
+
+func (b *jsonlBackend) close() error {
+	return nil
+}
+
+func (b *jsonlBackend) add(record *models.PostingHistory) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, count, err := b.read()
+	if err != nil {
+		return err
+	}
+	stored := *record
+	stored.ID = int64(count) + 1
+	line, err := json.Marshal(&stored)
+	if err != nil {
+		return err
+	}
+
+	// One write per line, so appends of concurrent processes do not mix. A
+	// line cut short is ended first, or the post would be appended to it.
+	line = append(line, '\n')
+	if torn, err := b.torn(); err != nil {
+		return err
+	} else if torn {
+		line = append([]byte{'\n'}, line...)
+	}
+	file, err := os.OpenFile(b.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	record.ID = stored.ID
+	return nil
+}
+
+func (b *jsonlBackend) find(q Query) ([]*models.PostingHistory, error) {
+	b.mu.Lock()
+	records, _, err := b.read()
+	b.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []*models.PostingHistory
+	for _, record := range records {
+		if q.matches(record) {
+			matched = append(matched, record)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if !matched[i].PostedAt.Equal(matched[j].PostedAt) {
+			return matched[i].PostedAt.After(matched[j].PostedAt)
+		}
+		return matched[i].ID > matched[j].ID
+	})
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[:q.Limit]
+	}
+	return matched, nil
+}
+
+// torn reports whether the file does not end with a newline
+func (b *jsonlBackend) torn() (bool, error) {
+	file, err := os.Open(b.path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return false, err
+	}
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return false, err
+	}
+	return last[0] != '\n', nil
+}
+
+// read returns the posts of the file, numbered by line, and the number of
+// lines. A line that does not decode, as one cut short by a crash, is
+// skipped but keeps its number.
+func (b *jsonlBackend) read() ([]*models.PostingHistory, int, error) {
+	file, err := os.Open(b.path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	var records []*models.PostingHistory
+	count := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		count++
+		var record models.PostingHistory
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		record.ID = int64(count)
+		records = append(records, &record)
+	}
+	return records, count, scanner.Err()
+}
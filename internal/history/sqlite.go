@@ -0,0 +1,212 @@
+package history
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"ypost/pkg/models"
+
+	_ "modernc.org/sqlite"
+)
+
+// schema creates the posts table; groups are stored comma separated
+const schema = `
+CREATE TABLE IF NOT EXISTS posts (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	file_name   TEXT    NOT NULL,
+	file_size   INTEGER NOT NULL,
+	groups      TEXT    NOT NULL,
+	nzb_path    TEXT    NOT NULL,
+	message_ids INTEGER NOT NULL,
+	posted_at   INTEGER NOT NULL,
+	duration_ms INTEGER NOT NULL,
+	success     INTEGER NOT NULL,
+	error       TEXT    NOT NULL,
+	server      TEXT    NOT NULL DEFAULT '',
+	retries     INTEGER NOT NULL DEFAULT 0,
+	post_id     TEXT    NOT NULL DEFAULT '',
+	failed      INTEGER NOT NULL DEFAULT 0,
+	content_hash TEXT    NOT NULL DEFAULT '',
+	round_trip  TEXT    NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS posts_posted_at ON posts (posted_at);
+`
+
+// addedColumns are the columns added to the posts table after its first
+// release, with their definitions
+var addedColumns = []struct{ name, definition string }{
+	{"server", "TEXT NOT NULL DEFAULT ''"},
+	{"retries", "INTEGER NOT NULL DEFAULT 0"},
+	{"post_id", "TEXT NOT NULL DEFAULT ''"},
+	{"failed", "INTEGER NOT NULL DEFAULT 0"},
+	{"content_hash", "TEXT NOT NULL DEFAULT ''"},
+	{"round_trip", "TEXT NOT NULL DEFAULT ''"},
+}
+
+const columns = "id, file_name, file_size, groups, nzb_path, message_ids, posted_at, duration_ms, success, error, server, retries, post_id, failed, content_hash, round_trip"
+
+// sqliteBackend keeps the posts in an SQLite database
+type sqliteBackend struct {
+	db *sql.DB
+}
+
+// openSQLite opens the database at path, creating it if needed
+func openSQLite(path string) (*sqliteBackend, error) {
+	// Concurrent posts of a batch wait for each other's writes
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(10000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open history %s: %w", path, err)
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open history %s: %w", path, err)
+	}
+	return &sqliteBackend{db: db}, nil
+}
+
+// migrate adds the columns missing from a history created by an older
+// release
+func migrate(db *sql.DB) error {
+	rows, err := db.Query("SELECT name FROM pragma_table_info('posts')")
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, column := range addedColumns {
+		if existing[column.name] {
+			continue
+		}
+		if _, err := db.Exec("ALTER TABLE posts ADD COLUMN " + column.name + " " + column.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *sqliteBackend) close() error {
+	return b.db.Close()
+}
+
+func (b *sqliteBackend) add(record *models.PostingHistory) error {
+	success := 0
+	if record.Success {
+		success = 1
+	}
+	result, err := b.db.Exec(
+		`INSERT INTO posts (file_name, file_size, groups, nzb_path, message_ids, posted_at, duration_ms, success, error, server, retries, post_id, failed, content_hash, round_trip)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		record.FileName, record.FileSize, strings.Join(record.Groups, ","), record.NZBPath, record.MessageIDs,
+		record.PostedAt.UnixMilli(), record.Duration.Milliseconds(), success, record.Error, record.Server, record.Retries, record.PostID, record.Failed, record.ContentHash, record.RoundTrip,
+	)
+	if err != nil {
+		return err
+	}
+	record.ID, err = result.LastInsertId()
+	return err
+}
+
+func (b *sqliteBackend) find(q Query) ([]*models.PostingHistory, error) {
+	var conditions []string
+	var args []interface{}
+	if q.ID != 0 {
+		conditions = append(conditions, "id = ?")
+		args = append(args, q.ID)
+	}
+	if q.PostID != "" {
+		conditions = append(conditions, "post_id = ?")
+		args = append(args, q.PostID)
+	}
+	if q.Term != "" {
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(q.Term) + "%"
+		conditions = append(conditions, `(file_name LIKE ? ESCAPE '\' OR nzb_path LIKE ? ESCAPE '\' OR groups LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern, pattern)
+	}
+	if q.File != "" {
+		conditions = append(conditions, "file_name = ?")
+		args = append(args, q.File)
+	}
+	if q.Size != 0 {
+		conditions = append(conditions, "file_size = ?")
+		args = append(args, q.Size)
+	}
+	if q.ContentHash != "" {
+		conditions = append(conditions, "content_hash = ?")
+		args = append(args, q.ContentHash)
+	}
+	if !q.Since.IsZero() {
+		conditions = append(conditions, "posted_at >= ?")
+		args = append(args, q.Since.UnixMilli())
+	}
+	if !q.Until.IsZero() {
+		conditions = append(conditions, "posted_at < ?")
+		args = append(args, q.Until.UnixMilli())
+	}
+	if q.Success != nil {
+		conditions = append(conditions, "success = ?")
+		args = append(args, *q.Success)
+	}
+
+	query := "SELECT " + columns + " FROM posts"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY posted_at DESC, id DESC LIMIT ?"
+	return b.query(query, append(args, sqlLimit(q.Limit))...)
+}
+
+// query runs a select over the posts columns
+func (b *sqliteBackend) query(query string, args ...interface{}) ([]*models.PostingHistory, error) {
+	rows, err := b.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*models.PostingHistory
+	for rows.Next() {
+		var record models.PostingHistory
+		var groups string
+		var postedAt, durationMS int64
+		var success int
+		err := rows.Scan(&record.ID, &record.FileName, &record.FileSize, &groups, &record.NZBPath,
+			&record.MessageIDs, &postedAt, &durationMS, &success, &record.Error, &record.Server, &record.Retries, &record.PostID, &record.Failed, &record.ContentHash, &record.RoundTrip)
+		if err != nil {
+			return nil, err
+		}
+		if groups != "" {
+			record.Groups = strings.Split(groups, ",")
+		}
+		record.PostedAt = time.UnixMilli(postedAt)
+		record.Duration = time.Duration(durationMS) * time.Millisecond
+		record.Success = success != 0
+		records = append(records, &record)
+	}
+	return records, rows.Err()
+}
+
+// sqlLimit turns 0 into SQLite's "no limit"
+func sqlLimit(limit int) int {
+	if limit <= 0 {
+		return -1
+	}
+	return limit
+}
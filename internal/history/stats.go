@@ -0,0 +1,106 @@
+package history
+
+import (
+	"sort"
+	"time"
+
+	"ypost/pkg/models"
+)
+
+// Totals aggregates posts. Bytes, Articles and Duration count the
+// successful posts only, and BytesPerSecond is their average speed.
+type Totals struct {
+	Posts          int           `json:"posts"`
+	Failed         int           `json:"failed"`
+	FailureRate    float64       `json:"failure_rate"`
+	Bytes          int64         `json:"bytes"`
+	Articles       int           `json:"articles"`
+	Retries        int           `json:"retries"`
+	Duration       time.Duration `json:"duration"`
+	BytesPerSecond float64       `json:"bytes_per_second"`
+}
+
+// add counts a post
+func (t *Totals) add(record *models.PostingHistory) {
+	t.Posts++
+	t.Retries += record.Retries
+	if !record.Success {
+		t.Failed++
+		return
+	}
+	t.Bytes += record.FileSize
+	t.Articles += record.MessageIDs
+	t.Duration += record.Duration
+}
+
+// finish computes the rates; the failure rate is from 0 to 100
+func (t *Totals) finish() {
+	if t.Posts > 0 {
+		t.FailureRate = float64(t.Failed) * 100 / float64(t.Posts)
+	}
+	if t.Duration > 0 {
+		t.BytesPerSecond = float64(t.Bytes) / t.Duration.Seconds()
+	}
+}
+
+// DayStats are the totals of the posts of a day, in local time
+type DayStats struct {
+	Day string `json:"day"`
+	Totals
+}
+
+// ServerStats are the totals of the posts to a server; posts recorded
+// before the history kept servers have none
+type ServerStats struct {
+	Server string `json:"server"`
+	Totals
+}
+
+// Stats are the totals of posts per day, per server and overall
+type Stats struct {
+	Days    []DayStats    `json:"days"`
+	Servers []ServerStats `json:"servers"`
+	Total   Totals        `json:"total"`
+}
+
+// Stats aggregates the posts made since since; a zero since aggregates all
+// of them
+func (s *Store) Stats(since time.Time) (*Stats, error) {
+	records, err := s.Find(Query{Since: since})
+	if err != nil {
+		return nil, err
+	}
+	return Aggregate(records), nil
+}
+
+// Aggregate totals records per day, newest first, and per server, by host
+func Aggregate(records []*models.PostingHistory) *Stats {
+	days := make(map[string]*Totals)
+	servers := make(map[string]*Totals)
+	stats := &Stats{Days: []DayStats{}, Servers: []ServerStats{}}
+	for _, record := range records {
+		day := record.PostedAt.Local().Format("2006-01-02")
+		if days[day] == nil {
+			days[day] = &Totals{}
+		}
+		days[day].add(record)
+		if servers[record.Server] == nil {
+			servers[record.Server] = &Totals{}
+		}
+		servers[record.Server].add(record)
+		stats.Total.add(record)
+	}
+
+	stats.Total.finish()
+	for day, totals := range days {
+		totals.finish()
+		stats.Days = append(stats.Days, DayStats{Day: day, Totals: *totals})
+	}
+	sort.Slice(stats.Days, func(i, j int) bool { return stats.Days[i].Day > stats.Days[j].Day })
+	for server, totals := range servers {
+		totals.finish()
+		stats.Servers = append(stats.Servers, ServerStats{Server: server, Totals: *totals})
+	}
+	sort.Slice(stats.Servers, func(i, j int) bool { return stats.Servers[i].Server < stats.Servers[j].Server })
+	return stats
+}
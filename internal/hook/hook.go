@@ -0,0 +1,124 @@
+// Package hook runs the commands configured around posts, handing them the
+// job as JSON on their standard input
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"ypost/pkg/models"
+)
+
+// Names of the hooks, as in the configuration
+const (
+	PrePost     = "pre_post"
+	PostSuccess = "post_success"
+	PostFailure = "post_failure"
+)
+
+// defaultTimeout bounds the run of a hook without a timeout
+const defaultTimeout = 10 * time.Minute
+
+// Job is the description of a post a hook reads on its standard input
+type Job struct {
+	// Hook is the name of the hook run
+	Hook   string `json:"hook"`
+	PostID string `json:"post_id"`
+	// Source is the file, directory or URL posted
+	Source string `json:"source"`
+	// Name and Size are those of the posted files
+	Name     string   `json:"name"`
+	Size     int64    `json:"size"`
+	Groups   []string `json:"groups"`
+	Category string   `json:"category,omitempty"`
+	// Result is the history record of the finished post, absent before it
+	Result *models.PostingHistory `json:"result,omitempty"`
+}
+
+// Runner runs the hooks of a configuration
+type Runner struct {
+	commands map[string]string
+	timeout  time.Duration
+}
+
+// New checks the hook settings and returns their runner
+func New(cfg models.HooksConfig) (*Runner, error) {
+	timeout := defaultTimeout
+	if cfg.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(cfg.Timeout); err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid hooks timeout %q (expected a duration such as 10m)", cfg.Timeout)
+		}
+	}
+	return &Runner{
+		commands: map[string]string{
+			PrePost:     strings.TrimSpace(cfg.PrePost),
+			PostSuccess: strings.TrimSpace(cfg.PostSuccess),
+			PostFailure: strings.TrimSpace(cfg.PostFailure),
+		},
+		timeout: timeout,
+	}, nil
+}
+
+// Has reports whether a command is configured for hook
+func (r *Runner) Has(hook string) bool {
+	return r.commands[hook] != ""
+}
+
+// Run runs the command of hook through the shell with job on its standard
+// input and YPOST_HOOK and YPOST_POST_ID in its environment, and returns its
+// output. A hook that exits with an error, or runs past the timeout, fails
+// with the last line it wrote. Nothing runs for a hook without a command.
+func (r *Runner) Run(ctx context.Context, hook string, job Job) ([]byte, error) {
+	command := r.commands[hook]
+	if command == "" {
+		return nil, nil
+	}
+	job.Hook = hook
+	input, err := json.Marshal(job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the job to the %s hook: %w", hook, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(), "YPOST_HOOK="+hook, "YPOST_POST_ID="+job.PostID)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// The children of the shell may keep its output open once it is killed
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return output.Bytes(), fmt.Errorf("%s hook did not finish in %s", hook, r.timeout)
+	}
+	if err != nil {
+		if line := lastLine(output.Bytes()); line != "" {
+			return output.Bytes(), fmt.Errorf("%s hook failed: %w: %s", hook, err, line)
+		}
+		return output.Bytes(), fmt.Errorf("%s hook failed: %w", hook, err)
+	}
+	return output.Bytes(), nil
+}
+
+// lastLine returns the last line of output that is not blank
+func lastLine(output []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,83 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ypost/pkg/models"
+)
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks of the test are shell commands")
+	}
+	dir := t.TempDir()
+	written := filepath.Join(dir, "job.json")
+	runner, err := New(models.HooksConfig{
+		PostSuccess: `echo "$YPOST_HOOK $YPOST_POST_ID"; cat > ` + written,
+		PostFailure: "echo checking; echo 'database is locked' >&2; exit 3",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := Job{PostID: "p1", Source: "/srv/file.iso", Name: "file.iso", Size: 42, Groups: []string{"alt.binaries.test"},
+		Result: &models.PostingHistory{FileName: "file.iso", Success: true, MessageIDs: 3}}
+	output, err := runner.Run(context.Background(), PostSuccess, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(output)) != "post_success p1" {
+		t.Errorf("unexpected output %q", output)
+	}
+	data, err := os.ReadFile(written)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var read Job
+	if err := json.Unmarshal(data, &read); err != nil {
+		t.Fatal(err)
+	}
+	if read.Hook != PostSuccess || read.Source != job.Source || read.Size != 42 || read.Result == nil || read.Result.MessageIDs != 3 {
+		t.Errorf("unexpected job %s", data)
+	}
+
+	_, err = runner.Run(context.Background(), PostFailure, job)
+	if err == nil || !strings.Contains(err.Error(), "database is locked") {
+		t.Errorf("expected the failure with the last line, got %v", err)
+	}
+
+	if runner.Has(PrePost) {
+		t.Error("expected no pre_post hook")
+	}
+	if output, err := runner.Run(context.Background(), PrePost, job); output != nil || err != nil {
+		t.Errorf("expected nothing to run, got %q, %v", output, err)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks of the test are shell commands")
+	}
+	runner, err := New(models.HooksConfig{PrePost: "sleep 5", Timeout: "50ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = runner.Run(context.Background(), PrePost, Job{})
+	if err == nil || !strings.Contains(err.Error(), "did not finish") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+}
+
+func TestNewInvalidTimeout(t *testing.T) {
+	for _, timeout := range []string{"soon", "-1m", "0s"} {
+		if _, err := New(models.HooksConfig{Timeout: timeout}); err == nil {
+			t.Errorf("expected timeout %q to be refused", timeout)
+		}
+	}
+}
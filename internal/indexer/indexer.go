@@ -0,0 +1,206 @@
+// Package indexer pushes the NZBs of posts to newznab-compatible indexers
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ypost/pkg/models"
+)
+
+// defaultAttempts bounds the submissions of an indexer without attempts
+const defaultAttempts = 3
+
+// pushTimeout bounds each submission
+const pushTimeout = time.Minute
+
+// retryDelay is the wait before the second submission, doubled afterwards
+var retryDelay = time.Second
+
+// Indexer submits NZBs to a newznab site
+type Indexer struct {
+	url             string
+	apiKey          string
+	mode            string
+	categories      []models.IndexerCategory
+	defaultCategory string
+	attempts        int
+	client          *http.Client
+}
+
+// New checks the configuration of an indexer
+func New(cfg models.IndexerConfig) (*Indexer, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid indexer URL %q (expected http or https)", cfg.URL)
+	}
+	mode := strings.ToLower(strings.TrimSpace(cfg.Mode))
+	switch mode {
+	case "":
+		mode = models.IndexerModeAPI
+	case models.IndexerModeAPI, models.IndexerModeUpload:
+	default:
+		return nil, fmt.Errorf("invalid indexer mode %q (expected api or upload)", cfg.Mode)
+	}
+	for i, mapping := range cfg.Categories {
+		if mapping.Match == "" || mapping.Category == "" {
+			return nil, fmt.Errorf("indexer category %d needs both match and category", i+1)
+		}
+		if _, err := path.Match(strings.ToLower(mapping.Match), ""); err != nil {
+			return nil, fmt.Errorf("invalid indexer category match %q: %w", mapping.Match, err)
+		}
+	}
+	if cfg.Attempts < 0 {
+		return nil, fmt.Errorf("invalid indexer attempts %d", cfg.Attempts)
+	}
+	attempts := cfg.Attempts
+	if attempts == 0 {
+		attempts = defaultAttempts
+	}
+	return &Indexer{
+		url:             strings.TrimSuffix(cfg.URL, "/"),
+		apiKey:          cfg.APIKey,
+		mode:            mode,
+		categories:      cfg.Categories,
+		defaultCategory: cfg.DefaultCategory,
+		attempts:        attempts,
+		client:          &http.Client{Timeout: pushTimeout},
+	}, nil
+}
+
+// String returns the URL of the indexer without its credentials
+func (i *Indexer) String() string {
+	if u, err := url.Parse(i.url); err == nil {
+		return u.Redacted()
+	}
+	return i.url
+}
+
+// Category returns the newznab category of a post: that of the first
+// mapping matching its NZB category or one of its groups, case-insensitively,
+// or the default category
+func (i *Indexer) Category(nzbCategory string, groups []string) string {
+	candidates := append([]string{nzbCategory}, groups...)
+	for _, mapping := range i.categories {
+		pattern := strings.ToLower(mapping.Match)
+		for _, candidate := range candidates {
+			if candidate == "" {
+				continue
+			}
+			if ok, _ := path.Match(pattern, strings.ToLower(strings.TrimSpace(candidate))); ok {
+				return mapping.Category
+			}
+		}
+	}
+	return i.defaultCategory
+}
+
+// Push submits an NZB in a category, none when empty, trying failed
+// submissions again with a growing delay unless the indexer refused it
+func (i *Indexer) Push(ctx context.Context, nzbPath, category string) error {
+	data, err := os.ReadFile(nzbPath)
+	if err != nil {
+		return fmt.Errorf("failed to read NZB: %w", err)
+	}
+
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		retry, err := i.submit(ctx, filepath.Base(nzbPath), data, category)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= i.attempts {
+			return fmt.Errorf("indexer %s: %w", i, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// submit posts the NZB once, reporting whether a failure is worth trying
+// again: network errors, rate limiting and server errors are
+func (i *Indexer) submit(ctx context.Context, name string, data []byte, category string) (bool, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	target := i.url
+	if i.mode == models.IndexerModeAPI {
+		query := url.Values{"t": {"nzbadd"}, "apikey": {i.apiKey}}
+		if category != "" {
+			query.Set("cat", category)
+		}
+		target += "/api?" + query.Encode()
+	} else {
+		form.WriteField("apikey", i.apiKey)
+		if category != "" {
+			form.WriteField("cat", category)
+		}
+	}
+	file, err := form.CreateFormFile("file", name)
+	if err != nil {
+		return false, err
+	}
+	file.Write(data)
+	if err := form.Close(); err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, &body)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("User-Agent", "ypost")
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		// The URL is left out, it holds the API key
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return true, err
+	}
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if err := replyError(reply); err != nil {
+			return retry, fmt.Errorf("%s: %w", resp.Status, err)
+		}
+		return retry, fmt.Errorf("%s", resp.Status)
+	}
+	return false, replyError(reply)
+}
+
+// replyError returns the error a newznab reply reports, as an
+// <error code="..." description="..."/> document, or nil
+func replyError(reply []byte) error {
+	var refusal struct {
+		XMLName     xml.Name
+		Code        string `xml:"code,attr"`
+		Description string `xml:"description,attr"`
+	}
+	if err := xml.Unmarshal(reply, &refusal); err != nil || refusal.XMLName.Local != "error" {
+		return nil
+	}
+	if refusal.Description == "" {
+		return fmt.Errorf("refused with code %s", refusal.Code)
+	}
+	return fmt.Errorf("refused with code %s: %s", refusal.Code, refusal.Description)
+}
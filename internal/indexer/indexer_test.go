@@ -0,0 +1,174 @@
+package indexer
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ypost/pkg/models"
+)
+
+// writeNZB writes a small NZB to a temporary directory
+func writeNZB(t *testing.T) string {
+	t.Helper()
+	nzbPath := filepath.Join(t.TempDir(), "movie.nzb")
+	if err := os.WriteFile(nzbPath, []byte("<nzb></nzb>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return nzbPath
+}
+
+func TestPushAPI(t *testing.T) {
+	var query, name, content string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.RawQuery
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		name, content = header.Filename, string(data)
+		w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><nzbadd id="123"/>`))
+	}))
+	defer server.Close()
+
+	idx, err := New(models.IndexerConfig{URL: server.URL + "/", APIKey: "k3y"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Push(context.Background(), writeNZB(t), "2040"); err != nil {
+		t.Fatal(err)
+	}
+	if query != "apikey=k3y&cat=2040&t=nzbadd" {
+		t.Errorf("unexpected query %q", query)
+	}
+	if name != "movie.nzb" || content != "<nzb></nzb>" {
+		t.Errorf("unexpected file %q: %q", name, content)
+	}
+}
+
+func TestPushUpload(t *testing.T) {
+	var apiKey, category string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/upload" {
+			http.NotFound(w, r)
+			return
+		}
+		apiKey, category = r.FormValue("apikey"), r.FormValue("cat")
+	}))
+	defer server.Close()
+
+	idx, err := New(models.IndexerConfig{URL: server.URL + "/upload", APIKey: "k3y", Mode: "upload"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Push(context.Background(), writeNZB(t), "5000"); err != nil {
+		t.Fatal(err)
+	}
+	if apiKey != "k3y" || category != "5000" {
+		t.Errorf("unexpected form apikey=%q cat=%q", apiKey, category)
+	}
+}
+
+func TestPushRefused(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><error code="100" description="Incorrect user credentials"/>`))
+	}))
+	defer server.Close()
+
+	idx, err := New(models.IndexerConfig{URL: server.URL, APIKey: "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Push(context.Background(), writeNZB(t), "")
+	if err == nil || !strings.Contains(err.Error(), "Incorrect user credentials") {
+		t.Fatalf("expected the refusal, got %v", err)
+	}
+	if strings.Contains(err.Error(), "wrong") {
+		t.Errorf("API key in the error %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected a refusal not to be tried again, got %d calls", calls.Load())
+	}
+}
+
+func TestPushRetries(t *testing.T) {
+	defer func(delay time.Duration) { retryDelay = delay }(retryDelay)
+	retryDelay = time.Millisecond
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	idx, err := New(models.IndexerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Push(context.Background(), writeNZB(t), ""); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 calls, got %d", calls.Load())
+	}
+}
+
+func TestCategory(t *testing.T) {
+	idx, err := New(models.IndexerConfig{
+		URL: "https://indexer.example.com",
+		Categories: []models.IndexerCategory{
+			{Match: "tv", Category: "5000"},
+			{Match: "alt.binaries.movies*", Category: "2000"},
+			{Match: "alt.binaries.sounds.*", Category: "3000"},
+		},
+		DefaultCategory: "7000",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		category string
+		groups   []string
+		expected string
+	}{
+		{"TV", []string{"alt.binaries.movies"}, "5000"},
+		{"", []string{"alt.binaries.test", "alt.binaries.Movies.hd"}, "2000"},
+		{"misc", []string{"alt.binaries.sounds.flac"}, "3000"},
+		{"misc", []string{"alt.binaries.test"}, "7000"},
+	} {
+		if category := idx.Category(test.category, test.groups); category != test.expected {
+			t.Errorf("%q %v: expected %s, got %s", test.category, test.groups, test.expected, category)
+		}
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, cfg := range []models.IndexerConfig{
+		{URL: "ftp://indexer.example.com"},
+		{URL: "https://indexer.example.com", Mode: "rss"},
+		{URL: "https://indexer.example.com", Categories: []models.IndexerCategory{{Match: "tv"}}},
+		{URL: "https://indexer.example.com", Categories: []models.IndexerCategory{{Match: "[tv", Category: "5000"}}},
+		{URL: "https://indexer.example.com", Attempts: -1},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("expected %+v to be refused", cfg)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package journal
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ypost/pkg/models"
+)
+
+// FileName is the name of the journal within the output directory of a post
+const FileName = "ypost-state.jsonl"
+
+// Entry types, one JSON object per line
+const (
+	typePost    = "post"
+	typeArchive = "archive"
+	typePlan    = "plan"
+	typeSegment = "segment"
+)
+
+// entry is a line of the journal; only the fields of its type are set
+type entry struct {
+	Type string `json:"type"`
+
+	// post
+	ID        string         `json:"id,omitempty"`
+	Source    string         `json:"source,omitempty"`
+	OutputDir string         `json:"output_dir,omitempty"`
+	StartedAt *time.Time     `json:"started_at,omitempty"`
+	Config    *models.Config `json:"config,omitempty"`
+
+	// archive
+	Volumes []string `json:"volumes,omitempty"`
+
+	// plan and segment
+	File   string `json:"file,omitempty"`
+	Number int    `json:"number,omitempty"`
+
+	// plan
+	Chunks int `json:"chunks,omitempty"`
+
+	// segment
+	Total     int        `json:"total,omitempty"`
+	MessageID string     `json:"message_id,omitempty"`
+	Subject   string     `json:"subject,omitempty"`
+	Bytes     int64      `json:"bytes,omitempty"`
+	PostedAt  *time.Time `json:"posted_at,omitempty"`
+	CRC32     uint32     `json:"crc32,omitempty"`
+}
+
+// segmentKey identifies an article by posted file name and chunk number
+type segmentKey struct {
+	file   string
+	number int
+}
+
+// plannedRange is a run of articles of a posted file
+type plannedRange struct {
+	file   string
+	first  int
+	chunks int
+}
+
+// State is the progress of a post read back from its journal
+type State struct {
+	ID        string
+	Source    string
+	OutputDir string
+	StartedAt time.Time
+	// Config holds the settings of the post; the servers, security
+	// settings and secrets are not recorded, PostConfig takes them from the
+	// current configuration
+	Config models.Config
+	// Volumes are the archive volumes created for the post, if any
+	Volumes []string
+
+	planned []plannedRange
+	posted  map[segmentKey]*models.PostSegment
+}
+
+// Posted returns the recorded article of a file's chunk
+func (s *State) Posted(fileName string, number int) (*models.PostSegment, bool) {
+	segment, ok := s.posted[segmentKey{fileName, number}]
+	return segment, ok
+}
+
+// PostedCount returns the number of recorded articles
+func (s *State) PostedCount() int {
+	return len(s.posted)
+}
+
+// PendingCount returns the number of planned articles not posted yet. Files
+// the interrupted post had not reached are not planned yet.
+func (s *State) PendingCount() int {
+	pending := 0
+	for _, run := range s.planned {
+		for number := run.first; number < run.first+run.chunks; number++ {
+			if _, ok := s.posted[segmentKey{run.file, number}]; !ok {
+				pending++
+			}
+		}
+	}
+	return pending
+}
+
+// Journal appends the progress of a post to its state file
+type Journal struct {
+	path string
+	id   string
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Path returns the state file of a post's output directory
+func Path(outputDir string) string {
+	return filepath.Join(outputDir, FileName)
+}
+
+// NewID returns a random post ID, which names a post in its journal, its
+// log lines and its history record
+func NewID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to create post ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// Create starts the journal of the new post id in outputDir. The
+// configuration is recorded without the servers and security settings, which
+// hold credentials, nor any password, token, API key or webhook.
+func Create(outputDir string, id string, source string, config *models.Config) (*Journal, error) {
+	saved := withoutSecrets(config)
+	now := time.Now()
+
+	path := Path(outputDir)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create journal: %w", err)
+	}
+	j := &Journal{path: path, id: id, file: file}
+	err = j.write(entry{Type: typePost, ID: j.id, Source: source, OutputDir: outputDir, StartedAt: &now, Config: &saved})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// Reopen appends to the journal of an interrupted post
+func Reopen(path string) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	// End a line cut short by a crash, so new entries start on a line of
+	// their own
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			if _, err := file.Write([]byte("\n")); err != nil {
+				file.Close()
+				return nil, fmt.Errorf("failed to write journal: %w", err)
+			}
+		}
+	}
+	return &Journal{path: path, file: file}, nil
+}
+
+// Path returns the state file of the journal
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// ID returns the ID of a post journal created with Create; the ID of a
+// reopened one is in its State
+func (j *Journal) ID() string {
+	return j.id
+}
+
+// Archive records the archive volumes created for the post
+func (j *Journal) Archive(volumes []string) error {
+	return j.write(entry{Type: typeArchive, Volumes: volumes})
+}
+
+// Plan records the articles of a posted file about to be posted: chunks
+// articles numbered from first
+func (j *Journal) Plan(fileName string, first int, chunks int) error {
+	return j.write(entry{Type: typePlan, File: fileName, Number: first, Chunks: chunks})
+}
+
+// Record records a posted article
+func (j *Journal) Record(segment *models.PostSegment) error {
+	postedAt := segment.PostedAt
+	return j.write(entry{
+		Type:      typeSegment,
+		File:      segment.FileName,
+		Number:    segment.PartNumber,
+		Total:     segment.TotalParts,
+		MessageID: segment.MessageID,
+		Subject:   segment.Subject,
+		Bytes:     segment.BytesPosted,
+		PostedAt:  &postedAt,
+		CRC32:     segment.CRC32,
+	})
+}
+
+// Close closes the state file
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
+
+// Remove closes and deletes the state file, once the post completed
+func (j *Journal) Remove() error {
+	j.file.Close()
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove journal: %w", err)
+	}
+	return nil
+}
+
+// write appends an entry; entries are written whole, so a crash loses at
+// most the last line
+func (j *Journal) write(e entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
+
+// Read reads the state of a post from its journal. Lines cut short by a
+// crash while writing are skipped.
+func Read(path string) (*State, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+
+	state := &State{posted: make(map[segmentKey]*models.PostSegment)}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		switch e.Type {
+		case typePost:
+			state.ID, state.Source, state.OutputDir = e.ID, e.Source, e.OutputDir
+			if e.StartedAt != nil {
+				state.StartedAt = *e.StartedAt
+			}
+			if e.Config != nil {
+				state.Config = *e.Config
+			}
+		case typeArchive:
+			state.Volumes = e.Volumes
+		case typePlan:
+			state.planned = append(state.planned, plannedRange{file: e.File, first: e.Number, chunks: e.Chunks})
+		case typeSegment:
+			segment := &models.PostSegment{
+				MessageID:   e.MessageID,
+				PartNumber:  e.Number,
+				TotalParts:  e.Total,
+				FileName:    e.File,
+				Subject:     e.Subject,
+				BytesPosted: e.Bytes,
+				CRC32:       e.CRC32,
+			}
+			if e.PostedAt != nil {
+				segment.PostedAt = *e.PostedAt
+			}
+			state.posted[segmentKey{e.File, e.Number}] = segment
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	if state.ID == "" {
+		return nil, fmt.Errorf("%s is not a post journal", path)
+	}
+	return state, nil
+}
@@ -0,0 +1,83 @@
+package journal
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+
+	"ypost/pkg/models"
+)
+
+// secretSetting matches the settings the journal leaves out: passwords,
+// tokens and API keys with the commands and references resolving them, and
+// the URLs and headers of webhooks, which are credentials themselves
+var secretSetting = regexp.MustCompile(`(password|passwd|token|secret|api_?key)|^notifications\.(webhooks|chats)\.(url|headers)$`)
+
+// withoutSecrets returns a copy of config to record, without the servers,
+// the security settings and any other secret
+func withoutSecrets(config *models.Config) models.Config {
+	saved := *config
+	saved.NNTP = models.Config{}.NNTP
+	saved.Security = models.Config{}.Security
+	copySecrets(reflect.ValueOf(&saved).Elem(), reflect.Value{}, "")
+	return saved
+}
+
+// PostConfig returns the settings of the post with the servers, the
+// security settings and the secrets of current, which the journal does not
+// record
+func (s *State) PostConfig(current *models.Config) models.Config {
+	config := s.Config
+	config.NNTP = current.NNTP
+	config.Security = current.Security
+	copySecrets(reflect.ValueOf(&config).Elem(), reflect.ValueOf(current).Elem(), "")
+	return config
+}
+
+// copySecrets sets the secret settings of the struct value to those of
+// from, or clears them when from is not valid. The lists of settings, such
+// as the webhooks, are taken whole from from, or copied before being
+// cleared so the struct copied keeps its secrets.
+func copySecrets(value, from reflect.Value, prefix string) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		key, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if key == "" || key == "-" {
+			key = strings.ToLower(field.Name)
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		item := value.Field(i)
+		var source reflect.Value
+		if from.IsValid() {
+			source = from.Field(i)
+		}
+		switch {
+		case secretSetting.MatchString(key) && source.IsValid():
+			item.Set(source)
+		case secretSetting.MatchString(key):
+			item.Set(reflect.Zero(item.Type()))
+		case item.Kind() == reflect.Struct:
+			copySecrets(item, source, key)
+		case item.Kind() == reflect.Slice && item.Type().Elem().Kind() == reflect.Struct:
+			if source.IsValid() {
+				item.Set(source)
+				continue
+			}
+			if item.Len() == 0 {
+				continue
+			}
+			list := reflect.MakeSlice(item.Type(), item.Len(), item.Len())
+			reflect.Copy(list, item)
+			for j := 0; j < list.Len(); j++ {
+				copySecrets(list.Index(j), reflect.Value{}, key)
+			}
+			item.Set(list)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
@@ -21,6 +22,28 @@ const (
 	FATAL
 )
 
+// Interface is the logging of a module. The logger of the process hands
+// one to every module that logs, through Module.
+type Interface interface {
+	Debug(format string, args ...interface{})
+	Info(format string, args ...interface{})
+	Warn(format string, args ...interface{})
+	Error(format string, args ...interface{})
+}
+
+// Backends are where log lines are written besides the console: a file, or
+// a log service of the system for yPost running as a service. journald reads
+// the syslog socket, so both send there.
+const (
+	BackendFile     = "file"
+	BackendSyslog   = "syslog"
+	BackendJournald = "journald"
+	BackendEventLog = "eventlog"
+)
+
+// Modules lists the modules whose level can be set on its own
+var Modules = []string{"par2", "progress"}
+
 // Logger provides thread-safe logging functionality
 type Logger struct {
 	debugLogger *log.Logger
@@ -29,94 +52,355 @@ type Logger struct {
 	errorLogger *log.Logger
 	fatalLogger *log.Logger
 	logFile     *os.File
+	system      systemLog
 	mu          sync.Mutex
 	level       LogLevel
+	levels      map[string]LogLevel
+	console     bool
+	secrets     []string
+	redactor    *strings.Replacer
+
+	// parent is the logger writing the lines of a post logger, which
+	// prefixes them with its post ID
+	parent *Logger
+	post   string
 }
 
-// New creates a new logger instance
-func New(logDir string) (*Logger, error) {
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create log directory: %w", err)
+// Options configures a logger
+type Options struct {
+	// Dir is the directory of the log file
+	Dir string
+	// File is the log file name, relative to Dir unless absolute. Empty uses
+	// a date-named file (ypost-2006-01-02.log).
+	File string
+	// Level is the minimum level written
+	Level LogLevel
+	// Backend is where lines are written besides the console, a file
+	// unless set
+	Backend string
+	// Levels overrides Level for the modules it lists
+	Levels map[string]LogLevel
+	// Console echoes log lines to stdout as well as the file
+	Console bool
+	// Quiet keeps debug and info lines off the console; they are still
+	// written to the file
+	Quiet bool
+}
+
+// ParseLevel returns the level for a configuration name (debug, info, warn or
+// error)
+func ParseLevel(name string) (LogLevel, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return DEBUG, nil
+	case "", "info":
+		return INFO, nil
+	case "warn", "warning":
+		return WARN, nil
+	case "error":
+		return ERROR, nil
+	default:
+		return INFO, fmt.Errorf("unknown log level %q (expected debug, info, warn or error)", name)
 	}
+}
 
-	logFileName := filepath.Join(logDir, fmt.Sprintf("ypost-%s.log", time.Now().Format("2006-01-02")))
-	
-	logFile, err := os.OpenFile(logFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+// ParseBackend returns the backend of a configuration name, "file" for ""
+func ParseBackend(name string) (string, error) {
+	switch backend := strings.ToLower(strings.TrimSpace(name)); backend {
+	case "", BackendFile:
+		return BackendFile, nil
+	case BackendSyslog, BackendJournald, BackendEventLog:
+		return backend, nil
+	}
+	return "", fmt.Errorf("invalid log backend %q (expected file, syslog, journald or eventlog)", name)
+}
+
+// ParseLevels returns the levels of a module level configuration, checking
+// the module names against Modules
+func ParseLevels(names map[string]string) (map[string]LogLevel, error) {
+	levels := make(map[string]LogLevel, len(names))
+	for module, name := range names {
+		module = strings.ToLower(strings.TrimSpace(module))
+		known := false
+		for _, candidate := range Modules {
+			known = known || candidate == module
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown log module %q (expected %s)", module, strings.Join(Modules, ", "))
+		}
+		level, err := ParseLevel(name)
+		if err != nil {
+			return nil, fmt.Errorf("log module %s: %w", module, err)
+		}
+		levels[module] = level
+	}
+	return levels, nil
+}
+
+// SplitLevels parses module levels written "par2=debug,progress=warn"
+func SplitLevels(value string) (map[string]string, error) {
+	names := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		module, level, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid module level %q (expected module=level)", entry)
+		}
+		names[strings.TrimSpace(module)] = strings.TrimSpace(level)
+	}
+	return names, nil
+}
+
+// New creates a new logger instance logging at INFO to a date-named file in
+// logDir and to the console
+func New(logDir string) (*Logger, error) {
+	return NewWithOptions(Options{Dir: logDir, Level: INFO, Console: true})
+}
+
+// NewWithOptions creates a new logger instance
+func NewWithOptions(opts Options) (*Logger, error) {
+	backend, err := ParseBackend(opts.Backend)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open log file: %w", err)
+		return nil, err
+	}
+
+	// A system log has its own timestamps and levels: the formatted lines
+	// only go to the file or the console
+	var logFile *os.File
+	var system systemLog
+	var fileWriter io.Writer = io.Discard
+	if backend == BackendFile {
+		logFile, err = openLogFile(opts.Dir, opts.File)
+		if err != nil {
+			return nil, err
+		}
+		fileWriter = logFile
+	} else {
+		system, err = openSystemLog(backend)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s log: %w", backend, err)
+		}
 	}
 
 	// Create multi-writer for both file and stdout
-	multiWriter := io.MultiWriter(os.Stdout, logFile)
+	var multiWriter = fileWriter
+	if opts.Console {
+		multiWriter = io.MultiWriter(os.Stdout, fileWriter)
+	}
+
+	var chattyWriter = multiWriter
+	if opts.Quiet {
+		chattyWriter = fileWriter
+	}
 
 	logger := &Logger{
-		debugLogger: log.New(multiWriter, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile),
-		infoLogger:  log.New(multiWriter, "INFO:  ", log.Ldate|log.Ltime|log.Lshortfile),
+		debugLogger: log.New(chattyWriter, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile),
+		infoLogger:  log.New(chattyWriter, "INFO:  ", log.Ldate|log.Ltime|log.Lshortfile),
 		warnLogger:  log.New(multiWriter, "WARN:  ", log.Ldate|log.Ltime|log.Lshortfile),
 		errorLogger: log.New(multiWriter, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile),
 		fatalLogger: log.New(multiWriter, "FATAL: ", log.Ldate|log.Ltime|log.Lshortfile),
 		logFile:     logFile,
-		level:       INFO,
+		system:      system,
+		level:       opts.Level,
+		levels:      opts.Levels,
+		console:     opts.Console,
 	}
 
 	return logger, nil
 }
 
+// openLogFile opens the log file for appending, named after the day unless
+// file is set, and relative to dir unless absolute
+func openLogFile(dir, file string) (*os.File, error) {
+	logFileName := file
+	if logFileName == "" {
+		logFileName = fmt.Sprintf("ypost-%s.log", time.Now().Format("2006-01-02"))
+	}
+	if !filepath.IsAbs(logFileName) {
+		logFileName = filepath.Join(dir, logFileName)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(logFileName), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	logFile, err := os.OpenFile(logFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	return logFile, nil
+}
+
+// WithPost returns a logger writing through l whose lines are prefixed with
+// the ID of a post, so the lines of concurrent posts can be told apart
+func (l *Logger) WithPost(id string) *Logger {
+	return &Logger{parent: l.base(), post: id}
+}
+
+// PostID returns the post ID of a logger created with WithPost
+func (l *Logger) PostID() string {
+	return l.post
+}
+
+// base returns the logger writing the lines of l
+func (l *Logger) base() *Logger {
+	if l.parent != nil {
+		return l.parent
+	}
+	return l
+}
+
 // SetLevel sets the logging level
 func (l *Logger) SetLevel(level LogLevel) {
+	l = l.base()
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	l.level = level
 }
 
-// Debug logs debug messages
-func (l *Logger) Debug(format string, args ...interface{}) {
+// SetModuleLevels replaces the level overrides of the modules
+func (l *Logger) SetModuleLevels(levels map[string]LogLevel) {
+	l = l.base()
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	if l.level <= DEBUG {
-		l.debugLogger.Printf(format, args...)
-	}
+	l.levels = levels
+}
+
+// Module returns the logging of a module: its messages are prefixed with
+// its name and written at its own level, or the logger's when it has none
+func (l *Logger) Module(name string) Interface {
+	return &moduleLogger{root: l, name: name}
+}
+
+// Debug logs debug messages
+func (l *Logger) Debug(format string, args ...interface{}) {
+	l.logf("", DEBUG, format, args...)
 }
 
 // Info logs informational messages
 func (l *Logger) Info(format string, args ...interface{}) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	if l.level <= INFO {
-		l.infoLogger.Printf(format, args...)
-	}
+	l.logf("", INFO, format, args...)
 }
 
 // Warn logs warning messages
 func (l *Logger) Warn(format string, args ...interface{}) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	if l.level <= WARN {
-		l.warnLogger.Printf(format, args...)
-	}
+	l.logf("", WARN, format, args...)
 }
 
 // Error logs error messages
 func (l *Logger) Error(format string, args ...interface{}) {
+	l.logf("", ERROR, format, args...)
+}
+
+// logf writes a message of a module, or of the process for "", when its
+// level is enabled
+func (l *Logger) logf(module string, level LogLevel, format string, args ...interface{}) {
+	post := l.post
+	l = l.base()
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	if l.level <= ERROR {
-		l.errorLogger.Printf(format, args...)
+	threshold := l.level
+	if override, ok := l.levels[module]; ok && module != "" {
+		threshold = override
+	}
+	if level < threshold {
+		return
+	}
+	message := l.redact(fmt.Sprintf(format, args...))
+	if module != "" {
+		message = "[" + module + "] " + message
+	}
+	if post != "" {
+		message = "[post " + post + "] " + message
+	}
+
+	if l.system != nil {
+		l.system.write(level, message)
+	}
+	switch level {
+	case DEBUG:
+		l.debugLogger.Print(message)
+	case INFO:
+		l.infoLogger.Print(message)
+	case WARN:
+		l.warnLogger.Print(message)
+	default:
+		l.errorLogger.Print(message)
 	}
 }
 
 // Fatal logs fatal messages and exits
 func (l *Logger) Fatal(format string, args ...interface{}) {
+	post := l.post
+	l = l.base()
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	l.fatalLogger.Printf(format, args...)
+	message := l.redact(fmt.Sprintf(format, args...))
+	if post != "" {
+		message = "[post " + post + "] " + message
+	}
+	l.fatalLogger.Print(message)
+	if l.system != nil {
+		l.system.write(FATAL, message)
+		l.system.Close()
+	}
+	if !l.console {
+		// Never exit silently
+		fmt.Fprintf(os.Stderr, "FATAL: %s\n", message)
+	}
 	os.Exit(1)
 }
 
-// Close closes the log file
+// moduleLogger is the logging of a module, written through the logger of
+// the process
+type moduleLogger struct {
+	root *Logger
+	name string
+}
+
+// Debug implements Interface
+func (m *moduleLogger) Debug(format string, args ...interface{}) {
+	m.root.logf(m.name, DEBUG, format, args...)
+}
+
+// Info implements Interface
+func (m *moduleLogger) Info(format string, args ...interface{}) {
+	m.root.logf(m.name, INFO, format, args...)
+}
+
+// Warn implements Interface
+func (m *moduleLogger) Warn(format string, args ...interface{}) {
+	m.root.logf(m.name, WARN, format, args...)
+}
+
+// Error implements Interface
+func (m *moduleLogger) Error(format string, args ...interface{}) {
+	m.root.logf(m.name, ERROR, format, args...)
+}
+
+// Discard drops every message; it is the logging of modules given none
+var Discard Interface = discard{}
+
+type discard struct{}
+
+func (discard) Debug(string, ...interface{}) {}
+func (discard) Info(string, ...interface{})  {}
+func (discard) Warn(string, ...interface{})  {}
+func (discard) Error(string, ...interface{}) {}
+
+// Close closes the log file or the connection to the system log; closing a
+// post logger does nothing
 func (l *Logger) Close() error {
+	if l.parent != nil {
+		return nil
+	}
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	if l.system != nil {
+		return l.system.Close()
+	}
 	if l.logFile != nil {
 		return l.logFile.Close()
 	}
@@ -165,26 +449,3 @@ func (l *Logger) LogPAR2Creation(fileName string, par2Files []string) {
 func (l *Logger) LogSFVCreation(fileName string, sfvPath string) {
 	l.Info("Created SFV file: %s", sfvPath)
 }
-
-// HistoryLogger handles posting history logging
-type HistoryLogger struct {
-	logger *Logger
-}
-
-// NewHistoryLogger creates a new history logger
-func NewHistoryLogger(logger *Logger) *HistoryLogger {
-	return &HistoryLogger{
-		logger: logger,
-	}
-}
-
-// LogPosting logs a posting operation to history
-func (h *HistoryLogger) LogPosting(fileName string, fileSize int64, totalParts int, nzbPath string, success bool) {
-	h.logger.Info("HISTORY: %s (%d bytes, %d parts) -> %s [success: %v]", 
-		fileName, fileSize, totalParts, nzbPath, success)
-}
-
-// LogError logs an error to history
-func (h *HistoryLogger) LogError(fileName string, err error) {
-	h.logger.Error("HISTORY ERROR: %s - %v", fileName, err)
-}
\ No newline at end of file
@@ -0,0 +1,11 @@
+package logger
+
+// systemLog is a log service of the operating system, keeping the level of
+// each message
+type systemLog interface {
+	write(level LogLevel, message string) error
+	Close() error
+}
+
+// tag identifies the messages of yPost in the system log
+const tag = "ypost"
@@ -0,0 +1,10 @@
+//go:build plan9
+
+package logger
+
+import "fmt"
+
+// openSystemLog fails: Plan 9 has no system log service
+func openSystemLog(backend string) (systemLog, error) {
+	return nil, fmt.Errorf("%s is not available on this system", backend)
+}
@@ -0,0 +1,49 @@
+//go:build !windows && !plan9
+
+package logger
+
+import (
+	"fmt"
+	"log/syslog"
+)
+
+// syslogNetwork and syslogAddress are where syslog messages are sent, the
+// local syslog daemon when empty
+var syslogNetwork, syslogAddress string
+
+// openSystemLog connects to the syslog daemon, which journald stands in for
+// on systemd hosts
+func openSystemLog(backend string) (systemLog, error) {
+	if backend == BackendEventLog {
+		return nil, fmt.Errorf("the event log is only available on Windows")
+	}
+	writer, err := syslog.Dial(syslogNetwork, syslogAddress, syslog.LOG_DAEMON|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogWriter{writer: writer}, nil
+}
+
+// syslogWriter writes to syslog at the severity of each level
+type syslogWriter struct {
+	writer *syslog.Writer
+}
+
+func (s *syslogWriter) write(level LogLevel, message string) error {
+	switch level {
+	case DEBUG:
+		return s.writer.Debug(message)
+	case INFO:
+		return s.writer.Info(message)
+	case WARN:
+		return s.writer.Warning(message)
+	case ERROR:
+		return s.writer.Err(message)
+	default:
+		return s.writer.Crit(message)
+	}
+}
+
+func (s *syslogWriter) Close() error {
+	return s.writer.Close()
+}
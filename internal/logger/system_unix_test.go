@@ -0,0 +1,59 @@
+//go:build !windows && !plan9
+
+package logger
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogBackend(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "log")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unix datagram sockets unavailable: %v", err)
+	}
+	defer conn.Close()
+	syslogNetwork, syslogAddress = "unixgram", socket
+	defer func() { syslogNetwork, syslogAddress = "", "" }()
+
+	log, err := NewWithOptions(Options{Backend: "syslog", Level: INFO})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Debug("hidden debug")
+	log.Warn("disk %s", "full")
+	log.Module("par2").Error("failed")
+	log.Close()
+
+	// <priority>: LOG_DAEMON is facility 3, warning severity 4 and error 3
+	expected := []string{"<28>", "ypost", "disk full", "<27>", "[par2] failed"}
+	var received strings.Builder
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 4096)
+	for i := 0; i < 2; i++ {
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		received.Write(buf[:n])
+	}
+	for _, part := range expected {
+		if !strings.Contains(received.String(), part) {
+			t.Errorf("expected %q in the syslog messages %q", part, received.String())
+		}
+	}
+	if strings.Contains(received.String(), "hidden debug") || strings.Contains(received.String(), "WARN:") {
+		t.Errorf("unexpected syslog content %q", received.String())
+	}
+
+	if _, err := NewWithOptions(Options{Backend: "eventlog"}); err == nil {
+		t.Error("expected the event log to be unavailable")
+	}
+	if _, err := ParseBackend("kafka"); err == nil {
+		t.Error("expected an unknown backend to fail")
+	}
+}
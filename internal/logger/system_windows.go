@@ -0,0 +1,52 @@
+//go:build windows
+
+package logger
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// eventID is the event identifier of every message, which the
+// EventCreate message file displays as is
+const eventID = 1
+
+// openSystemLog opens the Windows event log under the ypost source,
+// registering it on first use, which needs administrator rights
+func openSystemLog(backend string) (systemLog, error) {
+	if backend != BackendEventLog {
+		return nil, fmt.Errorf("%s is not available on Windows, use the event log", backend)
+	}
+	err := eventlog.InstallAsEventCreate(tag, eventlog.Error|eventlog.Warning|eventlog.Info)
+	if err != nil && !strings.Contains(err.Error(), "exists") {
+		return nil, fmt.Errorf("failed to register the %s event source: %w", tag, err)
+	}
+	writer, err := eventlog.Open(tag)
+	if err != nil {
+		return nil, err
+	}
+	return &eventLogWriter{writer: writer}, nil
+}
+
+// eventLogWriter writes to the event log, which has no debug type: debug
+// lines are information events
+type eventLogWriter struct {
+	writer *eventlog.Log
+}
+
+func (e *eventLogWriter) write(level LogLevel, message string) error {
+	switch level {
+	case DEBUG, INFO:
+		return e.writer.Info(eventID, message)
+	case WARN:
+		return e.writer.Warning(eventID, message)
+	default:
+		return e.writer.Error(eventID, message)
+	}
+}
+
+func (e *eventLogWriter) Close() error {
+	return e.writer.Close()
+}
@@ -0,0 +1,75 @@
+package memory
+
+import "sync"
+
+// Budget bounds the bytes the buffers of a process hold at once: the
+// articles in flight and the PAR2 working set. A nil budget is unlimited.
+type Budget struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int64
+	used  int64
+	// next and serving are tickets granting the acquisitions in order, so
+	// small ones never starve a large one
+	next    uint64
+	serving uint64
+}
+
+// NewBudget creates a budget of limit bytes, nil (unlimited) when limit is 0
+// or less
+func NewBudget(limit int64) *Budget {
+	if limit <= 0 {
+		return nil
+	}
+	b := &Budget{limit: limit}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// Limit returns the bytes of the budget, 0 when unlimited
+func (b *Budget) Limit() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.limit
+}
+
+// Acquire blocks until n bytes fit in the budget, then holds them.
+// Acquisitions are granted in order, and more than the limit once nothing
+// else is held, so a single large buffer still goes through, alone.
+func (b *Budget) Acquire(n int64) {
+	if b == nil || n <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ticket := b.next
+	b.next++
+	for ticket != b.serving || (b.used > 0 && b.used+n > b.limit) {
+		b.cond.Wait()
+	}
+	b.used += n
+	b.serving++
+	b.cond.Broadcast()
+}
+
+// Release gives back n bytes acquired before
+func (b *Budget) Release(n int64) {
+	if b == nil || n <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= n
+	b.cond.Broadcast()
+}
+
+// Used returns the bytes held
+func (b *Budget) Used() int64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
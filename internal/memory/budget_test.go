@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBudget(t *testing.T) {
+	budget := NewBudget(100)
+	budget.Acquire(60)
+	budget.Acquire(40)
+	if used := budget.Used(); used != 100 {
+		t.Fatalf("expected 100 bytes held, got %d", used)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		budget.Acquire(30)
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("expected Acquire to wait past the limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+	budget.Release(40)
+	<-acquired
+	if used := budget.Used(); used != 90 {
+		t.Errorf("expected 90 bytes held, got %d", used)
+	}
+	budget.Release(90)
+
+	// More than the limit goes through once the budget is free
+	budget.Acquire(500)
+	if used := budget.Used(); used != 500 {
+		t.Errorf("expected the large buffer granted, got %d", used)
+	}
+	budget.Release(500)
+}
+
+func TestBudgetOrder(t *testing.T) {
+	budget := NewBudget(100)
+	budget.Acquire(80)
+
+	// A small acquisition that would fit waits behind a larger one
+	large := make(chan struct{})
+	go func() {
+		budget.Acquire(50)
+		close(large)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	small := make(chan struct{})
+	go func() {
+		budget.Acquire(10)
+		close(small)
+	}()
+	select {
+	case <-small:
+		t.Fatal("expected the small acquisition to wait for the large one")
+	case <-time.After(20 * time.Millisecond):
+	}
+	budget.Release(80)
+	<-large
+	<-small
+	if used := budget.Used(); used != 60 {
+		t.Errorf("expected 60 bytes held, got %d", used)
+	}
+}
+
+func TestUnlimitedBudget(t *testing.T) {
+	budget := NewBudget(0)
+	if budget != nil {
+		t.Fatal("expected no budget without a limit")
+	}
+	// A nil budget grants everything
+	budget.Acquire(1 << 40)
+	budget.Release(1 << 40)
+	if budget.Limit() != 0 || budget.Used() != 0 {
+		t.Error("expected an unlimited budget to hold nothing")
+	}
+}
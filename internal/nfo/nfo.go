@@ -0,0 +1,147 @@
+// Package nfo writes the NFO of a post, the release information file
+// posted along with it, from a template or as provided
+package nfo
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"ypost/internal/utils"
+	"ypost/pkg/models"
+)
+
+// defaultTemplate lays out the release like the NFOs of common releases: a
+// summary, then each file with its size and CRC32
+const defaultTemplate = `{{.Name}}
+
+  Size ......: {{size .Size}} ({{.Size}} bytes)
+  Files .....: {{len .Files}}
+  Posted ....: {{.Date.Format "2006-01-02 15:04 MST"}}
+  Group .....: {{.Group}}
+{{- if .Poster}}
+  Poster ....: {{.Poster}}
+{{- end}}
+
+  Files
+{{- range .Files}}
+  {{printf "%-48s %10s  %s" .Name (size .Size) .CRC32}}
+{{- end}}
+
+  Posted with ypost
+`
+
+// File is a posted file listed in the NFO
+type File struct {
+	Name string
+	Size int64
+	// CRC32 is the checksum of the file in hex, empty when unknown
+	CRC32 string
+}
+
+// Release is what a template receives: the post, its files and the time it
+// was posted
+type Release struct {
+	Name   string
+	Size   int64
+	Files  []File
+	Group  string
+	Poster string
+	PostID string
+	Date   time.Time
+}
+
+// Generator writes the NFO of posts
+type Generator struct {
+	template *template.Template
+	file     string
+}
+
+// New checks the NFO settings and returns their generator. The template is
+// read and parsed now, so a broken one fails before anything is posted.
+func New(cfg models.NFOConfig) (*Generator, error) {
+	source := defaultTemplate
+	if cfg.Template != "" {
+		data, err := os.ReadFile(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read NFO template: %w", err)
+		}
+		source = string(data)
+	}
+	tmpl, err := template.New("nfo").Funcs(template.FuncMap{
+		"size": utils.FormatFileSize,
+	}).Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NFO template: %w", err)
+	}
+
+	generator := &Generator{file: cfg.File}
+	if cfg.Generate {
+		generator.template = tmpl
+	}
+	return generator, nil
+}
+
+// Enabled reports whether posts get an NFO
+func (g *Generator) Enabled() bool {
+	return g.file != "" || g.template != nil
+}
+
+// Provided reports whether the NFO is a file of the user, posted as is
+func (g *Generator) Provided() bool {
+	return g.file != ""
+}
+
+// Check fails when the provided NFO cannot be posted
+func (g *Generator) Check() error {
+	if g.file == "" {
+		return nil
+	}
+	info, err := os.Stat(g.file)
+	if err != nil {
+		return fmt.Errorf("failed to read NFO file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("NFO file %s is not a regular file", g.file)
+	}
+	return nil
+}
+
+// Render returns the NFO of release from the template
+func (g *Generator) Render(release Release) ([]byte, error) {
+	if g.template == nil {
+		return nil, fmt.Errorf("no NFO template")
+	}
+	var buf bytes.Buffer
+	if err := g.template.Execute(&buf, release); err != nil {
+		return nil, fmt.Errorf("failed to render NFO: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Create returns the path of the NFO of release: the provided file, or one
+// rendered into outputDir as name
+func (g *Generator) Create(outputDir string, name string, release Release) (string, error) {
+	if g.file != "" {
+		if err := g.Check(); err != nil {
+			return "", err
+		}
+		return g.file, nil
+	}
+
+	content, err := g.Render(release)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	nfoPath := filepath.Join(outputDir, name)
+	if err := os.WriteFile(nfoPath, content, 0644); err != nil {
+		return "", fmt.Errorf("failed to write NFO file: %w", err)
+	}
+	return nfoPath, nil
+}
@@ -0,0 +1,113 @@
+package nfo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ypost/pkg/models"
+)
+
+func testRelease() Release {
+	return Release{
+		Name:   "holiday",
+		Size:   3 << 20,
+		Group:  "alt.binaries.test",
+		Poster: "poster@example.com",
+		Date:   time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		Files: []File{
+			{Name: "holiday.mkv", Size: 2 << 20, CRC32: "1A2B3C4D"},
+			{Name: "extras/notes.txt", Size: 1 << 20, CRC32: "DEADBEEF"},
+		},
+	}
+}
+
+func TestDefaultTemplate(t *testing.T) {
+	generator, err := New(models.NFOConfig{Generate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := generator.Render(testRelease())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"holiday\n", "3.0MB (3145728 bytes)", "Files .....: 2", "2024-05-01 12:30 UTC", "alt.binaries.test", "holiday.mkv", "2.0MB  1A2B3C4D", "extras/notes.txt"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("expected %q in the NFO:\n%s", want, content)
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "release.tmpl")
+	if err := os.WriteFile(templatePath, []byte("{{.Name}} {{range .Files}}[{{.Name}} {{.CRC32}}]{{end}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	generator, err := New(models.NFOConfig{Generate: true, Template: templatePath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !generator.Enabled() || generator.Provided() {
+		t.Error("expected a generated NFO")
+	}
+	nfoPath, err := generator.Create(filepath.Join(dir, "out"), "holiday.nfo", testRelease())
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(nfoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "holiday [holiday.mkv 1A2B3C4D][extras/notes.txt DEADBEEF]"; string(content) != want {
+		t.Errorf("expected %q, got %q", want, content)
+	}
+}
+
+func TestProvidedFile(t *testing.T) {
+	dir := t.TempDir()
+	provided := filepath.Join(dir, "release.nfo")
+	if err := os.WriteFile(provided, []byte("my release"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file is posted as is, even with generation on
+	generator, err := New(models.NFOConfig{Generate: true, File: provided})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !generator.Provided() {
+		t.Error("expected the provided NFO")
+	}
+	nfoPath, err := generator.Create(filepath.Join(dir, "out"), "holiday.nfo", testRelease())
+	if err != nil || nfoPath != provided {
+		t.Errorf("expected %s, got %s (%v)", provided, nfoPath, err)
+	}
+
+	generator, err = New(models.NFOConfig{File: filepath.Join(dir, "missing.nfo")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := generator.Create(dir, "holiday.nfo", testRelease()); err == nil {
+		t.Error("expected a missing NFO file to fail")
+	}
+}
+
+func TestInvalidTemplate(t *testing.T) {
+	if _, err := New(models.NFOConfig{Template: filepath.Join(t.TempDir(), "missing.tmpl")}); err == nil {
+		t.Error("expected a missing template to be refused")
+	}
+	templatePath := filepath.Join(t.TempDir(), "broken.tmpl")
+	if err := os.WriteFile(templatePath, []byte("{{.Name"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(models.NFOConfig{Generate: true, Template: templatePath}); err == nil {
+		t.Error("expected a broken template to be refused")
+	}
+	generator, err := New(models.NFOConfig{})
+	if err != nil || generator.Enabled() {
+		t.Errorf("expected no NFO by default, got %v", err)
+	}
+}
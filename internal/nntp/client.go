@@ -3,9 +3,12 @@ package nntp
 import (
 	"bufio"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/textproto"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -13,6 +16,42 @@ import (
 	"ypost/pkg/models"
 )
 
+// writeBufferSize is the buffer of the writes to a connection: articles are
+// written in blocks of it rather than line by line
+const writeBufferSize = 64 << 10
+
+// ErrNoArticle is returned for a message ID the server has no article for
+var ErrNoArticle = errors.New("no such article")
+
+// ErrOutcomeUnknown is returned when the connection was lost while an
+// article was posted, before the server answered: it may have the article
+// or not
+var ErrOutcomeUnknown = errors.New("connection lost before the article was answered")
+
+// Throttled reports whether err is a server refusing more connections or
+// traffic for now: 400 and 502, or 481 and 482 naming connections, which
+// providers answer when the account's connections are all in use
+func Throttled(err error) bool {
+	var protoErr *textproto.Error
+	if !errors.As(err, &protoErr) {
+		return false
+	}
+	switch protoErr.Code {
+	case 400, 502:
+		return true
+	case 481, 482:
+		return strings.Contains(strings.ToLower(protoErr.Msg), "connections")
+	}
+	return false
+}
+
+// AuthRequired reports whether err is a server asking for authentication
+// before the command: 480
+func AuthRequired(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr) && protoErr.Code == 480
+}
+
 // Client represents an NNTP client connection
 type Client struct {
 	conn      net.Conn
@@ -20,7 +59,12 @@ type Client struct {
 	writer    *textproto.Writer
 	config    *models.ServerConfig
 	connected bool
-	mu        sync.Mutex
+	// postingAllowed is whether the welcome message allowed posting
+	postingAllowed bool
+	// group is the newsgroup selected on the connection, empty before the
+	// first GROUP
+	group string
+	mu    sync.Mutex
 }
 
 // NewClient creates a new NNTP client
@@ -39,7 +83,7 @@ func (c *Client) Connect() error {
 		return nil
 	}
 
-	address := fmt.Sprintf("%s:%d", c.config.Host, c.config.Port)
+	address := net.JoinHostPort(c.config.Host, strconv.Itoa(c.config.Port))
 	
 	var conn net.Conn
 	var err error
@@ -56,18 +100,89 @@ func (c *Client) Connect() error {
 		return fmt.Errorf("failed to connect to %s: %w", address, err)
 	}
 
-	c.conn = conn
-	c.reader = textproto.NewReader(bufio.NewReader(conn))
-	c.writer = textproto.NewWriter(bufio.NewWriter(conn))
+	c.attach(conn)
 
-	// Read welcome message
-	_, _, err = c.reader.ReadCodeLine(200)
+	// Read welcome message: 200 when posting is allowed, 201 when not
+	code, _, err := c.reader.ReadCodeLine(20)
 	if err != nil {
 		c.conn.Close()
 		return fmt.Errorf("failed to read welcome message: %w", err)
 	}
 
 	c.connected = true
+	c.postingAllowed = code == 200
+	c.group = ""
+	return nil
+}
+
+// attach sets up the reader and writer of a connection
+func (c *Client) attach(conn net.Conn) {
+	c.conn = conn
+	c.reader = textproto.NewReader(bufio.NewReader(conn))
+	c.writer = textproto.NewWriter(bufio.NewWriterSize(conn, writeBufferSize))
+}
+
+// PostingAllowed reports whether the server's welcome message allowed
+// posting. Servers may only allow it after authentication, which
+// Capabilities reflects.
+func (c *Client) PostingAllowed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.postingAllowed
+}
+
+// Capabilities returns the capability labels the server advertises (RFC
+// 3977), such as POST or READER, upper-cased
+func (c *Client) Capabilities() ([]string, error) {
+	if !c.connected {
+		return nil, fmt.Errorf("not connected to server")
+	}
+
+	if err := c.writer.PrintfLine("CAPABILITIES"); err != nil {
+		return nil, fmt.Errorf("failed to send CAPABILITIES command: %w", err)
+	}
+	if _, _, err := c.reader.ReadCodeLine(101); err != nil {
+		return nil, fmt.Errorf("failed to list capabilities: %w", err)
+	}
+	lines, err := c.reader.ReadDotLines()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list capabilities: %w", err)
+	}
+
+	var capabilities []string
+	for _, line := range lines {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			capabilities = append(capabilities, strings.ToUpper(fields[0]))
+		}
+	}
+	return capabilities, nil
+}
+
+// ModeReader switches the server to reader mode (MODE READER), which servers
+// running in transit mode need before STAT or BODY. Servers without the
+// command only read, their refusal of it is ignored.
+func (c *Client) ModeReader() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.connected {
+		return fmt.Errorf("not connected to server")
+	}
+
+	if err := c.writer.PrintfLine("MODE READER"); err != nil {
+		return fmt.Errorf("failed to send MODE READER command: %w", err)
+	}
+	// 200 when posting is allowed, 201 when not, as in the welcome message
+	code, _, err := c.reader.ReadCodeLine(20)
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && (protoErr.Code == 500 || protoErr.Code == 501) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to switch to reader mode: %w", err)
+	}
+	c.postingAllowed = code == 200
+	c.group = ""
 	return nil
 }
 
@@ -102,93 +217,112 @@ func (c *Client) Authenticate() error {
 	return nil
 }
 
-// PostArticle posts an article to the specified newsgroup
+// PostArticle posts an article to the specified newsgroup. A Message-ID in
+// headers is posted and returned instead of a random one at
+// DefaultMessageIDDomain.
+//
+// When the connection is lost once the article is on its way, the server
+// may have it or not: the client reconnects and looks the Message-ID up,
+// posting the article again only when the server does not have it.
 func (c *Client) PostArticle(group string, subject string, from string, body string, headers map[string]string) (string, error) {
-	if !c.connected {
-		return "", fmt.Errorf("not connected to server")
+	messageID := NewMessageID(DefaultMessageIDDomain)
+	if id, ok := headers["Message-ID"]; ok && id != "" {
+		messageID = id
 	}
 
-	// Send POST command
-	err := c.writer.PrintfLine("POST")
-	if err != nil {
-		return "", fmt.Errorf("failed to send POST command: %w", err)
+	err := c.post(group, subject, from, body, headers, messageID)
+	if !errors.Is(err, ErrOutcomeUnknown) {
+		return messageID, err
 	}
-
-	_, _, err = c.reader.ReadCodeLine(340)
+	if err := c.Reconnect(); err != nil {
+		return "", fmt.Errorf("failed to reconnect after losing the connection: %w", err)
+	}
+	found, err := c.Stat(messageID)
 	if err != nil {
-		return "", fmt.Errorf("server rejected POST command: %w", err)
+		return "", fmt.Errorf("failed to look up the article after losing the connection: %w", err)
 	}
+	if found {
+		return messageID, nil
+	}
+	if err := c.post(group, subject, from, body, headers, messageID); err != nil {
+		return "", err
+	}
+	return messageID, nil
+}
 
-	// Generate Message-ID matching Node.js format
-	// Node.js uses: random chars + '-' + timestamp + '@nyuu'
-	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
-	timestamp := fmt.Sprintf("%013d", time.Now().UnixNano()/1000000)
-	
-	var randomChars strings.Builder
-	for i := 0; i < 24; i++ {
-		randomChars.WriteByte(chars[time.Now().UnixNano()%int64(len(chars))])
+// post sends an article under messageID. Past the server's 340, a failure
+// of the connection rather than a refusal drops the connection and returns
+// ErrOutcomeUnknown.
+func (c *Client) post(group string, subject string, from string, body string, headers map[string]string, messageID string) error {
+	if !c.connected {
+		return fmt.Errorf("not connected to server")
 	}
-	
-	messageID := fmt.Sprintf("<%s-%s@nyuu>", randomChars.String()[:24], timestamp)
 
-	// Write headers
-	headersToSend := map[string]string{
+	// Headers that cannot be written as they are fail the article before
+	// it is offered to the server
+	headersToSend, err := articleHeaders(map[string]string{
 		"From":         from,
 		"Subject":      subject,
 		"Newsgroups":   group,
 		"Message-ID":   messageID,
-		"Date":         time.Now().Format(time.RFC1123Z),
+		"Date":         FormatDate(time.Now()),
 		"Content-Type": "text/plain; charset=UTF-8",
+	}, headers)
+	if err != nil {
+		return fmt.Errorf("invalid article headers: %w", err)
 	}
 
-	// Add custom headers
-	for k, v := range headers {
-		headersToSend[k] = v
-	}
-
-	// Send headers
-	for key, value := range headersToSend {
-		err := c.writer.PrintfLine("%s: %s", key, value)
-		if err != nil {
-			return "", fmt.Errorf("failed to send header %s: %w", key, err)
-		}
+	// Send POST command
+	err = c.writer.PrintfLine("POST")
+	if err != nil {
+		return fmt.Errorf("failed to send POST command: %w", err)
 	}
 
-	// Send empty line to separate headers from body
-	err = c.writer.PrintfLine("")
+	_, _, err = c.reader.ReadCodeLine(340)
 	if err != nil {
-		return "", fmt.Errorf("failed to send header separator: %w", err)
+		return fmt.Errorf("server rejected POST command: %w", err)
 	}
 
-	// Send body
-	bodyLines := strings.Split(body, "\n")
-	for _, line := range bodyLines {
-		// Handle dot-stuffing (lines starting with .)
-		if strings.HasPrefix(line, ".") {
-			line = "." + line
-		}
-		err := c.writer.PrintfLine(line)
-		if err != nil {
-			return "", fmt.Errorf("failed to send body line: %w", err)
-		}
-	}
+	// The article goes through the connection's buffer, written out as it
+	// fills and flushed once at the end; its errors stick until the flush
+	w := c.writer.W
 
-	// Send termination
-	err = c.writer.PrintfLine(".")
-	if err != nil {
-		return "", fmt.Errorf("failed to send termination: %w", err)
+	// Send headers in their order, then an empty line to separate them
+	// from the body
+	for _, h := range headersToSend {
+		fmt.Fprintf(w, "%s: %s\r\n", h.name, h.value)
+	}
+	w.WriteString("\r\n")
+
+	// Send the body, then the terminating dot: the dot writer ends its lines
+	// with CRLF, whether they end with LF or CRLF, and doubles their leading
+	// dots, leaving every other byte as it is
+	dot := c.writer.DotWriter()
+	io.WriteString(dot, body)
+	if err := dot.Close(); err != nil {
+		c.drop()
+		return fmt.Errorf("failed to send article: %w: %w", ErrOutcomeUnknown, err)
 	}
 
 	_, _, err = c.reader.ReadCodeLine(240)
+	var protoErr *textproto.Error
+	if err != nil && !errors.As(err, &protoErr) {
+		c.drop()
+		return fmt.Errorf("failed to read the article response: %w: %w", ErrOutcomeUnknown, err)
+	}
 	if err != nil {
-		return "", fmt.Errorf("server rejected article: %w", err)
+		return fmt.Errorf("server rejected article: %w", err)
 	}
-
-	return messageID, nil
+	return nil
 }
 
-// JoinGroup joins the specified newsgroup
+// JoinGroup joins the specified newsgroup. The group stays selected on the
+// connection, joining it again sends nothing.
 func (c *Client) JoinGroup(group string) error {
+	if c.group == group {
+		return nil
+	}
+
 	err := c.writer.PrintfLine("GROUP %s", group)
 	if err != nil {
 		return fmt.Errorf("failed to send GROUP command: %w", err)
@@ -199,9 +333,87 @@ func (c *Client) JoinGroup(group string) error {
 		return fmt.Errorf("failed to join group %s: %w", group, err)
 	}
 
+	c.group = group
 	return nil
 }
 
+// Stat reports whether the server has the article with the given message
+// ID, with or without angle brackets
+func (c *Client) Stat(messageID string) (bool, error) {
+	if !c.connected {
+		return false, fmt.Errorf("not connected to server")
+	}
+
+	messageID = "<" + strings.Trim(messageID, "<>") + ">"
+	if err := c.writer.PrintfLine("STAT %s", messageID); err != nil {
+		return false, fmt.Errorf("failed to send STAT command: %w", err)
+	}
+
+	code, message, err := c.reader.ReadCodeLine(223)
+	if err == nil {
+		return true, nil
+	}
+	// 430: no article with that message ID
+	if code == 430 {
+		return false, nil
+	}
+	if code != 0 {
+		return false, fmt.Errorf("STAT %s failed: %d %s", messageID, code, message)
+	}
+	return false, fmt.Errorf("failed to read STAT response: %w", err)
+}
+
+// Body fetches the body of the article with the given message ID, with or
+// without angle brackets. Dot-stuffing is undone and lines end in "\n". A
+// missing article returns ErrNoArticle.
+func (c *Client) Body(messageID string) ([]byte, error) {
+	if !c.connected {
+		return nil, fmt.Errorf("not connected to server")
+	}
+
+	messageID = "<" + strings.Trim(messageID, "<>") + ">"
+	if err := c.writer.PrintfLine("BODY %s", messageID); err != nil {
+		return nil, fmt.Errorf("failed to send BODY command: %w", err)
+	}
+
+	code, message, err := c.reader.ReadCodeLine(222)
+	if err != nil {
+		if code == 430 {
+			return nil, ErrNoArticle
+		}
+		if code != 0 {
+			return nil, fmt.Errorf("BODY %s failed: %d %s", messageID, code, message)
+		}
+		return nil, fmt.Errorf("failed to read BODY response: %w", err)
+	}
+
+	body, err := c.reader.ReadDotBytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body of %s: %w", messageID, err)
+	}
+	return body, nil
+}
+
+// Reconnect replaces the connection with a new, authenticated one
+func (c *Client) Reconnect() error {
+	c.drop()
+	if err := c.Connect(); err != nil {
+		return err
+	}
+	return c.Authenticate()
+}
+
+// drop closes a connection that failed, without the QUIT it could not take
+func (c *Client) drop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.connected {
+		c.conn.Close()
+		c.connected = false
+	}
+}
+
 // Quit closes the connection
 func (c *Client) Quit() error {
 	c.mu.Lock()
@@ -225,62 +437,76 @@ func (c *Client) IsConnected() bool {
 	return c.connected
 }
 
-// ConnectionPool manages multiple NNTP connections
+// ConnectionPool manages multiple NNTP connections. Each client is used by
+// one caller at a time, from GetClient until it is given back with Release.
 type ConnectionPool struct {
-	clients    []*Client
-	config     *models.ServerConfig
-	maxConns   int
-	current    int
-	mu         sync.Mutex
+	clients  []*Client
+	// idle are the clients connected or created that no caller is using
+	idle     []*Client
+	config   *models.ServerConfig
+	maxConns int
+	mu       sync.Mutex
+	// released is signalled when a client is given back
+	released *sync.Cond
 }
 
 // NewConnectionPool creates a new connection pool
 func NewConnectionPool(config *models.ServerConfig, maxConns int) *ConnectionPool {
-	return &ConnectionPool{
+	if maxConns < 1 {
+		maxConns = 1
+	}
+	p := &ConnectionPool{
 		config:   config,
 		maxConns: maxConns,
 		clients:  make([]*Client, 0, maxConns),
 	}
+	p.released = sync.NewCond(&p.mu)
+	return p
 }
 
-// GetClient returns an available client from the pool
+// GetClient takes a client from the pool for the caller's use: an idle one,
+// reconnected when its connection was lost, or a new one while the pool has
+// fewer than its maximum. When every client is in use it waits for one to be
+// released. The client goes back to the pool with Release, also after an
+// error.
 func (p *ConnectionPool) GetClient() (*Client, error) {
 	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	// Try to find an available client
-	for _, client := range p.clients {
-		if client.IsConnected() {
-			return client, nil
-		}
+	for len(p.idle) == 0 && len(p.clients) >= p.maxConns {
+		p.released.Wait()
 	}
 
-	// Create new client if we haven't reached max connections
-	if len(p.clients) < p.maxConns {
-		client := NewClient(p.config)
-		err := client.Connect()
-		if err != nil {
-			return nil, err
-		}
+	var client *Client
+	if n := len(p.idle); n > 0 {
+		client = p.idle[n-1]
+		p.idle = p.idle[:n-1]
+	} else {
+		client = NewClient(p.config)
+		p.clients = append(p.clients, client)
+	}
+	p.mu.Unlock()
 
-		err = client.Authenticate()
-		if err != nil {
-			client.Quit()
+	// Connections are made outside the lock, not holding up the others
+	if !client.IsConnected() {
+		if err := client.Reconnect(); err != nil {
+			client.drop()
+			p.Release(client)
 			return nil, err
 		}
-
-		p.clients = append(p.clients, client)
-		return client, nil
 	}
+	return client, nil
+}
 
-	// Reuse existing client (round-robin)
-	if len(p.clients) > 0 {
-		client := p.clients[p.current%len(p.clients)]
-		p.current++
-		return client, nil
-	}
+// Release gives a client taken with GetClient back to the pool
+func (p *ConnectionPool) Release(client *Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idle = append(p.idle, client)
+	p.released.Signal()
+}
 
-	return nil, fmt.Errorf("no clients available")
+// Server returns the configuration of the pool's server
+func (p *ConnectionPool) Server() *models.ServerConfig {
+	return p.config
 }
 
 // CloseAll closes all connections in the pool
@@ -292,4 +518,5 @@ func (p *ConnectionPool) CloseAll() {
 		client.Quit()
 	}
 	p.clients = nil
+	p.idle = nil
 }
\ No newline at end of file
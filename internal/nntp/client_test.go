@@ -0,0 +1,480 @@
+package nntp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ypost/pkg/models"
+)
+
+// serveNNTP accepts one connection on a local port and answers each command
+// line with handle's response
+func serveNNTP(t *testing.T, handle func(line string) string) *models.ServerConfig {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := textproto.NewReader(bufio.NewReader(conn))
+		writer := textproto.NewWriter(bufio.NewWriter(conn))
+		writer.PrintfLine("200 test server ready")
+		for {
+			line, err := reader.ReadLine()
+			if err != nil || line == "QUIT" {
+				return
+			}
+			writer.PrintfLine("%s", handle(line))
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return &models.ServerConfig{Host: "127.0.0.1", Port: addr.Port}
+}
+
+func TestStat(t *testing.T) {
+	config := serveNNTP(t, func(line string) string {
+		switch line {
+		case "STAT <present@test>":
+			return "223 0 <present@test>"
+		case "STAT <missing@test>":
+			return "430 no such article"
+		}
+		return "500 unknown command " + strconv.Quote(line)
+	})
+
+	client := NewClient(config)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+
+	if found, err := client.Stat("present@test"); err != nil || !found {
+		t.Errorf("expected present@test to be found, got %v, %v", found, err)
+	}
+	if found, err := client.Stat("<missing@test>"); err != nil || found {
+		t.Errorf("expected missing@test to be missing, got %v, %v", found, err)
+	}
+	if _, err := client.Stat("bad id"); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("expected a 500 error, got %v", err)
+	}
+}
+
+func TestBody(t *testing.T) {
+	config := serveNNTP(t, func(line string) string {
+		switch line {
+		case "BODY <present@test>":
+			return "222 0 <present@test>\r\nfirst line\r\n..dotted\r\n."
+		case "BODY <missing@test>":
+			return "430 no such article"
+		}
+		return "500 unknown command"
+	})
+
+	client := NewClient(config)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+
+	body, err := client.Body("present@test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "first line\n.dotted\n" {
+		t.Errorf("unexpected body %q", body)
+	}
+	if _, err := client.Body("missing@test"); err != ErrNoArticle {
+		t.Errorf("expected ErrNoArticle, got %v", err)
+	}
+}
+
+func TestJoinGroup(t *testing.T) {
+	var joins []string
+	config := serveNNTP(t, func(line string) string {
+		if group, ok := strings.CutPrefix(line, "GROUP "); ok {
+			joins = append(joins, group)
+			if group == "alt.missing" {
+				return "411 no such group"
+			}
+			return "211 0 0 0 " + group
+		}
+		return "500 unknown command"
+	})
+
+	client := NewClient(config)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+
+	for _, group := range []string{"alt.test", "alt.test", "alt.other", "alt.missing", "alt.other"} {
+		wantErr := group == "alt.missing"
+		if err := client.JoinGroup(group); (err != nil) != wantErr {
+			t.Errorf("joining %s: unexpected error %v", group, err)
+		}
+	}
+	if strings.Join(joins, " ") != "alt.test alt.other alt.missing" {
+		t.Errorf("unexpected GROUP commands %v", joins)
+	}
+}
+
+func TestModeReader(t *testing.T) {
+	tests := []struct {
+		response string
+		posting  bool
+		wantErr  bool
+		auth     bool
+	}{
+		{"200 reader mode, posting allowed", true, false, false},
+		{"201 reader mode, no posting", false, false, false},
+		{"500 unknown command", true, false, false},
+		{"502 reading service unavailable", true, true, false},
+		{"480 authentication required", true, true, true},
+	}
+
+	for _, test := range tests {
+		config := serveNNTP(t, func(line string) string {
+			if line == "MODE READER" {
+				return test.response
+			}
+			return "500 unknown command"
+		})
+
+		client := NewClient(config)
+		if err := client.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		err := client.ModeReader()
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: unexpected error %v", test.response, err)
+		}
+		if AuthRequired(err) != test.auth {
+			t.Errorf("%s: expected AuthRequired %v", test.response, test.auth)
+		}
+		if client.PostingAllowed() != test.posting {
+			t.Errorf("%s: expected posting allowed %v", test.response, test.posting)
+		}
+		client.Quit()
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	config := serveNNTP(t, func(line string) string {
+		if line == "CAPABILITIES" {
+			return "101 capability list\r\nVERSION 2\r\nreader\r\nPOST\r\nAUTHINFO USER\r\n."
+		}
+		return "500 unknown command"
+	})
+
+	client := NewClient(config)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+
+	if !client.PostingAllowed() {
+		t.Error("expected the 200 welcome message to allow posting")
+	}
+	capabilities, err := client.Capabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(capabilities, " ") != "VERSION READER POST AUTHINFO" {
+		t.Errorf("unexpected capabilities %v", capabilities)
+	}
+}
+
+func TestThrottled(t *testing.T) {
+	for _, test := range []struct {
+		err       error
+		throttled bool
+	}{
+		{&textproto.Error{Code: 400, Msg: "service temporarily unavailable"}, true},
+		{fmt.Errorf("failed to read welcome message: %w", &textproto.Error{Code: 502, Msg: "too many connections"}), true},
+		{&textproto.Error{Code: 481, Msg: "Too many connections for your user"}, true},
+		{&textproto.Error{Code: 481, Msg: "authentication rejected"}, false},
+		{&textproto.Error{Code: 441, Msg: "posting failed"}, false},
+		{errors.New("connection reset"), false},
+	} {
+		if got := Throttled(test.err); got != test.throttled {
+			t.Errorf("Throttled(%v) = %v, expected %v", test.err, got, test.throttled)
+		}
+	}
+}
+
+// countingConn counts the writes to a connection
+type countingConn struct {
+	net.Conn
+	writes int
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	c.writes++
+	return c.Conn.Write(p)
+}
+
+func TestPostArticleBatched(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// The server reads the article of a POST whole
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := textproto.NewReader(bufio.NewReader(conn))
+		writer := textproto.NewWriter(bufio.NewWriter(conn))
+		writer.PrintfLine("200 test server ready")
+		if line, err := reader.ReadLine(); err != nil || line != "POST" {
+			return
+		}
+		writer.PrintfLine("340 send article")
+		article, err := reader.ReadDotBytes()
+		if err != nil {
+			return
+		}
+		received <- article
+		writer.PrintfLine("240 article posted")
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	client := NewClient(&models.ServerConfig{Host: "127.0.0.1", Port: addr.Port})
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+	conn := &countingConn{Conn: client.conn}
+	client.attach(conn)
+
+	// 5000 lines of 40 bytes, some starting with a dot
+	var body strings.Builder
+	for i := 0; i < 5000; i++ {
+		if i%100 == 0 {
+			body.WriteString(".")
+		}
+		fmt.Fprintf(&body, "%039d\n", i)
+	}
+	if _, err := client.PostArticle("alt.test", "subject", "poster <poster@example.com>", body.String(), map[string]string{"Message-ID": "<id@test>"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The POST command, then the article in blocks of the buffer
+	if limit := 1 + body.Len()/writeBufferSize + 2; conn.writes > limit {
+		t.Errorf("expected at most %d writes, got %d", limit, conn.writes)
+	}
+	article := <-received
+	_, got, found := strings.Cut(string(article), "\n\n")
+	if !found || got != body.String() {
+		t.Errorf("the body was not received intact")
+	}
+}
+
+func TestPostArticleBinarySafe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// The server keeps the body as sent, up to the terminating dot
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		writer := textproto.NewWriter(bufio.NewWriter(conn))
+		writer.PrintfLine("200 test server ready")
+		if line, err := reader.ReadString('\n'); err != nil || line != "POST\r\n" {
+			return
+		}
+		writer.PrintfLine("340 send article")
+		var body strings.Builder
+		inBody := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if line == ".\r\n" {
+				break
+			}
+			if inBody {
+				body.WriteString(line)
+			}
+			inBody = inBody || line == "\r\n"
+		}
+		received <- body.String()
+		writer.PrintfLine("240 article posted")
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	client := NewClient(&models.ServerConfig{Host: "127.0.0.1", Port: addr.Port})
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+
+	// yEnc lines end with CRLF and may hold % and leading dots
+	body := "=ybegin line=128 size=6 name=a%d.bin\r\n.%s\x00\xff\r\n..\tx\r\nlast"
+	if _, err := client.PostArticle("alt.test", "subject", "<poster@example.com>", body, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "=ybegin line=128 size=6 name=a%d.bin\r\n..%s\x00\xff\r\n...\tx\r\nlast\r\n"
+	if got := <-received; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
+
+func TestPostArticleConnectionLost(t *testing.T) {
+	for _, stored := range []bool{true, false} {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+
+		// The first connection drops once the article is sent; the next
+		// answers STAT from whether the server kept it, and takes a POST
+		posts := make(chan string, 4)
+		go func() {
+			for connection := 0; ; connection++ {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				reader := textproto.NewReader(bufio.NewReader(conn))
+				writer := textproto.NewWriter(bufio.NewWriter(conn))
+				writer.PrintfLine("200 test server ready")
+				for {
+					line, err := reader.ReadLine()
+					if err != nil {
+						break
+					}
+					switch {
+					case line == "POST":
+						writer.PrintfLine("340 send article")
+						reader.ReadDotBytes()
+						posts <- line
+						if connection == 0 {
+							conn.Close()
+						} else {
+							writer.PrintfLine("240 article posted")
+						}
+					case strings.HasPrefix(line, "STAT "):
+						if stored {
+							writer.PrintfLine("223 0 %s", strings.TrimPrefix(line, "STAT "))
+						} else {
+							writer.PrintfLine("430 no such article")
+						}
+					default:
+						writer.PrintfLine("500 unknown command")
+					}
+				}
+				conn.Close()
+			}
+		}()
+
+		addr := listener.Addr().(*net.TCPAddr)
+		client := NewClient(&models.ServerConfig{Host: "127.0.0.1", Port: addr.Port})
+		if err := client.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		id, err := client.PostArticle("alt.test", "subject", "<poster@example.com>", "body", map[string]string{"Message-ID": "<id@test>"})
+		if err != nil || id != "<id@test>" {
+			t.Fatalf("stored %v: unexpected result %q, %v", stored, id, err)
+		}
+		want := 2
+		if stored {
+			want = 1
+		}
+		if len(posts) != want {
+			t.Errorf("stored %v: expected %d POSTs, got %d", stored, want, len(posts))
+		}
+		client.Quit()
+	}
+}
+
+func TestConnectionPoolExclusive(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(conn, "200 test server ready\r\n")
+			defer conn.Close()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	pool := NewConnectionPool(&models.ServerConfig{Host: "127.0.0.1", Port: addr.Port}, 2)
+	defer pool.CloseAll()
+
+	first, err := pool.GetClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := pool.GetClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatal("the same client was given to two callers")
+	}
+
+	// A third caller waits for a client to be released, then gets it
+	got := make(chan *Client)
+	go func() {
+		client, err := pool.GetClient()
+		if err != nil {
+			t.Error(err)
+		}
+		got <- client
+	}()
+	select {
+	case <-got:
+		t.Fatal("got a client while both were in use")
+	case <-time.After(50 * time.Millisecond):
+	}
+	pool.Release(second)
+	if client := <-got; client != second {
+		t.Errorf("expected the released client")
+	}
+
+	// A client whose connection was lost is reconnected before reuse
+	first.drop()
+	pool.Release(first)
+	client, err := pool.GetClient()
+	if err != nil || client != first || !client.IsConnected() {
+		t.Errorf("expected the first client reconnected, got %v", err)
+	}
+}
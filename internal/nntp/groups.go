@@ -0,0 +1,129 @@
+package nntp
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strings"
+)
+
+// GroupError is a newsgroup a server does not take articles for
+type GroupError struct {
+	Group string
+	// Code is the response it comes from: 411 for a group the server does
+	// not carry, 440 for one it does not allow posting to, or a server that
+	// does not allow posting at all
+	Code   int
+	Reason string
+}
+
+func (e *GroupError) Error() string {
+	return fmt.Sprintf("cannot post to %s: %s", e.Group, e.Reason)
+}
+
+// CanPost reports whether the server allows posting: after authentication
+// the capabilities are authoritative, servers without CAPABILITIES only have
+// the welcome message to go by
+func (c *Client) CanPost() bool {
+	capabilities, err := c.Capabilities()
+	if err != nil {
+		return c.PostingAllowed()
+	}
+	for _, capability := range capabilities {
+		if capability == "POST" {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckGroups looks up the groups articles are posted to on the connection.
+// A group the server does not carry, or marks in LIST ACTIVE as not taking
+// posts, and a server that does not allow posting are returned as
+// GroupErrors; the error is for the connection failing.
+func (c *Client) CheckGroups(groups []string) ([]*GroupError, error) {
+	if !c.CanPost() {
+		var problems []*GroupError
+		for _, group := range groups {
+			problems = append(problems, &GroupError{Group: group, Code: 440, Reason: "the server does not allow posting with this account"})
+		}
+		return problems, nil
+	}
+
+	var problems []*GroupError
+	for _, group := range groups {
+		problem, err := c.checkGroup(group)
+		if err != nil {
+			return nil, err
+		}
+		if problem != nil {
+			problems = append(problems, problem)
+		}
+	}
+	return problems, nil
+}
+
+// checkGroup selects a group and reads its status
+func (c *Client) checkGroup(group string) (*GroupError, error) {
+	var protoErr *textproto.Error
+	if err := c.JoinGroup(group); errors.As(err, &protoErr) && protoErr.Code == 411 {
+		return &GroupError{Group: group, Code: 411, Reason: "the server does not carry this group"}, nil
+	} else if err != nil && !errors.As(err, &protoErr) {
+		return nil, err
+	}
+
+	status, err := c.groupStatus(group)
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case "n":
+		return &GroupError{Group: group, Code: 440, Reason: "the group does not allow posting"}, nil
+	case "x":
+		return &GroupError{Group: group, Code: 440, Reason: "the server does not take posts to this group"}, nil
+	case "m":
+		return &GroupError{Group: group, Code: 440, Reason: "the group is moderated, articles need the approval of its moderator"}, nil
+	}
+	return nil, nil
+}
+
+// groupStatus returns the status of a group in LIST ACTIVE (RFC 3977): y
+// when posting is allowed, n when it is not, m when the group is moderated,
+// empty when the server does not tell
+func (c *Client) groupStatus(group string) (string, error) {
+	if err := c.writer.PrintfLine("LIST ACTIVE %s", group); err != nil {
+		return "", fmt.Errorf("failed to send LIST ACTIVE command: %w", err)
+	}
+	var protoErr *textproto.Error
+	if _, _, err := c.reader.ReadCodeLine(215); errors.As(err, &protoErr) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to list group %s: %w", group, err)
+	}
+	lines, err := c.reader.ReadDotLines()
+	if err != nil {
+		return "", fmt.Errorf("failed to list group %s: %w", group, err)
+	}
+	for _, line := range lines {
+		if fields := strings.Fields(line); len(fields) >= 4 && strings.EqualFold(fields[0], group) {
+			return strings.ToLower(fields[3][:1]), nil
+		}
+	}
+	return "", nil
+}
+
+// Refused reports whether err is a server refusing an article for where or
+// by whom it is posted as much as for what it holds: a group it does not
+// carry (411), posting not allowed (440) or posting failed (441). It returns
+// the code.
+func Refused(err error) (int, bool) {
+	var protoErr *textproto.Error
+	if !errors.As(err, &protoErr) {
+		return 0, false
+	}
+	switch protoErr.Code {
+	case 411, 440, 441:
+		return protoErr.Code, true
+	}
+	return 0, false
+}
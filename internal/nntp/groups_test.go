@@ -0,0 +1,86 @@
+package nntp
+
+import (
+	"fmt"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestCheckGroups(t *testing.T) {
+	// LIST ACTIVE answers with the status line, then the terminating dot
+	statuses := map[string]string{"alt.binaries.test": "y", "alt.binaries.readonly": "n", "alt.binaries.moderated": "m"}
+	server := serveNNTP(t, func(line string) string {
+		fields := strings.Fields(line)
+		switch {
+		case line == "CAPABILITIES":
+			return "101 capabilities\r\nVERSION 2\r\nPOST\r\n."
+		case fields[0] == "GROUP" && statuses[fields[1]] == "":
+			return "411 no such group"
+		case fields[0] == "GROUP":
+			return "211 10 1 10 " + fields[1]
+		case fields[0] == "LIST":
+			return fmt.Sprintf("215 list follows\r\n%s 10 1 %s\r\n.", fields[2], statuses[fields[2]])
+		}
+		return "500 unknown command"
+	})
+
+	client := NewClient(server)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+
+	problems, err := client.CheckGroups([]string{"alt.binaries.test", "alt.binaries.missing", "alt.binaries.readonly", "alt.binaries.moderated"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"alt.binaries.missing": 411, "alt.binaries.readonly": 440, "alt.binaries.moderated": 440}
+	if len(problems) != len(want) {
+		t.Fatalf("expected %d problems, got %v", len(want), problems)
+	}
+	for _, problem := range problems {
+		if want[problem.Group] != problem.Code || problem.Reason == "" {
+			t.Errorf("unexpected problem %+v", problem)
+		}
+	}
+}
+
+func TestCheckGroupsPostingNotAllowed(t *testing.T) {
+	// A server without CAPABILITIES goes by its welcome message
+	server := serveNNTP(t, func(line string) string { return "500 unknown command" })
+	client := NewClient(server)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+	client.postingAllowed = false
+
+	problems, err := client.CheckGroups([]string{"alt.binaries.test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || problems[0].Code != 440 {
+		t.Errorf("expected posting not allowed, got %v", problems)
+	}
+}
+
+func TestRefused(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+		ok   bool
+	}{
+		{fmt.Errorf("failed to join group: %w", &textproto.Error{Code: 411, Msg: "no such group"}), 411, true},
+		{&textproto.Error{Code: 440, Msg: "posting not permitted"}, 440, true},
+		{fmt.Errorf("server rejected article: %w", &textproto.Error{Code: 441, Msg: "posting failed"}), 441, true},
+		{&textproto.Error{Code: 502, Msg: "too many connections"}, 0, false},
+		{fmt.Errorf("connection refused"), 0, false},
+	}
+
+	for _, test := range tests {
+		if code, ok := Refused(test.err); code != test.code || ok != test.ok {
+			t.Errorf("%v: got %d, %v", test.err, code, ok)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package nntp
+
+import (
+	"fmt"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+)
+
+// dateLayout is the RFC 5322 date-time of the Date header, always in UTC
+const dateLayout = "Mon, 02 Jan 2006 15:04:05 -0700"
+
+// headerOrder is the order the standard headers of an article are written
+// in; any other header follows them, sorted by name
+var headerOrder = []string{"From", "Newsgroups", "Subject", "Message-ID", "Date", "Content-Type"}
+
+// header is a header line of an article
+type header struct {
+	name  string
+	value string
+}
+
+// FormatFrom returns the From header of a poster as RFC 5322 has it: the
+// address in angle brackets after the display name, quoted or encoded when
+// it is not plain atoms. The address may be given as "Name <address>", its
+// name kept when name is empty; an invalid address is an error.
+func FormatFrom(name, address string) (string, error) {
+	from, err := mail.ParseAddress(strings.TrimSpace(address))
+	if err != nil {
+		return "", fmt.Errorf("invalid poster address %q: %w", address, err)
+	}
+	if name = strings.TrimSpace(name); name != "" {
+		from.Name = name
+	}
+	return from.String(), nil
+}
+
+// FormatDate returns the Date header of an article posted at t
+func FormatDate(t time.Time) string {
+	return t.UTC().Format(dateLayout)
+}
+
+// ValidateHeader checks a header can be written as is: the name printable
+// ASCII without colon or space, the value on a single line. A CR or LF in the
+// value would end the header and let it inject others, or the body.
+func ValidateHeader(name, value string) error {
+	if name == "" {
+		return fmt.Errorf("empty header name")
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; c <= ' ' || c > '~' || c == ':' {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("header %s: value must not contain CR or LF", name)
+	}
+	return nil
+}
+
+// articleHeaders returns the headers of an article in the order they are
+// written: the standard headers first, in headerOrder, then the others by
+// name. Names are compared whatever their case: a header of extra named like
+// a standard one replaces its value, and of two extra headers differing only
+// by case the last by name wins. Every header is validated.
+func articleHeaders(standard map[string]string, extra map[string]string) ([]header, error) {
+	values := make(map[string]string, len(standard))
+	for name, value := range standard {
+		values[strings.ToLower(name)] = value
+	}
+
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var others []header
+	seen := make(map[string]int, len(names))
+	for _, name := range names {
+		key := strings.ToLower(name)
+		if _, ok := values[key]; ok {
+			values[key] = extra[name]
+		} else if i, ok := seen[key]; ok {
+			others[i] = header{name, extra[name]}
+		} else {
+			seen[key] = len(others)
+			others = append(others, header{name, extra[name]})
+		}
+	}
+
+	headers := make([]header, 0, len(headerOrder)+len(others))
+	for _, name := range headerOrder {
+		if value, ok := values[strings.ToLower(name)]; ok {
+			headers = append(headers, header{name, value})
+		}
+	}
+	headers = append(headers, others...)
+	for _, h := range headers {
+		if err := ValidateHeader(h.name, h.value); err != nil {
+			return nil, err
+		}
+	}
+	return headers, nil
+}
@@ -0,0 +1,130 @@
+package nntp
+
+import (
+	"fmt"
+	"net/mail"
+	"testing"
+	"time"
+)
+
+func TestFormatFrom(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    string
+		wantErr bool
+	}{
+		{"", "poster@example.com", "<poster@example.com>", false},
+		{"Poster", "poster@example.com", `"Poster" <poster@example.com>`, false},
+		{"John Q. Public", "jqp@example.com", `"John Q. Public" <jqp@example.com>`, false},
+		{`Say "hi"`, "poster@example.com", `"Say \"hi\"" <poster@example.com>`, false},
+		{"", "Poster <poster@example.com>", `"Poster" <poster@example.com>`, false},
+		{"Other", "Poster <poster@example.com>", `"Other" <poster@example.com>`, false},
+		{"", "poster", "", true},
+		{"", "poster@", "", true},
+		{"Poster", "", "", true},
+	}
+
+	for _, test := range tests {
+		got, err := FormatFrom(test.name, test.address)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q, %q: unexpected error %v", test.name, test.address, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q, %q: got %q, want %q", test.name, test.address, got, test.want)
+		}
+	}
+
+	// Names outside ASCII are encoded, and read back as they were
+	from, err := FormatFrom("Émile", "poster@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := mail.ParseAddress(from)
+	if err != nil || parsed.Name != "Émile" {
+		t.Errorf("unexpected From %q: %v", from, err)
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	at := time.Date(2024, 3, 5, 14, 7, 9, 0, time.FixedZone("CET", 3600))
+	if got, want := FormatDate(at), "Tue, 05 Mar 2024 13:07:09 +0000"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	parsed, err := mail.ParseDate(FormatDate(at))
+	if err != nil || !parsed.Equal(at) {
+		t.Errorf("unexpected date %v: %v", parsed, err)
+	}
+}
+
+func TestValidateHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"X-No-Archive", "yes", false},
+		{"Organization", "", false},
+		{"X-Test", "café", false},
+		{"", "value", true},
+		{"X Test", "value", true},
+		{"X-Test:", "value", true},
+		{"X-Tést", "value", true},
+		{"X-Test", "value\r\nBcc: someone", true},
+		{"X-Test", "value\nX-Other: injected", true},
+		{"X-Test", "value\r", true},
+	}
+
+	for _, test := range tests {
+		if err := ValidateHeader(test.name, test.value); (err != nil) != test.wantErr {
+			t.Errorf("%q: %q: unexpected error %v", test.name, test.value, err)
+		}
+	}
+}
+
+func TestArticleHeadersOrder(t *testing.T) {
+	standard := map[string]string{
+		"From":       "<poster@example.com>",
+		"Subject":    "subject",
+		"Newsgroups": "alt.test",
+		"Message-ID": "<id@test>",
+		"Date":       "Tue, 05 Mar 2024 13:07:09 +0000",
+	}
+	extra := map[string]string{
+		"X-Newsposter": "ypost",
+		"Organization": "Example",
+		"X-No-Archive": "yes",
+		"subject":      "custom subject",
+		"x-newsposter": "other",
+	}
+
+	want := []header{
+		{"From", "<poster@example.com>"},
+		{"Newsgroups", "alt.test"},
+		{"Subject", "custom subject"},
+		{"Message-ID", "<id@test>"},
+		{"Date", "Tue, 05 Mar 2024 13:07:09 +0000"},
+		{"Organization", "Example"},
+		{"x-newsposter", "other"},
+		{"X-No-Archive", "yes"},
+	}
+	// Maps iterate in random order, the headers come out the same every time
+	for i := 0; i < 20; i++ {
+		got, err := articleHeaders(standard, extra)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("got headers %v, want %v", got, want)
+		}
+	}
+
+	extra["X-Injected"] = "yes\r\nBcc: someone"
+	if _, err := articleHeaders(standard, extra); err == nil {
+		t.Error("expected injected header to be rejected")
+	}
+	if _, err := articleHeaders(map[string]string{"Subject": "a\nb"}, nil); err == nil {
+		t.Error("expected subject with a line break to be rejected")
+	}
+}
@@ -0,0 +1,57 @@
+package nntp
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// DefaultMessageIDDomain is the right-hand side of Message-IDs when none is
+// configured
+const DefaultMessageIDDomain = "nyuu"
+
+// messageIDLength is the number of base 36 digits of 128 bits
+const messageIDLength = 25
+
+// NewMessageID returns a Message-ID of 128 random bits in base 36 at domain,
+// in angle brackets. Nothing of the time or the poster is in it.
+func NewMessageID(domain string) string {
+	var random [16]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		panic("failed to read random bytes: " + err.Error())
+	}
+	id := new(big.Int).SetBytes(random[:]).Text(36)
+	return "<" + strings.Repeat("0", messageIDLength-len(id)) + id + "@" + domain + ">"
+}
+
+// MessageIDs generates the Message-IDs of the articles of a job, never the
+// same twice
+type MessageIDs struct {
+	domain string
+
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+// NewMessageIDs creates a generator of Message-IDs at domain, the default
+// when empty
+func NewMessageIDs(domain string) *MessageIDs {
+	if domain == "" {
+		domain = DefaultMessageIDDomain
+	}
+	return &MessageIDs{domain: domain, seen: make(map[string]struct{})}
+}
+
+// Next returns a Message-ID not returned before
+func (m *MessageIDs) Next() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for {
+		id := NewMessageID(m.domain)
+		if _, ok := m.seen[id]; !ok {
+			m.seen[id] = struct{}{}
+			return id
+		}
+	}
+}
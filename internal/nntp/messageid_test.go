@@ -0,0 +1,32 @@
+package nntp
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestNewMessageID(t *testing.T) {
+	format := regexp.MustCompile(`^<[0-9a-z]{25}@example\.com>$`)
+	first, second := NewMessageID("example.com"), NewMessageID("example.com")
+	if !format.MatchString(first) || !format.MatchString(second) {
+		t.Errorf("unexpected Message-IDs %q and %q", first, second)
+	}
+	if first == second {
+		t.Error("expected random Message-IDs")
+	}
+}
+
+func TestMessageIDs(t *testing.T) {
+	ids := NewMessageIDs("")
+	seen := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		id := ids.Next()
+		if seen[id] {
+			t.Fatalf("Message-ID %s returned twice", id)
+		}
+		seen[id] = true
+	}
+	if id := ids.Next(); !regexp.MustCompile(`@nyuu>$`).MatchString(id) {
+		t.Errorf("expected the default domain, got %q", id)
+	}
+}
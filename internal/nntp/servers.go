@@ -0,0 +1,72 @@
+package nntp
+
+import (
+	"sort"
+
+	"ypost/pkg/models"
+)
+
+// ServerGroup holds a connection pool per configured server in failover order:
+// primary servers by priority, then backup servers by priority. A lower
+// priority value is tried first, as in downloader server tiers.
+type ServerGroup struct {
+	pools []*ConnectionPool
+	ids   *MessageIDs
+}
+
+// NewServerGroup creates a connection pool for every server posting, those
+// with the read role left out. Connections are only opened when a pool is
+// first used, so backup servers stay idle until a segment fails on every
+// primary.
+func NewServerGroup(servers []models.ServerConfig) *ServerGroup {
+	var ordered []models.ServerConfig
+	for _, server := range servers {
+		if server.Posts() {
+			ordered = append(ordered, server)
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].Backup != ordered[j].Backup {
+			return !ordered[i].Backup
+		}
+		return ordered[i].Priority < ordered[j].Priority
+	})
+
+	group := &ServerGroup{ids: NewMessageIDs("")}
+	for i := range ordered {
+		group.pools = append(group.pools, NewConnectionPool(&ordered[i], ordered[i].MaxConns))
+	}
+	return group
+}
+
+// SetMessageIDDomain sets the right-hand side of the Message-IDs of the
+// articles posted through the group
+func (g *ServerGroup) SetMessageIDDomain(domain string) {
+	g.ids = NewMessageIDs(domain)
+}
+
+// NextMessageID returns the Message-ID of an article posted through the
+// group, unique among those of the group
+func (g *ServerGroup) NextMessageID() string {
+	return g.ids.Next()
+}
+
+// Pools returns the connection pools in failover order
+func (g *ServerGroup) Pools() []*ConnectionPool {
+	return g.pools
+}
+
+// Primary returns the pool that handles normal traffic
+func (g *ServerGroup) Primary() *ConnectionPool {
+	if len(g.pools) == 0 {
+		return nil
+	}
+	return g.pools[0]
+}
+
+// CloseAll closes the connections of every server
+func (g *ServerGroup) CloseAll() {
+	for _, pool := range g.pools {
+		pool.CloseAll()
+	}
+}
@@ -0,0 +1,33 @@
+package nntp
+
+import (
+	"testing"
+
+	"ypost/pkg/models"
+)
+
+func TestServerGroupOrder(t *testing.T) {
+	servers := []models.ServerConfig{
+		{Host: "backup.example.com", Backup: true},
+		{Host: "second.example.com", Priority: 1},
+		{Host: "fill.example.com", Priority: 0, Backup: true},
+		{Host: "first.example.com", Priority: 0},
+		{Host: "reader.example.com", Role: models.RoleRead},
+	}
+
+	group := NewServerGroup(servers)
+	expected := []string{"first.example.com", "second.example.com", "backup.example.com", "fill.example.com"}
+
+	pools := group.Pools()
+	if len(pools) != len(expected) {
+		t.Fatalf("expected %d pools, got %d", len(expected), len(pools))
+	}
+	for i, pool := range pools {
+		if pool.Server().Host != expected[i] {
+			t.Errorf("pool %d: expected %s, got %s", i, expected[i], pool.Server().Host)
+		}
+	}
+	if group.Primary().Server().Host != "first.example.com" {
+		t.Errorf("unexpected primary server %s", group.Primary().Server().Host)
+	}
+}
@@ -0,0 +1,187 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"ypost/internal/nzb"
+	"ypost/pkg/models"
+)
+
+// Apps handed the NZBs of successful posts
+const (
+	AppSonarr = "sonarr"
+	AppRadarr = "radarr"
+	AppLidarr = "lidarr"
+)
+
+// arrAPIs are the API versions of the apps
+var arrAPIs = map[string]string{
+	AppSonarr: "v3",
+	AppRadarr: "v3",
+	AppLidarr: "v1",
+}
+
+// Arr hands the NZB of a successful post to Sonarr, Radarr or Lidarr: it
+// pushes the release to the API of the app, which fetches the NZB from its
+// download URL, or writes the NZB to the blackhole folder of the app
+type Arr struct {
+	app         string
+	downloadURL *template.Template
+	blackhole   string
+	category    string
+	hook        *Webhook
+}
+
+// NewArr checks the settings of an app and parses its download URL template
+func NewArr(cfg models.ArrConfig) (*Arr, error) {
+	app := strings.ToLower(strings.TrimSpace(cfg.App))
+	version, ok := arrAPIs[app]
+	if !ok {
+		return nil, fmt.Errorf("invalid app %q (expected sonarr, radarr or lidarr)", cfg.App)
+	}
+	arr := &Arr{app: app, blackhole: cfg.Blackhole, category: cfg.Category}
+	switch {
+	case cfg.URL != "" && cfg.Blackhole != "":
+		return nil, fmt.Errorf("%s: set either url or blackhole", app)
+	case cfg.Blackhole != "":
+		return arr, nil
+	case cfg.URL == "":
+		return nil, fmt.Errorf("%s: no url or blackhole", app)
+	case cfg.APIKey == "":
+		return nil, fmt.Errorf("%s: no api_key", app)
+	case cfg.DownloadURL == "":
+		return nil, fmt.Errorf("%s: no download_url the NZB can be fetched from", app)
+	}
+
+	downloadURL, err := parseTemplate("download_url", cfg.DownloadURL)
+	if err != nil {
+		return nil, err
+	}
+	hook, err := NewWebhook(models.WebhookConfig{
+		URL:      strings.TrimSuffix(cfg.URL, "/") + "/api/" + version + "/release/push",
+		Headers:  map[string]string{"X-Api-Key": cfg.APIKey},
+		Attempts: cfg.Attempts,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", app, err)
+	}
+	hook.check = checkRelease
+	arr.downloadURL, arr.hook = downloadURL, hook
+	return arr, nil
+}
+
+// String names the app and where the NZBs are handed to it
+func (a *Arr) String() string {
+	if a.hook == nil {
+		return a.app + " blackhole " + a.blackhole
+	}
+	return a.app + " " + a.hook.String()
+}
+
+// Notify hands the NZB of a successful post to the app
+func (a *Arr) Notify(ctx context.Context, event Event) error {
+	if event.Kind != EventComplete || !event.Success || event.NZB == "" {
+		return nil
+	}
+	doc, err := nzb.ParseFile(event.NZB)
+	if err != nil {
+		return fmt.Errorf("%s: %w", a, err)
+	}
+	if a.hook == nil {
+		if err := a.drop(doc, event); err != nil {
+			return fmt.Errorf("%s: %w", a, err)
+		}
+		return nil
+	}
+
+	var downloadURL strings.Builder
+	if err := a.downloadURL.Execute(&downloadURL, event); err != nil {
+		return fmt.Errorf("%s: failed to render download_url: %w", a, err)
+	}
+	title := doc.MetaValue("title")
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(event.NZB), ".nzb")
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"title":       title,
+		"downloadUrl": strings.TrimSpace(downloadURL.String()),
+		"protocol":    "usenet",
+		"publishDate": event.Time.UTC().Format("2006-01-02T15:04:05Z"),
+		"size":        event.Size,
+		"indexer":     "ypost",
+	})
+	if err != nil {
+		return err
+	}
+	if err := a.hook.send(ctx, payload); err != nil {
+		return fmt.Errorf("%s: %w", a, err)
+	}
+	return nil
+}
+
+// drop writes the NZB to the blackhole folder with the password of the
+// archive and the category in its head, as downloaders read them
+func (a *Arr) drop(doc *nzb.NZB, event Event) error {
+	if event.Password != "" {
+		doc.SetMetaValue("password", event.Password)
+	}
+	category := a.category
+	if category == "" {
+		category = event.Category
+	}
+	if category != "" {
+		doc.SetMetaValue("category", category)
+	}
+	if err := os.MkdirAll(a.blackhole, 0755); err != nil {
+		return fmt.Errorf("failed to create blackhole folder: %w", err)
+	}
+	return doc.WriteFile(filepath.Join(a.blackhole, filepath.Base(event.NZB)))
+}
+
+// checkRelease returns why the app rejected a pushed release, if it did.
+// The apps reply with the release, or a list of the releases, and the
+// reasons of a rejection as strings or as objects with a reason.
+func checkRelease(reply []byte) error {
+	type release struct {
+		Rejected   bool              `json:"rejected"`
+		Rejections []json.RawMessage `json:"rejections"`
+	}
+	var releases []release
+	if err := json.Unmarshal(reply, &releases); err != nil {
+		var single release
+		if err := json.Unmarshal(reply, &single); err != nil {
+			return nil
+		}
+		releases = []release{single}
+	}
+	for _, r := range releases {
+		if !r.Rejected {
+			continue
+		}
+		var reasons []string
+		for _, raw := range r.Rejections {
+			var reason string
+			if json.Unmarshal(raw, &reason) != nil {
+				var rejection struct {
+					Reason string `json:"reason"`
+				}
+				json.Unmarshal(raw, &rejection)
+				reason = rejection.Reason
+			}
+			if reason != "" {
+				reasons = append(reasons, reason)
+			}
+		}
+		if len(reasons) == 0 {
+			return fmt.Errorf("release rejected")
+		}
+		return fmt.Errorf("release rejected: %s", strings.Join(reasons, "; "))
+	}
+	return nil
+}
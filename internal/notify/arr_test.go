@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ypost/internal/nzb"
+	"ypost/pkg/models"
+)
+
+// arrEvent writes an NZB and returns the successful post event of it
+func arrEvent(t *testing.T) Event {
+	t.Helper()
+	doc := &nzb.NZB{
+		Meta: []nzb.Meta{{Type: "title", Value: "Show.S01E02.1080p"}, {Type: "category", Value: "misc"}},
+		Files: []nzb.File{{
+			Poster:   "poster@example.com",
+			Subject:  `"show.mkv" yEnc (1/1)`,
+			Groups:   []string{"alt.binaries.test"},
+			Segments: []nzb.Segment{{Bytes: 1000, Number: 1, MessageID: "one@test"}},
+		}},
+	}
+	event := testEvent
+	event.Kind = EventComplete
+	event.NZB = filepath.Join(t.TempDir(), "show.nzb")
+	if err := doc.WriteFile(event.NZB); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestArrPushesRelease(t *testing.T) {
+	var path, apiKey string
+	var release map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, apiKey = r.URL.Path, r.Header.Get("X-Api-Key")
+		if err := json.NewDecoder(r.Body).Decode(&release); err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(`[{"title": "Show.S01E02.1080p", "approved": true, "rejected": false}]`))
+	}))
+	defer server.Close()
+
+	arr, err := NewArr(models.ArrConfig{
+		App:         "Sonarr",
+		URL:         server.URL,
+		APIKey:      "k3y",
+		DownloadURL: "http://ypost:8080/nzb/{{base .NZB}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := arr.Notify(context.Background(), arrEvent(t)); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/api/v3/release/push" || apiKey != "k3y" {
+		t.Errorf("unexpected push to %s with key %q", path, apiKey)
+	}
+	if release["title"] != "Show.S01E02.1080p" || release["downloadUrl"] != "http://ypost:8080/nzb/show.nzb" || release["protocol"] != "usenet" {
+		t.Errorf("unexpected release %v", release)
+	}
+}
+
+func TestArrRejection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"rejected": true, "rejections": ["Unknown Movie", {"reason": "Not wanted"}]}`))
+	}))
+	defer server.Close()
+
+	arr, err := NewArr(models.ArrConfig{App: "radarr", URL: server.URL, APIKey: "k3y", DownloadURL: "http://ypost/x.nzb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = arr.Notify(context.Background(), arrEvent(t))
+	if err == nil || !strings.Contains(err.Error(), "Unknown Movie; Not wanted") {
+		t.Errorf("expected the rejection, got %v", err)
+	}
+}
+
+func TestArrBlackhole(t *testing.T) {
+	blackhole := filepath.Join(t.TempDir(), "watch")
+	arr, err := NewArr(models.ArrConfig{App: "lidarr", Blackhole: blackhole, Category: "music"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := arrEvent(t)
+	event.Password = "s3cret"
+	if err := arr.Notify(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := nzb.ParseFile(filepath.Join(blackhole, "show.nzb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.MetaValue("password") != "s3cret" || doc.MetaValue("category") != "music" || doc.MetaValue("title") != "Show.S01E02.1080p" {
+		t.Errorf("unexpected head %+v", doc.Meta)
+	}
+
+	failed := event
+	failed.Kind, failed.Success = EventFailure, false
+	os.Remove(filepath.Join(blackhole, "show.nzb"))
+	if err := arr.Notify(context.Background(), failed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(blackhole, "show.nzb")); err == nil {
+		t.Error("the NZB of a failed post was handed over")
+	}
+}
+
+func TestNewArrInvalid(t *testing.T) {
+	for _, cfg := range []models.ArrConfig{
+		{App: "readarr", Blackhole: "/watch"},
+		{App: "sonarr"},
+		{App: "sonarr", URL: "http://sonarr:8989", Blackhole: "/watch"},
+		{App: "sonarr", URL: "http://sonarr:8989", DownloadURL: "http://ypost/x.nzb"},
+		{App: "sonarr", URL: "http://sonarr:8989", APIKey: "k3y"},
+		{App: "sonarr", URL: "http://sonarr:8989", APIKey: "k3y", DownloadURL: "{{.NZB"},
+	} {
+		if _, err := NewArr(cfg); err == nil {
+			t.Errorf("expected %+v to be refused", cfg)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"ypost/pkg/models"
+)
+
+// Chat platforms notifications can be sent to
+const (
+	PlatformDiscord  = "discord"
+	PlatformSlack    = "slack"
+	PlatformTelegram = "telegram"
+)
+
+// telegramAPI is the Bot API endpoint of Telegram
+var telegramAPI = "https://api.telegram.org"
+
+// messageLimits are the longest messages of the platforms, in characters
+var messageLimits = map[string]int{
+	PlatformDiscord:  2000,
+	PlatformSlack:    40000,
+	PlatformTelegram: 4096,
+}
+
+// defaultMessages are the message templates of the events
+var defaultMessages = map[string]string{
+	EventStart:    `Posting {{.File}} ({{size .Size}}) to {{join .Groups ", "}}`,
+	EventComplete: `Posted {{.File}} ({{size .Size}}, {{.Articles}} articles in {{duration .Duration}})`,
+	EventFailure:  `Posting {{.File}} failed: {{.Error}}`,
+}
+
+// Chat sends messages to a Discord or Slack channel, through its incoming
+// webhook, or to a Telegram chat, through a bot
+type Chat struct {
+	platform string
+	chatID   string
+	events   map[string]bool
+	messages map[string]*template.Template
+	hook     *Webhook
+}
+
+// NewChat checks the settings of a chat and parses its message templates
+func NewChat(cfg models.ChatConfig) (*Chat, error) {
+	platform := strings.ToLower(cfg.Platform)
+	target := cfg.URL
+	switch platform {
+	case PlatformDiscord, PlatformSlack:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("no %s webhook URL", platform)
+		}
+	case PlatformTelegram:
+		if cfg.Token == "" || cfg.ChatID == "" {
+			return nil, fmt.Errorf("telegram needs a bot token and a chat ID")
+		}
+		target = telegramAPI + "/bot" + url.PathEscape(cfg.Token) + "/sendMessage"
+	default:
+		return nil, fmt.Errorf("invalid chat platform %q (expected discord, slack or telegram)", cfg.Platform)
+	}
+	hook, err := NewWebhook(models.WebhookConfig{URL: target})
+	if err != nil {
+		return nil, err
+	}
+
+	chat := &Chat{
+		platform: platform,
+		chatID:   cfg.ChatID,
+		events:   map[string]bool{EventComplete: true, EventFailure: true},
+		messages: make(map[string]*template.Template),
+		hook:     hook,
+	}
+	if len(cfg.Events) > 0 {
+		chat.events = make(map[string]bool)
+		for _, kind := range cfg.Events {
+			kind = strings.ToLower(strings.TrimSpace(kind))
+			if _, ok := defaultMessages[kind]; !ok {
+				return nil, fmt.Errorf("invalid chat event %q (expected start, complete or failure)", kind)
+			}
+			chat.events[kind] = true
+		}
+	}
+	for kind := range cfg.Templates {
+		if _, ok := defaultMessages[kind]; !ok {
+			return nil, fmt.Errorf("invalid chat template %q (expected start, complete or failure)", kind)
+		}
+	}
+	for kind, text := range defaultMessages {
+		if custom := cfg.Templates[kind]; custom != "" {
+			text = custom
+		}
+		message, err := parseTemplate(kind+" message", text)
+		if err != nil {
+			return nil, err
+		}
+		chat.messages[kind] = message
+	}
+	return chat, nil
+}
+
+// String names the platform of the chat; the URL holds its secret
+func (c *Chat) String() string {
+	return c.platform + " chat"
+}
+
+// Notify sends the message of an event unless its kind is turned off
+func (c *Chat) Notify(ctx context.Context, event Event) error {
+	if !c.events[event.Kind] {
+		return nil
+	}
+	message, err := render(c.messages[event.Kind], event)
+	if err != nil {
+		return err
+	}
+	text := []rune(strings.TrimSpace(string(message)))
+	if limit := messageLimits[c.platform]; len(text) > limit {
+		text = append(text[:limit-1], '…')
+	}
+
+	var payload map[string]string
+	switch c.platform {
+	case PlatformDiscord:
+		payload = map[string]string{"content": string(text)}
+	case PlatformSlack:
+		payload = map[string]string{"text": string(text)}
+	case PlatformTelegram:
+		payload = map[string]string{"chat_id": c.chatID, "text": string(text)}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if err := c.hook.send(ctx, data); err != nil {
+		return fmt.Errorf("%s: %w", c, err)
+	}
+	return nil
+}
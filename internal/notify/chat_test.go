@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ypost/pkg/models"
+)
+
+// chatServer records the JSON messages posted to it by path
+func chatServer(t *testing.T) (*httptest.Server, map[string][]map[string]string) {
+	t.Helper()
+	received := make(map[string][]map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Error(err)
+		}
+		received[r.URL.Path] = append(received[r.URL.Path], message)
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func TestChatPlatforms(t *testing.T) {
+	server, received := chatServer(t)
+	telegramAPI = server.URL
+	defer func() { telegramAPI = "https://api.telegram.org" }()
+
+	chats := []models.ChatConfig{
+		{Platform: "discord", URL: server.URL + "/discord", Events: []string{"start", "complete"}},
+		{Platform: "slack", URL: server.URL + "/slack", Templates: map[string]string{"complete": "{{.File}} is up"}},
+		{Platform: "telegram", Token: "123:abc", ChatID: "-100", Events: []string{"failure"}},
+	}
+	for _, cfg := range chats {
+		chat, err := NewChat(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := testEvent
+		start.Kind = EventStart
+		complete := testEvent
+		complete.Kind = EventComplete
+		failure := testEvent
+		failure.Kind, failure.Success, failure.Error = EventFailure, false, "430 no such article"
+		for _, event := range []Event{start, complete, failure} {
+			if err := chat.Notify(context.Background(), event); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	discord := received["/discord"]
+	if len(discord) != 2 || discord[0]["content"] != "Posting movie.mkv (1.0MB) to alt.binaries.test" ||
+		!strings.HasPrefix(discord[1]["content"], "Posted movie.mkv") {
+		t.Errorf("unexpected discord messages %v", discord)
+	}
+	slack := received["/slack"]
+	if len(slack) != 2 || slack[0]["text"] != "movie.mkv is up" || slack[1]["text"] != "Posting movie.mkv failed: 430 no such article" {
+		t.Errorf("unexpected slack messages %v", slack)
+	}
+	telegram := received["/bot123:abc/sendMessage"]
+	if len(telegram) != 1 || telegram[0]["chat_id"] != "-100" || !strings.Contains(telegram[0]["text"], "failed") {
+		t.Errorf("unexpected telegram messages %v", received)
+	}
+}
+
+func TestNewChatChecksSettings(t *testing.T) {
+	for _, cfg := range []models.ChatConfig{
+		{Platform: "irc", URL: "https://example.com"},
+		{Platform: "discord"},
+		{Platform: "telegram", Token: "123:abc"},
+		{Platform: "slack", URL: "https://hooks.slack.com/x", Events: []string{"progress"}},
+		{Platform: "slack", URL: "https://hooks.slack.com/x", Templates: map[string]string{"done": "x"}},
+		{Platform: "slack", URL: "https://hooks.slack.com/x", Templates: map[string]string{"start": "{{.File"}},
+	} {
+		if _, err := NewChat(cfg); err == nil {
+			t.Errorf("expected %+v to fail", cfg)
+		}
+	}
+}
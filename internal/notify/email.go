@@ -0,0 +1,245 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"ypost/internal/utils"
+	"ypost/pkg/models"
+)
+
+// DefaultSubject is the subject template of summary mails
+const DefaultSubject = `[ypost] {{.File}} {{if .Success}}posted{{else}}failed{{end}}`
+
+// emailTimeout bounds the SMTP session of a mail
+const emailTimeout = 30 * time.Second
+
+// Email mails a summary of events, with the NZB attached
+type Email struct {
+	host      string
+	port      int
+	security  string
+	username  string
+	password  string
+	from      string
+	to        []string
+	on        string
+	subject   *template.Template
+	attachNZB bool
+}
+
+// NewEmail checks the SMTP settings of summary mails
+func NewEmail(cfg models.EmailConfig) (*Email, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("no SMTP host")
+	}
+	if cfg.Port <= 0 || cfg.Port > 65535 {
+		return nil, fmt.Errorf("invalid SMTP port %d", cfg.Port)
+	}
+	security := strings.ToLower(cfg.Security)
+	switch security {
+	case "", "starttls":
+		security = "starttls"
+	case "tls", "none":
+	default:
+		return nil, fmt.Errorf("invalid SMTP security %q (expected starttls, tls or none)", cfg.Security)
+	}
+	if _, err := mail.ParseAddress(cfg.From); err != nil {
+		return nil, fmt.Errorf("invalid sender %q: %w", cfg.From, err)
+	}
+	if len(cfg.To) == 0 {
+		return nil, fmt.Errorf("no recipients")
+	}
+	for _, to := range cfg.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", to, err)
+		}
+	}
+	on, err := ParseOn(cfg.On)
+	if err != nil {
+		return nil, err
+	}
+	subjectText := cfg.Subject
+	if subjectText == "" {
+		subjectText = DefaultSubject
+	}
+	subject, err := parseTemplate("subject", subjectText)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Email{
+		host:      cfg.Host,
+		port:      cfg.Port,
+		security:  security,
+		username:  cfg.Username,
+		password:  cfg.Password,
+		from:      cfg.From,
+		to:        cfg.To,
+		on:        on,
+		subject:   subject,
+		attachNZB: cfg.AttachNZB,
+	}, nil
+}
+
+// String returns the recipients of the mails
+func (e *Email) String() string {
+	return "mail to " + strings.Join(e.to, ", ")
+}
+
+// Notify mails the summary of an event unless it is filtered out
+func (e *Email) Notify(ctx context.Context, event Event) error {
+	if !wants(e.on, event) {
+		return nil
+	}
+	message, err := e.message(event, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := e.send(ctx, message); err != nil {
+		return fmt.Errorf("%s: %w", e, err)
+	}
+	return nil
+}
+
+// message builds the summary mail of an event
+func (e *Email) message(event Event, now time.Time) ([]byte, error) {
+	subject, err := render(e.subject, event)
+	if err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	body := multipart.NewWriter(&message)
+	headers := []string{
+		"From: " + e.from,
+		"To: " + strings.Join(e.to, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", strings.TrimSpace(string(subject))),
+		"Date: " + now.Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/mixed; boundary=" + body.Boundary(),
+	}
+	message.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	text, err := body.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return nil, err
+	}
+	text.Write([]byte(strings.ReplaceAll(summary(event), "\n", "\r\n")))
+
+	if e.attachNZB && event.NZB != "" {
+		if data, err := os.ReadFile(event.NZB); err == nil {
+			name := filepath.Base(event.NZB)
+			attachment, err := body.CreatePart(textproto.MIMEHeader{
+				"Content-Type":              {mime.FormatMediaType("application/x-nzb", map[string]string{"name": name})},
+				"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": name})},
+				"Content-Transfer-Encoding": {"base64"},
+			})
+			if err != nil {
+				return nil, err
+			}
+			encoded := base64.StdEncoding.EncodeToString(data)
+			for len(encoded) > 76 {
+				attachment.Write([]byte(encoded[:76] + "\r\n"))
+				encoded = encoded[76:]
+			}
+			attachment.Write([]byte(encoded + "\r\n"))
+		}
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	return message.Bytes(), nil
+}
+
+// summary is the text of the mail of an event
+func summary(event Event) string {
+	var text strings.Builder
+	if event.Success {
+		fmt.Fprintf(&text, "%s was posted.\n\n", event.File)
+	} else {
+		fmt.Fprintf(&text, "Posting %s failed: %s\n\n", event.File, event.Error)
+	}
+	fmt.Fprintf(&text, "Size:     %s\n", utils.FormatFileSize(event.Size))
+	fmt.Fprintf(&text, "Duration: %s\n", formatDuration(event.Duration))
+	fmt.Fprintf(&text, "Groups:   %s\n", strings.Join(event.Groups, ", "))
+	fmt.Fprintf(&text, "Articles: %d\n", event.Articles)
+	if event.NZB != "" {
+		fmt.Fprintf(&text, "NZB:      %s\n", event.NZB)
+	}
+	return text.String()
+}
+
+// send delivers a message over SMTP
+func (e *Email) send(ctx context.Context, message []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, emailTimeout)
+	defer cancel()
+	address := net.JoinHostPort(e.host, strconv.Itoa(e.port))
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	if e.security == "tls" {
+		conn = tls.Client(conn, &tls.Config{ServerName: e.host})
+	}
+
+	client, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if e.security == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not support STARTTLS", address)
+		}
+		if err := client.StartTLS(&tls.Config{ServerName: e.host}); err != nil {
+			return err
+		}
+	}
+	if e.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.username, e.password, e.host)); err != nil {
+			return fmt.Errorf("SMTP login: %w", err)
+		}
+	}
+
+	sender, _ := mail.ParseAddress(e.from)
+	if err := client.Mail(sender.Address); err != nil {
+		return err
+	}
+	for _, to := range e.to {
+		recipient, _ := mail.ParseAddress(to)
+		if err := client.Rcpt(recipient.Address); err != nil {
+			return err
+		}
+	}
+	data, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := data.Write(message); err != nil {
+		return err
+	}
+	if err := data.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,176 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"ypost/pkg/models"
+)
+
+// smtpMessage is a mail received by serveSMTP
+type smtpMessage struct {
+	from, auth string
+	to         []string
+	data       string
+}
+
+// serveSMTP accepts one mail on a minimal SMTP server without TLS
+func serveSMTP(t *testing.T) (string, <-chan smtpMessage) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan smtpMessage, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+		var message smtpMessage
+		reply("220 localhost ready")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.TrimSpace(line)
+			switch verb := strings.ToUpper(strings.SplitN(command, " ", 2)[0]); verb {
+			case "EHLO":
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case "AUTH":
+				message.auth = command
+				reply("235 accepted")
+			case "MAIL":
+				message.from = command
+				reply("250 ok")
+			case "RCPT":
+				message.to = append(message.to, command)
+				reply("250 ok")
+			case "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				message.data = data.String()
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				received <- message
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestEmail(t *testing.T) {
+	address, received := serveSMTP(t)
+	host, port, _ := net.SplitHostPort(address)
+	nzbPath := filepath.Join(t.TempDir(), "movie.nzb")
+	nzbData := strings.Repeat("<nzb>segment</nzb>\n", 20)
+	if err := os.WriteFile(nzbPath, []byte(nzbData), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	portNumber, _ := strconv.Atoi(port)
+	email, err := NewEmail(models.EmailConfig{
+		Host:      host,
+		Port:      portNumber,
+		Security:  "none",
+		Username:  "poster",
+		Password:  "secret",
+		From:      "yPost <ypost@example.com>",
+		To:        []string{"me@example.com"},
+		AttachNZB: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := testEvent
+	event.NZB = nzbPath
+	if err := email.Notify(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	message := <-received
+	if message.from != "MAIL FROM:<ypost@example.com>" || len(message.to) != 1 || message.to[0] != "RCPT TO:<me@example.com>" {
+		t.Errorf("unexpected envelope %q %q", message.from, message.to)
+	}
+	credentials, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(message.auth, "AUTH PLAIN "))
+	if string(credentials) != "\x00poster\x00secret" {
+		t.Errorf("unexpected credentials %q", credentials)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(message.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject := parsed.Header.Get("Subject"); subject != "[ypost] movie.mkv posted" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+	_, params, _ := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	text, err := parts.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(text)
+	if !strings.Contains(string(body), "movie.mkv was posted") || !strings.Contains(string(body), "Articles: 2") {
+		t.Errorf("unexpected summary %q", body)
+	}
+	attachment, err := parts.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := io.ReadAll(attachment)
+	decoded, _ := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(encoded), "\r\n", ""))
+	if attachment.FileName() != "movie.nzb" || string(decoded) != nzbData {
+		t.Errorf("unexpected attachment %q: %q", attachment.FileName(), decoded)
+	}
+}
+
+func TestNewEmailChecksSettings(t *testing.T) {
+	valid := models.EmailConfig{Host: "smtp.example.com", Port: 587, From: "ypost@example.com", To: []string{"me@example.com"}}
+	if _, err := NewEmail(valid); err != nil {
+		t.Fatal(err)
+	}
+	for _, change := range []func(*models.EmailConfig){
+		func(c *models.EmailConfig) { c.Host = "" },
+		func(c *models.EmailConfig) { c.Port = 0 },
+		func(c *models.EmailConfig) { c.Security = "ssl" },
+		func(c *models.EmailConfig) { c.From = "not an address" },
+		func(c *models.EmailConfig) { c.To = nil },
+		func(c *models.EmailConfig) { c.Subject = "{{.File" },
+	} {
+		cfg := valid
+		change(&cfg)
+		if _, err := NewEmail(cfg); err == nil {
+			t.Errorf("expected %+v to fail", cfg)
+		}
+	}
+}
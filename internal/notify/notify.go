@@ -0,0 +1,164 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"ypost/internal/utils"
+	"ypost/pkg/models"
+)
+
+// Kinds of events: a post starting, then completing or failing
+const (
+	EventStart    = "start"
+	EventComplete = "complete"
+	EventFailure  = "failure"
+)
+
+// Event is the report of a post starting or finishing
+type Event struct {
+	// Kind is start, complete or failure
+	Kind string `json:"event"`
+	// PostID is the ID of the post in its log lines and history record
+	PostID string `json:"post_id,omitempty"`
+	File   string `json:"file"`
+	// Size is the size of the posted files in bytes
+	Size int64 `json:"size"`
+	// Duration is the posting time in seconds
+	Duration float64  `json:"duration"`
+	NZB      string   `json:"nzb,omitempty"`
+	Groups   []string `json:"groups"`
+	Articles int      `json:"articles"`
+	Success  bool     `json:"success"`
+	Error    string   `json:"error,omitempty"`
+	// Category is the category of the NZB
+	Category string `json:"category,omitempty"`
+	// Password is the password of the posted archive, only written to the
+	// NZBs handed to blackhole folders
+	Password string `json:"-"`
+	// Time is when the post started or finished
+	Time time.Time `json:"time"`
+}
+
+// Outcomes filtering the events a notification is sent for
+const (
+	OnAll     = "all"
+	OnSuccess = "success"
+	OnFailure = "failure"
+)
+
+// ParseOn checks an outcome filter, all for ""
+func ParseOn(on string) (string, error) {
+	switch on = strings.ToLower(strings.TrimSpace(on)); on {
+	case "", OnAll:
+		return OnAll, nil
+	case OnSuccess, OnFailure:
+		return on, nil
+	}
+	return "", fmt.Errorf("invalid notification filter %q (expected all, success or failure)", on)
+}
+
+// wants reports whether an event passes an outcome filter, which only
+// finished posts can
+func wants(on string, event Event) bool {
+	if event.Kind == EventStart {
+		return false
+	}
+	switch on {
+	case OnSuccess:
+		return event.Success
+	case OnFailure:
+		return !event.Success
+	}
+	return true
+}
+
+// templateFuncs are available to payload templates: json quotes a value,
+// as a string field needs inside a JSON template, size formats a byte count,
+// duration a number of seconds and base the file name of a path
+var templateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+	"join":     strings.Join,
+	"size":     utils.FormatFileSize,
+	"duration": formatDuration,
+	"base":     filepath.Base,
+}
+
+// formatDuration formats a number of seconds to the second
+func formatDuration(seconds float64) string {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Second).String()
+}
+
+// parseTemplate parses a payload template, nil when text is empty
+func parseTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// render executes a payload template, or returns the JSON of the event
+// without one
+func render(tmpl *template.Template, event Event) ([]byte, error) {
+	if tmpl == nil {
+		return json.Marshal(event)
+	}
+	var payload strings.Builder
+	if err := tmpl.Execute(&payload, event); err != nil {
+		return nil, fmt.Errorf("failed to render payload: %w", err)
+	}
+	return []byte(payload.String()), nil
+}
+
+// Notifier delivers events
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+	// String describes the destination in log lines
+	String() string
+}
+
+// New returns the notifiers configured in the notification settings
+func New(cfg *models.Config) ([]Notifier, error) {
+	var notifiers []Notifier
+	for i, hookConfig := range cfg.Notifications.Webhooks {
+		hook, err := NewWebhook(hookConfig)
+		if err != nil {
+			return nil, fmt.Errorf("notifications.webhooks[%d]: %w", i, err)
+		}
+		notifiers = append(notifiers, hook)
+	}
+	if cfg.Notifications.Email.Enabled {
+		email, err := NewEmail(cfg.Notifications.Email)
+		if err != nil {
+			return nil, fmt.Errorf("notifications.email: %w", err)
+		}
+		notifiers = append(notifiers, email)
+	}
+	for i, chatConfig := range cfg.Notifications.Chats {
+		chat, err := NewChat(chatConfig)
+		if err != nil {
+			return nil, fmt.Errorf("notifications.chats[%d]: %w", i, err)
+		}
+		notifiers = append(notifiers, chat)
+	}
+	for i, arrConfig := range cfg.Notifications.Arrs {
+		arr, err := NewArr(arrConfig)
+		if err != nil {
+			return nil, fmt.Errorf("notifications.arrs[%d]: %w", i, err)
+		}
+		notifiers = append(notifiers, arr)
+	}
+	return notifiers, nil
+}
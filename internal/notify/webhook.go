@@ -0,0 +1,145 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"ypost/pkg/models"
+)
+
+// defaultAttempts bounds the deliveries of a webhook without attempts
+const defaultAttempts = 3
+
+// webhookTimeout bounds each delivery
+const webhookTimeout = 10 * time.Second
+
+// retryDelay is the wait before the second delivery, doubled afterwards
+var retryDelay = time.Second
+
+// Webhook posts events to a URL
+type Webhook struct {
+	url      string
+	on       string
+	template *template.Template
+	headers  map[string]string
+	attempts int
+	client   *http.Client
+	// check reads the error a receiver reports in a successful reply
+	check func(reply []byte) error
+}
+
+// NewWebhook checks the configuration of a webhook and parses its template
+func NewWebhook(cfg models.WebhookConfig) (*Webhook, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL %q (expected http or https)", cfg.URL)
+	}
+	on, err := ParseOn(cfg.On)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := parseTemplate("webhook", cfg.Template)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Attempts < 0 {
+		return nil, fmt.Errorf("invalid webhook attempts %d", cfg.Attempts)
+	}
+	attempts := cfg.Attempts
+	if attempts == 0 {
+		attempts = defaultAttempts
+	}
+	return &Webhook{
+		url:      cfg.URL,
+		on:       on,
+		template: tmpl,
+		headers:  cfg.Headers,
+		attempts: attempts,
+		client:   &http.Client{Timeout: webhookTimeout},
+	}, nil
+}
+
+// String returns the URL of the webhook without its credentials
+func (w *Webhook) String() string {
+	if u, err := url.Parse(w.url); err == nil {
+		return u.Redacted()
+	}
+	return w.url
+}
+
+// Notify posts an event unless the webhook filters it out
+func (w *Webhook) Notify(ctx context.Context, event Event) error {
+	if !wants(w.on, event) {
+		return nil
+	}
+	payload, err := render(w.template, event)
+	if err != nil {
+		return err
+	}
+	if err := w.send(ctx, payload); err != nil {
+		return fmt.Errorf("webhook %s: %w", w, err)
+	}
+	return nil
+}
+
+// send posts a payload, trying failed deliveries again with a growing delay
+// unless the receiver rejected it
+func (w *Webhook) send(ctx context.Context, payload []byte) error {
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		retry, err := w.deliver(ctx, payload)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.attempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// deliver posts the payload once, reporting whether a failure is worth
+// trying again: network errors, rate limiting and server errors are
+func (w *Webhook) deliver(ctx context.Context, payload []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ypost")
+	for name, value := range w.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		// The URL is left out, it may hold a token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return true, err
+	}
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if w.check != nil {
+			return false, w.check(reply)
+		}
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("%s", resp.Status)
+}
@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ypost/pkg/models"
+)
+
+// testEvent is a successful post of one file
+var testEvent = Event{
+	File:     "movie.mkv",
+	Size:     1048576,
+	Duration: 12.5,
+	NZB:      "/out/movie.nzb",
+	Groups:   []string{"alt.binaries.test"},
+	Articles: 2,
+	Success:  true,
+	Time:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+}
+
+func TestWebhookPostsJSON(t *testing.T) {
+	var received Event
+	var token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	hook, err := NewWebhook(models.WebhookConfig{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer abc"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Notify(context.Background(), testEvent); err != nil {
+		t.Fatal(err)
+	}
+	if received.File != "movie.mkv" || received.Size != 1048576 || received.NZB != "/out/movie.nzb" || !received.Success {
+		t.Errorf("unexpected payload %+v", received)
+	}
+	if token != "Bearer abc" {
+		t.Errorf("expected the configured header, got %q", token)
+	}
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer server.Close()
+
+	failed := testEvent
+	failed.Success, failed.Error = false, `server said "no"`
+	hook, err := NewWebhook(models.WebhookConfig{
+		URL:      server.URL,
+		On:       "failure",
+		Template: `{"text": {{json (printf "%s failed: %s" .File .Error)}}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Notify(context.Background(), testEvent); err != nil || body != "" {
+		t.Fatalf("expected a success to be filtered out, got %q (%v)", body, err)
+	}
+	if err := hook.Notify(context.Background(), failed); err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"text": "movie.mkv failed: server said \"no\""}`; body != expected {
+		t.Errorf("expected %s, got %s", expected, body)
+	}
+
+	if _, err := NewWebhook(models.WebhookConfig{URL: server.URL, Template: "{{.Missing"}); err == nil {
+		t.Error("expected an invalid template to fail")
+	}
+	if _, err := NewWebhook(models.WebhookConfig{URL: "ftp://example.com"}); err == nil {
+		t.Error("expected a non-HTTP URL to fail")
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	retryDelay = time.Millisecond
+	defer func() { retryDelay = time.Second }()
+
+	var calls int32
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(status)
+		}
+	}))
+	defer server.Close()
+
+	hook, err := NewWebhook(models.WebhookConfig{URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Notify(context.Background(), testEvent); err != nil || calls != 3 {
+		t.Fatalf("expected delivery on the third attempt, got %d calls (%v)", calls, err)
+	}
+
+	// A rejected payload is not sent again
+	calls, status = 0, http.StatusBadRequest
+	if err := hook.Notify(context.Background(), testEvent); err == nil || calls != 1 {
+		t.Errorf("expected one rejected delivery, got %d calls (%v)", calls, err)
+	}
+}
@@ -1,11 +1,15 @@
 package nzb
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"text/template"
 	"time"
 
 	"ypost/pkg/models"
@@ -13,8 +17,38 @@ import (
 
 // Generator handles NZB file generation
 type Generator struct {
-	outputDir string
-	poster    string
+	outputDir     string
+	poster        string
+	titleTemplate string
+	titleName     string
+	category      string
+	tags          []string
+	nameMapping   map[string]string
+	mappingMode   string
+	par2Mode      string
+	excludeSFV    bool
+	excludeNFO    bool
+}
+
+// Mapping modes for storing obfuscated file names
+const (
+	MappingNone    = "none"
+	MappingMeta    = "meta"
+	MappingSidecar = "sidecar"
+)
+
+// PAR2 inclusion modes for the NZB
+const (
+	PAR2All   = "all"
+	PAR2Index = "index"
+	PAR2None  = "none"
+)
+
+// NameMapping is the sidecar document recording obfuscated → real file names
+type NameMapping struct {
+	NZB       string            `json:"nzb"`
+	CreatedAt time.Time         `json:"created_at"`
+	Files     map[string]string `json:"files"`
 }
 
 // NewGenerator creates a new NZB generator
@@ -25,15 +59,61 @@ func NewGenerator(outputDir string, poster string) *Generator {
 	}
 }
 
+// SetMeta configures the NZB head metadata. The title template is a Go template
+// receiving .Filename and .Group; empty category or tags are omitted from the head.
+func (g *Generator) SetMeta(titleTemplate string, category string, tags []string) {
+	g.titleTemplate = titleTemplate
+	g.category = category
+	g.tags = tags
+}
+
+// SetTitleName sets the name the title is rendered from instead of the name
+// of the NZB, e.g. the random name of an obfuscated post
+func (g *Generator) SetTitleName(name string) {
+	g.titleName = name
+}
+
+// SetNameMapping records the obfuscated → real file names of the post. In meta
+// mode the mapping is embedded in the NZB head, in sidecar mode it is written to
+// a JSON file next to the NZB.
+func (g *Generator) SetNameMapping(mapping map[string]string, mode string) {
+	g.nameMapping = mapping
+	g.mappingMode = mode
+}
+
+// SetInclusion controls which additional files are listed in the NZB. The files
+// are still created and posted; this only affects what indexers see. par2Mode
+// is one of PAR2All, PAR2Index (only the .par2 index) or PAR2None.
+func (g *Generator) SetInclusion(par2Mode string, includeSFV bool, includeNFO bool) {
+	g.par2Mode = par2Mode
+	g.excludeSFV = !includeSFV
+	g.excludeNFO = !includeNFO
+}
+
+// FileEntry describes one posted input file and its segments. Lengths holds
+// the real size of posted files that were padded, keyed by posted file name.
+type FileEntry struct {
+	Name     string
+	Segments []*models.PostSegment
+	Lengths  map[string]int64
+}
+
 // Generate creates an NZB file from posting results
 func (g *Generator) Generate(fileName string, segments []*models.PostSegment, group string, additionalFiles map[string][]*models.PostSegment) (string, error) {
+	files := []FileEntry{{Name: fileName, Segments: segments}}
+	return g.GenerateMulti(fileName, files, group, additionalFiles)
+}
+
+// GenerateMulti creates a single NZB describing several posted input files,
+// e.g. a directory post, plus the shared PAR2/SFV/NFO files
+func (g *Generator) GenerateMulti(name string, files []FileEntry, group string, additionalFiles map[string][]*models.PostSegment) (string, error) {
 	if err := os.MkdirAll(g.outputDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	nzbContent := g.buildNZBContent(fileName, segments, group, additionalFiles)
+	nzbContent := g.buildNZBContent(name, files, group, additionalFiles)
 	
-	filePath := filepath.Join(g.outputDir, fmt.Sprintf("%s.nzb", sanitizeFileName(fileName)))
+	filePath := filepath.Join(g.outputDir, fmt.Sprintf("%s.nzb", sanitizeFileName(name)))
 	
 	file, err := os.Create(filePath)
 	if err != nil {
@@ -46,11 +126,42 @@ func (g *Generator) Generate(fileName string, segments []*models.PostSegment, gr
 		return "", fmt.Errorf("failed to write NZB file: %w", err)
 	}
 
+	if g.mappingMode == MappingSidecar && len(g.nameMapping) > 0 {
+		if err := g.writeMappingSidecar(filePath); err != nil {
+			return "", err
+		}
+	}
+
 	return filePath, nil
 }
 
+// MappingPath returns the sidecar mapping path for an NZB file
+func MappingPath(nzbPath string) string {
+	return strings.TrimSuffix(nzbPath, ".nzb") + ".mapping.json"
+}
+
+// writeMappingSidecar writes the name mapping as JSON next to the NZB file
+func (g *Generator) writeMappingSidecar(nzbPath string) error {
+	mapping := NameMapping{
+		NZB:       filepath.Base(nzbPath),
+		CreatedAt: time.Now(),
+		Files:     g.nameMapping,
+	}
+
+	data, err := json.MarshalIndent(mapping, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode name mapping: %w", err)
+	}
+
+	// The mapping reveals the real names, keep it private to the poster
+	if err := os.WriteFile(MappingPath(nzbPath), data, 0600); err != nil {
+		return fmt.Errorf("failed to write name mapping: %w", err)
+	}
+	return nil
+}
+
 // buildNZBContent constructs the NZB XML content as a string
-func (g *Generator) buildNZBContent(fileName string, segments []*models.PostSegment, group string, additionalFiles map[string][]*models.PostSegment) string {
+func (g *Generator) buildNZBContent(name string, files []FileEntry, group string, additionalFiles map[string][]*models.PostSegment) string {
 	var content strings.Builder
 	
 	// Add XML declaration and DOCTYPE - updated to NZB 1.1
@@ -58,28 +169,36 @@ func (g *Generator) buildNZBContent(fileName string, segments []*models.PostSegm
 <!DOCTYPE nzb PUBLIC "-//newzBin//DTD NZB 1.1//EN" "http://www.newzbin.com/DTD/nzb/nzb-1.1.dtd">
 <nzb xmlns="http://www.newzbin.com/DTD/2003/nzb">
   <head>
-    <meta type="title">` + sanitizeXML(fileName) + `</meta>
-    <meta type="category">misc</meta>
-    <meta type="tag">AI</meta>
-  </head>
+`)
+	lengths := make(map[string]int64)
+	for _, file := range files {
+		for fileName, length := range file.Lengths {
+			lengths[fileName] = length
+		}
+	}
+	title := name
+	if g.titleName != "" {
+		title = g.titleName
+	}
+	content.WriteString(g.buildHeadContent(title, group, lengths))
+	content.WriteString(`  </head>
 `)
 	
-	// Process all files (main file + additional files)
-	allFiles := []struct {
-		name     string
-		segments []*models.PostSegment
-	}{
-		{fileName, segments},
+	// Process all files (input files in posting order, then additional files)
+	var allFiles [][]*models.PostSegment
+	for _, file := range files {
+		allFiles = append(allFiles, groupByFileName(file.Segments)...)
 	}
 	
-	// Add additional files
-	for name, fileSegments := range additionalFiles {
-		if len(fileSegments) > 0 {
-			allFiles = append(allFiles, struct {
-				name     string
-				segments []*models.PostSegment
-			}{name, fileSegments})
-		}
+	// Add additional files in a stable order (map iteration is random)
+	var additionalNames []string
+	for name := range additionalFiles {
+		additionalNames = append(additionalNames, name)
+	}
+	sort.Strings(additionalNames)
+	for _, name := range additionalNames {
+		// Each PAR2 volume gets its own <file> entry
+		allFiles = append(allFiles, groupByFileName(g.filterAdditional(name, additionalFiles[name]))...)
 	}
 	
 	// Split group string by comma for multiple groups
@@ -89,8 +208,8 @@ func (g *Generator) buildNZBContent(fileName string, segments []*models.PostSegm
 	}
 	
 	// Create file entries
-	for _, file := range allFiles {
-		if len(file.segments) == 0 {
+	for _, fileSegments := range allFiles {
+		if len(fileSegments) == 0 {
 			continue
 		}
 		
@@ -99,7 +218,7 @@ func (g *Generator) buildNZBContent(fileName string, segments []*models.PostSegm
 		date := time.Now().Unix()
 		
 		// Use the actual subject from the segment
-		subject := file.segments[0].Subject
+		subject := fileSegments[0].Subject
 		
 		content.WriteString(fmt.Sprintf(`  <file poster="%s" date="%d" subject="%s">
     <groups>
@@ -116,7 +235,7 @@ func (g *Generator) buildNZBContent(fileName string, segments []*models.PostSegm
 `)
 		
 		// Add segments with actual message IDs
-		for _, segment := range file.segments {
+		for _, segment := range fileSegments {
 			segmentID := g.generateSegmentID(segment.MessageID)
 			content.WriteString(fmt.Sprintf(`      <segment bytes="%d" number="%d">%s</segment>
 `, segment.BytesPosted, segment.PartNumber, segmentID))
@@ -131,6 +250,137 @@ func (g *Generator) buildNZBContent(fileName string, segments []*models.PostSegm
 	return content.String()
 }
 
+// buildHeadContent renders the meta entries of the NZB head
+func (g *Generator) buildHeadContent(fileName string, group string, lengths map[string]int64) string {
+	var content strings.Builder
+
+	content.WriteString(fmt.Sprintf(`    <meta type="title">%s</meta>
+`, sanitizeXML(g.renderTitle(fileName, group))))
+
+	if g.category != "" {
+		content.WriteString(fmt.Sprintf(`    <meta type="category">%s</meta>
+`, sanitizeXML(g.category)))
+	}
+
+	for _, tag := range g.tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		content.WriteString(fmt.Sprintf(`    <meta type="tag">%s</meta>
+`, sanitizeXML(tag)))
+	}
+
+	if g.mappingMode == MappingMeta && len(g.nameMapping) > 0 {
+		// json.Marshal sorts map keys, so the entry is deterministic
+		data, err := json.Marshal(g.nameMapping)
+		if err == nil {
+			content.WriteString(fmt.Sprintf(`    <meta type="x-ypost-mapping">%s</meta>
+`, sanitizeXML(string(data))))
+		}
+	}
+
+	if len(lengths) > 0 {
+		// Padded files must be truncated to these lengths after download
+		data, err := json.Marshal(lengths)
+		if err == nil {
+			content.WriteString(fmt.Sprintf(`    <meta type="x-ypost-lengths">%s</meta>
+`, sanitizeXML(string(data))))
+		}
+	}
+
+	return content.String()
+}
+
+// renderTitle processes the title template, falling back to the file name
+func (g *Generator) renderTitle(fileName string, group string) string {
+	if g.titleTemplate == "" {
+		return fileName
+	}
+
+	tmpl, err := template.New("title").Parse(g.titleTemplate)
+	if err != nil {
+		return fileName
+	}
+
+	templateData := struct {
+		Filename string
+		Group    string
+	}{
+		Filename: fileName,
+		Group:    group,
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, templateData); err != nil || buf.Len() == 0 {
+		return fileName
+	}
+	return buf.String()
+}
+
+// sortSegments returns a copy of segments ordered by file name and segment number.
+// Parallel uploads complete out of order, so the NZB must not rely on append order.
+func sortSegments(segments []*models.PostSegment) []*models.PostSegment {
+	sorted := make([]*models.PostSegment, len(segments))
+	copy(sorted, segments)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].FileName != sorted[j].FileName {
+			return sorted[i].FileName < sorted[j].FileName
+		}
+		return sorted[i].PartNumber < sorted[j].PartNumber
+	})
+	return sorted
+}
+
+// filterAdditional applies the inclusion settings to one kind of additional file
+func (g *Generator) filterAdditional(kind string, segments []*models.PostSegment) []*models.PostSegment {
+	switch kind {
+	case "SFV":
+		if g.excludeSFV {
+			return nil
+		}
+	case "NFO":
+		if g.excludeNFO {
+			return nil
+		}
+	case "PAR2":
+		switch g.par2Mode {
+		case PAR2None:
+			return nil
+		case PAR2Index:
+			var index []*models.PostSegment
+			for _, segment := range segments {
+				if !isPAR2Volume(segment.FileName) {
+					index = append(index, segment)
+				}
+			}
+			return index
+		}
+	}
+	return segments
+}
+
+// isPAR2Volume reports whether name is a recovery volume (name.volNNN+NN.par2)
+func isPAR2Volume(name string) bool {
+	return par2VolumePattern.MatchString(strings.ToLower(name))
+}
+
+var par2VolumePattern = regexp.MustCompile(`\.vol\d+\+\d+\.par2$`)
+
+// groupByFileName splits segments into one sorted group per posted file name
+func groupByFileName(segments []*models.PostSegment) [][]*models.PostSegment {
+	var groups [][]*models.PostSegment
+	for _, segment := range sortSegments(segments) {
+		last := len(groups) - 1
+		if last >= 0 && groups[last][0].FileName == segment.FileName {
+			groups[last] = append(groups[last], segment)
+			continue
+		}
+		groups = append(groups, []*models.PostSegment{segment})
+	}
+	return groups
+}
+
 // generateUniqueID creates a unique identifier for a file
 func (g *Generator) generateUniqueID() string {
 	const safeChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
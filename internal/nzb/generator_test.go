@@ -0,0 +1,288 @@
+package nzb
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ypost/pkg/models"
+)
+
+func TestGenerateSortsSegments(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Segments in completion order, as produced by parallel workers
+	segments := []*models.PostSegment{
+		{MessageID: "<c@test>", PartNumber: 3, FileName: "test.bin", Subject: "test", BytesPosted: 10},
+		{MessageID: "<a@test>", PartNumber: 1, FileName: "test.bin", Subject: "test", BytesPosted: 10},
+		{MessageID: "<b@test>", PartNumber: 2, FileName: "test.bin", Subject: "test", BytesPosted: 10},
+	}
+	par2Segments := []*models.PostSegment{
+		{MessageID: "<p2@test>", PartNumber: 2, FileName: "test.par2", Subject: "par2", BytesPosted: 10},
+		{MessageID: "<p1@test>", PartNumber: 1, FileName: "test.par2", Subject: "par2", BytesPosted: 10},
+	}
+
+	generator := NewGenerator(tempDir, "poster@example.com")
+	nzbPath, err := generator.Generate("test.bin", segments, "alt.binaries.test", map[string][]*models.PostSegment{
+		"PAR2": par2Segments,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(nzbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Message IDs must appear in segment number order within each file
+	expected := []string{"a@test", "b@test", "c@test", "p1@test", "p2@test"}
+	last := -1
+	for _, id := range expected {
+		idx := strings.Index(string(content), ">"+id+"<")
+		if idx < 0 {
+			t.Fatalf("segment %s not found in NZB", id)
+		}
+		if idx < last {
+			t.Errorf("segment %s out of order", id)
+		}
+		last = idx
+	}
+
+	// The caller's slice must not be reordered
+	if segments[0].PartNumber != 3 {
+		t.Error("Generate modified the input segment order")
+	}
+}
+
+func TestGenerateIsStable(t *testing.T) {
+	tempDir := t.TempDir()
+
+	segments := []*models.PostSegment{
+		{MessageID: "<b@test>", PartNumber: 2, FileName: "test.bin", Subject: "test", BytesPosted: 10},
+		{MessageID: "<a@test>", PartNumber: 1, FileName: "test.bin", Subject: "test", BytesPosted: 10},
+	}
+	additional := map[string][]*models.PostSegment{
+		"SFV":  {{MessageID: "<s@test>", PartNumber: 1, FileName: "test.sfv", Subject: "sfv", BytesPosted: 10}},
+		"PAR2": {{MessageID: "<p@test>", PartNumber: 1, FileName: "test.par2", Subject: "par2", BytesPosted: 10}},
+	}
+
+	generator := NewGenerator(tempDir, "poster@example.com")
+	files := []FileEntry{{Name: "test.bin", Segments: segments}}
+	first := generator.buildNZBContent("test.bin", files, "alt.binaries.test", additional)
+	for i := 0; i < 10; i++ {
+		again := generator.buildNZBContent("test.bin", files, "alt.binaries.test", additional)
+		// Dates are second-granular; compare with them stripped
+		if stripDates(again) != stripDates(first) {
+			t.Fatal("NZB content is not deterministic across runs")
+		}
+	}
+}
+
+// stripDates removes date attributes so content can be compared across runs
+func stripDates(content string) string {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		if idx := strings.Index(line, `date="`); idx >= 0 {
+			end := strings.Index(line[idx+6:], `"`)
+			line = line[:idx] + line[idx+6+end+1:]
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestGenerateMulti(t *testing.T) {
+	files := []FileEntry{
+		{Name: "b.bin", Segments: []*models.PostSegment{
+			{MessageID: "<b1@test>", PartNumber: 1, FileName: "b.bin", Subject: "b subject", BytesPosted: 10},
+		}},
+		{Name: "a.bin", Segments: []*models.PostSegment{
+			{MessageID: "<a2@test>", PartNumber: 2, FileName: "a.bin", Subject: "a subject", BytesPosted: 10},
+			{MessageID: "<a1@test>", PartNumber: 1, FileName: "a.bin", Subject: "a subject", BytesPosted: 10},
+		}},
+	}
+	additional := map[string][]*models.PostSegment{
+		"PAR2": {
+			{MessageID: "<v1@test>", PartNumber: 1, FileName: "dir.vol000+01.par2", Subject: "vol", BytesPosted: 10},
+			{MessageID: "<p1@test>", PartNumber: 1, FileName: "dir.par2", Subject: "index", BytesPosted: 10},
+		},
+	}
+
+	generator := NewGenerator(t.TempDir(), "poster@example.com")
+	content := generator.buildNZBContent("dir", files, "alt.binaries.test", additional)
+
+	// One <file> per input file and per PAR2 file
+	if n := strings.Count(content, "<file "); n != 4 {
+		t.Fatalf("expected 4 file entries, got %d:\n%s", n, content)
+	}
+
+	// Input files keep posting order, shared files follow
+	order := []string{`subject="b subject"`, `subject="a subject"`, `subject="index"`, `subject="vol"`}
+	last := -1
+	for _, want := range order {
+		idx := strings.Index(content, want)
+		if idx < last {
+			t.Errorf("file entry %s out of order", want)
+		}
+		last = idx
+	}
+}
+
+func TestGenerateHeadMeta(t *testing.T) {
+	generator := NewGenerator(t.TempDir(), "poster@example.com")
+	generator.SetMeta("{{.Filename}} [{{.Group}}]", "tv", []string{"hd", " ", "x264"})
+
+	head := generator.buildHeadContent("show.mkv", "alt.binaries.test", nil)
+
+	for _, want := range []string{
+		`<meta type="title">show.mkv [alt.binaries.test]</meta>`,
+		`<meta type="category">tv</meta>`,
+		`<meta type="tag">hd</meta>`,
+		`<meta type="tag">x264</meta>`,
+	} {
+		if !strings.Contains(head, want) {
+			t.Errorf("head missing %q:\n%s", want, head)
+		}
+	}
+	if strings.Count(head, `type="tag"`) != 2 {
+		t.Errorf("blank tags should be skipped:\n%s", head)
+	}
+
+	// Without configuration only the title is written
+	generator.SetMeta("", "", nil)
+	head = generator.buildHeadContent("show.mkv", "alt.binaries.test", nil)
+	if strings.Contains(head, "category") || strings.Contains(head, "tag") {
+		t.Errorf("unexpected meta in default head:\n%s", head)
+	}
+}
+
+func TestPaddedLengths(t *testing.T) {
+	files := []FileEntry{{
+		Name: "show.mkv",
+		Segments: []*models.PostSegment{
+			{MessageID: "<a@test>", PartNumber: 1, FileName: "show.mkv", Subject: "show", BytesPosted: 10},
+		},
+		Lengths: map[string]int64{"show.mkv": 2500},
+	}}
+
+	generator := NewGenerator(t.TempDir(), "poster@example.com")
+	content := generator.buildNZBContent("show.mkv", files, "alt.binaries.test", nil)
+	if !strings.Contains(content, `<meta type="x-ypost-lengths">{&quot;show.mkv&quot;:2500}</meta>`) {
+		t.Errorf("lengths meta missing:\n%s", content)
+	}
+}
+
+func TestNameMapping(t *testing.T) {
+	tempDir := t.TempDir()
+	segments := []*models.PostSegment{
+		{MessageID: "<a@test>", PartNumber: 1, FileName: "x7k2q9.bin", Subject: "x7k2q9", BytesPosted: 10},
+	}
+	mapping := map[string]string{"x7k2q9.bin": "holiday & family.mkv"}
+
+	// Meta mode embeds the mapping in the head
+	generator := NewGenerator(tempDir, "poster@example.com")
+	generator.SetNameMapping(mapping, MappingMeta)
+	head := generator.buildHeadContent("x7k2q9.bin", "alt.binaries.test", nil)
+	if !strings.Contains(head, `<meta type="x-ypost-mapping">{&quot;x7k2q9.bin&quot;:&quot;holiday \u0026 family.mkv&quot;}</meta>`) {
+		t.Errorf("mapping meta missing or not escaped:\n%s", head)
+	}
+
+	// Sidecar mode writes JSON next to the NZB and keeps the head clean
+	generator.SetNameMapping(mapping, MappingSidecar)
+	nzbPath, err := generator.Generate("x7k2q9.bin", segments, "alt.binaries.test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(nzbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "holiday") {
+		t.Error("sidecar mode leaked the real name into the NZB")
+	}
+
+	data, err := os.ReadFile(MappingPath(nzbPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sidecar NameMapping
+	if err := json.Unmarshal(data, &sidecar); err != nil {
+		t.Fatal(err)
+	}
+	if sidecar.Files["x7k2q9.bin"] != "holiday & family.mkv" {
+		t.Errorf("unexpected sidecar mapping: %v", sidecar.Files)
+	}
+}
+
+func TestTitleName(t *testing.T) {
+	segments := []*models.PostSegment{
+		{MessageID: "<a@test>", PartNumber: 1, FileName: "x7k2q9.mkv", Subject: "x7k2q9", BytesPosted: 10},
+	}
+	generator := NewGenerator(t.TempDir(), "poster@example.com")
+	generator.SetTitleName("x7k2q9")
+	nzbPath, err := generator.Generate("holiday", segments, "alt.binaries.test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The NZB keeps the name of the post, its title the random one
+	if filepath.Base(nzbPath) != "holiday.nzb" {
+		t.Errorf("expected holiday.nzb, got %s", filepath.Base(nzbPath))
+	}
+	content, err := os.ReadFile(nzbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `<meta type="title">x7k2q9</meta>`) || strings.Contains(string(content), "holiday") {
+		t.Errorf("expected the title of the random name only:\n%s", content)
+	}
+}
+
+func TestInclusion(t *testing.T) {
+	files := []FileEntry{{Name: "test.bin", Segments: []*models.PostSegment{
+		{MessageID: "<a@test>", PartNumber: 1, FileName: "test.bin", Subject: "main", BytesPosted: 10},
+	}}}
+	additional := map[string][]*models.PostSegment{
+		"PAR2": {
+			{MessageID: "<p@test>", PartNumber: 1, FileName: "test.par2", Subject: "index", BytesPosted: 10},
+			{MessageID: "<v@test>", PartNumber: 1, FileName: "test.vol000+01.par2", Subject: "vol", BytesPosted: 10},
+		},
+		"SFV": {
+			{MessageID: "<s@test>", PartNumber: 1, FileName: "test.sfv", Subject: "sfv", BytesPosted: 10},
+		},
+		"NFO": {
+			{MessageID: "<n@test>", PartNumber: 1, FileName: "test.nfo", Subject: "nfo", BytesPosted: 10},
+		},
+	}
+
+	tests := []struct {
+		par2Mode   string
+		includeSFV bool
+		includeNFO bool
+		want       []string
+		notWant    []string
+	}{
+		{PAR2All, true, true, []string{"p@test", "v@test", "s@test", "n@test"}, nil},
+		{PAR2Index, true, false, []string{"p@test", "s@test"}, []string{"v@test", "n@test"}},
+		{PAR2None, false, true, []string{"a@test", "n@test"}, []string{"p@test", "v@test", "s@test"}},
+	}
+
+	generator := NewGenerator(t.TempDir(), "poster@example.com")
+	for _, test := range tests {
+		generator.SetInclusion(test.par2Mode, test.includeSFV, test.includeNFO)
+		content := generator.buildNZBContent("test.bin", files, "alt.binaries.test", additional)
+		for _, id := range test.want {
+			if !strings.Contains(content, ">"+id+"<") {
+				t.Errorf("mode %s/%v: expected %s in NZB", test.par2Mode, test.includeSFV, id)
+			}
+		}
+		for _, id := range test.notWant {
+			if strings.Contains(content, ">"+id+"<") {
+				t.Errorf("mode %s/%v: unexpected %s in NZB", test.par2Mode, test.includeSFV, id)
+			}
+		}
+	}
+}
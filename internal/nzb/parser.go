@@ -0,0 +1,262 @@
+package nzb
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// NZB is a parsed NZB document
+type NZB struct {
+	Meta  []Meta `xml:"head>meta"`
+	Files []File `xml:"file"`
+}
+
+// Meta is an entry of the NZB head
+type Meta struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// File is a posted file of an NZB
+type File struct {
+	Poster   string    `xml:"poster,attr"`
+	Date     int64     `xml:"date,attr"`
+	Subject  string    `xml:"subject,attr"`
+	Groups   []string  `xml:"groups>group"`
+	Segments []Segment `xml:"segments>segment"`
+}
+
+// Segment is an article of a file
+type Segment struct {
+	Bytes     int64  `xml:"bytes,attr"`
+	Number    int    `xml:"number,attr"`
+	MessageID string `xml:",chardata"`
+}
+
+var (
+	quotedName    = regexp.MustCompile(`"([^"]+)"`)
+	subjectPrefix = regexp.MustCompile(`^[\[(]\d+/\d+[\])]\s*-\s*`)
+	subjectSuffix = regexp.MustCompile(`(\s*-\s*\([^)]*\))?(\s*yEnc\s*(\(\d+/\d+\))?)?\s*$`)
+	subjectTotal  = regexp.MustCompile(`yEnc\s*\(\d+/(\d+)\)`)
+)
+
+// ParseFile reads the NZB at path
+func ParseFile(path string) (*NZB, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open NZB: %w", err)
+	}
+	defer file.Close()
+	return Parse(file)
+}
+
+// Parse reads an NZB document. Segments are sorted by number and their
+// message IDs trimmed of angle brackets and spaces.
+func Parse(r io.Reader) (*NZB, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read NZB: %w", err)
+	}
+
+	var doc NZB
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// NZBs declare iso-8859-1 but are often written in UTF-8, ours included
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		if utf8.Valid(data) {
+			return input, nil
+		}
+		return latin1Reader(input)
+	}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse NZB: %w", err)
+	}
+	if len(doc.Files) == 0 {
+		return nil, fmt.Errorf("NZB contains no files")
+	}
+
+	for i := range doc.Files {
+		file := &doc.Files[i]
+		for j := range file.Segments {
+			file.Segments[j].MessageID = strings.Trim(strings.TrimSpace(file.Segments[j].MessageID), "<>")
+		}
+		sort.SliceStable(file.Segments, func(a, b int) bool {
+			return file.Segments[a].Number < file.Segments[b].Number
+		})
+	}
+	return &doc, nil
+}
+
+// MetaValue returns the first head entry of the given type, or ""
+func (n *NZB) MetaValue(metaType string) string {
+	for _, meta := range n.Meta {
+		if meta.Type == metaType {
+			return strings.TrimSpace(meta.Value)
+		}
+	}
+	return ""
+}
+
+// SetMetaValue replaces the value of the first head entry of the given type,
+// adding the entry when there is none
+func (n *NZB) SetMetaValue(metaType, value string) {
+	for i := range n.Meta {
+		if n.Meta[i].Type == metaType {
+			n.Meta[i].Value = value
+			return
+		}
+	}
+	n.Meta = append(n.Meta, Meta{Type: metaType, Value: value})
+}
+
+// Lengths returns the real lengths of padded files recorded in the head, by
+// posted name
+func (n *NZB) Lengths() (map[string]int64, error) {
+	lengths := make(map[string]int64)
+	if value := n.MetaValue("x-ypost-lengths"); value != "" {
+		if err := json.Unmarshal([]byte(value), &lengths); err != nil {
+			return nil, fmt.Errorf("invalid file lengths in NZB: %w", err)
+		}
+	}
+	return lengths, nil
+}
+
+// Mapping returns the obfuscated → real file names recorded in the head
+func (n *NZB) Mapping() (map[string]string, error) {
+	mapping := make(map[string]string)
+	if value := n.MetaValue("x-ypost-mapping"); value != "" {
+		if err := json.Unmarshal([]byte(value), &mapping); err != nil {
+			return nil, fmt.Errorf("invalid name mapping in NZB: %w", err)
+		}
+	}
+	return mapping, nil
+}
+
+// ReadMapping reads the sidecar name mapping of an NZB file; it returns nil
+// when there is none
+func ReadMapping(nzbPath string) (map[string]string, error) {
+	data, err := os.ReadFile(MappingPath(nzbPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read name mapping: %w", err)
+	}
+	var mapping NameMapping
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("invalid name mapping %s: %w", MappingPath(nzbPath), err)
+	}
+	return mapping.Files, nil
+}
+
+// SegmentCount returns the number of segments of all files
+func (n *NZB) SegmentCount() int {
+	count := 0
+	for _, file := range n.Files {
+		count += len(file.Segments)
+	}
+	return count
+}
+
+// Name returns the file name given in the subject: the quoted name when there
+// is one, else the subject without the part counters and yEnc suffix
+func (f *File) Name() string {
+	if match := quotedName.FindStringSubmatch(f.Subject); match != nil {
+		return match[1]
+	}
+	name := subjectPrefix.ReplaceAllString(f.Subject, "")
+	name = strings.TrimSpace(subjectSuffix.ReplaceAllString(name, ""))
+	if name == "" {
+		return f.Subject
+	}
+	return name
+}
+
+// Bytes returns the total size of the file's segments
+func (f *File) Bytes() int64 {
+	var total int64
+	for _, segment := range f.Segments {
+		total += segment.Bytes
+	}
+	return total
+}
+
+// ExpectedSegments returns the number of segments the file should have: the
+// total its subject announces ("yEnc (1/N)"), or the highest segment number
+// when that is larger or the subject announces none
+func (f *File) ExpectedSegments() int {
+	expected := 0
+	if match := subjectTotal.FindStringSubmatch(f.Subject); match != nil {
+		expected, _ = strconv.Atoi(match[1])
+	}
+	for _, segment := range f.Segments {
+		if segment.Number > expected {
+			expected = segment.Number
+		}
+	}
+	return expected
+}
+
+// Gaps returns the numbers of the expected segments the file lacks
+func (f *File) Gaps() []int {
+	present := make(map[int]bool, len(f.Segments))
+	for _, segment := range f.Segments {
+		present[segment.Number] = true
+	}
+	var gaps []int
+	for number := 1; number <= f.ExpectedSegments(); number++ {
+		if !present[number] {
+			gaps = append(gaps, number)
+		}
+	}
+	return gaps
+}
+
+// Groups returns the groups of all files, in order of appearance
+func (n *NZB) Groups() []string {
+	seen := make(map[string]bool)
+	var groups []string
+	for _, file := range n.Files {
+		for _, group := range file.Groups {
+			if !seen[group] {
+				seen[group] = true
+				groups = append(groups, group)
+			}
+		}
+	}
+	return groups
+}
+
+// Posters returns the posters of all files, in order of appearance
+func (n *NZB) Posters() []string {
+	seen := make(map[string]bool)
+	var posters []string
+	for _, file := range n.Files {
+		if !seen[file.Poster] {
+			seen[file.Poster] = true
+			posters = append(posters, file.Poster)
+		}
+	}
+	return posters
+}
+
+// latin1Reader converts iso-8859-1 input to UTF-8
+func latin1Reader(input io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return strings.NewReader(string(runes)), nil
+}
@@ -0,0 +1,108 @@
+package nzb
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"ypost/pkg/models"
+)
+
+func TestParseGeneratedNZB(t *testing.T) {
+	segments := []*models.PostSegment{
+		{MessageID: "<b@test>", PartNumber: 2, FileName: "test.bin", Subject: "[1/1] - test.bin - (20B) yEnc (2/2)", BytesPosted: 10},
+		{MessageID: "<a@test>", PartNumber: 1, FileName: "test.bin", Subject: "[1/1] - test.bin - (20B) yEnc (1/2)", BytesPosted: 10},
+	}
+
+	generator := NewGenerator(t.TempDir(), "poster@example.com")
+	generator.SetMeta("", "TV", nil)
+	nzbPath, err := generator.Generate("test.bin", segments, "alt.binaries.test, alt.binaries.misc", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := ParseFile(nzbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Files) != 1 {
+		t.Fatalf("expected 1 file, got %d", len(doc.Files))
+	}
+	file := doc.Files[0]
+	if file.Name() != "test.bin" {
+		t.Errorf("unexpected file name %q", file.Name())
+	}
+	if file.Poster != "poster@example.com" || len(file.Groups) != 2 || file.Groups[1] != "alt.binaries.misc" {
+		t.Errorf("unexpected file attributes %+v", file)
+	}
+	if len(file.Segments) != 2 || file.Segments[0].MessageID != "a@test" || file.Segments[1].Number != 2 {
+		t.Errorf("unexpected segments %+v", file.Segments)
+	}
+	if file.Bytes() != 20 || doc.SegmentCount() != 2 {
+		t.Errorf("unexpected sizes: %d bytes, %d segments", file.Bytes(), doc.SegmentCount())
+	}
+	if doc.MetaValue("category") != "TV" {
+		t.Errorf("unexpected category %q", doc.MetaValue("category"))
+	}
+}
+
+func TestParseSortsAndTrims(t *testing.T) {
+	doc, err := Parse(strings.NewReader(`<?xml version="1.0" encoding="iso-8859-1"?>
+<nzb xmlns="http://www.newzbin.com/DTD/2003/nzb">
+  <file poster="p" date="1" subject="&quot;caf` + "\xe9" + `.mkv&quot; yEnc (1/2)">
+    <groups><group>alt.binaries.test</group></groups>
+    <segments>
+      <segment bytes="5" number="2"> &lt;two@test&gt; </segment>
+      <segment bytes="5" number="1">one@test</segment>
+    </segments>
+  </file>
+</nzb>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := doc.Files[0]
+	if file.Name() != "café.mkv" {
+		t.Errorf("unexpected file name %q", file.Name())
+	}
+	if file.Segments[0].MessageID != "one@test" || file.Segments[1].MessageID != "two@test" {
+		t.Errorf("unexpected segments %+v", file.Segments)
+	}
+}
+
+func TestParseRejectsEmptyNZB(t *testing.T) {
+	if _, err := Parse(strings.NewReader(`<nzb></nzb>`)); err == nil {
+		t.Error("expected an error for an NZB without files")
+	}
+}
+
+func TestFileName(t *testing.T) {
+	tests := map[string]string{
+		`[01/10] - "movie.part01.rar" yEnc (1/50)`:          "movie.part01.rar",
+		`[1/1] - movie.mkv - (1.2GB) yEnc (10/1000)`:        "movie.mkv",
+		`(02/03) - my file.bin - (10.0MB) yEnc (0001/0014)`: "my file.bin",
+		`[1/1] - data.bin - (2.9MB)`:                        "data.bin",
+		`plain subject`:                                     "plain subject",
+	}
+	for subject, expected := range tests {
+		file := File{Subject: subject}
+		if name := file.Name(); name != expected {
+			t.Errorf("%q: expected %q, got %q", subject, expected, name)
+		}
+	}
+}
+
+func TestGaps(t *testing.T) {
+	file := File{Subject: `"a.bin" yEnc (1/6)`, Segments: []Segment{{Number: 1}, {Number: 3}, {Number: 4}}}
+	if expected := file.ExpectedSegments(); expected != 6 {
+		t.Errorf("expected 6 segments from the subject, got %d", expected)
+	}
+	if gaps := fmt.Sprint(file.Gaps()); gaps != "[2 5 6]" {
+		t.Errorf("unexpected gaps %s", gaps)
+	}
+
+	// Without a total, segments past the highest number cannot be missed
+	file = File{Subject: "a.bin", Segments: []Segment{{Number: 2}, {Number: 3}}}
+	if gaps := fmt.Sprint(file.Gaps()); gaps != "[1]" {
+		t.Errorf("unexpected gaps %s", gaps)
+	}
+}
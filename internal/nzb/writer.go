@@ -0,0 +1,73 @@
+package nzb
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Write renders the document in the layout of the generated NZBs
+func (n *NZB) Write(w io.Writer) error {
+	var content strings.Builder
+
+	content.WriteString(`<?xml version="1.0" encoding="iso-8859-1"?>
+<!DOCTYPE nzb PUBLIC "-//newzBin//DTD NZB 1.1//EN" "http://www.newzbin.com/DTD/nzb/nzb-1.1.dtd">
+<nzb xmlns="http://www.newzbin.com/DTD/2003/nzb">
+  <head>
+`)
+	for _, meta := range n.Meta {
+		content.WriteString(fmt.Sprintf(`    <meta type="%s">%s</meta>
+`, sanitizeXML(meta.Type), sanitizeXML(meta.Value)))
+	}
+	content.WriteString(`  </head>
+`)
+
+	for _, file := range n.Files {
+		content.WriteString(fmt.Sprintf(`  <file poster="%s" date="%d" subject="%s">
+    <groups>
+`, sanitizeXML(file.Poster), file.Date, sanitizeXML(file.Subject)))
+		for _, group := range file.Groups {
+			content.WriteString(fmt.Sprintf(`      <group>%s</group>
+`, sanitizeXML(group)))
+		}
+		content.WriteString(`    </groups>
+    <segments>
+`)
+		for _, segment := range file.Segments {
+			content.WriteString(fmt.Sprintf(`      <segment bytes="%d" number="%d">%s</segment>
+`, segment.Bytes, segment.Number, sanitizeXML(strings.Trim(segment.MessageID, "<>"))))
+		}
+		content.WriteString(`    </segments>
+  </file>
+`)
+	}
+	content.WriteString("</nzb>")
+
+	if _, err := io.WriteString(w, content.String()); err != nil {
+		return fmt.Errorf("failed to write NZB: %w", err)
+	}
+	return nil
+}
+
+// WriteFile writes the document to path, replacing it atomically
+func (n *NZB) WriteFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".nzb-*")
+	if err != nil {
+		return fmt.Errorf("failed to create NZB: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := n.Write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write NZB: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write NZB: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package nzb
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"ypost/pkg/models"
+)
+
+func TestWriteRoundTrip(t *testing.T) {
+	segments := []*models.PostSegment{
+		{MessageID: "<a@test>", PartNumber: 1, FileName: "a & b.bin", Subject: `[1/1] - "a & b.bin" - (20B) yEnc (1/2)`, BytesPosted: 10},
+		{MessageID: "<b@test>", PartNumber: 2, FileName: "a & b.bin", Subject: `[1/1] - "a & b.bin" - (20B) yEnc (2/2)`, BytesPosted: 10},
+	}
+	generator := NewGenerator(t.TempDir(), "poster@example.com")
+	generator.SetMeta("", "TV", []string{"tag"})
+	nzbPath, err := generator.Generate("a & b.bin", segments, "alt.binaries.test, alt.binaries.misc", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ParseFile(nzbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc.Files[0].Segments[1].MessageID = "c@test"
+	patched := filepath.Join(t.TempDir(), "patched.nzb")
+	if err := doc.WriteFile(patched); err != nil {
+		t.Fatal(err)
+	}
+	reparsed, err := ParseFile(patched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc, reparsed) {
+		t.Errorf("round trip changed the document:\n%+v\n%+v", doc, reparsed)
+	}
+}
@@ -0,0 +1,67 @@
+package obfuscate
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// alphabet holds the characters of the random names; 248 is the largest
+// multiple of its length below 256, so every character is equally likely
+const (
+	alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	unbiased = 248
+)
+
+// DefaultLength is the length of the random names when none is configured
+const DefaultLength = 16
+
+// Obfuscator derives the random file names, subjects and poster of a post
+// from a seed, so a resumed post gets the same ones
+type Obfuscator struct {
+	seed   []byte
+	length int
+}
+
+// New returns an obfuscator deriving names of length characters from seed
+func New(seed string, length int) *Obfuscator {
+	if length <= 0 {
+		length = DefaultLength
+	}
+	return &Obfuscator{seed: []byte(seed), length: length}
+}
+
+// Name returns the random name a file is posted under
+func (o *Obfuscator) Name(name string) string {
+	return o.token("name", name, o.length)
+}
+
+// Subject returns the random subject of the articles of a posted file
+func (o *Obfuscator) Subject(name string) string {
+	return o.token("subject", name, o.length)
+}
+
+// Poster returns the random poster name and address of the post
+func (o *Obfuscator) Poster() (string, string) {
+	name := o.token("poster", "", 10)
+	address := o.token("user", "", 10) + "@" + o.token("domain", "", 8) + ".com"
+	return name, address
+}
+
+// token derives length random characters from kind and value
+func (o *Obfuscator) token(kind string, value string, length int) string {
+	result := make([]byte, 0, length)
+	for counter := uint32(0); len(result) < length; counter++ {
+		mac := hmac.New(sha256.New, o.seed)
+		mac.Write([]byte(kind))
+		mac.Write([]byte{0})
+		mac.Write([]byte(value))
+		binary.Write(mac, binary.BigEndian, counter)
+		for _, b := range mac.Sum(nil) {
+			if b < unbiased && len(result) < length {
+				result = append(result, alphabet[int(b)%len(alphabet)])
+			}
+		}
+	}
+	return string(result)
+}
@@ -0,0 +1,41 @@
+package obfuscate
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestNamesAreStablePerSeed(t *testing.T) {
+	first := New("seed", 20)
+	again := New("seed", 20)
+	other := New("other", 20)
+
+	name := first.Name("movie.mkv")
+	if len(name) != 20 || !regexp.MustCompile(`^[A-Za-z0-9]+$`).MatchString(name) {
+		t.Errorf("unexpected name %q", name)
+	}
+	if again.Name("movie.mkv") != name {
+		t.Error("expected the same seed to give the same name")
+	}
+	if other.Name("movie.mkv") == name || first.Name("movie.nfo") == name {
+		t.Error("expected other seeds and files to give other names")
+	}
+	if first.Subject("movie.mkv") == name {
+		t.Error("expected the subject to differ from the name")
+	}
+
+	posterName, address := first.Poster()
+	againName, againAddress := again.Poster()
+	if posterName != againName || address != againAddress {
+		t.Error("expected the same seed to give the same poster")
+	}
+	if !regexp.MustCompile(`^[A-Za-z0-9]+@[A-Za-z0-9]+\.com$`).MatchString(address) {
+		t.Errorf("unexpected poster address %q", address)
+	}
+}
+
+func TestDefaultLength(t *testing.T) {
+	if name := New("seed", 0).Name("file"); len(name) != DefaultLength {
+		t.Errorf("expected %d characters, got %q", DefaultLength, name)
+	}
+}
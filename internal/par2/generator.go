@@ -4,15 +4,18 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"sync"
-	"time"
 	"unsafe"
 
-	"github.com/schollz/progressbar/v3"
+	"ypost/internal/logger"
+	"ypost/internal/memory"
+	"ypost/internal/progress"
+
 	"golang.org/x/exp/mmap"
 )
 
@@ -21,14 +24,112 @@ import "github.com/klauspost/reedsolomon"
 
 // Generator handles PAR2 recovery file generation
 type Generator struct {
-	par2Path string
+	par2Path  string
+	sliceSize int
+	log       logger.Interface
+	sinks     progress.SinkFactory
+	// budget bounds the memory of the shards, unlimited when nil
+	budget *memory.Budget
+	// fileCRCs are the CRC32 of the files written, computed as they are
+	mu       sync.Mutex
+	fileCRCs map[string]uint32
+	// names are the names files are described under, their base names
+	// when not set
+	names map[string]string
 }
 
 // NewGenerator creates a new PAR2 generator
 func NewGenerator(par2Path string) *Generator {
 	return &Generator{
 		par2Path: par2Path,
+		log:      logger.Discard,
+		sinks:    progress.NewSink,
+		fileCRCs: make(map[string]uint32),
+		names:    make(map[string]string),
+	}
+}
+
+// FileCRCs returns the CRC32 of every PAR2 file written so far, keyed by
+// path. They are computed while writing, so the SFV need not read the files
+// back.
+func (g *Generator) FileCRCs() map[string]uint32 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	crcs := make(map[string]uint32, len(g.fileCRCs))
+	for path, crc := range g.fileCRCs {
+		crcs[path] = crc
+	}
+	return crcs
+}
+
+// checksumFile is a file being written and the CRC32 of what was written,
+// recorded by the generator once closed
+type checksumFile struct {
+	file *os.File
+	g    *Generator
+	crc  uint32
+}
+
+// create creates a file whose CRC32 is computed as it is written
+func (g *Generator) create(path string) (*checksumFile, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
 	}
+	return &checksumFile{file: file, g: g}, nil
+}
+
+// Write writes to the file, updating its CRC32
+func (f *checksumFile) Write(p []byte) (int, error) {
+	n, err := f.file.Write(p)
+	f.crc = crc32.Update(f.crc, crc32.IEEETable, p[:n])
+	return n, err
+}
+
+// Close closes the file and records its CRC32
+func (f *checksumFile) Close() error {
+	f.g.mu.Lock()
+	f.g.fileCRCs[f.file.Name()] = f.crc
+	f.g.mu.Unlock()
+	return f.file.Close()
+}
+
+// SetLogger sets where the generator reports its progress
+func (g *Generator) SetLogger(log logger.Interface) {
+	g.log = log
+}
+
+// SetSinkFactory sets where the steps of the generation report their
+// progress: computing the recovery data, then writing the volumes
+func (g *Generator) SetSinkFactory(factory progress.SinkFactory) {
+	g.sinks = factory
+}
+
+// SetMemoryBudget makes the generation hold its shards and recovery data
+// within budget, encoding the shards in stripes when they do not fit
+func (g *Generator) SetMemoryBudget(budget *memory.Budget) {
+	g.budget = budget
+}
+
+// SetSliceSize sets the recovery block size in bytes, a multiple of 4; 0
+// picks it from the size of the data
+func (g *Generator) SetSliceSize(size int) {
+	g.sliceSize = size
+}
+
+// SetEntryName sets the name a file is described under, e.g. the relative
+// path or random name it is posted as
+func (g *Generator) SetEntryName(filePath string, name string) {
+	g.names[filePath] = name
+}
+
+// entryName returns the name a file is described under, never the path it
+// is read from
+func (g *Generator) entryName(filePath string) string {
+	if name, ok := g.names[filePath]; ok {
+		return name
+	}
+	return filepath.Base(filePath)
 }
 
 // CreatePAR2ForParts creates PAR2 recovery files for split file parts (standard practice)
@@ -37,8 +138,8 @@ func (g *Generator) CreatePAR2ForParts(parts []string, baseName string, redundan
 		return nil, fmt.Errorf("no parts provided")
 	}
 
-	fmt.Printf("Creating PAR2 recovery files for %d parts of: %s\n", len(parts), baseName)
-	fmt.Printf("Redundancy: %d%%\n", redundancy)
+	g.log.Info("Creating PAR2 recovery files for %d parts of: %s", len(parts), baseName)
+	g.log.Info("Redundancy: %d%%", redundancy)
 
 	// Calculate total size of all parts
 	var totalSize int64
@@ -63,6 +164,7 @@ func (g *Generator) CreatePAR2ForParts(parts []string, baseName string, redundan
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate recovery data: %w", err)
 	}
+	defer g.budget.Release(int64(len(recoveryData)))
 
 	// Write main PAR2 index file (control file with file list)
 	err = g.writePAR2IndexFileForParts(par2File, parts, sliceSize)
@@ -80,7 +182,7 @@ func (g *Generator) CreatePAR2ForParts(parts []string, baseName string, redundan
 	}
 	par2Files = append(par2Files, volFiles...)
 
-	fmt.Printf("PAR2 recovery files created successfully: %d files\n", len(par2Files))
+	g.log.Info("PAR2 recovery files created successfully: %d files", len(par2Files))
 	return par2Files, nil
 }
 
@@ -91,8 +193,8 @@ func (g *Generator) CreatePAR2(filePath string, redundancy int) ([]string, error
 		return nil, fmt.Errorf("failed to stat file: %w", err)
 	}
 
-	fmt.Printf("Creating PAR2 recovery files for: %s\n", fileInfo.Name())
-	fmt.Printf("File size: %d bytes, Redundancy: %d%%\n", fileInfo.Size(), redundancy)
+	g.log.Info("Creating PAR2 recovery files for: %s", fileInfo.Name())
+	g.log.Info("File size: %d bytes, Redundancy: %d%%", fileInfo.Size(), redundancy)
 
 	// Calculate recovery slice parameters
 	fileSize := fileInfo.Size()
@@ -109,6 +211,7 @@ func (g *Generator) CreatePAR2(filePath string, redundancy int) ([]string, error
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate recovery data: %w", err)
 	}
+	defer g.budget.Release(int64(len(recoveryData)))
 
 	// Write main PAR2 index file (small control file)
 	err = g.writePAR2IndexFile(par2File, filePath, sliceSize, numSlices)
@@ -126,12 +229,16 @@ func (g *Generator) CreatePAR2(filePath string, redundancy int) ([]string, error
 	}
 	par2Files = append(par2Files, volFiles...)
 
-	fmt.Printf("PAR2 recovery files created successfully: %d files\n", len(par2Files))
+	g.log.Info("PAR2 recovery files created successfully: %d files", len(par2Files))
 	return par2Files, nil
 }
 
 // calculateSliceSize determines appropriate slice size based on file size
 func (g *Generator) calculateSliceSize(fileSize int64) int {
+	if g.sliceSize > 0 {
+		return g.sliceSize
+	}
+
 	// Use different slice sizes based on file size
 	switch {
 	case fileSize < 1024*1024: // < 1MB
@@ -185,15 +292,7 @@ func (g *Generator) generateRecoveryDataMmap(filePath string, sliceSize, numSlic
 	}
 	
 	// Create progress bar with throttled updates
-	progressBar := progressbar.NewOptions(recoverySize,
-		progressbar.OptionSetDescription("Generating recovery data (mmap)"),
-		progressbar.OptionShowCount(),
-		progressbar.OptionSetWidth(15),
-		progressbar.OptionSetRenderBlankState(true),
-		progressbar.OptionSetPredictTime(false),
-		progressbar.OptionClearOnFinish(),
-		progressbar.OptionThrottle(200*time.Millisecond),
-	)
+	sink := g.sinks(progress.Task{Description: "Generating recovery data (mmap)", Total: int64(recoverySize), Transient: true, Step: 1, Steps: 2})
 
 	recoveryData := make([]byte, recoverySize*sliceSize)
 	
@@ -213,7 +312,7 @@ func (g *Generator) generateRecoveryDataMmap(filePath string, sliceSize, numSlic
 			
 			// Throttled progress update
 			if recoveryIndex%max(1, recoverySize/100) == 0 {
-				progressBar.Add(1)
+				sink.Add(1)
 			}
 		}(i)
 		
@@ -224,7 +323,7 @@ func (g *Generator) generateRecoveryDataMmap(filePath string, sliceSize, numSlic
 	}
 	wg.Wait()
 	
-	progressBar.Finish()
+	sink.Finish()
 	return recoveryData, nil
 }
 
@@ -237,15 +336,7 @@ func (g *Generator) generateRecoveryDataStream(filePath string, sliceSize, numSl
 	defer file.Close()
 
 	// Create progress bar
-	progressBar := progressbar.NewOptions(recoverySize,
-		progressbar.OptionSetDescription("Generating recovery data (stream)"),
-		progressbar.OptionShowCount(),
-		progressbar.OptionSetWidth(15),
-		progressbar.OptionSetRenderBlankState(true),
-		progressbar.OptionSetPredictTime(false),
-		progressbar.OptionClearOnFinish(),
-		progressbar.OptionThrottle(200*time.Millisecond),
-	)
+	sink := g.sinks(progress.Task{Description: "Generating recovery data (stream)", Total: int64(recoverySize), Transient: true, Step: 1, Steps: 2})
 
 	recoveryData := make([]byte, recoverySize*sliceSize)
 	
@@ -275,7 +366,7 @@ func (g *Generator) generateRecoveryDataStream(filePath string, sliceSize, numSl
 			g.xorBytes(recoverySlice, slice)
 		}
 		
-		progressBar.Add(1)
+		sink.Add(1)
 	}
 	
 	return recoveryData, nil
@@ -368,13 +459,7 @@ func (g *Generator) generateRecoveryDataReedSolomon(filePath string, sliceSize i
 		parityShards = 1
 	}
 
-	fmt.Printf("Reed-Solomon encoding: %d data shards, %d parity shards\n", numSlices, parityShards)
-
-	// Create Reed-Solomon encoder
-	enc, err := reedsolomon.New(numSlices, parityShards)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Reed-Solomon encoder: %w", err)
-	}
+	g.log.Debug("Reed-Solomon encoding: %d data shards, %d parity shards", numSlices, parityShards)
 
 	// Read file data into shards
 	file, err := os.Open(filePath)
@@ -383,56 +468,13 @@ func (g *Generator) generateRecoveryDataReedSolomon(filePath string, sliceSize i
 	}
 	defer file.Close()
 
-	// Create progress bar
-	progressBar := progressbar.NewOptions(numSlices+parityShards,
-		progressbar.OptionSetDescription("Reed-Solomon encoding"),
-		progressbar.OptionShowCount(),
-		progressbar.OptionSetWidth(15),
-		progressbar.OptionSetRenderBlankState(true),
-		progressbar.OptionSetPredictTime(false),
-		progressbar.OptionClearOnFinish(),
-		progressbar.OptionThrottle(200*time.Millisecond),
-	)
-
-	// Create shards
-	shards := make([][]byte, numSlices+parityShards)
-	for i := 0; i < numSlices; i++ {
-		shards[i] = make([]byte, sliceSize)
-		n, err := file.Read(shards[i])
-		if err != nil && err != io.EOF {
-			return nil, fmt.Errorf("failed to read shard: %w", err)
-		}
-		// Pad with zeros if needed
-		if n < sliceSize {
-			for j := n; j < sliceSize; j++ {
-				shards[i][j] = 0
-			}
-		}
-		progressBar.Add(1)
-	}
-
-	// Initialize parity shards
-	for i := numSlices; i < numSlices+parityShards; i++ {
-		shards[i] = make([]byte, sliceSize)
-	}
-
-	// Generate parity data
-	err = enc.Encode(shards)
-	if err != nil {
-		return nil, fmt.Errorf("failed to encode shards: %w", err)
-	}
-
-	// Update progress for parity generation
-	progressBar.Add(parityShards)
-	progressBar.Finish()
-
-	// Combine parity shards into recovery data
-	recoveryData := make([]byte, parityShards*sliceSize)
-	for i := 0; i < parityShards; i++ {
-		copy(recoveryData[i*sliceSize:(i+1)*sliceSize], shards[numSlices+i])
+	// The shards are the consecutive slices of the file
+	shards := make([]dataShard, numSlices)
+	for i := range shards {
+		offset := int64(i) * int64(sliceSize)
+		shards[i] = dataShard{file: file, offset: offset, size: int(min(int64(sliceSize), fileSize-offset))}
 	}
-
-	return recoveryData, nil
+	return g.encodeShards(shards, sliceSize, parityShards, "Reed-Solomon encoding")
 }
 
 // generateRecoveryDataReedSolomonFromParts creates Reed-Solomon recovery data from multiple file parts
@@ -453,93 +495,144 @@ func (g *Generator) generateRecoveryDataReedSolomonFromParts(parts []string, sli
 		parityShards = 1
 	}
 
-	fmt.Printf("Reed-Solomon encoding from parts: %d data shards, %d parity shards\n", numSlices, parityShards)
-
-	// Create Reed-Solomon encoder
-	enc, err := reedsolomon.New(numSlices, parityShards)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Reed-Solomon encoder: %w", err)
-	}
+	g.log.Debug("Reed-Solomon encoding from parts: %d data shards, %d parity shards", numSlices, parityShards)
 
-	// Create progress bar
-	progressBar := progressbar.NewOptions(numSlices+parityShards,
-		progressbar.OptionSetDescription("Reed-Solomon encoding (parts)"),
-		progressbar.OptionShowCount(),
-		progressbar.OptionSetWidth(15),
-		progressbar.OptionSetRenderBlankState(true),
-		progressbar.OptionSetPredictTime(false),
-		progressbar.OptionClearOnFinish(),
-		progressbar.OptionThrottle(200*time.Millisecond),
-	)
-
-	// Create shards
-	shards := make([][]byte, numSlices+parityShards)
-	
-	// Read data from all parts into shards
-	shardIndex := 0
+	// Each part starts a new shard, up to numSlices shards
+	var shards []dataShard
 	for _, partPath := range parts {
+		if len(shards) >= numSlices {
+			break
+		}
 		file, err := os.Open(partPath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to open part %s: %w", partPath, err)
 		}
-		
-		// Read this part into shards
-		for {
-			if shardIndex >= numSlices {
-				break
-			}
-			
-			shards[shardIndex] = make([]byte, sliceSize)
-			n, err := file.Read(shards[shardIndex])
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				file.Close()
-				return nil, fmt.Errorf("failed to read shard from part %s: %w", partPath, err)
-			}
-			
-			// Pad with zeros if needed
-			if n < sliceSize {
-				for j := n; j < sliceSize; j++ {
-					shards[shardIndex][j] = 0
-				}
-			}
-			
-			shardIndex++
-			progressBar.Add(1)
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat part %s: %w", partPath, err)
+		}
+		for offset := int64(0); offset < info.Size() && len(shards) < numSlices; offset += int64(sliceSize) {
+			shards = append(shards, dataShard{file: file, offset: offset, size: int(min(int64(sliceSize), info.Size()-offset))})
 		}
-		
-		file.Close()
 	}
-
-	// Initialize remaining parity shards
-	for i := numSlices; i < numSlices+parityShards; i++ {
-		shards[i] = make([]byte, sliceSize)
+	// Shards left past the parts are zeros
+	for len(shards) < numSlices {
+		shards = append(shards, dataShard{})
 	}
+	return g.encodeShards(shards, sliceSize, parityShards, "Reed-Solomon encoding (parts)")
+}
+
+// dataShard is where the data of a shard is read from, the shard padded with
+// zeros past size
+type dataShard struct {
+	file   io.ReaderAt
+	offset int64
+	size   int
+}
 
-	// Generate parity data
-	err = enc.Encode(shards)
+// encodeShards computes the parity shards of the data shards. When the
+// shards exceed the memory budget they are encoded in stripes, the same
+// range of bytes of every shard at once, Reed-Solomon encoding each byte
+// offset on its own. The recovery data is held in the budget until released
+// by the caller.
+func (g *Generator) encodeShards(shards []dataShard, sliceSize int, parityShards int, description string) ([]byte, error) {
+	enc, err := reedsolomon.New(len(shards), parityShards)
 	if err != nil {
-		return nil, fmt.Errorf("failed to encode shards: %w", err)
+		return nil, fmt.Errorf("failed to create Reed-Solomon encoder: %w", err)
+	}
+
+	total := len(shards) + parityShards
+	recoverySize := int64(parityShards) * int64(sliceSize)
+	width := g.stripeWidth(total, sliceSize, recoverySize)
+	stripes := (sliceSize + width - 1) / width
+	if stripes > 1 {
+		g.log.Info("Encoding PAR2 in %d stripes of %d bytes to fit the memory budget", stripes, width)
 	}
+	working := int64(total) * int64(width)
+	g.budget.Acquire(recoverySize + working)
+	defer g.budget.Release(working)
 
-	// Update progress for parity generation
-	progressBar.Add(parityShards)
-	progressBar.Finish()
+	// Create progress bar
+	sink := g.sinks(progress.Task{Description: description, Total: int64(total * stripes), Transient: true, Step: 1, Steps: 2})
+
+	buffers := make([][]byte, total)
+	for i := range buffers {
+		buffers[i] = make([]byte, width)
+	}
+	recoveryData := make([]byte, recoverySize)
+	for start := 0; start < sliceSize; start += width {
+		size := min(width, sliceSize-start)
+		stripe := make([][]byte, total)
+		for i, shard := range shards {
+			stripe[i] = buffers[i][:size]
+			if err := shard.read(stripe[i], start); err != nil {
+				g.budget.Release(recoverySize)
+				return nil, fmt.Errorf("failed to read shard: %w", err)
+			}
+			sink.Add(1)
+		}
+		for i := len(shards); i < total; i++ {
+			stripe[i] = buffers[i][:size]
+		}
 
-	// Combine parity shards into recovery data
-	recoveryData := make([]byte, parityShards*sliceSize)
-	for i := 0; i < parityShards; i++ {
-		copy(recoveryData[i*sliceSize:(i+1)*sliceSize], shards[numSlices+i])
+		// Generate parity data
+		if err := enc.Encode(stripe); err != nil {
+			g.budget.Release(recoverySize)
+			return nil, fmt.Errorf("failed to encode shards: %w", err)
+		}
+		for i := 0; i < parityShards; i++ {
+			copy(recoveryData[i*sliceSize+start:], stripe[len(shards)+i])
+		}
+
+		// Update progress for parity generation
+		sink.Add(int64(parityShards))
 	}
+	sink.Finish()
 
 	return recoveryData, nil
 }
 
+// stripeWidth returns the bytes of each of total shards encoded at once: the
+// whole slice, unless it does not fit in the budget left by the recovery data
+func (g *Generator) stripeWidth(total int, sliceSize int, recoverySize int64) int {
+	limit := g.budget.Limit()
+	if limit == 0 || int64(total)*int64(sliceSize)+recoverySize <= limit {
+		return sliceSize
+	}
+	// Stripes of whole 64 byte words, the unit of the encoder
+	width := int((limit - recoverySize) / int64(total))
+	width -= width % 64
+	if width < 64 {
+		g.log.Warn("The PAR2 recovery data of %d bytes leaves no room in the memory budget of %d bytes", recoverySize, limit)
+		width = 64
+	}
+	return min(width, sliceSize)
+}
+
+// read reads the bytes of the shard at offset into p, zeros past its size
+func (s dataShard) read(p []byte, offset int) error {
+	n := 0
+	if s.file != nil && offset < s.size {
+		end := min(len(p), s.size-offset)
+		read, err := s.file.ReadAt(p[:end], s.offset+int64(offset))
+		if read < end {
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		n = end
+	}
+	for i := n; i < len(p); i++ {
+		p[i] = 0
+	}
+	return nil
+}
+
 // writePAR2IndexFile writes the main PAR2 index file (control file)
 func (g *Generator) writePAR2IndexFile(par2File string, originalFile string, sliceSize int, numSlices int) error {
-	file, err := os.Create(par2File)
+	file, err := g.create(par2File)
 	if err != nil {
 		return fmt.Errorf("failed to create PAR2 index file: %w", err)
 	}
@@ -556,7 +649,7 @@ func (g *Generator) writePAR2IndexFile(par2File string, originalFile string, sli
 	fileHash := g.calculateFileHash(originalFile)
 
 	// Create file description
-	desc := g.createFileDescription(originalFile, fileInfo.Size(), sliceSize, numSlices, fileHash)
+	desc := g.createFileDescription(g.entryName(originalFile), fileInfo.Size(), sliceSize, numSlices, fileHash)
 	if _, err := file.Write(desc); err != nil {
 		return fmt.Errorf("failed to write file description: %w", err)
 	}
@@ -566,7 +659,7 @@ func (g *Generator) writePAR2IndexFile(par2File string, originalFile string, sli
 
 // writePAR2VolumeFile writes a PAR2 volume file with recovery data
 func (g *Generator) writePAR2VolumeFile(volFile string, originalFile string, sliceSize int, numSlices int, recoveryData []byte) error {
-	file, err := os.Create(volFile)
+	file, err := g.create(volFile)
 	if err != nil {
 		return fmt.Errorf("failed to create PAR2 volume file: %w", err)
 	}
@@ -583,7 +676,7 @@ func (g *Generator) writePAR2VolumeFile(volFile string, originalFile string, sli
 	fileHash := g.calculateFileHash(originalFile)
 
 	// Create file description
-	desc := g.createFileDescription(originalFile, fileInfo.Size(), sliceSize, numSlices, fileHash)
+	desc := g.createFileDescription(g.entryName(originalFile), fileInfo.Size(), sliceSize, numSlices, fileHash)
 	if _, err := file.Write(desc); err != nil {
 		return fmt.Errorf("failed to write file description: %w", err)
 	}
@@ -654,15 +747,7 @@ func (g *Generator) createStandardVOLFiles(baseName string, recoveryData []byte,
 	volIndex := 0
 	
 	// Create progress bar for VOL file creation
-	volBar := progressbar.NewOptions(totalRecoveryBlocks,
-		progressbar.OptionSetDescription("Creating PAR2 volumes"),
-		progressbar.OptionShowCount(),
-		progressbar.OptionSetWidth(15),
-		progressbar.OptionSetRenderBlankState(true),
-		progressbar.OptionSetPredictTime(false),
-		progressbar.OptionClearOnFinish(),
-		progressbar.OptionThrottle(100*time.Millisecond),
-	)
+	sink := g.sinks(progress.Task{Description: "Creating PAR2 volumes", Total: int64(totalRecoveryBlocks), Transient: true, Step: 2, Steps: 2})
 	
 	for blockIndex < totalRecoveryBlocks {
 		// Calculate blocks for this volume (start with 1, then powers of 2: 1, 2, 4, 8, ...)
@@ -694,16 +779,16 @@ func (g *Generator) createStandardVOLFiles(baseName string, recoveryData []byte,
 		blockIndex += blocksInVolume
 		volIndex++
 		
-		volBar.Add(blocksInVolume)
+		sink.Add(int64(blocksInVolume))
 	}
 	
-	volBar.Finish()
+	sink.Finish()
 	return volFiles, nil
 }
 
 // writeVolumeFile writes a PAR2 volume file with just the recovery data
 func (g *Generator) writeVolumeFile(volFile string, recoveryData []byte) error {
-	file, err := os.Create(volFile)
+	file, err := g.create(volFile)
 	if err != nil {
 		return fmt.Errorf("failed to create volume file: %w", err)
 	}
@@ -769,18 +854,10 @@ func (g *Generator) generateRecoveryDataFromParts(parts []string, sliceSize int,
 		recoverySlices = 1
 	}
 
-	fmt.Printf("Generating recovery data: %d slices, %d recovery slices\n", numSlices, recoverySlices)
+	g.log.Info("Generating recovery data: %d slices, %d recovery slices", numSlices, recoverySlices)
 
 	// Create progress bar
-	progressBar := progressbar.NewOptions(recoverySlices,
-		progressbar.OptionSetDescription("Generating recovery data"),
-		progressbar.OptionShowCount(),
-		progressbar.OptionSetWidth(15),
-		progressbar.OptionSetRenderBlankState(true),
-		progressbar.OptionSetPredictTime(false),
-		progressbar.OptionClearOnFinish(),
-		progressbar.OptionThrottle(200*time.Millisecond),
-	)
+	sink := g.sinks(progress.Task{Description: "Generating recovery data", Total: int64(recoverySlices), Transient: true, Step: 1, Steps: 2})
 
 	recoveryData := make([]byte, recoverySlices*sliceSize)
 	
@@ -808,10 +885,10 @@ func (g *Generator) generateRecoveryDataFromParts(parts []string, sliceSize int,
 			}
 		}
 		
-		progressBar.Add(1)
+		sink.Add(1)
 	}
 	
-	progressBar.Finish()
+	sink.Finish()
 	return recoveryData, nil
 }
 
@@ -855,7 +932,7 @@ func (g *Generator) xorPartIntoRecoverySlice(partPath string, sliceOffset int, s
 
 // writePAR2IndexFileForParts writes the main PAR2 index file for multiple parts
 func (g *Generator) writePAR2IndexFileForParts(par2File string, parts []string, sliceSize int) error {
-	file, err := os.Create(par2File)
+	file, err := g.create(par2File)
 	if err != nil {
 		return fmt.Errorf("failed to create PAR2 index file: %w", err)
 	}
@@ -878,7 +955,7 @@ func (g *Generator) writePAR2IndexFileForParts(par2File string, parts []string,
 		numSlices := int((fileInfo.Size() + int64(sliceSize) - 1) / int64(sliceSize))
 		
 		// Create file description for this part
-		desc := g.createFileDescription(partPath, fileInfo.Size(), sliceSize, numSlices, fileHash)
+		desc := g.createFileDescription(g.entryName(partPath), fileInfo.Size(), sliceSize, numSlices, fileHash)
 		if _, err := file.Write(desc); err != nil {
 			return fmt.Errorf("failed to write file description for %s: %w", partPath, err)
 		}
@@ -1,9 +1,14 @@
 package par2
 
 import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"ypost/internal/memory"
 )
 
 func TestPAR2Generation(t *testing.T) {
@@ -109,6 +114,35 @@ func TestPAR2GenerationForParts(t *testing.T) {
 	t.Logf("Successfully created %d PAR2 files for parts", len(par2Files))
 }
 
+func TestFileCRCs(t *testing.T) {
+	tempDir := t.TempDir()
+	part := filepath.Join(tempDir, "test.bin")
+	if err := os.WriteFile(part, bytes.Repeat([]byte("recovery data "), 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	generator := NewGenerator(tempDir)
+	par2Files, err := generator.CreatePAR2ForParts([]string{part}, "test.bin", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every file written has the CRC32 of its content
+	crcs := generator.FileCRCs()
+	if len(crcs) != len(par2Files) {
+		t.Errorf("expected %d CRCs, got %d", len(par2Files), len(crcs))
+	}
+	for _, par2File := range par2Files {
+		data, err := os.ReadFile(par2File)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if crc, ok := crcs[par2File]; !ok || crc != crc32.ChecksumIEEE(data) {
+			t.Errorf("%s: wrong CRC32 %08X", filepath.Base(par2File), crc)
+		}
+	}
+}
+
 func TestXORFunctions(t *testing.T) {
 	generator := NewGenerator("")
 	
@@ -138,4 +172,80 @@ func TestXORFunctions(t *testing.T) {
 			t.Errorf("Optimized XOR failed at index %d: got %02x, want %02x", i, dstCopy2[i], v)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestPAR2StripedWithinBudget(t *testing.T) {
+	tempDir := t.TempDir()
+	var parts []string
+	for i := 0; i < 3; i++ {
+		data := make([]byte, 20000)
+		for j := range data {
+			data[j] = byte((j*31 + i*7) % 251)
+		}
+		part := filepath.Join(tempDir, fmt.Sprintf("test.part%02d", i+1))
+		if err := os.WriteFile(part, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, part)
+	}
+
+	generator := NewGenerator(tempDir)
+	whole, err := generator.generateRecoveryDataReedSolomonFromParts(parts, 4096, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 16 shards of 4KB do not fit in 20000 bytes, encoded in stripes
+	budget := memory.NewBudget(20000)
+	generator.SetMemoryBudget(budget)
+	if width := generator.stripeWidth(16, 4096, 4096); width != 960 {
+		t.Fatalf("expected stripes of 960 bytes, got %d", width)
+	}
+	striped, err := generator.generateRecoveryDataReedSolomonFromParts(parts, 4096, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(striped, whole) {
+		t.Error("striped recovery data differs from the whole slices'")
+	}
+	// Only the recovery data is still held
+	if used := budget.Used(); used != int64(len(striped)) {
+		t.Errorf("expected %d bytes held, got %d", len(striped), used)
+	}
+}
+
+func TestPAR2EntryNames(t *testing.T) {
+	tempDir := t.TempDir()
+	named := filepath.Join(tempDir, "holiday.mkv")
+	unnamed := filepath.Join(tempDir, "extras.mkv")
+	for _, path := range []string{named, unnamed} {
+		if err := os.WriteFile(path, bytes.Repeat([]byte("a"), 4096), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	generator := NewGenerator(tempDir)
+	generator.SetEntryName(named, "x7k2q9.mkv")
+	par2Files, err := generator.CreatePAR2ForParts([]string{named, unnamed}, "x7k2q9", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The files are described under the names they are posted as, never
+	// the paths they are read from
+	for _, par2File := range par2Files {
+		data, err := os.ReadFile(par2File)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, []byte(tempDir)) || bytes.Contains(data, []byte("holiday")) {
+			t.Errorf("%s: the local path or real name is written", filepath.Base(par2File))
+		}
+	}
+	index, err := os.ReadFile(par2Files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(index, []byte("x7k2q9.mkv\x00")) || !bytes.Contains(index, []byte("extras.mkv\x00")) {
+		t.Errorf("expected the index to describe x7k2q9.mkv and extras.mkv")
+	}
+}
@@ -0,0 +1,170 @@
+package progress
+
+import (
+	"fmt"
+	"time"
+
+	"ypost/internal/utils"
+)
+
+// connectionsInterval is the shortest time between two reports of the
+// connections to the sink
+const connectionsInterval = 100 * time.Millisecond
+
+// maxArticleLabel is the width FormatConnection cuts the article to
+const maxArticleLabel = 48
+
+// ConnectionProgress is a snapshot of a connection of a tracker
+type ConnectionProgress struct {
+	// ID is the number of the connection, from 0
+	ID int
+	// Article is the article being posted, empty while idle
+	Article string
+	// For is how long the article has been posting, or the connection idle
+	For    time.Duration
+	Posted int
+	Sent   int64
+	// Speed is in bytes per second over the last SpeedWindow
+	Speed float64
+}
+
+// connection is the state of a connection of a tracker
+type connection struct {
+	article string
+	since   time.Time
+	started time.Time
+	posted  int
+	sent    int64
+	// samples are the articles posted over the last SpeedWindow
+	samples []sample
+}
+
+// FormatConnection renders a connection as a line of text: its number, speed
+// and the article it is posting and for how long, marked stalled after
+// SpeedWindow
+func FormatConnection(conn ConnectionProgress) string {
+	speed := fmt.Sprintf("%s/s", utils.FormatFileSize(int64(conn.Speed)))
+	if conn.Article == "" {
+		return fmt.Sprintf("  #%-2d %10s  idle", conn.ID+1, speed)
+	}
+	article := conn.Article
+	if len(article) > maxArticleLabel {
+		article = "..." + article[len(article)-maxArticleLabel+3:]
+	}
+	line := fmt.Sprintf("  #%-2d %10s  %s %s", conn.ID+1, speed, article, conn.For.Round(time.Second))
+	if conn.For >= SpeedWindow {
+		line += " (stalled)"
+	}
+	return line
+}
+
+// snapshot returns the state of connection id as of now, dropping the
+// samples out of the window
+func (c *connection) snapshot(id int, now time.Time) ConnectionProgress {
+	for len(c.samples) > 0 && now.Sub(c.samples[0].at) >= SpeedWindow {
+		c.samples = c.samples[1:]
+	}
+	var bytes int64
+	for _, s := range c.samples {
+		bytes += s.bytes
+	}
+	progress := ConnectionProgress{
+		ID:      id,
+		Article: c.article,
+		For:     now.Sub(c.since),
+		Posted:  c.posted,
+		Sent:    c.sent,
+	}
+	// A connection younger than the window is averaged over its life
+	if span := min(now.Sub(c.started), SpeedWindow); span > 0 {
+		progress.Speed = float64(bytes) / span.Seconds()
+	}
+	return progress
+}
+
+// SetConnections sets the number of connections of the upload, numbered
+// from 0, reported to the sink with the bytes. Connections already set are
+// kept.
+func (t *Tracker) SetConnections(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	for len(t.conns) < n {
+		t.conns = append(t.conns, &connection{since: now, started: now})
+	}
+	t.reportConnections(now)
+}
+
+// BeginArticle shows the article a connection starts posting
+func (t *Tracker) BeginArticle(conn int, article string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if c := t.connection(conn); c != nil {
+		c.article = article
+		c.since = t.now()
+		t.throttleConnections()
+	}
+}
+
+// EndArticle records the end of the article of a connection, which sent
+// bytes, none when the article failed
+func (t *Tracker) EndArticle(conn int, bytes int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if c := t.connection(conn); c != nil {
+		now := t.now()
+		c.article = ""
+		c.since = now
+		if bytes > 0 {
+			c.posted++
+			c.sent += bytes
+			c.samples = append(c.samples, sample{at: now, bytes: bytes})
+		}
+		t.throttleConnections()
+	}
+}
+
+// Connections returns the state of every connection set
+func (t *Tracker) Connections() []ConnectionProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.snapshotConnections(t.now())
+}
+
+// snapshotConnections returns the state of the connections as of now
+func (t *Tracker) snapshotConnections(now time.Time) []ConnectionProgress {
+	conns := make([]ConnectionProgress, len(t.conns))
+	for i, c := range t.conns {
+		conns[i] = c.snapshot(i, now)
+	}
+	return conns
+}
+
+// connection returns connection id, nil when not set
+func (t *Tracker) connection(id int) *connection {
+	if id < 0 || id >= len(t.conns) {
+		return nil
+	}
+	return t.conns[id]
+}
+
+// throttleConnections reports the connections to the sink unless they were
+// within connectionsInterval
+func (t *Tracker) throttleConnections() {
+	if now := t.now(); now.Sub(t.reported) >= connectionsInterval {
+		t.reportConnections(now)
+	}
+}
+
+// reportConnections reports the connections to the sink
+func (t *Tracker) reportConnections(now time.Time) {
+	if len(t.conns) == 0 {
+		return
+	}
+	t.reported = now
+	t.sink.Connections(t.snapshotConnections(now))
+}
@@ -0,0 +1,104 @@
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventInterval is the shortest time between two progress events of a
+// tracker; the completion of a file or job is always reported
+const EventInterval = 250 * time.Millisecond
+
+// Kinds of progress events
+const (
+	EventProgress = "progress"
+	EventFile     = "file"
+	EventComplete = "complete"
+)
+
+// Event is a progress event, written as one JSON line
+type Event struct {
+	// Event is progress while posting, file when a file of the job is
+	// posted and complete at the end of the job
+	Event  string `json:"event"`
+	PostID string `json:"post_id,omitempty"`
+	Job    string `json:"job"`
+	// File is the file being posted, or posted for file events
+	File string `json:"file,omitempty"`
+	// Bytes and Total are the bytes sent and to send, of the file for file
+	// events and of the job otherwise
+	Bytes int64 `json:"bytes"`
+	Total int64 `json:"total"`
+	// Speed is in bytes per second, ETA and Elapsed in seconds
+	Speed    float64 `json:"speed"`
+	ETA      float64 `json:"eta,omitempty"`
+	Elapsed  float64 `json:"elapsed"`
+	Articles int     `json:"articles"`
+	// Phase is the phase of the post and Overall its weighted progress in
+	// percent, from the upload phase on
+	Phase   string    `json:"phase,omitempty"`
+	Overall float64   `json:"overall,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+var (
+	eventMu  sync.Mutex
+	eventOut io.Writer
+)
+
+// SetEventOutput makes trackers write their progress as JSON lines to w;
+// nil stops the events. Trackers of concurrent posts share w, each event
+// written whole.
+func SetEventOutput(w io.Writer) {
+	eventMu.Lock()
+	defer eventMu.Unlock()
+	eventOut = w
+}
+
+// OpenEventOutput opens the destination of progress events: "-" for the
+// standard output, fd:N for an inherited file descriptor, or a file path,
+// appended to
+func OpenEventOutput(target string) (io.Writer, error) {
+	switch {
+	case target == "-":
+		return os.Stdout, nil
+	case strings.HasPrefix(target, "fd:"):
+		fd, err := strconv.Atoi(strings.TrimPrefix(target, "fd:"))
+		if err != nil || fd < 0 {
+			return nil, fmt.Errorf("invalid progress file descriptor %q", target)
+		}
+		return os.NewFile(uintptr(fd), target), nil
+	}
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open progress output: %w", err)
+	}
+	return file, nil
+}
+
+// emitEvent writes an event when progress events are on
+func emitEvent(event Event) {
+	eventMu.Lock()
+	defer eventMu.Unlock()
+	if eventOut == nil {
+		return
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	eventOut.Write(append(line, '\n'))
+}
+
+// eventsOn reports whether progress events are written
+func eventsOn() bool {
+	eventMu.Lock()
+	defer eventMu.Unlock()
+	return eventOut != nil
+}
@@ -0,0 +1,251 @@
+package progress
+
+import (
+	"fmt"
+	"sync"
+
+	"ypost/internal/logger"
+)
+
+// Phase is a step of a post
+type Phase string
+
+// Phases of a post, in the order they run
+const (
+	PhaseSplit  Phase = "split"
+	PhasePAR2   Phase = "par2"
+	PhaseSFV    Phase = "sfv"
+	PhaseUpload Phase = "upload"
+	PhaseVerify Phase = "verify"
+	// PhaseRoundTrip is the download of the post by SABnzbd or NZBGet
+	PhaseRoundTrip Phase = "roundtrip"
+)
+
+// phaseWeights are the shares of the phases in the overall progress of a
+// post, the phases it skips left out
+var phaseWeights = map[Phase]float64{
+	PhaseSplit:     10,
+	PhasePAR2:      25,
+	PhaseSFV:       5,
+	PhaseUpload:    55,
+	PhaseVerify:    5,
+	PhaseRoundTrip: 5,
+}
+
+// Phases is the progress of a post through its phases, weighted into an
+// overall progress
+type Phases struct {
+	mu       sync.Mutex
+	order    []Phase
+	fraction map[Phase]float64
+	current  int
+	log      logger.Interface
+	// tracker is the tracker of the upload, once it started
+	tracker *Tracker
+}
+
+// NewPhases plans the phases of a post, in their order
+func NewPhases(phases ...Phase) *Phases {
+	return &Phases{order: phases, fraction: make(map[Phase]float64), current: -1, log: logger.Discard}
+}
+
+// SetLogger sets where the start of each phase is reported
+func (p *Phases) SetLogger(log logger.Interface) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.log = log
+}
+
+// Start starts a phase, finishing the ones before it
+func (p *Phases) Start(phase Phase) {
+	p.Update(phase, 0)
+}
+
+// Finish ends a phase
+func (p *Phases) Finish(phase Phase) {
+	p.Update(phase, 1)
+}
+
+// Update sets the part of a phase done, from 0 to 1, starting it when it is
+// not yet. A phase never goes back, nor does a phase not planned count.
+func (p *Phases) Update(phase Phase, fraction float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	index := p.index(phase)
+	if index < 0 {
+		return
+	}
+	if index > p.current {
+		for _, earlier := range p.order[:index] {
+			p.fraction[earlier] = 1
+		}
+		p.current = index
+		p.log.Debug("Phase %d/%d: %s", index+1, len(p.order), phase)
+	}
+	fraction = min(max(fraction, 0), 1)
+	if fraction > p.fraction[phase] {
+		p.fraction[phase] = fraction
+	}
+}
+
+// index returns the position of a phase in the plan, -1 when not planned
+func (p *Phases) index(phase Phase) int {
+	for i, candidate := range p.order {
+		if candidate == phase {
+			return i
+		}
+	}
+	return -1
+}
+
+// Current returns the phase running, empty before the first
+func (p *Phases) Current() Phase {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.current < 0 {
+		return ""
+	}
+	return p.order[p.current]
+}
+
+// Overall returns the weighted progress of the post, from 0 to 1
+func (p *Phases) Overall() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.overall()
+}
+
+// overall computes the weighted progress
+func (p *Phases) overall() float64 {
+	var done, total float64
+	for _, phase := range p.order {
+		done += phaseWeights[phase] * p.fraction[phase]
+		total += phaseWeights[phase]
+	}
+	if total == 0 {
+		return 0
+	}
+	return done / total
+}
+
+// Label names the running phase and the overall progress, as
+// "[2/4 par2 21%]"
+func (p *Phases) Label() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.label()
+}
+
+// label renders the label
+func (p *Phases) label() string {
+	if p.current < 0 {
+		return ""
+	}
+	// Rounded down, 100% is only shown once done
+	return fmt.Sprintf("[%d/%d %s %d%%]", p.current+1, len(p.order), p.order[p.current], int(p.overall()*100))
+}
+
+// Progress returns the progress of the upload once it started, with the
+// phase and overall progress
+func (p *Phases) Progress() Progress {
+	p.mu.Lock()
+	tracker := p.tracker
+	progress := Progress{Overall: p.overall()}
+	if p.current >= 0 {
+		progress.Phase = p.order[p.current]
+	}
+	p.mu.Unlock()
+	if tracker != nil {
+		return tracker.GetProgress()
+	}
+	return progress
+}
+
+// attach makes the phases report the progress of the upload's tracker
+func (p *Phases) attach(tracker *Tracker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tracker = tracker
+}
+
+// Sinks returns a factory of the sinks of the tasks of a phase: they show
+// the phase label before their description and move the phase with the
+// steps of the tasks
+func (p *Phases) Sinks(phase Phase) SinkFactory {
+	return func(task Task) ProgressSink {
+		p.Update(phase, 0)
+		s := &phaseSink{phases: p, phase: phase, task: task, description: task.Description, label: p.Label()}
+		task.Description = s.label + " " + task.Description
+		s.sink = NewSink(task)
+		return s
+	}
+}
+
+// phaseSink moves a phase with the task of a sink
+type phaseSink struct {
+	phases      *Phases
+	phase       Phase
+	task        Task
+	sink        ProgressSink
+	mu          sync.Mutex
+	done        int64
+	description string
+	label       string
+}
+
+// Describe sets the description after the phase label
+func (s *phaseSink) Describe(description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.description = description
+	s.sink.Describe(s.label + " " + description)
+}
+
+// Add moves the task and the phase, relabelling the task as the overall
+// progress changes
+func (s *phaseSink) Add(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done += n
+	s.phases.Update(s.phase, s.fraction())
+	s.sink.Add(n)
+	if label := s.phases.Label(); label != s.label {
+		s.label = label
+		s.sink.Describe(label + " " + s.description)
+	}
+}
+
+// fraction returns the part of the phase done: the steps before the task,
+// then the task's share of its step
+func (s *phaseSink) fraction() float64 {
+	steps := max(s.task.Steps, 1)
+	step := min(max(s.task.Step, 1), steps)
+	done := 1.0
+	if s.task.Total > 0 {
+		done = min(float64(s.done)/float64(s.task.Total), 1)
+	}
+	return (float64(step-1) + done) / float64(steps)
+}
+
+// Grow raises the total of the task
+func (s *phaseSink) Grow(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.task.Total += n
+	s.sink.Grow(n)
+}
+
+// Connections passes the connections on
+func (s *phaseSink) Connections(conns []ConnectionProgress) {
+	s.sink.Connections(conns)
+}
+
+// Finish completes the task's step
+func (s *phaseSink) Finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = s.task.Total
+	s.phases.Update(s.phase, s.fraction())
+	s.sink.Finish()
+}
@@ -0,0 +1,79 @@
+package progress
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPhases(t *testing.T) {
+	phases := NewPhases(PhaseSplit, PhasePAR2, PhaseUpload)
+	if phases.Current() != "" || phases.Overall() != 0 || phases.Label() != "" {
+		t.Fatal("expected no phase before the first starts")
+	}
+
+	phases.Update(PhaseSplit, 0.5)
+	// Split weighs 10 of 90
+	if overall := phases.Overall(); math.Abs(overall-5.0/90) > 1e-9 {
+		t.Errorf("expected 5/90, got %v", overall)
+	}
+	// Starting a phase finishes those before it, and phases never go back
+	phases.Start(PhasePAR2)
+	phases.Update(PhaseSplit, 0.2)
+	phases.Update(PhaseSFV, 1)
+	if overall := phases.Overall(); math.Abs(overall-10.0/90) > 1e-9 {
+		t.Errorf("expected 10/90, got %v", overall)
+	}
+	if label := phases.Label(); label != "[2/3 par2 11%]" {
+		t.Errorf("unexpected label %q", label)
+	}
+
+	// The steps of a task share its phase
+	SetVisible(false)
+	sinks := phases.Sinks(PhasePAR2)
+	sink := sinks(Task{Description: "Encoding", Total: 10, Step: 1, Steps: 2})
+	sink.Add(5)
+	if overall := phases.Overall(); math.Abs(overall-(10+25*0.25)/90) > 1e-9 {
+		t.Errorf("expected a quarter of par2, got %v", overall)
+	}
+	sink.Finish()
+	sinks(Task{Description: "Volumes", Total: 4, Step: 2, Steps: 2}).Finish()
+	if overall := phases.Overall(); math.Abs(overall-35.0/90) > 1e-9 {
+		t.Errorf("expected par2 done, got %v", overall)
+	}
+
+	tracker := NewTracker("file.bin", 2, 100)
+	tracker.SetPhases(phases)
+	tracker.EmitProgress(1, 50)
+	progress := phases.Progress()
+	if progress.Phase != PhaseUpload || progress.BytesSent != 50 || math.Abs(progress.Overall-(35+55*0.5)/90) > 1e-9 {
+		t.Errorf("unexpected progress %+v", progress)
+	}
+	tracker.EmitProgress(2, 50)
+	tracker.EmitComplete()
+	if overall := phases.Overall(); overall != 1 {
+		t.Errorf("expected the post done, got %v", overall)
+	}
+}
+
+func TestPhaseSinkLabel(t *testing.T) {
+	var sinks []*recordingSink
+	SetSinkFactory(func(task Task) ProgressSink {
+		sink := &recordingSink{task: task}
+		sinks = append(sinks, sink)
+		return sink
+	})
+	defer SetSinkFactory(nil)
+	SetVisible(true)
+	defer SetVisible(false)
+
+	phases := NewPhases(PhasePAR2, PhaseUpload)
+	sink := phases.Sinks(PhasePAR2)(Task{Description: "Encoding", Total: 2, Step: 1, Steps: 2})
+	if description := sinks[0].task.Description; description != "[1/2 par2 0%] Encoding" {
+		t.Errorf("unexpected description %q", description)
+	}
+	sink.Add(2)
+	if description := sinks[0].description; !strings.HasPrefix(description, "[1/2 par2 15%]") {
+		t.Errorf("expected the overall progress in %q", description)
+	}
+}
@@ -0,0 +1,80 @@
+package progress
+
+import (
+	"sync"
+)
+
+// ProgressSink shows the progress of a task: the upload of a post or a step
+// of PAR2 generation. Sinks must be safe for concurrent use, PAR2 generation
+// adding from several goroutines.
+type ProgressSink interface {
+	// Describe replaces the description of the task
+	Describe(description string)
+	// Add records n more units of the task done
+	Add(n int64)
+	// Grow raises the total of the task by n units, for work added to it
+	Grow(n int64)
+	// Connections reports the state of the connections of an upload
+	Connections(conns []ConnectionProgress)
+	// Finish ends the task
+	Finish()
+}
+
+// Task is what a sink is created for
+type Task struct {
+	Description string
+	Total       int64
+	// Bytes is whether the units of the task are bytes rather than items
+	Bytes bool
+	// Transient tasks are steps of a longer one, their progress cleared once
+	// finished
+	Transient bool
+	// Step and Steps place the task among those of its operation, as step 1
+	// of 2; 0 when it is the only one
+	Step  int
+	Steps int
+}
+
+// SinkFactory creates the sink of a task
+type SinkFactory func(Task) ProgressSink
+
+var (
+	sinkMu      sync.Mutex
+	sinkFactory SinkFactory
+)
+
+// SetSinkFactory makes the trackers and PAR2 generation started afterwards
+// report to sinks created by factory; nil, the default, discards the
+// progress
+func SetSinkFactory(factory SinkFactory) {
+	sinkMu.Lock()
+	defer sinkMu.Unlock()
+	sinkFactory = factory
+}
+
+// NewSink creates the sink of a task with the factory set, one discarding
+// the progress when there is none or progress is hidden
+func NewSink(task Task) ProgressSink {
+	sinkMu.Lock()
+	factory := sinkFactory
+	sinkMu.Unlock()
+	if factory == nil || !Visible() {
+		return discard{}
+	}
+	return factory(task)
+}
+
+// Discard creates sinks ignoring the progress, for tasks running beside one
+// whose progress is shown
+func Discard(Task) ProgressSink {
+	return discard{}
+}
+
+// discard is a sink ignoring the progress
+type discard struct{}
+
+func (discard) Describe(string)                  {}
+func (discard) Add(int64)                        {}
+func (discard) Grow(int64)                       {}
+func (discard) Connections([]ConnectionProgress) {}
+func (discard) Finish()                          {}
@@ -3,12 +3,38 @@ package progress
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/schollz/progressbar/v3"
+	"ypost/internal/logger"
+	"ypost/internal/utils"
 )
 
-// Tracker handles real-time progress tracking for file transmission
+// SpeedWindow is the span the upload speed is averaged over
+const SpeedWindow = 10 * time.Second
+
+// visible is whether progress bars are drawn
+var visible atomic.Bool
+
+func init() {
+	visible.Store(true)
+}
+
+// SetVisible shows or hides the progress of the trackers and PAR2 generation
+// started afterwards, e.g. when the output is not a terminal
+func SetVisible(shown bool) {
+	visible.Store(shown)
+}
+
+// Visible reports whether progress is shown
+func Visible() bool {
+	return visible.Load()
+}
+
+// Tracker handles real-time progress tracking for file transmission. A
+// tracker follows one file, or a job of several files created with
+// NewJobTracker: its sink shows the bytes of the whole job and the file being
+// posted.
 type Tracker struct {
 	mu           sync.Mutex
 	totalChunks  int
@@ -17,34 +43,238 @@ type Tracker struct {
 	totalBytes   int64
 	bytesSent    int64
 	startTime    time.Time
-	progressBar  *progressbar.ProgressBar
+	sink         ProgressSink
+	conns        []*connection
+	// reported is when the connections were last reported to the sink
+	reported time.Time
+	log          logger.Interface
+	files        []*FileProgress
+	fileIndex    map[string]*FileProgress
+	current      *FileProgress
+	// label is the bar description before the speed and ETA
+	label string
+	// samples are the bytes sent over the last SpeedWindow, oldest first
+	samples []sample
+	now     func() time.Time
+	// postID and lastEvent are for the progress events
+	postID    string
+	lastEvent time.Time
+	// phases are the phases of the post, upload among them
+	phases *Phases
+}
+
+// sample is the bytes sent by a point in time
+type sample struct {
+	at    time.Time
+	bytes int64
+}
+
+// Progress is a snapshot of a tracker
+type Progress struct {
+	Chunk       int
+	TotalChunks int
+	BytesSent   int64
+	TotalBytes  int64
+	Elapsed     time.Duration
+	// Speed is the upload speed in bytes per second, averaged over the last
+	// SpeedWindow
+	Speed float64
+	// ETA is the time left at Speed, 0 while unknown
+	ETA time.Duration
+	// Phase is the phase of the post and Overall its weighted progress, from
+	// 0 to 1, when the tracker follows the phases of a post
+	Phase   Phase
+	Overall float64
+}
+
+// FileProgress is the progress of one file of a job
+type FileProgress struct {
+	Name string
+	// Chunks and Bytes are the articles and bytes of the file, Posted and
+	// Sent those posted so far
+	Chunks int
+	Bytes  int64
+	Posted int
+	Sent   int64
+}
+
+// Done reports whether every article of the file was posted
+func (f FileProgress) Done() bool {
+	return f.Posted >= f.Chunks
 }
 
 // NewTracker creates a new progress tracker
 func NewTracker(filename string, totalChunks int, totalBytes int64) *Tracker {
-	// Create a progress bar with appropriate settings
-	bar := progressbar.NewOptions64(
-		totalBytes,
-		progressbar.OptionSetDescription(fmt.Sprintf("Uploading %s", filename)),
-		progressbar.OptionShowBytes(true),
-		progressbar.OptionSetWidth(50),
-		progressbar.OptionThrottle(65*time.Millisecond),
-		progressbar.OptionShowCount(),
-		progressbar.OptionOnCompletion(func() {
-			fmt.Printf("\n")
-		}),
-		progressbar.OptionSpinnerType(14),
-		progressbar.OptionFullWidth(),
-		progressbar.OptionSetRenderBlankState(true),
-	)
-
-	return &Tracker{
+	t := &Tracker{
 		filename:    filename,
 		totalChunks: totalChunks,
 		totalBytes:  totalBytes,
-		startTime:   time.Now(),
-		progressBar: bar,
+		log:         logger.Discard,
+		label:       fmt.Sprintf("Uploading %s", filename),
+		now:         time.Now,
+	}
+	t.sink = newSink(filename, totalBytes)
+	t.start()
+	return t
+}
+
+// start starts the clock of the speed and ETA
+func (t *Tracker) start() {
+	t.startTime = t.now()
+	t.samples = []sample{{at: t.startTime}}
+}
+
+// NewJobTracker creates the tracker of a job posting files, whose articles
+// and bytes make its totals. Files of the same name are counted as one.
+func NewJobTracker(name string, files []FileProgress) *Tracker {
+	t := &Tracker{
+		filename:  name,
+		log:       logger.Discard,
+		fileIndex: make(map[string]*FileProgress),
+		label:     fmt.Sprintf("Uploading %s", name),
+		now:       time.Now,
 	}
+	t.start()
+	t.add(files)
+	t.sink = newSink(name, t.totalBytes)
+	return t
+}
+
+// AddFiles adds files to the job of the tracker, such as recovery files
+// created while the job is posted, raising its totals
+func (t *Tracker) AddFiles(files []FileProgress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	before := t.totalBytes
+	t.add(files)
+	t.sink.Grow(t.totalBytes - before)
+}
+
+// add counts the articles and bytes of files in the job
+func (t *Tracker) add(files []FileProgress) {
+	for _, planned := range files {
+		file, ok := t.fileIndex[planned.Name]
+		if !ok {
+			file = &FileProgress{Name: planned.Name}
+			t.fileIndex[planned.Name] = file
+			t.files = append(t.files, file)
+		}
+		file.Chunks += planned.Chunks
+		file.Bytes += planned.Bytes
+		t.totalChunks += planned.Chunks
+		t.totalBytes += planned.Bytes
+	}
+}
+
+// SetLogger sets where the tracker reports the end of the transmission
+func (t *Tracker) SetLogger(log logger.Interface) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.log = log
+}
+
+// SetPostID sets the post ID of the tracker's progress events
+func (t *Tracker) SetPostID(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.postID = id
+}
+
+// SetPhases makes the tracker follow the upload phase of a post, starting
+// it; the bar and events show the phase and overall progress
+func (t *Tracker) SetPhases(phases *Phases) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.phases = phases
+	phases.Start(PhaseUpload)
+	phases.attach(t)
+	t.sink.Describe(phases.Label() + " " + t.label)
+}
+
+// newSink creates the sink of the upload of a file or job
+func newSink(name string, totalBytes int64) ProgressSink {
+	return NewSink(Task{Description: fmt.Sprintf("Uploading %s", name), Total: totalBytes, Bytes: true})
+}
+
+// EmitFileProgress records an article of a file of the job posted. The bar
+// names the file while it is posted, and its end is logged.
+func (t *Tracker) EmitFileProgress(name string, bytes int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.currentChunk++
+	t.bytesSent += bytes
+	file, ok := t.fileIndex[name]
+	if !ok {
+		// Not planned: count it in the job only
+		t.advance(bytes)
+		return
+	}
+	file.Posted++
+	file.Sent += bytes
+	if file != t.current {
+		t.current = file
+		t.label = t.describe(file)
+	}
+	t.advance(bytes)
+	if file.Posted == file.Chunks {
+		event := t.event(EventFile, t.now())
+		event.Bytes, event.Total = file.Sent, file.Bytes
+		emitEvent(event)
+		t.log.Info("Posted %s: %d articles, %d bytes (file %d of %d)", file.Name, file.Chunks, file.Sent, t.position(file), len(t.files))
+	}
+}
+
+// Seed counts an article of a file posted by an interrupted run, so the
+// percentage and ETA cover the work left. It moves the bar without counting
+// in the speed.
+func (t *Tracker) Seed(name string, bytes int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.currentChunk++
+	t.bytesSent += bytes
+	if file, ok := t.fileIndex[name]; ok {
+		file.Posted++
+		file.Sent += bytes
+	}
+	// The speed is the difference of the samples, which the seed shifts
+	for i := range t.samples {
+		t.samples[i].bytes += bytes
+	}
+	t.sink.Add(bytes)
+	if t.phases != nil && t.totalBytes > 0 {
+		t.phases.Update(PhaseUpload, float64(t.bytesSent)/float64(t.totalBytes))
+	}
+}
+
+// describe returns the bar description while file is posted
+func (t *Tracker) describe(file *FileProgress) string {
+	if len(t.files) < 2 {
+		return fmt.Sprintf("Uploading %s", file.Name)
+	}
+	return fmt.Sprintf("Uploading %s [%d/%d] %s", t.filename, t.position(file), len(t.files), file.Name)
+}
+
+// position returns the number of a file in the job, from 1
+func (t *Tracker) position(file *FileProgress) int {
+	for i, candidate := range t.files {
+		if candidate == file {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Files returns the progress of every file of the job, in the order added
+func (t *Tracker) Files() []FileProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	files := make([]FileProgress, len(t.files))
+	for i, file := range t.files {
+		files[i] = *file
+	}
+	return files
 }
 
 // EmitProgress emits progress by incrementing the progress bar
@@ -56,7 +286,83 @@ func (t *Tracker) EmitProgress(chunkNum int, bytes int64) {
 	t.bytesSent += bytes
 	
 	// Update the progress bar with the actual bytes sent
-	t.progressBar.Add64(bytes)
+	t.advance(bytes)
+}
+
+// advance samples the bytes sent, already counted, and moves the bar with
+// the speed and ETA in its description
+func (t *Tracker) advance(bytes int64) {
+	now := t.now()
+	t.samples = append(t.samples, sample{at: now, bytes: t.bytesSent})
+	// Keep one sample older than the window as its start
+	for len(t.samples) > 2 && now.Sub(t.samples[1].at) >= SpeedWindow {
+		t.samples = t.samples[1:]
+	}
+
+	speed, eta := t.estimate(now)
+	description := t.label
+	if t.phases != nil {
+		if t.totalBytes > 0 {
+			t.phases.Update(PhaseUpload, float64(t.bytesSent)/float64(t.totalBytes))
+		}
+		description = t.phases.Label() + " " + description
+	}
+	if speed > 0 {
+		description += fmt.Sprintf(" %s/s", utils.FormatFileSize(int64(speed)))
+		if eta = eta.Round(time.Second); eta > 0 {
+			description += fmt.Sprintf(" ETA %s", eta)
+		}
+	}
+	t.sink.Describe(description)
+	t.sink.Add(bytes)
+
+	if now.Sub(t.lastEvent) >= EventInterval && eventsOn() {
+		t.lastEvent = now
+		emitEvent(t.event(EventProgress, now))
+	}
+}
+
+// event returns a progress event of the job as of now
+func (t *Tracker) event(kind string, now time.Time) Event {
+	speed, eta := t.estimate(now)
+	event := Event{
+		Event:    kind,
+		PostID:   t.postID,
+		Job:      t.filename,
+		Bytes:    t.bytesSent,
+		Total:    t.totalBytes,
+		Speed:    speed,
+		ETA:      eta.Seconds(),
+		Elapsed:  now.Sub(t.startTime).Seconds(),
+		Articles: t.currentChunk,
+		Time:     now,
+	}
+	if t.phases != nil {
+		event.Phase = string(t.phases.Current())
+		event.Overall = t.phases.Overall() * 100
+	}
+	if t.current != nil {
+		event.File = t.current.Name
+	}
+	return event
+}
+
+// estimate returns the speed over the samples and the time left at it
+func (t *Tracker) estimate(now time.Time) (float64, time.Duration) {
+	first := t.samples[0]
+	elapsed := now.Sub(first.at).Seconds()
+	if elapsed <= 0 {
+		return 0, 0
+	}
+	speed := float64(t.bytesSent-first.bytes) / elapsed
+	if speed <= 0 {
+		return 0, 0
+	}
+	remaining := t.totalBytes - t.bytesSent
+	if remaining <= 0 {
+		return speed, 0
+	}
+	return speed, time.Duration(float64(remaining) / speed * float64(time.Second))
 }
 
 // EmitComplete emits the final progress and marks completion
@@ -64,19 +370,46 @@ func (t *Tracker) EmitComplete() {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	
-	// Ensure progress bar is complete
-	t.progressBar.Finish()
+	// Ensure progress bar is complete, with the final connections
+	now := t.now()
+	t.reportConnections(now)
+	t.sink.Finish()
+	if t.phases != nil {
+		t.phases.Finish(PhaseUpload)
+	}
 	
-	duration := time.Since(t.startTime)
-	fmt.Printf("Transmission complete: %s (%d bytes in %v)\n", t.filename, t.totalBytes, duration)
+	duration := now.Sub(t.startTime)
+	event := t.event(EventComplete, now)
+	event.File, event.ETA = "", 0
+	if duration > 0 {
+		// The average speed of the whole job
+		event.Speed = float64(t.bytesSent) / duration.Seconds()
+	}
+	emitEvent(event)
+	t.log.Info("Transmission complete: %s (%d bytes in %v)", t.filename, t.totalBytes, duration)
 }
 
 // GetProgress returns current progress information
-func (t *Tracker) GetProgress() (int, int, int64, int64) {
+func (t *Tracker) GetProgress() Progress {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	
-	return t.currentChunk, t.totalChunks, t.bytesSent, t.totalBytes
+	now := t.now()
+	speed, eta := t.estimate(now)
+	progress := Progress{
+		Chunk:       t.currentChunk,
+		TotalChunks: t.totalChunks,
+		BytesSent:   t.bytesSent,
+		TotalBytes:  t.totalBytes,
+		Elapsed:     now.Sub(t.startTime),
+		Speed:       speed,
+		ETA:         eta,
+	}
+	if t.phases != nil {
+		progress.Phase = t.phases.Current()
+		progress.Overall = t.phases.Overall()
+	}
+	return progress
 }
 
 // Reset resets the tracker for a new file
@@ -85,8 +418,8 @@ func (t *Tracker) Reset(filename string, totalChunks int, totalBytes int64) {
 	defer t.mu.Unlock()
 	
 	// Finish current progress bar if it exists
-	if t.progressBar != nil {
-		t.progressBar.Finish()
+	if t.sink != nil {
+		t.sink.Finish()
 	}
 	
 	t.filename = filename
@@ -94,21 +427,11 @@ func (t *Tracker) Reset(filename string, totalChunks int, totalBytes int64) {
 	t.totalBytes = totalBytes
 	t.currentChunk = 0
 	t.bytesSent = 0
-	t.startTime = time.Now()
+	t.start()
+	t.files, t.fileIndex, t.current = nil, nil, nil
+	t.label = fmt.Sprintf("Uploading %s", filename)
 	
 	// Create new progress bar for the new file
-	t.progressBar = progressbar.NewOptions64(
-		totalBytes,
-		progressbar.OptionSetDescription(fmt.Sprintf("Uploading %s", filename)),
-		progressbar.OptionShowBytes(true),
-		progressbar.OptionSetWidth(50),
-		progressbar.OptionThrottle(65*time.Millisecond),
-		progressbar.OptionShowCount(),
-		progressbar.OptionOnCompletion(func() {
-			fmt.Printf("\n")
-		}),
-		progressbar.OptionSpinnerType(14),
-		progressbar.OptionFullWidth(),
-		progressbar.OptionSetRenderBlankState(true),
-	)
+	t.conns = nil
+	t.sink = newSink(filename, totalBytes)
 }
\ No newline at end of file
@@ -0,0 +1,263 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobTracker(t *testing.T) {
+	SetVisible(false)
+	tracker := NewJobTracker("movie", []FileProgress{
+		{Name: "movie.mkv", Chunks: 2, Bytes: 200},
+		{Name: "movie.par2", Chunks: 1, Bytes: 10},
+		{Name: "movie.mkv", Chunks: 1, Bytes: 50},
+	})
+
+	tracker.EmitFileProgress("movie.mkv", 100)
+	tracker.EmitFileProgress("movie.par2", 10)
+	tracker.EmitFileProgress("unplanned.sfv", 5)
+
+	progress := tracker.GetProgress()
+	if progress.Chunk != 3 || progress.TotalChunks != 4 || progress.BytesSent != 115 || progress.TotalBytes != 260 {
+		t.Errorf("unexpected progress %+v", progress)
+	}
+	files := tracker.Files()
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, got %+v", files)
+	}
+	if files[0].Name != "movie.mkv" || files[0].Chunks != 3 || files[0].Bytes != 250 || files[0].Sent != 100 || files[0].Done() {
+		t.Errorf("unexpected progress of the main file %+v", files[0])
+	}
+	if !files[1].Done() || files[1].Sent != 10 {
+		t.Errorf("expected the PAR2 file done, got %+v", files[1])
+	}
+	tracker.EmitComplete()
+}
+
+func TestSpeedAndETA(t *testing.T) {
+	SetVisible(false)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker("file.bin", 100, 10000)
+	tracker.now = func() time.Time { return now }
+	tracker.start()
+
+	// 100 bytes per second for 20 seconds, then 1000 per second
+	for second := 1; second <= 20; second++ {
+		now = now.Add(time.Second)
+		tracker.EmitProgress(second, 100)
+	}
+	progress := tracker.GetProgress()
+	if progress.Speed != 100 || progress.ETA != 80*time.Second || progress.Elapsed != 20*time.Second {
+		t.Errorf("expected 100 B/s and 80s left, got %+v", progress)
+	}
+	for second := 21; second <= 25; second++ {
+		now = now.Add(time.Second)
+		tracker.EmitProgress(second, 1000)
+	}
+	// The window spans 5 seconds at each speed
+	progress = tracker.GetProgress()
+	if progress.Speed != 550 || progress.ETA.Round(time.Second) != 5*time.Second {
+		t.Errorf("expected 550 B/s and 5s left, got %+v", progress)
+	}
+
+	// A stalled upload slows down as time passes
+	now = now.Add(10 * time.Second)
+	if progress := tracker.GetProgress(); progress.Speed >= 550 {
+		t.Errorf("expected the speed to drop while stalled, got %v", progress.Speed)
+	}
+}
+
+func TestSeed(t *testing.T) {
+	SetVisible(false)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tracker := NewJobTracker("movie", []FileProgress{{Name: "movie.mkv", Chunks: 10, Bytes: 1000}})
+	tracker.now = func() time.Time { return now }
+	tracker.start()
+
+	// 6 articles posted before the restart, then 1 per second
+	for i := 0; i < 6; i++ {
+		tracker.Seed("movie.mkv", 100)
+	}
+	for second := 1; second <= 2; second++ {
+		now = now.Add(time.Second)
+		tracker.EmitFileProgress("movie.mkv", 100)
+	}
+	progress := tracker.GetProgress()
+	if progress.Chunk != 8 || progress.BytesSent != 800 {
+		t.Errorf("expected the seeded articles counted, got %+v", progress)
+	}
+	if progress.Speed != 100 || progress.ETA != 2*time.Second {
+		t.Errorf("expected 100 B/s and 2s left, got %+v", progress)
+	}
+	if files := tracker.Files(); files[0].Posted != 8 || files[0].Sent != 800 {
+		t.Errorf("unexpected progress of the file %+v", files[0])
+	}
+}
+
+func TestAddFiles(t *testing.T) {
+	var sink *recordingSink
+	SetSinkFactory(func(task Task) ProgressSink {
+		sink = &recordingSink{task: task}
+		return sink
+	})
+	defer SetSinkFactory(nil)
+	SetVisible(true)
+	defer SetVisible(false)
+
+	tracker := NewJobTracker("movie", []FileProgress{{Name: "movie.mkv", Chunks: 2, Bytes: 200}})
+	tracker.EmitFileProgress("movie.mkv", 100)
+	tracker.AddFiles([]FileProgress{{Name: "movie.par2", Chunks: 1, Bytes: 10}, {Name: "movie.vol00+01.par2", Chunks: 1, Bytes: 40}})
+
+	progress := tracker.GetProgress()
+	if progress.TotalChunks != 4 || progress.TotalBytes != 250 || progress.BytesSent != 100 {
+		t.Errorf("unexpected progress %+v", progress)
+	}
+	if sink.task.Total != 250 {
+		t.Errorf("expected the bar to grow to 250 bytes, got %d", sink.task.Total)
+	}
+	if files := tracker.Files(); len(files) != 3 || files[2].Name != "movie.vol00+01.par2" {
+		t.Errorf("unexpected files %+v", files)
+	}
+}
+
+func TestProgressEvents(t *testing.T) {
+	SetVisible(false)
+	var out bytes.Buffer
+	SetEventOutput(&out)
+	defer SetEventOutput(nil)
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tracker := NewJobTracker("job", []FileProgress{
+		{Name: "a.bin", Chunks: 2, Bytes: 200},
+		{Name: "b.bin", Chunks: 1, Bytes: 100},
+	})
+	tracker.now = func() time.Time { return now }
+	tracker.start()
+	tracker.SetPostID("0123456789abcdef")
+
+	// Progress is throttled to one event per EventInterval
+	now = now.Add(time.Second)
+	tracker.EmitFileProgress("a.bin", 100)
+	now = now.Add(EventInterval / 2)
+	tracker.EmitFileProgress("a.bin", 100)
+	now = now.Add(time.Second)
+	tracker.EmitFileProgress("b.bin", 100)
+	tracker.EmitComplete()
+
+	var kinds []string
+	var last Event
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("invalid event %q: %v", line, err)
+		}
+		if event.PostID != "0123456789abcdef" || event.Job != "job" {
+			t.Errorf("unexpected event %+v", event)
+		}
+		kinds = append(kinds, event.Event)
+		last = event
+	}
+	expected := []string{EventProgress, EventFile, EventProgress, EventFile, EventComplete}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Fatalf("expected %v, got %v", expected, kinds)
+	}
+	if last.Bytes != 300 || last.Total != 300 || last.Articles != 3 || last.Speed <= 0 {
+		t.Errorf("unexpected completion %+v", last)
+	}
+}
+
+func TestConnections(t *testing.T) {
+	SetVisible(false)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker("file.bin", 10, 1000)
+	tracker.now = func() time.Time { return now }
+	tracker.SetConnections(2)
+
+	tracker.BeginArticle(0, "file.bin (1/10)")
+	tracker.BeginArticle(1, "file.bin (2/10)")
+	now = now.Add(2 * time.Second)
+	tracker.EndArticle(0, 100)
+	tracker.BeginArticle(0, "file.bin (3/10)")
+	tracker.EndArticle(5, 100)
+	now = now.Add(8 * time.Second)
+
+	conns := tracker.Connections()
+	if len(conns) != 2 {
+		t.Fatalf("expected 2 connections, got %d", len(conns))
+	}
+	if conns[0].Posted != 1 || conns[0].Sent != 100 || conns[0].Speed != 10 || conns[0].Article != "file.bin (3/10)" {
+		t.Errorf("unexpected connection %+v", conns[0])
+	}
+	// Connection 1 has been posting its article for 10 seconds
+	if conns[1].Posted != 0 || conns[1].Speed != 0 || conns[1].For != 10*time.Second {
+		t.Errorf("unexpected connection %+v", conns[1])
+	}
+
+	for conn, expected := range map[int]string{0: "  #1       10B/s  file.bin (3/10) 8s", 1: "  #2        0B/s  file.bin (2/10) 10s (stalled)"} {
+		if line := FormatConnection(conns[conn]); line != expected {
+			t.Errorf("expected %q, got %q", expected, line)
+		}
+	}
+	if line := FormatConnection(ConnectionProgress{ID: 2}); line != "  #3        0B/s  idle" {
+		t.Errorf("unexpected idle line %q", line)
+	}
+}
+
+// recordingSink records the progress it is given
+type recordingSink struct {
+	task        Task
+	description string
+	done        int64
+	conns       []ConnectionProgress
+	finished    bool
+}
+
+func (s *recordingSink) Describe(description string)            { s.description = description }
+func (s *recordingSink) Add(n int64)                            { s.done += n }
+func (s *recordingSink) Grow(n int64)                           { s.task.Total += n }
+func (s *recordingSink) Connections(conns []ConnectionProgress) { s.conns = conns }
+func (s *recordingSink) Finish()                                { s.finished = true }
+
+func TestSink(t *testing.T) {
+	var sinks []*recordingSink
+	SetSinkFactory(func(task Task) ProgressSink {
+		sink := &recordingSink{task: task}
+		sinks = append(sinks, sink)
+		return sink
+	})
+	defer SetSinkFactory(nil)
+	SetVisible(true)
+	defer SetVisible(false)
+
+	tracker := NewJobTracker("job", []FileProgress{{Name: "a.bin", Chunks: 2, Bytes: 200}})
+	tracker.SetConnections(1)
+	tracker.BeginArticle(0, "a.bin (1/2)")
+	tracker.EndArticle(0, 100)
+	tracker.EmitFileProgress("a.bin", 100)
+	tracker.EmitFileProgress("a.bin", 100)
+	tracker.EmitComplete()
+
+	if len(sinks) != 1 {
+		t.Fatalf("expected 1 sink, got %d", len(sinks))
+	}
+	sink := sinks[0]
+	if sink.task != (Task{Description: "Uploading job", Total: 200, Bytes: true}) {
+		t.Errorf("unexpected task %+v", sink.task)
+	}
+	if sink.done != 200 || !sink.finished || !strings.HasPrefix(sink.description, "Uploading a.bin") {
+		t.Errorf("unexpected progress %+v", sink)
+	}
+	if len(sink.conns) != 1 || sink.conns[0].Posted != 1 {
+		t.Errorf("unexpected connections %+v", sink.conns)
+	}
+
+	// Hidden progress goes nowhere
+	SetVisible(false)
+	if _, ok := NewSink(Task{}).(discard); !ok {
+		t.Error("expected a discarding sink while hidden")
+	}
+}
@@ -0,0 +1,290 @@
+package prune
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ypost/internal/journal"
+)
+
+// Kinds of pruned entries
+const (
+	// KindOutput is the timestamped output folder of a post
+	KindOutput = "output"
+	// KindInterrupted is the output folder of a post that can still be resumed
+	KindInterrupted = "interrupted"
+	// KindLog is a date-named log file
+	KindLog = "log"
+	// KindTemp is a temporary file left by an interrupted write
+	KindTemp = "temp"
+)
+
+// folderLayout is the timestamp prefixing output folder names, as written by
+// utils.GenerateTimestampedFolderName
+const folderLayout = "2006-01-02_15-04"
+
+// tempPrefixes start the names of the temporary files of NZB writes and
+// downloads
+var tempPrefixes = []string{".nzb-", ".ypost-download-"}
+
+// Policy holds the age past which each kind of entry is removed; a zero age
+// keeps that kind forever
+type Policy struct {
+	Outputs time.Duration
+	// NZBs is the age past which the NZBs and name mappings of pruned output
+	// folders go too; until then they are kept in the folder
+	NZBs time.Duration
+	Logs time.Duration
+	Temp time.Duration
+	// Interrupted also prunes the folders of posts that can still be resumed
+	Interrupted bool
+}
+
+// Dirs are the directories pruned; empty ones are skipped
+type Dirs struct {
+	Output string
+	NZB    string
+	Log    string
+}
+
+// Entry is a file or folder to remove
+type Entry struct {
+	Path string    `json:"path"`
+	Kind string    `json:"kind"`
+	Time time.Time `json:"time"`
+	// Size is the number of bytes freed
+	Size int64 `json:"size"`
+	// Kept lists the files left in a folder, which is then not removed
+	Kept []string `json:"kept,omitempty"`
+}
+
+// ParseAge parses an age such as "30d", "2w" or any time.ParseDuration value
+func ParseAge(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if number, ok := strings.CutSuffix(value, suffix); ok {
+			count, err := strconv.Atoi(number)
+			if err != nil || count < 0 {
+				return 0, fmt.Errorf("invalid age %q", value)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	age, err := time.ParseDuration(value)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age %q (expected e.g. 30d, 2w or 12h)", value)
+	}
+	return age, nil
+}
+
+// Plan lists what policy removes from dirs at now, oldest first, without
+// removing anything
+func Plan(dirs Dirs, policy Policy, now time.Time) ([]Entry, error) {
+	var entries []Entry
+	expired := func(age time.Duration, t time.Time) bool {
+		return age > 0 && now.Sub(t) > age
+	}
+
+	if dirs.Output != "" {
+		folders, err := readDir(dirs.Output)
+		if err != nil {
+			return nil, err
+		}
+		for _, folder := range folders {
+			if !folder.IsDir() || len(folder.Name()) <= len(folderLayout) {
+				continue
+			}
+			created, err := time.ParseInLocation(folderLayout, folder.Name()[:len(folderLayout)], now.Location())
+			if err != nil {
+				continue
+			}
+			path := filepath.Join(dirs.Output, folder.Name())
+			if !expired(policy.Outputs, created) {
+				temp, err := tempFiles(path, policy.Temp, now)
+				if err != nil {
+					return nil, err
+				}
+				entries = append(entries, temp...)
+				continue
+			}
+
+			entry := Entry{Path: path, Kind: KindOutput, Time: created}
+			if _, err := os.Stat(journal.Path(path)); err == nil {
+				if !policy.Interrupted {
+					continue
+				}
+				entry.Kind = KindInterrupted
+			}
+			keepNZBs := !expired(policy.NZBs, created)
+			if entry.Size, entry.Kept, err = folderUsage(path, keepNZBs); err != nil {
+				return nil, err
+			}
+			// A folder pruned before holds only its NZBs
+			if len(entry.Kept) > 0 && !holdsMore(path, entry.Kept) {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+		temp, err := tempFiles(dirs.Output, policy.Temp, now)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, temp...)
+	}
+
+	if dirs.NZB != "" && filepath.Clean(dirs.NZB) != filepath.Clean(dirs.Output) {
+		temp, err := tempFiles(dirs.NZB, policy.Temp, now)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, temp...)
+	}
+
+	if dirs.Log != "" {
+		logs, err := readDir(dirs.Log)
+		if err != nil {
+			return nil, err
+		}
+		for _, log := range logs {
+			day, ok := strings.CutPrefix(log.Name(), "ypost-")
+			if !ok || !log.Type().IsRegular() {
+				continue
+			}
+			date, err := time.ParseInLocation("2006-01-02.log", day, now.Location())
+			// A log is written to until the end of its day
+			if err != nil || !expired(policy.Logs, date.AddDate(0, 0, 1)) {
+				continue
+			}
+			info, err := log.Info()
+			if err != nil {
+				continue
+			}
+			entries = append(entries, Entry{Path: filepath.Join(dirs.Log, log.Name()), Kind: KindLog, Time: date, Size: info.Size()})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	return entries, nil
+}
+
+// Remove removes the entries of a plan. A folder with kept files only loses
+// its other files.
+func Remove(entries []Entry) error {
+	for _, entry := range entries {
+		if len(entry.Kept) == 0 {
+			if err := os.RemoveAll(entry.Path); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", entry.Path, err)
+			}
+			continue
+		}
+
+		kept := make(map[string]bool)
+		for _, name := range entry.Kept {
+			kept[name] = true
+		}
+		files, err := os.ReadDir(entry.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", entry.Path, err)
+		}
+		for _, file := range files {
+			if kept[file.Name()] {
+				continue
+			}
+			if err := os.RemoveAll(filepath.Join(entry.Path, file.Name())); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", filepath.Join(entry.Path, file.Name()), err)
+			}
+		}
+	}
+	return nil
+}
+
+// readDir lists dir, a missing directory having no entries
+func readDir(dir string) ([]fs.DirEntry, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	return entries, nil
+}
+
+// tempFiles lists the temporary files of dir older than age
+func tempFiles(dir string, age time.Duration, now time.Time) ([]Entry, error) {
+	if age <= 0 {
+		return nil, nil
+	}
+	files, err := readDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, file := range files {
+		if !file.Type().IsRegular() || !isTemp(file.Name()) {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil || now.Sub(info.ModTime()) <= age {
+			continue
+		}
+		entries = append(entries, Entry{Path: filepath.Join(dir, file.Name()), Kind: KindTemp, Time: info.ModTime(), Size: info.Size()})
+	}
+	return entries, nil
+}
+
+// isTemp reports whether name is a temporary file
+func isTemp(name string) bool {
+	for _, prefix := range tempPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNZB reports whether name is an NZB or the name mapping next to one
+func isNZB(name string) bool {
+	return strings.HasSuffix(name, ".nzb") || strings.HasSuffix(name, ".mapping.json")
+}
+
+// holdsMore reports whether folder holds anything besides kept
+func holdsMore(folder string, kept []string) bool {
+	files, err := os.ReadDir(folder)
+	return err != nil || len(files) > len(kept)
+}
+
+// folderUsage returns the bytes pruning a folder frees and, with keepNZBs,
+// the NZBs left in it
+func folderUsage(path string, keepNZBs bool) (int64, []string, error) {
+	var size int64
+	var kept []string
+	err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		if keepNZBs && filepath.Dir(file) == path && isNZB(entry.Name()) {
+			kept = append(kept, entry.Name())
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return size, kept, nil
+}
@@ -0,0 +1,118 @@
+package prune
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ypost/internal/journal"
+)
+
+func TestParseAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":    0,
+		"30d": 30 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+	}
+	for value, expected := range tests {
+		age, err := ParseAge(value)
+		if err != nil || age != expected {
+			t.Errorf("%q: expected %s, got %s (%v)", value, expected, age, err)
+		}
+	}
+	for _, value := range []string{"30", "d", "-1d", "1.5d", "soon"} {
+		if _, err := ParseAge(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path string, size int, modTime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlanAndRemove(t *testing.T) {
+	now := time.Date(2024, 6, 30, 12, 0, 0, 0, time.Local)
+	root := t.TempDir()
+	dirs := Dirs{Output: filepath.Join(root, "output"), NZB: filepath.Join(root, "output", "nzb"), Log: filepath.Join(root, "logs")}
+
+	old := filepath.Join(dirs.Output, "2024-05-01_10-00-old")
+	writeFile(t, filepath.Join(old, "old.nzb"), 10, now)
+	writeFile(t, filepath.Join(old, "old.mapping.json"), 10, now)
+	writeFile(t, filepath.Join(old, "old.par2"), 100, now)
+	writeFile(t, filepath.Join(old, "old.sfv"), 20, now)
+	interrupted := filepath.Join(dirs.Output, "2024-05-02_10-00-interrupted")
+	writeFile(t, journal.Path(interrupted), 5, now)
+	recent := filepath.Join(dirs.Output, "2024-06-29_10-00-recent")
+	writeFile(t, filepath.Join(recent, "recent.nzb"), 10, now)
+	writeFile(t, filepath.Join(recent, ".nzb-123"), 7, now.AddDate(0, 0, -2))
+	writeFile(t, filepath.Join(dirs.Output, "notes", "keep.txt"), 1, now.AddDate(-1, 0, 0))
+	writeFile(t, filepath.Join(dirs.NZB, ".nzb-456"), 3, now.Add(-time.Hour))
+	writeFile(t, filepath.Join(dirs.Log, "ypost-2024-05-01.log"), 50, now)
+	writeFile(t, filepath.Join(dirs.Log, "ypost-2024-06-30.log"), 50, now)
+	writeFile(t, filepath.Join(dirs.Log, "custom.log"), 50, now.AddDate(-1, 0, 0))
+
+	policy := Policy{Outputs: 30 * 24 * time.Hour, Logs: 30 * 24 * time.Hour, Temp: 24 * time.Hour}
+	entries, err := Plan(dirs, policy, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Entry{
+		{Path: filepath.Join(dirs.Log, "ypost-2024-05-01.log"), Kind: KindLog, Size: 50},
+		{Path: old, Kind: KindOutput, Size: 120, Kept: []string{"old.mapping.json", "old.nzb"}},
+		{Path: filepath.Join(recent, ".nzb-123"), Kind: KindTemp, Size: 7},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %+v", len(expected), entries)
+	}
+	for i, entry := range entries {
+		if entry.Path != expected[i].Path || entry.Kind != expected[i].Kind || entry.Size != expected[i].Size ||
+			len(entry.Kept) != len(expected[i].Kept) {
+			t.Errorf("entry %d: expected %+v, got %+v", i, expected[i], entry)
+		}
+	}
+
+	if err := Remove(entries); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := os.ReadDir(old)
+	if len(files) != 2 {
+		t.Errorf("expected the NZB and mapping to be kept, got %d files", len(files))
+	}
+	for _, path := range []string{interrupted, filepath.Join(recent, "recent.nzb"), filepath.Join(dirs.Log, "custom.log")} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %s to be kept: %v", path, err)
+		}
+	}
+
+	// A pruned folder is not listed again until its NZBs expire too
+	if entries, err = Plan(dirs, policy, now); err != nil || len(entries) != 0 {
+		t.Errorf("expected nothing left to prune, got %+v (%v)", entries, err)
+	}
+	policy.NZBs = policy.Outputs
+	policy.Interrupted = true
+	entries, err = Plan(dirs, policy, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Path != old || entries[1].Kind != KindInterrupted {
+		t.Fatalf("expected the old and interrupted folders, got %+v", entries)
+	}
+	if err := Remove(entries); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed", old)
+	}
+}
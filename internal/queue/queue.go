@@ -0,0 +1,514 @@
+package queue
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// State is the state of a job
+type State string
+
+// Job states. A job moves from pending to running when claimed, then to done
+// or failed; retrying a failed job makes it pending again. A pending or
+// running job cancelled stays cancelled.
+const (
+	StatePending   State = "pending"
+	StateRunning   State = "running"
+	StateDone      State = "done"
+	StateFailed    State = "failed"
+	StateCancelled State = "cancelled"
+)
+
+var (
+	jobsBucket     = []byte("jobs")
+	segmentsBucket = []byte("segments")
+)
+
+// ErrNotFound is the error of a job ID the queue does not hold
+var ErrNotFound = errors.New("not found")
+
+// openTimeout bounds the wait for another process holding the store
+const openTimeout = 10 * time.Second
+
+// Posted articles are recorded in memory and written with the next
+// transaction, or once segmentBatch of them or segmentDelay have gone by,
+// rather than opening and syncing the database for each
+const (
+	segmentBatch = 100
+	segmentDelay = 2 * time.Second
+)
+
+// errLocked is the error of a run lock held by another process
+var errLocked = errors.New("locked")
+
+// Job is a queued posting of a file or directory
+type Job struct {
+	ID        uint64    `json:"id"`
+	Path      string    `json:"path"`
+	Group     string    `json:"group,omitempty"`
+	State     State     `json:"state"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error,omitempty"`
+	NZBPath   string    `json:"nzb_path,omitempty"`
+	PostID    string    `json:"post_id,omitempty"` // Post of the last attempt
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Segment is a posted article of a job
+type Segment struct {
+	FileName  string    `json:"file_name"`
+	Number    int       `json:"number"`
+	MessageID string    `json:"message_id"`
+	Bytes     int64     `json:"bytes"`
+	PostedAt  time.Time `json:"posted_at"`
+}
+
+// Store is a durable job queue in a bolt database. The database is opened for
+// each operation only, so other processes can add jobs while one runs them.
+type Store struct {
+	path string
+	mu   sync.Mutex
+
+	pendingMu    sync.Mutex
+	pending      []pendingSegment
+	pendingSince time.Time
+}
+
+// pendingSegment is a posted article not written yet
+type pendingSegment struct {
+	id      uint64
+	segment Segment
+}
+
+// RunLock is the lock of the process running the jobs of a store
+type RunLock struct {
+	file *os.File
+}
+
+// Open creates the store at path, creating the database if needed
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create queue directory: %w", err)
+	}
+
+	s := &Store{path: path}
+	err := s.update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(jobsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(segmentsBucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open queue: %w", err)
+	}
+	return s, nil
+}
+
+// Path returns the database file of the store
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Add queues a path for posting, to group or the configured group when empty
+func (s *Store) Add(path string, group string) (*Job, error) {
+	var job *Job
+	err := s.update(func(tx *bolt.Tx) error {
+		jobs := tx.Bucket(jobsBucket)
+		id, err := jobs.NextSequence()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		job = &Job{ID: id, Path: path, Group: group, State: StatePending, CreatedAt: now, UpdatedAt: now}
+		return putJob(jobs, job)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add job: %w", err)
+	}
+	return job, nil
+}
+
+// Get returns a job by ID
+func (s *Store) Get(id uint64) (*Job, error) {
+	var job *Job
+	err := s.view(func(tx *bolt.Tx) error {
+		var err error
+		job, err = getJob(tx.Bucket(jobsBucket), id)
+		return err
+	})
+	return job, err
+}
+
+// List returns all jobs in the order they were added
+func (s *Store) List() ([]*Job, error) {
+	var list []*Job
+	err := s.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobsBucket).ForEach(func(_, value []byte) error {
+			var job Job
+			if err := json.Unmarshal(value, &job); err != nil {
+				return err
+			}
+			list = append(list, &job)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	return list, nil
+}
+
+// Claim marks the oldest pending job as running and returns it, or nil when
+// no job is pending
+func (s *Store) Claim() (*Job, error) {
+	var claimed *Job
+	err := s.update(func(tx *bolt.Tx) error {
+		jobs := tx.Bucket(jobsBucket)
+		cursor := jobs.Cursor()
+		for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+			var job Job
+			if err := json.Unmarshal(value, &job); err != nil {
+				return err
+			}
+			if job.State != StatePending {
+				continue
+			}
+			job.State = StateRunning
+			job.Attempts++
+			job.Error = ""
+			job.UpdatedAt = time.Now()
+			claimed = &job
+			return putJob(jobs, &job)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim job: %w", err)
+	}
+	return claimed, nil
+}
+
+// Started records the post ID of a running job's attempt
+func (s *Store) Started(id uint64, postID string) error {
+	return s.setState(id, func(job *Job) {
+		job.PostID = postID
+	})
+}
+
+// Complete marks a job as done with the NZB it produced
+func (s *Store) Complete(id uint64, nzbPath string) error {
+	return s.setState(id, func(job *Job) {
+		job.State = StateDone
+		job.NZBPath = nzbPath
+		job.Error = ""
+	})
+}
+
+// Fail marks a job as failed with the error that stopped it
+func (s *Store) Fail(id uint64, cause error) error {
+	return s.setState(id, func(job *Job) {
+		job.State = StateFailed
+		job.Error = cause.Error()
+	})
+}
+
+// Cancel marks a pending or running job as cancelled and returns it; the
+// process running a running job is to stop it. A finished job is an error.
+func (s *Store) Cancel(id uint64) (*Job, error) {
+	var cancelled *Job
+	err := s.update(func(tx *bolt.Tx) error {
+		jobs := tx.Bucket(jobsBucket)
+		job, err := getJob(jobs, id)
+		if err != nil {
+			return err
+		}
+		if job.State != StatePending && job.State != StateRunning {
+			return fmt.Errorf("job %d is %s", id, job.State)
+		}
+		job.State = StateCancelled
+		job.UpdatedAt = time.Now()
+		cancelled = job
+		return putJob(jobs, job)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel job %d: %w", id, err)
+	}
+	return cancelled, nil
+}
+
+// Retry makes failed jobs pending again: the given ones, or all failed jobs
+// when none are given. It returns the number of jobs requeued.
+func (s *Store) Retry(ids ...uint64) (int, error) {
+	return s.requeue(StateFailed, ids)
+}
+
+// Lock takes the run lock of the store, a file beside the database, failing
+// when another process runs its jobs. It is held until Unlock, or the
+// process exits.
+func (s *Store) Lock() (*RunLock, error) {
+	file, err := os.OpenFile(s.path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open queue lock: %w", err)
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		if errors.Is(err, errLocked) {
+			return nil, fmt.Errorf("queue %s is run by another process", s.path)
+		}
+		return nil, fmt.Errorf("failed to lock queue: %w", err)
+	}
+	return &RunLock{file: file}, nil
+}
+
+// Unlock releases the run lock
+func (l *RunLock) Unlock() error {
+	defer l.file.Close()
+	return unlockFile(l.file)
+}
+
+// Recover makes jobs left running by an interrupted run pending again. It is
+// only for the holder of the run lock, as other jobs running are another
+// process's.
+func (s *Store) Recover() (int, error) {
+	return s.requeue(StateRunning, nil)
+}
+
+// RecordSegment records a posted article of a job. The article is written
+// with the next transaction, once enough have been recorded, or by Flush.
+func (s *Store) RecordSegment(id uint64, segment Segment) error {
+	s.pendingMu.Lock()
+	if len(s.pending) == 0 {
+		s.pendingSince = time.Now()
+	}
+	s.pending = append(s.pending, pendingSegment{id: id, segment: segment})
+	due := len(s.pending) >= segmentBatch || time.Since(s.pendingSince) >= segmentDelay
+	s.pendingMu.Unlock()
+
+	if !due {
+		return nil
+	}
+	if err := s.Flush(); err != nil {
+		return fmt.Errorf("failed to record segment: %w", err)
+	}
+	return nil
+}
+
+// Flush writes the recorded articles not written yet
+func (s *Store) Flush() error {
+	s.pendingMu.Lock()
+	empty := len(s.pending) == 0
+	s.pendingMu.Unlock()
+	if empty {
+		return nil
+	}
+	return s.update(func(*bolt.Tx) error { return nil })
+}
+
+// Segments returns the recorded articles of a job, by file name and number
+func (s *Store) Segments(id uint64) ([]Segment, error) {
+	if err := s.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to read segments: %w", err)
+	}
+	var segments []Segment
+	err := s.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(segmentsBucket).Bucket(itob(id))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(_, value []byte) error {
+			var segment Segment
+			if err := json.Unmarshal(value, &segment); err != nil {
+				return err
+			}
+			segments = append(segments, segment)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read segments: %w", err)
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		if segments[i].FileName != segments[j].FileName {
+			return segments[i].FileName < segments[j].FileName
+		}
+		return segments[i].Number < segments[j].Number
+	})
+	return segments, nil
+}
+
+// setState updates a job in place
+func (s *Store) setState(id uint64, change func(*Job)) error {
+	err := s.update(func(tx *bolt.Tx) error {
+		jobs := tx.Bucket(jobsBucket)
+		job, err := getJob(jobs, id)
+		if err != nil {
+			return err
+		}
+		change(job)
+		job.UpdatedAt = time.Now()
+		return putJob(jobs, job)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update job %d: %w", id, err)
+	}
+	return nil
+}
+
+// requeue makes jobs in state from pending; ids limits it to those jobs, each
+// of which must be in state from
+func (s *Store) requeue(from State, ids []uint64) (int, error) {
+	count := 0
+	err := s.update(func(tx *bolt.Tx) error {
+		jobs := tx.Bucket(jobsBucket)
+		var selected []*Job
+		if len(ids) > 0 {
+			for _, id := range ids {
+				job, err := getJob(jobs, id)
+				if err != nil {
+					return err
+				}
+				if job.State != from {
+					return fmt.Errorf("job %d is %s, not %s", id, job.State, from)
+				}
+				selected = append(selected, job)
+			}
+		} else {
+			err := jobs.ForEach(func(_, value []byte) error {
+				var job Job
+				if err := json.Unmarshal(value, &job); err != nil {
+					return err
+				}
+				if job.State == from {
+					selected = append(selected, &job)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, job := range selected {
+			job.State = StatePending
+			job.UpdatedAt = time.Now()
+			if err := putJob(jobs, job); err != nil {
+				return err
+			}
+		}
+		count = len(selected)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue jobs: %w", err)
+	}
+	return count, nil
+}
+
+// update runs fn in a read-write transaction, which also writes the recorded
+// articles; they are kept for the next one when it fails
+func (s *Store) update(fn func(*bolt.Tx) error) error {
+	s.pendingMu.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.pendingMu.Unlock()
+
+	err := s.with(false, func(db *bolt.DB) error {
+		return db.Update(func(tx *bolt.Tx) error {
+			if err := putSegments(tx, pending); err != nil {
+				return err
+			}
+			return fn(tx)
+		})
+	})
+	if err != nil && len(pending) > 0 {
+		s.pendingMu.Lock()
+		s.pending = append(pending, s.pending...)
+		s.pendingMu.Unlock()
+	}
+	return err
+}
+
+// view runs fn in a read-only transaction
+func (s *Store) view(fn func(*bolt.Tx) error) error {
+	return s.with(true, func(db *bolt.DB) error { return db.View(fn) })
+}
+
+// with opens the database for the duration of fn
+func (s *Store) with(readOnly bool, fn func(*bolt.DB) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	db, err := bolt.Open(s.path, 0600, &bolt.Options{Timeout: openTimeout, ReadOnly: readOnly})
+	if err != nil {
+		if err == bolt.ErrTimeout {
+			return fmt.Errorf("queue %s is locked by another process", s.path)
+		}
+		return err
+	}
+	defer db.Close()
+	return fn(db)
+}
+
+// getJob reads a job from the jobs bucket
+func getJob(jobs *bolt.Bucket, id uint64) (*Job, error) {
+	value := jobs.Get(itob(id))
+	if value == nil {
+		return nil, fmt.Errorf("job %d %w", id, ErrNotFound)
+	}
+	var job Job
+	if err := json.Unmarshal(value, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// putJob writes a job to the jobs bucket
+func putJob(jobs *bolt.Bucket, job *Job) error {
+	value, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return jobs.Put(itob(job.ID), value)
+}
+
+// putSegments writes recorded articles to the segments bucket
+func putSegments(tx *bolt.Tx, pending []pendingSegment) error {
+	for _, p := range pending {
+		value, err := json.Marshal(p.segment)
+		if err != nil {
+			return err
+		}
+		bucket, err := tx.Bucket(segmentsBucket).CreateBucketIfNotExists(itob(p.id))
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(segmentKey(p.segment.FileName, p.segment.Number), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// itob encodes an ID as a big-endian key, so keys sort in ID order
+func itob(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+// segmentKey orders the segments of a job by file name and number
+func segmentKey(fileName string, number int) []byte {
+	key := append([]byte(fileName), 0)
+	return binary.BigEndian.AppendUint32(key, uint32(number))
+}
@@ -0,0 +1,229 @@
+package queue
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestJobLifecycle(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "queue.db")
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := store.Add("/data/first.iso", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.Add("/data/second", "alt.binaries.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID == second.ID || first.State != StatePending {
+		t.Fatalf("unexpected jobs %+v %+v", first, second)
+	}
+
+	claimed, err := store.Claim()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed == nil || claimed.ID != first.ID || claimed.State != StateRunning || claimed.Attempts != 1 {
+		t.Fatalf("expected the oldest job to be claimed, got %+v", claimed)
+	}
+	if err := store.Complete(first.ID, "/out/first.nzb"); err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err = store.Claim()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Fail(claimed.ID, errors.New("connection refused")); err != nil {
+		t.Fatal(err)
+	}
+	if claimed, err = store.Claim(); err != nil || claimed != nil {
+		t.Fatalf("expected no pending job, got %+v, %v", claimed, err)
+	}
+
+	// Jobs survive reopening the store
+	store, err = Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || jobs[0].State != StateDone || jobs[0].NZBPath != "/out/first.nzb" ||
+		jobs[1].State != StateFailed || jobs[1].Error != "connection refused" || jobs[1].Group != "alt.binaries.test" {
+		t.Fatalf("unexpected jobs after reopening: %+v %+v", jobs[0], jobs[1])
+	}
+
+	if _, err := store.Retry(first.ID); err == nil {
+		t.Error("expected an error retrying a done job")
+	}
+	if count, err := store.Retry(); err != nil || count != 1 {
+		t.Fatalf("expected 1 job requeued, got %d, %v", count, err)
+	}
+	claimed, err = store.Claim()
+	if err != nil || claimed == nil || claimed.ID != second.ID || claimed.Attempts != 2 || claimed.Error != "" {
+		t.Fatalf("unexpected retried job %+v, %v", claimed, err)
+	}
+
+	// A run interrupted while the job was running leaves it recoverable
+	if count, err := store.Recover(); err != nil || count != 1 {
+		t.Fatalf("expected 1 job recovered, got %d, %v", count, err)
+	}
+	if job, err := store.Get(second.ID); err != nil || job.State != StatePending {
+		t.Fatalf("job not pending after recovery: %+v, %v", job, err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := store.Add("/data/pending.iso", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	running, err := store.Add("/data/running.iso", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := store.Cancel(pending.ID)
+	if err != nil || job.State != StateCancelled {
+		t.Fatalf("unexpected cancelled job %+v, %v", job, err)
+	}
+	// A cancelled job is never claimed
+	claimed, err := store.Claim()
+	if err != nil || claimed == nil || claimed.ID != running.ID {
+		t.Fatalf("expected the second job to be claimed, got %+v, %v", claimed, err)
+	}
+	if err := store.Started(running.ID, "1a215f384a413bb8"); err != nil {
+		t.Fatal(err)
+	}
+	if job, err = store.Cancel(running.ID); err != nil || job.State != StateCancelled || job.PostID != "1a215f384a413bb8" {
+		t.Fatalf("unexpected cancelled job %+v, %v", job, err)
+	}
+
+	if _, err := store.Cancel(running.ID); err == nil {
+		t.Error("expected an error cancelling a cancelled job")
+	}
+	if count, err := store.Recover(); err != nil || count != 0 {
+		t.Errorf("expected no job recovered, got %d, %v", count, err)
+	}
+}
+
+func TestSegments(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := store.Add("/data/file.bin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, segment := range []Segment{
+		{FileName: "file.bin", Number: 2, MessageID: "<b@test>"},
+		{FileName: "file.bin", Number: 1, MessageID: "<a@test>"},
+		{FileName: "file.bin", Number: 2, MessageID: "<b2@test>"},
+	} {
+		if err := store.RecordSegment(job.ID, segment); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	segments, err := store.Segments(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 2 || segments[0].MessageID != "<a@test>" || segments[1].MessageID != "<b2@test>" {
+		t.Errorf("unexpected segments %+v", segments)
+	}
+
+	if segments, err := store.Segments(job.ID + 1); err != nil || len(segments) != 0 {
+		t.Errorf("expected no segments for an unknown job, got %+v, %v", segments, err)
+	}
+}
+
+func TestSegmentsBatched(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "queue.db")
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := store.Add("/data/file.bin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another process sees the store as written
+	other, err := Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.RecordSegment(job.ID, Segment{FileName: "file.bin", Number: 1, MessageID: "<a@test>"}); err != nil {
+		t.Fatal(err)
+	}
+	if segments, err := other.Segments(job.ID); err != nil || len(segments) != 0 {
+		t.Errorf("expected the segment to wait for a transaction, got %+v, %v", segments, err)
+	}
+	// Any transaction writes it
+	if err := store.Started(job.ID, "post"); err != nil {
+		t.Fatal(err)
+	}
+	if segments, err := other.Segments(job.ID); err != nil || len(segments) != 1 {
+		t.Errorf("expected the segment once a job was updated, got %+v, %v", segments, err)
+	}
+
+	for i := 2; i <= segmentBatch+1; i++ {
+		if err := store.RecordSegment(job.ID, Segment{FileName: "file.bin", Number: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if segments, err := other.Segments(job.ID); err != nil || len(segments) != segmentBatch+1 {
+		t.Errorf("expected a full batch to be written, got %d segments, %v", len(segments), err)
+	}
+
+	if err := store.RecordSegment(job.ID, Segment{FileName: "file.bin", Number: segmentBatch + 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if segments, err := other.Segments(job.ID); err != nil || len(segments) != segmentBatch+2 {
+		t.Errorf("expected a flush to write the segment, got %d segments, %v", len(segments), err)
+	}
+}
+
+func TestRunLock(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, err := store.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Lock(); err == nil {
+		t.Error("expected a second run lock to fail")
+	}
+	// Adding jobs does not need the lock
+	if _, err := store.Add("/data/file.bin", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	lock, err = store.Lock()
+	if err != nil {
+		t.Fatalf("expected the lock once released: %v", err)
+	}
+	lock.Unlock()
+}
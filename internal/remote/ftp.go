@@ -0,0 +1,197 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ftpSession is a logged-in FTP control connection
+type ftpSession struct {
+	conn net.Conn
+	text *textproto.Conn
+	ctx  context.Context
+}
+
+// openFTP opens ftp://[user[:password]@]host[:port]/path. As with curl, the
+// path is relative to the login directory; start it with two slashes for an
+// absolute one. Without user the login is anonymous.
+func openFTP(ctx context.Context, u *url.URL) (Object, error) {
+	file := strings.TrimPrefix(u.Path, "/")
+	if file == "" || strings.HasSuffix(file, "/") || strings.ContainsAny(file, "\r\n") {
+		return nil, fmt.Errorf("invalid FTP URL %q (expected a file)", u.Redacted())
+	}
+
+	session, err := dialFTP(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	size, err := session.size(file)
+	session.close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe %s: %w", u.Redacted(), err)
+	}
+
+	// Every stream has its own session: aborting a transfer half way leaves
+	// servers replying inconsistently on the control connection
+	open := func(offset int64) (io.ReadCloser, error) {
+		session, err := dialFTP(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		data, err := session.retrieve(file, offset)
+		if err != nil {
+			session.close()
+			return nil, err
+		}
+		return &ftpStream{data: data, session: session}, nil
+	}
+	return &object{name: path.Base(file), size: size, open: open}, nil
+}
+
+// dialFTP connects and logs in to the server of u
+func dialFTP(ctx context.Context, u *url.URL) (*ftpSession, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "21")
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", host, err)
+	}
+	session := &ftpSession{conn: conn, text: textproto.NewConn(conn), ctx: ctx}
+	if _, _, err := session.text.ReadResponse(220); err != nil {
+		session.close()
+		return nil, fmt.Errorf("FTP greeting from %s: %w", host, err)
+	}
+
+	user, password := "anonymous", "anonymous@"
+	if u.User != nil {
+		user = u.User.Username()
+		if pass, ok := u.User.Password(); ok {
+			password = pass
+		}
+	}
+	if strings.ContainsAny(user+password, "\r\n") {
+		session.close()
+		return nil, fmt.Errorf("invalid FTP credentials")
+	}
+	code, _, err := session.command(0, "USER %s", user)
+	if err == nil && code == 331 {
+		code, _, err = session.command(0, "PASS %s", password)
+	}
+	if err == nil && code != 230 {
+		err = fmt.Errorf("login refused (%d)", code)
+	}
+	if err == nil {
+		_, _, err = session.command(200, "TYPE I")
+	}
+	if err != nil {
+		session.close()
+		return nil, fmt.Errorf("FTP login to %s: %w", host, err)
+	}
+	return session, nil
+}
+
+// command sends a command and reads its reply, which must have the expected
+// code unless expected is 0
+func (s *ftpSession) command(expected int, format string, args ...interface{}) (int, string, error) {
+	if err := s.text.PrintfLine(format, args...); err != nil {
+		return 0, "", err
+	}
+	return s.text.ReadResponse(expected)
+}
+
+// size returns the size of file
+func (s *ftpSession) size(file string) (int64, error) {
+	_, message, err := s.command(213, "SIZE %s", file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(message), 10, 64)
+}
+
+// retrieve starts the download of file from offset in passive mode
+func (s *ftpSession) retrieve(file string, offset int64) (net.Conn, error) {
+	_, message, err := s.command(227, "PASV")
+	if err != nil {
+		return nil, err
+	}
+	port, err := passivePort(message)
+	if err != nil {
+		return nil, err
+	}
+	// The address of the reply is ignored, it is often wrong behind NAT
+	host, _, _ := net.SplitHostPort(s.conn.RemoteAddr().String())
+	var dialer net.Dialer
+	data, err := dialer.DialContext(s.ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open FTP data connection: %w", err)
+	}
+
+	if offset > 0 {
+		if _, _, err := s.command(350, "REST %d", offset); err != nil {
+			data.Close()
+			return nil, err
+		}
+	}
+	code, message, err := s.command(0, "RETR %s", file)
+	if err == nil && code != 125 && code != 150 {
+		err = fmt.Errorf("RETR %s: %d %s", file, code, message)
+	}
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	return data, nil
+}
+
+// passivePort returns the port of a PASV reply,
+// "Entering Passive Mode (h1,h2,h3,h4,p1,p2)"
+func passivePort(message string) (int, error) {
+	start, end := strings.Index(message, "("), strings.Index(message, ")")
+	if start < 0 || end < start {
+		return 0, fmt.Errorf("invalid PASV reply %q", message)
+	}
+	fields := strings.Split(message[start+1:end], ",")
+	if len(fields) != 6 {
+		return 0, fmt.Errorf("invalid PASV reply %q", message)
+	}
+	high, errHigh := strconv.Atoi(strings.TrimSpace(fields[4]))
+	low, errLow := strconv.Atoi(strings.TrimSpace(fields[5]))
+	if errHigh != nil || errLow != nil {
+		return 0, fmt.Errorf("invalid PASV reply %q", message)
+	}
+	return high<<8 | low, nil
+}
+
+// close ends the session
+func (s *ftpSession) close() {
+	s.text.PrintfLine("QUIT")
+	s.text.Close()
+}
+
+// ftpStream is a download and the session it belongs to
+type ftpStream struct {
+	data    net.Conn
+	session *ftpSession
+}
+
+// Read implements io.Reader
+func (s *ftpStream) Read(p []byte) (int, error) {
+	return s.data.Read(p)
+}
+
+// Close ends the download and its session
+func (s *ftpStream) Close() error {
+	s.data.Close()
+	s.session.close()
+	return nil
+}
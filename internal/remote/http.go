@@ -0,0 +1,100 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// signer prepares a request before it is sent, e.g. to authenticate it
+type signer func(req *http.Request) error
+
+// httpClient sends the requests of HTTP and S3 objects. It has no timeout,
+// as a stream lasts as long as the posting reading it.
+var httpClient = &http.Client{}
+
+// openHTTP probes an object served over HTTP with range requests
+func openHTTP(ctx context.Context, u *url.URL, sign signer) (Object, error) {
+	location := u.String()
+	request := func(method string, rangeHeader string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, method, location, nil)
+		if err != nil {
+			return nil, err
+		}
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		if sign != nil {
+			if err := sign(req); err != nil {
+				return nil, err
+			}
+		}
+		return httpClient.Do(req)
+	}
+
+	obj := &object{name: path.Base(u.Path)}
+	size, disposition, err := probeHTTP(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe %s: %w", location, err)
+	}
+	obj.size = size
+	if _, params, err := mime.ParseMediaType(disposition); err == nil && params["filename"] != "" {
+		obj.name = path.Base(params["filename"])
+	}
+
+	obj.open = func(offset int64) (io.ReadCloser, error) {
+		resp, err := request(http.MethodGet, fmt.Sprintf("bytes=%d-", offset))
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusPartialContent && !(offset == 0 && resp.StatusCode == http.StatusOK) {
+			resp.Body.Close()
+			return nil, fmt.Errorf("GET %s: %s", location, resp.Status)
+		}
+		return resp.Body, nil
+	}
+	return obj, nil
+}
+
+// probeHTTP returns the size and content disposition of an object. A HEAD
+// request is tried first; servers refusing it, or not announcing range
+// support, are asked for the first byte instead.
+func probeHTTP(request func(method string, rangeHeader string) (*http.Response, error)) (int64, string, error) {
+	resp, err := request(http.MethodHead, "")
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK && resp.ContentLength >= 0 && resp.Header.Get("Accept-Ranges") == "bytes" {
+			return resp.ContentLength, resp.Header.Get("Content-Disposition"), nil
+		}
+	}
+
+	resp, err = request(http.MethodGet, "bytes=0-0")
+	if err != nil {
+		return 0, "", err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		return 0, "", fmt.Errorf("the server does not support range requests")
+	default:
+		return 0, "", fmt.Errorf("%s", resp.Status)
+	}
+	// Content-Range: bytes 0-0/<size>
+	contentRange := resp.Header.Get("Content-Range")
+	slash := strings.LastIndex(contentRange, "/")
+	if slash < 0 {
+		return 0, "", fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	size, err := strconv.ParseInt(contentRange[slash+1:], 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("unknown object size in Content-Range %q", contentRange)
+	}
+	return size, resp.Header.Get("Content-Disposition"), nil
+}
@@ -0,0 +1,156 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Object is a remote file read in place: sequential reads share one
+// download stream, and a read elsewhere reopens it at the new offset
+type Object interface {
+	io.ReaderAt
+	io.Closer
+	// Name is the file name of the object
+	Name() string
+	// Size is the length of the object in bytes
+	Size() int64
+}
+
+// schemes are the URL schemes of remote sources
+var schemes = []string{"http://", "https://", "s3://", "ftp://", "sftp://", "smb://"}
+
+// IsURL reports whether location names a remote source rather than a path
+func IsURL(location string) bool {
+	lower := strings.ToLower(location)
+	for _, scheme := range schemes {
+		if strings.HasPrefix(lower, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// Open probes the size of the object at location and opens it for reading.
+// Reads are bound to ctx.
+func Open(ctx context.Context, location string) (Object, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", location, err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return openHTTP(ctx, u, nil)
+	case "s3":
+		return openS3(ctx, u)
+	case "ftp":
+		return openFTP(ctx, u)
+	case "sftp":
+		return openSFTP(ctx, u)
+	case "smb":
+		return openSMB(ctx, u)
+	}
+	return nil, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+}
+
+// Name returns the file name of the object at location, the last element of
+// its path, without contacting it
+func Name(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return path.Base(location)
+	}
+	return path.Base(u.Path)
+}
+
+// opener starts a download stream at offset
+type opener func(offset int64) (io.ReadCloser, error)
+
+// maxAttempts bounds the streams opened for one read, so a connection
+// dropped mid-download is resumed where it stopped
+const maxAttempts = 3
+
+// object serves ReadAt from a download stream
+type object struct {
+	name string
+	size int64
+	open opener
+
+	mu     sync.Mutex
+	body   io.ReadCloser
+	offset int64
+}
+
+// Name implements Object
+func (o *object) Name() string {
+	return o.name
+}
+
+// Size implements Object
+func (o *object) Size() int64 {
+	return o.size
+}
+
+// ReadAt implements io.ReaderAt
+func (o *object) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("%s: negative offset", o.name)
+	}
+	if off >= o.size {
+		return 0, io.EOF
+	}
+	want := p
+	if remaining := o.size - off; int64(len(want)) > remaining {
+		want = want[:remaining]
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := 0
+	var lastErr error
+	for attempt := 0; attempt < maxAttempts && n < len(want); attempt++ {
+		if o.body == nil || o.offset != off+int64(n) {
+			o.closeBody()
+			body, err := o.open(off + int64(n))
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			o.body, o.offset = body, off+int64(n)
+		}
+		read, err := io.ReadFull(o.body, want[n:])
+		n += read
+		o.offset += int64(read)
+		if err != nil {
+			lastErr = err
+			o.closeBody()
+		}
+	}
+	if n < len(want) {
+		return n, fmt.Errorf("failed to read %s at %d: %w", o.name, off+int64(n), lastErr)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Close implements io.Closer
+func (o *object) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.closeBody()
+	return nil
+}
+
+// closeBody ends the current stream
+func (o *object) closeBody() {
+	if o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+}
@@ -0,0 +1,171 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// defaultKeys are the private keys of ~/.ssh tried when logging in
+var defaultKeys = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// openSFTP opens sftp://[user[:password]@]host[:port]/path. As with curl,
+// the path is absolute; start it with /~/ for one relative to the login
+// directory. The login tries the password of the URL, then the keys of the
+// SSH agent and the unencrypted ones of ~/.ssh, and the server must be in
+// ~/.ssh/known_hosts.
+func openSFTP(ctx context.Context, u *url.URL) (Object, error) {
+	file := strings.TrimPrefix(u.Path, "/~/")
+	if file == "" || file == "/" || strings.HasSuffix(file, "/") {
+		return nil, fmt.Errorf("invalid SFTP URL %q (expected a file)", u.Redacted())
+	}
+	config, err := sshConfig(u)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := dialSFTP(ctx, u, config)
+	if err != nil {
+		return nil, err
+	}
+	info, err := session.client.Stat(file)
+	session.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe %s: %w", u.Redacted(), err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a file", u.Redacted())
+	}
+
+	open := func(offset int64) (io.ReadCloser, error) {
+		session, err := dialSFTP(ctx, u, config)
+		if err != nil {
+			return nil, err
+		}
+		source, err := session.client.Open(file)
+		if err == nil {
+			_, err = source.Seek(offset, io.SeekStart)
+		}
+		if err != nil {
+			session.Close()
+			return nil, fmt.Errorf("failed to open %s: %w", u.Redacted(), err)
+		}
+		session.file = source
+		return session, nil
+	}
+	return &object{name: path.Base(file), size: info.Size(), open: open}, nil
+}
+
+// sshConfig returns the login of u: its user, the ways to authenticate and
+// the known hosts
+func sshConfig(u *url.URL) (*ssh.ClientConfig, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the known hosts: %w", err)
+	}
+	hostKeys, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the known hosts: %w", err)
+	}
+
+	name := ""
+	var methods []ssh.AuthMethod
+	if u.User != nil {
+		name = u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			methods = append(methods, ssh.Password(password))
+		}
+	}
+	if name == "" {
+		current, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("invalid SFTP URL %q (expected a user)", u.Redacted())
+		}
+		name = current.Username
+	}
+
+	var signers []ssh.Signer
+	if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			// The agent is asked for its keys once, so its connection can
+			// be closed right away
+			signers, _ = agent.NewClient(conn).Signers()
+			conn.Close()
+		}
+	}
+	for _, key := range defaultKeys {
+		data, err := os.ReadFile(filepath.Join(home, ".ssh", key))
+		if err != nil {
+			continue
+		}
+		if signer, err := ssh.ParsePrivateKey(data); err == nil {
+			signers = append(signers, signer)
+		}
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	return &ssh.ClientConfig{
+		User:            name,
+		Auth:            methods,
+		HostKeyCallback: hostKeys,
+	}, nil
+}
+
+// sftpSession is an SFTP client, its SSH connection and the file it reads
+type sftpSession struct {
+	conn   *ssh.Client
+	client *sftp.Client
+	file   *sftp.File
+}
+
+// dialSFTP connects and logs in to the server of u
+func dialSFTP(ctx context.Context, u *url.URL, config *ssh.ClientConfig) (*sftpSession, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "22")
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", host, err)
+	}
+	sshConn, channels, requests, err := ssh.NewClientConn(conn, host, config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SSH login to %s: %w", host, err)
+	}
+	session := &sftpSession{conn: ssh.NewClient(sshConn, channels, requests)}
+	if session.client, err = sftp.NewClient(session.conn); err != nil {
+		session.conn.Close()
+		return nil, fmt.Errorf("failed to start SFTP on %s: %w", host, err)
+	}
+	return session, nil
+}
+
+// Read implements io.Reader
+func (s *sftpSession) Read(p []byte) (int, error) {
+	return s.file.Read(p)
+}
+
+// Close ends the session, with its file
+func (s *sftpSession) Close() error {
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.client.Close()
+	return s.conn.Close()
+}
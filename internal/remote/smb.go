@@ -0,0 +1,122 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/hirochachacha/go-smb2"
+)
+
+// smbLocation splits the path of smb://host/share/path into the share and
+// the path of the file in it, with Windows separators
+func smbLocation(u *url.URL) (string, string, error) {
+	share, file, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if share == "" || file == "" || strings.HasSuffix(file, "/") {
+		return "", "", fmt.Errorf("invalid SMB URL %q (expected smb://host/share/path)", u.Redacted())
+	}
+	return share, strings.ReplaceAll(file, "/", `\`), nil
+}
+
+// openSMB opens smb://[[domain;]user[:password]@]host[:port]/share/path, such
+// as a file of a NAS share. Without user the login is the guest account.
+func openSMB(ctx context.Context, u *url.URL) (Object, error) {
+	share, file, err := smbLocation(u)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := dialSMB(ctx, u, share)
+	if err != nil {
+		return nil, err
+	}
+	info, err := session.share.Stat(file)
+	session.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe %s: %w", u.Redacted(), err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a file", u.Redacted())
+	}
+
+	open := func(offset int64) (io.ReadCloser, error) {
+		session, err := dialSMB(ctx, u, share)
+		if err != nil {
+			return nil, err
+		}
+		source, err := session.share.Open(file)
+		if err == nil {
+			_, err = source.Seek(offset, io.SeekStart)
+		}
+		if err != nil {
+			session.Close()
+			return nil, fmt.Errorf("failed to open %s: %w", u.Redacted(), err)
+		}
+		session.file = source
+		return session, nil
+	}
+	return &object{name: path.Base(u.Path), size: info.Size(), open: open}, nil
+}
+
+// smbSession is a share mounted over its own connection, and the file it
+// reads
+type smbSession struct {
+	conn    net.Conn
+	session *smb2.Session
+	share   *smb2.Share
+	file    *smb2.File
+}
+
+// dialSMB connects, logs in to the server of u and mounts share
+func dialSMB(ctx context.Context, u *url.URL, share string) (*smbSession, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "445")
+	}
+	login := &smb2.NTLMInitiator{User: "Guest"}
+	if u.User != nil && u.User.Username() != "" {
+		login.User = u.User.Username()
+		if domain, name, ok := strings.Cut(login.User, ";"); ok {
+			login.Domain, login.User = domain, name
+		}
+		login.Password, _ = u.User.Password()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", host, err)
+	}
+	smbDialer := &smb2.Dialer{Initiator: login}
+	session, err := smbDialer.DialContext(ctx, conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SMB login to %s: %w", host, err)
+	}
+	mounted, err := session.Mount(share)
+	if err != nil {
+		session.Logoff()
+		conn.Close()
+		return nil, fmt.Errorf("failed to mount %s on %s: %w", share, host, err)
+	}
+	return &smbSession{conn: conn, session: session, share: mounted}, nil
+}
+
+// Read implements io.Reader
+func (s *smbSession) Read(p []byte) (int, error) {
+	return s.file.Read(p)
+}
+
+// Close ends the session, with its file and share
+func (s *smbSession) Close() error {
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.share.Umount()
+	s.session.Logoff()
+	return s.conn.Close()
+}
@@ -0,0 +1,344 @@
+package repost
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"ypost/internal/nzb"
+	"ypost/internal/yenc"
+)
+
+// Conn is a poster connection
+type Conn interface {
+	PostArticle(group string, subject string, from string, body string, headers map[string]string) (string, error)
+	Quit() error
+}
+
+// Options configures a repost
+type Options struct {
+	// ArticleSize is the data size of every segment but the last of a
+	// part; zero takes the size the NZB records for the file's first segment
+	ArticleSize int64
+	// PartSize is the size of the parts the file was split into, the first
+	// segment of each part starting it; zero posts the file as one part
+	PartSize int64
+	// PadParts zero-pads the last part to PartSize, as the post did
+	PadParts bool
+	// NewIDs posts the segments under new message IDs instead of the ones
+	// the NZB records
+	NewIDs bool
+	// Connections is the number of connections posting at once
+	Connections int
+	// Headers are added to every article
+	Headers map[string]string
+	// LineLength is the number of encoded bytes per yEnc line,
+	// yenc.DefaultLineLength when 0
+	LineLength int
+}
+
+// Job is a file of the NZB to repair from its source
+type Job struct {
+	// File is the index of the file in the NZB
+	File int
+	// Source is the file as it was posted
+	Source string
+	// Segments holds the numbers of the segments to repost, including
+	// numbers the NZB has no segment for
+	Segments []int
+}
+
+// Segment is the outcome of reposting a segment
+type Segment struct {
+	File      string `json:"file"`
+	Number    int    `json:"number"`
+	MessageID string `json:"message_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Result is the outcome of a repost
+type Result struct {
+	Segments []Segment `json:"segments"`
+	Reposted int       `json:"reposted"`
+	Failed   int       `json:"failed"`
+}
+
+// subjectCounter is the article counter of a subject
+var subjectCounter = regexp.MustCompile(`yEnc\s*\(\d+/(\d+)\)`)
+
+// article is a segment to repost
+type article struct {
+	index   int
+	file    int
+	source  *os.File
+	number  int
+	offset  int64
+	length  int64
+	padding int64
+	total   int
+	// fileSize is the size of the file as posted, padding included
+	fileSize int64
+	reuseID  string
+}
+
+// span is where the data of a segment lies in its source
+type span struct {
+	offset int64
+	// length runs past the source into the padding of the last part
+	length int64
+}
+
+// Repost posts the segments of jobs again from their sources and patches
+// doc with their message IDs, adding the segments it had no entry for.
+// Segments are read from the parts the file was posted in, laid out by opts.
+// Failed segments are recorded in the result rather than returned; an
+// error is returned when a source does not match its file.
+func Repost(ctx context.Context, doc *nzb.NZB, jobs []Job, dial func() (Conn, error), opts Options) (*Result, error) {
+	var articles []article
+	for _, job := range jobs {
+		source, err := os.Open(job.Source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open source: %w", err)
+		}
+		defer source.Close()
+
+		planned, err := plan(doc, job, source, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range planned {
+			a.index = len(articles)
+			articles = append(articles, a)
+		}
+	}
+
+	result := &Result{Segments: make([]Segment, len(articles))}
+	connections := opts.Connections
+	if connections <= 0 {
+		connections = 1
+	}
+	if connections > len(articles) {
+		connections = len(articles)
+	}
+
+	queue := make(chan article)
+	var wg sync.WaitGroup
+	for i := 0; i < connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			poster := &poster{dial: dial, headers: opts.Headers, encoder: yenc.Encoder{LineLength: opts.LineLength}}
+			defer poster.close()
+			for a := range queue {
+				result.Segments[a.index] = poster.post(doc, a)
+			}
+		}()
+	}
+
+dispatch:
+	for _, a := range articles {
+		select {
+		case queue <- a:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(queue)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, a := range articles {
+		segment := result.Segments[i]
+		if segment.Error != "" {
+			result.Failed++
+			continue
+		}
+		result.Reposted++
+		patch(&doc.Files[a.file], a.number, a.length, segment.MessageID)
+	}
+	return result, nil
+}
+
+// plan lists the articles of a job after checking that its source has
+// the segments the file announces, and the sizes the NZB records
+func plan(doc *nzb.NZB, job Job, source *os.File, opts Options) ([]article, error) {
+	if job.File < 0 || job.File >= len(doc.Files) {
+		return nil, fmt.Errorf("no file %d in the NZB", job.File)
+	}
+	file := &doc.Files[job.File]
+
+	info, err := source.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat source: %w", err)
+	}
+	size := info.Size()
+
+	articleSize := opts.ArticleSize
+	if articleSize <= 0 {
+		for _, segment := range file.Segments {
+			if segment.Number == 1 {
+				articleSize = segment.Bytes
+			}
+		}
+		if articleSize <= 0 {
+			return nil, fmt.Errorf("the article size of %s is unknown, set it explicitly", file.Name())
+		}
+	}
+
+	spans := layout(size, opts.PartSize, articleSize, opts.PadParts)
+	total := len(spans)
+	var posted int64
+	if total > 0 {
+		posted = spans[total-1].offset + spans[total-1].length
+	}
+	if expected := file.ExpectedSegments(); expected != total {
+		return nil, fmt.Errorf("source %s does not match %s: %d segments of %d bytes, expected %d",
+			job.Source, file.Name(), total, articleSize, expected)
+	}
+
+	ids := make(map[int]string)
+	for _, segment := range file.Segments {
+		ids[segment.Number] = segment.MessageID
+		if segment.Number < 1 || segment.Number > total || segment.Bytes <= 0 {
+			continue
+		}
+		if length := spans[segment.Number-1].length; segment.Bytes != length {
+			return nil, fmt.Errorf("source %s does not match %s: segment %d is %d bytes, expected %d",
+				job.Source, file.Name(), segment.Number, length, segment.Bytes)
+		}
+	}
+
+	var articles []article
+	for _, number := range job.Segments {
+		if number < 1 || number > total {
+			return nil, fmt.Errorf("%s has no segment %d", file.Name(), number)
+		}
+		at := spans[number-1]
+		a := article{
+			file:     job.File,
+			source:   source,
+			number:   number,
+			offset:   at.offset,
+			length:   at.length,
+			total:    total,
+			fileSize: posted,
+		}
+		if end := at.offset + at.length; end > size {
+			a.padding = end - size
+			if a.padding > at.length {
+				a.padding = at.length
+			}
+		}
+		if !opts.NewIDs {
+			a.reuseID = ids[number]
+		}
+		articles = append(articles, a)
+	}
+	return articles, nil
+}
+
+// layout returns the segments of a source of size bytes as it was posted:
+// split into parts of partSize, or one part when zero, each posted in
+// articles of articleSize. With pad, the last part is padded to partSize.
+func layout(size int64, partSize int64, articleSize int64, pad bool) []span {
+	if partSize <= 0 {
+		partSize = size
+	}
+	var spans []span
+	for offset := int64(0); offset < size; offset += partSize {
+		end := offset + partSize
+		if end > size && !pad {
+			end = size
+		}
+		for start := offset; start < end; start += articleSize {
+			length := articleSize
+			if start+length > end {
+				length = end - start
+			}
+			spans = append(spans, span{offset: start, length: length})
+		}
+	}
+	return spans
+}
+
+// patch records a reposted segment in file
+func patch(file *nzb.File, number int, length int64, messageID string) {
+	messageID = strings.Trim(messageID, "<>")
+	for i := range file.Segments {
+		if file.Segments[i].Number == number {
+			file.Segments[i].MessageID = messageID
+			return
+		}
+	}
+	file.Segments = append(file.Segments, nzb.Segment{Bytes: length, Number: number, MessageID: messageID})
+	sort.SliceStable(file.Segments, func(a, b int) bool {
+		return file.Segments[a].Number < file.Segments[b].Number
+	})
+}
+
+// poster posts articles over a connection of its own, reopened after a
+// failure
+type poster struct {
+	dial    func() (Conn, error)
+	headers map[string]string
+	conn    Conn
+	encoder yenc.Encoder
+}
+
+// post reposts an article, retrying once on a fresh connection
+func (p *poster) post(doc *nzb.NZB, a article) Segment {
+	file := &doc.Files[a.file]
+	segment := Segment{File: file.Name(), Number: a.number}
+
+	// The padding of the last part stays zero
+	data := make([]byte, a.length)
+	if _, err := a.source.ReadAt(data[:a.length-a.padding], a.offset); err != nil {
+		segment.Error = fmt.Sprintf("failed to read source: %v", err)
+		return segment
+	}
+	// The articles of the file count its segments, as the post numbered them
+	body := p.encoder.Encode(data, yenc.Article{
+		Name: file.Name(), Number: a.number, Total: a.total, FileSize: a.fileSize, Offset: a.offset,
+	})
+
+	subject := subjectCounter.ReplaceAllString(file.Subject, fmt.Sprintf("yEnc (%d/%d)", a.number, a.total))
+	headers := make(map[string]string, len(p.headers)+1)
+	for key, value := range p.headers {
+		headers[key] = value
+	}
+	if a.reuseID != "" {
+		headers["Message-ID"] = "<" + a.reuseID + ">"
+	}
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if p.conn == nil {
+			if p.conn, err = p.dial(); err != nil {
+				continue
+			}
+		}
+		var messageID string
+		messageID, err = p.conn.PostArticle(strings.Join(file.Groups, ","), subject, file.Poster, body, headers)
+		if err == nil {
+			segment.MessageID = strings.Trim(messageID, "<>")
+			return segment
+		}
+		p.close()
+	}
+	segment.Error = err.Error()
+	return segment
+}
+
+// close closes the connection, if any
+func (p *poster) close() {
+	if p.conn != nil {
+		p.conn.Quit()
+		p.conn = nil
+	}
+}
@@ -0,0 +1,232 @@
+package repost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"ypost/internal/nzb"
+	"ypost/internal/yenc"
+)
+
+// posted is an article received by fakeConn
+type posted struct {
+	subject   string
+	messageID string
+	data      []byte
+	part      int
+	total     int
+	begin     int64
+}
+
+// fakeConn records the articles it accepts, failing the subjects listed in
+// fail
+type fakeConn struct {
+	mu       *sync.Mutex
+	articles map[string]posted
+	fail     map[string]bool
+}
+
+func (c *fakeConn) PostArticle(group, subject, from, body string, headers map[string]string) (string, error) {
+	if c.fail[subject] {
+		return "", errors.New("441 posting failed")
+	}
+	if group != "alt.binaries.test" || from != "poster@example.com" || headers["X-Test"] != "yes" {
+		return "", errors.New("unexpected article")
+	}
+	part, err := yenc.DecodePart([]byte(body))
+	if err != nil {
+		return "", err
+	}
+	messageID := headers["Message-ID"]
+	if messageID == "" {
+		messageID = "<new-" + subject + ">"
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.articles[subject] = posted{subject: subject, messageID: messageID, data: part.Data, part: part.Number, total: part.Total, begin: part.Begin}
+	return messageID, nil
+}
+
+func (c *fakeConn) Quit() error { return nil }
+
+// testNZB returns a 25-byte file posted in three 10-byte segments, the
+// second of which is absent from the NZB
+func testNZB(t *testing.T) (*nzb.NZB, string) {
+	t.Helper()
+	source := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(source, []byte("0123456789abcdefghijKLMNO"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	doc := &nzb.NZB{Files: []nzb.File{{
+		Poster:  "poster@example.com",
+		Subject: `[1/1] - "data.bin" - (25B) yEnc (1/3)`,
+		Groups:  []string{"alt.binaries.test"},
+		Segments: []nzb.Segment{
+			{Bytes: 10, Number: 1, MessageID: "one@test"},
+			{Bytes: 5, Number: 3, MessageID: "three@test"},
+		},
+	}}}
+	return doc, source
+}
+
+func TestRepost(t *testing.T) {
+	doc, source := testNZB(t)
+	conn := &fakeConn{mu: &sync.Mutex{}, articles: make(map[string]posted)}
+	dial := func() (Conn, error) { return conn, nil }
+
+	jobs := []Job{{File: 0, Source: source, Segments: []int{1, 2}}}
+	result, err := Repost(context.Background(), doc, jobs, dial, Options{Connections: 2, Headers: map[string]string{"X-Test": "yes"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Reposted != 2 || result.Failed != 0 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	first := conn.articles[`[1/1] - "data.bin" - (25B) yEnc (1/3)`]
+	if first.messageID != "<one@test>" || string(first.data) != "0123456789" {
+		t.Errorf("expected segment 1 to be reposted under its ID, got %+v", first)
+	}
+	second := conn.articles[`[1/1] - "data.bin" - (25B) yEnc (2/3)`]
+	if string(second.data) != "abcdefghij" {
+		t.Errorf("unexpected data of segment 2 %q", second.data)
+	}
+
+	segments := doc.Files[0].Segments
+	if len(segments) != 3 || segments[1].Number != 2 || segments[1].Bytes != 10 || segments[1].MessageID != "new-"+second.subject {
+		t.Errorf("expected segment 2 to be added to the NZB, got %+v", segments)
+	}
+	if segments[0].MessageID != "one@test" {
+		t.Errorf("expected segment 1 to keep its ID, got %q", segments[0].MessageID)
+	}
+}
+
+func TestRepostNewIDs(t *testing.T) {
+	doc, source := testNZB(t)
+	conn := &fakeConn{mu: &sync.Mutex{}, articles: make(map[string]posted)}
+	dial := func() (Conn, error) { return conn, nil }
+
+	jobs := []Job{{File: 0, Source: source, Segments: []int{3}}}
+	if _, err := Repost(context.Background(), doc, jobs, dial, Options{NewIDs: true, Headers: map[string]string{"X-Test": "yes"}}); err != nil {
+		t.Fatal(err)
+	}
+	last := doc.Files[0].Segments[1]
+	if last.Number != 3 || last.MessageID != `new-[1/1] - "data.bin" - (25B) yEnc (3/3)` {
+		t.Errorf("expected segment 3 to get a new ID, got %+v", last)
+	}
+	if data := conn.articles[`[1/1] - "data.bin" - (25B) yEnc (3/3)`].data; string(data) != "KLMNO" {
+		t.Errorf("unexpected data of the last segment %q", data)
+	}
+}
+
+func TestRepostRecordsFailures(t *testing.T) {
+	doc, source := testNZB(t)
+	conn := &fakeConn{
+		mu:       &sync.Mutex{},
+		articles: make(map[string]posted),
+		fail:     map[string]bool{`[1/1] - "data.bin" - (25B) yEnc (2/3)`: true},
+	}
+	dials := 0
+	dial := func() (Conn, error) {
+		dials++
+		return conn, nil
+	}
+
+	jobs := []Job{{File: 0, Source: source, Segments: []int{1, 2}}}
+	result, err := Repost(context.Background(), doc, jobs, dial, Options{Headers: map[string]string{"X-Test": "yes"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Reposted != 1 || result.Failed != 1 || result.Segments[1].Error == "" {
+		t.Errorf("expected segment 2 to fail, got %+v", result)
+	}
+	if dials != 2 {
+		t.Errorf("expected a redial after the failure, got %d dials", dials)
+	}
+	if len(doc.Files[0].Segments) != 2 {
+		t.Errorf("expected the failed segment to stay out of the NZB, got %+v", doc.Files[0].Segments)
+	}
+}
+
+func TestRepostRejectsMismatchedSource(t *testing.T) {
+	doc, source := testNZB(t)
+	if err := os.WriteFile(source, make([]byte, 35), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dial := func() (Conn, error) { return nil, errors.New("unexpected dial") }
+
+	jobs := []Job{{File: 0, Source: source, Segments: []int{2}}}
+	if _, err := Repost(context.Background(), doc, jobs, dial, Options{}); err == nil {
+		t.Fatal("expected a source of 4 segments to be rejected")
+	}
+}
+
+func TestRepostMultipartPost(t *testing.T) {
+	// 12-byte parts of 5-byte articles: the third segment ends the first part
+	// short and the fourth starts the second part at byte 12
+	_, source := testNZB(t)
+	doc := &nzb.NZB{Files: []nzb.File{{
+		Poster:  "poster@example.com",
+		Subject: `[1/1] - "data.bin" - (25B) yEnc (1/7)`,
+		Groups:  []string{"alt.binaries.test"},
+		Segments: []nzb.Segment{
+			{Bytes: 5, Number: 1, MessageID: "one@test"},
+			{Bytes: 5, Number: 2, MessageID: "two@test"},
+			{Bytes: 2, Number: 3, MessageID: "three@test"},
+			{Bytes: 5, Number: 5, MessageID: "five@test"},
+			{Bytes: 2, Number: 6, MessageID: "six@test"},
+		},
+	}}}
+	conn := &fakeConn{mu: &sync.Mutex{}, articles: make(map[string]posted)}
+	dial := func() (Conn, error) { return conn, nil }
+	opts := Options{PartSize: 12, Headers: map[string]string{"X-Test": "yes"}}
+
+	jobs := []Job{{File: 0, Source: source, Segments: []int{3, 4, 7}}}
+	result, err := Repost(context.Background(), doc, jobs, dial, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Reposted != 3 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	for number, want := range map[int]posted{
+		3: {data: []byte("ab"), part: 3, total: 7, begin: 11},
+		4: {data: []byte("cdefg"), part: 4, total: 7, begin: 13},
+		7: {data: []byte("O"), part: 7, total: 7, begin: 25},
+	} {
+		got := conn.articles[fmt.Sprintf(`[1/1] - "data.bin" - (25B) yEnc (%d/7)`, number)]
+		if string(got.data) != string(want.data) || got.part != want.part || got.total != want.total || got.begin != want.begin {
+			t.Errorf("expected segment %d to be %q of part %d/%d at %d, got %q of part %d/%d at %d",
+				number, want.data, want.part, want.total, want.begin, got.data, got.part, got.total, got.begin)
+		}
+	}
+	if segments := doc.Files[0].Segments; len(segments) != 7 || segments[3].Bytes != 5 || segments[6].Bytes != 1 {
+		t.Errorf("expected segments 4 and 7 to be added to the NZB, got %+v", segments)
+	}
+
+	// A part size other than the post's does not match the recorded sizes
+	jobs = []Job{{File: 0, Source: source, Segments: []int{4}}}
+	if _, err := Repost(context.Background(), doc, jobs, dial, Options{PartSize: 11}); err == nil {
+		t.Error("expected a source laid out differently to be rejected")
+	}
+
+	// The last part of a padded post ends with zeros
+	doc.Files[0].Subject = `[1/1] - "data.bin" - (25B) yEnc (1/9)`
+	doc.Files[0].Segments = doc.Files[0].Segments[:6]
+	opts.PadParts = true
+	jobs = []Job{{File: 0, Source: source, Segments: []int{7, 9}}}
+	if _, err := Repost(context.Background(), doc, jobs, dial, opts); err != nil {
+		t.Fatal(err)
+	}
+	if data := conn.articles[`[1/1] - "data.bin" - (25B) yEnc (7/9)`].data; string(data) != "O\x00\x00\x00\x00" {
+		t.Errorf("expected the padded segment 7, got %q", data)
+	}
+	if data := conn.articles[`[1/1] - "data.bin" - (25B) yEnc (9/9)`].data; string(data) != "\x00\x00" {
+		t.Errorf("expected the padding of segment 9, got %q", data)
+	}
+}
@@ -0,0 +1,155 @@
+package roundtrip
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// nzbget is an NZBGet instance, driven through its JSON-RPC API
+type nzbget struct {
+	url      string
+	username string
+	password string
+	category string
+	http     *http.Client
+}
+
+func (n *nzbget) String() string {
+	return "NZBGet " + n.url
+}
+
+// Submit adds the NZB with append, forcing it past the duplicate check; the
+// password is the unpack parameter of the download
+func (n *nzbget) Submit(ctx context.Context, name, password string, nzb []byte) (string, error) {
+	parameters := []map[string]string{}
+	if password != "" {
+		parameters = append(parameters, map[string]string{"Name": "*Unpack:Password", "Value": password})
+	}
+	var id int64
+	params := []interface{}{name + ".nzb", base64.StdEncoding.EncodeToString(nzb), n.category, 0, false, false, "", 0, "FORCE", parameters}
+	if err := n.call(ctx, "append", params, &id); err != nil {
+		return "", err
+	}
+	if id <= 0 {
+		return "", fmt.Errorf("refused the NZB")
+	}
+	return strconv.FormatInt(id, 10), nil
+}
+
+// nzbgetGroup is a download of the queue or the history
+type nzbgetGroup struct {
+	ID              int64   `json:"NZBID"`
+	Status          string  `json:"Status"`
+	FileSizeMB      float64 `json:"FileSizeMB"`
+	RemainingSizeMB float64 `json:"RemainingSizeMB"`
+}
+
+// Status looks for the download in the history, where finished downloads
+// are, then in the queue. Only a SUCCESS status passes; FAILURE, WARNING
+// (damaged or not repaired) and DELETED do not.
+func (n *nzbget) Status(ctx context.Context, id string) (Status, error) {
+	nzbID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return Status{}, fmt.Errorf("invalid download ID %q", id)
+	}
+
+	var history []nzbgetGroup
+	if err := n.call(ctx, "history", []interface{}{false}, &history); err != nil {
+		return Status{}, err
+	}
+	for _, group := range history {
+		if group.ID != nzbID {
+			continue
+		}
+		if strings.HasPrefix(group.Status, "SUCCESS") {
+			return Status{Done: true, Passed: true, Progress: 1}, nil
+		}
+		return Status{Done: true, Progress: 1, Message: group.Status}, nil
+	}
+
+	var queue []nzbgetGroup
+	if err := n.call(ctx, "listgroups", []interface{}{0}, &queue); err != nil {
+		return Status{}, err
+	}
+	for _, group := range queue {
+		if group.ID != nzbID {
+			continue
+		}
+		status := Status{}
+		if group.FileSizeMB > 0 {
+			status.Progress = 1 - group.RemainingSizeMB/group.FileSizeMB
+		}
+		return status, nil
+	}
+	return Status{}, fmt.Errorf("download %s is gone", id)
+}
+
+// Remove deletes the download from the queue, with its files, and from the
+// history
+func (n *nzbget) Remove(ctx context.Context, id string) error {
+	nzbID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid download ID %q", id)
+	}
+	for _, command := range []string{"GroupFinalDelete", "HistoryFinalDelete"} {
+		var done bool
+		if err := n.call(ctx, "editqueue", []interface{}{command, "", []int64{nzbID}}, &done); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// call sends a JSON-RPC request and decodes its result into result
+func (n *nzbget) call(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	payload, err := json.Marshal(map[string]interface{}{"version": "1.1", "id": 1, "method": method, "params": params})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url+"/jsonrpc", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ypost")
+	if n.username != "" || n.password != "" {
+		req.SetBasicAuth(n.username, n.password)
+	}
+
+	resp, err := n.http.Do(req)
+	if err != nil {
+		return redactedError(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+
+	var reply struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return fmt.Errorf("invalid reply to %s: %w", method, err)
+	}
+	if reply.Error != nil {
+		return fmt.Errorf("%s: %s", method, reply.Error.Message)
+	}
+	if err := json.Unmarshal(reply.Result, result); err != nil {
+		return fmt.Errorf("invalid reply to %s: %w", method, err)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+// Package roundtrip downloads posts back with SABnzbd or NZBGet, checking
+// end to end that they download and repair
+package roundtrip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"ypost/pkg/models"
+)
+
+// defaultTimeout bounds the wait for a download without a timeout
+const defaultTimeout = 2 * time.Hour
+
+// requestTimeout bounds each API request
+const requestTimeout = 30 * time.Second
+
+// pollInterval is the wait between two looks at a download
+var pollInterval = 10 * time.Second
+
+// Status is the state of a download
+type Status struct {
+	// Done reports the download finished, its repair and unpacking included
+	Done bool
+	// Passed reports a finished download succeeded
+	Passed bool
+	// Progress is the fraction downloaded, from 0 to 1
+	Progress float64
+	// Message is why the download failed, as the downloader reports it
+	Message string
+}
+
+// Client submits NZBs to a downloader and follows their downloads
+type Client interface {
+	// Submit adds an NZB under a name, with the password of its archive
+	// when not empty, returning the ID of the download
+	Submit(ctx context.Context, name, password string, nzb []byte) (string, error)
+	// Status returns the state of a download
+	Status(ctx context.Context, id string) (Status, error)
+	// Remove deletes a download and its files, finished or not
+	Remove(ctx context.Context, id string) error
+	// String describes the downloader in log lines
+	String() string
+}
+
+// Checker downloads posts with a client
+type Checker struct {
+	client  Client
+	delay   time.Duration
+	timeout time.Duration
+	keep    bool
+}
+
+// New checks the round trip settings and returns the checker of their client
+func New(cfg models.RoundTripConfig) (*Checker, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid roundtrip URL %q (expected http or https)", cfg.URL)
+	}
+	base := strings.TrimSuffix(cfg.URL, "/")
+	httpClient := &http.Client{Timeout: requestTimeout}
+
+	var client Client
+	switch strings.ToLower(cfg.Client) {
+	case models.ClientSABnzbd:
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("sabnzbd needs an api_key")
+		}
+		client = &sabnzbd{url: base, apiKey: cfg.APIKey, category: cfg.Category, http: httpClient}
+	case models.ClientNZBGet:
+		client = &nzbget{url: base, username: cfg.Username, password: cfg.Password, category: cfg.Category, http: httpClient}
+	default:
+		return nil, fmt.Errorf("invalid roundtrip client %q (expected sabnzbd or nzbget)", cfg.Client)
+	}
+
+	delay, err := parseDuration(cfg.Delay)
+	if err != nil {
+		return nil, fmt.Errorf("invalid roundtrip delay: %w", err)
+	}
+	timeout, err := parseDuration(cfg.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid roundtrip timeout: %w", err)
+	}
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	return &Checker{client: client, delay: delay, timeout: timeout, keep: cfg.Keep}, nil
+}
+
+// parseDuration parses a duration such as 90s or 2h, 0 for ""
+func parseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("negative duration %s", value)
+	}
+	return duration, nil
+}
+
+// String describes the downloader of the checker
+func (c *Checker) String() string {
+	return c.client.String()
+}
+
+// ErrTimeout is the error of a download that did not finish in time
+var ErrTimeout = errors.New("the download did not finish in time")
+
+// Check submits an NZB, with the password of its archive, after the delay
+// and waits for its download to finish, reporting its progress. The download
+// is removed afterwards unless the checker keeps them. An error is a round
+// trip that could not be carried out, a download that failed is a Status
+// that did not pass.
+func (c *Checker) Check(ctx context.Context, name, password string, nzb []byte, progress func(float64)) (Status, error) {
+	if c.delay > 0 {
+		select {
+		case <-ctx.Done():
+			return Status{}, ctx.Err()
+		case <-time.After(c.delay):
+		}
+	}
+
+	id, err := c.client.Submit(ctx, name, password, nzb)
+	if err != nil {
+		return Status{}, fmt.Errorf("failed to submit the NZB to %s: %w", c, err)
+	}
+	if !c.keep {
+		// An interrupted check still removes its download
+		defer c.client.Remove(context.WithoutCancel(ctx), id)
+	}
+
+	deadline := time.NewTimer(c.timeout)
+	defer deadline.Stop()
+	for {
+		status, err := c.client.Status(ctx, id)
+		if err != nil {
+			return Status{}, fmt.Errorf("failed to follow the download on %s: %w", c, err)
+		}
+		if status.Done {
+			return status, nil
+		}
+		if progress != nil {
+			progress(status.Progress)
+		}
+
+		select {
+		case <-ctx.Done():
+			return Status{}, ctx.Err()
+		case <-deadline.C:
+			return Status{}, ErrTimeout
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// redactedError strips the URL from a request error, it may hold the API key
+func redactedError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
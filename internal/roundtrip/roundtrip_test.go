@@ -0,0 +1,227 @@
+package roundtrip
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"ypost/pkg/models"
+)
+
+func init() {
+	pollInterval = time.Millisecond
+}
+
+// fakeSABnzbd answers the API of SABnzbd: a download is in the queue for
+// its first look, then in the history with status. NZBs must come with
+// password.
+func fakeSABnzbd(t *testing.T, status, password string) (*httptest.Server, *[]string) {
+	var mu sync.Mutex
+	var calls []string
+	looks := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		query := r.URL.Query()
+		if query.Get("apikey") != "k3y" {
+			w.Write([]byte(`{"status": false, "error": "API Key Incorrect"}`))
+			return
+		}
+		mode := query.Get("mode")
+		if name := query.Get("name"); name != "" {
+			mode += " " + name + " " + query.Get("value")
+		}
+		calls = append(calls, mode)
+		switch mode {
+		case "addfile":
+			file, header, err := r.FormFile("name")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			data, _ := io.ReadAll(file)
+			if header.Filename != "movie.nzb" || string(data) != "<nzb/>" || query.Get("cat") != "check" || query.Get("password") != password {
+				t.Errorf("unexpected upload %s of %q in %q with password %q", header.Filename, data, query.Get("cat"), query.Get("password"))
+			}
+			w.Write([]byte(`{"status": true, "nzo_ids": ["SABnzbd_nzo_1"]}`))
+		case "history":
+			if looks == 0 {
+				w.Write([]byte(`{"history": {"slots": []}}`))
+				return
+			}
+			fmt.Fprintf(w, `{"history": {"slots": [{"nzo_id": "SABnzbd_nzo_1", "status": %q, "fail_message": "Repair failed, not enough repair blocks"}]}}`, status)
+		case "queue":
+			looks++
+			w.Write([]byte(`{"queue": {"slots": [{"nzo_id": "SABnzbd_nzo_1", "status": "Downloading", "percentage": "40"}]}}`))
+		default:
+			w.Write([]byte(`{"status": true}`))
+		}
+	}))
+	return server, &calls
+}
+
+func TestSABnzbdPassed(t *testing.T) {
+	server, calls := fakeSABnzbd(t, "Completed", "s3cret")
+	defer server.Close()
+
+	checker, err := New(models.RoundTripConfig{Client: "sabnzbd", URL: server.URL, APIKey: "k3y", Category: "check"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var progress []float64
+	status, err := checker.Check(context.Background(), "movie", "s3cret", []byte("<nzb/>"), func(fraction float64) {
+		progress = append(progress, fraction)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Passed {
+		t.Errorf("expected the download to pass, got %+v", status)
+	}
+	if len(progress) != 1 || progress[0] != 0.4 {
+		t.Errorf("unexpected progress %v", progress)
+	}
+	expected := []string{"addfile", "history", "queue", "history", "queue delete SABnzbd_nzo_1", "history delete SABnzbd_nzo_1"}
+	if fmt.Sprint(*calls) != fmt.Sprint(expected) {
+		t.Errorf("expected calls %v, got %v", expected, *calls)
+	}
+}
+
+func TestSABnzbdFailed(t *testing.T) {
+	server, _ := fakeSABnzbd(t, "Failed", "")
+	defer server.Close()
+
+	checker, err := New(models.RoundTripConfig{Client: "sabnzbd", URL: server.URL, APIKey: "k3y", Category: "check", Keep: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := checker.Check(context.Background(), "movie", "", []byte("<nzb/>"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Passed || !status.Done || status.Message != "Repair failed, not enough repair blocks" {
+		t.Errorf("expected the failure, got %+v", status)
+	}
+}
+
+func TestSABnzbdWrongKey(t *testing.T) {
+	server, _ := fakeSABnzbd(t, "Completed", "")
+	defer server.Close()
+
+	checker, err := New(models.RoundTripConfig{Client: "sabnzbd", URL: server.URL, APIKey: "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := checker.Check(context.Background(), "movie", "", []byte("<nzb/>"), nil); err == nil {
+		t.Error("expected the key to be refused")
+	}
+}
+
+// rpcRequest is a JSON-RPC request to NZBGet
+type rpcRequest struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+func TestNZBGet(t *testing.T) {
+	var methods []string
+	var content []byte
+	var parameters string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, _ := r.BasicAuth(); user != "nzbget" || password != "tegbzn6789" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+			return
+		}
+		methods = append(methods, req.Method)
+		switch req.Method {
+		case "append":
+			var encoded string
+			json.Unmarshal(req.Params[1], &encoded)
+			content, _ = base64.StdEncoding.DecodeString(encoded)
+			parameters = string(req.Params[9])
+			w.Write([]byte(`{"version": "1.1", "result": 7}`))
+		case "history":
+			w.Write([]byte(`{"version": "1.1", "result": [{"NZBID": 3, "Status": "SUCCESS/ALL"}, {"NZBID": 7, "Status": "FAILURE/PAR"}]}`))
+		default:
+			w.Write([]byte(`{"version": "1.1", "result": true}`))
+		}
+	}))
+	defer server.Close()
+
+	checker, err := New(models.RoundTripConfig{Client: "nzbget", URL: server.URL, Username: "nzbget", Password: "tegbzn6789"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := checker.Check(context.Background(), "movie", "s3cret", []byte("<nzb/>"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parameters != `[{"Name":"*Unpack:Password","Value":"s3cret"}]` {
+		t.Errorf("unexpected post-processing parameters %s", parameters)
+	}
+	if status.Passed || status.Message != "FAILURE/PAR" {
+		t.Errorf("expected the failure of download 7, got %+v", status)
+	}
+	if string(content) != "<nzb/>" {
+		t.Errorf("unexpected NZB %q", content)
+	}
+	if expected := "[append history editqueue editqueue]"; fmt.Sprint(methods) != expected {
+		t.Errorf("expected calls %s, got %v", expected, methods)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		switch req.Method {
+		case "append":
+			w.Write([]byte(`{"result": 1}`))
+		case "listgroups":
+			w.Write([]byte(`{"result": [{"NZBID": 1, "Status": "DOWNLOADING", "FileSizeMB": 100, "RemainingSizeMB": 75}]}`))
+		default:
+			w.Write([]byte(`{"result": []}`))
+		}
+	}))
+	defer server.Close()
+
+	checker, err := New(models.RoundTripConfig{Client: "nzbget", URL: server.URL, Timeout: "20ms", Keep: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last float64
+	_, err = checker.Check(context.Background(), "movie", "", []byte("<nzb/>"), func(fraction float64) { last = fraction })
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	if last != 0.25 {
+		t.Errorf("expected a progress of 0.25, got %v", last)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, cfg := range []models.RoundTripConfig{
+		{Client: "nzbget", URL: "localhost:6789"},
+		{Client: "deluge", URL: "http://localhost:8112"},
+		{Client: "sabnzbd", URL: "http://localhost:8080"},
+		{Client: "nzbget", URL: "http://localhost:6789", Delay: "soon"},
+		{Client: "nzbget", URL: "http://localhost:6789", Timeout: "-1h"},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("expected %+v to be refused", cfg)
+		}
+	}
+}
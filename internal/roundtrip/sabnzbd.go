@@ -0,0 +1,175 @@
+package roundtrip
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// sabnzbd is a SABnzbd instance, driven through its API
+type sabnzbd struct {
+	url      string
+	apiKey   string
+	category string
+	http     *http.Client
+}
+
+func (s *sabnzbd) String() string {
+	return "SABnzbd " + s.url
+}
+
+// Submit adds the NZB with mode=addfile
+func (s *sabnzbd) Submit(ctx context.Context, name, password string, nzb []byte) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("name", name+".nzb")
+	if err != nil {
+		return "", err
+	}
+	file.Write(nzb)
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	query := url.Values{"mode": {"addfile"}, "nzbname": {name}}
+	if s.category != "" {
+		query.Set("cat", s.category)
+	}
+	if password != "" {
+		query.Set("password", password)
+	}
+	var reply struct {
+		Status bool     `json:"status"`
+		Error  string   `json:"error"`
+		IDs    []string `json:"nzo_ids"`
+	}
+	if err := s.call(ctx, query, &body, form.FormDataContentType(), &reply); err != nil {
+		return "", err
+	}
+	if !reply.Status || len(reply.IDs) == 0 {
+		if reply.Error != "" {
+			return "", fmt.Errorf("refused: %s", reply.Error)
+		}
+		return "", fmt.Errorf("refused the NZB")
+	}
+	return reply.IDs[0], nil
+}
+
+// sabnzbdSlot is a download of the queue or the history
+type sabnzbdSlot struct {
+	ID         string `json:"nzo_id"`
+	Status     string `json:"status"`
+	Percentage string `json:"percentage"`
+	FailReason string `json:"fail_message"`
+}
+
+// Status looks for the download in the history, where finished downloads
+// are, then in the queue
+func (s *sabnzbd) Status(ctx context.Context, id string) (Status, error) {
+	var history struct {
+		History struct {
+			Slots []sabnzbdSlot `json:"slots"`
+		} `json:"history"`
+	}
+	if err := s.call(ctx, url.Values{"mode": {"history"}, "nzo_ids": {id}}, nil, "", &history); err != nil {
+		return Status{}, err
+	}
+	for _, slot := range history.History.Slots {
+		if slot.ID != id {
+			continue
+		}
+		switch slot.Status {
+		case "Completed":
+			return Status{Done: true, Passed: true, Progress: 1}, nil
+		case "Failed":
+			return Status{Done: true, Progress: 1, Message: slot.FailReason}, nil
+		}
+		// Verifying, repairing, extracting or moving
+		return Status{Progress: 1}, nil
+	}
+
+	var queue struct {
+		Queue struct {
+			Slots []sabnzbdSlot `json:"slots"`
+		} `json:"queue"`
+	}
+	if err := s.call(ctx, url.Values{"mode": {"queue"}, "nzo_ids": {id}}, nil, "", &queue); err != nil {
+		return Status{}, err
+	}
+	for _, slot := range queue.Queue.Slots {
+		if slot.ID == id {
+			percentage, _ := strconv.ParseFloat(slot.Percentage, 64)
+			return Status{Progress: percentage / 100}, nil
+		}
+	}
+	return Status{}, fmt.Errorf("download %s is gone", id)
+}
+
+// Remove deletes the download from the queue and the history, with its files
+func (s *sabnzbd) Remove(ctx context.Context, id string) error {
+	for _, mode := range []string{"queue", "history"} {
+		query := url.Values{"mode": {mode}, "name": {"delete"}, "value": {id}, "del_files": {"1"}}
+		if err := s.call(ctx, query, nil, "", nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// call sends an API request, posting body when set, and decodes its JSON
+// reply into reply when set
+func (s *sabnzbd) call(ctx context.Context, query url.Values, body io.Reader, contentType string, reply interface{}) error {
+	query.Set("apikey", s.apiKey)
+	query.Set("output", "json")
+	method := http.MethodGet
+	if body != nil {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.url+"/api?"+query.Encode(), body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("User-Agent", "ypost")
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return redactedError(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	// A wrong API key is answered with an error, as plain text by older
+	// releases
+	if text := strings.TrimSpace(string(data)); strings.HasPrefix(text, "error:") {
+		return fmt.Errorf("%s", strings.TrimSpace(strings.TrimPrefix(text, "error:")))
+	}
+	var failure struct {
+		Status *bool  `json:"status"`
+		Error  string `json:"error"`
+	}
+	if json.Unmarshal(data, &failure) == nil && failure.Status != nil && !*failure.Status && failure.Error != "" {
+		return fmt.Errorf("%s", failure.Error)
+	}
+	if reply == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, reply); err != nil {
+		return fmt.Errorf("invalid reply: %w", err)
+	}
+	return nil
+}
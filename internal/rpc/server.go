@@ -0,0 +1,96 @@
+// Package rpc serves the gRPC API of pkg/api over the job queue of a ypost
+// process.
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"ypost/pkg/api"
+)
+
+// shutdownTimeout bounds the wait for open calls when the server stops;
+// progress streams still open are then closed
+const shutdownTimeout = 5 * time.Second
+
+// Server is the gRPC endpoint of a service
+type Server struct {
+	listener net.Listener
+	server   *grpc.Server
+	done     chan error
+}
+
+// Listen serves the service on address (host:port) until Close. With a
+// token, calls must carry it as an "authorization: Bearer <token>" header.
+func Listen(address string, service *Service, token string) (*Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+
+	var options []grpc.ServerOption
+	if token != "" {
+		options = append(options,
+			grpc.UnaryInterceptor(func(ctx context.Context, request interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				if err := authorize(ctx, token); err != nil {
+					return nil, err
+				}
+				return handler(ctx, request)
+			}),
+			grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				if err := authorize(stream.Context(), token); err != nil {
+					return err
+				}
+				return handler(srv, stream)
+			}),
+		)
+	}
+
+	s := &Server{listener: listener, server: grpc.NewServer(options...), done: make(chan error, 1)}
+	api.RegisterYpostServer(s.server, service)
+	go func() {
+		s.done <- s.server.Serve(listener)
+	}()
+	return s, nil
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server, letting open calls finish for a while
+func (s *Server) Close() error {
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(shutdownTimeout):
+		s.server.Stop()
+	}
+	return <-s.done
+}
+
+// authorize checks the bearer token of a call
+func authorize(ctx context.Context, token string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		scheme, credential, ok := strings.Cut(value, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") && subtle.ConstantTimeCompare([]byte(credential), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid token")
+}
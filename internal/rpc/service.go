@@ -0,0 +1,205 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"ypost/internal/queue"
+	"ypost/internal/remote"
+	poststatus "ypost/internal/status"
+	"ypost/pkg/api"
+)
+
+// Progress updates are sent every defaultInterval unless the client asks for
+// another interval, at most every minInterval
+const (
+	defaultInterval = time.Second
+	minInterval     = 100 * time.Millisecond
+)
+
+// Runner is the process running the jobs of the queue
+type Runner interface {
+	// Wake tells the runner a job was queued
+	Wake()
+	// Cancel stops a job if the runner is running it
+	Cancel(id uint64)
+}
+
+// Service is the Ypost gRPC service over a job queue, the runner of its jobs
+// and the monitor following their posts
+type Service struct {
+	api.UnimplementedYpostServer
+	store   *queue.Store
+	runner  Runner
+	monitor *poststatus.Monitor
+}
+
+// NewService creates the service of a queue
+func NewService(store *queue.Store, runner Runner, monitor *poststatus.Monitor) *Service {
+	return &Service{store: store, runner: runner, monitor: monitor}
+}
+
+// SubmitJob queues a path of the server's host or a URL and wakes the runner
+func (s *Service) SubmitJob(ctx context.Context, request *api.SubmitJobRequest) (*api.Job, error) {
+	path := request.GetPath()
+	if !remote.IsURL(path) {
+		if !filepath.IsAbs(path) {
+			return nil, status.Errorf(codes.InvalidArgument, "path %q is neither absolute nor a URL", path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, status.Errorf(codes.NotFound, "%v", err)
+		}
+	}
+
+	job, err := s.store.Add(path, request.GetGroup())
+	if err != nil {
+		return nil, storeError(err)
+	}
+	s.runner.Wake()
+	return toJob(job), nil
+}
+
+// WatchProgress sends the progress of a job every interval, then once more
+// when it is finished
+func (s *Service) WatchProgress(request *api.WatchProgressRequest, stream api.Ypost_WatchProgressServer) error {
+	interval := defaultInterval
+	if request.GetInterval() != nil {
+		interval = max(request.GetInterval().AsDuration(), minInterval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		job, err := s.store.Get(request.GetJobId())
+		if err != nil {
+			return storeError(err)
+		}
+		if err := stream.Send(s.progress(job)); err != nil {
+			return err
+		}
+		if finished(job.State) {
+			return nil
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetNZB returns the NZB file of a done job
+func (s *Service) GetNZB(ctx context.Context, request *api.GetNZBRequest) (*api.NZB, error) {
+	job, err := s.store.Get(request.GetJobId())
+	if err != nil {
+		return nil, storeError(err)
+	}
+	if job.State != queue.StateDone {
+		return nil, status.Errorf(codes.FailedPrecondition, "job %d is %s", job.ID, job.State)
+	}
+
+	content, err := os.ReadFile(job.NZBPath)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "failed to read the NZB of job %d: %v", job.ID, err)
+	}
+	return &api.NZB{Name: filepath.Base(job.NZBPath), Content: content}, nil
+}
+
+// CancelJob cancels a pending job, or a running one, which the runner stops
+func (s *Service) CancelJob(ctx context.Context, request *api.CancelJobRequest) (*api.Job, error) {
+	job, err := s.store.Get(request.GetJobId())
+	if err != nil {
+		return nil, storeError(err)
+	}
+	if finished(job.State) {
+		return nil, status.Errorf(codes.FailedPrecondition, "job %d is %s", job.ID, job.State)
+	}
+
+	if job, err = s.store.Cancel(job.ID); err != nil {
+		return nil, storeError(err)
+	}
+	s.runner.Cancel(job.ID)
+	return toJob(job), nil
+}
+
+// progress returns the progress of a job: its state, and the progress of its
+// post while the monitor follows it
+func (s *Service) progress(job *queue.Job) *api.JobProgress {
+	progress := &api.JobProgress{Job: toJob(job)}
+	if s.monitor == nil || job.PostID == "" {
+		return progress
+	}
+	post := s.monitor.Job(job.PostID)
+	if post == nil {
+		return progress
+	}
+
+	progress.Articles = int32(post.Articles)
+	progress.Failed = int32(post.Failed)
+	if current := post.Progress; current != nil {
+		progress.Phase = current.Phase
+		progress.Overall = current.Overall
+		progress.Bytes = current.Bytes
+		progress.TotalBytes = current.Total
+		progress.Speed = current.Speed
+		progress.Eta = durationpb.New(time.Duration(current.ETA * float64(time.Second)))
+	}
+	for _, conn := range post.Connections {
+		progress.Connections = append(progress.Connections, &api.Connection{
+			Id:       int32(conn.ID),
+			Server:   conn.Server,
+			Articles: int32(conn.Articles),
+			Bytes:    conn.Bytes,
+			Speed:    conn.Speed,
+		})
+	}
+	return progress
+}
+
+// finished reports whether a job in state is out of the queue for good, or
+// until retried
+func finished(state queue.State) bool {
+	return state == queue.StateDone || state == queue.StateFailed || state == queue.StateCancelled
+}
+
+// jobStates maps the states of the queue to those of the API
+var jobStates = map[queue.State]api.JobState{
+	queue.StatePending:   api.JobState_JOB_STATE_PENDING,
+	queue.StateRunning:   api.JobState_JOB_STATE_RUNNING,
+	queue.StateDone:      api.JobState_JOB_STATE_DONE,
+	queue.StateFailed:    api.JobState_JOB_STATE_FAILED,
+	queue.StateCancelled: api.JobState_JOB_STATE_CANCELLED,
+}
+
+// toJob converts a queued job
+func toJob(job *queue.Job) *api.Job {
+	return &api.Job{
+		Id:        job.ID,
+		Path:      job.Path,
+		Group:     job.Group,
+		State:     jobStates[job.State],
+		Attempts:  int32(job.Attempts),
+		Error:     job.Error,
+		NzbPath:   job.NZBPath,
+		PostId:    job.PostID,
+		CreatedAt: timestamppb.New(job.CreatedAt),
+		UpdatedAt: timestamppb.New(job.UpdatedAt),
+	}
+}
+
+// storeError converts an error of the queue to a gRPC status
+func storeError(err error) error {
+	if errors.Is(err, queue.ErrNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
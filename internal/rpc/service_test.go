@@ -0,0 +1,160 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"ypost/internal/queue"
+	"ypost/pkg/api"
+)
+
+// testRunner records what the service asks of the runner
+type testRunner struct {
+	woken     int
+	cancelled []uint64
+}
+
+func (r *testRunner) Wake()            { r.woken++ }
+func (r *testRunner) Cancel(id uint64) { r.cancelled = append(r.cancelled, id) }
+
+// serveTest serves a service over a fresh queue and returns a client of it
+func serveTest(t *testing.T, token string) (api.YpostClient, *queue.Store, *testRunner) {
+	t.Helper()
+	store, err := queue.Open(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := &testRunner{}
+	server, err := Listen("127.0.0.1:0", NewService(store, runner, nil), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	conn, err := grpc.NewClient(server.Addr(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return api.NewYpostClient(conn), store, runner
+}
+
+func TestSubmitAndCancel(t *testing.T) {
+	client, store, runner := serveTest(t, "")
+	ctx := context.Background()
+
+	job, err := client.SubmitJob(ctx, &api.SubmitJobRequest{Path: t.TempDir(), Group: "alt.binaries.test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != api.JobState_JOB_STATE_PENDING || job.Group != "alt.binaries.test" || runner.woken != 1 {
+		t.Fatalf("unexpected job %v, woken %d", job, runner.woken)
+	}
+	if queued, err := store.Get(job.Id); err != nil || queued.State != queue.StatePending {
+		t.Fatalf("job not queued: %+v, %v", queued, err)
+	}
+
+	for path, code := range map[string]codes.Code{"relative/file.bin": codes.InvalidArgument, "/does/not/exist": codes.NotFound} {
+		if _, err := client.SubmitJob(ctx, &api.SubmitJobRequest{Path: path}); status.Code(err) != code {
+			t.Errorf("%s: expected %v, got %v", path, code, err)
+		}
+	}
+
+	cancelled, err := client.CancelJob(ctx, &api.CancelJobRequest{JobId: job.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.State != api.JobState_JOB_STATE_CANCELLED || len(runner.cancelled) != 1 {
+		t.Errorf("unexpected cancelled job %v, runner %v", cancelled, runner.cancelled)
+	}
+	if _, err := client.CancelJob(ctx, &api.CancelJobRequest{JobId: job.Id}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected a cancelled job not to be cancelled again, got %v", err)
+	}
+	if _, err := client.CancelJob(ctx, &api.CancelJobRequest{JobId: job.Id + 1}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected an unknown job not found, got %v", err)
+	}
+}
+
+func TestGetNZBAndWatch(t *testing.T) {
+	client, store, _ := serveTest(t, "")
+	ctx := context.Background()
+
+	job, err := store.Add("/data/file.bin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetNZB(ctx, &api.GetNZBRequest{JobId: job.ID}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected no NZB for a pending job, got %v", err)
+	}
+
+	nzbPath := filepath.Join(t.TempDir(), "file.nzb")
+	if err := os.WriteFile(nzbPath, []byte("<nzb/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Claim(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Complete(job.ID, nzbPath); err != nil {
+		t.Fatal(err)
+	}
+
+	nzb, err := client.GetNZB(ctx, &api.GetNZBRequest{JobId: job.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nzb.Name != "file.nzb" || string(nzb.Content) != "<nzb/>" {
+		t.Errorf("unexpected NZB %v", nzb)
+	}
+
+	// The progress of a finished job is sent once
+	stream, err := client.WatchProgress(ctx, &api.WatchProgressRequest{JobId: job.ID, Interval: durationpb.New(time.Millisecond)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Job.State != api.JobState_JOB_STATE_DONE || progress.Job.NzbPath != nzbPath {
+		t.Errorf("unexpected progress %v", progress)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("expected the stream to end, got %v", err)
+	}
+}
+
+func TestToken(t *testing.T) {
+	client, _, _ := serveTest(t, "s3cret")
+	request := &api.GetNZBRequest{JobId: 1}
+
+	if _, err := client.GetNZB(context.Background(), request); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected a call without token refused, got %v", err)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer wrong")
+	if _, err := client.GetNZB(ctx, request); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected a call with a wrong token refused, got %v", err)
+	}
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer s3cret")
+	if _, err := client.GetNZB(ctx, request); status.Code(err) != codes.NotFound {
+		t.Errorf("expected an unknown job not found, got %v", err)
+	}
+
+	stream, err := client.WatchProgress(context.Background(), &api.WatchProgressRequest{JobId: 1})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected a stream without token refused, got %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package scaling
+
+import (
+	"sync"
+	"time"
+
+	"ypost/internal/logger"
+)
+
+// Interval is how often the scaler measures the throughput and adjusts
+const Interval = 2 * time.Second
+
+// minGain is the share of a connection's throughput the throughput must grow
+// by for one more connection to be kept
+const minGain = 0.5
+
+// Scaler adjusts the number of connections posting at once. While articles
+// wait for a connection it adds one at a time, keeping it when the throughput
+// grows with it; once one does not help, or a server throttles, it drops back
+// and never goes past that count again.
+type Scaler struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	// active connections post, the others wait; ceiling bounds active
+	active  int
+	ceiling int
+	stopped bool
+	done    chan struct{}
+	log     logger.Interface
+
+	// bytes are posted since the last adjustment
+	bytes int64
+	// grown is whether the last adjustment added a connection, and speed
+	// the throughput before it
+	grown bool
+	speed float64
+}
+
+// New creates a scaler starting start connections of up to limit
+func New(start, limit int) *Scaler {
+	limit = max(limit, 1)
+	s := &Scaler{
+		active:  min(max(start, 1), limit),
+		ceiling: limit,
+		log:     logger.Discard,
+		done:    make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// SetLogger sets where the changes of the number of connections are reported
+func (s *Scaler) SetLogger(log logger.Interface) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.log = log
+}
+
+// Active returns the number of connections posting
+func (s *Scaler) Active() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active
+}
+
+// Wait blocks connection id, numbered from 0, while it is not among the
+// active ones. It returns false once the scaler is stopped.
+func (s *Scaler) Wait(id int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id >= s.active && !s.stopped {
+		s.cond.Wait()
+	}
+	return !s.stopped
+}
+
+// Posted counts the bytes of an article posted
+func (s *Scaler) Posted(bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bytes += bytes
+}
+
+// Throttled drops a connection after a server refused more of them
+func (s *Scaler) Throttled() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active <= 1 {
+		return
+	}
+	s.active--
+	s.ceiling = s.active
+	s.grown = false
+	s.log.Warn("Server throttling, posting with %d connections", s.active)
+}
+
+// Adjust measures the throughput over elapsed, with waiting articles ready
+// for a connection, then adds or drops a connection
+func (s *Scaler) Adjust(elapsed time.Duration, waiting int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	speed := float64(s.bytes) / elapsed.Seconds()
+	s.bytes = 0
+	if s.grown {
+		s.grown = false
+		// The last connection added must bring its share of the throughput
+		if before := s.active - 1; speed < s.speed*(1+minGain/float64(before)) {
+			s.active = before
+			s.ceiling = before
+			s.log.Info("Posting no faster with %d connections, keeping %d", before+1, before)
+			return
+		}
+	}
+	if waiting > 0 && s.active < s.ceiling {
+		s.speed = speed
+		s.grown = true
+		s.active++
+		s.log.Debug("Articles waiting for a connection, posting with %d", s.active)
+		s.cond.Broadcast()
+	}
+}
+
+// Run adjusts every Interval, with waiting giving the articles ready for a
+// connection, until the scaler is stopped
+func (s *Scaler) Run(waiting func() int) {
+	ticker := time.NewTicker(Interval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			s.Adjust(now.Sub(last), waiting())
+			last = now
+		}
+	}
+}
+
+// Stop releases the waiting connections, whose Wait returns false
+func (s *Scaler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.done)
+		s.cond.Broadcast()
+	}
+}
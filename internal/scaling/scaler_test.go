@@ -0,0 +1,84 @@
+package scaling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScalerGrowsWhileFaster(t *testing.T) {
+	scaler := New(1, 4)
+
+	// Each connection posts 100 bytes a second, until the third
+	perConnection := []float64{100, 100, 100, 20}
+	for step := 0; step < 5; step++ {
+		var speed float64
+		for i := 0; i < scaler.Active(); i++ {
+			speed += perConnection[i]
+		}
+		scaler.Posted(int64(speed))
+		scaler.Adjust(time.Second, 3)
+	}
+	// The fourth connection was tried, then dropped for good
+	if active := scaler.Active(); active != 3 {
+		t.Errorf("expected 3 connections, got %d", active)
+	}
+}
+
+func TestScalerHoldsWithoutWaitingArticles(t *testing.T) {
+	scaler := New(2, 8)
+	scaler.Posted(1000)
+	scaler.Adjust(time.Second, 0)
+	if active := scaler.Active(); active != 2 {
+		t.Errorf("expected 2 connections without articles waiting, got %d", active)
+	}
+}
+
+func TestScalerThrottled(t *testing.T) {
+	scaler := New(4, 8)
+	scaler.Throttled()
+	if active := scaler.Active(); active != 3 {
+		t.Fatalf("expected 3 connections once throttled, got %d", active)
+	}
+	// Never back past the count the server throttled
+	for step := 0; step < 3; step++ {
+		scaler.Posted(int64(1000 * (step + 1)))
+		scaler.Adjust(time.Second, 5)
+	}
+	if active := scaler.Active(); active != 3 {
+		t.Errorf("expected to stay at 3 connections, got %d", active)
+	}
+	// The last connection is kept
+	for i := 0; i < 5; i++ {
+		scaler.Throttled()
+	}
+	if active := scaler.Active(); active != 1 {
+		t.Errorf("expected 1 connection left, got %d", active)
+	}
+}
+
+func TestScalerWait(t *testing.T) {
+	scaler := New(1, 2)
+	if !scaler.Wait(0) {
+		t.Fatal("expected the first connection active")
+	}
+
+	released := make(chan bool)
+	go func() { released <- scaler.Wait(1) }()
+	select {
+	case <-released:
+		t.Fatal("expected the second connection to wait")
+	case <-time.After(20 * time.Millisecond):
+	}
+	scaler.Adjust(time.Second, 1)
+	if !<-released {
+		t.Error("expected the second connection to post once added")
+	}
+
+	// Stopping releases the connections still waiting
+	scaler = New(1, 2)
+	go func() { released <- scaler.Wait(1) }()
+	scaler.Stop()
+	if <-released {
+		t.Error("expected Wait to report the stop")
+	}
+}
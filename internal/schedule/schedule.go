@@ -0,0 +1,155 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ParseClock parses a time of day written HH:MM into the time since
+// midnight
+func ParseClock(clock string) (time.Duration, error) {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q (expected HH:MM)", clock)
+	}
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
+
+// At returns the next time at or after now that a post set to start at
+// value begins: a time of day (HH:MM) is the next occurrence of it, a date
+// and time ("2006-01-02 15:04" or RFC 3339) is taken as is.
+func At(value string, now time.Time) (time.Time, error) {
+	if clock, err := ParseClock(value); err == nil {
+		return nextClock(clock, now), nil
+	}
+	if at, err := time.ParseInLocation("2006-01-02 15:04", value, now.Location()); err == nil {
+		return at, nil
+	}
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid start time %q (expected HH:MM, \"2006-01-02 15:04\" or RFC 3339)", value)
+}
+
+// nextClock returns the next time at or after now that the wall clock of
+// now's location shows clock
+func nextClock(clock time.Duration, now time.Time) time.Time {
+	hour, minute := int(clock/time.Hour), int(clock%time.Hour/time.Minute)
+	at := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if at.Before(now) {
+		at = time.Date(now.Year(), now.Month(), now.Day()+1, hour, minute, 0, 0, now.Location())
+	}
+	return at
+}
+
+// clockOf returns the wall clock time of t since midnight
+func clockOf(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+}
+
+// Window is a daily period, from Start to End past midnight; an End
+// before Start wraps past midnight
+type Window struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// ParseWindow parses the start and end of a window written HH:MM. Both
+// empty mean no window, returned as nil.
+func ParseWindow(start string, end string) (*Window, error) {
+	if start == "" && end == "" {
+		return nil, nil
+	}
+	if start == "" || end == "" {
+		return nil, fmt.Errorf("a posting window needs both a start and an end")
+	}
+	var window Window
+	var err error
+	if window.Start, err = ParseClock(start); err != nil {
+		return nil, err
+	}
+	if window.End, err = ParseClock(end); err != nil {
+		return nil, err
+	}
+	if window.Start == window.End {
+		return nil, fmt.Errorf("the posting window starts and ends at %s", start)
+	}
+	return &window, nil
+}
+
+// Contains reports whether t is within the window
+func (w *Window) Contains(t time.Time) bool {
+	clock := clockOf(t)
+	if w.Start < w.End {
+		return clock >= w.Start && clock < w.End
+	}
+	return clock >= w.Start || clock < w.End
+}
+
+// Next returns t when the window is open at t, or the time it next opens
+func (w *Window) Next(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	return nextClock(w.Start, t)
+}
+
+// String renders the window as HH:MM-HH:MM
+func (w *Window) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return clock(w.Start) + "-" + clock(w.End)
+}
+
+// Sleep waits until t or until ctx is done
+func Sleep(ctx context.Context, t time.Time) error {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Gate holds workers back while a window is closed
+type Gate struct {
+	window *Window
+	// paused is called once per pause, with the time the window opens
+	paused func(until time.Time)
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+// NewGate returns a gate for window; a nil window never closes
+func NewGate(window *Window, paused func(until time.Time)) *Gate {
+	return &Gate{window: window, paused: paused}
+}
+
+// Wait returns once the window is open, or when ctx is done
+func (g *Gate) Wait(ctx context.Context) error {
+	if g == nil || g.window == nil {
+		return nil
+	}
+	now := time.Now()
+	next := g.window.Next(now)
+	if !next.After(now) {
+		return nil
+	}
+
+	g.mu.Lock()
+	if !g.pausedUntil.Equal(next) {
+		g.pausedUntil = next
+		if g.paused != nil {
+			g.paused(next)
+		}
+	}
+	g.mu.Unlock()
+	return Sleep(ctx, next)
+}
@@ -0,0 +1,107 @@
+package schedule
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAt(t *testing.T) {
+	now := time.Date(2024, 3, 10, 14, 30, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"16:00":                time.Date(2024, 3, 10, 16, 0, 0, 0, time.UTC),
+		"02:00":                time.Date(2024, 3, 11, 2, 0, 0, 0, time.UTC),
+		"14:30":                now,
+		"2024-03-12 01:15":     time.Date(2024, 3, 12, 1, 15, 0, 0, time.UTC),
+		"2024-03-12T01:15:00Z": time.Date(2024, 3, 12, 1, 15, 0, 0, time.UTC),
+	}
+	for value, expected := range tests {
+		at, err := At(value, now)
+		if err != nil {
+			t.Errorf("%q: %v", value, err)
+			continue
+		}
+		if !at.Equal(expected) {
+			t.Errorf("%q: expected %s, got %s", value, expected, at)
+		}
+	}
+	for _, value := range []string{"", "25:00", "2pm", "tomorrow"} {
+		if _, err := At(value, now); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestWindow(t *testing.T) {
+	day := func(hour, minute int) time.Time {
+		return time.Date(2024, 3, 10, hour, minute, 0, 0, time.UTC)
+	}
+
+	night, err := ParseWindow("22:00", "06:30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if night.String() != "22:00-06:30" {
+		t.Errorf("unexpected window %s", night)
+	}
+	for _, open := range []time.Time{day(22, 0), day(23, 59), day(0, 0), day(6, 29)} {
+		if !night.Contains(open) || !night.Next(open).Equal(open) {
+			t.Errorf("expected the window to be open at %s", open.Format("15:04"))
+		}
+	}
+	if night.Contains(day(6, 30)) || night.Contains(day(12, 0)) {
+		t.Error("expected the window to be closed during the day")
+	}
+	if next := night.Next(day(12, 0)); !next.Equal(day(22, 0)) {
+		t.Errorf("expected the window to open at 22:00, got %s", next)
+	}
+
+	office, err := ParseWindow("09:00", "17:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := office.Next(day(18, 0)); !next.Equal(day(9, 0).AddDate(0, 0, 1)) {
+		t.Errorf("expected the window to open the next morning, got %s", next)
+	}
+
+	if window, err := ParseWindow("", ""); window != nil || err != nil {
+		t.Errorf("expected no window, got %v, %v", window, err)
+	}
+	for _, bounds := range [][2]string{{"09:00", ""}, {"9", "17:00"}, {"09:00", "09:00"}} {
+		if _, err := ParseWindow(bounds[0], bounds[1]); err == nil {
+			t.Errorf("%v: expected an error", bounds)
+		}
+	}
+}
+
+func TestGate(t *testing.T) {
+	var nilGate *Gate
+	if err := nilGate.Wait(context.Background()); err != nil {
+		t.Errorf("expected a nil gate to be open, got %v", err)
+	}
+	if err := NewGate(nil, nil).Wait(context.Background()); err != nil {
+		t.Errorf("expected a gate without window to be open, got %v", err)
+	}
+
+	// A window that opened a minute ago and closes in two is open
+	now := time.Now()
+	open := &Window{Start: clockOf(now.Add(-time.Minute)).Truncate(time.Minute), End: clockOf(now.Add(2 * time.Minute)).Truncate(time.Minute)}
+	if err := NewGate(open, nil).Wait(context.Background()); err != nil {
+		t.Errorf("expected an open window not to wait, got %v", err)
+	}
+
+	// A closed window waits until cancelled, reporting the pause once
+	closed := &Window{Start: clockOf(now.Add(time.Hour)).Truncate(time.Minute), End: clockOf(now.Add(2 * time.Hour)).Truncate(time.Minute)}
+	pauses := 0
+	gate := NewGate(closed, func(time.Time) { pauses++ })
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		if err := gate.Wait(ctx); err == nil {
+			t.Error("expected the wait to end with the context")
+		}
+	}
+	if pauses != 1 {
+		t.Errorf("expected the pause to be reported once, got %d", pauses)
+	}
+}
@@ -7,21 +7,49 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 )
 
 // Generator handles SFV checksum file generation
 type Generator struct {
 	outputDir string
+	known     map[string]uint32
+	names     map[string]string
+	workers   int
 }
 
 // NewGenerator creates a new SFV generator
 func NewGenerator(outputDir string) *Generator {
 	return &Generator{
 		outputDir: outputDir,
+		known:     make(map[string]uint32),
+		names:     make(map[string]string),
+		workers:   runtime.NumCPU(),
 	}
 }
 
+// SetWorkers sets the number of files checksummed at once
+func (g *Generator) SetWorkers(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	g.workers = workers
+}
+
+// SetEntryName sets the name a file is listed under, e.g. the relative path
+// it is posted as
+func (g *Generator) SetEntryName(filePath string, name string) {
+	g.names[filePath] = name
+}
+
+// AddChecksum records an already computed CRC32 for a file so CreateSFV does
+// not read it again
+func (g *Generator) AddChecksum(filePath string, crc uint32) {
+	g.known[filePath] = crc
+}
+
 // CreateSFV creates an SFV file for the given file(s)
 func (g *Generator) CreateSFV(filePaths []string, sfvName string) (string, error) {
 	if err := os.MkdirAll(g.outputDir, 0755); err != nil {
@@ -44,18 +72,23 @@ func (g *Generator) CreateSFV(filePaths []string, sfvName string) (string, error
 		return "", fmt.Errorf("failed to write SFV header: %w", err)
 	}
 
-	// Calculate and write checksums for each file
-	for _, filePath := range filePaths {
-		checksum, err := g.calculateCRC32(filePath)
-		if err != nil {
-			return "", fmt.Errorf("failed to calculate checksum for %s: %w", filePath, err)
-		}
+	checksums, err := g.checksumsFor(filePaths)
+	if err != nil {
+		return "", err
+	}
+
+	// Write checksums for each file
+	for i, filePath := range filePaths {
+		checksum := checksums[i]
 
-		// Use relative path for SFV entry
+		// Use relative path for SFV entry, files outside the output directory by name
 		relPath, err := filepath.Rel(g.outputDir, filePath)
-		if err != nil {
+		if err != nil || strings.HasPrefix(relPath, "..") {
 			relPath = filepath.Base(filePath)
 		}
+		if name, ok := g.names[filePath]; ok {
+			relPath = name
+		}
 
 		_, err = writer.WriteString(fmt.Sprintf("%s %08X\n", relPath, checksum))
 		if err != nil {
@@ -66,6 +99,46 @@ func (g *Generator) CreateSFV(filePaths []string, sfvName string) (string, error
 	return sfvPath, nil
 }
 
+// checksumsFor returns the CRC32 of each file, using the recorded ones and
+// calculating the others in parallel
+func (g *Generator) checksumsFor(filePaths []string) ([]uint32, error) {
+	checksums := make([]uint32, len(filePaths))
+	errs := make([]error, len(filePaths))
+	g.parallel(len(filePaths), func(i int) {
+		if crc, ok := g.known[filePaths[i]]; ok {
+			checksums[i] = crc
+			return
+		}
+		checksums[i], errs[i] = g.calculateCRC32(filePaths[i])
+	})
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate checksum for %s: %w", filePaths[i], err)
+		}
+	}
+	return checksums, nil
+}
+
+// parallel calls work for 0 to n-1 on the generator's workers
+func (g *Generator) parallel(n int, work func(i int)) {
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < g.workers && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				work(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
 // calculateCRC32 calculates the CRC32 checksum of a file
 func (g *Generator) calculateCRC32(filePath string) (uint32, error) {
 	file, err := os.Open(filePath)
@@ -133,6 +206,76 @@ func (g *Generator) VerifySFV(sfvPath string) (bool, error) {
 	return allValid, nil
 }
 
+// Entry is the verification of a file listed in an SFV file
+type Entry struct {
+	Name     string `json:"name"`
+	Expected string `json:"expected"`
+	// Actual is the checksum of the file, empty when it could not be read
+	Actual string `json:"actual,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// OK reports whether the file matches its listed checksum
+func (e Entry) OK() bool {
+	return e.Actual != "" && strings.EqualFold(e.Actual, e.Expected)
+}
+
+// Missing reports whether the file could not be read
+func (e Entry) Missing() bool {
+	return e.Actual == ""
+}
+
+// Verify checks every file listed in an SFV file, relative to its directory,
+// in parallel and returns the entries in file order. Unreadable files are
+// reported in their entry rather than as an error.
+func (g *Generator) Verify(sfvPath string) ([]Entry, error) {
+	lines, err := readEntries(sfvPath)
+	if err != nil {
+		return nil, err
+	}
+
+	sfvDir := filepath.Dir(sfvPath)
+	entries := make([]Entry, len(lines))
+	g.parallel(len(lines), func(i int) {
+		entries[i] = Entry{Name: lines[i].name, Expected: strings.ToUpper(lines[i].checksum)}
+		actual, err := g.calculateCRC32(filepath.Join(sfvDir, filepath.FromSlash(lines[i].name)))
+		if err != nil {
+			entries[i].Error = err.Error()
+			return
+		}
+		entries[i].Actual = fmt.Sprintf("%08X", actual)
+	})
+	return entries, nil
+}
+
+// VerifyFiles checks the given files against the entries of an SFV file and
+// returns the names that are listed with a different checksum. Files without
+// an entry are ignored.
+func (g *Generator) VerifyFiles(sfvPath string, filePaths []string) ([]string, error) {
+	checksums, err := g.ReadSFV(sfvPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var mismatched []string
+	for _, filePath := range filePaths {
+		expected, ok := checksums[filepath.Base(filePath)]
+		if !ok {
+			continue
+		}
+
+		actual, err := g.calculateCRC32(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify %s: %w", filePath, err)
+		}
+		if !strings.EqualFold(fmt.Sprintf("%08X", actual), expected) {
+			mismatched = append(mismatched, filepath.Base(filePath))
+		}
+	}
+
+	return mismatched, nil
+}
+
 // CreateSFVForDirectory creates an SFV file for all files in a directory
 func (g *Generator) CreateSFVForDirectory(dirPath string, recursive bool) (string, error) {
 	var filePaths []string
@@ -167,39 +310,59 @@ func (g *Generator) CreateSFVForDirectory(dirPath string, recursive bool) (strin
 
 // ReadSFV reads an SFV file and returns the checksums
 func (g *Generator) ReadSFV(sfvPath string) (map[string]string, error) {
+	lines, err := readEntries(sfvPath)
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := make(map[string]string)
+	for _, line := range lines {
+		checksums[line.name] = line.checksum
+	}
+	return checksums, nil
+}
+
+// sfvLine is an entry of an SFV file
+type sfvLine struct {
+	name     string
+	checksum string
+}
+
+// readEntries returns the entries of an SFV file in order. The checksum is
+// the last field of a line, so names may contain spaces.
+func readEntries(sfvPath string) ([]sfvLine, error) {
 	file, err := os.Open(sfvPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open SFV file: %w", err)
 	}
 	defer file.Close()
 
-	checksums := make(map[string]string)
+	var lines []sfvLine
 	scanner := bufio.NewScanner(file)
-	
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
-		
+
 		// Skip empty lines and comments
 		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
 			continue
 		}
-		
-		// Parse SFV entry
-		parts := strings.Fields(line)
-		if len(parts) != 2 {
+
+		separator := strings.LastIndexAny(line, " \t")
+		if separator < 0 {
 			continue
 		}
-		
-		fileName := parts[0]
-		checksum := parts[1]
-		checksums[fileName] = checksum
+		name := strings.TrimSpace(line[:separator])
+		checksum := line[separator+1:]
+		if name == "" || len(checksum) != 8 {
+			continue
+		}
+		lines = append(lines, sfvLine{name: name, checksum: checksum})
 	}
-	
+
 	if err := scanner.Err(); err != nil {
 		return nil, fmt.Errorf("failed to read SFV file: %w", err)
 	}
-	
-	return checksums, nil
+	return lines, nil
 }
 
 // UpdateSFV updates an existing SFV file with new checksums
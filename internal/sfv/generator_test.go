@@ -0,0 +1,54 @@
+package sfv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for _, name := range []string{"a.bin", "b.bin", "with space.bin", "d.bin"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("content of "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+
+	generator := NewGenerator(dir)
+	generator.SetWorkers(3)
+	sfvPath, err := generator.CreateSFV(files, "set.sfv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Damage one file and remove another
+	if err := os.WriteFile(files[1], []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(files[3]); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := generator.Verify(sfvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %+v", entries)
+	}
+	if entries[0].Name != "a.bin" || !entries[0].OK() {
+		t.Errorf("expected a.bin to match, got %+v", entries[0])
+	}
+	if entries[1].OK() || entries[1].Missing() {
+		t.Errorf("expected b.bin to mismatch, got %+v", entries[1])
+	}
+	if entries[2].Name != "with space.bin" || !entries[2].OK() {
+		t.Errorf("expected the name with a space to match, got %+v", entries[2])
+	}
+	if !entries[3].Missing() || entries[3].Error == "" {
+		t.Errorf("expected d.bin to be missing, got %+v", entries[3])
+	}
+}
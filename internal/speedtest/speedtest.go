@@ -0,0 +1,169 @@
+package speedtest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ypost/internal/yenc"
+)
+
+// Conn is a poster connection
+type Conn interface {
+	PostArticle(group string, subject string, from string, body string, headers map[string]string) (string, error)
+	Quit() error
+}
+
+// Options configures a measurement
+type Options struct {
+	// Group receives the throwaway articles
+	Group string
+	From  string
+	// ArticleSize is the size of the data of each article before encoding
+	ArticleSize int
+	// Duration is how long articles are posted
+	Duration time.Duration
+}
+
+// Result is the throughput measured with a number of connections
+type Result struct {
+	Connections int `json:"connections"`
+	Articles    int `json:"articles"`
+	// Bytes counts the encoded article bodies posted
+	Bytes    int64         `json:"bytes"`
+	Duration time.Duration `json:"duration"`
+	Errors   int           `json:"errors"`
+}
+
+// BytesPerSecond returns the throughput of all connections
+func (r Result) BytesPerSecond() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Duration.Seconds()
+}
+
+// PerConnection returns the throughput of one connection
+func (r Result) PerConnection() float64 {
+	if r.Connections == 0 {
+		return 0
+	}
+	return r.BytesPerSecond() / float64(r.Connections)
+}
+
+// Run posts throwaway articles over connections connections for the
+// duration of opts and measures the throughput. The connections are opened
+// before the clock starts. A connection failing mid-run is reopened; the run
+// fails when no article could be posted at all.
+func Run(ctx context.Context, dial func() (Conn, error), connections int, opts Options) (Result, error) {
+	if connections < 1 {
+		connections = 1
+	}
+	result := Result{Connections: connections}
+
+	conns := make([]Conn, 0, connections)
+	defer func() {
+		for _, conn := range conns {
+			conn.Quit()
+		}
+	}()
+	for i := 0; i < connections; i++ {
+		conn, err := dial()
+		if err != nil {
+			return result, fmt.Errorf("failed to open connection %d: %w", i+1, err)
+		}
+		conns = append(conns, conn)
+	}
+
+	data := make([]byte, opts.ArticleSize)
+	if _, err := rand.Read(data); err != nil {
+		return result, fmt.Errorf("failed to create test data: %w", err)
+	}
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return result, fmt.Errorf("failed to create test data: %w", err)
+	}
+	name := "ypost-speedtest-" + hex.EncodeToString(id) + ".bin"
+	var encoder yenc.Encoder
+	body := encoder.Encode(data, yenc.Article{Name: name})
+	// Test articles are of no use to anyone, keep them out of archives
+	headers := map[string]string{"X-No-Archive": "yes"}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+
+	var articles, failures atomic.Int64
+	var number atomic.Int64
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range conns {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				n := number.Add(1)
+				subject := fmt.Sprintf("\"%s\" yEnc (%d/1) test", name, n)
+				if _, err := conns[i].PostArticle(opts.Group, subject, opts.From, body, headers); err == nil {
+					articles.Add(1)
+					continue
+				}
+				failures.Add(1)
+
+				// Start over on a new connection
+				conns[i].Quit()
+				conn, err := dial()
+				if err != nil {
+					conns[i] = nopConn{}
+					return
+				}
+				conns[i] = conn
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	result.Duration = time.Since(start)
+	result.Articles = int(articles.Load())
+	result.Errors = int(failures.Load())
+	result.Bytes = int64(result.Articles) * int64(len(body))
+	if result.Articles == 0 && result.Errors > 0 {
+		return result, fmt.Errorf("no article could be posted (%d errors)", result.Errors)
+	}
+	return result, nil
+}
+
+// nopConn stands in for a connection that could not be reopened
+type nopConn struct{}
+
+func (nopConn) PostArticle(string, string, string, string, map[string]string) (string, error) {
+	return "", fmt.Errorf("not connected")
+}
+
+func (nopConn) Quit() error { return nil }
+
+// Recommend returns the result with the fewest connections whose throughput
+// is within 5% of the best one; more connections than that add load on the
+// server without making posts faster
+func Recommend(results []Result) (Result, bool) {
+	if len(results) == 0 {
+		return Result{}, false
+	}
+	best := 0.0
+	for _, result := range results {
+		if rate := result.BytesPerSecond(); rate > best {
+			best = rate
+		}
+	}
+	var recommended Result
+	found := false
+	for _, result := range results {
+		if result.BytesPerSecond() >= best*0.95 && (!found || result.Connections < recommended.Connections) {
+			recommended, found = result, true
+		}
+	}
+	return recommended, found
+}
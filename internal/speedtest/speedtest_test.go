@@ -0,0 +1,78 @@
+package speedtest
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeConn accepts every article after a short delay, failing the first
+// fail ones
+type fakeConn struct {
+	posted *atomic.Int64
+	fail   *atomic.Int64
+}
+
+func (c *fakeConn) PostArticle(group, subject, from, body string, headers map[string]string) (string, error) {
+	time.Sleep(time.Millisecond)
+	if c.fail.Add(-1) >= 0 {
+		return "", errors.New("connection reset")
+	}
+	if group != "alt.binaries.test" || headers["X-No-Archive"] != "yes" {
+		return "", errors.New("unexpected article")
+	}
+	c.posted.Add(1)
+	return "<id@test>", nil
+}
+
+func (c *fakeConn) Quit() error { return nil }
+
+func TestRun(t *testing.T) {
+	var posted, fail, dials atomic.Int64
+	fail.Store(2)
+	dial := func() (Conn, error) {
+		dials.Add(1)
+		return &fakeConn{posted: &posted, fail: &fail}, nil
+	}
+
+	result, err := Run(context.Background(), dial, 3, Options{Group: "alt.binaries.test", ArticleSize: 1000, Duration: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Connections != 3 || result.Articles == 0 || int64(result.Articles) != posted.Load() {
+		t.Errorf("unexpected result %+v (posted %d)", result, posted.Load())
+	}
+	if result.Errors != 2 || dials.Load() != 5 {
+		t.Errorf("expected 2 errors and 2 redials, got %d errors and %d dials", result.Errors, dials.Load())
+	}
+	if result.Bytes <= int64(result.Articles)*1000 || result.BytesPerSecond() <= 0 {
+		t.Errorf("expected the encoded bytes to be counted, got %d for %d articles", result.Bytes, result.Articles)
+	}
+}
+
+func TestRunFailsWithoutArticles(t *testing.T) {
+	dial := func() (Conn, error) {
+		return nil, errors.New("refused")
+	}
+	if _, err := Run(context.Background(), dial, 1, Options{ArticleSize: 10, Duration: time.Millisecond}); err == nil {
+		t.Fatal("expected an error when no connection can be opened")
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	results := []Result{
+		{Connections: 1, Bytes: 100, Duration: time.Second},
+		{Connections: 2, Bytes: 190, Duration: time.Second},
+		{Connections: 4, Bytes: 200, Duration: time.Second},
+		{Connections: 8, Bytes: 198, Duration: time.Second},
+	}
+	recommended, ok := Recommend(results)
+	if !ok || recommended.Connections != 2 {
+		t.Errorf("expected 2 connections, got %+v", recommended)
+	}
+	if _, ok := Recommend(nil); ok {
+		t.Error("expected no recommendation without results")
+	}
+}
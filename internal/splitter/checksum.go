@@ -0,0 +1,42 @@
+package splitter
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+	"lukechampine.com/blake3"
+)
+
+// Checksum algorithms for part integrity
+const (
+	ChecksumCRC32  = "crc32"
+	ChecksumXXHash = "xxhash"
+	ChecksumBLAKE3 = "blake3"
+	ChecksumSHA256 = "sha256"
+)
+
+// newHash returns a fresh hash for the named algorithm
+func newHash(algorithm string) (hash.Hash, error) {
+	switch strings.ToLower(algorithm) {
+	case "", ChecksumCRC32:
+		return crc32.NewIEEE(), nil
+	case ChecksumXXHash:
+		return xxhash.New(), nil
+	case ChecksumBLAKE3:
+		return blake3.New(32, nil), nil
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	default:
+		return nil, fmt.Errorf("unknown checksum algorithm %q (expected crc32, xxhash, blake3 or sha256)", algorithm)
+	}
+}
+
+// ValidateChecksumAlgorithm reports whether algorithm is supported
+func ValidateChecksumAlgorithm(algorithm string) error {
+	_, err := newHash(algorithm)
+	return err
+}
@@ -0,0 +1,39 @@
+package splitter
+
+import (
+	"context"
+	"hash/crc32"
+	"testing"
+)
+
+func TestChecksumAlgorithms(t *testing.T) {
+	tempDir := t.TempDir()
+	source, data := writeTestFile(t, tempDir, "test.bin", 2500)
+
+	for _, algorithm := range []string{ChecksumCRC32, ChecksumXXHash, ChecksumBLAKE3, ChecksumSHA256} {
+		split := NewSplitter(1000)
+		if err := split.SetChecksumAlgorithm(algorithm); err != nil {
+			t.Fatalf("Unexpected error for %s: %v", algorithm, err)
+		}
+
+		parts, err := split.Split(context.Background(), source, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parts[0].Checksum == "" || parts[0].Checksum == parts[1].Checksum {
+			t.Errorf("%s: unexpected part checksums %q / %q", algorithm, parts[0].Checksum, parts[1].Checksum)
+		}
+		if err := split.ValidateParts(parts); err != nil {
+			t.Errorf("%s: %v", algorithm, err)
+		}
+
+		// The whole-file CRC32 comes from the same read pass
+		if crc := split.FileCRCs()[source]; crc != crc32.ChecksumIEEE(data) {
+			t.Errorf("%s: file CRC32 %08X does not match %08X", algorithm, crc, crc32.ChecksumIEEE(data))
+		}
+	}
+
+	if err := NewSplitter(1000).SetChecksumAlgorithm("md4"); err == nil {
+		t.Error("Expected error for unknown algorithm")
+	}
+}
@@ -0,0 +1,121 @@
+package splitter
+
+import (
+	"fmt"
+	"hash/crc32"
+	"sync"
+
+	"ypost/pkg/models"
+)
+
+// crcBlock is the size of the reads of a chunk, each checksummed while it is
+// still in the CPU cache
+const crcBlock = 64 << 10
+
+// ChunkReader reads the articles of parts one chunk at a time, so only the
+// chunks being posted are in memory. Each part is opened on its first chunk
+// and closed once its planned chunks were all read.
+type ChunkReader struct {
+	chunkSize int
+
+	mu    sync.Mutex
+	parts map[*models.FilePart]*openPart
+}
+
+// openPart is a part being read and the number of its chunks left to read
+type openPart struct {
+	reader *PartReader
+	left   int
+}
+
+// NewChunkReader creates a reader of chunks of chunkSize bytes
+func NewChunkReader(chunkSize int) *ChunkReader {
+	return &ChunkReader{chunkSize: chunkSize, parts: make(map[*models.FilePart]*openPart)}
+}
+
+// Add plans the reading of chunks chunks of a part
+func (r *ChunkReader) Add(part *models.FilePart, chunks int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if open := r.parts[part]; open != nil {
+		open.left += chunks
+		return
+	}
+	r.parts[part] = &openPart{left: chunks}
+}
+
+// ReadChunk reads the chunk at index in a part, padding included, into buf
+// and returns the bytes read with their CRC32, computed as they are read
+func (r *ChunkReader) ReadChunk(part *models.FilePart, index int, buf []byte) ([]byte, uint32, error) {
+	reader, err := r.open(part)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer r.done(part)
+
+	offset := int64(index) * int64(r.chunkSize)
+	size := min(int64(r.chunkSize), reader.Size()-offset)
+	if size < 0 {
+		return nil, 0, fmt.Errorf("chunk %d is past the end of part %d of %s", index+1, part.PartNumber, part.FileName)
+	}
+	if int64(cap(buf)) < size {
+		buf = make([]byte, size)
+	}
+	buf = buf[:size]
+	var crc uint32
+	for done := 0; done < len(buf); {
+		block := buf[done:min(done+crcBlock, len(buf))]
+		if _, err := reader.ReadAt(block, offset+int64(done)); err != nil {
+			return nil, 0, fmt.Errorf("failed to read chunk %d of part %d of %s: %w", index+1, part.PartNumber, part.FileName, err)
+		}
+		crc = crc32.Update(crc, crc32.IEEETable, block)
+		done += len(block)
+	}
+	return buf, crc, nil
+}
+
+// open returns the reader of a part, opening it on its first chunk
+func (r *ChunkReader) open(part *models.FilePart) (*PartReader, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	open := r.parts[part]
+	if open == nil {
+		open = &openPart{left: 1}
+		r.parts[part] = open
+	}
+	if open.reader == nil {
+		reader, err := OpenPart(part)
+		if err != nil {
+			return nil, err
+		}
+		open.reader = reader
+	}
+	return open.reader, nil
+}
+
+// done counts a chunk of a part read, closing the part after its last
+func (r *ChunkReader) done(part *models.FilePart) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	open := r.parts[part]
+	if open.left--; open.left <= 0 {
+		open.reader.Close()
+		delete(r.parts, part)
+	}
+}
+
+// Close closes the parts left open, whose chunks were not all read
+func (r *ChunkReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var first error
+	for part, open := range r.parts {
+		if open.reader != nil {
+			if err := open.reader.Close(); err != nil && first == nil {
+				first = err
+			}
+		}
+		delete(r.parts, part)
+	}
+	return first
+}
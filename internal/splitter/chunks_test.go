@@ -0,0 +1,80 @@
+package splitter
+
+import (
+	"bytes"
+	"context"
+	"hash/crc32"
+	"testing"
+)
+
+func TestChunkReader(t *testing.T) {
+	tempDir := t.TempDir()
+	source, data := writeTestFile(t, tempDir, "test.bin", 2500)
+
+	split := NewSplitter(1000)
+	split.SetPadParts(true)
+	parts, err := split.Split(context.Background(), source, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Chunks of 400 bytes: 3 per part, the last of each part shorter
+	reader := NewChunkReader(400)
+	for _, part := range parts {
+		reader.Add(part, 3)
+	}
+	padded := append(append([]byte{}, data...), make([]byte, 500)...)
+	buf := make([]byte, 0, 400)
+	for i, part := range parts {
+		for index := 0; index < 3; index++ {
+			chunk, crc, err := reader.ReadChunk(part, index, buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			start := i*1000 + index*400
+			end := min(start+400, (i+1)*1000)
+			if !bytes.Equal(chunk, padded[start:end]) {
+				t.Errorf("chunk %d of part %d differs", index+1, part.PartNumber)
+			}
+			if crc != crc32.ChecksumIEEE(chunk) {
+				t.Errorf("chunk %d of part %d: wrong CRC32 %08X", index+1, part.PartNumber, crc)
+			}
+		}
+		// The part is closed after its last chunk
+		if reader.parts[part] != nil {
+			t.Errorf("part %d still open", part.PartNumber)
+		}
+	}
+
+	if _, _, err := reader.ReadChunk(parts[0], 3, buf); err == nil {
+		t.Error("expected an error reading past the end of the part")
+	}
+	if err := reader.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(reader.parts) != 0 {
+		t.Error("expected every part closed")
+	}
+}
+
+func TestChunkReaderCRCAcrossBlocks(t *testing.T) {
+	source, data := writeTestFile(t, t.TempDir(), "large.bin", 3*crcBlock+100)
+	parts, err := NewSplitter(int64(len(data))).Split(context.Background(), source, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One chunk read in four blocks, the last partial
+	reader := NewChunkReader(len(data))
+	defer reader.Close()
+	chunk, crc, err := reader.ReadChunk(parts[0], 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(chunk, data) {
+		t.Error("chunk differs from the file")
+	}
+	if crc != crc32.ChecksumIEEE(data) {
+		t.Errorf("wrong CRC32 %08X", crc)
+	}
+}
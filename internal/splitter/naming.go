@@ -0,0 +1,140 @@
+package splitter
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NamingScheme generates the file name of a part
+type NamingScheme interface {
+	PartName(originalName string, partNumber int, totalParts int) string
+}
+
+// Naming scheme identifiers accepted by ParseNamingScheme
+const (
+	NamingDefault = "default"
+	NamingPart    = "part"
+	NamingRNN     = "rnn"
+)
+
+// DefaultNaming produces name.partNN.ext, keeping the original extension
+type DefaultNaming struct {
+	Width int
+}
+
+// PartName implements NamingScheme
+func (n DefaultNaming) PartName(originalName string, partNumber int, totalParts int) string {
+	if totalParts <= 1 {
+		return originalName
+	}
+	base, ext := splitExt(originalName)
+	return fmt.Sprintf("%s.part%0*d%s", base, padWidth(n.Width, totalParts), partNumber, ext)
+}
+
+// PartNaming produces RAR5-style name.partNN.rar names
+type PartNaming struct {
+	Width int
+}
+
+// PartName implements NamingScheme
+func (n PartNaming) PartName(originalName string, partNumber int, totalParts int) string {
+	if totalParts <= 1 {
+		return originalName
+	}
+	base, _ := splitExt(originalName)
+	return fmt.Sprintf("%s.part%0*d.rar", base, padWidth(n.Width, totalParts), partNumber)
+}
+
+// RNNNaming produces old-style name.rar, name.r00, name.r01, ... names
+type RNNNaming struct {
+	Width int
+}
+
+// PartName implements NamingScheme
+func (n RNNNaming) PartName(originalName string, partNumber int, totalParts int) string {
+	if totalParts <= 1 {
+		return originalName
+	}
+	base, _ := splitExt(originalName)
+	if partNumber == 1 {
+		return base + ".rar"
+	}
+	// The first volume is .rar, so the numbered volumes go up to totalParts-2
+	return fmt.Sprintf("%s.r%0*d", base, padWidth(n.Width, totalParts-2), partNumber-2)
+}
+
+// ParseNamingScheme returns the naming scheme for a configuration name. A width
+// of 0 pads part numbers to fit the total (at least two digits).
+func ParseNamingScheme(name string, width int) (NamingScheme, error) {
+	if width < 0 {
+		return nil, fmt.Errorf("invalid part name width %d", width)
+	}
+
+	switch strings.ToLower(name) {
+	case "", NamingDefault:
+		return DefaultNaming{Width: width}, nil
+	case NamingPart:
+		return PartNaming{Width: width}, nil
+	case NamingRNN:
+		return RNNNaming{Width: width}, nil
+	default:
+		return nil, fmt.Errorf("unknown part naming scheme %q (expected default, part or rnn)", name)
+	}
+}
+
+// splitExt splits a file name into base name and extension
+func splitExt(name string) (string, string) {
+	ext := filepath.Ext(name)
+	return name[:len(name)-len(ext)], ext
+}
+
+// padWidth returns the zero-padding width for part numbers up to count
+func padWidth(width int, count int) int {
+	if width > 0 {
+		return width
+	}
+	digits := len(fmt.Sprintf("%d", count))
+	if digits < 2 {
+		digits = 2
+	}
+	return digits
+}
+
+var (
+	partNumberPattern = regexp.MustCompile(`(?i)\.part(\d+)(\.[^.]+)?$`)
+	rnnNumberPattern  = regexp.MustCompile(`(?i)\.r(\d+)$`)
+)
+
+// SortPartNames orders part file names by volume number for any of the naming
+// schemes, so that name.rar precedes name.r00 and part10 follows part9
+func SortPartNames(names []string) {
+	sort.SliceStable(names, func(i, j int) bool {
+		baseI, numI := partOrder(filepath.Base(names[i]))
+		baseJ, numJ := partOrder(filepath.Base(names[j]))
+		if baseI != baseJ {
+			return baseI < baseJ
+		}
+		return numI < numJ
+	})
+}
+
+// partOrder returns the set base name and position of a part file name
+func partOrder(name string) (string, int) {
+	if m := partNumberPattern.FindStringSubmatchIndex(name); m != nil {
+		number, _ := strconv.Atoi(name[m[2]:m[3]])
+		return name[:m[0]], number
+	}
+	if m := rnnNumberPattern.FindStringSubmatchIndex(name); m != nil {
+		number, _ := strconv.Atoi(name[m[2]:m[3]])
+		// name.rar is volume 0, name.r00 volume 1
+		return name[:m[0]], number + 1
+	}
+	if strings.EqualFold(filepath.Ext(name), ".rar") {
+		return strings.TrimSuffix(name, filepath.Ext(name)), 0
+	}
+	return name, 0
+}
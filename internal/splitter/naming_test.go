@@ -0,0 +1,81 @@
+package splitter
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNamingSchemes(t *testing.T) {
+	tests := []struct {
+		scheme     string
+		width      int
+		partNumber int
+		totalParts int
+		expected   string
+	}{
+		{NamingDefault, 0, 1, 3, "movie.part01.mkv"},
+		{NamingDefault, 0, 1, 1, "movie.mkv"},
+		{NamingDefault, 0, 7, 120, "movie.part007.mkv"},
+		{NamingPart, 0, 2, 3, "movie.part02.rar"},
+		{NamingPart, 4, 2, 3, "movie.part0002.rar"},
+		{NamingRNN, 0, 1, 3, "movie.rar"},
+		{NamingRNN, 0, 2, 3, "movie.r00"},
+		{NamingRNN, 0, 3, 3, "movie.r01"},
+		{NamingRNN, 0, 101, 101, "movie.r99"},
+		{NamingRNN, 0, 102, 102, "movie.r100"},
+		{NamingRNN, 3, 2, 3, "movie.r000"},
+	}
+
+	for _, test := range tests {
+		scheme, err := ParseNamingScheme(test.scheme, test.width)
+		if err != nil {
+			t.Fatalf("Unexpected error for scheme %q: %v", test.scheme, err)
+		}
+		result := scheme.PartName("movie.mkv", test.partNumber, test.totalParts)
+		if result != test.expected {
+			t.Errorf("%s width %d part %d/%d: expected %q, got %q",
+				test.scheme, test.width, test.partNumber, test.totalParts, test.expected, result)
+		}
+	}
+
+	if _, err := ParseNamingScheme("zip", 0); err == nil {
+		t.Error("Expected error for unknown scheme")
+	}
+}
+
+func TestSplitWithNaming(t *testing.T) {
+	tempDir := t.TempDir()
+	source, _ := writeTestFile(t, tempDir, "movie.mkv", 2500)
+
+	split := NewSplitter(1000)
+	split.SetNamingScheme(RNNNaming{})
+	parts, err := split.Split(context.Background(), source, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"movie.rar", "movie.r00", "movie.r01"}
+	for i, part := range parts {
+		if part.FileName != expected[i] {
+			t.Errorf("part %d: expected %q, got %q", part.PartNumber, expected[i], part.FileName)
+		}
+	}
+}
+
+func TestSortPartNames(t *testing.T) {
+	names := []string{
+		"dir/movie.r01", "dir/movie.r00", "dir/movie.rar",
+		"show.part10.mkv", "show.part02.mkv", "show.part9.mkv",
+	}
+	SortPartNames(names)
+
+	expected := []string{
+		"dir/movie.rar", "dir/movie.r00", "dir/movie.r01",
+		"show.part02.mkv", "show.part9.mkv", "show.part10.mkv",
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("position %d: expected %q, got %q", i, expected[i], names[i])
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package splitter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"ypost/internal/remote"
+)
+
+// source is a file being split or read back, local or remote
+type source interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// openSource opens the file at path, or the remote object when path is a
+// URL, and returns it with its name and size
+func openSource(ctx context.Context, path string) (source, string, int64, error) {
+	if remote.IsURL(path) {
+		obj, err := remote.Open(ctx, path)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		return obj, obj.Name(), obj.Size(), nil
+	}
+
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to stat file: %w", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	return file, filepath.Base(path), fileInfo.Size(), nil
+}
+
+// sourceName returns the file name of a path or URL
+func sourceName(path string) string {
+	if remote.IsURL(path) {
+		return remote.Name(path)
+	}
+	return filepath.Base(path)
+}
@@ -1,9 +1,11 @@
 package splitter
 
 import (
-	"crypto/sha256"
+	"bytes"
+	"context"
 	"encoding/hex"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
@@ -13,98 +15,376 @@ import (
 
 // Splitter handles file splitting operations
 type Splitter struct {
-	maxPartSize int64
+	maxPartSize       int64
+	naming            NamingScheme
+	checksumAlgorithm string
+	padParts          bool
+	fileCRCs          map[string]uint32
 }
 
 // NewSplitter creates a new file splitter
 func NewSplitter(maxPartSize int64) *Splitter {
-	fmt.Printf("DEBUG: Splitter created with maxPartSize: %d bytes\n", maxPartSize)
 	return &Splitter{
-		maxPartSize: maxPartSize,
+		maxPartSize:       maxPartSize,
+		checksumAlgorithm: ChecksumCRC32,
+		fileCRCs:          make(map[string]uint32),
 	}
 }
 
-// SplitFile splits a file into parts based on configuration and saves them to output directory
-func (s *Splitter) SplitFile(filePath string, outputDir string) ([]*models.FilePart, error) {
-	fileInfo, err := os.Stat(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to stat file: %w", err)
+// NewFromConfig creates a splitter for input files with the part size, naming
+// scheme, checksum and article alignment from the configuration
+func NewFromConfig(cfg *models.Config) (*Splitter, error) {
+	s := NewSplitter(cfg.Posting.MaxPartSize)
+	if cfg.Splitting.Naming != "" {
+		scheme, err := ParseNamingScheme(cfg.Splitting.Naming, cfg.Splitting.NameWidth)
+		if err != nil {
+			return nil, err
+		}
+		s.SetNamingScheme(scheme)
+	}
+	if cfg.Splitting.Checksum != "" {
+		if err := s.SetChecksumAlgorithm(cfg.Splitting.Checksum); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Splitting.AlignParts {
+		s.AlignToArticles(cfg.Posting.MaxArticleSize)
+	}
+	s.SetPadParts(cfg.Splitting.PadParts)
+	return s, nil
+}
+
+// PartSize returns the size of every part but the last
+func (s *Splitter) PartSize() int64 {
+	return s.maxPartSize
+}
+
+// SetChecksumAlgorithm selects the part checksum algorithm (crc32, xxhash,
+// blake3 or sha256)
+func (s *Splitter) SetChecksumAlgorithm(algorithm string) error {
+	if err := ValidateChecksumAlgorithm(algorithm); err != nil {
+		return err
+	}
+	s.checksumAlgorithm = algorithm
+	return nil
+}
+
+// FileCRCs returns the CRC32 of every file planned so far, keyed by path. They
+// are computed in the same pass as the part checksums so SFV generation does
+// not need to read the files again.
+func (s *Splitter) FileCRCs() map[string]uint32 {
+	return s.fileCRCs
+}
+
+// SetNamingScheme posts each part as its own file named by scheme (e.g. RAR
+// volume names). Without a scheme, parts are segments of the original file.
+func (s *Splitter) SetNamingScheme(scheme NamingScheme) {
+	s.naming = scheme
+}
+
+// SetPadParts pads the final part of each file with zero bytes up to the full
+// part size. The padding is only added when the part is read; checksums and
+// sizes still describe the real data.
+func (s *Splitter) SetPadParts(pad bool) {
+	s.padParts = pad
+}
+
+// AlignToArticles rounds the part size down to a whole multiple of the article
+// size so every article except the very last one is full
+func (s *Splitter) AlignToArticles(articleSize int64) {
+	s.maxPartSize = AlignedPartSize(s.maxPartSize, articleSize)
+}
+
+// AlignedPartSize returns the largest multiple of articleSize not exceeding
+// maxPartSize, and at least one article
+func AlignedPartSize(maxPartSize int64, articleSize int64) int64 {
+	if articleSize <= 0 {
+		return maxPartSize
 	}
+	aligned := (maxPartSize / articleSize) * articleSize
+	if aligned < articleSize {
+		aligned = articleSize
+	}
+	return aligned
+}
+
+// Storage selects where the data of split parts lives
+type Storage int
+
+const (
+	// StorageView leaves part data in the source file; it is read lazily
+	// through OpenPart and nothing is written to disk
+	StorageView Storage = iota
+	// StorageDisk writes each part to its own file in Options.OutputDir
+	StorageDisk
+	// StorageMemory loads the bytes of each part into FilePart.Data
+	StorageMemory
+)
 
-	file, err := os.Open(filePath)
+// Options controls how Split produces parts
+type Options struct {
+	Storage   Storage
+	OutputDir string
+}
+
+// Split divides src into parts of at most the configured part size. Whatever
+// the storage, every part can be read back through OpenPart.
+func (s *Splitter) Split(ctx context.Context, src string, opts Options) ([]*models.FilePart, error) {
+	if opts.Storage == StorageDisk && opts.OutputDir == "" {
+		return nil, fmt.Errorf("on-disk parts require an output directory")
+	}
+
+	parts, err := s.planParts(ctx, src)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+		return nil, err
 	}
-	defer file.Close()
 
-	// Ensure output directory exists
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	switch opts.Storage {
+	case StorageView:
+	case StorageDisk:
+		if err := s.writeParts(ctx, parts, src, opts.OutputDir); err != nil {
+			return nil, err
+		}
+	case StorageMemory:
+		if err := loadParts(ctx, parts); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown part storage %d", opts.Storage)
 	}
 
+	return parts, nil
+}
+
+// planParts describes the parts of a file as views over the source
+func (s *Splitter) planParts(ctx context.Context, filePath string) ([]*models.FilePart, error) {
+	file, sourceFileName, fileSize, err := openSource(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
 	var parts []*models.FilePart
-	partNumber := 1
-	bytesRead := int64(0)
-	totalParts := int((fileInfo.Size() + s.maxPartSize - 1) / s.maxPartSize)
-	
-	fmt.Printf("DEBUG: SplitFile - fileSize: %d, maxPartSize: %d, calculated totalParts: %d\n", 
-		fileInfo.Size(), s.maxPartSize, totalParts)
+	totalParts := int((fileSize + s.maxPartSize - 1) / s.maxPartSize)
+
+	// One sequential read computes both the part checksums and the file CRC32
+	fileCRC := crc32.NewIEEE()
+	for partNumber := 1; partNumber <= totalParts; partNumber++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 
-	for bytesRead < fileInfo.Size() {
+		offset := int64(partNumber-1) * s.maxPartSize
 		partSize := s.maxPartSize
-		if fileInfo.Size()-bytesRead < partSize {
-			partSize = fileInfo.Size() - bytesRead
+		if fileSize-offset < partSize {
+			partSize = fileSize - offset
 		}
 
-		data := make([]byte, partSize)
-		n, err := file.Read(data)
-		if err != nil && err != io.EOF {
+		section := io.TeeReader(io.NewSectionReader(file, offset, partSize), fileCRC)
+		checksum, err := s.calculateChecksum(section)
+		if err != nil {
 			return nil, fmt.Errorf("failed to read file: %w", err)
 		}
 
-		if n > 0 {
-			data = data[:n]
-			checksum := s.calculateChecksum(data)
-			
-			// Generate filename for this part
-			partFileName := s.GetPartFileName(filepath.Base(filePath), partNumber, totalParts)
-			partFilePath := filepath.Join(outputDir, partFileName)
-			
-			// Write part to file
-			if err := os.WriteFile(partFilePath, data, 0644); err != nil {
-				return nil, fmt.Errorf("failed to write part file: %w", err)
-			}
-			
-			part := &models.FilePart{
-				PartNumber: partNumber,
-				FileName:   filepath.Base(filePath),
-				Size:       int64(n),
-				FilePath:   partFilePath,
-				Data:       nil, // No longer storing data in memory
-				Checksum:   checksum,
-			}
-			
-			parts = append(parts, part)
-			partNumber++
-			bytesRead += int64(n)
-		}
-
-		if err == io.EOF {
-			break
+		fileName := sourceFileName
+		if s.naming != nil {
+			fileName = s.naming.PartName(fileName, partNumber, totalParts)
+		}
+
+		part := &models.FilePart{
+			PartNumber: partNumber,
+			FileName:   fileName,
+			Size:       partSize,
+			SourcePath: filePath,
+			Offset:     offset,
+			Checksum:   checksum,
+		}
+		if s.padParts {
+			part.Padding = s.maxPartSize - partSize
 		}
+		parts = append(parts, part)
 	}
 
+	s.fileCRCs[filePath] = fileCRC.Sum32()
 	return parts, nil
 }
 
-// SplitIntoChunks splits data into chunks of specified size
-func (s *Splitter) SplitIntoChunks(data []byte, chunkSize int64) [][]byte {
+// writeParts copies part views into their own files in outputDir
+func (s *Splitter) writeParts(ctx context.Context, parts []*models.FilePart, src string, outputDir string) error {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	for _, part := range parts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		partFileName := s.GetPartFileName(sourceName(src), part.PartNumber, len(parts))
+		partFilePath := filepath.Join(outputDir, partFileName)
+
+		// An unsplit file in its own directory is already its only part
+		if samePath(partFilePath, src) {
+			continue
+		}
+
+		if err := s.writePart(part, partFilePath); err != nil {
+			return err
+		}
+		part.FilePath = partFilePath
+	}
+	return nil
+}
+
+// loadParts reads the bytes of part views into memory
+func loadParts(ctx context.Context, parts []*models.FilePart) error {
+	for _, part := range parts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		reader, err := OpenPartData(part)
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read part %d: %w", part.PartNumber, err)
+		}
+		part.Data = data
+	}
+	return nil
+}
+
+// PartReader gives sequential and random access to the bytes of a part,
+// followed by its padding
+type PartReader struct {
+	*io.SectionReader
+	file source
+}
+
+// Close releases the underlying file or remote object, if any
+func (r *PartReader) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
+
+// OpenPart opens a part for reading, whether it is held in memory, was written
+// to disk or is a view over a file or remote object
+func OpenPart(part *models.FilePart) (*PartReader, error) {
+	if part.Data != nil {
+		return &PartReader{
+			SectionReader: padSection(bytes.NewReader(part.Data), 0, part),
+		}, nil
+	}
+
+	path, offset := part.FilePath, int64(0)
+	if path == "" {
+		path, offset = part.SourcePath, part.Offset
+	}
+
+	file, _, _, err := openSource(context.Background(), path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open part %d: %w", part.PartNumber, err)
+	}
+
+	return &PartReader{
+		SectionReader: padSection(file, offset, part),
+		file:          file,
+	}, nil
+}
+
+// OpenPartData opens only the real data of a part, without its padding
+func OpenPartData(part *models.FilePart) (*PartReader, error) {
+	reader, err := OpenPart(part)
+	if err != nil {
+		return nil, err
+	}
+	reader.SectionReader = io.NewSectionReader(reader.SectionReader, 0, part.Size)
+	return reader, nil
+}
+
+// padSection returns a reader over the part's data at offset in r, extended
+// with the part's zero padding
+func padSection(r io.ReaderAt, offset int64, part *models.FilePart) *io.SectionReader {
+	if part.Padding <= 0 {
+		return io.NewSectionReader(r, offset, part.Size)
+	}
+	padded := &paddedReaderAt{data: io.NewSectionReader(r, offset, part.Size), size: part.Size}
+	return io.NewSectionReader(padded, 0, part.Size+part.Padding)
+}
+
+// paddedReaderAt reads zeros past the end of data
+type paddedReaderAt struct {
+	data io.ReaderAt
+	size int64
+}
+
+// ReadAt implements io.ReaderAt
+func (r *paddedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	if off < r.size {
+		end := len(p)
+		if int64(end) > r.size-off {
+			end = int(r.size - off)
+		}
+		read, err := r.data.ReadAt(p[:end], off)
+		n += read
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+		if read < end {
+			return n, io.ErrUnexpectedEOF
+		}
+	}
+	// The enclosing SectionReader bounds the padding
+	for i := n; i < len(p); i++ {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// samePath reports whether two paths refer to the same file location
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return absA == absB
+}
+
+// writePart copies the bytes of a part view into its own file
+func (s *Splitter) writePart(part *models.FilePart, partFilePath string) error {
+	reader, err := OpenPartData(part)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	out, err := os.Create(partFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to write part file: %w", err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, reader); err != nil {
+		return fmt.Errorf("failed to write part file: %w", err)
+	}
+	return nil
+}
+
+// SplitIntoChunks splits data into chunks of at most chunkSize bytes
+func SplitIntoChunks(data []byte, chunkSize int) [][]byte {
 	var chunks [][]byte
 	
-	for i := int64(0); i < int64(len(data)); i += chunkSize {
+	for i := 0; i < len(data); i += chunkSize {
 		end := i + chunkSize
-		if end > int64(len(data)) {
-			end = int64(len(data))
+		if end > len(data) {
+			end = len(data)
 		}
 		chunks = append(chunks, data[i:end])
 	}
@@ -112,22 +392,24 @@ func (s *Splitter) SplitIntoChunks(data []byte, chunkSize int64) [][]byte {
 	return chunks
 }
 
-// calculateChecksum calculates SHA256 checksum for data integrity
-func (s *Splitter) calculateChecksum(data []byte) string {
-	hash := sha256.Sum256(data)
-	return hex.EncodeToString(hash[:])
+// calculateChecksum calculates the configured checksum for data integrity
+func (s *Splitter) calculateChecksum(reader io.Reader) (string, error) {
+	hash, err := newHash(s.checksumAlgorithm)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
 // GetPartFileName generates a filename for a file part
 func (s *Splitter) GetPartFileName(originalName string, partNumber int, totalParts int) string {
-	ext := filepath.Ext(originalName)
-	base := originalName[:len(originalName)-len(ext)]
-	
-	if totalParts > 1 {
-		return fmt.Sprintf("%s.part%02d%s", base, partNumber, ext)
+	if s.naming != nil {
+		return s.naming.PartName(originalName, partNumber, totalParts)
 	}
-	
-	return originalName
+	return DefaultNaming{}.PartName(originalName, partNumber, totalParts)
 }
 
 // JoinParts joins file parts back into a single file
@@ -139,27 +421,59 @@ func (s *Splitter) JoinParts(parts []*models.FilePart, outputPath string) error
 	defer outputFile.Close()
 
 	for _, part := range parts {
-		// Read data from file
-		data, err := os.ReadFile(part.FilePath)
+		reader, err := OpenPartData(part)
 		if err != nil {
-			return fmt.Errorf("failed to read part file %s: %w", part.FilePath, err)
-		}
-		
-		// Verify checksum
-		calculatedChecksum := s.calculateChecksum(data)
-		if calculatedChecksum != part.Checksum {
-			return fmt.Errorf("checksum mismatch for part %d", part.PartNumber)
+			return err
 		}
 
-		_, err = outputFile.Write(data)
+		// Verify checksum while copying
+		hash, err := newHash(s.checksumAlgorithm)
+		if err != nil {
+			reader.Close()
+			return err
+		}
+		_, err = io.Copy(io.MultiWriter(outputFile, hash), reader)
+		reader.Close()
 		if err != nil {
 			return fmt.Errorf("failed to write part %d: %w", part.PartNumber, err)
 		}
+
+		// Parts discovered on disk carry no recorded checksum
+		if part.Checksum != "" && hex.EncodeToString(hash.Sum(nil)) != part.Checksum {
+			return fmt.Errorf("checksum mismatch for part %d", part.PartNumber)
+		}
 	}
 
 	return nil
 }
 
+// DiscoverParts describes existing part files, ordered by volume number
+func DiscoverParts(paths []string) ([]*models.FilePart, error) {
+	sorted := make([]string, len(paths))
+	copy(sorted, paths)
+	SortPartNames(sorted)
+
+	var parts []*models.FilePart
+	for i, path := range sorted {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat part file: %w", err)
+		}
+		if info.IsDir() {
+			continue
+		}
+
+		parts = append(parts, &models.FilePart{
+			PartNumber: i + 1,
+			FileName:   filepath.Base(path),
+			Size:       info.Size(),
+			FilePath:   path,
+		})
+	}
+
+	return parts, nil
+}
+
 // GetPartInfo returns information about file parts without splitting
 func (s *Splitter) GetPartInfo(filePath string) (int64, int, error) {
 	fileInfo, err := os.Stat(filePath)
@@ -176,13 +490,16 @@ func (s *Splitter) GetPartInfo(filePath string) (int64, int, error) {
 // ValidateParts validates that all parts exist and have correct checksums
 func (s *Splitter) ValidateParts(parts []*models.FilePart) error {
 	for _, part := range parts {
-		// Read data from file
-		data, err := os.ReadFile(part.FilePath)
+		reader, err := OpenPartData(part)
 		if err != nil {
-			return fmt.Errorf("failed to read part file %s: %w", part.FilePath, err)
+			return err
+		}
+
+		calculatedChecksum, err := s.calculateChecksum(reader)
+		reader.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read part %d: %w", part.PartNumber, err)
 		}
-		
-		calculatedChecksum := s.calculateChecksum(data)
 		if calculatedChecksum != part.Checksum {
 			return fmt.Errorf("checksum validation failed for part %d", part.PartNumber)
 		}
@@ -190,9 +507,13 @@ func (s *Splitter) ValidateParts(parts []*models.FilePart) error {
 	return nil
 }
 
-// CleanupPartFiles removes temporary part files
+// CleanupPartFiles removes temporary part files. Views are left untouched
+// since their data lives in the source file.
 func (s *Splitter) CleanupPartFiles(parts []*models.FilePart) error {
 	for _, part := range parts {
+		if part.FilePath == "" {
+			continue
+		}
 		if err := os.Remove(part.FilePath); err != nil {
 			return fmt.Errorf("failed to remove part file %s: %w", part.FilePath, err)
 		}
@@ -0,0 +1,328 @@
+package splitter
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ypost/pkg/models"
+)
+
+// writeTestFile creates a file with size bytes of patterned data
+func writeTestFile(t *testing.T, dir string, name string, size int) (string, []byte) {
+	t.Helper()
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+func TestSplitViews(t *testing.T) {
+	tempDir := t.TempDir()
+	source, data := writeTestFile(t, tempDir, "test.bin", 2500)
+
+	split := NewSplitter(1000)
+	parts, err := split.Split(context.Background(), source, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 parts, got %d", len(parts))
+	}
+
+	// Nothing should have been written next to the source
+	entries, _ := os.ReadDir(tempDir)
+	if len(entries) != 1 {
+		t.Errorf("Split wrote files to disk: %d entries", len(entries))
+	}
+
+	for i, part := range parts {
+		if part.FilePath != "" {
+			t.Errorf("part %d should be a view, has FilePath %s", part.PartNumber, part.FilePath)
+		}
+
+		reader, err := OpenPart(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		start := i * 1000
+		end := start + 1000
+		if end > len(data) {
+			end = len(data)
+		}
+		if !bytes.Equal(got, data[start:end]) {
+			t.Errorf("part %d data mismatch", part.PartNumber)
+		}
+	}
+
+	if err := split.ValidateParts(parts); err != nil {
+		t.Errorf("ValidateParts failed on views: %v", err)
+	}
+
+	// Cleaning up views must never touch the source
+	if err := split.CleanupPartFiles(parts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(source); err != nil {
+		t.Fatalf("source removed by cleanup: %v", err)
+	}
+}
+
+func TestSplitRemoteViews(t *testing.T) {
+	tempDir := t.TempDir()
+	source, data := writeTestFile(t, tempDir, "test.bin", 2500)
+	server := httptest.NewServer(http.FileServer(http.Dir(tempDir)))
+	defer server.Close()
+
+	local := NewSplitter(1000)
+	localParts, err := local.Split(context.Background(), source, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	split := NewSplitter(1000)
+	url := server.URL + "/test.bin"
+	parts, err := split.Split(context.Background(), url, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != len(localParts) || split.FileCRCs()[url] != local.FileCRCs()[source] {
+		t.Fatalf("expected the remote split to match the local one")
+	}
+
+	for i, part := range parts {
+		if part.FileName != "test.bin" || part.Checksum != localParts[i].Checksum {
+			t.Errorf("part %d: unexpected name %s or checksum", part.PartNumber, part.FileName)
+		}
+		reader, err := OpenPart(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil || !bytes.Equal(got, data[i*1000:min(len(data), (i+1)*1000)]) {
+			t.Errorf("part %d data mismatch (%v)", part.PartNumber, err)
+		}
+	}
+}
+
+func TestSplitToDiskAndJoin(t *testing.T) {
+	tempDir := t.TempDir()
+	source, data := writeTestFile(t, tempDir, "test.bin", 2500)
+	outputDir := filepath.Join(tempDir, "out")
+
+	split := NewSplitter(1000)
+	parts, err := split.Split(context.Background(), source, Options{Storage: StorageDisk, OutputDir: outputDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, part := range parts {
+		if _, err := os.Stat(part.FilePath); err != nil {
+			t.Errorf("part file missing: %v", err)
+		}
+	}
+
+	joined := filepath.Join(tempDir, "joined.bin")
+	if err := split.JoinParts(parts, joined); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(joined)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("joined file differs from source")
+	}
+}
+
+func TestSplitToDiskInPlace(t *testing.T) {
+	tempDir := t.TempDir()
+	source, data := writeTestFile(t, tempDir, "test.par2", 500)
+
+	// Splitting an unsplit file into its own directory must not truncate it
+	split := NewSplitter(1000)
+	parts, err := split.Split(context.Background(), source, Options{Storage: StorageDisk, OutputDir: tempDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 1 || parts[0].FilePath != "" {
+		t.Fatalf("expected a single view part, got %+v", parts)
+	}
+
+	got, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("source file was modified")
+	}
+}
+
+func TestSplitPadParts(t *testing.T) {
+	tempDir := t.TempDir()
+	source, data := writeTestFile(t, tempDir, "test.bin", 2500)
+
+	split := NewSplitter(1000)
+	split.SetPadParts(true)
+	parts, err := split.Split(context.Background(), source, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	last := parts[len(parts)-1]
+	if last.Size != 500 || last.Padding != 500 {
+		t.Fatalf("expected 500 bytes of data and 500 of padding, got %d and %d", last.Size, last.Padding)
+	}
+
+	reader, err := OpenPart(last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	posted, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := append(append([]byte{}, data[2000:]...), make([]byte, 500)...)
+	if !bytes.Equal(posted, expected) {
+		t.Error("padded part should be the real data followed by zeros")
+	}
+
+	// Checksums and joins only cover the real data
+	if err := split.ValidateParts(parts); err != nil {
+		t.Errorf("ValidateParts failed on padded parts: %v", err)
+	}
+	joined := filepath.Join(tempDir, "joined.bin")
+	if err := split.JoinParts(parts, joined); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(joined)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("joined padded parts differ from source")
+	}
+}
+
+func TestSplitInMemory(t *testing.T) {
+	tempDir := t.TempDir()
+	source, data := writeTestFile(t, tempDir, "test.bin", 2500)
+
+	split := NewSplitter(1000)
+	parts, err := split.Split(context.Background(), source, Options{Storage: StorageMemory})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var joined []byte
+	for _, part := range parts {
+		if part.FilePath != "" || int64(len(part.Data)) != part.Size {
+			t.Errorf("part %d not held in memory: %+v", part.PartNumber, part)
+		}
+		joined = append(joined, part.Data...)
+	}
+	if !bytes.Equal(joined, data) {
+		t.Error("in-memory parts differ from source")
+	}
+
+	// In-memory parts read and validate like any other
+	if err := split.ValidateParts(parts); err != nil {
+		t.Errorf("ValidateParts failed on in-memory parts: %v", err)
+	}
+}
+
+func TestSplitOptions(t *testing.T) {
+	tempDir := t.TempDir()
+	source, _ := writeTestFile(t, tempDir, "test.bin", 100)
+
+	split := NewSplitter(1000)
+	if _, err := split.Split(context.Background(), source, Options{Storage: StorageDisk}); err == nil {
+		t.Error("expected an error for on-disk parts without an output directory")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := split.Split(ctx, source, Options{}); err == nil {
+		t.Error("expected an error for a cancelled context")
+	}
+}
+
+func TestNewFromConfig(t *testing.T) {
+	cfg := &models.Config{}
+	cfg.Posting.MaxPartSize = 1100
+	cfg.Posting.MaxArticleSize = 250
+	cfg.Splitting.AlignParts = true
+	cfg.Splitting.Naming = NamingRNN
+	cfg.Splitting.Checksum = ChecksumSHA256
+
+	split, err := NewFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if split.PartSize() != 1000 {
+		t.Errorf("expected aligned part size 1000, got %d", split.PartSize())
+	}
+	if split.GetPartFileName("test.bin", 1, 3) != "test.rar" {
+		t.Errorf("naming scheme not applied: %s", split.GetPartFileName("test.bin", 1, 3))
+	}
+
+	cfg.Splitting.Checksum = "md4"
+	if _, err := NewFromConfig(cfg); err == nil {
+		t.Error("expected an error for an unknown checksum")
+	}
+}
+
+func TestAlignedPartSize(t *testing.T) {
+	tests := []struct {
+		maxPartSize int64
+		articleSize int64
+		expected    int64
+	}{
+		{750000, 500000, 500000},
+		{1000000, 250000, 1000000},
+		{1100000, 250000, 1000000},
+		{100000, 500000, 500000},
+		{750000, 0, 750000},
+	}
+
+	for _, test := range tests {
+		result := AlignedPartSize(test.maxPartSize, test.articleSize)
+		if result != test.expected {
+			t.Errorf("AlignedPartSize(%d, %d): expected %d, got %d",
+				test.maxPartSize, test.articleSize, test.expected, result)
+		}
+	}
+
+	// Every part but the last is a whole number of articles
+	tempDir := t.TempDir()
+	source, _ := writeTestFile(t, tempDir, "test.bin", 2500)
+	split := NewSplitter(1000)
+	split.AlignToArticles(300)
+	parts, err := split.Split(context.Background(), source, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range parts[:len(parts)-1] {
+		if part.Size%300 != 0 {
+			t.Errorf("part %d size %d is not a multiple of the article size", part.PartNumber, part.Size)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// The dashboard polls /status and keeps the throughput of each server over
+// the last samples for its graph
+"use strict";
+
+const pollInterval = 2000;
+const keepSamples = 150;
+const history = new Map();
+
+function formatBytes(bytes) {
+  const units = ["B", "KB", "MB", "GB", "TB"];
+  let unit = 0;
+  while (bytes >= 1024 && unit < units.length - 1) {
+    bytes /= 1024;
+    unit++;
+  }
+  return bytes.toFixed(unit ? 1 : 0) + " " + units[unit];
+}
+
+function formatDuration(seconds) {
+  seconds = Math.round(seconds);
+  const h = Math.floor(seconds / 3600);
+  const m = Math.floor((seconds % 3600) / 60);
+  const s = seconds % 60;
+  return h ? `${h}h${String(m).padStart(2, "0")}m` : m ? `${m}m${String(s).padStart(2, "0")}s` : `${s}s`;
+}
+
+function element(tag, text, className) {
+  const node = document.createElement(tag);
+  if (text !== undefined) {
+    node.textContent = text;
+  }
+  if (className) {
+    node.className = className;
+  }
+  return node;
+}
+
+function progressBar(percent) {
+  const bar = element("div", undefined, "bar");
+  const fill = element("span");
+  fill.style.width = Math.min(percent, 100) + "%";
+  bar.append(fill, element("em", percent.toFixed(1) + "%"));
+  return bar;
+}
+
+function renderJobs(jobs) {
+  const body = document.getElementById("jobs");
+  body.replaceChildren();
+  if (!jobs.length) {
+    const row = body.insertRow();
+    row.append(element("td", "No jobs yet", "empty"));
+    row.cells[0].colSpan = 8;
+    return;
+  }
+  // Newest first
+  for (const job of jobs.slice().reverse()) {
+    const progress = job.progress || {};
+    const running = job.state === "running";
+    const row = body.insertRow();
+    const file = element("td", job.file, "file");
+    file.title = job.error || job.file;
+    const state = running && progress.phase ? progress.phase : job.state;
+    row.append(
+      file,
+      element("td", state, "state-" + job.state),
+      element("td"),
+      element("td", running && progress.speed ? formatBytes(progress.speed) + "/s" : ""),
+      element("td", running && progress.eta ? formatDuration(progress.eta) : ""),
+      element("td", job.failed ? `${job.articles} (${job.failed} failed)` : String(job.articles)),
+      element("td", new Date(job.started_at).toLocaleString()),
+      element("td"),
+    );
+    row.cells[2].append(progressBar(progress.overall || (job.state === "done" ? 100 : 0)));
+    if (job.nzb_path) {
+      const link = element("a", "NZB");
+      link.href = `jobs/${encodeURIComponent(job.id)}/nzb`;
+      row.cells[7].append(link);
+    }
+  }
+}
+
+function renderServers(servers) {
+  const now = Date.now();
+  for (const server of servers) {
+    if (!history.has(server.host)) {
+      history.set(server.host, []);
+    }
+  }
+  // Servers no job posts to any more fall to zero, then out of the graphs
+  for (const [host, samples] of history) {
+    const server = servers.find((s) => s.host === host);
+    samples.push({ at: now, speed: server ? server.speed : 0 });
+    if (samples.length > keepSamples) {
+      samples.shift();
+    }
+    if (!server && samples.every((s) => s.speed === 0)) {
+      history.delete(host);
+    }
+  }
+
+  const container = document.getElementById("servers");
+  container.replaceChildren();
+  if (!history.size) {
+    container.append(element("p", "No upload running", "empty"));
+    return;
+  }
+  for (const [host, samples] of history) {
+    const server = servers.find((s) => s.host === host) || { connections: 0, speed: 0, bytes: 0 };
+    const box = element("div", undefined, "server");
+    box.append(element("strong", host), element("div",
+      `${formatBytes(server.speed)}/s on ${server.connections} connection(s), ${formatBytes(server.bytes)} posted`));
+    box.append(graph(samples));
+    container.append(box);
+  }
+}
+
+function graph(samples) {
+  const width = 300;
+  const height = 80;
+  const peak = Math.max(...samples.map((s) => s.speed), 1);
+  const points = samples.map((s, i) => {
+    const x = (width * (i + keepSamples - samples.length)) / (keepSamples - 1);
+    const y = height - 2 - ((height - 4) * s.speed) / peak;
+    return `${x.toFixed(1)},${y.toFixed(1)}`;
+  });
+  const svg = document.createElementNS("http://www.w3.org/2000/svg", "svg");
+  svg.setAttribute("viewBox", `0 0 ${width} ${height}`);
+  svg.setAttribute("preserveAspectRatio", "none");
+  const line = document.createElementNS("http://www.w3.org/2000/svg", "polyline");
+  line.setAttribute("points", points.join(" "));
+  svg.append(line);
+  const title = document.createElementNS("http://www.w3.org/2000/svg", "title");
+  title.textContent = `peak ${formatBytes(peak)}/s over the last ${formatDuration((keepSamples * pollInterval) / 1000)}`;
+  svg.append(title);
+  return svg;
+}
+
+function renderErrors(errors) {
+  const list = document.getElementById("errors");
+  list.replaceChildren();
+  if (!errors.length) {
+    list.append(element("li", "None", "empty"));
+    return;
+  }
+  for (const entry of errors.slice(-10).reverse()) {
+    const where = entry.article ? `${entry.file} (${entry.article})` : entry.file || entry.post_id;
+    list.append(element("li", `${new Date(entry.time).toLocaleTimeString()} ${where}: ${entry.message}`));
+  }
+}
+
+async function poll() {
+  const connection = document.getElementById("connection");
+  try {
+    const response = await fetch("status", { cache: "no-store" });
+    if (!response.ok) {
+      throw new Error(response.statusText);
+    }
+    const status = await response.json();
+    document.getElementById("uptime").textContent = "up " + formatDuration(status.uptime);
+    renderJobs(status.jobs);
+    renderServers(status.servers || []);
+    renderErrors(status.errors);
+    connection.textContent = "live";
+    connection.className = "";
+  } catch (err) {
+    connection.textContent = "disconnected";
+    connection.className = "offline";
+  }
+  setTimeout(poll, pollInterval);
+}
+
+poll();
@@ -0,0 +1,115 @@
+package status
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+// shutdownTimeout bounds the wait for open requests when the server stops
+const shutdownTimeout = 5 * time.Second
+
+// dashboard holds the web dashboard, which polls /status
+//
+//go:embed dashboard
+var dashboard embed.FS
+
+// Handler serves the monitor's status as JSON: /status for the process and
+// its jobs, /jobs/<post id> for one job and /jobs/<post id>/nzb for its NZB.
+// /healthz answers 200 while the process runs and 503 once it is draining,
+// for container probes. The web dashboard is served at /.
+func Handler(m *Monitor) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFileFS(w, r, dashboard, "dashboard/index.html")
+	})
+	mux.Handle("GET /dashboard/", http.FileServerFS(dashboard))
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		if m.Draining() {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "draining"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, m.Status())
+	})
+	mux.HandleFunc("GET /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		job := m.Job(r.PathValue("id"))
+		if job == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	})
+	mux.HandleFunc("GET /jobs/{id}/nzb", func(w http.ResponseWriter, r *http.Request) {
+		job := m.Job(r.PathValue("id"))
+		if job == nil || job.NZBPath == "" {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "NZB not found"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-nzb")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(job.NZBPath)}))
+		http.ServeFile(w, r, job.NZBPath)
+	})
+	return mux
+}
+
+// writeJSON writes a value as the JSON response
+func writeJSON(w http.ResponseWriter, code int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
+
+// Server is the status endpoint of a monitor
+type Server struct {
+	listener net.Listener
+	server   *http.Server
+	done     chan error
+}
+
+// Listen serves the monitor's status on address (host:port) until Close
+func Listen(address string, m *Monitor) (*Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+	s := &Server{
+		listener: listener,
+		server:   &http.Server{Handler: Handler(m), ReadHeaderTimeout: 10 * time.Second},
+		done:     make(chan error, 1),
+	}
+	go func() {
+		err := s.server.Serve(listener)
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+		s.done <- err
+	}()
+	return s, nil
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server, letting open requests finish
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		return err
+	}
+	return <-s.done
+}
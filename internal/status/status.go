@@ -0,0 +1,368 @@
+package status
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"ypost/internal/events"
+	"ypost/internal/progress"
+)
+
+// Job states
+const (
+	StateRunning = "running"
+	StateDone    = "done"
+	StateFailed  = "failed"
+)
+
+// keepFinished is the number of finished jobs the monitor remembers
+const keepFinished = 20
+
+// keepErrors is the number of recent errors the monitor remembers
+const keepErrors = 50
+
+// Job is the status of a post
+type Job struct {
+	ID         string     `json:"id"`
+	File       string     `json:"file"`
+	Size       int64      `json:"size"`
+	State      string     `json:"state"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Articles and Failed count the articles posted and refused so far
+	Articles    int          `json:"articles"`
+	Failed      int          `json:"failed"`
+	Bytes       int64        `json:"bytes"`
+	Progress    *Progress    `json:"progress,omitempty"`
+	Connections []Connection `json:"connections"`
+	NZBPath     string       `json:"nzb_path,omitempty"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// Progress is the progress of a running job's upload
+type Progress struct {
+	Bytes   int64   `json:"bytes"`
+	Total   int64   `json:"total"`
+	Percent float64 `json:"percent"`
+	// Speed is in bytes per second, ETA and Elapsed in seconds
+	Speed   float64 `json:"speed"`
+	ETA     float64 `json:"eta"`
+	Elapsed float64 `json:"elapsed"`
+	// Phase is the phase of the post and Overall its weighted progress in
+	// percent; the bytes are those of the upload, once it started
+	Phase   string  `json:"phase,omitempty"`
+	Overall float64 `json:"overall"`
+}
+
+// Connection is the traffic of one connection of a job
+type Connection struct {
+	ID       int    `json:"id"`
+	Server   string `json:"server"`
+	Articles int    `json:"articles"`
+	Bytes    int64  `json:"bytes"`
+	// Speed is in bytes per second over the last progress.SpeedWindow
+	Speed    float64   `json:"speed"`
+	LastPost time.Time `json:"last_post"`
+}
+
+// Error is a refused article or a failed post
+type Error struct {
+	Time   time.Time `json:"time"`
+	PostID string    `json:"post_id"`
+	File   string    `json:"file,omitempty"`
+	// Article is the number of the refused article, 0 for a failed post
+	Article int    `json:"article,omitempty"`
+	Message string `json:"message"`
+}
+
+// ServerTraffic is the traffic of the running jobs' connections to a server
+type ServerTraffic struct {
+	Host        string `json:"host"`
+	Connections int    `json:"connections"`
+	Articles    int    `json:"articles"`
+	Bytes       int64  `json:"bytes"`
+	// Speed is in bytes per second over the last progress.SpeedWindow
+	Speed float64 `json:"speed"`
+}
+
+// Status is the state of the process and its jobs
+type Status struct {
+	StartedAt time.Time       `json:"started_at"`
+	Uptime    float64         `json:"uptime"`
+	Jobs      []*Job          `json:"jobs"`
+	Servers   []ServerTraffic `json:"servers"`
+	Errors    []Error         `json:"errors"`
+}
+
+// job is a followed post
+type job struct {
+	status      Job
+	progress    func() progress.Progress
+	connections map[int]*connection
+}
+
+// connection is the traffic of a connection and its recent samples
+type connection struct {
+	status  Connection
+	samples []sample
+}
+
+// sample is the bytes of an article posted at a point in time
+type sample struct {
+	at    time.Time
+	bytes int64
+}
+
+// Monitor follows the posts of a long-running process through their events
+type Monitor struct {
+	mu      sync.Mutex
+	started time.Time
+	jobs    map[string]*job
+	order   []string
+	errors  []Error
+	now     func() time.Time
+	// draining is set once the process is stopping
+	draining bool
+}
+
+// NewMonitor creates a monitor without jobs
+func NewMonitor() *Monitor {
+	return &Monitor{started: time.Now(), jobs: make(map[string]*job), now: time.Now}
+}
+
+// Drain marks the process as stopping: it finishes the running jobs but
+// takes no others
+func (m *Monitor) Drain() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.draining = true
+}
+
+// Draining reports whether the process is stopping
+func (m *Monitor) Draining() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.draining
+}
+
+// Subscribe makes the monitor follow the posts published on the bus of the
+// process, until the returned function is called
+func (m *Monitor) Subscribe() func() {
+	return events.Subscribe(m.Handle)
+}
+
+// Track makes the monitor report the progress of a post, as given by its
+// phases. It does nothing on a nil monitor.
+func (m *Monitor) Track(postID string, current func() progress.Progress) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if job := m.jobs[postID]; job != nil {
+		job.progress = current
+	}
+}
+
+// Handle updates the jobs with an event
+func (m *Monitor) Handle(event events.Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if event.Kind == events.PostStarted {
+		m.start(event)
+		return
+	}
+	job := m.jobs[event.PostID]
+	if job == nil {
+		return
+	}
+
+	switch event.Kind {
+	case events.SegmentPosted:
+		segment := event.Segment
+		job.status.Articles++
+		job.status.Bytes += segment.BytesPosted
+		conn := job.connections[segment.Connection]
+		if conn == nil {
+			conn = &connection{status: Connection{ID: segment.Connection}}
+			job.connections[segment.Connection] = conn
+		}
+		conn.status.Server = segment.Server
+		conn.status.Articles++
+		conn.status.Bytes += segment.BytesPosted
+		conn.status.LastPost = event.Time
+		conn.samples = append(conn.samples, sample{at: event.Time, bytes: segment.BytesPosted})
+	case events.SegmentFailed:
+		job.status.Failed++
+		entry := Error{Time: event.Time, PostID: event.PostID, File: event.Segment.FileName, Article: event.Segment.PartNumber}
+		if event.Err != nil {
+			entry.Message = event.Err.Error()
+		}
+		m.addError(entry)
+	case events.PostCompleted, events.Error:
+		job.status.State = StateDone
+		finished := event.Time
+		job.status.FinishedAt = &finished
+		if event.Record != nil {
+			job.status.NZBPath = event.Record.NZBPath
+		}
+		if event.Kind == events.Error {
+			job.status.State = StateFailed
+			if event.Err != nil {
+				job.status.Error = event.Err.Error()
+			}
+			m.addError(Error{Time: event.Time, PostID: event.PostID, File: job.status.File, Message: job.status.Error})
+		}
+		if job.progress != nil {
+			// Keep the final progress of the job
+			final := toProgress(job.progress())
+			job.status.Progress = &final
+			job.progress = nil
+		}
+		m.forget()
+	}
+}
+
+// start follows a new post
+func (m *Monitor) start(event events.Event) {
+	m.jobs[event.PostID] = &job{
+		status: Job{
+			ID:        event.PostID,
+			File:      event.File,
+			Size:      event.Size,
+			State:     StateRunning,
+			StartedAt: event.Time,
+		},
+		connections: make(map[int]*connection),
+	}
+	m.order = append(m.order, event.PostID)
+}
+
+// addError remembers an error, dropping the oldest beyond keepErrors
+func (m *Monitor) addError(entry Error) {
+	m.errors = append(m.errors, entry)
+	if len(m.errors) > keepErrors {
+		m.errors = m.errors[len(m.errors)-keepErrors:]
+	}
+}
+
+// forget drops the oldest finished jobs beyond keepFinished
+func (m *Monitor) forget() {
+	finished := 0
+	for i := len(m.order) - 1; i >= 0; i-- {
+		id := m.order[i]
+		if m.jobs[id].status.State == StateRunning {
+			continue
+		}
+		finished++
+		if finished > keepFinished {
+			delete(m.jobs, id)
+			m.order = append(m.order[:i:i], m.order[i+1:]...)
+		}
+	}
+}
+
+// Status returns the jobs, oldest first, the servers the running ones post to
+// and the recent errors
+func (m *Monitor) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	status := Status{
+		StartedAt: m.started,
+		Uptime:    now.Sub(m.started).Seconds(),
+		Jobs:      []*Job{},
+		Servers:   []ServerTraffic{},
+		Errors:    append([]Error{}, m.errors...),
+	}
+	servers := make(map[string]*ServerTraffic)
+	for _, id := range m.order {
+		job := m.jobs[id].snapshot(now)
+		status.Jobs = append(status.Jobs, job)
+		if job.State != StateRunning {
+			continue
+		}
+		for _, conn := range job.Connections {
+			server := servers[conn.Server]
+			if server == nil {
+				server = &ServerTraffic{Host: conn.Server}
+				servers[conn.Server] = server
+			}
+			server.Connections++
+			server.Articles += conn.Articles
+			server.Bytes += conn.Bytes
+			server.Speed += conn.Speed
+		}
+	}
+	for _, server := range servers {
+		status.Servers = append(status.Servers, *server)
+	}
+	sort.Slice(status.Servers, func(i, j int) bool { return status.Servers[i].Host < status.Servers[j].Host })
+	return status
+}
+
+// Job returns the status of a post, nil when the monitor does not know it
+func (m *Monitor) Job(postID string) *Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job := m.jobs[postID]
+	if job == nil {
+		return nil
+	}
+	return job.snapshot(m.now())
+}
+
+// snapshot returns a copy of the status of the job as of now
+func (j *job) snapshot(now time.Time) *Job {
+	status := j.status
+	if j.progress != nil {
+		current := toProgress(j.progress())
+		status.Progress = &current
+	}
+	status.Connections = []Connection{}
+	for id := 0; len(status.Connections) < len(j.connections); id++ {
+		if conn := j.connections[id]; conn != nil {
+			status.Connections = append(status.Connections, conn.snapshot(now))
+		}
+	}
+	return &status
+}
+
+// snapshot returns the status of the connection with its speed as of now
+func (c *connection) snapshot(now time.Time) Connection {
+	cutoff := now.Add(-progress.SpeedWindow)
+	kept := c.samples[:0]
+	var bytes int64
+	for _, s := range c.samples {
+		if s.at.After(cutoff) {
+			kept = append(kept, s)
+			bytes += s.bytes
+		}
+	}
+	c.samples = kept
+
+	status := c.status
+	status.Speed = float64(bytes) / progress.SpeedWindow.Seconds()
+	return status
+}
+
+// toProgress converts the progress of a tracker
+func toProgress(current progress.Progress) Progress {
+	result := Progress{
+		Bytes:   current.BytesSent,
+		Total:   current.TotalBytes,
+		Speed:   current.Speed,
+		ETA:     current.ETA.Seconds(),
+		Elapsed: current.Elapsed.Seconds(),
+		Phase:   string(current.Phase),
+		Overall: current.Overall * 100,
+	}
+	if current.TotalBytes > 0 {
+		result.Percent = float64(current.BytesSent) * 100 / float64(current.TotalBytes)
+	}
+	return result
+}
@@ -0,0 +1,181 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ypost/internal/events"
+	"ypost/internal/progress"
+	"ypost/pkg/models"
+)
+
+func TestMonitor(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	m := NewMonitor()
+	m.now = func() time.Time { return now }
+
+	m.Handle(events.Event{Kind: events.PostStarted, PostID: "a", File: "movie.mkv", Size: 300, Time: now})
+	m.Track("a", func() progress.Progress {
+		return progress.Progress{BytesSent: 200, TotalBytes: 400, Speed: 20, ETA: 10 * time.Second}
+	})
+	for i, conn := range []int{0, 1, 0} {
+		segment := &models.PostSegment{FileName: "movie.mkv", PartNumber: i + 1, BytesPosted: 100, Server: "news.example.com", Connection: conn}
+		m.Handle(events.Event{Kind: events.SegmentPosted, PostID: "a", Segment: segment, Time: now.Add(time.Duration(i) * 5 * time.Second)})
+	}
+	m.Handle(events.Event{Kind: events.SegmentFailed, PostID: "a", Segment: &models.PostSegment{FileName: "movie.mkv", PartNumber: 4}, Err: errors.New("441 posting failed"), Time: now})
+	now = now.Add(12 * time.Second)
+
+	job := m.Job("a")
+	if job.State != StateRunning || job.Articles != 3 || job.Failed != 1 || job.Bytes != 300 {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	if job.Progress == nil || job.Progress.Percent != 50 || job.Progress.ETA != 10 {
+		t.Errorf("unexpected progress %+v", job.Progress)
+	}
+	if len(job.Connections) != 2 {
+		t.Fatalf("expected 2 connections, got %+v", job.Connections)
+	}
+	// Connection 0 posted at 0s and 10s, only the later inside the window
+	if conn := job.Connections[0]; conn.Articles != 2 || conn.Bytes != 200 || conn.Speed != 10 {
+		t.Errorf("unexpected connection %+v", conn)
+	}
+	if conn := job.Connections[1]; conn.Speed != 10 {
+		t.Errorf("unexpected connection %+v", conn)
+	}
+	// Both connections post to the same server
+	if servers := m.Status().Servers; len(servers) != 1 || servers[0].Connections != 2 || servers[0].Bytes != 300 || servers[0].Speed != 20 {
+		t.Errorf("unexpected servers %+v", servers)
+	}
+
+	m.Handle(events.Event{Kind: events.Error, PostID: "a", Err: errors.New("upload failed"), Record: &models.PostingHistory{}, Time: now})
+	status := m.Status()
+	if len(status.Jobs) != 1 || status.Jobs[0].State != StateFailed || status.Jobs[0].FinishedAt == nil {
+		t.Fatalf("unexpected jobs %+v", status.Jobs)
+	}
+	if len(status.Errors) != 2 || status.Errors[0].Article != 4 || status.Errors[1].Message != "upload failed" {
+		t.Errorf("unexpected errors %+v", status.Errors)
+	}
+	if m.Job("b") != nil {
+		t.Error("expected no job b")
+	}
+}
+
+func TestMonitorForgetsOldJobs(t *testing.T) {
+	m := NewMonitor()
+	m.Handle(events.Event{Kind: events.PostStarted, PostID: "running"})
+	for i := 0; i < keepFinished+5; i++ {
+		id := string(rune('a' + i))
+		m.Handle(events.Event{Kind: events.PostStarted, PostID: id})
+		m.Handle(events.Event{Kind: events.PostCompleted, PostID: id, Record: &models.PostingHistory{}})
+	}
+	status := m.Status()
+	if len(status.Jobs) != keepFinished+1 || status.Jobs[0].ID != "running" || status.Jobs[1].ID != "f" {
+		t.Errorf("expected the running job and the last %d finished, got %d jobs", keepFinished, len(status.Jobs))
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := NewMonitor()
+	m.Handle(events.Event{Kind: events.PostStarted, PostID: "0123456789abcdef", File: "movie.mkv"})
+	server := httptest.NewServer(Handler(m))
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status Status
+	if err := json.NewDecoder(response.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if len(status.Jobs) != 1 || status.Jobs[0].File != "movie.mkv" {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	response, err = http.Get(server.URL + "/jobs/0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected response %d %s", response.StatusCode, response.Header.Get("Content-Type"))
+	}
+
+	for _, path := range []string{"/jobs/unknown", "/jobs/0123456789abcdef/nzb"} {
+		response, err = http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", path, response.StatusCode)
+		}
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	m := NewMonitor()
+	server := httptest.NewServer(Handler(m))
+	defer server.Close()
+
+	for _, expected := range []int{http.StatusOK, http.StatusServiceUnavailable} {
+		response, err := http.Get(server.URL + "/healthz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != expected {
+			t.Errorf("expected %d, got %d", expected, response.StatusCode)
+		}
+		m.Drain()
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	nzbPath := filepath.Join(t.TempDir(), "movie.mkv.nzb")
+	if err := os.WriteFile(nzbPath, []byte("<nzb/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := NewMonitor()
+	m.Handle(events.Event{Kind: events.PostStarted, PostID: "0123456789abcdef", File: "movie.mkv"})
+	m.Handle(events.Event{Kind: events.PostCompleted, PostID: "0123456789abcdef", Record: &models.PostingHistory{NZBPath: nzbPath}})
+	server := httptest.NewServer(Handler(m))
+	defer server.Close()
+
+	get := func(path string) (*http.Response, string) {
+		t.Helper()
+		response, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response, string(body)
+	}
+
+	response, body := get("/")
+	if response.StatusCode != http.StatusOK || !strings.Contains(body, "dashboard/dashboard.js") {
+		t.Errorf("unexpected dashboard %d: %s", response.StatusCode, body)
+	}
+	if response, _ := get("/dashboard/dashboard.js"); response.StatusCode != http.StatusOK {
+		t.Errorf("expected the dashboard script, got %d", response.StatusCode)
+	}
+
+	response, body = get("/jobs/0123456789abcdef/nzb")
+	if response.StatusCode != http.StatusOK || body != "<nzb/>" ||
+		response.Header.Get("Content-Disposition") != `attachment; filename=movie.mkv.nzb` {
+		t.Errorf("unexpected NZB %d %v: %s", response.StatusCode, response.Header, body)
+	}
+}
@@ -0,0 +1,135 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ypost/internal/logger"
+	"ypost/internal/yenc"
+	"ypost/pkg/models"
+)
+
+// chunkOutcome is an article posted, or one no server accepted with the
+// error of the last
+type chunkOutcome struct {
+	job     job
+	segment *models.PostSegment
+	err     error
+}
+
+// article is a chunk encoded and ready to be posted
+type article struct {
+	job     job
+	encoded []byte
+	// size is the number of bytes of the chunk
+	size    int
+	segment *models.PostSegment
+	// err is the failure to read the chunk, not encoded then
+	err error
+}
+
+// loadedChunk is the data of a chunk read from its part and its CRC32, or
+// the failure to read it
+type loadedChunk struct {
+	job  job
+	data []byte
+	crc  uint32
+	err  error
+}
+
+// encodeChunk encodes the data of a chunk into buf and renders its subject
+func encodeChunk(job job, subject *subjectFormat, yencEnc *yenc.Encoder, data []byte, crc uint32, buf []byte) *article {
+	// The articles of a file are the parts of a multi-part post, counted
+	// as in the NZB and the subject
+	encoded := yencEnc.AppendEncodeCRC(buf, data, crc, yenc.Article{
+		Name:       job.part.FileName,
+		Number:     job.chunkNumber,
+		Total:      job.totalChunks,
+		FileSize:   job.fileBytes,
+		Offset:     job.offset,
+		FileCRC32:  job.fileCRC,
+		HasFileCRC: job.hasFileCRC,
+	})
+
+	segment := newSegment(job, subject.render(job))
+	segment.CRC32 = crc
+	return &article{job: job, encoded: encoded, size: len(data), segment: segment}
+}
+
+// newSegment creates the segment of a chunk before it is posted
+func newSegment(job job, subject string) *models.PostSegment {
+	return &models.PostSegment{
+		PartNumber: job.chunkNumber, // Use chunk number for NZB
+		TotalParts: job.totalChunks, // Total chunks for NZB
+		FileName:   job.part.FileName,
+		Subject:    subject,
+		Failures:   job.failures,
+	}
+}
+
+// postArticle posts an encoded chunk from a poster. A chunk no server
+// accepts is returned with the error, its segment holding the server
+// responses; one whose retries ctx interrupts is returned with ctx's error.
+func postArticle(ctx context.Context, servers []Server, nextMessageID func() string, article *article, cfg *models.Config, from string, log logger.Interface) (*models.PostSegment, error) {
+	job, segment := article.job, article.segment
+	if article.err != nil {
+		return segment, article.err
+	}
+	body := string(article.encoded)
+
+	// Upload chunk, failing over to the next server tier on error, all of
+	// them tried again after a pause up to posting.retries times; every
+	// server is sent the same article, under the same Message-ID
+	messageID := nextMessageID()
+	var host string
+	var err error
+	for attempt := 0; attempt <= cfg.Posting.Retries; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(time.Duration(attempt) * retryDelay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return segment, ctx.Err()
+			case <-timer.C:
+			}
+		}
+		for _, server := range servers {
+			err = server.Post(segment.Subject, from, body, messageID)
+			if err == nil {
+				host = server.Host()
+				break
+			}
+			segment.Failures = append(segment.Failures, models.ServerFailure{Server: server.Host(), Response: err.Error()})
+			log.Warn("Failed to post chunk %d of part %d to %s: %v", job.chunkIndex+1, job.part.PartNumber, server.Host(), err)
+		}
+		if err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return segment, fmt.Errorf("failed to post chunk %d of part %d: %w", job.chunkIndex+1, job.part.PartNumber, err)
+	}
+
+	segment.MessageID = messageID
+	segment.PostedAt = time.Now()
+	segment.BytesPosted = int64(article.size)
+	segment.Server = host
+	segment.Retries = len(segment.Failures)
+
+	return segment, nil
+}
+
+// explainRefusal tells what an article refused at the start of a post says
+// of the post as a whole
+func explainRefusal(code int) string {
+	switch code {
+	case 411:
+		return "the server does not carry the group"
+	case 440:
+		return "the server does not allow posting with this account"
+	default:
+		return "the server refuses the articles: the group may not take posts, or the account may lack posting rights"
+	}
+}
@@ -0,0 +1,57 @@
+package upload
+
+import (
+	"errors"
+	"fmt"
+)
+
+// IncompleteError is an upload some articles of which no server accepted,
+// with the error of the first
+type IncompleteError struct {
+	Failed int
+	Err    error
+}
+
+func (e *IncompleteError) Error() string {
+	return fmt.Sprintf("upload failed with %d errors: %v", e.Failed, e.Err)
+}
+
+func (e *IncompleteError) Unwrap() error {
+	return e.Err
+}
+
+// Add counts the articles err reports were not posted, reporting whether
+// err is nil or an incomplete upload
+func (e *IncompleteError) Add(err error) bool {
+	if err == nil {
+		return true
+	}
+	var incomplete *IncompleteError
+	if !errors.As(err, &incomplete) {
+		return false
+	}
+	if e.Failed == 0 {
+		e.Err = incomplete.Err
+	}
+	e.Failed += incomplete.Failed
+	return true
+}
+
+// Result returns the upload as an error when articles were not posted, nil
+// otherwise
+func (e *IncompleteError) Result() error {
+	if e.Failed == 0 {
+		return nil
+	}
+	return e
+}
+
+// IncompleteArticles returns the number of articles err reports were not
+// posted, 0 when err is not an incomplete upload
+func IncompleteArticles(err error) int {
+	var incomplete *IncompleteError
+	if errors.As(err, &incomplete) {
+		return incomplete.Failed
+	}
+	return 0
+}
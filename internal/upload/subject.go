@@ -0,0 +1,131 @@
+package upload
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+
+	"ypost/internal/utils"
+	"ypost/pkg/models"
+)
+
+// defaultSubjectTemplate is the subject of articles when
+// posting.subject_template is empty, the form indexers parse:
+// comment [n/total] - "filename" yEnc (part/parts) size
+const defaultSubjectTemplate = `{{with .Comment}}{{.}} {{end}}{{.Counter}} - {{.Name}} yEnc ({{.ChunkIndex}}/{{.TotalChunks}}) {{.Bytes}}`
+
+// subjectData is what subject templates render, with both part and chunk
+// information
+type subjectData struct {
+	Index       int // Part number (for file parts like RAR)
+	Total       int // Total parts
+	Filename    string
+	Size        string
+	ChunkIndex  int // Article number within the file (for NNTP articles)
+	TotalChunks int // Articles of the file
+	Comment     string
+	FileNumber  int    // Number of the file among those posted
+	Files       int    // Files posted
+	Counter     string // FileNumber/Files in the configured brackets
+	Name        string // Filename in the configured quotes
+	Bytes       int64  // Size of the file in bytes
+}
+
+// subjectQuotes and subjectBrackets are the quotes of the file name and the
+// brackets of the file counter, by posting.subject_quote and
+// posting.subject_brackets
+var (
+	subjectQuotes = map[string]string{
+		models.SubjectQuoteDouble: `"`,
+		models.SubjectQuoteSingle: `'`,
+		models.SubjectQuoteNone:   "",
+	}
+	subjectBrackets = map[string][2]string{
+		models.SubjectBracketsSquare: {"[", "]"},
+		models.SubjectBracketsRound:  {"(", ")"},
+	}
+)
+
+// subjectTemplates caches the parsed subject templates by their text, so
+// posting articles only executes them
+var subjectTemplates sync.Map
+
+// parseSubjectTemplate returns the parsed subject template of text, the
+// default when empty. A template that does not parse, or fails to render an
+// article, is an error before anything is posted.
+func parseSubjectTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = defaultSubjectTemplate
+	}
+	if cached, ok := subjectTemplates.Load(text); ok {
+		return cached.(*template.Template), nil
+	}
+
+	tmpl, err := template.New("subject").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject template: %w", err)
+	}
+	if err := tmpl.Execute(&strings.Builder{}, subjectData{}); err != nil {
+		return nil, fmt.Errorf("invalid subject template: %w", err)
+	}
+	cached, _ := subjectTemplates.LoadOrStore(text, tmpl)
+	return cached.(*template.Template), nil
+}
+
+// subjectFormat renders the subjects of the articles of a post
+type subjectFormat struct {
+	tmpl     *template.Template
+	comment  string
+	quote    string
+	brackets [2]string
+}
+
+// newSubjectFormat returns the subject format of the posting settings,
+// double quotes and square brackets when unset
+func newSubjectFormat(cfg *models.Config) (*subjectFormat, error) {
+	tmpl, err := parseSubjectTemplate(cfg.Posting.SubjectTemplate)
+	if err != nil {
+		return nil, err
+	}
+	format := &subjectFormat{tmpl: tmpl, comment: cfg.Posting.SubjectComment, quote: `"`, brackets: [2]string{"[", "]"}}
+	if quote, ok := subjectQuotes[cfg.Posting.SubjectQuote]; ok {
+		format.quote = quote
+	}
+	if brackets, ok := subjectBrackets[cfg.Posting.SubjectBrackets]; ok {
+		format.brackets = brackets
+	}
+	return format, nil
+}
+
+// render returns the subject of the article of a chunk: the part's own
+// subject when it has one, else the template's
+func (f *subjectFormat) render(job job) string {
+	if job.part.Subject != "" {
+		return job.part.Subject
+	}
+
+	counter := fmt.Sprintf("%s%d/%d%s", f.brackets[0], job.fileNumber, job.files, f.brackets[1])
+	name := f.quote + job.part.FileName + f.quote
+	var subject strings.Builder
+	err := f.tmpl.Execute(&subject, subjectData{
+		Index:       job.part.PartNumber,
+		Total:       job.totalParts,
+		Filename:    job.part.FileName,
+		Size:        utils.FormatFileSize(job.totalBytes),
+		ChunkIndex:  job.chunkNumber,
+		TotalChunks: job.totalChunks,
+		Comment:     f.comment,
+		FileNumber:  job.fileNumber,
+		Files:       job.files,
+		Counter:     counter,
+		Name:        name,
+		Bytes:       job.fileBytes,
+	})
+	if err != nil {
+		// Fallback to the default form
+		return strings.TrimSpace(fmt.Sprintf("%s %s - %s yEnc (%d/%d) %d",
+			f.comment, counter, name, job.chunkNumber, job.totalChunks, job.fileBytes))
+	}
+	return subject.String()
+}
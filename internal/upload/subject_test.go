@@ -0,0 +1,78 @@
+package upload
+
+import (
+	"testing"
+
+	"ypost/pkg/models"
+)
+
+// subjectJob is the third of the 10 articles of the first of two files
+func subjectJob() job {
+	return job{
+		part:        &models.FilePart{PartNumber: 1, FileName: "data.bin", Size: 2500},
+		chunkNumber: 3,
+		totalParts:  1,
+		totalChunks: 10,
+		totalBytes:  2500,
+		fileNumber:  1,
+		files:       2,
+		fileBytes:   2500,
+	}
+}
+
+func TestSubjectFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(cfg *models.Config)
+		want  string
+	}{
+		{"default", func(cfg *models.Config) {}, `[1/2] - "data.bin" yEnc (3/10) 2500`},
+		{"comment", func(cfg *models.Config) {
+			cfg.Posting.SubjectComment = "Holiday photos"
+		}, `Holiday photos [1/2] - "data.bin" yEnc (3/10) 2500`},
+		{"quotes and brackets", func(cfg *models.Config) {
+			cfg.Posting.SubjectQuote = models.SubjectQuoteSingle
+			cfg.Posting.SubjectBrackets = models.SubjectBracketsRound
+		}, `(1/2) - 'data.bin' yEnc (3/10) 2500`},
+		{"no quotes", func(cfg *models.Config) {
+			cfg.Posting.SubjectQuote = models.SubjectQuoteNone
+		}, `[1/2] - data.bin yEnc (3/10) 2500`},
+		{"template", func(cfg *models.Config) {
+			cfg.Posting.SubjectTemplate = `{{.Filename}} ({{.Index}}/{{.Total}}) [{{.ChunkIndex}}/{{.TotalChunks}}] {{.Size}}`
+		}, `data.bin (1/1) [3/10] 2.4KB`},
+	}
+	for _, test := range tests {
+		var cfg models.Config
+		test.setup(&cfg)
+		format, err := newSubjectFormat(&cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := format.render(subjectJob()); got != test.want {
+			t.Errorf("%s: expected %q, got %q", test.name, test.want, got)
+		}
+	}
+
+	// A part's own subject is kept
+	var cfg models.Config
+	format, _ := newSubjectFormat(&cfg)
+	job := subjectJob()
+	job.part.Subject = "a1b2c3d4"
+	if got := format.render(job); got != "a1b2c3d4" {
+		t.Errorf("expected the subject of the part, got %q", got)
+	}
+}
+
+func TestSubjectFormatInvalidTemplates(t *testing.T) {
+	for _, text := range []string{
+		"{{.Filename",
+		"{{.Nope}}",
+		"{{template \"missing\"}}",
+	} {
+		var cfg models.Config
+		cfg.Posting.SubjectTemplate = text
+		if _, err := newSubjectFormat(&cfg); err == nil {
+			t.Errorf("%q: expected an invalid template", text)
+		}
+	}
+}
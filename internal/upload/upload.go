@@ -0,0 +1,551 @@
+// Package upload posts the parts of a file as yEnc articles over several
+// connections, reading and encoding the articles ahead of them
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"runtime"
+	"sync"
+	"time"
+
+	"ypost/internal/bufpool"
+	"ypost/internal/logger"
+	"ypost/internal/memory"
+	"ypost/internal/nntp"
+	"ypost/internal/progress"
+	"ypost/internal/scaling"
+	"ypost/internal/schedule"
+	"ypost/internal/splitter"
+	"ypost/internal/yenc"
+	"ypost/pkg/models"
+)
+
+// requeueRounds is the number of times the retry policy posts a failed
+// article again after the others
+const requeueRounds = 3
+
+// startRefusals is the number of articles refused with 441 before any is
+// posted that stop the upload, a refusal of the post rather than of them
+const startRefusals = 3
+
+// refusals tells the servers refusing a post from those refusing some of
+// its articles: articles refused before any is posted are the group or the
+// account refused, startRefusals articles in a row with 441
+type refusals struct {
+	posted bool
+	count  int
+}
+
+// add counts the outcome of an article, returning the code of a refusal
+// that stops the upload
+func (r *refusals) add(err error) (int, bool) {
+	if err == nil {
+		r.posted = true
+		return 0, false
+	}
+	code, ok := nntp.Refused(err)
+	if !ok {
+		r.count = 0
+		return 0, false
+	}
+	if r.posted {
+		return 0, false
+	}
+	r.count++
+	return code, code != 441 || r.count >= startRefusals
+}
+
+// retryDelay is the pause before an article is sent through the servers
+// again, growing with each retry
+const retryDelay = time.Second
+
+// Server is a server articles are posted to
+type Server interface {
+	// Host names the server in the segments and the logs
+	Host() string
+	// Post posts an article under messageID; a refusal of the server is a
+	// *textproto.Error of its response
+	Post(subject string, from string, body string, messageID string) error
+}
+
+// Hooks follow the articles of an upload; any of them may be nil
+type Hooks struct {
+	// Planned is called for the articles of each part, numbered from first
+	Planned func(fileName string, first int, chunks int)
+	// Reused returns the segment of an article posted by an interrupted
+	// run, not posted again then
+	Reused func(fileName string, number int) (*models.PostSegment, bool)
+	// Posted is called for every article posted
+	Posted func(segment *models.PostSegment)
+	// Failed is called for every article no server accepted
+	Failed func(segment *models.PostSegment, err error)
+}
+
+// Options configure an upload
+type Options struct {
+	// Config holds the posting settings: the article size, the subjects,
+	// the retries and the on-error policy, the posting window and the
+	// performance settings
+	Config models.Config
+	// Servers are tried in order for every article
+	Servers []Server
+	// MessageID returns the Message-ID of the next article, the same on
+	// every server
+	MessageID func() string
+	// From is the From header of the articles
+	From string
+	// Connections is the number of articles posted at once
+	Connections int
+	// Encoder is copied by each encoder of articles
+	Encoder yenc.Encoder
+	// SourceCRCs are the checksums of the sources of the parts by path,
+	// written in the trailers of the files posted from them
+	SourceCRCs map[string]uint32
+	// Budget bounds the memory of the articles in flight; nil is unbounded
+	Budget *memory.Budget
+	// Log and Tracker follow the upload when set
+	Log     logger.Interface
+	Tracker *progress.Tracker
+	Hooks   Hooks
+}
+
+// pool recycles the buffers of chunks and articles, a bufpool.Pool
+type pool interface {
+	Size() int
+	Get() []byte
+	Put(buf []byte)
+}
+
+// job represents a single chunk upload task
+type job struct {
+	part        *models.FilePart
+	chunkIndex  int
+	chunkNumber int
+	totalParts  int
+	totalChunks int
+	totalBytes  int64
+	// fileNumber is the number of the job's file among the files of the
+	// parts, and fileBytes its size
+	fileNumber int
+	files      int
+	fileBytes  int64
+	// offset is where the chunk starts in its file, and fileCRC the
+	// checksum of the file when known
+	offset     int64
+	fileCRC    uint32
+	hasFileCRC bool
+	// requeued is the number of times the job was queued again after it
+	// failed, with the responses of the servers then
+	requeued int
+	failures []models.ServerFailure
+}
+
+// Articles returns the number of articles a part is posted in
+func Articles(part *models.FilePart, maxArticleSize int) int {
+	return int((part.Size + part.Padding + int64(maxArticleSize) - 1) / int64(maxArticleSize))
+}
+
+// Parts posts the articles of parts and returns their segments. Articles no
+// server accepted are left out, the error an *IncompleteError counting
+// them; an upload stopped, by ctx, the on-error policy or the servers
+// refusing the post, returns the segments posted before with the error.
+func Parts(ctx context.Context, parts []*models.FilePart, opts Options) ([]*models.PostSegment, error) {
+	maxArticleSize := int(opts.Config.Posting.MaxArticleSize)
+	chunks := bufpool.New(maxArticleSize)
+	buffers := bufpool.New(yenc.EncodedSize(maxArticleSize, opts.Config.Posting.MaxLineLength))
+	return upload(ctx, parts, opts, chunks, buffers)
+}
+
+// upload is Parts reading chunks into buffers of chunks and encoding
+// articles into buffers of buffers
+func upload(ctx context.Context, parts []*models.FilePart, opts Options, chunks pool, buffers pool) ([]*models.PostSegment, error) {
+	log, tracker, hooks := opts.Log, opts.Tracker, opts.Hooks
+	if log == nil {
+		log = logger.Discard
+	}
+	if len(opts.Servers) == 0 {
+		return nil, fmt.Errorf("no servers to post to")
+	}
+
+	// Calculate total bytes for progress tracking
+	var totalBytes int64
+	for _, part := range parts {
+		totalBytes += part.Size + part.Padding
+	}
+
+	// NNTP article size limit from configuration
+	maxArticleSize := int(opts.Config.Posting.MaxArticleSize)
+
+	// The subject template is parsed once, its errors reported before posting
+	subject, err := newSubjectFormat(&opts.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Articles are numbered within their file, in the NZB as in subjects;
+	// the parts split under the name of their file make one file
+	var totalChunks int
+	var allJobs []job
+	fileChunks := make(map[string]int)
+	fileBytes := make(map[string]int64)
+	fileNumbers := make(map[string]int)
+	for _, part := range parts {
+		fileChunks[part.FileName] += Articles(part, maxArticleSize)
+		fileBytes[part.FileName] += part.Size + part.Padding
+		if _, ok := fileNumbers[part.FileName]; !ok {
+			fileNumbers[part.FileName] = len(fileNumbers) + 1
+		}
+	}
+	numbered := make(map[string]int)
+	offsets := make(map[string]int64)
+	fileCRCs := fileChecksums(parts, opts.SourceCRCs)
+
+	// Articles posted by an interrupted run are reused, not posted again
+	var reused []*models.PostSegment
+
+	// Chunks are read from their part as they are encoded, not loaded up
+	// front, so only the articles in flight are in memory
+	reader := splitter.NewChunkReader(maxArticleSize)
+	defer reader.Close()
+
+	// Prepare all upload jobs
+	for _, part := range parts {
+		partChunks := Articles(part, maxArticleSize)
+		chunkNumber := numbered[part.FileName] + 1
+		numbered[part.FileName] += partChunks
+		partOffset := offsets[part.FileName]
+		offsets[part.FileName] += part.Size + part.Padding
+		fileCRC, hasFileCRC := fileCRCs[part.FileName]
+		if hooks.Planned != nil {
+			hooks.Planned(part.FileName, chunkNumber, partChunks)
+		}
+		totalChunks += partChunks
+
+		pending := 0
+		for chunkIndex := 0; chunkIndex < partChunks; chunkIndex++ {
+			if hooks.Reused != nil {
+				if segment, ok := hooks.Reused(part.FileName, chunkNumber); ok {
+					reused = append(reused, segment)
+					chunkNumber++
+					continue
+				}
+			}
+			allJobs = append(allJobs, job{
+				part:        part,
+				chunkIndex:  chunkIndex,
+				chunkNumber: chunkNumber,
+				totalParts:  len(parts),
+				totalChunks: fileChunks[part.FileName],
+				totalBytes:  totalBytes,
+				fileNumber:  fileNumbers[part.FileName],
+				files:       len(fileNumbers),
+				fileBytes:   fileBytes[part.FileName],
+				offset:      partOffset + int64(chunkIndex)*int64(maxArticleSize),
+				fileCRC:     fileCRC,
+				hasFileCRC:  hasFileCRC,
+			})
+			pending++
+			chunkNumber++
+		}
+		// A part whose articles were all posted before is not opened again
+		if pending > 0 {
+			reader.Add(part, pending)
+		}
+	}
+
+	if len(reused) > 0 {
+		log.Info("Reusing %d of %d articles posted before", len(reused), totalChunks)
+	}
+
+	numWorkers := max(opts.Connections, 1)
+
+	// The queue holds every job, those posted again under the retry policy
+	// going back to it; it is closed once all are posted or failed, or when
+	// the upload is aborted, the jobs left in it then dropped
+	jobs := make(chan job, len(allJobs))
+	outcomes := make(chan chunkOutcome, numWorkers)
+	// The upload stops when ctx is done, as when it is aborted
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Adaptive uploads start half the connections and add them while the
+	// throughput grows with them
+	scaler := scaling.New(numWorkers, numWorkers)
+	if opts.Config.Performance.AdaptiveConnections {
+		scaler = scaling.New((numWorkers+1)/2, numWorkers)
+	}
+	scaler.SetLogger(log)
+
+	if opts.Config.Performance.AdaptiveConnections {
+		log.Info("Starting parallel upload with %d connections, up to %d, for %d chunks", scaler.Active(), numWorkers, totalChunks)
+	} else {
+		log.Info("Starting parallel upload with %d connections for %d chunks", numWorkers, totalChunks)
+	}
+	if tracker != nil {
+		tracker.SetConnections(numWorkers)
+	}
+
+	// Outside the posting window the workers hold their next chunk until it opens
+	window, _ := schedule.ParseWindow(opts.Config.Schedule.WindowStart, opts.Config.Schedule.WindowEnd)
+	gate := schedule.NewGate(window, func(until time.Time) {
+		log.Info("Outside the posting window %s, pausing until %s", window, until.Format("2006-01-02 15:04"))
+	})
+
+	// Encoders prepare the articles ahead of the connections, the queue
+	// between them holding one ready article per connection
+	ready := make(chan *article, numWorkers)
+	// Chunks are read and articles encoded in recycled buffers, a chunk's
+	// given back once encoded and an article's once posted; both count in
+	// the memory budget until the article is posted
+	budget := opts.Budget
+	articleMemory := int64(chunks.Size() + buffers.Size())
+	read := func(job job) loadedChunk {
+		budget.Acquire(articleMemory)
+		buf := chunks.Get()
+		data, crc, err := reader.ReadChunk(job.part, job.chunkIndex, buf)
+		if err != nil {
+			// The reader returns no data then, the buffer is given back here
+			chunks.Put(buf)
+		}
+		return loadedChunk{job: job, data: data, crc: crc, err: err}
+	}
+	// The encoders read their chunk, unless a reader reads the chunks in
+	// order ahead of them
+	next := func() (loadedChunk, bool) {
+		for {
+			select {
+			case job, ok := <-jobs:
+				if !ok {
+					return loadedChunk{}, false
+				}
+				if ctx.Err() == nil {
+					return read(job), true
+				}
+			case <-ctx.Done():
+				return loadedChunk{}, false
+			}
+		}
+	}
+	if readahead := opts.Config.Performance.Readahead; readahead > 0 {
+		loaded := make(chan loadedChunk, readahead)
+		readNext := next
+		go func() {
+			defer close(loaded)
+			for chunk, ok := readNext(); ok; chunk, ok = readNext() {
+				loaded <- chunk
+			}
+		}()
+		next = func() (loadedChunk, bool) {
+			chunk, ok := <-loaded
+			return chunk, ok
+		}
+	}
+	var encoders sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		encoders.Add(1)
+		go func() {
+			defer encoders.Done()
+			// The encoder keeps the state of the article it encodes
+			encoder := opts.Encoder
+			for chunk, ok := next(); ok; chunk, ok = next() {
+				if chunk.err != nil {
+					ready <- &article{job: chunk.job, segment: newSegment(chunk.job, ""), err: chunk.err}
+					continue
+				}
+				article := encodeChunk(chunk.job, subject, &encoder, chunk.data, chunk.crc, buffers.Get())
+				chunks.Put(chunk.data)
+				ready <- article
+			}
+		}()
+	}
+	go func() {
+		encoders.Wait()
+		close(ready)
+	}()
+
+	// Start worker goroutines. Every article taken from the queue is
+	// answered with its outcome, or dropped once the upload is aborted, and
+	// the workers end when the encoders have no more.
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+
+			for scaler.Wait(workerID) {
+				article, ok := <-ready
+				if !ok {
+					// The workers the scaler holds back end too
+					scaler.Stop()
+					return
+				}
+				// The articles encoded before the upload was aborted are dropped
+				if gate.Wait(ctx) != nil || ctx.Err() != nil {
+					buffers.Put(article.encoded)
+					budget.Release(articleMemory)
+					continue
+				}
+				job := article.job
+				if tracker != nil {
+					tracker.BeginArticle(workerID, fmt.Sprintf("%s (%d/%d)", job.part.FileName, job.chunkNumber, job.totalChunks))
+				}
+				segment, err := postArticle(ctx, opts.Servers, opts.MessageID, article, &opts.Config, opts.From, log)
+				buffers.Put(article.encoded)
+				budget.Release(articleMemory)
+				if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+					// Interrupted between retries, the article is dropped
+					// like those after it
+					if tracker != nil {
+						tracker.EndArticle(workerID, 0)
+					}
+					continue
+				}
+				segment.Connection = workerID
+				if tracker != nil {
+					tracker.EndArticle(workerID, segment.BytesPosted)
+				}
+				scaler.Posted(segment.BytesPosted)
+				if nntp.Throttled(err) {
+					scaler.Throttled()
+				}
+				if err != nil {
+					log.Error("Worker %d failed to upload chunk %d: %v", workerID, job.chunkNumber, err)
+					err = fmt.Errorf("worker %d: %w", workerID, err)
+				}
+				outcomes <- chunkOutcome{job: job, segment: segment, err: err}
+			}
+		}(i)
+	}
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	if opts.Config.Performance.AdaptiveConnections {
+		go scaler.Run(func() int { return len(ready) })
+	}
+
+	// Queue all jobs
+	for _, job := range allJobs {
+		jobs <- job
+	}
+	left := len(allJobs)
+	queued := true
+	closeJobs := func() {
+		if queued {
+			queued = false
+			close(jobs)
+		}
+	}
+	if left == 0 {
+		closeJobs()
+	}
+
+	// Collect results until the workers end
+	segments := reused
+	var uploadErrors []error
+	var aborted error
+	policy := opts.Config.Posting.OnError
+
+	// Articles refused before any is posted are the group or the account
+	// refused, not the articles: the upload stops, whatever the policy
+	refused := refusals{posted: len(reused) > 0}
+
+	for outcome := range outcomes {
+		if code, stop := refused.add(outcome.err); stop && aborted == nil {
+			log.Error("Aborting the upload: %s", explainRefusal(code))
+			aborted = fmt.Errorf("%s: %w", explainRefusal(code), outcome.err)
+			cancel()
+			closeJobs()
+		}
+		switch {
+		case outcome.err == nil:
+			segments = append(segments, outcome.segment)
+			if hooks.Posted != nil {
+				hooks.Posted(outcome.segment)
+			}
+		case policy == models.OnErrorRetry && queued && outcome.job.requeued < requeueRounds:
+			// The article is read and posted again after those queued
+			job := outcome.job
+			job.requeued++
+			job.failures = outcome.segment.Failures
+			reader.Add(job.part, 1)
+			log.Warn("Posting chunk %d again after the other articles (%d/%d)", job.chunkNumber, job.requeued, requeueRounds)
+			jobs <- job
+			continue
+		default:
+			// The upload goes on with the next articles, the NZB left
+			// without this one, unless the policy aborts it
+			uploadErrors = append(uploadErrors, outcome.err)
+			if hooks.Failed != nil {
+				hooks.Failed(outcome.segment, outcome.err)
+			}
+			if policy == models.OnErrorAbort && aborted == nil {
+				log.Error("Aborting the upload after chunk %d failed", outcome.job.chunkNumber)
+				aborted = outcome.err
+				cancel()
+				closeJobs()
+			}
+		}
+		if left--; left == 0 {
+			closeJobs()
+		}
+	}
+	if aborted == nil && ctx.Err() != nil {
+		aborted = ctx.Err()
+	}
+
+	if aborted != nil {
+		return segments, fmt.Errorf("upload aborted: %w", aborted)
+	}
+
+	// The segments posted are returned with the failures, to be listed
+	// without the others
+	if len(uploadErrors) > 0 {
+		return segments, &IncompleteError{Failed: len(uploadErrors), Err: uploadErrors[0]}
+	}
+
+	log.Info("Successfully uploaded %d chunks using %d parallel connections", len(segments), scaler.Active())
+
+	return segments, nil
+}
+
+// fileChecksums returns the CRC32 of the files parts are posted as, from
+// the checksums of their sources by path: that of a source posted whole
+// under one name, extended over the zeros padding it. A source split under
+// several names is posted as files of unknown checksums.
+func fileChecksums(parts []*models.FilePart, sourceCRCs map[string]uint32) map[string]uint32 {
+	names := make(map[string]map[string]bool)
+	sources := make(map[string]string)
+	padding := make(map[string]int64)
+	for _, part := range parts {
+		if names[part.SourcePath] == nil {
+			names[part.SourcePath] = make(map[string]bool)
+		}
+		names[part.SourcePath][part.FileName] = true
+		if source, ok := sources[part.FileName]; ok && source != part.SourcePath {
+			// Posted from several sources
+			sources[part.FileName] = ""
+			continue
+		}
+		sources[part.FileName] = part.SourcePath
+		padding[part.FileName] += part.Padding
+	}
+
+	crcs := make(map[string]uint32)
+	for name, source := range sources {
+		crc, ok := sourceCRCs[source]
+		if !ok || source == "" || len(names[source]) > 1 {
+			continue
+		}
+		zeros := make([]byte, 32*1024)
+		for left := padding[name]; left > 0; left -= int64(len(zeros)) {
+			crc = crc32.Update(crc, crc32.IEEETable, zeros[:min(left, int64(len(zeros)))])
+		}
+		crcs[name] = crc
+	}
+	return crcs
+}
@@ -0,0 +1,447 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ypost/internal/bufpool"
+	"ypost/internal/memory"
+	"ypost/internal/splitter"
+	"ypost/internal/yenc"
+	"ypost/pkg/models"
+)
+
+// fakeServer keeps the articles it accepts, answering the nth article
+// posted to it, from 1, with the error of refuse when set
+type fakeServer struct {
+	host   string
+	refuse func(n int, subject string) error
+
+	mu       sync.Mutex
+	posted   int
+	articles map[string]string
+	subjects map[string]string
+}
+
+func newFakeServer(host string) *fakeServer {
+	return &fakeServer{host: host, articles: make(map[string]string), subjects: make(map[string]string)}
+}
+
+func (s *fakeServer) Host() string { return s.host }
+
+func (s *fakeServer) Post(subject string, from string, body string, messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.posted++
+	if s.refuse != nil {
+		if err := s.refuse(s.posted, subject); err != nil {
+			return err
+		}
+	}
+	s.articles[messageID] = body
+	s.subjects[messageID] = subject
+	return nil
+}
+
+// countingPool is a buffer pool counting the buffers taken and not given
+// back
+type countingPool struct {
+	*bufpool.Pool
+	out atomic.Int64
+}
+
+func (p *countingPool) Get() []byte {
+	p.out.Add(1)
+	return p.Pool.Get()
+}
+
+func (p *countingPool) Put(buf []byte) {
+	if buf != nil {
+		p.out.Add(-1)
+	}
+	p.Pool.Put(buf)
+}
+
+// testUpload holds what an upload of parts was given
+type testUpload struct {
+	opts    Options
+	chunks  *countingPool
+	buffers *countingPool
+	budget  *memory.Budget
+}
+
+// newTestUpload returns an upload of articles of articleSize to servers,
+// over connections connections
+func newTestUpload(articleSize int, connections int, servers ...Server) *testUpload {
+	var cfg models.Config
+	cfg.Posting.MaxArticleSize = int64(articleSize)
+	var ids atomic.Int64
+	budget := memory.NewBudget(1 << 30)
+	return &testUpload{
+		opts: Options{
+			Config:      cfg,
+			Servers:     servers,
+			MessageID:   func() string { return fmt.Sprintf("<%d@test>", ids.Add(1)) },
+			From:        "poster@example.com",
+			Connections: connections,
+			Budget:      budget,
+		},
+		chunks:  &countingPool{Pool: bufpool.New(articleSize)},
+		buffers: &countingPool{Pool: bufpool.New(yenc.EncodedSize(articleSize, 0))},
+		budget:  budget,
+	}
+}
+
+func (u *testUpload) run(ctx context.Context, parts []*models.FilePart) ([]*models.PostSegment, error) {
+	return upload(ctx, parts, u.opts, u.chunks, u.buffers)
+}
+
+// checkReleased fails when buffers or memory of the upload were not given
+// back
+func (u *testUpload) checkReleased(t *testing.T) {
+	t.Helper()
+	if out := u.chunks.out.Load(); out != 0 {
+		t.Errorf("%d chunk buffers not given back", out)
+	}
+	if out := u.buffers.out.Load(); out != 0 {
+		t.Errorf("%d article buffers not given back", out)
+	}
+	if used := u.budget.Used(); used != 0 {
+		t.Errorf("%d bytes of the memory budget not released", used)
+	}
+}
+
+// testParts writes size bytes to a file and returns them with its parts of
+// partSize
+func testParts(t *testing.T, size int, partSize int64) ([]byte, []*models.FilePart, map[string]uint32) {
+	t.Helper()
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	source := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(source, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	split := splitter.NewSplitter(partSize)
+	parts, err := split.Split(context.Background(), source, splitter.Options{Storage: splitter.StorageView})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data, parts, split.FileCRCs()
+}
+
+// subjectCounter is the article counter of a subject
+var subjectCounter = regexp.MustCompile(`yEnc \((\d+)/(\d+)\)`)
+
+func TestPartsNumbersArticlesInFileOrder(t *testing.T) {
+	// Parts of 1000 bytes in articles of 300: the parts of one file are one
+	// multi-part post of 10 articles, 4 per whole part
+	data, parts, crcs := testParts(t, 2500, 1000)
+	server := newFakeServer("news.example.com")
+	u := newTestUpload(300, 3, server)
+	u.opts.SourceCRCs = crcs
+	u.opts.Config.Performance.Readahead = 2
+
+	var mu sync.Mutex
+	var planned []int
+	u.opts.Hooks.Planned = func(fileName string, first int, chunks int) {
+		mu.Lock()
+		defer mu.Unlock()
+		planned = append(planned, first, chunks)
+	}
+	segments, err := u.run(context.Background(), parts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.checkReleased(t)
+	if fmt.Sprint(planned) != "[1 4 5 4 9 2]" {
+		t.Errorf("expected parts planned from articles 1, 5 and 9, got %v", planned)
+	}
+	if len(segments) != 10 {
+		t.Fatalf("expected 10 segments, got %d", len(segments))
+	}
+
+	numbers := make(map[int]bool)
+	for _, segment := range segments {
+		numbers[segment.PartNumber] = true
+		if segment.TotalParts != 10 || segment.Server != "news.example.com" {
+			t.Errorf("unexpected segment %+v", segment)
+		}
+		part, err := yenc.DecodePart([]byte(server.articles[segment.MessageID]))
+		if err != nil {
+			t.Fatalf("article %d: %v", segment.PartNumber, err)
+		}
+		// The article is placed in the file by its number, as the subject counts it
+		if part.Number != segment.PartNumber || part.Total != 10 || part.Size != int64(len(data)) {
+			t.Errorf("article %d: unexpected yEnc header %+v", segment.PartNumber, part)
+		}
+		if !bytes.Equal(part.Data, data[part.Begin-1:part.End]) {
+			t.Errorf("article %d: data of %d-%d differs from the file", segment.PartNumber, part.Begin, part.End)
+		}
+		if counter := subjectCounter.FindStringSubmatch(segment.Subject); counter == nil ||
+			counter[1] != strconv.Itoa(part.Number) || counter[2] != strconv.Itoa(part.Total) {
+			t.Errorf("article %d: subject %q does not count it as %d/%d", segment.PartNumber, segment.Subject, part.Number, part.Total)
+		}
+		if !part.HasFileCRC || part.FileCRC32 != crcs[parts[0].SourcePath] {
+			t.Errorf("article %d: expected the file CRC in the trailer", segment.PartNumber)
+		}
+	}
+	for number := 1; number <= 10; number++ {
+		if !numbers[number] {
+			t.Errorf("article %d was not posted", number)
+		}
+	}
+}
+
+func TestPartsCancelled(t *testing.T) {
+	for _, readahead := range []int{0, 2} {
+		_, parts, _ := testParts(t, 5000, 5000)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		server := newFakeServer("news.example.com")
+		// The upload is interrupted while the third article is posted
+		server.refuse = func(n int, subject string) error {
+			if n == 3 {
+				cancel()
+			}
+			return nil
+		}
+		u := newTestUpload(100, 2, server)
+		u.opts.Config.Performance.Readahead = readahead
+
+		segments, err := u.run(ctx, parts)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("readahead %d: expected the upload cancelled, got %v", readahead, err)
+		}
+		if len(segments) == 0 || len(segments) >= 50 {
+			t.Errorf("readahead %d: expected the articles posted before the interruption, got %d", readahead, len(segments))
+		}
+		u.checkReleased(t)
+	}
+}
+
+func TestPartsReadErrors(t *testing.T) {
+	_, parts, _ := testParts(t, 1000, 1000)
+	// The source of the second file went away
+	missing := &models.FilePart{PartNumber: 1, FileName: "gone.bin", Size: 500, SourcePath: filepath.Join(t.TempDir(), "gone.bin")}
+	parts = append(parts, missing)
+
+	server := newFakeServer("news.example.com")
+	u := newTestUpload(100, 2, server)
+	var failed atomic.Int64
+	u.opts.Hooks.Failed = func(segment *models.PostSegment, err error) {
+		failed.Add(1)
+	}
+	segments, err := u.run(context.Background(), parts)
+	var incomplete *IncompleteError
+	if !errors.As(err, &incomplete) || incomplete.Failed != 5 || failed.Load() != 5 {
+		t.Fatalf("expected the 5 articles of the missing file to fail, got %v", err)
+	}
+	if len(segments) != 10 {
+		t.Errorf("expected the 10 articles of the other file posted, got %d", len(segments))
+	}
+	u.checkReleased(t)
+
+	// The articles encoded when the upload is aborted are dropped
+	u = newTestUpload(100, 2, server)
+	u.opts.Config.Posting.OnError = models.OnErrorAbort
+	if _, err := u.run(context.Background(), parts); err == nil || errors.As(err, &incomplete) {
+		t.Errorf("expected the upload aborted, got %v", err)
+	}
+	u.checkReleased(t)
+}
+
+// failArticle returns a refuse function of fakeServer failing the article
+// of number, for its first times posts when times is positive
+func failArticle(number int, times int) func(n int, subject string) error {
+	counter := fmt.Sprintf("yEnc (%d/", number)
+	failures := 0
+	return func(n int, subject string) error {
+		if !strings.Contains(subject, counter) || (times > 0 && failures == times) {
+			return nil
+		}
+		failures++
+		return errors.New("500 article rejected")
+	}
+}
+
+func TestPartsOnErrorPolicies(t *testing.T) {
+	_, parts, _ := testParts(t, 1000, 1000)
+
+	// skip posts the other articles, the upload left incomplete
+	server := newFakeServer("news.example.com")
+	server.refuse = failArticle(3, 0)
+	u := newTestUpload(100, 2, server)
+	u.opts.Config.Posting.OnError = models.OnErrorSkip
+	var failed []int
+	u.opts.Hooks.Failed = func(segment *models.PostSegment, err error) {
+		failed = append(failed, segment.PartNumber)
+	}
+	segments, err := u.run(context.Background(), parts)
+	var incomplete *IncompleteError
+	if !errors.As(err, &incomplete) || incomplete.Failed != 1 || fmt.Sprint(failed) != "[3]" {
+		t.Fatalf("expected article 3 to fail alone, got %v and %v", err, failed)
+	}
+	if len(segments) != 9 {
+		t.Errorf("expected the 9 other articles posted, got %d", len(segments))
+	}
+	u.checkReleased(t)
+
+	// abort stops at the failure
+	server = newFakeServer("news.example.com")
+	server.refuse = failArticle(3, 0)
+	u = newTestUpload(100, 1, server)
+	u.opts.Config.Posting.OnError = models.OnErrorAbort
+	segments, err = u.run(context.Background(), parts)
+	if err == nil || errors.As(err, &incomplete) || !strings.Contains(err.Error(), "upload aborted") {
+		t.Fatalf("expected the upload aborted, got %v", err)
+	}
+	if len(segments) >= 9 {
+		t.Errorf("expected the articles after the failure left out, got %d posted", len(segments))
+	}
+	u.checkReleased(t)
+
+	// retry posts the article again after the others, with the failures of
+	// its earlier rounds
+	server = newFakeServer("news.example.com")
+	server.refuse = failArticle(3, 2)
+	u = newTestUpload(100, 2, server)
+	u.opts.Config.Posting.OnError = models.OnErrorRetry
+	segments, err = u.run(context.Background(), parts)
+	if err != nil {
+		t.Fatalf("expected article 3 posted on its third round, got %v", err)
+	}
+	if len(segments) != 10 {
+		t.Fatalf("expected 10 articles posted, got %d", len(segments))
+	}
+	for _, segment := range segments {
+		if segment.PartNumber == 3 && (len(segment.Failures) != 2 || segment.Retries != 2) {
+			t.Errorf("expected the 2 failures of article 3 recorded, got %+v", segment.Failures)
+		}
+	}
+	u.checkReleased(t)
+
+	// and gives up after requeueRounds rounds
+	server = newFakeServer("news.example.com")
+	server.refuse = failArticle(3, 0)
+	u = newTestUpload(100, 2, server)
+	u.opts.Config.Posting.OnError = models.OnErrorRetry
+	if _, err := u.run(context.Background(), parts); !errors.As(err, &incomplete) || incomplete.Failed != 1 {
+		t.Fatalf("expected article 3 to fail after its rounds, got %v", err)
+	}
+	if server.posted != 10+requeueRounds {
+		t.Errorf("expected article 3 posted %d times, got %d posts in all", 1+requeueRounds, server.posted)
+	}
+	u.checkReleased(t)
+}
+
+func TestPartsRetryDelayStopsWithContext(t *testing.T) {
+	_, parts, _ := testParts(t, 100, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := newFakeServer("news.example.com")
+	// The upload is interrupted once the article failed, before its retry
+	server.refuse = func(n int, subject string) error {
+		cancel()
+		return errors.New("500 article rejected")
+	}
+	u := newTestUpload(100, 1, server)
+	u.opts.Config.Posting.Retries = 3
+	failed := false
+	u.opts.Hooks.Failed = func(segment *models.PostSegment, err error) {
+		failed = true
+	}
+
+	start := time.Now()
+	_, err := u.run(ctx, parts)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the upload cancelled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= retryDelay {
+		t.Errorf("expected the upload to stop without waiting for the retry, took %v", elapsed)
+	}
+	if failed || server.posted != 1 {
+		t.Errorf("expected the interrupted article neither failed nor posted again, got %d posts", server.posted)
+	}
+	u.checkReleased(t)
+}
+
+func TestRefusals(t *testing.T) {
+	refused := &textproto.Error{Code: 441, Msg: "posting failed"}
+	var r refusals
+	for i := 1; i < startRefusals; i++ {
+		if _, stop := r.add(refused); stop {
+			t.Fatalf("refusal %d stopped the upload", i)
+		}
+	}
+	// Another failure breaks the refusals in a row
+	r.add(errors.New("connection reset"))
+	for i := 1; i < startRefusals; i++ {
+		r.add(refused)
+	}
+	if code, stop := r.add(refused); !stop || code != 441 {
+		t.Fatalf("expected %d refusals in a row to stop the upload", startRefusals)
+	}
+
+	// A group the server does not carry stops it at once
+	r = refusals{}
+	if code, stop := r.add(&textproto.Error{Code: 411, Msg: "no such group"}); !stop || code != 411 {
+		t.Error("expected a 411 to stop the upload")
+	}
+
+	// Refusals after an article was posted are of the articles
+	r = refusals{}
+	r.add(nil)
+	for i := 0; i < 2*startRefusals; i++ {
+		if _, stop := r.add(refused); stop {
+			t.Fatal("a refusal after an article was posted stopped the upload")
+		}
+	}
+	if _, stop := (&refusals{posted: true}).add(&textproto.Error{Code: 440}); stop {
+		t.Error("expected the articles reused from an earlier run to count as posted")
+	}
+}
+
+func TestPartsRefusedAtStart(t *testing.T) {
+	_, parts, _ := testParts(t, 1000, 1000)
+	refuse := func(n int, subject string) error {
+		return &textproto.Error{Code: 441, Msg: "posting failed"}
+	}
+
+	server := newFakeServer("news.example.com")
+	server.refuse = refuse
+	u := newTestUpload(100, 1, server)
+	_, err := u.run(context.Background(), parts)
+	if err == nil || !strings.Contains(err.Error(), explainRefusal(441)) {
+		t.Fatalf("expected the upload aborted as refused, got %v", err)
+	}
+	u.checkReleased(t)
+
+	// Once an article is posted, refusals leave the others out
+	server = newFakeServer("news.example.com")
+	server.refuse = func(n int, subject string) error {
+		if n == 1 {
+			return nil
+		}
+		return refuse(n, subject)
+	}
+	u = newTestUpload(100, 1, server)
+	var incomplete *IncompleteError
+	if _, err := u.run(context.Background(), parts); !errors.As(err, &incomplete) || incomplete.Failed != 9 {
+		t.Fatalf("expected the 9 refused articles left out, got %v", err)
+	}
+	u.checkReleased(t)
+}
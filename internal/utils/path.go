@@ -2,8 +2,10 @@ package utils
 
 import (
 	"fmt"
+	"io/fs"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -33,6 +35,58 @@ func GetUnifiedOutputPath(outputDir, filename string) string {
 	return filepath.Join(outputDir, folderName)
 }
 
+// CollectFiles walks root recursively and returns the regular files below it in
+// lexical order. A root that is a file is returned as the only entry.
+func CollectFiles(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", root, err)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// RelativeName returns path relative to root using forward slashes, as used in
+// subjects and NZB file names. A root that is the file itself yields its base name.
+func RelativeName(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return filepath.Base(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// PostedNames returns the name each file below root is posted as: its path
+// relative to root with preservePaths, its base name otherwise. Two files
+// posted under the same name are an error, as they would clash in the NZB and
+// the recovery set.
+func PostedNames(root string, files []string, preservePaths bool) ([]string, error) {
+	names := make([]string, len(files))
+	seen := make(map[string]string)
+	for i, file := range files {
+		name := filepath.Base(file)
+		if preservePaths {
+			name = RelativeName(root, file)
+		}
+		if other, ok := seen[name]; ok {
+			return nil, fmt.Errorf("%s and %s would both be posted as %s", other, file, name)
+		}
+		seen[name] = file
+		names[i] = name
+	}
+	return names, nil
+}
+
 // ParseFileSize parses a file size string (e.g., "50MB", "1.5GB") into bytes
 func ParseFileSize(sizeStr string) (int64, error) {
 	if sizeStr == "" {
@@ -77,4 +131,18 @@ func ParseFileSize(sizeStr string) (int64, error) {
 	}
 	
 	return int64(value * float64(multiplier)), nil
-}
\ No newline at end of file
+}
+// FormatFileSize formats a byte count the way subjects show it (e.g. "1.5GB"),
+// the reverse of ParseFileSize
+func FormatFileSize(size int64) string {
+	value := float64(size)
+	switch {
+	case value >= 1024*1024*1024:
+		return fmt.Sprintf("%.1fGB", value/(1024*1024*1024))
+	case value >= 1024*1024:
+		return fmt.Sprintf("%.1fMB", value/(1024*1024))
+	case value >= 1024:
+		return fmt.Sprintf("%.1fKB", value/1024)
+	}
+	return fmt.Sprintf("%dB", size)
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -37,4 +39,81 @@ func TestParseFileSize(t *testing.T) {
 			}
 		}
 	}
-}
\ No newline at end of file
+}
+func TestFormatFileSize(t *testing.T) {
+	tests := map[int64]string{
+		512:                    "512B",
+		1536:                   "1.5KB",
+		50 * 1024 * 1024:       "50.0MB",
+		3 * 1024 * 1024 * 1024: "3.0GB",
+	}
+	for size, expected := range tests {
+		if actual := FormatFileSize(size); actual != expected {
+			t.Errorf("FormatFileSize(%d): expected %q, got %q", size, expected, actual)
+		}
+	}
+}
+
+func TestCollectFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"b.bin", "a/c.bin", "a/deep/d.bin"} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := CollectFiles(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"a/c.bin", "a/deep/d.bin", "b.bin"}
+	if len(files) != len(expected) {
+		t.Fatalf("expected %d files, got %v", len(expected), files)
+	}
+	for i, file := range files {
+		if name := RelativeName(root, file); name != expected[i] {
+			t.Errorf("file %d: expected %q, got %q", i, expected[i], name)
+		}
+	}
+
+	// A single file is its own root
+	single, err := CollectFiles(files[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(single) != 1 || RelativeName(files[2], single[0]) != "b.bin" {
+		t.Errorf("unexpected result for file root: %v", single)
+	}
+}
+
+func TestPostedNames(t *testing.T) {
+	root := filepath.Join("in", "job")
+	files := []string{
+		filepath.Join(root, "a", "x.bin"),
+		filepath.Join(root, "b", "x.bin"),
+		filepath.Join(root, "y.bin"),
+	}
+
+	if _, err := PostedNames(root, files, false); err == nil {
+		t.Error("expected an error for files posted under the same name")
+	}
+	if names, err := PostedNames(root, files[1:], false); err != nil || names[0] != "x.bin" || names[1] != "y.bin" {
+		t.Errorf("unexpected base names %v: %v", names, err)
+	}
+
+	names, err := PostedNames(root, files, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"a/x.bin", "b/x.bin", "y.bin"}
+	for i, name := range names {
+		if name != expected[i] {
+			t.Errorf("file %d: expected %q, got %q", i, expected[i], name)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+
+	"ypost/internal/nntp"
+	"ypost/internal/yenc"
+)
+
+// Conn is a reader connection able to fetch article bodies
+type Conn interface {
+	Body(messageID string) ([]byte, error)
+	Quit() error
+}
+
+// Server is a reader server to fetch the articles back from
+type Server struct {
+	Name        string
+	Connections int
+	Dial        func() (Conn, error)
+}
+
+// Sample is the part of the posted articles verified: a percentage of them,
+// or a number of them when Count is set
+type Sample struct {
+	Percent float64
+	Count   int
+}
+
+// ParseSample parses a verification mode: "sample:5%" verifies 5% of the
+// articles, "sample:20" 20 of them and "all" every one. Empty or "none"
+// verifies none.
+func ParseSample(spec string) (Sample, error) {
+	spec = strings.TrimSpace(spec)
+	switch spec {
+	case "", "none":
+		return Sample{}, nil
+	case "all":
+		return Sample{Percent: 100}, nil
+	}
+
+	value, ok := strings.CutPrefix(spec, "sample:")
+	if !ok {
+		return Sample{}, fmt.Errorf("invalid verify mode %q (expected none, all, sample:N%% or sample:N)", spec)
+	}
+	if percent, ok := strings.CutSuffix(value, "%"); ok {
+		p, err := strconv.ParseFloat(percent, 64)
+		if err != nil || p <= 0 || p > 100 {
+			return Sample{}, fmt.Errorf("invalid verify sample %q: the percentage must be above 0 and at most 100", spec)
+		}
+		return Sample{Percent: p}, nil
+	}
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 1 {
+		return Sample{}, fmt.Errorf("invalid verify sample %q: the number of articles must be at least 1", spec)
+	}
+	return Sample{Count: count}, nil
+}
+
+// Enabled reports whether any article is verified
+func (s Sample) Enabled() bool {
+	return s.Percent > 0 || s.Count > 0
+}
+
+// Size returns the number of articles verified out of total, at least one
+// when enabled
+func (s Sample) Size(total int) int {
+	if !s.Enabled() || total == 0 {
+		return 0
+	}
+	size := s.Count
+	if size == 0 {
+		size = int(float64(total)*s.Percent/100 + 0.5)
+	}
+	return min(max(size, 1), total)
+}
+
+// Article is a posted article with the CRC32 and size of the data it holds,
+// as read from the source
+type Article struct {
+	MessageID string
+	FileName  string
+	Number    int
+	Size      int64
+	CRC32     uint32
+}
+
+// Problem is an article that did not come back as posted
+type Problem struct {
+	Article
+	// Missing is set when no server had the article, which may also be an
+	// article not yet propagated
+	Missing bool
+	// Reason tells how a corrupt article differs
+	Reason string
+}
+
+// Report is the outcome of a verification
+type Report struct {
+	// Articles is the number of articles posted, Sampled those verified
+	Articles int
+	Sampled  int
+	Problems []Problem
+}
+
+// OK reports whether every article verified came back intact
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Counts returns the number of corrupt and missing articles
+func (r *Report) Counts() (corrupt, missing int) {
+	for _, problem := range r.Problems {
+		if problem.Missing {
+			missing++
+		} else {
+			corrupt++
+		}
+	}
+	return corrupt, missing
+}
+
+// Choose picks the sample of articles verified, at random
+func Choose(articles []Article, sample Sample) []Article {
+	chosen := make([]Article, 0, sample.Size(len(articles)))
+	for _, i := range rand.Perm(len(articles))[:cap(chosen)] {
+		chosen = append(chosen, articles[i])
+	}
+	return chosen
+}
+
+// Verify fetches a sample of the articles back, each from the first server
+// having it, and checks its decoded data against the size and CRC32 of the
+// source. A connection failing is reopened once per article before the
+// verification gives up.
+func Verify(ctx context.Context, articles []Article, sample Sample, servers []Server) (*Report, error) {
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no servers to verify with")
+	}
+	chosen := Choose(articles, sample)
+	report := &Report{Articles: len(articles), Sampled: len(chosen)}
+
+	connections := max(servers[0].Connections, 1)
+	refs := make(chan int)
+	problems := make([]*Problem, len(chosen))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for w := 0; w < connections; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conns := make([]Conn, len(servers))
+			defer func() {
+				for _, conn := range conns {
+					if conn != nil {
+						conn.Quit()
+					}
+				}
+			}()
+			for i := range refs {
+				problem, err := verifyArticle(chosen[i], servers, conns)
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				problems[i] = problem
+			}
+		}()
+	}
+
+	go func() {
+		defer close(refs)
+		for i := range chosen {
+			select {
+			case refs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for _, problem := range problems {
+		if problem != nil {
+			report.Problems = append(report.Problems, *problem)
+		}
+	}
+	return report, nil
+}
+
+// verifyArticle fetches an article from the first server having it over the
+// worker's connections, nil problem meaning it came back intact
+func verifyArticle(article Article, servers []Server, conns []Conn) (*Problem, error) {
+	for i, server := range servers {
+		body, err := fetch(server, &conns[i], article.MessageID)
+		if errors.Is(err, nntp.ErrNoArticle) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s from %s: %w", article.MessageID, server.Name, err)
+		}
+		if reason := compare(article, body); reason != "" {
+			return &Problem{Article: article, Reason: fmt.Sprintf("%s: %s", server.Name, reason)}, nil
+		}
+		return nil, nil
+	}
+	return &Problem{Article: article, Missing: true, Reason: "not found on any server"}, nil
+}
+
+// fetch fetches an article body from a server, dialing once more when the
+// connection fails
+func fetch(server Server, conn *Conn, messageID string) ([]byte, error) {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if *conn == nil {
+			if *conn, err = server.Dial(); err != nil {
+				*conn = nil
+				continue
+			}
+		}
+		var body []byte
+		body, err = (*conn).Body(messageID)
+		if err == nil || errors.Is(err, nntp.ErrNoArticle) {
+			return body, err
+		}
+		(*conn).Quit()
+		*conn = nil
+	}
+	return nil, err
+}
+
+// compare decodes an article body and tells how it differs from the source,
+// empty when it does not
+func compare(article Article, body []byte) string {
+	part, err := yenc.DecodePart(body)
+	if err != nil {
+		return err.Error()
+	}
+	if int64(len(part.Data)) != article.Size {
+		return fmt.Sprintf("%d bytes instead of %d", len(part.Data), article.Size)
+	}
+	if crc := crc32.ChecksumIEEE(part.Data); crc != article.CRC32 {
+		return fmt.Sprintf("CRC32 %08x instead of %08x", crc, article.CRC32)
+	}
+	return ""
+}
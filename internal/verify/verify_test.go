@@ -0,0 +1,170 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"ypost/internal/nntp"
+	"ypost/internal/yenc"
+)
+
+// fakeConn serves article bodies from a map
+type fakeConn struct {
+	articles map[string][]byte
+}
+
+func (c *fakeConn) Body(messageID string) ([]byte, error) {
+	body, ok := c.articles[messageID]
+	if !ok {
+		return nil, nntp.ErrNoArticle
+	}
+	return body, nil
+}
+
+func (c *fakeConn) Quit() error { return nil }
+
+// post encodes chunks as articles and returns them as posted
+func post(articles map[string][]byte, name string, chunks [][]byte) []Article {
+	var posted []Article
+	var size, offset int64
+	for _, chunk := range chunks {
+		size += int64(len(chunk))
+	}
+	for i, chunk := range chunks {
+		id := fmt.Sprintf("%s-%d@test", name, i+1)
+		encoder := &yenc.Encoder{}
+		articles[id] = []byte(strings.ReplaceAll(encoder.Encode(chunk, yenc.Article{Name: name, Number: i + 1, Total: len(chunks), FileSize: size, Offset: offset}), "\r\n", "\n"))
+		posted = append(posted, Article{MessageID: id, FileName: name, Number: i + 1, Size: int64(len(chunk)), CRC32: crc32.ChecksumIEEE(chunk)})
+		offset += int64(len(chunk))
+	}
+	return posted
+}
+
+func TestParseSample(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Sample
+		wantErr bool
+	}{
+		{"", Sample{}, false},
+		{"none", Sample{}, false},
+		{"all", Sample{Percent: 100}, false},
+		{"sample:5%", Sample{Percent: 5}, false},
+		{"sample:0.5%", Sample{Percent: 0.5}, false},
+		{"sample:20", Sample{Count: 20}, false},
+		{"sample:0%", Sample{}, true},
+		{"sample:101%", Sample{}, true},
+		{"sample:0", Sample{}, true},
+		{"sample:", Sample{}, true},
+		{"5%", Sample{}, true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseSample(test.spec)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: unexpected error %v", test.spec, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q: got %+v, want %+v", test.spec, got, test.want)
+		}
+	}
+}
+
+func TestSampleSize(t *testing.T) {
+	tests := []struct {
+		sample Sample
+		total  int
+		want   int
+	}{
+		{Sample{}, 100, 0},
+		{Sample{Percent: 5}, 100, 5},
+		{Sample{Percent: 5}, 10, 1},
+		{Sample{Percent: 100}, 7, 7},
+		{Sample{Count: 20}, 100, 20},
+		{Sample{Count: 20}, 8, 8},
+		{Sample{Percent: 5}, 0, 0},
+	}
+
+	for _, test := range tests {
+		if got := test.sample.Size(test.total); got != test.want {
+			t.Errorf("%+v of %d: got %d, want %d", test.sample, test.total, got, test.want)
+		}
+	}
+}
+
+func TestChoose(t *testing.T) {
+	articles := make([]Article, 40)
+	for i := range articles {
+		articles[i].MessageID = fmt.Sprintf("%d@test", i)
+	}
+
+	chosen := Choose(articles, Sample{Percent: 25})
+	if len(chosen) != 10 {
+		t.Fatalf("expected 10 articles, got %d", len(chosen))
+	}
+	seen := make(map[string]bool)
+	for _, article := range chosen {
+		if seen[article.MessageID] {
+			t.Errorf("%s chosen twice", article.MessageID)
+		}
+		seen[article.MessageID] = true
+	}
+}
+
+func TestVerify(t *testing.T) {
+	stored := make(map[string][]byte)
+	chunks := [][]byte{
+		bytes.Repeat([]byte("intact "), 50),
+		bytes.Repeat([]byte("corrupt "), 50),
+		bytes.Repeat([]byte("missing "), 50),
+		bytes.Repeat([]byte("other "), 50),
+	}
+	articles := post(stored, "data.bin", chunks)
+
+	// The provider damaged the second article, its trailer matching the
+	// damaged data, and lost the third; the fourth only the backup has
+	corrupted := append([]byte(nil), chunks[1]...)
+	corrupted[10] ^= 0xff
+	post(stored, "damaged", [][]byte{nil, corrupted})
+	stored[articles[1].MessageID] = stored["damaged-2@test"]
+	delete(stored, articles[2].MessageID)
+	backup := map[string][]byte{articles[3].MessageID: stored[articles[3].MessageID]}
+	delete(stored, articles[3].MessageID)
+
+	servers := []Server{
+		{Name: "primary", Connections: 2, Dial: func() (Conn, error) { return &fakeConn{articles: stored}, nil }},
+		{Name: "backup", Dial: func() (Conn, error) { return &fakeConn{articles: backup}, nil }},
+	}
+	report, err := Verify(context.Background(), articles, Sample{Percent: 100}, servers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Articles != 4 || report.Sampled != 4 {
+		t.Errorf("expected 4 of 4 articles verified, got %d of %d", report.Sampled, report.Articles)
+	}
+	if corrupt, missing := report.Counts(); corrupt != 1 || missing != 1 || report.OK() {
+		t.Fatalf("expected 1 corrupt and 1 missing article, got %+v", report.Problems)
+	}
+	for _, problem := range report.Problems {
+		switch {
+		case problem.Missing && problem.Number != 3:
+			t.Errorf("unexpected missing article %d", problem.Number)
+		case !problem.Missing && (problem.Number != 2 || !strings.Contains(problem.Reason, "CRC32")):
+			t.Errorf("unexpected corrupt article %d: %s", problem.Number, problem.Reason)
+		}
+	}
+}
+
+func TestVerifyConnectionError(t *testing.T) {
+	articles := post(make(map[string][]byte), "data.bin", [][]byte{[]byte("data")})
+	servers := []Server{{Name: "down", Dial: func() (Conn, error) { return nil, fmt.Errorf("connection refused") }}}
+	if _, err := Verify(context.Background(), articles, Sample{Count: 1}, servers); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("expected the connection error, got %v", err)
+	}
+}
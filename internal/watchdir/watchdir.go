@@ -0,0 +1,148 @@
+package watchdir
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Default timings of a Folder
+const (
+	DefaultInterval = 10 * time.Second
+	DefaultSettle   = 30 * time.Second
+)
+
+// Folder tracks the entries of a watched directory until they stop changing.
+// Each file or directory directly inside it is one entry; a directory is
+// ready once nothing below it has changed.
+type Folder struct {
+	dir    string
+	settle time.Duration
+	skip   map[string]bool
+	seen   map[string]*entryState
+}
+
+// entryState is the last observed state of an entry
+type entryState struct {
+	size     int64
+	modTime  time.Time
+	since    time.Time
+	reported bool
+}
+
+// New watches dir. An entry is ready once its size and modification time
+// have not changed for settle. Entries named in skip (the done and failed
+// folders when they are inside dir) and hidden entries are ignored.
+func New(dir string, settle time.Duration, skip ...string) *Folder {
+	f := &Folder{dir: dir, settle: settle, skip: make(map[string]bool), seen: make(map[string]*entryState)}
+	for _, path := range skip {
+		if rel, err := filepath.Rel(dir, path); err == nil && !strings.Contains(rel, string(filepath.Separator)) {
+			f.skip[rel] = true
+		}
+	}
+	return f
+}
+
+// Scan observes the entries at now and returns the paths of those that are
+// ready, in name order. A ready entry is returned once, unless it changes
+// again.
+func (f *Folder) Scan(now time.Time) ([]string, error) {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.dir, err)
+	}
+
+	present := make(map[string]bool)
+	var ready []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if f.skip[name] || strings.HasPrefix(name, ".") {
+			continue
+		}
+		present[name] = true
+
+		path := filepath.Join(f.dir, name)
+		size, modTime, err := measure(path)
+		if err != nil {
+			// Removed or unreadable while scanning; look again next time
+			delete(f.seen, name)
+			continue
+		}
+
+		state, ok := f.seen[name]
+		if !ok || state.size != size || !state.modTime.Equal(modTime) {
+			f.seen[name] = &entryState{size: size, modTime: modTime, since: now}
+			continue
+		}
+		if !state.reported && now.Sub(state.since) >= f.settle {
+			ready = append(ready, path)
+			state.reported = true
+		}
+	}
+
+	for name := range f.seen {
+		if !present[name] {
+			delete(f.seen, name)
+		}
+	}
+
+	sort.Strings(ready)
+	return ready, nil
+}
+
+// measure returns the total size and latest modification time of a file or
+// everything below a directory
+func measure(path string) (int64, time.Time, error) {
+	var size int64
+	var latest time.Time
+	err := filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+	return size, latest, err
+}
+
+// Move moves path into dir, creating it, and returns the new path. An entry
+// of the same name already there is kept and the moved one numbered instead
+// (name-1.ext, name-2.ext, ...).
+func Move(path string, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	name := filepath.Base(path)
+	ext := filepath.Ext(name)
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		ext = ""
+	}
+	stem := strings.TrimSuffix(name, ext)
+
+	target := filepath.Join(dir, name)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(target); os.IsNotExist(err) {
+			break
+		}
+		target = filepath.Join(dir, fmt.Sprintf("%s-%d%s", stem, i, ext))
+	}
+
+	if err := os.Rename(path, target); err != nil {
+		return "", fmt.Errorf("failed to move %s: %w", path, err)
+	}
+	return target, nil
+}
@@ -0,0 +1,78 @@
+package watchdir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScanWaitsForEntriesToSettle(t *testing.T) {
+	dir := t.TempDir()
+	done := filepath.Join(dir, "done")
+	if err := os.MkdirAll(done, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name string, data string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("file.bin", "abc")
+	write("release/a.bin", "a")
+	write(".partial", "hidden")
+
+	folder := New(dir, time.Minute, done)
+	start := time.Now()
+
+	if ready, err := folder.Scan(start); err != nil || len(ready) != 0 {
+		t.Fatalf("nothing should be ready on first sight: %v, %v", ready, err)
+	}
+
+	// The directory keeps growing, the file does not
+	write("release/b.bin", "bb")
+	ready, err := folder.Scan(start.Add(2 * time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ready) != 1 || ready[0] != filepath.Join(dir, "file.bin") {
+		t.Fatalf("expected only the settled file, got %v", ready)
+	}
+
+	ready, err = folder.Scan(start.Add(4 * time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ready) != 1 || ready[0] != filepath.Join(dir, "release") {
+		t.Fatalf("expected the settled directory, got %v", ready)
+	}
+
+	// Ready entries are returned once
+	if ready, _ := folder.Scan(start.Add(6 * time.Minute)); len(ready) != 0 {
+		t.Errorf("entries returned twice: %v", ready)
+	}
+}
+
+func TestMoveKeepsExistingEntries(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "done")
+	for i := 0; i < 3; i++ {
+		path := filepath.Join(dir, "file.bin")
+		if err := os.WriteFile(path, []byte{byte(i)}, 0644); err != nil {
+			t.Fatal(err)
+		}
+		moved, err := Move(path, target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"file.bin", "file-1.bin", "file-2.bin"}[i]
+		if moved != filepath.Join(target, expected) {
+			t.Errorf("move %d: expected %s, got %s", i, expected, moved)
+		}
+	}
+}
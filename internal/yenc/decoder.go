@@ -0,0 +1,153 @@
+package yenc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+)
+
+// ErrCRCMismatch is returned when decoded data does not match the checksum
+// of the trailer
+var ErrCRCMismatch = errors.New("yEnc CRC32 mismatch")
+
+// Part is a decoded yEnc article
+type Part struct {
+	// Name is the file name of the header
+	Name string
+	// Number and Total are the part counters of a multi-part post, 0 otherwise
+	Number int
+	Total  int
+	// Size is the size given in the header
+	Size int64
+	// Begin and End are the 1-based offsets of the =ypart line, 0 without one
+	Begin int64
+	End   int64
+	// FileCRC32 is the checksum of the whole file given by the trailer of a
+	// multi-part post, with HasFileCRC set when there is one
+	FileCRC32  uint32
+	HasFileCRC bool
+	Data       []byte
+}
+
+// DecodePart decodes a yEnc article body. Lines before =ybegin are skipped and
+// line breaks within the data ignored. A part of a multi-part post must give
+// its offsets in a =ypart line. The decoded size is checked against the
+// trailer, and the data against pcrc32, or crc32 for an article without a
+// =ypart line; a checksum mismatch returns the part along with an error
+// wrapping ErrCRCMismatch.
+func DecodePart(body []byte) (*Part, error) {
+	lines := bytes.Split(body, []byte("\n"))
+
+	start := -1
+	for i, line := range lines {
+		if bytes.HasPrefix(line, []byte(yencHeader+" ")) {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("no =ybegin line")
+	}
+
+	part := &Part{}
+	header := parseParams(string(bytes.TrimRight(lines[start], "\r")))
+	part.Name = header["name"]
+	part.Number, _ = strconv.Atoi(header["part"])
+	part.Total, _ = strconv.Atoi(header["total"])
+	part.Size, _ = strconv.ParseInt(header["size"], 10, 64)
+
+	i := start + 1
+	if i < len(lines) && bytes.HasPrefix(lines[i], []byte("=ypart ")) {
+		params := parseParams(string(bytes.TrimRight(lines[i], "\r")))
+		part.Begin, _ = strconv.ParseInt(params["begin"], 10, 64)
+		part.End, _ = strconv.ParseInt(params["end"], 10, 64)
+		i++
+	}
+	if _, ok := header["part"]; ok && part.Begin == 0 {
+		return nil, fmt.Errorf("no =ypart line for part %d", part.Number)
+	}
+
+	var trailer map[string]string
+	var escaped bool
+	data := make([]byte, 0, len(body))
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if bytes.HasPrefix(line, []byte(yencTrailer+" ")) {
+			trailer = parseParams(string(bytes.TrimRight(line, "\r")))
+			break
+		}
+		data = appendDecoded(data, line, &escaped)
+	}
+	part.Data = data
+
+	if trailer == nil {
+		return nil, fmt.Errorf("no =yend line")
+	}
+	if size, ok := trailer["size"]; ok {
+		if expected, err := strconv.ParseInt(size, 10, 64); err == nil && expected != int64(len(data)) {
+			return nil, fmt.Errorf("yEnc size mismatch: expected %d bytes, got %d", expected, len(data))
+		}
+	}
+
+	expected, check := "", false
+	if value, ok := trailer["pcrc32"]; ok {
+		expected, check = value, true
+	} else if value, ok := trailer["crc32"]; ok && part.Begin == 0 {
+		expected, check = value, true
+	}
+	if value, ok := trailer["crc32"]; ok && part.Begin > 0 {
+		if crc, err := strconv.ParseUint(value, 16, 32); err == nil {
+			part.FileCRC32, part.HasFileCRC = uint32(crc), true
+		}
+	}
+	if check {
+		crc, err := strconv.ParseUint(expected, 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid yEnc CRC32 %q", expected)
+		}
+		if actual := crc32.ChecksumIEEE(data); actual != uint32(crc) {
+			return part, fmt.Errorf("%w: expected %08X, got %08X", ErrCRCMismatch, uint32(crc), actual)
+		}
+	}
+
+	return part, nil
+}
+
+// parseParams parses the key=value pairs of a yEnc control line. The name is
+// the rest of the line, as it may contain spaces.
+func parseParams(line string) map[string]string {
+	params := make(map[string]string)
+	if idx := strings.Index(line, " name="); idx >= 0 {
+		params["name"] = strings.TrimSpace(line[idx+len(" name="):])
+		line = line[:idx]
+	}
+	for _, field := range strings.Fields(line) {
+		if key, value, ok := strings.Cut(field, "="); ok && key != "" {
+			params[key] = value
+		}
+	}
+	return params
+}
+
+// appendDecoded appends the decoded bytes of a data line to data. An escape
+// character ending a line applies to the first character of the next one, as
+// lines may be split within an escape sequence.
+func appendDecoded(data []byte, line []byte, escaped *bool) []byte {
+	for _, c := range line {
+		switch {
+		case c == '\r' || c == '\n':
+			continue
+		case *escaped:
+			*escaped = false
+			c -= 64
+		case c == '=':
+			*escaped = true
+			continue
+		}
+		data = append(data, c-42)
+	}
+	return data
+}
@@ -0,0 +1,69 @@
+package yenc
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodePartRoundTrip(t *testing.T) {
+	// Every byte value, so escapes land at line ends too
+	data := make([]byte, 4096)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	encoder := &Encoder{}
+	body := encoder.Encode(data, Article{Name: "test file.bin", Number: 2, Total: 3, FileSize: 10000, Offset: 4096})
+	// NNTP readers hand bodies over with "\n" line ends
+	part, err := DecodePart([]byte(strings.ReplaceAll(body, "\r\n", "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(part.Data, data) {
+		t.Fatal("decoded data differs from the input")
+	}
+	if part.Name != "test file.bin" || part.Number != 2 || part.Total != 3 || part.Size != 10000 {
+		t.Errorf("unexpected header fields %+v", part)
+	}
+	if part.Begin != 4097 || part.End != 8192 {
+		t.Errorf("expected the part at 4097-8192, got %d-%d", part.Begin, part.End)
+	}
+}
+
+func TestDecodePartChecksAndOffsets(t *testing.T) {
+	body := "=ybegin part=1 total=2 line=128 size=6 name=abc.bin\n" +
+		"=ypart begin=1 end=3\n" +
+		string([]byte{'a' + 42, 'b' + 42, 'c' + 42}) + "\n" +
+		"=yend size=3 part=1 pcrc32=352441C2 crc32=4B8E39EF\n"
+	part, err := DecodePart([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(part.Data) != "abc" || part.Begin != 1 || part.End != 3 {
+		t.Errorf("unexpected part %+v", part)
+	}
+	if !part.HasFileCRC || part.FileCRC32 != 0x4B8E39EF {
+		t.Errorf("expected the file CRC of the trailer, got %08X", part.FileCRC32)
+	}
+
+	damaged := strings.Replace(body, "pcrc32=352441C2", "pcrc32=00000000", 1)
+	if part, err := DecodePart([]byte(damaged)); !errors.Is(err, ErrCRCMismatch) || part == nil {
+		t.Errorf("expected a CRC mismatch with the part, got %v", err)
+	}
+
+	truncated := strings.Replace(body, "=yend size=3", "=yend size=4", 1)
+	if _, err := DecodePart([]byte(truncated)); err == nil {
+		t.Error("expected a size mismatch")
+	}
+
+	unplaced := strings.Replace(body, "=ypart begin=1 end=3\n", "", 1)
+	if _, err := DecodePart([]byte(unplaced)); err == nil {
+		t.Error("expected an error for a part without a =ypart line")
+	}
+
+	if _, err := DecodePart([]byte("no yenc here\n")); err == nil {
+		t.Error("expected an error without =ybegin")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"hash"
 	"hash/crc32"
 	"io"
 	"strings"
@@ -12,95 +13,176 @@ import (
 const (
 	yencHeader  = "=ybegin"
 	yencTrailer = "=yend"
-	lineLength  = 128
+	// DefaultLineLength is the number of encoded bytes per line of an
+	// encoder without a line length set
+	DefaultLineLength = 128
 )
 
 // Encoder handles yEnc encoding
 type Encoder struct {
-	crc32 uint32
-	size  int64
+	// LineLength is the number of encoded bytes per line, DefaultLineLength
+	// when 0
+	LineLength int
+	crc32      uint32
+	size       int64
+}
+
+// Article is where the data of an article belongs. A file posted in one
+// article has a Total of 1 or less; the articles of a multi-part post give
+// the part they are, where their data starts in the file and its size.
+type Article struct {
+	Name   string
+	Number int
+	Total  int
+	// FileSize is the size of the whole file, Offset where the data of the
+	// article starts in it
+	FileSize int64
+	Offset   int64
+	// FileCRC32 is the checksum of the whole file, written in the trailer of
+	// a multi-part article when HasFileCRC is set
+	FileCRC32  uint32
+	HasFileCRC bool
+}
+
+// multipart reports whether the article is one part of several
+func (a Article) multipart() bool {
+	return a.Total > 1
 }
 
 // Encode encodes data using yEnc format
-func (e *Encoder) Encode(data []byte, filename string, partNum int, totalParts int) string {
-	var buf bytes.Buffer
-	
-	// Calculate CRC32
-	e.crc32 = crc32.ChecksumIEEE(data)
+func (e *Encoder) Encode(data []byte, article Article) string {
+	return string(e.AppendEncode(make([]byte, 0, EncodedSize(len(data), e.LineLength)), data, article))
+}
+
+// EncodedSize returns a capacity enough for the article of n bytes of usual
+// data in lines of lineLength encoded bytes (DefaultLineLength when 0),
+// escapes, the spaces escaped at both ends of a line and line ends included
+func EncodedSize(n int, lineLength int) int {
+	if lineLength <= 0 {
+		lineLength = DefaultLineLength
+	}
+	return n + n/32 + 4*(n/lineLength+1) + 512
+}
+
+// lineLength returns the number of encoded bytes per line
+func (e *Encoder) lineLength() int {
+	if e.LineLength > 0 {
+		return e.LineLength
+	}
+	return DefaultLineLength
+}
+
+// AppendEncode appends the yEnc article of data to dst, such as a pooled
+// buffer, and returns the extended buffer
+func (e *Encoder) AppendEncode(dst []byte, data []byte, article Article) []byte {
+	return e.AppendEncodeCRC(dst, data, crc32.ChecksumIEEE(data), article)
+}
+
+// AppendEncodeCRC is AppendEncode with the CRC32 of data already known, as
+// computed while reading it, so the data is not checksummed again
+func (e *Encoder) AppendEncodeCRC(dst []byte, data []byte, crc uint32, article Article) []byte {
+	e.crc32 = crc
 	e.size = int64(len(data))
 	
 	// Write header
-	header := e.buildHeader(filename, partNum, totalParts)
-	buf.WriteString(header)
-	buf.WriteString("\r\n")
+	dst = append(dst, e.buildHeader(article)...)
+	dst = append(dst, "\r\n"...)
 	
-	// Encode data
-	encoded := e.encodeData(data)
-	
-	// Split into lines
-	lines := e.splitIntoLines(encoded)
-	for _, line := range lines {
-		buf.WriteString(line)
-		buf.WriteString("\r\n")
+	// Encode data, breaking lines every lineLength encoded bytes
+	dst, line := appendLines(dst, 0, e.lineLength(), data, true)
+	if line > 0 {
+		dst = append(dst, "\r\n"...)
 	}
 	
 	// Write trailer
-	trailer := e.buildTrailer()
-	buf.WriteString(trailer)
-	buf.WriteString("\r\n")
+	dst = append(dst, e.buildTrailer(article)...)
+	dst = append(dst, "\r\n"...)
 	
-	return buf.String()
-}
-
-// buildHeader creates the yEnc header matching Node.js format
-func (e *Encoder) buildHeader(filename string, partNum int, totalParts int) string {
-	if totalParts > 1 {
-		return fmt.Sprintf("%s part=%d total=%d line=%d size=%d name=%s",
-			yencHeader, partNum, totalParts, lineLength, e.size, filename)
-	}
-	return fmt.Sprintf("%s line=%d size=%d name=%s",
-		yencHeader, lineLength, e.size, filename)
-}
-
-// buildTrailer creates the yEnc trailer
-func (e *Encoder) buildTrailer() string {
-	return fmt.Sprintf("%s size=%d crc32=%s", yencTrailer, e.size, strings.ToUpper(hex.EncodeToString([]byte{byte(e.crc32 >> 24), byte(e.crc32 >> 16), byte(e.crc32 >> 8), byte(e.crc32)})))
+	return dst
 }
 
-// encodeData performs the actual yEnc encoding
-func (e *Encoder) encodeData(data []byte) []byte {
-	var result []byte
-	
+// appendLines appends data encoded to dst in lines of width encoded bytes,
+// dst ending with the line begun, line bytes long, and returns dst and the
+// length of the line it ends on. The two bytes of an escape stay on one
+// line, which ends before them rather than between. A space starting or
+// ending a line is escaped, as servers may strip it; with final, data ends
+// the last line.
+func appendLines(dst []byte, line int, width int, data []byte, final bool) ([]byte, int) {
 	for _, b := range data {
 		// yEnc encoding: add 42 to each byte, escape special chars
 		encoded := b + 42
-		
-		// Escape special characters
+
+		escape := false
 		switch encoded {
-		case 0, 9, 10, 13, '=':
-			result = append(result, '=')
-			encoded += 64
+		case 0, '\t', '\n', '\r', '=':
+			escape = true
+		case ' ':
+			escape = line == 0 || line+1 >= width
+		}
+
+		if escape {
+			if line > 0 && line+2 > width {
+				dst = append(escapeSpace(dst), "\r\n"...)
+				line = 0
+			}
+			dst = append(dst, '=', encoded+64)
+			line += 2
+		} else {
+			dst = append(dst, encoded)
+			line++
+		}
+		if line >= width {
+			dst = append(dst, "\r\n"...)
+			line = 0
 		}
-		
-		result = append(result, encoded)
 	}
-	
-	return result
+	if final && line > 0 {
+		n := len(dst)
+		dst = escapeSpace(dst)
+		line += len(dst) - n
+	}
+	return dst, line
 }
 
-// splitIntoLines splits encoded data into lines of specified length
-func (e *Encoder) splitIntoLines(data []byte) []string {
-	var lines []string
-	
-	for i := 0; i < len(data); i += lineLength {
-		end := i + lineLength
-		if end > len(data) {
-			end = len(data)
+// escapeSpace escapes the space ending a line, which is then a byte longer.
+// An escaped byte is never a space, so a space ending dst is one written
+// as is.
+func escapeSpace(dst []byte) []byte {
+	if n := len(dst); n > 0 && dst[n-1] == ' ' {
+		return append(dst[:n-1], '=', ' '+64)
+	}
+	return dst
+}
+
+// buildHeader creates the yEnc header matching Node.js format. The header
+// of a multi-part article gives the size of the whole file and is followed
+// by the =ypart line of the offsets of its data, counted from 1.
+func (e *Encoder) buildHeader(article Article) string {
+	if article.multipart() {
+		return fmt.Sprintf("%s part=%d total=%d line=%d size=%d name=%s\r\n=ypart begin=%d end=%d",
+			yencHeader, article.Number, article.Total, e.lineLength(), article.FileSize, article.Name,
+			article.Offset+1, article.Offset+e.size)
+	}
+	return fmt.Sprintf("%s line=%d size=%d name=%s",
+		yencHeader, e.lineLength(), e.size, article.Name)
+}
+
+// buildTrailer creates the yEnc trailer. The checksum of a multi-part
+// article is pcrc32, followed by the crc32 of the whole file when known.
+func (e *Encoder) buildTrailer(article Article) string {
+	if article.multipart() {
+		trailer := fmt.Sprintf("%s size=%d part=%d pcrc32=%s", yencTrailer, e.size, article.Number, formatCRC(e.crc32))
+		if article.HasFileCRC {
+			trailer += " crc32=" + formatCRC(article.FileCRC32)
 		}
-		lines = append(lines, string(data[i:end]))
+		return trailer
 	}
-	
-	return lines
+	return fmt.Sprintf("%s size=%d crc32=%s", yencTrailer, e.size, formatCRC(e.crc32))
+}
+
+// formatCRC returns a checksum in the 8 upper case hex digits of yEnc
+func formatCRC(crc uint32) string {
+	return strings.ToUpper(hex.EncodeToString([]byte{byte(crc >> 24), byte(crc >> 16), byte(crc >> 8), byte(crc)}))
 }
 
 // GetCRC32 returns the CRC32 checksum of the last encoded data
@@ -172,23 +254,28 @@ func decodeLine(line string) ([]byte, error) {
 
 // EncoderReader wraps an io.Reader to provide yEnc encoding
 type EncoderReader struct {
-	reader io.Reader
-	buffer bytes.Buffer
-	header string
-	trailer string
+	reader  io.Reader
+	buffer  bytes.Buffer
+	encoder Encoder
+	article Article
+	header  string
+	crc     hash.Hash32
 	done    bool
+	// line holds the encoded bytes of the line begun, written once it ends
+	line []byte
 }
 
-// NewEncoderReader creates a new yEnc encoder reader
-func NewEncoderReader(reader io.Reader, filename string, partNum int, totalParts int, fileSize int64) *EncoderReader {
-	encoder := &Encoder{}
-	header := encoder.buildHeader(filename, partNum, totalParts)
-	trailer := encoder.buildTrailer()
-	
+// NewEncoderReader creates a new yEnc encoder reader of the size bytes of
+// reader, the data of article. The checksum of the trailer is computed as
+// the data is read.
+func NewEncoderReader(reader io.Reader, article Article, size int64) *EncoderReader {
+	encoder := Encoder{size: size}
 	return &EncoderReader{
 		reader:  reader,
-		header:  header,
-		trailer: trailer,
+		encoder: encoder,
+		article: article,
+		header:  encoder.buildHeader(article),
+		crc:     crc32.NewIEEE(),
 	}
 }
 
@@ -201,31 +288,31 @@ func (er *EncoderReader) Read(p []byte) (n int, err error) {
 			er.buffer.WriteString("\r\n")
 			er.header = ""
 		}
-		
+
 		// Read and encode data
 		buf := make([]byte, 8192)
 		n, err := er.reader.Read(buf)
 		if err != nil && err != io.EOF {
 			return 0, err
 		}
-		
-		if n > 0 {
-			encoder := &Encoder{}
-			encoded := encoder.encodeData(buf[:n])
-			lines := encoder.splitIntoLines(encoded)
-			for _, line := range lines {
-				er.buffer.WriteString(line)
+		er.crc.Write(buf[:n])
+
+		encoded, line := appendLines(er.line, len(er.line), DefaultLineLength, buf[:n], err == io.EOF)
+		er.buffer.Write(encoded[:len(encoded)-line])
+		er.line = append(er.line[:0], encoded[len(encoded)-line:]...)
+
+		if err == io.EOF {
+			if line > 0 {
+				er.buffer.Write(er.line)
 				er.buffer.WriteString("\r\n")
 			}
-		}
-		
-		if err == io.EOF {
 			// Add trailer
-			er.buffer.WriteString(er.trailer)
+			er.encoder.crc32 = er.crc.Sum32()
+			er.buffer.WriteString(er.encoder.buildTrailer(er.article))
 			er.buffer.WriteString("\r\n")
 			er.done = true
 		}
 	}
-	
+
 	return er.buffer.Read(p)
-}
\ No newline at end of file
+}
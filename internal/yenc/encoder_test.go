@@ -0,0 +1,165 @@
+package yenc
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+)
+
+// escapeHeavy returns data whose bytes encode to the characters escaped,
+// spaces and tabs among them, at every position of a line
+func escapeHeavy(size int) []byte {
+	special := []byte{0xd6, 0xe0, 0xe3, 0x13, 0xf6, 'a', 0xf6, 0xf6}
+	data := make([]byte, size)
+	for i := range data {
+		if i%3 == 0 {
+			data[i] = byte(i * 7)
+		} else {
+			data[i] = special[i%len(special)]
+		}
+	}
+	return data
+}
+
+// checkLines fails when a data line of article exceeds width, splits an
+// escape over two lines or starts or ends with a space or tab
+func checkLines(t *testing.T, name string, article string, width int) {
+	t.Helper()
+	lines := strings.Split(strings.TrimSuffix(article, "\r\n"), "\r\n")
+	for n, line := range lines {
+		if strings.HasPrefix(line, "=y") {
+			continue
+		}
+		if len(line) > width {
+			t.Errorf("%s: line %d holds %d bytes, more than %d", name, n, len(line), width)
+		}
+		for i := 0; i < len(line); i++ {
+			if line[i] == '=' {
+				if i == len(line)-1 {
+					t.Errorf("%s: line %d ends within an escape: %q", name, n, line)
+				}
+				i++
+			}
+		}
+		if line != strings.Trim(line, " \t") {
+			t.Errorf("%s: line %d starts or ends with whitespace: %q", name, n, line)
+		}
+	}
+}
+
+func TestAppendEncode(t *testing.T) {
+	for _, lineLength := range []int{0, 64, 127, 256} {
+		width := lineLength
+		if width == 0 {
+			width = DefaultLineLength
+		}
+		for _, size := range []int{0, 1, DefaultLineLength, 1000, 4096} {
+			data := escapeHeavy(size)
+			encoder := &Encoder{LineLength: lineLength}
+
+			// Appended after what the buffer holds
+			dst := make([]byte, 0, EncodedSize(size, lineLength))
+			dst = append(dst, "prefix"...)
+			got := string(encoder.AppendEncode(dst, data, Article{Name: "file.bin"}))
+			if !strings.HasPrefix(got, "prefix"+encoder.buildHeader(Article{Name: "file.bin"})+"\r\n") {
+				t.Errorf("line %d, size %d: unexpected header in %q", lineLength, size, got)
+			}
+			if cap(dst) < len(got) && size > 0 && size%3 == 0 {
+				t.Errorf("line %d, size %d: %d bytes for an article of %d", lineLength, size, cap(dst), len(got))
+			}
+			article := strings.TrimPrefix(got, "prefix")
+			checkLines(t, "AppendEncode", article, width)
+
+			part, err := DecodePart([]byte(article))
+			if err != nil {
+				t.Fatalf("line %d, size %d: %v", lineLength, size, err)
+			}
+			if !bytes.Equal(part.Data, data) {
+				t.Errorf("line %d, size %d: the article does not decode to its data", lineLength, size)
+			}
+		}
+	}
+
+	// Lines hold the encoded bytes the encoder is set to
+	article := (&Encoder{LineLength: 64}).Encode(make([]byte, 1000), Article{Name: "file.bin"})
+	lines := strings.Split(article, "\r\n")
+	if !strings.Contains(lines[0], " line=64 ") || len(lines[1]) != 64 {
+		t.Errorf("expected lines of 64 bytes, got %q and %d bytes", lines[0], len(lines[1]))
+	}
+
+	// A space is escaped at both ends of a line only
+	if got := string((&Encoder{}).Encode([]byte{0xf6, 0xf6, 0xf6}, Article{Name: "file.bin"})); !strings.Contains(got, "\r\n=` =`\r\n") {
+		t.Errorf("expected the spaces ending the line escaped, got %q", got)
+	}
+}
+
+func TestEncoderReaderLines(t *testing.T) {
+	data := escapeHeavy(20000)
+	// Short reads end the data of a read anywhere on a line
+	reader := NewEncoderReader(io.MultiReader(bytes.NewReader(data[:777]), bytes.NewReader(data[777:])), Article{Name: "file.bin"}, int64(len(data)))
+	article, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkLines(t, "EncoderReader", string(article), DefaultLineLength)
+	lines := strings.Split(strings.TrimSuffix(string(article), "\r\n"), "\r\n")
+	var decoded []byte
+	var escaped bool
+	for _, line := range lines[1 : len(lines)-1] {
+		decoded = appendDecoded(decoded, []byte(line), &escaped)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Error("the encoded lines do not decode to the data")
+	}
+	if _, err := DecodePart(article); err != nil {
+		t.Errorf("the article does not decode: %v", err)
+	}
+}
+
+func TestEncodeMultipart(t *testing.T) {
+	file := escapeHeavy(2500)
+	fileCRC := crc32.ChecksumIEEE(file)
+	const articleSize = 1000
+	var assembled []byte
+	for number, offset := 1, 0; offset < len(file); number, offset = number+1, offset+articleSize {
+		data := file[offset:min(offset+articleSize, len(file))]
+		article := Article{Name: "file.bin", Number: number, Total: 3, FileSize: int64(len(file)), Offset: int64(offset), FileCRC32: fileCRC, HasFileCRC: true}
+		body := (&Encoder{}).Encode(data, article)
+
+		lines := strings.Split(body, "\r\n")
+		header := fmt.Sprintf("=ybegin part=%d total=3 line=%d size=%d name=file.bin", number, DefaultLineLength, len(file))
+		ypart := fmt.Sprintf("=ypart begin=%d end=%d", offset+1, offset+len(data))
+		trailer := fmt.Sprintf("=yend size=%d part=%d pcrc32=%08X crc32=%08X", len(data), number, crc32.ChecksumIEEE(data), fileCRC)
+		if lines[0] != header || lines[1] != ypart || lines[len(lines)-2] != trailer {
+			t.Errorf("part %d: unexpected control lines %q, %q and %q", number, lines[0], lines[1], lines[len(lines)-2])
+		}
+		checkLines(t, "multi-part", body, DefaultLineLength)
+
+		part, err := DecodePart([]byte(body))
+		if err != nil {
+			t.Fatalf("part %d: %v", number, err)
+		}
+		if part.Number != number || part.Total != 3 || part.Size != int64(len(file)) {
+			t.Errorf("part %d: unexpected header fields %+v", number, part)
+		}
+		if part.Begin != int64(offset+1) || part.End != int64(offset+len(data)) {
+			t.Errorf("part %d: unexpected offsets %d-%d", number, part.Begin, part.End)
+		}
+		if !part.HasFileCRC || part.FileCRC32 != fileCRC {
+			t.Errorf("part %d: expected the file CRC %08X, got %08X", number, fileCRC, part.FileCRC32)
+		}
+		assembled = append(assembled, part.Data...)
+	}
+	if !bytes.Equal(assembled, file) {
+		t.Error("the parts do not assemble to the file")
+	}
+
+	// The file CRC is left out when unknown
+	body := (&Encoder{}).Encode(file[:10], Article{Name: "file.bin", Number: 1, Total: 2, FileSize: 20})
+	if strings.Contains(body, " crc32=") {
+		t.Errorf("expected no file CRC in %q", body)
+	}
+}
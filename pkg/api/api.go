@@ -0,0 +1,6 @@
+// Package api is the gRPC API of ypost serve, generated from ypost.proto:
+// clients submit jobs to the queue the process runs, stream their progress,
+// fetch their NZB and cancel them.
+package api
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative ypost.proto
@@ -0,0 +1,254 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: ypost.proto
+
+// The API of ypost serve: jobs are submitted to the job queue the process
+// runs, followed while they are posted and their NZB fetched once done.
+
+package api
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Ypost_SubmitJob_FullMethodName     = "/ypost.v1.Ypost/SubmitJob"
+	Ypost_WatchProgress_FullMethodName = "/ypost.v1.Ypost/WatchProgress"
+	Ypost_GetNZB_FullMethodName        = "/ypost.v1.Ypost/GetNZB"
+	Ypost_CancelJob_FullMethodName     = "/ypost.v1.Ypost/CancelJob"
+)
+
+// YpostClient is the client API for Ypost service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Ypost posts files through the job queue of a ypost process
+type YpostClient interface {
+	// SubmitJob queues a file, directory or URL for posting
+	SubmitJob(ctx context.Context, in *SubmitJobRequest, opts ...grpc.CallOption) (*Job, error)
+	// WatchProgress streams the progress of a job until it is finished
+	WatchProgress(ctx context.Context, in *WatchProgressRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[JobProgress], error)
+	// GetNZB returns the NZB of a done job
+	GetNZB(ctx context.Context, in *GetNZBRequest, opts ...grpc.CallOption) (*NZB, error)
+	// CancelJob stops a pending or running job
+	CancelJob(ctx context.Context, in *CancelJobRequest, opts ...grpc.CallOption) (*Job, error)
+}
+
+type ypostClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewYpostClient(cc grpc.ClientConnInterface) YpostClient {
+	return &ypostClient{cc}
+}
+
+func (c *ypostClient) SubmitJob(ctx context.Context, in *SubmitJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, Ypost_SubmitJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ypostClient) WatchProgress(ctx context.Context, in *WatchProgressRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[JobProgress], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Ypost_ServiceDesc.Streams[0], Ypost_WatchProgress_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchProgressRequest, JobProgress]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Ypost_WatchProgressClient = grpc.ServerStreamingClient[JobProgress]
+
+func (c *ypostClient) GetNZB(ctx context.Context, in *GetNZBRequest, opts ...grpc.CallOption) (*NZB, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NZB)
+	err := c.cc.Invoke(ctx, Ypost_GetNZB_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ypostClient) CancelJob(ctx context.Context, in *CancelJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, Ypost_CancelJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// YpostServer is the server API for Ypost service.
+// All implementations must embed UnimplementedYpostServer
+// for forward compatibility.
+//
+// Ypost posts files through the job queue of a ypost process
+type YpostServer interface {
+	// SubmitJob queues a file, directory or URL for posting
+	SubmitJob(context.Context, *SubmitJobRequest) (*Job, error)
+	// WatchProgress streams the progress of a job until it is finished
+	WatchProgress(*WatchProgressRequest, grpc.ServerStreamingServer[JobProgress]) error
+	// GetNZB returns the NZB of a done job
+	GetNZB(context.Context, *GetNZBRequest) (*NZB, error)
+	// CancelJob stops a pending or running job
+	CancelJob(context.Context, *CancelJobRequest) (*Job, error)
+	mustEmbedUnimplementedYpostServer()
+}
+
+// UnimplementedYpostServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedYpostServer struct{}
+
+func (UnimplementedYpostServer) SubmitJob(context.Context, *SubmitJobRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitJob not implemented")
+}
+func (UnimplementedYpostServer) WatchProgress(*WatchProgressRequest, grpc.ServerStreamingServer[JobProgress]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchProgress not implemented")
+}
+func (UnimplementedYpostServer) GetNZB(context.Context, *GetNZBRequest) (*NZB, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetNZB not implemented")
+}
+func (UnimplementedYpostServer) CancelJob(context.Context, *CancelJobRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelJob not implemented")
+}
+func (UnimplementedYpostServer) mustEmbedUnimplementedYpostServer() {}
+func (UnimplementedYpostServer) testEmbeddedByValue()               {}
+
+// UnsafeYpostServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to YpostServer will
+// result in compilation errors.
+type UnsafeYpostServer interface {
+	mustEmbedUnimplementedYpostServer()
+}
+
+func RegisterYpostServer(s grpc.ServiceRegistrar, srv YpostServer) {
+	// If the following call pancis, it indicates UnimplementedYpostServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Ypost_ServiceDesc, srv)
+}
+
+func _Ypost_SubmitJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YpostServer).SubmitJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ypost_SubmitJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YpostServer).SubmitJob(ctx, req.(*SubmitJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Ypost_WatchProgress_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchProgressRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(YpostServer).WatchProgress(m, &grpc.GenericServerStream[WatchProgressRequest, JobProgress]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Ypost_WatchProgressServer = grpc.ServerStreamingServer[JobProgress]
+
+func _Ypost_GetNZB_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetNZBRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YpostServer).GetNZB(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ypost_GetNZB_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YpostServer).GetNZB(ctx, req.(*GetNZBRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Ypost_CancelJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YpostServer).CancelJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ypost_CancelJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YpostServer).CancelJob(ctx, req.(*CancelJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Ypost_ServiceDesc is the grpc.ServiceDesc for Ypost service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Ypost_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ypost.v1.Ypost",
+	HandlerType: (*YpostServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitJob",
+			Handler:    _Ypost_SubmitJob_Handler,
+		},
+		{
+			MethodName: "GetNZB",
+			Handler:    _Ypost_GetNZB_Handler,
+		},
+		{
+			MethodName: "CancelJob",
+			Handler:    _Ypost_CancelJob_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchProgress",
+			Handler:       _Ypost_WatchProgress_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "ypost.proto",
+}
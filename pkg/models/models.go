@@ -12,6 +12,7 @@ type Config struct {
 		Port        int           `mapstructure:"port"`
 		Username    string         `mapstructure:"username"`
 		Password    string         `mapstructure:"password"`
+		PasswordCmd string         `mapstructure:"password_cmd"`
 		SSL         bool          `mapstructure:"ssl"`
 		Connections int           `mapstructure:"connections"`
 	} `mapstructure:"nntp"`
@@ -22,19 +23,64 @@ type Config struct {
 		PosterName     string            `mapstructure:"poster_name"`
 		PosterEmail    string            `mapstructure:"poster_email"`
 		SubjectTemplate string            `mapstructure:"subject_template"`
+		// SubjectComment leads the subjects of the default template;
+		// SubjectQuote and SubjectBrackets are the quotes of the file name
+		// and the brackets of the file counter, SubjectQuoteDouble and
+		// SubjectBracketsSquare as indexers expect
+		SubjectComment  string `mapstructure:"subject_comment"`
+		SubjectQuote    string `mapstructure:"subject_quote"`
+		SubjectBrackets string `mapstructure:"subject_brackets"`
 		MaxLineLength  int               `mapstructure:"max_line_length"`
 		MaxPartSize    int64             `mapstructure:"max_part_size"`
 		MaxArticleSize int64             `mapstructure:"max_article_size"`
 		CustomHeaders  map[string]string `mapstructure:"custom_headers"`
+		// XNoArchive, Organization and XNewsposter set the standard
+		// optional headers of the same names, left out when unset; a
+		// custom header of the same name takes precedence
+		XNoArchive   bool   `mapstructure:"x_no_archive"`
+		Organization string `mapstructure:"organization"`
+		XNewsposter  string `mapstructure:"x_newsposter"`
+		PreservePaths  bool              `mapstructure:"preserve_paths"`
+		// JoinGroup selects the group with GROUP before posting, once per
+		// connection; most servers post without it
+		JoinGroup bool `mapstructure:"join_group"`
+		// Retries is the number of times an article refused by every
+		// server is sent through them again, after a pause, before it fails
+		Retries int `mapstructure:"retries"`
+		// OnError is what becomes of the post when an article fails:
+		// OnErrorSkip, OnErrorAbort or OnErrorRetry
+		OnError string `mapstructure:"on_error"`
+		// Verify fetches posted articles back once the post is done to
+		// compare them with the source: none, all, sample:N% or sample:N
+		Verify string `mapstructure:"verify"`
 	} `mapstructure:"posting"`
 	Output struct {
 		OutputDir string `mapstructure:"output_dir"`
 		NZBDir    string `mapstructure:"nzb_dir"`
 		LogDir    string `mapstructure:"log_dir"`
 	} `mapstructure:"output"`
+	NZB struct {
+		TitleTemplate string   `mapstructure:"title_template"`
+		Category      string   `mapstructure:"category"`
+		Tags          []string `mapstructure:"tags"`
+		MappingMode   string   `mapstructure:"mapping_mode"`
+		PAR2          string   `mapstructure:"par2"`
+		IncludeSFV    bool     `mapstructure:"include_sfv"`
+		IncludeNFO    bool     `mapstructure:"include_nfo"`
+	} `mapstructure:"nzb"`
 	Splitting struct {
 		MaxFileSize string `mapstructure:"max_file_size"`
+		Naming      string `mapstructure:"naming"`
+		NameWidth   int    `mapstructure:"name_width"`
+		AlignParts  bool   `mapstructure:"align_parts"`
+		Checksum    string `mapstructure:"checksum"`
+		PadParts    bool   `mapstructure:"pad_parts"`
 	} `mapstructure:"splitting"`
+	Archive struct {
+		Format     string `mapstructure:"format"`
+		Password   string `mapstructure:"password"`
+		VolumeSize string `mapstructure:"volume_size"`
+	} `mapstructure:"archive"`
 	Features struct {
 		CreatePAR2 bool `mapstructure:"create_par2"`
 		CreateSFV  bool `mapstructure:"create_sfv"`
@@ -42,14 +88,248 @@ type Config struct {
 	Par2 struct {
 		Redundancy int  `mapstructure:"redundancy"`
 		Enabled    bool `mapstructure:"enabled"`
+		BlockSize  int  `mapstructure:"block_size"`
 	} `mapstructure:"par2"`
 	SFV struct {
 		Enabled bool `mapstructure:"enabled"`
 	} `mapstructure:"sfv"`
 	Logging struct {
-		Level string `mapstructure:"level"`
-		File  string `mapstructure:"file"`
+		Level   string `mapstructure:"level"`
+		File    string `mapstructure:"file"`
+		Console bool   `mapstructure:"console"`
+		// Backend is file, syslog, journald or eventlog
+		Backend string `mapstructure:"backend"`
+		// Levels overrides Level per module, e.g. par2: debug
+		Levels map[string]string `mapstructure:"levels"`
 	} `mapstructure:"logging"`
+	Obfuscation struct {
+		Enabled           bool   `mapstructure:"enabled"`
+		RandomSubjects    bool   `mapstructure:"random_subjects"`
+		RandomPoster      bool   `mapstructure:"random_poster"`
+		ScrambleFilenames bool   `mapstructure:"scramble_filenames"`
+		NameLength        int    `mapstructure:"name_length"`
+		MessageIDDomain   string `mapstructure:"message_id_domain"`
+	} `mapstructure:"obfuscation"`
+	// Retention holds the ages past which ypost prune removes outputs; empty
+	// ages keep them forever
+	Retention struct {
+		Outputs string `mapstructure:"outputs"`
+		NZBs    string `mapstructure:"nzbs"`
+		Logs    string `mapstructure:"logs"`
+		Temp    string `mapstructure:"temp"`
+	} `mapstructure:"retention"`
+	// Schedule confines uploads to a daily window; both empty post any time
+	Schedule struct {
+		WindowStart string `mapstructure:"window_start"`
+		WindowEnd   string `mapstructure:"window_end"`
+	} `mapstructure:"schedule"`
+	Security struct {
+		EncryptPasswords bool   `mapstructure:"encrypt_passwords"`
+		KeyFile          string `mapstructure:"key_file"`
+		KeyCmd           string `mapstructure:"key_cmd"`
+	} `mapstructure:"security"`
+	Queue struct {
+		Path string `mapstructure:"path"`
+	} `mapstructure:"queue"`
+	// Status is the HTTP status endpoint of the queue run and watch modes
+	Status struct {
+		// Address is the host:port to listen on; empty disables it
+		Address string `mapstructure:"address"`
+	} `mapstructure:"status"`
+	// GRPC is the gRPC API of the serve and queue run modes
+	GRPC struct {
+		// Address is the host:port to listen on; empty disables it
+		Address string `mapstructure:"address"`
+		// Token is the bearer token clients authenticate with; empty
+		// accepts any client
+		Token string `mapstructure:"token"`
+	} `mapstructure:"grpc"`
+	// Performance tunes how posts use the connections and memory
+	Performance struct {
+		// AdaptiveConnections starts with half the connections, adding them
+		// while they raise the throughput
+		AdaptiveConnections bool `mapstructure:"adaptive_connections"`
+		// MaxMemory bounds the article buffers in flight and the PAR2
+		// working set together, e.g. 512MB; empty is unlimited
+		MaxMemory string `mapstructure:"max_memory"`
+		// Readahead is the number of articles read from disk ahead of the
+		// encoders, in order by one reader; 0 lets the encoders read them
+		Readahead int `mapstructure:"readahead"`
+	} `mapstructure:"performance"`
+	History struct {
+		Enabled bool   `mapstructure:"enabled"`
+		Path    string `mapstructure:"path"`
+		// Duplicates is what posting content the history holds a
+		// successful post of within DuplicateWindow (an age such as 30d,
+		// any time when empty) does: DuplicatesAbort, DuplicatesWarn or
+		// DuplicatesOff
+		Duplicates      string `mapstructure:"duplicates"`
+		DuplicateWindow string `mapstructure:"duplicate_window"`
+	} `mapstructure:"history"`
+	// Notifications are sent when a post finishes
+	Notifications struct {
+		Webhooks []WebhookConfig `mapstructure:"webhooks"`
+		Email    EmailConfig     `mapstructure:"email"`
+		Chats    []ChatConfig    `mapstructure:"chats"`
+		Arrs     []ArrConfig     `mapstructure:"arrs"`
+	} `mapstructure:"notifications"`
+	// Indexer is the newznab indexer the NZBs of successful posts are
+	// pushed to
+	Indexer IndexerConfig `mapstructure:"indexer"`
+	// RoundTrip has each successful post downloaded by a SABnzbd or NZBGet
+	// instance, checking end to end that it downloads and repairs
+	RoundTrip RoundTripConfig `mapstructure:"roundtrip"`
+	// Hooks are commands run before a post and after it succeeds or fails
+	Hooks HooksConfig `mapstructure:"hooks"`
+	// NFO is the release information file posted with each post
+	NFO NFOConfig `mapstructure:"nfo"`
+	// Groups holds posting presets keyed by newsgroup name
+	Groups map[string]GroupPreset `mapstructure:"groups"`
+}
+
+// WebhookConfig is a URL a JSON report is posted to when a post finishes
+type WebhookConfig struct {
+	URL string `mapstructure:"url"`
+	// On is all (the default), success or failure
+	On string `mapstructure:"on"`
+	// Template is a text/template of the body, the JSON report when empty
+	Template string            `mapstructure:"template"`
+	Headers  map[string]string `mapstructure:"headers"`
+	// Attempts bounds the deliveries tried, 3 when 0
+	Attempts int `mapstructure:"attempts"`
+}
+
+// ChatConfig is a Discord, Slack or Telegram chat messaged as posts start
+// and finish
+type ChatConfig struct {
+	// Platform is discord, slack or telegram
+	Platform string `mapstructure:"platform"`
+	// URL is the incoming webhook of a Discord or Slack channel
+	URL string `mapstructure:"url"`
+	// Token and ChatID are the bot and chat of Telegram
+	Token  string `mapstructure:"token"`
+	ChatID string `mapstructure:"chat_id"`
+	// Events lists the events messaged, start, complete and failure;
+	// complete and failure when empty
+	Events []string `mapstructure:"events"`
+	// Templates overrides the message of events, keyed by event
+	Templates map[string]string `mapstructure:"templates"`
+}
+
+// ArrConfig is a Sonarr, Radarr or Lidarr instance handed the NZBs of
+// successful posts, through its API or its blackhole folder
+type ArrConfig struct {
+	// App is sonarr, radarr or lidarr
+	App string `mapstructure:"app"`
+	// URL and APIKey are the instance the release is pushed to
+	URL    string `mapstructure:"url"`
+	APIKey string `mapstructure:"api_key"`
+	// DownloadURL is a text/template of the URL the app fetches the NZB
+	// from, needed with URL
+	DownloadURL string `mapstructure:"download_url"`
+	// Blackhole is the folder the NZB is written to instead, with its
+	// password and category in the head
+	Blackhole string `mapstructure:"blackhole"`
+	// Category replaces the NZB category in the NZBs written to Blackhole
+	Category string `mapstructure:"category"`
+	// Attempts bounds the pushes tried, 3 when 0
+	Attempts int `mapstructure:"attempts"`
+}
+
+// EmailConfig is an SMTP account mailing a summary when a post finishes
+type EmailConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Host    string `mapstructure:"host"`
+	Port    int    `mapstructure:"port"`
+	// Security is starttls, tls (implicit, as on port 465) or none
+	Security    string   `mapstructure:"security"`
+	Username    string   `mapstructure:"username"`
+	Password    string   `mapstructure:"password"`
+	PasswordCmd string   `mapstructure:"password_cmd"`
+	From        string   `mapstructure:"from"`
+	To          []string `mapstructure:"to"`
+	// On is all, success or failure
+	On string `mapstructure:"on"`
+	// Subject is a text/template of the subject line
+	Subject   string `mapstructure:"subject"`
+	AttachNZB bool   `mapstructure:"attach_nzb"`
+}
+
+// IndexerConfig is a newznab-compatible indexer NZBs are pushed to
+type IndexerConfig struct {
+	// URL is the site of the indexer; empty disables the push
+	URL    string `mapstructure:"url"`
+	APIKey string `mapstructure:"api_key"`
+	// Mode is api, adding the NZB with t=nzbadd on URL/api, or upload,
+	// posting it as a form to URL itself
+	Mode string `mapstructure:"mode"`
+	// Categories maps NZB categories and posting groups to newznab
+	// categories, the first mapping matching taken
+	Categories []IndexerCategory `mapstructure:"categories"`
+	// DefaultCategory is the category of posts no mapping matches
+	DefaultCategory string `mapstructure:"default_category"`
+	// Attempts bounds the submissions tried, 3 when 0
+	Attempts int `mapstructure:"attempts"`
+}
+
+// RoundTripConfig is the downloader posts are fetched back with
+type RoundTripConfig struct {
+	// Client is sabnzbd or nzbget; empty disables the round trip
+	Client string `mapstructure:"client"`
+	URL    string `mapstructure:"url"`
+	// APIKey authenticates to SABnzbd, Username and Password to NZBGet
+	APIKey   string `mapstructure:"api_key"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// Category is the downloader category of the downloads
+	Category string `mapstructure:"category"`
+	// Delay is the wait before the NZB is submitted, for the articles to
+	// propagate, e.g. 2m
+	Delay string `mapstructure:"delay"`
+	// Timeout bounds the wait for the download and its repair, e.g. 2h
+	Timeout string `mapstructure:"timeout"`
+	// Keep leaves the download and its files in the downloader
+	Keep bool `mapstructure:"keep"`
+}
+
+// HooksConfig holds the commands run around posts, through the shell, with
+// the job as JSON on their standard input
+type HooksConfig struct {
+	// PrePost runs before a post starts; failing, it refuses the post
+	PrePost string `mapstructure:"pre_post"`
+	// PostSuccess and PostFailure run once a post succeeded or failed
+	PostSuccess string `mapstructure:"post_success"`
+	PostFailure string `mapstructure:"post_failure"`
+	// Timeout bounds the run of a hook, e.g. 10m
+	Timeout string `mapstructure:"timeout"`
+}
+
+// NFOConfig is the NFO posted as a file of its own with each post, listed
+// in the NZB like the SFV
+type NFOConfig struct {
+	// Generate writes an NFO from Template for posts without one
+	Generate bool `mapstructure:"generate"`
+	// Template is the text/template file of the generated NFO; empty uses
+	// the built-in one
+	Template string `mapstructure:"template"`
+	// File is an NFO posted as is instead of a generated one
+	File string `mapstructure:"file"`
+}
+
+// IndexerCategory maps an NZB category or a posting group to a newznab
+// category, such as 2040
+type IndexerCategory struct {
+	// Match is the NZB category or group, where * matches any characters
+	Match    string `mapstructure:"match"`
+	Category string `mapstructure:"category"`
+}
+
+// GroupPreset holds the posting conventions of a newsgroup, applied when
+// posting to it. Zero values leave the general setting unchanged.
+type GroupPreset struct {
+	SubjectTemplate string `mapstructure:"subject_template"`
+	ArticleSize     int64  `mapstructure:"article_size"`
+	Par2Redundancy  int    `mapstructure:"par2_redundancy"`
 }
 
 // ServerConfig represents NNTP server configuration
@@ -60,16 +340,95 @@ type ServerConfig struct {
 	Password string `mapstructure:"password"`
 	SSL      bool   `mapstructure:"ssl"`
 	MaxConns int    `mapstructure:"max_connections"`
+	Priority int    `mapstructure:"priority"`
+	Backup   bool   `mapstructure:"backup"`
+	// PasswordCmd is run at load time to obtain the password, e.g. from the
+	// OS keyring
+	PasswordCmd string `mapstructure:"password_cmd"`
+	// PasswordRef holds the password as written in the configuration when it
+	// was resolved from a ${VAR} reference; it is never read from a file
+	PasswordRef string `mapstructure:"-" yaml:"-"`
+	// Role is what the server is used for: RolePost, RoleRead or RoleBoth,
+	// the default when empty
+	Role string `mapstructure:"role"`
+}
+
+// Server roles: posting articles, reading them back to check or download
+// them, or both
+const (
+	RolePost = "post"
+	RoleRead = "read"
+	RoleBoth = "both"
+)
+
+// Quotes of the file name in subjects
+const (
+	SubjectQuoteDouble = "double"
+	SubjectQuoteSingle = "single"
+	SubjectQuoteNone   = "none"
+)
+
+// Brackets of the file counter in subjects: [1/5] or (1/5)
+const (
+	SubjectBracketsSquare = "square"
+	SubjectBracketsRound  = "round"
+)
+
+// Policies for content posted before: refuse to post it again, warn and post
+// it, or not look for it
+const (
+	DuplicatesAbort = "abort"
+	DuplicatesWarn  = "warn"
+	DuplicatesOff   = "off"
+)
+
+// Article failure policies: leave the article out of the NZB, stop the
+// upload, or post the article again once the others are posted
+const (
+	OnErrorSkip  = "skip"
+	OnErrorAbort = "abort"
+	OnErrorRetry = "retry"
+)
+
+// Round trip downloaders
+const (
+	ClientSABnzbd = "sabnzbd"
+	ClientNZBGet  = "nzbget"
+)
+
+// Indexer modes: the newznab API, or a form upload
+const (
+	IndexerModeAPI    = "api"
+	IndexerModeUpload = "upload"
+)
+
+// Posts reports whether articles are posted to the server
+func (s ServerConfig) Posts() bool {
+	return s.Role != RoleRead
 }
 
-// FilePart represents a split file part
+// Reads reports whether articles are read from the server, to check or
+// download them
+func (s ServerConfig) Reads() bool {
+	return s.Role != RolePost
+}
+
+// FilePart represents a split file part. A part is held in memory (Data set),
+// a file written to disk (FilePath set) or a view of Size bytes at Offset
+// within SourcePath. Padding zero bytes follow the Size bytes of real data
+// when the part is posted.
 type FilePart struct {
 	PartNumber int
 	FileName   string
 	Size       int64
 	FilePath   string
-	Data       []byte
+	SourcePath string
+	Offset     int64
 	Checksum   string
+	Data       []byte
+	Padding    int64
+	// Subject replaces the subject template for the articles of the part
+	Subject string
 }
 
 // PostSegment represents a posted Usenet segment
@@ -81,6 +440,25 @@ type PostSegment struct {
 	Subject     string
 	PostedAt    time.Time
 	BytesPosted int64
+	// Server is the host that accepted the article, after Retries failed
+	// attempts on other servers
+	Server  string
+	Retries int
+	// Connection is the worker that posted the article, each worker using
+	// one connection
+	Connection int
+	// Failures are the responses of the servers that refused the article,
+	// in the order they were tried
+	Failures []ServerFailure
+	// CRC32 is the checksum of the data of the article as read from the
+	// source, 0 when not known
+	CRC32 uint32
+}
+
+// ServerFailure is the refusal of an article by a server
+type ServerFailure struct {
+	Server   string
+	Response string
 }
 
 // NZBFile represents the NZB file structure
@@ -123,11 +501,34 @@ type PostingResult struct {
 
 // PostingHistory represents historical posting records
 type PostingHistory struct {
-	ID         string    `json:"id"`
-	FileName   string    `json:"file_name"`
-	FileSize   int64     `json:"file_size"`
-	PostedAt   time.Time `json:"posted_at"`
-	TotalParts int       `json:"total_parts"`
-	NZBPath    string    `json:"nzb_path"`
-	Success    bool      `json:"success"`
-}
\ No newline at end of file
+	ID         int64         `json:"id"`
+	// PostID is the ID of the post in its log lines and state file
+	PostID     string        `json:"post_id,omitempty"`
+	FileName   string        `json:"file_name"`
+	FileSize   int64         `json:"file_size"`
+	Groups     []string      `json:"groups"`
+	NZBPath    string        `json:"nzb_path,omitempty"`
+	MessageIDs int           `json:"message_ids"`
+	PostedAt   time.Time     `json:"posted_at"`
+	Duration   time.Duration `json:"duration"`
+	Success    bool          `json:"success"`
+	Error      string        `json:"error,omitempty"`
+	// Server is the host that accepted most articles of the post
+	Server  string `json:"server,omitempty"`
+	Retries int    `json:"retries"`
+	// Failed is the number of articles no server accepted; a post with
+	// some is incomplete, its NZB only listing the articles posted
+	Failed int `json:"failed,omitempty"`
+	// ContentHash is the hash of the files posted, by which the same
+	// content is recognized under other names
+	ContentHash string `json:"content_hash,omitempty"`
+	// RoundTrip is RoundTripPassed or RoundTripFailed once the post was
+	// downloaded back, empty when it was not
+	RoundTrip string `json:"round_trip,omitempty"`
+}
+
+// Outcomes of the round trip of a post
+const (
+	RoundTripPassed = "passed"
+	RoundTripFailed = "failed"
+)
\ No newline at end of file
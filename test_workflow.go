@@ -1,6 +1,9 @@
+//go:build ignore
+
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -41,7 +44,9 @@ func testCompleteWorkflow() {
 	defer os.RemoveAll(outputDir)
 
 	// Initialize components
+	ctx := context.Background()
 	split := splitter.NewSplitter(2048) // Small parts for testing (2KB each)
+	diskParts := splitter.Options{Storage: splitter.StorageDisk, OutputDir: outputDir}
 	yencEnc := yenc.Encoder{}
 	testPoster := "test@example.com"
 	nzbGen := nzb.NewGenerator(outputDir, testPoster)
@@ -50,7 +55,7 @@ func testCompleteWorkflow() {
 
 	// Test file splitting
 	fmt.Println("1. Testing file splitting...")
-	parts, err := split.SplitFile(testFile, outputDir)
+	parts, err := split.Split(ctx, testFile, diskParts)
 	if err != nil {
 		fmt.Printf("Failed to split file: %v\n", err)
 		return
@@ -97,7 +102,7 @@ func testCompleteWorkflow() {
 			fmt.Printf("Failed to read part file: %v\n", err)
 			continue
 		}
-		encoded := yencEnc.Encode(data, part.FileName, part.PartNumber, len(parts))
+		encoded := yencEnc.Encode(data, yenc.Article{Name: part.FileName})
 		segment := &models.PostSegment{
 			MessageID:   fmt.Sprintf("<test-%d@example.com>", i),
 			PartNumber:  part.PartNumber,
@@ -113,7 +118,7 @@ func testCompleteWorkflow() {
 	// Simulate posting PAR2 files
 	var par2Segments []*models.PostSegment
 	for _, par2File := range par2Files {
-		par2Parts, err := split.SplitFile(par2File, outputDir)
+		par2Parts, err := split.Split(ctx, par2File, diskParts)
 		if err != nil {
 			fmt.Printf("Failed to split PAR2 file: %v\n", err)
 			continue
@@ -124,7 +129,7 @@ func testCompleteWorkflow() {
 				fmt.Printf("Failed to read PAR2 part file: %v\n", err)
 				continue
 			}
-			encoded := yencEnc.Encode(data, part.FileName, part.PartNumber, len(par2Parts))
+			encoded := yencEnc.Encode(data, yenc.Article{Name: part.FileName})
 			segment := &models.PostSegment{
 				MessageID:   fmt.Sprintf("<par2-%d-%d@example.com>", i, part.PartNumber),
 				PartNumber:  part.PartNumber,
@@ -140,7 +145,7 @@ func testCompleteWorkflow() {
 
 	// Simulate posting SFV file
 	var sfvSegments []*models.PostSegment
-	sfvParts, err := split.SplitFile(sfvPath, outputDir)
+	sfvParts, err := split.Split(ctx, sfvPath, diskParts)
 	if err == nil {
 		for i, part := range sfvParts {
 			data, err := os.ReadFile(part.FilePath)
@@ -148,7 +153,7 @@ func testCompleteWorkflow() {
 				fmt.Printf("Failed to read SFV part file: %v\n", err)
 				continue
 			}
-			encoded := yencEnc.Encode(data, part.FileName, part.PartNumber, len(sfvParts))
+			encoded := yencEnc.Encode(data, yenc.Article{Name: part.FileName})
 			segment := &models.PostSegment{
 				MessageID:   fmt.Sprintf("<sfv-%d-%d@example.com>", i, part.PartNumber),
 				PartNumber:  part.PartNumber,